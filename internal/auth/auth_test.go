@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	encrypted, err := EncryptSecret("jwt-key", "s3cret")
+	assert.NoError(t, err)
+	assert.NotContains(t, encrypted, "s3cret")
+
+	plaintext, err := DecryptSecret("jwt-key", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	_, err = DecryptSecret("other-key", encrypted)
+	assert.Error(t, err, "should not decrypt with a different key")
+
+	_, err = EncryptSecret("", "s3cret")
+	assert.Error(t, err, "should require a key")
+}
+
+func TestParseAuthOrder(t *testing.T) {
+	assert.Equal(t, DefaultAuthOrder, ParseAuthOrder(""))
+	assert.Equal(t, []string{LDAP, LOCAL, OIDC}, ParseAuthOrder("ldap, local"))
+	assert.Equal(t, []string{OIDC, LOCAL, LDAP}, ParseAuthOrder("oidc,kerberos,oidc"))
+
+	assert.True(t, IsValidAuthOrder("oidc,ldap,local"))
+	assert.False(t, IsValidAuthOrder("ldap,local"))
+	assert.False(t, IsValidAuthOrder("ldap,ldap,local"))
+}
+
+func TestLDAPFilter(t *testing.T) {
+	assert.Equal(t, "(&(objectClass=person)(|(sAMAccountName=jdoe)(uid=jdoe)))", LDAPFilter("", DefaultLDAPUserFilter, "jdoe", ""))
+	assert.Equal(t, `(uid=\2a\29\28uid=\2a)`, LDAPFilter("(uid={username})", DefaultLDAPUserFilter, "*)(uid=*", ""), "should escape the username")
+	assert.Equal(t, `(member=CN=John \5c, Doe,DC=example)`, LDAPFilter("(member={dn})", "", "", `CN=John \, Doe,DC=example`))
+}
+
+func TestLDAPCN(t *testing.T) {
+	assert.Equal(t, "OpenUEM Admins", ldapCN("CN=OpenUEM Admins,OU=Groups,DC=example,DC=com"))
+	assert.Equal(t, "", ldapCN("OU=Groups,DC=example,DC=com"))
+	assert.Equal(t, "", ldapCN("not a dn"))
+}
+
+func TestSecretBox(t *testing.T) {
+	box := NewSecretBox(strings.Repeat("k", MinSecretsKeyLength))
+	sealed, err := box.Seal("s3cret")
+	assert.NoError(t, err)
+	assert.True(t, IsEncryptedSecret(sealed))
+
+	plaintext, err := box.Open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	empty, err := box.Seal("")
+	assert.NoError(t, err)
+	assert.Empty(t, empty, "should keep empty secrets empty")
+
+	plaintext, err = box.Open("not encrypted")
+	assert.NoError(t, err)
+	assert.Equal(t, "not encrypted", plaintext, "should return the secrets in plaintext as they are")
+
+	var noKey *SecretBox
+	stored, err := noKey.Seal("s3cret")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", stored, "should store the secrets in plaintext without a key")
+	_, err = noKey.Open(sealed)
+	assert.ErrorIs(t, err, ErrSecretsKeyMissing)
+}
+
+func TestLoadSecretsKey(t *testing.T) {
+	key, err := LoadSecretsKey("", "")
+	assert.NoError(t, err)
+	assert.Empty(t, key, "the secrets key is optional")
+
+	_, err = LoadSecretsKey("short", "")
+	assert.Error(t, err, "should require a long key")
+
+	keyFile := filepath.Join(t.TempDir(), "secrets.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat("f", MinSecretsKeyLength)+"\n"), 0o600))
+	key, err = LoadSecretsKey("", keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("f", MinSecretsKeyLength), key, "should trim the key read from the file")
+
+	_, err = LoadSecretsKey(strings.Repeat("k", MinSecretsKeyLength), keyFile)
+	assert.Error(t, err, "should not accept both a key and a key file")
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Security of the connection with the LDAP server
+const (
+	LDAPSecurityNone     = "none"
+	LDAPSecurityStartTLS = "starttls"
+	LDAPSecurityLDAPS    = "ldaps"
+)
+
+// Default filters, {username} is replaced by the escaped username and {dn} by the escaped DN of the user
+const (
+	DefaultLDAPUserFilter  = "(&(objectClass=person)(|(sAMAccountName={username})(uid={username})))"
+	DefaultLDAPGroupFilter = "(|(member={dn})(uniqueMember={dn})(memberUid={username}))"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// ErrLDAPInvalidCredentials is returned when the user is not found or the password is wrong
+var ErrLDAPInvalidCredentials = errors.New("invalid LDAP credentials")
+
+// LDAPConfig is how the console connects to the directory and finds the users and their groups
+type LDAPConfig struct {
+	Host         string
+	Port         int
+	Security     string
+	BindDN       string
+	BindPassword string
+	UserBaseDN   string
+	UserFilter   string
+	GroupBaseDN  string
+	GroupFilter  string
+}
+
+// LDAPUser is a user that has been authenticated by the directory
+type LDAPUser struct {
+	DN     string
+	Name   string
+	Email  string
+	Phone  string
+	Groups []string
+}
+
+// LDAPAuthenticate finds the user with the service account and binds as the user to check the password.
+// The password is only sent to the directory, it's never stored
+func LDAPAuthenticate(cfg LDAPConfig, username, password string) (*LDAPUser, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := ldapConnect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := ldapFindUser(conn, cfg, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("could not bind as %s: %w", entry.DN, err)
+	}
+
+	u := LDAPUser{
+		DN:    entry.DN,
+		Name:  entry.GetAttributeValue("displayName"),
+		Email: entry.GetAttributeValue("mail"),
+		Phone: entry.GetAttributeValue("telephoneNumber"),
+	}
+	if u.Name == "" {
+		u.Name = entry.GetAttributeValue("cn")
+	}
+	for _, dn := range entry.GetAttributeValues("memberOf") {
+		if cn := ldapCN(dn); cn != "" {
+			u.Groups = append(u.Groups, cn)
+		}
+	}
+
+	// The groups are searched with the service account, users may not be allowed to read them
+	if cfg.GroupBaseDN != "" {
+		if err := ldapBindService(conn, cfg); err != nil {
+			return nil, err
+		}
+		groups, err := ldapFindGroups(conn, cfg, username, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			if !containsFold(u.Groups, g) {
+				u.Groups = append(u.Groups, g)
+			}
+		}
+	}
+
+	return &u, nil
+}
+
+// LDAPTestConnection connects and binds with the service account and checks that the user base DN can be searched
+func LDAPTestConnection(cfg LDAPConfig) error {
+	conn, err := ldapConnect(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Search(ldap.NewSearchRequest(cfg.UserBaseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(ldapTimeout.Seconds()), false,
+		"(objectClass=*)", []string{"dn"}, nil))
+	if err != nil {
+		return fmt.Errorf("could not search the user base DN %s: %w", cfg.UserBaseDN, err)
+	}
+	return nil
+}
+
+func ldapConnect(cfg LDAPConfig) (*ldap.Conn, error) {
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var conn *ldap.Conn
+	var err error
+	switch cfg.Security {
+	case LDAPSecurityLDAPS:
+		conn, err = ldap.DialURL("ldaps://"+address, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	default:
+		conn, err = ldap.DialURL("ldap://"+address, ldap.DialWithDialer(dialer))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not connect with the LDAP server %s: %w", address, err)
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if cfg.Security == LDAPSecurityStartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not start TLS with the LDAP server %s: %w", address, err)
+		}
+	}
+
+	if err := ldapBindService(conn, cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func ldapBindService(conn *ldap.Conn, cfg LDAPConfig) error {
+	if cfg.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+		return fmt.Errorf("could not bind with the service account %s: %w", cfg.BindDN, err)
+	}
+	return nil
+}
+
+func ldapFindUser(conn *ldap.Conn, cfg LDAPConfig, username string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		LDAPFilter(cfg.UserFilter, DefaultLDAPUserFilter, username, ""),
+		[]string{"dn", "cn", "displayName", "mail", "telephoneNumber", "memberOf"}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("could not search the user %s: %w", username, err)
+	}
+
+	// The filter must match only one user, otherwise the user can't be told apart
+	if len(result.Entries) != 1 {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+func ldapFindGroups(conn *ldap.Conn, cfg LDAPConfig, username, dn string) ([]string, error) {
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest(cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false,
+		LDAPFilter(cfg.GroupFilter, DefaultLDAPGroupFilter, username, dn),
+		[]string{"cn"}, nil), 500)
+	if err != nil {
+		return nil, fmt.Errorf("could not search the groups of %s: %w", username, err)
+	}
+
+	groups := []string{}
+	for _, entry := range result.Entries {
+		if cn := entry.GetAttributeValue("cn"); cn != "" {
+			groups = append(groups, cn)
+		}
+	}
+	return groups, nil
+}
+
+// LDAPFilter replaces the placeholders of the filter, or of the default filter if it's empty, with the
+// escaped values so the username can't change the filter
+func LDAPFilter(filter, defaultFilter, username, dn string) string {
+	if strings.TrimSpace(filter) == "" {
+		filter = defaultFilter
+	}
+	return strings.NewReplacer("{username}", ldap.EscapeFilter(username), "{dn}", ldap.EscapeFilter(dn)).Replace(filter)
+}
+
+// ldapCN returns the common name of a DN, e.g. OpenUEM Admins for CN=OpenUEM Admins,OU=Groups,DC=example,DC=com
+func ldapCN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+)
+
+// Authentication methods that can be ordered
+const (
+	LOCAL = "local"
+	LDAP  = "ldap"
+	OIDC  = "oidc"
+)
+
+// DefaultAuthOrder tries the local accounts first, then LDAP and OpenID Connect
+var DefaultAuthOrder = []string{LOCAL, LDAP, OIDC}
+
+// ParseAuthOrder returns the methods of a comma separated order. Unknown or repeated methods are
+// ignored and the missing ones are added in the default order
+func ParseAuthOrder(order string) []string {
+	methods := []string{}
+	for _, m := range strings.Split(order, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if slices.Contains(DefaultAuthOrder, m) && !slices.Contains(methods, m) {
+			methods = append(methods, m)
+		}
+	}
+	for _, m := range DefaultAuthOrder {
+		if !slices.Contains(methods, m) {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// IsValidAuthOrder returns if the order has every method once
+func IsValidAuthOrder(order string) bool {
+	methods := strings.Split(order, ",")
+	return len(methods) == len(DefaultAuthOrder) && slices.Equal(ParseAuthOrder(order), trimAll(methods))
+}
+
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		trimmed = append(trimmed, strings.ToLower(strings.TrimSpace(v)))
+	}
+	return trimmed
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// Limits of the password policy
+const (
+	DefaultPasswordMinLength = 15
+	MinPasswordMinLength     = 8
+	PasswordMaxLength        = 64
+	MaxPasswordHistory       = 24
+)
+
+// Rules of the password policy, they're used as the keys of the error messages
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleUppercase = "uppercase"
+	PasswordRuleLowercase = "lowercase"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSymbol    = "symbol"
+	PasswordRuleCommon    = "common"
+	PasswordRuleHistory   = "history"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+var commonPasswords = func() map[string]struct{} {
+	passwords := map[string]struct{}{}
+	for _, p := range strings.Split(commonPasswordsList, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			passwords[strings.ToLower(p)] = struct{}{}
+		}
+	}
+	return passwords
+}()
+
+// PasswordPolicy are the requirements of the passwords of the local accounts
+type PasswordPolicy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowCommon   bool
+	// History is how many of the last passwords, including the current one, can't be reused
+	History int
+	// MaxAgeDays is how long a password is valid, 0 if it doesn't expire
+	MaxAgeDays int
+}
+
+// PasswordPolicyError is the rule that a password doesn't comply with. Value is the limit of the rule if it has one
+type PasswordPolicyError struct {
+	Rule  string
+	Value int
+}
+
+func (e *PasswordPolicyError) Error() string {
+	switch e.Rule {
+	case PasswordRuleMinLength:
+		return fmt.Sprintf("the password must have at least %d characters", e.Value)
+	case PasswordRuleMaxLength:
+		return fmt.Sprintf("the password can't have more than %d characters", e.Value)
+	case PasswordRuleUppercase:
+		return "the password must have an uppercase letter"
+	case PasswordRuleLowercase:
+		return "the password must have a lowercase letter"
+	case PasswordRuleDigit:
+		return "the password must have a digit"
+	case PasswordRuleSymbol:
+		return "the password must have a symbol"
+	case PasswordRuleCommon:
+		return "the password is too common"
+	case PasswordRuleHistory:
+		return fmt.Sprintf("the password can't be one of the last %d passwords", e.Value)
+	default:
+		return "the password doesn't comply with the password policy"
+	}
+}
+
+// EffectiveMinLength is the minimum length of the policy, the default one if it hasn't been set
+func (p PasswordPolicy) EffectiveMinLength() int {
+	if p.MinLength < 1 {
+		return DefaultPasswordMinLength
+	}
+	return p.MinLength
+}
+
+// Validate checks the length and the characters of the password and if it's a common password
+func (p PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.EffectiveMinLength() {
+		return &PasswordPolicyError{Rule: PasswordRuleMinLength, Value: p.EffectiveMinLength()}
+	}
+	if length > PasswordMaxLength {
+		return &PasswordPolicyError{Rule: PasswordRuleMaxLength, Value: PasswordMaxLength}
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	switch {
+	case p.RequireUppercase && !upper:
+		return &PasswordPolicyError{Rule: PasswordRuleUppercase}
+	case p.RequireLowercase && !lower:
+		return &PasswordPolicyError{Rule: PasswordRuleLowercase}
+	case p.RequireDigit && !digit:
+		return &PasswordPolicyError{Rule: PasswordRuleDigit}
+	case p.RequireSymbol && !symbol:
+		return &PasswordPolicyError{Rule: PasswordRuleSymbol}
+	case p.DisallowCommon && IsCommonPassword(password):
+		return &PasswordPolicyError{Rule: PasswordRuleCommon}
+	}
+
+	return nil
+}
+
+// CheckHistory returns an error if the password matches the current hash or one of the previous hashes
+// kept by the policy, the most recent first
+func (p PasswordPolicy) CheckHistory(password, currentHash string, previousHashes []string) error {
+	if p.History < 1 {
+		return nil
+	}
+
+	hashes := append([]string{currentHash}, previousHashes...)
+	if len(hashes) > p.History {
+		hashes = hashes[:p.History]
+	}
+
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		match, err := argon2id.ComparePasswordAndHash(password, hash)
+		if err != nil {
+			return err
+		}
+		if match {
+			return &PasswordPolicyError{Rule: PasswordRuleHistory, Value: p.History}
+		}
+	}
+
+	return nil
+}
+
+// NextHistory returns the previous hashes to keep when the current hash is replaced by a new password
+func (p PasswordPolicy) NextHistory(currentHash string, previousHashes []string) []string {
+	history := []string{}
+	if p.History < 2 {
+		return history
+	}
+
+	if currentHash != "" {
+		history = append(history, currentHash)
+	}
+	history = append(history, previousHashes...)
+	if len(history) > p.History-1 {
+		history = history[:p.History-1]
+	}
+	return history
+}
+
+// Expired returns if a password set at changedAt must be changed
+func (p PasswordPolicy) Expired(changedAt, now time.Time) bool {
+	if p.MaxAgeDays < 1 || changedAt.IsZero() {
+		return false
+	}
+	return now.After(changedAt.AddDate(0, 0, p.MaxAgeDays))
+}
+
+// IsCommonPassword returns if the password is in the embedded list of common passwords, ignoring the case
+func IsCommonPassword(password string) bool {
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func passwordRule(err error) string {
+	var policyErr *PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr.Rule
+	}
+	return ""
+}
+
+func TestPasswordPolicyMinLength(t *testing.T) {
+	p := PasswordPolicy{}
+	assert.Equal(t, PasswordRuleMinLength, passwordRule(p.Validate("fourteen-chars")), "should use the default minimum length")
+	assert.NoError(t, p.Validate("fifteen-chars-x"))
+
+	p.MinLength = 8
+	assert.Equal(t, PasswordRuleMinLength, passwordRule(p.Validate("seven-c")))
+	assert.NoError(t, p.Validate("eight-ch"))
+	assert.NoError(t, p.Validate("ñandúñan"), "should count characters, not bytes")
+
+	var policyErr *PasswordPolicyError
+	require.ErrorAs(t, p.Validate("short"), &policyErr)
+	assert.Equal(t, 8, policyErr.Value)
+}
+
+func TestPasswordPolicyMaxLength(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8}
+	assert.NoError(t, p.Validate(strings.Repeat("x", PasswordMaxLength)))
+	assert.Equal(t, PasswordRuleMaxLength, passwordRule(p.Validate(strings.Repeat("x", PasswordMaxLength+1))))
+}
+
+func TestPasswordPolicyCharacterClasses(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8, RequireUppercase: true}
+	assert.Equal(t, PasswordRuleUppercase, passwordRule(p.Validate("lowercase only")))
+	assert.NoError(t, p.Validate("One Uppercase"))
+
+	p = PasswordPolicy{MinLength: 8, RequireLowercase: true}
+	assert.Equal(t, PasswordRuleLowercase, passwordRule(p.Validate("UPPERCASE ONLY")))
+	assert.NoError(t, p.Validate("ONE LOWERCASe"))
+
+	p = PasswordPolicy{MinLength: 8, RequireDigit: true}
+	assert.Equal(t, PasswordRuleDigit, passwordRule(p.Validate("no digits here")))
+	assert.NoError(t, p.Validate("one digit 7"))
+
+	p = PasswordPolicy{MinLength: 8, RequireSymbol: true}
+	assert.Equal(t, PasswordRuleSymbol, passwordRule(p.Validate("NoSymbols123")))
+	assert.NoError(t, p.Validate("Symbol#123"))
+	assert.NoError(t, p.Validate("with spaces"), "a space should count as a symbol")
+}
+
+func TestPasswordPolicyCommonPasswords(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8}
+	assert.NoError(t, p.Validate("password123"), "common passwords should be allowed unless disallowed")
+
+	p.DisallowCommon = true
+	assert.Equal(t, PasswordRuleCommon, passwordRule(p.Validate("password123")))
+	assert.Equal(t, PasswordRuleCommon, passwordRule(p.Validate("PASSWORD123")), "should ignore the case")
+	assert.NoError(t, p.Validate("tidy-otter-lamp"))
+
+	assert.True(t, IsCommonPassword("qwerty123"))
+	assert.False(t, IsCommonPassword(""))
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	params := &argon2id.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hashes := []string{}
+	for _, password := range []string{"current", "previous-1", "previous-2"} {
+		hash, err := argon2id.CreateHash(password, params)
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+
+	p := PasswordPolicy{}
+	assert.NoError(t, p.CheckHistory("current", hashes[0], hashes[1:]), "no history should allow reusing passwords")
+
+	p.History = 2
+	assert.Equal(t, PasswordRuleHistory, passwordRule(p.CheckHistory("current", hashes[0], hashes[1:])))
+	assert.Equal(t, PasswordRuleHistory, passwordRule(p.CheckHistory("previous-1", hashes[0], hashes[1:])))
+	assert.NoError(t, p.CheckHistory("previous-2", hashes[0], hashes[1:]), "should only check the last passwords")
+	assert.NoError(t, p.CheckHistory("new", hashes[0], hashes[1:]))
+
+	assert.Equal(t, []string{hashes[0]}, p.NextHistory(hashes[0], hashes[1:]))
+	p.History = 3
+	assert.Equal(t, []string{hashes[0], hashes[1]}, p.NextHistory(hashes[0], hashes[1:]))
+	assert.Empty(t, PasswordPolicy{History: 1}.NextHistory(hashes[0], hashes[1:]))
+}
+
+func TestPasswordPolicyMaxAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	p := PasswordPolicy{}
+	assert.False(t, p.Expired(now.AddDate(-5, 0, 0), now), "passwords should not expire without a maximum age")
+
+	p.MaxAgeDays = 90
+	assert.False(t, p.Expired(now.AddDate(0, 0, -89), now))
+	assert.True(t, p.Expired(now.AddDate(0, 0, -91), now))
+	assert.False(t, p.Expired(time.Time{}, now), "an unknown change date should not expire")
+}
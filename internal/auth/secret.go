@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptSecret encrypts a secret stored in the database, e.g. the password of the LDAP service account,
+// with AES-GCM. The key is derived from a secret of the server, such as the JWT key, so the database
+// alone is not enough to read it
+func EncryptSecret(key, plaintext string) (string, error) {
+	aesGCM, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("could not generate the nonce: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// DecryptSecret decrypts a secret encrypted with EncryptSecret and the same key
+func DecryptSecret(key, ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+
+	aesGCM, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("could not decode the secret: %w", err)
+	}
+	if len(data) < aesGCM.NonceSize() {
+		return "", errors.New("the secret is not valid")
+	}
+
+	plaintext, err := aesGCM.Open(nil, data[:aesGCM.NonceSize()], data[aesGCM.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt the secret, maybe the key has changed: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func secretCipher(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, errors.New("the encryption key is empty")
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("could not create the AES cipher block: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedSecretPrefix marks the values encrypted with a SecretBox, values without it are in plaintext
+const EncryptedSecretPrefix = "enc:v1:"
+
+// MinSecretsKeyLength is the minimum length of the key that encrypts the secrets stored in the database
+const MinSecretsKeyLength = 32
+
+// ErrSecretsKeyMissing is returned when a secret is encrypted but no key has been set to decrypt it
+var ErrSecretsKeyMissing = errors.New("the secret is encrypted but the secrets key has not been set")
+
+// SecretBox encrypts the secrets stored in the database, e.g. the SMTP passwords, with a key that's not
+// stored in the database. Without a key, secrets are stored in plaintext
+type SecretBox struct {
+	key string
+}
+
+func NewSecretBox(key string) *SecretBox {
+	return &SecretBox{key: key}
+}
+
+// Enabled returns if the box has a key to encrypt the secrets
+func (b *SecretBox) Enabled() bool {
+	return b != nil && b.key != ""
+}
+
+// Seal encrypts a secret, empty secrets are kept empty so they can still be told apart
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	if plaintext == "" || !b.Enabled() {
+		return plaintext, nil
+	}
+
+	encrypted, err := EncryptSecret(b.key, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return EncryptedSecretPrefix + encrypted, nil
+}
+
+// Open decrypts a secret sealed by a box with the same key, values in plaintext are returned as they are
+func (b *SecretBox) Open(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	if !b.Enabled() {
+		return "", ErrSecretsKeyMissing
+	}
+	return DecryptSecret(b.key, strings.TrimPrefix(value, EncryptedSecretPrefix))
+}
+
+// IsEncryptedSecret returns if the value has been sealed by a SecretBox
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, EncryptedSecretPrefix)
+}
+
+// LoadSecretsKey returns the secrets key, read from keyFile if it's set, e.g. a file mounted from a KMS.
+// An empty key means that the secrets are not encrypted
+func LoadSecretsKey(key, keyFile string) (string, error) {
+	if keyFile != "" {
+		if key != "" {
+			return "", errors.New("the secrets key and the secrets key file can't be used together")
+		}
+
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read the secrets key file: %w", err)
+		}
+		if key = strings.TrimSpace(string(data)); key == "" {
+			return "", fmt.Errorf("the secrets key file %s is empty", keyFile)
+		}
+	}
+
+	if key != "" && len(key) < MinSecretsKeyLength {
+		return "", fmt.Errorf("the secrets key must have at least %d characters", MinSecretsKeyLength)
+	}
+	return key, nil
+}
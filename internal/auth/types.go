@@ -0,0 +1,8 @@
+package auth
+
+// Types of the user accounts, they set how the user logs in
+const (
+	PASSWORD_AUTH     = "passwd"
+	CERTIFICATES_AUTH = "certificate"
+	OIDC_AUTH         = "oidc"
+)
@@ -35,7 +35,7 @@ func (command *ConsoleCommand) CheckRequisites(cCtx *cli.Context) error {
 	log.Println("... connecting to database")
 	command.DBUrl = cCtx.String("dburl")
 	command.Domain = cCtx.String("domain")
-	command.Model, err = models.New(command.DBUrl, "pgx", command.Domain)
+	command.Model, err = models.New(command.DBUrl, "pgx", command.Domain, models.DBConfig{})
 	if err != nil {
 		log.Fatalf("[FATAL]: could not connect to database, reason: %s", err.Error())
 	}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+func ResetPassword() *cli.Command {
+	return &cli.Command{
+		Name:   "reset-password",
+		Usage:  "Set a new password for a local user account, it must comply with the password policy. It's asked in the terminal, or read from NEW_PASSWORD or the standard input",
+		Action: resetPassword,
+		Flags:  ResetPasswordFlags(),
+	}
+}
+
+func resetPassword(cCtx *cli.Context) error {
+	username := cCtx.String("user")
+
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	model, err := models.New(cCtx.String("dburl"), "pgx", "", models.DBConfig{})
+	if err != nil {
+		log.Fatalf("[FATAL]: could not connect to database: %v", err)
+	}
+	defer model.Close()
+
+	u, err := model.GetUserById(username)
+	if err != nil {
+		return fmt.Errorf("could not get the user %s: %w", username, err)
+	}
+	if !u.Passwd || u.Ldap {
+		return fmt.Errorf("the user %s doesn't log in with a local password", username)
+	}
+
+	if err := model.CheckPasswordPolicy(username, password); err != nil {
+		return fmt.Errorf("the password doesn't comply with the password policy: %w", err)
+	}
+
+	if err := model.ChangePassword(username, password); err != nil {
+		return fmt.Errorf("could not change the password of %s: %w", username, err)
+	}
+
+	if cCtx.Bool("must-change") {
+		if err := model.ForcePasswordChange(username); err != nil {
+			return fmt.Errorf("could not force the password change of %s: %w", username, err)
+		}
+	}
+
+	log.Printf("[INFO]: the password of user %s has been changed", username)
+	return nil
+}
+
+// readNewPassword reads the new password from the NEW_PASSWORD environment variable or from the standard input.
+// In a terminal the password isn't echoed and it's asked twice, it's never read from a flag so it isn't shown
+// in the processes list or in the shell history
+func readNewPassword() (string, error) {
+	if password := os.Getenv("NEW_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("could not read the new password: %w", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", errors.New("the new password is empty")
+		}
+		return password, nil
+	}
+
+	fmt.Print("New password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("could not read the new password: %w", err)
+	}
+	if len(password) == 0 {
+		return "", errors.New("the new password is empty")
+	}
+
+	fmt.Print("Repeat the new password: ")
+	confirmation, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("could not read the new password: %w", err)
+	}
+	if string(confirmation) != string(password) {
+		return "", errors.New("the passwords don't match")
+	}
+
+	return string(password), nil
+}
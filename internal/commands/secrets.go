@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+func EncryptSecrets() *cli.Command {
+	return &cli.Command{
+		Name:   "encrypt-secrets",
+		Usage:  "Encrypt with the secrets key the secrets stored in plaintext in the database, e.g. the SMTP passwords",
+		Action: encryptSecrets,
+		Flags:  EncryptSecretsFlags(),
+	}
+}
+
+func RotateSecretsKey() *cli.Command {
+	return &cli.Command{
+		Name:   "rotate-secrets-key",
+		Usage:  "Decrypt the secrets stored in the database with the current secrets key and encrypt them with a new one",
+		Action: rotateSecretsKey,
+		Flags:  RotateSecretsKeyFlags(),
+	}
+}
+
+func encryptSecrets(cCtx *cli.Context) error {
+	key, err := auth.LoadSecretsKey(cCtx.String("secrets-key"), cCtx.String("secrets-key-file"))
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("the secrets key is required, set it with --secrets-key or --secrets-key-file")
+	}
+
+	model, err := models.New(cCtx.String("dburl"), "pgx", "", models.DBConfig{}, models.WithSecrets(auth.NewSecretBox(key)))
+	if err != nil {
+		log.Fatalf("[FATAL]: could not connect to database: %v", err)
+	}
+	defer model.Close()
+
+	if _, err := model.CheckSecrets(); err != nil {
+		return fmt.Errorf("the secrets key is not the key the encrypted secrets were encrypted with: %w", err)
+	}
+
+	n, err := model.EncryptSecrets(cCtx.String("jwt-key"))
+	if err != nil {
+		return fmt.Errorf("could not encrypt the secrets, none has been changed: %w", err)
+	}
+
+	log.Printf("[INFO]: %d secrets have been encrypted", n)
+	return nil
+}
+
+func rotateSecretsKey(cCtx *cli.Context) error {
+	key, err := auth.LoadSecretsKey(cCtx.String("secrets-key"), cCtx.String("secrets-key-file"))
+	if err != nil {
+		return err
+	}
+	newKey, err := auth.LoadSecretsKey(cCtx.String("new-secrets-key"), cCtx.String("new-secrets-key-file"))
+	if err != nil {
+		return err
+	}
+	if key == "" || newKey == "" {
+		return errors.New("both the current and the new secrets keys are required")
+	}
+	if key == newKey {
+		return errors.New("the new secrets key must be different from the current one")
+	}
+
+	model, err := models.New(cCtx.String("dburl"), "pgx", "", models.DBConfig{}, models.WithSecrets(auth.NewSecretBox(key)))
+	if err != nil {
+		log.Fatalf("[FATAL]: could not connect to database: %v", err)
+	}
+	defer model.Close()
+
+	n, err := model.RotateSecretsKey(auth.NewSecretBox(newKey))
+	if err != nil {
+		return fmt.Errorf("could not rotate the secrets key, none of the secrets has been changed: %w", err)
+	}
+
+	log.Printf("[INFO]: %d secrets have been encrypted with the new key, restart the console with it", n)
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/open-uem/openuem-console/internal/common"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 	"github.com/open-uem/utils"
 	"github.com/urfave/cli/v2"
 )
@@ -55,6 +56,12 @@ func startConsole(cCtx *cli.Context) error {
 		log.Fatalf("[FATAL]: could not create server releases temp dir: %v", err)
 	}
 
+	// Create the database backups directory, local backups are disabled if it's not valid
+	if err := worker.CreateBackupDir(); err != nil {
+		log.Printf("[ERROR]: could not create the database backups dir, local backups are disabled: %v", err)
+		worker.Backups.Dir = ""
+	}
+
 	// Create common software directory
 	worker.CommonSoftwareDBFolder = filepath.Join(cwd, "tmp", "commondb")
 	if strings.HasSuffix(cwd, "tmp") {
@@ -70,7 +77,7 @@ func startConsole(cCtx *cli.Context) error {
 	}
 
 	// Start Task Scheduler
-	worker.TaskScheduler, err = gocron.NewScheduler()
+	worker.TaskScheduler, err = gocron.NewScheduler(telemetry.SchedulerOptions()...)
 	if err != nil {
 		log.Fatalf("[FATAL]: could not create task scheduler, reason: %s", err.Error())
 	}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/locales"
+	"github.com/urfave/cli/v2"
+)
+
+func SendWeeklyReports() *cli.Command {
+	return &cli.Command{
+		Name:   "send-weekly-reports",
+		Usage:  "Send the weekly agent status report to the admins of every tenant",
+		Action: sendWeeklyReports,
+		Flags:  SendWeeklyReportsFlags(),
+	}
+}
+
+func sendWeeklyReports(cCtx *cli.Context) error {
+	secretsKey, err := auth.LoadSecretsKey(cCtx.String("secrets-key"), cCtx.String("secrets-key-file"))
+	if err != nil {
+		return err
+	}
+
+	// The reports are written in the language of each tenant
+	translations, err := locales.WithFallback("en")
+	if err != nil {
+		return err
+	}
+	if err := ctxi18n.LoadWithDefault(translations, "en"); err != nil {
+		return err
+	}
+
+	model, err := models.New(cCtx.String("dburl"), "pgx", "", models.DBConfig{}, models.WithSecrets(auth.NewSecretBox(secretsKey)))
+	if err != nil {
+		log.Fatalf("[FATAL]: could not connect to database: %v", err)
+	}
+	defer model.Close()
+
+	// SMTP flags override the SMTP settings stored for each tenant, the reports are sent right away with
+	// them. Otherwise the reports are queued and the console delivers them
+	var smtpSettings *models.SMTPSettings
+	if cCtx.String("smtp-server") != "" {
+		smtpSettings = &models.SMTPSettings{
+			Server:   cCtx.String("smtp-server"),
+			Port:     cCtx.Int("smtp-port"),
+			User:     cCtx.String("smtp-user"),
+			Password: cCtx.String("smtp-password"),
+			Auth:     cCtx.String("smtp-auth"),
+			MailFrom: cCtx.String("smtp-from"),
+		}
+	}
+
+	tenants, err := model.GetTenants()
+	if err != nil {
+		log.Fatalf("[FATAL]: could not get tenants: %v", err)
+	}
+
+	for _, t := range tenants {
+		recipients, err := model.GetTenantAdminEmails(t.ID)
+		if err != nil {
+			log.Printf("[ERROR]: could not get admins for tenant %s: %v", t.Description, err)
+			continue
+		}
+
+		if len(recipients) == 0 {
+			log.Printf("[INFO]: tenant %s has no admins with an email address, skipping weekly report", t.Description)
+			continue
+		}
+
+		if smtpSettings != nil {
+			err = model.SendWeeklyReportEmailWithSettings(t.ID, recipients, smtpSettings)
+		} else {
+			err = model.EnqueueWeeklyReportEmail(t.ID, recipients)
+		}
+		if err != nil {
+			log.Printf("[ERROR]: could not send weekly report for tenant %s: %v", t.Description, err)
+			continue
+		}
+
+		if smtpSettings != nil {
+			log.Printf("[INFO]: weekly report for tenant %s has been sent to %d recipient(s)", t.Description, len(recipients))
+		} else {
+			log.Printf("[INFO]: weekly report for tenant %s has been queued for %d recipient(s)", t.Description, len(recipients))
+		}
+	}
+
+	return nil
+}
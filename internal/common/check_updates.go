@@ -12,6 +12,35 @@ import (
 	"github.com/open-uem/utils"
 )
 
+// StartReleaseChecks starts the jobs that look for new OpenUEM releases in the releases endpoint, unless
+// they're disabled as air-gapped deployments can't reach it. The first check runs in the background,
+// the console doesn't wait for the endpoint to start
+func (w *Worker) StartReleaseChecks() {
+	if !w.CheckUpdates {
+		log.Println("[INFO]: the checks for new OpenUEM releases are disabled")
+		return
+	}
+
+	go func() {
+		channel, err := w.Model.GetDefaultUpdateChannel()
+		if err != nil {
+			log.Println("[ERROR]: could not get updates channel settings")
+			channel = "stable"
+		}
+
+		// Start a job to download server releases version
+		if err := w.StartServerReleasesDownloadJob(); err != nil {
+			log.Printf("[ERROR]: could not start server releases download job, reason: %s", err.Error())
+			return
+		}
+
+		// Start a job to check latest OpenUEM releases
+		if err := w.StartCheckLatestReleasesJob(channel); err != nil {
+			log.Printf("[ERROR]: could not start check latest releases job, reason: %s", err.Error())
+		}
+	}()
+}
+
 func (w *Worker) StartCheckLatestReleasesJob(channel string) error {
 	if err := w.GetLatestReleases(channel); err != nil {
 		log.Printf("[ERROR]: could not get latest agent releases, reason: %v", err)
@@ -1,6 +1,10 @@
 package common
 
 import (
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/telemetry"
+	"github.com/open-uem/openuem-console/internal/version"
 	"github.com/open-uem/utils"
 	"github.com/urfave/cli/v2"
 )
@@ -53,18 +57,47 @@ func (w *Worker) GenerateConsoleConfigFromCLI(cCtx *cli.Context) error {
 	w.Country = cCtx.String("country")
 	w.ReverseProxyAuthPort = cCtx.String("reverse-proxy-auth-port")
 	w.ReverseProxyServer = cCtx.String("reverse-proxy-server")
+	w.TrustedProxies = cCtx.String("trusted-proxies")
 	w.ReenableCertAuth = cCtx.Bool("re-enable-certificates-auth")
 	w.ReenablePasswdAuth = cCtx.Bool("re-enable-passwd-auth")
 	w.ResetOpenUEMUser = cCtx.Bool("reset-openuem-user")
+	w.CacheTTL = cCtx.Duration("cache-ttl")
+	w.RedisURL = cCtx.String("redis-url")
+	w.RoleCacheTTL = cCtx.Duration("role-cache-ttl")
+	w.ShutdownTimeout = cCtx.Duration("shutdown-timeout")
+	w.DeletedAgentsRetention = cCtx.Duration("deleted-agents-retention")
+	w.AgentPlaceholderRetention = cCtx.Duration("agent-placeholder-retention")
+	w.DBConfig = models.DBConfig{
+		MaxOpenConns:       cCtx.Int("db-max-open-conns"),
+		MaxIdleConns:       cCtx.Int("db-max-idle-conns"),
+		ConnMaxLifetime:    cCtx.Duration("db-conn-max-lifetime"),
+		SlowQueryThreshold: cCtx.Duration("db-slow-query-threshold"),
+	}
 	w.RepoPort = cCtx.String("repo-port")
 	if w.RepoPort == "" {
 		w.RepoPort = "8443"
 	}
+	w.Backups = models.BackupConfig{
+		Dir:        cCtx.String("backup-dir"),
+		PGDumpPath: cCtx.String("pg-dump-path"),
+	}
 	w.RepoCACertPath = cCtx.String("repo-cacert")
 	if w.RepoCACertPath == "" {
 		w.RepoCACertPath = w.CACertPath
 	}
-	w.Version = "0.12.0"
+	w.ChecksumURL = cCtx.String("checksum-url")
+	w.AgentReleaseBaseURL = cCtx.String("agent-release-url")
+	w.CheckUpdates = cCtx.Bool("check-updates")
+	w.Tracing = telemetry.Config{
+		Endpoint:   cCtx.String("otel-endpoint"),
+		SampleRate: cCtx.Float64("otel-sample-rate"),
+	}
+
+	w.SecretsKey, err = auth.LoadSecretsKey(cCtx.String("secrets-key"), cCtx.String("secrets-key-file"))
+	if err != nil {
+		return err
+	}
+	w.Version = version.Get("0.12.0")
 
 	return nil
 }
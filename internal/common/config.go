@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/version"
 	"github.com/open-uem/utils"
 	"gopkg.in/ini.v1"
 )
@@ -157,6 +159,11 @@ func (w *Worker) GenerateConsoleConfig() error {
 	}
 	w.ReverseProxyServer = key.String()
 
+	key, err = cfg.Section("Console").GetKey("trustedproxies")
+	if err == nil {
+		w.TrustedProxies = key.String()
+	}
+
 	key, err = cfg.Section("Console").GetKey("reenablecertauth")
 	if err == nil {
 		w.ReenableCertAuth, err = key.Bool()
@@ -181,11 +188,146 @@ func (w *Worker) GenerateConsoleConfig() error {
 		}
 	}
 
-	key, err = cfg.Section("Server").GetKey("Version")
+	key, err = cfg.Section("Console").GetKey("cachettl")
+	if err == nil {
+		w.CacheTTL, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("redisurl")
+	if err == nil {
+		w.RedisURL = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("rolecachettl")
+	if err == nil {
+		w.RoleCacheTTL, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("shutdowntimeout")
+	if err == nil {
+		w.ShutdownTimeout, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("deletedagentsretention")
+	if err == nil {
+		w.DeletedAgentsRetention, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("agentplaceholderretention")
+	if err == nil {
+		w.AgentPlaceholderRetention, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("dbmaxopenconns")
+	if err == nil {
+		w.DBConfig.MaxOpenConns, err = key.Int()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("dbmaxidleconns")
+	if err == nil {
+		w.DBConfig.MaxIdleConns, err = key.Int()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("dbconnmaxlifetime")
+	if err == nil {
+		w.DBConfig.ConnMaxLifetime, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("dbslowquerythreshold")
+	if err == nil {
+		w.DBConfig.SlowQueryThreshold, err = key.Duration()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("backupdir")
+	if err == nil {
+		w.Backups.Dir = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("pgdumppath")
+	if err == nil {
+		w.Backups.PGDumpPath = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("checksumurl")
+	if err == nil {
+		w.ChecksumURL = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("agentreleaseurl")
+	if err == nil {
+		w.AgentReleaseBaseURL = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("checkupdates")
+	if err == nil {
+		w.CheckUpdates, err = key.Bool()
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err = cfg.Section("Console").GetKey("otelendpoint")
+	if err == nil {
+		w.Tracing.Endpoint = key.String()
+	}
+
+	key, err = cfg.Section("Console").GetKey("otelsamplerate")
+	if err == nil {
+		w.Tracing.SampleRate, err = key.Float64()
+		if err != nil {
+			return err
+		}
+	}
+
+	var secretsKey, secretsKeyFile string
+	if key, err = cfg.Section("Console").GetKey("secretskey"); err == nil {
+		secretsKey = key.String()
+	}
+	if key, err = cfg.Section("Console").GetKey("secretskeyfile"); err == nil {
+		secretsKeyFile = key.String()
+	}
+	w.SecretsKey, err = auth.LoadSecretsKey(secretsKey, secretsKeyFile)
 	if err != nil {
 		return err
 	}
-	w.Version = key.String()
+
+	// The version set when the console was built wins over the one written by the installer
+	key, err = cfg.Section("Server").GetKey("Version")
+	if err != nil && version.Version == "" {
+		return err
+	}
+	if err == nil {
+		w.Version = version.Get(key.String())
+	} else {
+		w.Version = version.Version
+	}
 
 	return nil
 }
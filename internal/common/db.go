@@ -3,9 +3,11 @@ package common
 import (
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"github.com/open-uem/openuem-console/internal/auth"
 	"github.com/open-uem/openuem-console/internal/controllers/authserver"
 	"github.com/open-uem/openuem-console/internal/controllers/reposerver"
 	"github.com/open-uem/openuem-console/internal/controllers/sessions"
@@ -16,16 +18,22 @@ import (
 func (w *Worker) StartDBConnectJob() error {
 	var err error
 
-	w.Model, err = models.New(w.DBUrl, "pgx", w.Domain)
+	w.Model, err = models.New(w.DBUrl, "pgx", w.Domain, w.DBConfig, w.modelOptions()...)
 	if err == nil {
 		log.Println("[INFO]: connection established with database")
+		w.Model.Cache = models.NewCache(w.CacheTTL)
+		w.Model.DeletedAgentsRetention = w.DeletedAgentsRetention
+		w.Model.AgentPlaceholderRetention = w.AgentPlaceholderRetention
+		w.Model.Backups = w.Backups
+		w.Model.DefaultBranding = w.DefaultBranding
+		w.checkSecrets()
 
 		if err := w.Model.CreateInitialSettings(); err != nil {
 			log.Println("[WARN]: could not create initial settings")
 		}
 
 		// Create default orgs and sites #feat-119
-		if err := w.Model.CreateDefaultTenantAndSite(); err != nil {
+		if _, err := w.Model.CreateDefaultTenantAndSite(); err != nil {
 			log.Println("[WARN]: could not create initial settings")
 		}
 
@@ -54,6 +62,21 @@ func (w *Worker) StartDBConnectJob() error {
 			log.Println("[WARN]: could not associate domain to default site")
 		}
 
+		// Allow the enrollment tokens bound to a site to enroll agents in it
+		if err := w.Model.MigrateEnrollmentTokenSites(); err != nil {
+			log.Println("[WARN]: could not migrate the sites of the enrollment tokens")
+		}
+
+		// The tenants with their own SMTP server in their settings keep sending their emails with it
+		if err := w.Model.MigrateTenantSMTPSettings(); err != nil {
+			log.Println("[WARN]: could not migrate the SMTP settings of the tenants")
+		}
+
+		// Concurrent consoles may have created the global branding and authentication settings twice
+		if err := w.Model.MigrateSingletons(); err != nil {
+			log.Println("[WARN]: could not merge the duplicated branding and authentication settings")
+		}
+
 		// Nickname uses the hostname as the default value
 		if err := w.Model.SetDefaultNickname(); err != nil {
 			log.Println("[WARN]: could not default nickname to default site")
@@ -66,21 +89,7 @@ func (w *Worker) StartDBConnectJob() error {
 
 		w.StartConsoleService()
 
-		// Start a job to check latest OpenUEM releases
-		channel, err := w.Model.GetDefaultUpdateChannel()
-		if err != nil {
-			log.Println("[ERROR]: could not get updates channel settings")
-			channel = "stable"
-		}
-
-		// Start a job to download server releases version
-		if err := w.StartServerReleasesDownloadJob(); err != nil {
-			log.Printf("[ERROR]: could not start server releases download job, reason: %s", err.Error())
-		}
-
-		if err := w.StartCheckLatestReleasesJob(channel); err != nil {
-			log.Printf("[ERROR]: could not start check latest releases job, reason: %s", err.Error())
-		}
+		w.StartReleaseChecks()
 
 		return nil
 	}
@@ -93,12 +102,18 @@ func (w *Worker) StartDBConnectJob() error {
 		),
 		gocron.NewTask(
 			func() {
-				w.Model, err = models.New(w.DBUrl, "pgx", w.Domain)
+				w.Model, err = models.New(w.DBUrl, "pgx", w.Domain, w.DBConfig, w.modelOptions()...)
 				if err != nil {
 					log.Printf("[ERROR]: could not connect with database %v", err)
 					return
 				}
 				log.Println("[INFO]: connection established with database")
+				w.Model.Cache = models.NewCache(w.CacheTTL)
+				w.Model.DeletedAgentsRetention = w.DeletedAgentsRetention
+				w.Model.AgentPlaceholderRetention = w.AgentPlaceholderRetention
+				w.Model.Backups = w.Backups
+				w.Model.DefaultBranding = w.DefaultBranding
+				w.checkSecrets()
 
 				if err := w.TaskScheduler.RemoveJob(w.DBConnectJob.ID()); err != nil {
 					return
@@ -109,7 +124,7 @@ func (w *Worker) StartDBConnectJob() error {
 				}
 
 				// Create default orgs and sites #feat-119
-				if err := w.Model.CreateDefaultTenantAndSite(); err != nil {
+				if _, err := w.Model.CreateDefaultTenantAndSite(); err != nil {
 					log.Println("[WARN]: could not create default tenant and site")
 				}
 
@@ -138,30 +153,29 @@ func (w *Worker) StartDBConnectJob() error {
 					log.Println("[WARN]: could not associate domain to default site")
 				}
 
-				// Create argon2 default password for openuem admin if not exist or if a reset is required
-				if err := w.Model.CreateDefaultAdminPassword(w.ResetOpenUEMUser); err != nil {
-					log.Println("[WARN]: could not create default openuem password")
+				// Allow the enrollment tokens bound to a site to enroll agents in it
+				if err := w.Model.MigrateEnrollmentTokenSites(); err != nil {
+					log.Println("[WARN]: could not migrate the sites of the enrollment tokens")
 				}
 
-				w.StartConsoleService()
-
-				// Start a job to check latest OpenUEM releases
-				channel, err := w.Model.GetDefaultUpdateChannel()
-				if err != nil {
-					log.Println("[ERROR]: could not get updates channel settings")
-					channel = "stable"
+				// The tenants with their own SMTP server in their settings keep sending their emails with it
+				if err := w.Model.MigrateTenantSMTPSettings(); err != nil {
+					log.Println("[WARN]: could not migrate the SMTP settings of the tenants")
 				}
 
-				// Start a job to download server releases version
-				if err := w.StartServerReleasesDownloadJob(); err != nil {
-					log.Printf("[ERROR]: could not start server releases download job, reason: %s", err.Error())
-					return
+				// Concurrent consoles may have created the global branding and authentication settings twice
+				if err := w.Model.MigrateSingletons(); err != nil {
+					log.Println("[WARN]: could not merge the duplicated branding and authentication settings")
 				}
 
-				if err := w.StartCheckLatestReleasesJob(channel); err != nil {
-					log.Printf("[ERROR]: could not start check latest releases job, reason: %s", err.Error())
-					return
+				// Create argon2 default password for openuem admin if not exist or if a reset is required
+				if err := w.Model.CreateDefaultAdminPassword(w.ResetOpenUEMUser); err != nil {
+					log.Println("[WARN]: could not create default openuem password")
 				}
+
+				w.StartConsoleService()
+
+				w.StartReleaseChecks()
 			},
 		),
 	)
@@ -201,7 +215,12 @@ func (w *Worker) StartConsoleService() {
 	w.SessionManager = sessions.New(w.DBUrl, sessionLifetimeInMinutes)
 
 	// HTTPS web server
-	w.WebServer = webserver.New(w.Model, w.NATSServers, w.SessionManager, w.TaskScheduler, w.JWTKey, w.ConsoleCertPath, w.ConsolePrivateKeyPath, w.SFTPPrivateKeyPath, w.CACertPath, w.AgentCertPath, w.AgentKeyPath, w.SFTPCertPath, serverName, consolePort, authPort, w.DownloadDir, w.Domain, w.OrgName, w.OrgProvince, w.OrgLocality, w.OrgAddress, w.Country, w.ReverseProxyAuthPort, w.ReverseProxyServer, w.ServerReleasesFolder, w.WinGetDBFolder, w.FlatpakDBFolder, w.BrewDBFolder, w.CommonSoftwareDBFolder, w.Version, w.ReenableCertAuth, w.ReenablePasswdAuth, w.ResetOpenUEMUser, w.AuthLogger)
+	w.WebServer = webserver.New(w.Model, w.NATSServers, w.SessionManager, w.TaskScheduler, w.JWTKey, w.ConsoleCertPath, w.ConsolePrivateKeyPath, w.SFTPPrivateKeyPath, w.CACertPath, w.AgentCertPath, w.AgentKeyPath, w.SFTPCertPath, serverName, consolePort, authPort, w.DownloadDir, w.Domain, w.OrgName, w.OrgProvince, w.OrgLocality, w.OrgAddress, w.Country, w.ReverseProxyAuthPort, w.ReverseProxyServer, w.TrustedProxies, w.ServerReleasesFolder, w.WinGetDBFolder, w.FlatpakDBFolder, w.BrewDBFolder, w.CommonSoftwareDBFolder, w.Version, w.ReenableCertAuth, w.ReenablePasswdAuth, w.ResetOpenUEMUser, w.AuthLogger)
+	w.WebServer.Handler.ChecksumURL = w.ChecksumURL
+	if w.AgentReleaseBaseURL != "" {
+		w.WebServer.Handler.AgentReleaseBaseURL = strings.TrimSuffix(w.AgentReleaseBaseURL, "/")
+	}
+	w.WebServer.Handler.CheckUpdates = w.CheckUpdates
 	go func() {
 		if err := w.WebServer.Serve(":"+consolePort, w.ConsoleCertPath, w.ConsolePrivateKeyPath); err != http.ErrServerClosed {
 			log.Printf("[ERROR]: the server has stopped, reason: %v", err.Error())
@@ -210,7 +229,7 @@ func (w *Worker) StartConsoleService() {
 	log.Println("[INFO]: console is running")
 
 	// HTTPS auth server
-	w.AuthServer = authserver.New(w.Model, w.SessionManager, w.CACertPath, serverName, consolePort, authPort, w.ReverseProxyAuthPort)
+	w.AuthServer = authserver.New(w.Model, w.SessionManager, w.CACertPath, serverName, consolePort, authPort, w.ReverseProxyAuthPort, w.TrustedProxies)
 	go func() {
 		if err := w.AuthServer.Serve(":"+authPort, w.ConsoleCertPath, w.ConsolePrivateKeyPath); err != http.ErrServerClosed {
 			log.Printf("[ERROR]: the server has stopped, reason: %v", err.Error())
@@ -227,3 +246,40 @@ func (w *Worker) StartConsoleService() {
 	}()
 	log.Printf("[INFO]: repo server is running on port %s", w.RepoPort)
 }
+
+// checkSecrets stops the console if the secrets stored in the database can't be decrypted, i.e. the
+// secrets key is missing or it's not the key they were encrypted with
+func (w *Worker) checkSecrets() {
+	pending, err := w.Model.CheckSecrets()
+	if err != nil {
+		log.Fatalf("[FATAL]: %v, set the key the secrets were encrypted with using --secrets-key or --secrets-key-file", err)
+	}
+
+	if pending > 0 {
+		if w.SecretsKey == "" {
+			log.Printf("[WARN]: %d secrets are stored in plaintext in the database, set a secrets key and run the encrypt-secrets command to encrypt them", pending)
+		} else {
+			log.Printf("[WARN]: %d secrets are not encrypted with the secrets key yet, run the encrypt-secrets command to encrypt them", pending)
+		}
+	}
+}
+
+// modelOptions are the options of the model of the console. The Redis client is created once and reused
+// when the connection with the database is established again
+func (w *Worker) modelOptions() []models.Option {
+	opts := []models.Option{models.WithSecrets(auth.NewSecretBox(w.SecretsKey))}
+
+	if w.roleCache == nil && w.RedisURL != "" {
+		cache, err := models.NewRedisRoleCacheFromURL(w.RedisURL, w.RoleCacheTTL)
+		if err != nil {
+			log.Printf("[ERROR]: the roles won't be cached in Redis: %v", err)
+		} else {
+			w.roleCache = cache
+		}
+	}
+	if w.roleCache != nil {
+		opts = append(opts, models.WithRoleCache(w.roleCache))
+	}
+
+	return opts
+}
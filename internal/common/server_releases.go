@@ -53,12 +53,12 @@ func (w *Worker) StartServerReleasesDownloadJob() error {
 		w.DownloadServerReleasesJobDuration = 10 * time.Minute
 	} else {
 		log.Println("[INFO]: server releases files have been downloaded")
-		w.DownloadServerReleasesJobDuration = 6 * time.Hour
+		w.DownloadServerReleasesJobDuration = 24 * time.Hour
 	}
 
 	// Create task
 	if err := w.StartDownloadServerReleasesJob(); err == nil {
-		log.Printf("[INFO]: download server releases job has been scheduled every %d minutes", 10)
+		log.Println("[INFO]: download server releases job has been scheduled every " + w.DownloadServerReleasesJobDuration.String())
 	}
 	return nil
 }
@@ -76,7 +76,7 @@ func (w *Worker) StartDownloadServerReleasesJob() error {
 					log.Printf("[ERROR]: could not get server releases, reason: %v", err)
 					jobDuration = 2 * time.Minute
 				} else {
-					jobDuration = 6 * time.Hour
+					jobDuration = 24 * time.Hour
 				}
 
 				if jobDuration.String() == w.DownloadServerReleasesJobDuration.String() {
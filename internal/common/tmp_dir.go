@@ -1,7 +1,12 @@
 package common
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-uem/openuem-console/internal/controllers/router"
 )
 
 func (w *Worker) CreateDowloadTempDir() error {
@@ -33,3 +38,40 @@ func (w *Worker) CreateServerReleasesDir() error {
 
 	return nil
 }
+
+// CreateBackupDir creates the directory of the database backups. The backups have all the data of the
+// console so the directory can't be one that is served, like the assets or the downloads directory
+func (w *Worker) CreateBackupDir() error {
+	cwd, err := GetWd()
+	if err != nil {
+		return err
+	}
+
+	if w.Backups.Dir == "" {
+		w.Backups.Dir = filepath.Join(cwd, "backups")
+	}
+
+	dir, err := filepath.Abs(w.Backups.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, served := range []string{router.AssetsPath(cwd), w.DownloadDir} {
+		if served == "" {
+			continue
+		}
+		if served, err = filepath.Abs(served); err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(served, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("the backups directory %s can't be inside %s", dir, served)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	w.Backups.Dir = dir
+
+	return nil
+}
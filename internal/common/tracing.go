@@ -0,0 +1,36 @@
+package common
+
+import (
+	"context"
+	"log"
+
+	"github.com/open-uem/openuem-console/internal/telemetry"
+)
+
+// StartTracing exports the traces if an OTLP endpoint has been configured. It must be called
+// before the database is opened and the servers are created, as they're only traced if the
+// traces are exported when they're created
+func (w *Worker) StartTracing() {
+	if w.Tracing.Endpoint == "" {
+		return
+	}
+
+	stop, err := telemetry.Start(w.Tracing, w.Version)
+	if err != nil {
+		log.Printf("[ERROR]: could not export traces, tracing is disabled: %v", err)
+		return
+	}
+	w.stopTracing = stop
+	log.Printf("[INFO]: exporting %.0f%% of the traces to %s", w.Tracing.SampleRate*100, w.Tracing.Endpoint)
+}
+
+// StopTracing sends the spans that haven't been exported yet
+func (w *Worker) StopTracing(ctx context.Context) {
+	if w.stopTracing == nil {
+		return
+	}
+
+	if err := w.stopTracing(ctx); err != nil {
+		log.Printf("[ERROR]: could not export the last traces, reason: %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"context"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -9,10 +11,21 @@ import (
 	"github.com/open-uem/openuem-console/internal/controllers/reposerver"
 	"github.com/open-uem/openuem-console/internal/controllers/sessions"
 	"github.com/open-uem/openuem-console/internal/controllers/webserver"
+	"github.com/open-uem/openuem-console/internal/controllers/webserver/handlers"
 	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 	"github.com/open-uem/utils"
 )
 
+// DefaultShutdownTimeout is how long in-flight requests have to finish when the console is stopped
+const DefaultShutdownTimeout = 30 * time.Second
+
+// DefaultChecksumURL is the checksums file of the latest agent release
+const DefaultChecksumURL = "https://github.com/open-uem/openuem-agent/releases/latest/download/sha256sums.txt"
+
+// DefaultAgentReleaseBaseURL is where the install scripts download the agent from by default
+const DefaultAgentReleaseBaseURL = handlers.DefaultAgentReleaseBaseURL
+
 type Worker struct {
 	Model                             *models.Model
 	Logger                            *utils.OpenUEMLogger
@@ -37,6 +50,9 @@ type Worker struct {
 	ConsolePort                       string
 	AuthPort                          string
 	RepoPort                          string
+	ChecksumURL                       string
+	AgentReleaseBaseURL               string
+	SecretsKey                        string
 	ServerName                        string
 	Domain                            string
 	NATSServers                       string
@@ -48,6 +64,7 @@ type Worker struct {
 	Country                           string
 	ReverseProxyAuthPort              string
 	ReverseProxyServer                string
+	TrustedProxies                    string
 	ServerReleasesFolder              string
 	DownloadWingetDBJob               gocron.Job
 	DownloadWingetJobDuration         time.Duration
@@ -62,14 +79,28 @@ type Worker struct {
 	CommonSoftwareDBJob               gocron.Job
 	CommonSoftwareJobDuration         time.Duration
 	Version                           string
+	CheckUpdates                      bool
 	ReenableCertAuth                  bool
 	ReenablePasswdAuth                bool
 	ResetOpenUEMUser                  bool
+	CacheTTL                          time.Duration
+	RedisURL                          string
+	RoleCacheTTL                      time.Duration
+	DBConfig                          models.DBConfig
+	ShutdownTimeout                   time.Duration
+	DeletedAgentsRetention            time.Duration
+	AgentPlaceholderRetention         time.Duration
+	Backups                           models.BackupConfig
+	DefaultBranding                   models.BrandingDefaults
 	AuthLogger                        *log.Logger
+	Tracing                           telemetry.Config
+
+	stopTracing func(context.Context) error
+	roleCache   *models.RedisRoleCache
 }
 
 func NewWorker(logName string) *Worker {
-	worker := Worker{}
+	worker := Worker{CacheTTL: models.DefaultCacheTTL, RoleCacheTTL: models.DefaultRoleCacheTTL, ShutdownTimeout: DefaultShutdownTimeout, DeletedAgentsRetention: models.DefaultDeletedAgentsRetention, AgentPlaceholderRetention: models.DefaultAgentPlaceholderRetention, DefaultBranding: models.OpenUEMBranding, ChecksumURL: DefaultChecksumURL, AgentReleaseBaseURL: DefaultAgentReleaseBaseURL, CheckUpdates: true, Tracing: telemetry.Config{SampleRate: telemetry.DefaultSampleRate}}
 	if logName != "" {
 		worker.Logger = utils.NewLogger(logName)
 	}
@@ -80,6 +111,9 @@ func NewWorker(logName string) *Worker {
 }
 
 func (w *Worker) StartWorker() {
+	// Export the traces first, the database driver and the servers are traced when they're created
+	w.StartTracing()
+
 	// Start a job to try to connect with the database
 	if err := w.StartDBConnectJob(); err != nil {
 		log.Fatalf("[FATAL]: could not start DB connect job, reason: %s", err.Error())
@@ -93,33 +127,74 @@ func (w *Worker) StartWorker() {
 	}
 }
 
+// StopWorker stops the servers gracefully, letting in-flight requests finish during the
+// shutdown timeout, then stops the background jobs and closes the connections
 func (w *Worker) StopWorker() {
-	w.Model.Close()
+	log.Printf("[INFO]: shutting down, waiting up to %v for in-flight requests to finish", w.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if w.WebServer != nil {
+		wg.Go(func() {
+			if err := w.WebServer.Shutdown(ctx); err != nil {
+				log.Printf("[ERROR]: the web server could not be stopped gracefully, reason: %v", err)
+				if err := w.WebServer.Close(); err != nil {
+					log.Println("[ERROR]: Error closing the web server")
+				}
+			}
+		})
+	}
+
+	if w.AuthServer != nil {
+		wg.Go(func() {
+			if err := w.AuthServer.Shutdown(ctx); err != nil {
+				log.Printf("[ERROR]: the auth server could not be stopped gracefully, reason: %v", err)
+				if err := w.AuthServer.Close(); err != nil {
+					log.Println("[ERROR]: Error closing the auth server")
+				}
+			}
+		})
+	}
+
+	if w.RepoServer != nil {
+		wg.Go(func() {
+			if err := w.RepoServer.Shutdown(ctx); err != nil {
+				log.Printf("[ERROR]: the repo server could not be stopped gracefully, reason: %v", err)
+				if err := w.RepoServer.Close(); err != nil {
+					log.Println("[ERROR]: Error closing the repo server")
+				}
+			}
+		})
+	}
+	wg.Wait()
+	log.Println("[INFO]: servers have been stopped and NATS connection has been drained")
+
+	// Wait for running jobs so they don't use the database once it's closed
 	if err := w.TaskScheduler.Shutdown(); err != nil {
 		log.Printf("[ERROR]: could not stop the task scheduler, reason: %s", err.Error())
 	}
+	log.Println("[INFO]: task scheduler has been stopped")
 
 	if w.SessionManager != nil {
 		w.SessionManager.Close()
 	}
 
-	if w.WebServer != nil {
-		if err := w.WebServer.Close(); err != nil {
-			log.Println("[ERROR]: Error closing the web server")
+	if w.Model != nil {
+		if err := w.Model.Close(); err != nil {
+			log.Printf("[ERROR]: could not close the database connection, reason: %v", err)
 		}
 	}
+	log.Println("[INFO]: database connections have been closed")
 
-	if w.AuthServer != nil {
-		if err := w.AuthServer.Close(); err != nil {
-			log.Println("[ERROR]: Error closing the auth server")
+	if w.roleCache != nil {
+		if err := w.roleCache.Close(); err != nil {
+			log.Printf("[ERROR]: could not close the Redis connections, reason: %v", err)
 		}
 	}
 
-	if w.RepoServer != nil {
-		if err := w.RepoServer.Close(); err != nil {
-			log.Println("[ERROR]: Error closing the repo server")
-		}
-	}
+	w.StopTracing(ctx)
 
 	if w.Logger != nil {
 		w.Logger.Close()
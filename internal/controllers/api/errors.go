@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Prefix is the path of the API endpoints, their errors are sent as JSON instead of error pages
+const Prefix = "/api/"
+
+// FieldError tells which request field has an invalid value
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the envelope of every error returned by the API. Code is a stable identifier
+// clients can check, Message is for humans and RequestID is logged with unexpected errors
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func NewError(status int, code, message string, fields ...FieldError) *Error {
+	return &Error{Status: status, Code: code, Message: message, Fields: fields}
+}
+
+// IsAPIRequest tells if the errors of the request must be sent with the JSON envelope
+func IsAPIRequest(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().URL.Path, Prefix)
+}
+
+// HandleError sends the error with the JSON envelope. Echo errors, like not found routes, get the
+// code of their status and any other error is logged and hidden behind an internal error
+func HandleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := &Error{}
+	var he *echo.HTTPError
+	switch {
+	case errors.As(err, &apiErr):
+		apiErr = &Error{Status: apiErr.Status, Code: apiErr.Code, Message: apiErr.Message, Fields: apiErr.Fields}
+	case errors.As(err, &he):
+		message, ok := he.Message.(string)
+		if !ok {
+			message = http.StatusText(he.Code)
+		}
+		apiErr = NewError(he.Code, StatusCode(he.Code), message)
+	default:
+		apiErr = NewError(http.StatusInternalServerError, StatusCode(http.StatusInternalServerError), http.StatusText(http.StatusInternalServerError))
+	}
+
+	apiErr.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("[ERROR]: request %s to %s failed: %v", apiErr.RequestID, c.Request().URL.Path, err)
+	}
+
+	if err := c.JSON(apiErr.Status, apiErr); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// StatusCode returns the error code used for a status when there's no more specific one
+func StatusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Security schemes of the API, routes list the ones they accept
+const (
+	// SecurityEnrollmentToken is the value of an enrollment token in the path of the request
+	SecurityEnrollmentToken = "enrollmentToken"
+	// SecuritySession is the session cookie of a user logged in the console
+	SecuritySession = "session"
+	// SecurityAPIToken is an API token of a user sent as a bearer token
+	SecurityAPIToken = "apiToken"
+)
+
+// Route describes an API endpoint. The routes are registered and documented in the
+// OpenAPI document from the same metadata so both can't get out of sync
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Tag         string
+	Security    []string
+	Query       []Parameter
+	// RequestBody is the schema of the JSON body of the request, if it has one
+	RequestBody *Schema
+	// Paginated routes accept the page and pageSize params and return a page of items
+	Paginated bool
+	// Responses are the successful responses by status, error responses use the error envelope
+	Responses map[int]Response
+	// Errors are the statuses of the errors the route may return
+	Errors     []int
+	Handler    echo.HandlerFunc
+	Middleware []echo.MiddlewareFunc
+}
+
+// Register adds the routes to the group
+func Register(g *echo.Group, routes []Route) {
+	for _, r := range routes {
+		g.Add(r.Method, r.Path, r.Handler, r.Middleware...)
+	}
+}
+
+// Document is the subset of the OpenAPI 3 document used by the API
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Minimum    *int               `json:"minimum,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Parameters      map[string]Parameter      `json:"parameters"`
+	Responses       map[string]Response       `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// Spec builds the OpenAPI document of the routes served under the base path
+func Spec(version, basePath string, routes []Route) *Document {
+	doc := Document{
+		OpenAPI:    "3.0.3",
+		Info:       Info{Title: "OpenUEM Console API", Version: version},
+		Servers:    []Server{{URL: basePath}},
+		Paths:      map[string]map[string]Operation{},
+		Components: components(),
+	}
+
+	for _, r := range routes {
+		path, params := specPath(r.Path)
+		op := Operation{
+			OperationID: r.OperationID,
+			Summary:     r.Summary,
+			Parameters:  append(params, r.Query...),
+			Responses:   map[string]Response{},
+		}
+		if r.Tag != "" {
+			op.Tags = []string{r.Tag}
+		}
+		if r.RequestBody != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{echo.MIMEApplicationJSON: {Schema: r.RequestBody}}}
+		}
+		if r.Paginated {
+			op.Parameters = append(op.Parameters, Parameter{Ref: "#/components/parameters/page"}, Parameter{Ref: "#/components/parameters/pageSize"})
+		}
+		for _, s := range r.Security {
+			op.Security = append(op.Security, map[string][]string{s: {}})
+		}
+		for status, response := range r.Responses {
+			op.Responses[strconv.Itoa(status)] = response
+		}
+		for _, status := range r.Errors {
+			op.Responses[strconv.Itoa(status)] = Response{Ref: "#/components/responses/Error"}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	return &doc
+}
+
+// SpecPath converts the echo path params to the OpenAPI syntax, e.g. /enroll/:token to /enroll/{token}
+func SpecPath(path string) string {
+	p, _ := specPath(path)
+	return p
+}
+
+func specPath(path string) (string, []Parameter) {
+	params := []Parameter{}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if name, ok := strings.CutPrefix(s, ":"); ok {
+			segments[i] = "{" + name + "}"
+			params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// PageSchema returns the schema of a page of items
+func PageSchema(items *Schema) *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"items", "page", "page_size", "total"},
+		Properties: map[string]*Schema{
+			"items":     {Type: "array", Items: items},
+			"page":      {Type: "integer", Minimum: intPtr(1)},
+			"page_size": {Type: "integer", Minimum: intPtr(1)},
+			"total":     {Type: "integer", Minimum: intPtr(0)},
+		},
+	}
+}
+
+func components() Components {
+	return Components{
+		Schemas: map[string]*Schema{
+			"Error": {
+				Type:     "object",
+				Required: []string{"code", "message"},
+				Properties: map[string]*Schema{
+					"code":       {Type: "string"},
+					"message":    {Type: "string"},
+					"request_id": {Type: "string"},
+					"fields": {
+						Type: "array",
+						Items: &Schema{
+							Type:       "object",
+							Required:   []string{"field", "message"},
+							Properties: map[string]*Schema{"field": {Type: "string"}, "message": {Type: "string"}},
+						},
+					},
+				},
+			},
+		},
+		Parameters: map[string]Parameter{
+			"page":     {Name: "page", In: "query", Description: "Page to return, starting at 1", Schema: &Schema{Type: "integer", Minimum: intPtr(1)}},
+			"pageSize": {Name: "pageSize", In: "query", Description: "Number of items in each page", Schema: &Schema{Type: "integer", Minimum: intPtr(1)}},
+		},
+		Responses: map[string]Response{
+			"Error": {
+				Description: "Error",
+				Content:     map[string]MediaType{echo.MIMEApplicationJSON: {Schema: &Schema{Ref: "#/components/schemas/Error"}}},
+			},
+		},
+		SecuritySchemes: map[string]SecurityScheme{
+			SecurityEnrollmentToken: {
+				Type:        "apiKey",
+				In:          "header",
+				Name:        "X-Enrollment-Token",
+				Description: "The value of an active enrollment token. Enrollment endpoints take it in the {token} path parameter",
+			},
+			SecurityAPIToken: {
+				Type:        "http",
+				Scheme:      "bearer",
+				Description: "API token created in My Account, sent in the Authorization header",
+			},
+			SecuritySession: {
+				Type:        "apiKey",
+				In:          "cookie",
+				Name:        "session",
+				Description: "Session cookie of a user logged in the console",
+			},
+		},
+	}
+}
+
+// Methods returns the methods documented for each path, sorted, used to compare the document with the router
+func (d *Document) Methods() map[string][]string {
+	methods := map[string][]string{}
+	for path, ops := range d.Paths {
+		for method := range ops {
+			methods[path] = append(methods[path], strings.ToUpper(method))
+		}
+		sort.Strings(methods[path])
+	}
+	return methods
+}
+
+// JSONResponse is a successful response with a JSON body
+func JSONResponse(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{echo.MIMEApplicationJSON: {Schema: schema}}}
+}
+
+// FileResponse is a successful response with a file of the content type
+func FileResponse(description, contentType string) Response {
+	return Response{Description: description, Content: map[string]MediaType{contentType: {Schema: &Schema{Type: "string", Format: "binary"}}}}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers sent with the responses of the rate limited endpoints
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// RateLimit is a token bucket that is refilled with PerMinute requests each minute and holds up to Burst
+// requests. A zero PerMinute doesn't limit the requests
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateDecision is whether a request is allowed and the state of its bucket, sent in the rate limit headers
+type RateDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed if this one isn't
+	RetryAfter time.Duration
+}
+
+// RateLimiter decides if the request of a key, e.g. an API token, is allowed. MemoryRateLimiter keeps the
+// buckets of this console, a limiter shared by the replicas of the console can implement it later
+type RateLimiter interface {
+	Allow(key string, limit RateLimit) (RateDecision, error)
+}
+
+// MemoryRateLimiter keeps the buckets in memory, those not used in a while are removed
+type MemoryRateLimiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*rateBucket
+	expiresIn   time.Duration
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimiter creates a limiter that forgets the buckets not used for expiresIn, they're full by then
+// for any limit of at least one request per minute
+func NewMemoryRateLimiter(expiresIn time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:   map[string]*rateBucket{},
+		expiresIn: expiresIn,
+		now:       time.Now,
+	}
+}
+
+// Allow takes a request from the bucket of the key. The limit is passed on every request so the changes of the
+// settings apply without restarting the console
+func (l *MemoryRateLimiter) Allow(key string, limit RateLimit) (RateDecision, error) {
+	if limit.PerMinute <= 0 {
+		return RateDecision{Allowed: true}, nil
+	}
+
+	burst := max(limit.Burst, 1)
+	rate := float64(limit.PerMinute) / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastCleanup) > l.expiresIn {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > l.expiresIn {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	d := RateDecision{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((float64(burst) - b.tokens) / rate)
+
+	return d, nil
+}
+
+// SetRateLimitHeaders sends the state of the bucket of the request, and when it can be retried if it's not allowed
+func SetRateLimitHeaders(c echo.Context, d RateDecision) {
+	if d.Limit == 0 {
+		return
+	}
+
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(d.Limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(d.Remaining))
+	header.Set(HeaderRateLimitReset, strconv.Itoa(int(math.Ceil(d.Reset.Seconds()))))
+	if !d.Allowed {
+		header.Set(echo.HeaderRetryAfter, strconv.Itoa(max(int(math.Ceil(d.RetryAfter.Seconds())), 1)))
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
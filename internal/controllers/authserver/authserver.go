@@ -1,6 +1,7 @@
 package authserver
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"log"
@@ -22,7 +23,7 @@ type AuthServer struct {
 	CACert         *x509.Certificate
 }
 
-func New(m *models.Model, s *sessions.SessionManager, caCert, server, consolePort, authPort, reverseProxyAuthPort string) *AuthServer {
+func New(m *models.Model, s *sessions.SessionManager, caCert, server, consolePort, authPort, reverseProxyAuthPort, trustedProxies string) *AuthServer {
 	var err error
 	a := AuthServer{}
 
@@ -34,7 +35,7 @@ func New(m *models.Model, s *sessions.SessionManager, caCert, server, consolePor
 	}
 
 	// Router
-	a.Router = router.New(s, server, authPort, maxUploadSize)
+	a.Router = router.New(m, s, server, authPort, maxUploadSize, trustedProxies)
 
 	// Session Manager
 	a.SessionManager = s
@@ -66,6 +67,14 @@ func (a *AuthServer) Serve(address, certFile, certKey string) error {
 	return a.Server.ListenAndServeTLS(certFile, certKey)
 }
 
+// Shutdown stops accepting new connections and waits for the in-flight requests until the context expires
+func (a *AuthServer) Shutdown(ctx context.Context) error {
+	if a.Server == nil {
+		return nil
+	}
+	return a.Server.Shutdown(ctx)
+}
+
 func (a *AuthServer) Close() error {
 	return a.Server.Close()
 }
@@ -131,8 +131,12 @@ func (h *Handler) Auth(c echo.Context) error {
 		h.SessionManager.Manager.Put(c.Request().Context(), "username", user.Name)
 		h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 		h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
+		h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+		h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+		h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+		h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
 		h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
-		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 		token, expiry, err := h.SessionManager.Manager.Commit(c.Request().Context())
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
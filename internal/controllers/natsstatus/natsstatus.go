@@ -0,0 +1,222 @@
+package natsstatus
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// DiagnosticSubject is the prefix of the subjects where the console checks that it receives its own messages
+const DiagnosticSubject = "console.diagnostic"
+
+// maxEvents is the number of connection events kept in the history
+const maxEvents = 20
+
+// EventKind is what happened to the NATS connection
+type EventKind string
+
+const (
+	EventConnected    EventKind = "connected"
+	EventDisconnected EventKind = "disconnected"
+	EventReconnected  EventKind = "reconnected"
+	EventClosed       EventKind = "closed"
+)
+
+// Event is a change of the NATS connection
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Status is the state of the NATS connection shown in the NATS page and the health endpoint.
+// The URLs of the servers never include their credentials
+type Status struct {
+	Connected       bool      `json:"connected"`
+	State           string    `json:"state"`
+	Servers         []string  `json:"servers"`
+	ConnectedURL    string    `json:"connected_url,omitempty"`
+	Reconnects      uint64    `json:"reconnects"`
+	LastDisconnect  time.Time `json:"last_disconnect"`
+	LastReconnect   time.Time `json:"last_reconnect"`
+	FailedPublishes uint64    `json:"failed_publishes"`
+	LastError       string    `json:"last_error,omitempty"`
+	Events          []Event   `json:"-"`
+}
+
+// Monitor records the history of the NATS connection of the console and the messages
+// that could not be published
+type Monitor struct {
+	mu              sync.RWMutex
+	events          []Event
+	lastDisconnect  time.Time
+	lastReconnect   time.Time
+	failedPublishes uint64
+}
+
+func New() *Monitor {
+	return &Monitor{}
+}
+
+// Watch records the events of the connection. The NATS client keeps a single handler for each event,
+// so onReconnect is called after a reconnection for the work that depends on it
+func (m *Monitor) Watch(nc *nats.Conn, onReconnect func()) {
+	m.record(EventConnected, nil)
+
+	nc.SetDisconnectErrHandler(func(_ *nats.Conn, err error) {
+		log.Printf("[WARN]: NATS connection lost, reason: %v", err)
+		m.record(EventDisconnected, err)
+	})
+
+	nc.SetReconnectHandler(func(_ *nats.Conn) {
+		log.Println("[INFO]: NATS connection restored")
+		m.record(EventReconnected, nil)
+		if onReconnect != nil {
+			onReconnect()
+		}
+	})
+
+	nc.SetClosedHandler(func(_ *nats.Conn) {
+		m.record(EventClosed, nil)
+	})
+}
+
+func (m *Monitor) record(kind EventKind, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := Event{Kind: kind, At: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	switch kind {
+	case EventDisconnected:
+		m.lastDisconnect = e.At
+	case EventReconnected:
+		m.lastReconnect = e.At
+	}
+
+	m.events = append(m.events, e)
+	if len(m.events) > maxEvents {
+		m.events = m.events[len(m.events)-maxEvents:]
+	}
+}
+
+// PublishFailed counts a message that could not be published. Requests that timed out or had
+// no one to answer them are not failures of the connection and aren't counted
+func (m *Monitor) PublishFailed(err error) {
+	if err == nil || errors.Is(err, nats.ErrTimeout) || errors.Is(err, nats.ErrNoResponders) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedPublishes++
+}
+
+// Status returns the state of the connection, nc can be nil if the console couldn't connect yet.
+// The events are returned from the newest to the oldest
+func (m *Monitor) Status(nc *nats.Conn, servers string) Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := Status{
+		State:           "NOT CONNECTED",
+		LastDisconnect:  m.lastDisconnect,
+		LastReconnect:   m.lastReconnect,
+		FailedPublishes: m.failedPublishes,
+	}
+
+	for _, server := range strings.Split(servers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			s.Servers = append(s.Servers, MaskURL(server))
+		}
+	}
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		s.Events = append(s.Events, m.events[i])
+	}
+
+	if nc == nil {
+		return s
+	}
+
+	s.Connected = nc.IsConnected()
+	s.State = nc.Status().String()
+	s.ConnectedURL = MaskURL(nc.ConnectedUrl())
+	s.Reconnects = nc.Stats().Reconnects
+	if err := nc.LastError(); err != nil {
+		s.LastError = err.Error()
+	}
+	return s
+}
+
+// RTT measures the round trip to the NATS server, waiting at most the timeout
+func RTT(nc *nats.Conn, timeout time.Duration) (time.Duration, error) {
+	if nc == nil || !nc.IsConnected() {
+		return 0, nats.ErrConnectionClosed
+	}
+
+	start := time.Now()
+	if err := nc.FlushTimeout(timeout); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// Echo publishes a message to a diagnostic subject and waits until the console receives it, returning
+// how long it took. It checks that the console can both publish and receive messages
+func Echo(nc *nats.Conn, timeout time.Duration) (time.Duration, error) {
+	if nc == nil || !nc.IsConnected() {
+		return 0, nats.ErrConnectionClosed
+	}
+
+	subject := DiagnosticSubject + "." + uuid.NewString()
+	sub, err := nc.SubscribeSync(subject)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	start := time.Now()
+	if err := nc.Publish(subject, []byte(start.Format(time.RFC3339Nano))); err != nil {
+		return 0, err
+	}
+	if _, err := sub.NextMsg(timeout); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// MaskURL hides the credentials of a NATS server URL, the password of a user or the token
+func MaskURL(server string) string {
+	scheme, rest, hasScheme := strings.Cut(server, "://")
+	if !hasScheme {
+		scheme, rest = "", server
+	}
+
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return server
+	}
+	if slash := strings.Index(rest, "/"); slash >= 0 && slash < at {
+		return server
+	}
+
+	credentials := "xxxxx"
+	if user, _, hasPassword := strings.Cut(rest[:at], ":"); hasPassword {
+		credentials = user + ":xxxxx"
+	}
+
+	masked := credentials + "@" + rest[at+1:]
+	if hasScheme {
+		return scheme + "://" + masked
+	}
+	return masked
+}
@@ -0,0 +1,75 @@
+package nicknames
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ChangedSubject is the subject where the console replicas announce that an agent's nickname has changed
+const ChangedSubject = "agents.nickname.changed"
+
+// Event is the message published when an agent's nickname changes
+type Event struct {
+	AgentID  string `json:"agent_id"`
+	Nickname string `json:"nickname"`
+	TenantID string `json:"tenant_id"`
+	SiteID   string `json:"site_id"`
+}
+
+// Cache keeps in memory the nicknames changed in any console replica, so every replica knows
+// the new nickname without querying the database
+type Cache struct {
+	mu        sync.RWMutex
+	nicknames map[string]string
+	sub       *nats.Subscription
+}
+
+func New() *Cache {
+	return &Cache{nicknames: map[string]string{}}
+}
+
+// Set stores the nickname of the agent
+func (c *Cache) Set(agentID, nickname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nicknames[agentID] = nickname
+}
+
+// Get returns the nickname of the agent if it has changed since the console started
+func (c *Cache) Get(agentID string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nickname, ok := c.nicknames[agentID]
+	return nickname, ok
+}
+
+// Subscribe listens to the nickname changes of all the replicas, including this one. The NATS client
+// resubscribes by itself after a reconnection, so this must only be called again if the connection is replaced
+func (c *Cache) Subscribe(nc *nats.Conn) error {
+	sub, err := nc.Subscribe(ChangedSubject, func(msg *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			log.Printf("[WARN]: could not read the nickname change event, reason: %v", err)
+			return
+		}
+		if e.AgentID != "" {
+			c.Set(e.AgentID, e.Nickname)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sub != nil {
+		if err := c.sub.Unsubscribe(); err != nil {
+			log.Printf("[WARN]: could not remove previous nickname changes subscription, reason: %v", err)
+		}
+	}
+	c.sub = sub
+	return nil
+}
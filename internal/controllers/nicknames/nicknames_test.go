@@ -0,0 +1,28 @@
+package nicknames
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	cache := New()
+
+	_, ok := cache.Get("agent1")
+	assert.False(t, ok, "should not know nicknames that haven't changed")
+
+	cache.Set("agent1", "Reception")
+	cache.Set("agent1", "Front desk")
+
+	nickname, ok := cache.Get("agent1")
+	assert.True(t, ok)
+	assert.Equal(t, "Front desk", nickname, "should keep the last nickname")
+}
+
+func TestEventPayload(t *testing.T) {
+	data, err := json.Marshal(Event{AgentID: "agent1", Nickname: "Reception", TenantID: "1", SiteID: "-1"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"agent_id":"agent1","nickname":"Reception","tenant_id":"1","site_id":"-1"}`, string(data))
+}
@@ -0,0 +1,176 @@
+package presence
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HeartbeatSubject is the subject where agents publish their heartbeats, the last token is the agent's ID
+const HeartbeatSubject = "agent.heartbeat.*"
+
+// Status is the presence subscription state shown in the health endpoint
+type Status struct {
+	Subscribed bool      `json:"subscribed"`
+	LastEvent  time.Time `json:"last_event"`
+	Online     int       `json:"online"`
+}
+
+// Tracker keeps in memory which agents are online from the heartbeats received
+// through NATS. An agent is considered offline if no heartbeat has been received
+// during the timeout
+type Tracker struct {
+	mu        sync.RWMutex
+	timeout   time.Duration
+	lastSeen  map[string]time.Time
+	pending   map[string]time.Time
+	lastEvent time.Time
+	sub       *nats.Subscription
+	onChange  func(agentID string, online bool)
+}
+
+func New(timeout time.Duration) *Tracker {
+	return &Tracker{
+		timeout:  timeout,
+		lastSeen: map[string]time.Time{},
+		pending:  map[string]time.Time{},
+	}
+}
+
+// OnChange registers a function called every time an agent goes online or offline
+func (t *Tracker) OnChange(f func(agentID string, online bool)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = f
+}
+
+// Subscribe listens to the agents heartbeats. The NATS client resubscribes by itself after
+// a reconnection, so this must only be called again if the connection is replaced
+func (t *Tracker) Subscribe(nc *nats.Conn) error {
+	sub, err := nc.Subscribe(HeartbeatSubject, func(msg *nats.Msg) {
+		agentID := msg.Subject[strings.LastIndex(msg.Subject, ".")+1:]
+		if agentID != "" {
+			t.Seen(agentID, time.Now())
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sub != nil {
+		if err := t.sub.Unsubscribe(); err != nil {
+			log.Printf("[WARN]: could not remove previous presence subscription, reason: %v", err)
+		}
+	}
+	t.sub = sub
+	return nil
+}
+
+// Seen registers a heartbeat from an agent
+func (t *Tracker) Seen(agentID string, when time.Time) {
+	t.mu.Lock()
+	now := time.Now()
+	wasOnline := t.isOnline(agentID, now)
+	if when.After(t.lastSeen[agentID]) {
+		t.lastSeen[agentID] = when
+		t.pending[agentID] = when
+	}
+	if when.After(t.lastEvent) {
+		t.lastEvent = when
+	}
+	cameOnline := !wasOnline && t.isOnline(agentID, now)
+	onChange := t.onChange
+	t.mu.Unlock()
+
+	if cameOnline && onChange != nil {
+		onChange(agentID, true)
+	}
+}
+
+// Load merges last seen dates stored in the database, e.g. by other console
+// replicas, without marking them as pending to be saved
+func (t *Tracker) Load(lastSeen map[string]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for agentID, when := range lastSeen {
+		if when.After(t.lastSeen[agentID]) {
+			t.lastSeen[agentID] = when
+		}
+	}
+}
+
+// IsOnline returns true if a heartbeat has been received from the agent during the timeout
+func (t *Tracker) IsOnline(agentID string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.isOnline(agentID, time.Now())
+}
+
+func (t *Tracker) isOnline(agentID string, now time.Time) bool {
+	when, ok := t.lastSeen[agentID]
+	return ok && now.Sub(when) <= t.timeout
+}
+
+// Online returns the IDs of the agents that are online
+func (t *Tracker) Online() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := time.Now()
+	ids := []string{}
+	for agentID := range t.lastSeen {
+		if t.isOnline(agentID, now) {
+			ids = append(ids, agentID)
+		}
+	}
+	return ids
+}
+
+// Pending returns the heartbeats received since the previous call so they can be saved
+func (t *Tracker) Pending() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := t.pending
+	t.pending = map[string]time.Time{}
+	return pending
+}
+
+// Sweep forgets the agents whose heartbeat has timed out, as no event is received
+// when an agent disconnects, and returns their IDs
+func (t *Tracker) Sweep(now time.Time) []string {
+	t.mu.Lock()
+	offline := []string{}
+	for agentID := range t.lastSeen {
+		if !t.isOnline(agentID, now) {
+			delete(t.lastSeen, agentID)
+			offline = append(offline, agentID)
+		}
+	}
+	onChange := t.onChange
+	t.mu.Unlock()
+
+	if onChange != nil {
+		for _, agentID := range offline {
+			onChange(agentID, false)
+		}
+	}
+	return offline
+}
+
+// Status returns the state of the subscription
+func (t *Tracker) Status() Status {
+	online := len(t.Online())
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return Status{
+		Subscribed: t.sub != nil && t.sub.IsValid(),
+		LastEvent:  t.lastEvent,
+		Online:     online,
+	}
+}
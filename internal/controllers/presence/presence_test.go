@@ -0,0 +1,40 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	changes := map[string]bool{}
+	tracker := New(time.Minute)
+	tracker.OnChange(func(agentID string, online bool) { changes[agentID] = online })
+
+	now := time.Now()
+	tracker.Seen("agent1", now)
+	tracker.Seen("agent2", now.Add(-2*time.Minute))
+
+	assert.True(t, tracker.IsOnline("agent1"), "agent with recent heartbeat should be online")
+	assert.False(t, tracker.IsOnline("agent2"), "agent with old heartbeat should be offline")
+	assert.False(t, tracker.IsOnline("agent3"), "unknown agent should be offline")
+	assert.Equal(t, []string{"agent1"}, tracker.Online())
+	assert.True(t, changes["agent1"], "should notify agent going online")
+
+	pending := tracker.Pending()
+	assert.Equal(t, 2, len(pending), "should return heartbeats to be saved")
+	assert.Equal(t, 0, len(tracker.Pending()), "should clear pending heartbeats")
+
+	tracker.Load(map[string]time.Time{"agent3": now})
+	assert.True(t, tracker.IsOnline("agent3"), "should load heartbeats from other replicas")
+	assert.Equal(t, 0, len(tracker.Pending()), "loaded heartbeats should not be saved again")
+
+	offline := tracker.Sweep(now.Add(90 * time.Second))
+	assert.ElementsMatch(t, []string{"agent1", "agent2", "agent3"}, offline, "should sweep timed out agents")
+	assert.False(t, changes["agent1"], "should notify agent going offline")
+
+	status := tracker.Status()
+	assert.False(t, status.Subscribed)
+	assert.Equal(t, now, status.LastEvent)
+}
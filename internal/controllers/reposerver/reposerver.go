@@ -1,6 +1,7 @@
 package reposerver
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"log"
@@ -9,7 +10,9 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/open-uem/openuem-console/internal/controllers/reposerver/handlers"
+	openuem_middleware "github.com/open-uem/openuem-console/internal/controllers/router/middleware"
 	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 	"github.com/open-uem/utils"
 )
 
@@ -45,6 +48,9 @@ func New(m *models.Model, caCertPath string, repoCACertPath string) *RepoServer
 	r.Router.HideBanner = true
 	r.Router.Use(middleware.Recover())
 	r.Router.Use(middleware.Logger())
+	if telemetry.Enabled() {
+		r.Router.Use(openuem_middleware.Tracing(nil))
+	}
 
 	// Create handler and register routes
 	r.Handler = handlers.NewHandler(m, r.CACert)
@@ -75,6 +81,14 @@ func (r *RepoServer) Serve(address, certFile, certKey string) error {
 }
 
 // Close gracefully shuts down the repo server.
+// Shutdown stops accepting new connections and waits for the in-flight requests until the context expires
+func (r *RepoServer) Shutdown(ctx context.Context) error {
+	if r.Server == nil {
+		return nil
+	}
+	return r.Server.Shutdown(ctx)
+}
+
 func (r *RepoServer) Close() error {
 	if r.Server != nil {
 		return r.Server.Close()
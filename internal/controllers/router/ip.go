@@ -0,0 +1,66 @@
+package router
+
+import (
+	"log"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ParseTrustedProxies converts a comma-separated list of IPs or CIDRs into networks.
+// Single IP addresses are treated as /32 (or /128 for IPv6) networks
+func ParseTrustedProxies(trustedProxies string) []*net.IPNet {
+	networks := []*net.IPNet{}
+
+	for _, entry := range strings.Split(trustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Printf("[WARN]: trusted proxy %s is not a valid IP address, it will be ignored", entry)
+				continue
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("[WARN]: trusted proxy %s is not a valid CIDR, it will be ignored", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// NewIPExtractor returns the IP extractor used by echo to resolve c.RealIP().
+// If no trusted proxies are configured, the X-Forwarded-For header is ignored and the
+// remote address is used. Otherwise, the X-Forwarded-For header is only honored when the
+// request comes from a trusted proxy, and the first untrusted hop (from right to left) is used
+func NewIPExtractor(trustedProxies string) echo.IPExtractor {
+	networks := ParseTrustedProxies(trustedProxies)
+	if len(networks) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, network := range networks {
+		options = append(options, echo.TrustIPRange(network))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
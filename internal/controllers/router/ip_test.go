@@ -0,0 +1,67 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequest(remoteAddr string, xff string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if xff != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, xff)
+	}
+	return req
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10,, fd00::/8, not-an-ip, 300.1.1.1/24")
+	assert.Equal(t, 3, len(networks), "should ignore empty and invalid entries")
+	assert.Equal(t, "10.0.0.0/8", networks[0].String())
+	assert.Equal(t, "192.168.1.10/32", networks[1].String())
+	assert.Equal(t, "fd00::/8", networks[2].String())
+}
+
+func TestIPExtractorWithoutTrustedProxies(t *testing.T) {
+	extractor := NewIPExtractor("")
+
+	assert.Equal(t, "203.0.113.5", extractor(newRequest("203.0.113.5:4444", "")))
+	assert.Equal(t, "10.0.0.2", extractor(newRequest("10.0.0.2:4444", "198.51.100.7")), "should ignore X-Forwarded-For when no proxy is trusted")
+}
+
+func TestIPExtractorTrustedProxy(t *testing.T) {
+	extractor := NewIPExtractor("10.0.0.0/24")
+
+	assert.Equal(t, "198.51.100.7", extractor(newRequest("10.0.0.2:4444", "198.51.100.7")), "should use the client IP sent by a trusted proxy")
+	assert.Equal(t, "10.0.0.2", extractor(newRequest("10.0.0.2:4444", "")), "should use the proxy IP if no header is sent")
+}
+
+func TestIPExtractorNestedProxies(t *testing.T) {
+	extractor := NewIPExtractor("10.0.0.0/24,172.16.0.1")
+
+	// client -> 172.16.0.1 (CDN) -> 10.0.0.2 (load balancer) -> console
+	assert.Equal(t, "198.51.100.7", extractor(newRequest("10.0.0.2:4444", "198.51.100.7, 172.16.0.1")), "should skip every trusted hop")
+
+	// client -> 172.16.0.9 (untrusted) -> 10.0.0.2 -> console
+	assert.Equal(t, "172.16.0.9", extractor(newRequest("10.0.0.2:4444", "198.51.100.7, 172.16.0.9")), "should stop at the first untrusted hop")
+}
+
+func TestIPExtractorSpoofedHeaders(t *testing.T) {
+	extractor := NewIPExtractor("10.0.0.0/24")
+
+	// Direct request from an untrusted address trying to impersonate another client
+	assert.Equal(t, "203.0.113.5", extractor(newRequest("203.0.113.5:4444", "198.51.100.7")), "should ignore X-Forwarded-For from an untrusted peer")
+
+	// Direct request from an untrusted private address, private networks are not trusted by default
+	assert.Equal(t, "192.168.1.20", extractor(newRequest("192.168.1.20:4444", "198.51.100.7")), "should not trust private networks unless configured")
+
+	// Client sends a forged header through the trusted proxy, the proxy appends the real client IP
+	assert.Equal(t, "203.0.113.5", extractor(newRequest("10.0.0.2:4444", "198.51.100.7, 203.0.113.5")), "should use the IP appended by the trusted proxy")
+
+	// Client forges a trusted proxy address in the header
+	assert.Equal(t, "203.0.113.5", extractor(newRequest("10.0.0.2:4444", "10.0.0.3, 203.0.113.5")), "should not be fooled by a forged trusted hop")
+}
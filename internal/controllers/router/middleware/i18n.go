@@ -3,16 +3,36 @@ package middleware
 import (
 	"github.com/invopop/ctxi18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/sessions"
+	"github.com/open-uem/openuem-console/internal/views/locales"
 )
 
-func GetLocale(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		accept := c.Request().Header.Get("Accept-Language")
-		ctx, err := ctxi18n.WithLocale(c.Request().Context(), accept)
-		if err != nil {
-			return err
+// LanguageCookie stores the language chosen before logging in
+const LanguageCookie = "lang"
+
+// GetLocale sets the locale using the language chosen by the user in its profile,
+// then the language cookie set from the login page and finally the Accept-Language header
+func GetLocale(s *sessions.SessionManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lang := c.Request().Header.Get("Accept-Language")
+
+			if cookie, err := c.Cookie(LanguageCookie); err == nil && locales.IsSupported(cookie.Value) {
+				lang = cookie.Value
+			}
+
+			if s != nil {
+				if userLang := s.Manager.GetString(c.Request().Context(), "lang"); locales.IsSupported(userLang) {
+					lang = userLang
+				}
+			}
+
+			ctx, err := ctxi18n.WithLocale(c.Request().Context(), lang)
+			if err != nil {
+				return err
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
 		}
-		c.SetRequest(c.Request().WithContext(ctx))
-		return next(c)
 	}
 }
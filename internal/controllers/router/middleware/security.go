@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+)
+
+// CSPReportPath receives the violations of the Content Security Policy reported by the browsers
+const CSPReportPath = "/csp-report"
+
+// DefaultContentSecurityPolicy is the policy of the console if the admins haven't set another one.
+// Inline scripts and styles are allowed because the layouts, the charts and the branding colors are
+// rendered inline, and data: images because the branding logos are stored as data URLs. wss: lets
+// the remote desktop connect to the agents
+const DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self' data:; connect-src 'self' wss:; object-src 'none'; base-uri 'self'; form-action 'self'"
+
+// publicContentSecurityPolicy is sent by the public endpoints, like the enrollment downloads, that
+// don't return pages
+const publicContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// maxCSPReportSize is the largest report that is read, browsers send a few KB at most
+const maxCSPReportSize = 64 * 1024
+
+// SecurityHeadersConfig is the Content Security Policy of the console and how it's sent
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy replaces the default policy if it's not empty
+	ContentSecurityPolicy string
+	// ReportOnly sends the policy with the Content-Security-Policy-Report-Only header, the browsers
+	// report the violations but don't block anything
+	ReportOnly bool
+	// FrameAncestors are the origins that can embed the console in an iframe, none if it's empty
+	FrameAncestors []string
+}
+
+// Policy returns the policy with the frame-ancestors and reporting directives
+func (c SecurityHeadersConfig) Policy() string {
+	policy := strings.TrimSuffix(strings.TrimSpace(c.ContentSecurityPolicy), ";")
+	if policy == "" {
+		policy = DefaultContentSecurityPolicy
+	}
+	return policy + "; " + c.frameAncestors() + "; report-uri " + CSPReportPath + "; report-to csp"
+}
+
+func (c SecurityHeadersConfig) frameAncestors() string {
+	if len(c.FrameAncestors) == 0 {
+		return "frame-ancestors 'none'"
+	}
+	return "frame-ancestors " + strings.Join(c.FrameAncestors, " ")
+}
+
+// SecurityHeaders sets the Content Security Policy and the headers that protect the pages of the console.
+// config is called for every request so changes in the settings apply without restarting. The API
+// and the public enrollment endpoints get a fixed minimal set of headers
+func SecurityHeaders(config func() SecurityHeadersConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+			if api.IsAPIRequest(c) {
+				header.Set(echo.HeaderContentSecurityPolicy, publicContentSecurityPolicy)
+				header.Set(echo.HeaderXFrameOptions, "DENY")
+				header.Set(echo.HeaderReferrerPolicy, "no-referrer")
+				return next(c)
+			}
+
+			cfg := config()
+			header.Set(echo.HeaderReferrerPolicy, "same-origin")
+			header.Set("Reporting-Endpoints", `csp="`+CSPReportPath+`"`)
+
+			if cfg.ReportOnly {
+				// Browsers ignore frame-ancestors in report-only policies, it's still enforced so
+				// trying a policy doesn't allow other sites to embed the console
+				header.Set(echo.HeaderContentSecurityPolicyReportOnly, cfg.Policy())
+				header.Set(echo.HeaderContentSecurityPolicy, cfg.frameAncestors())
+			} else {
+				header.Set(echo.HeaderContentSecurityPolicy, cfg.Policy())
+			}
+
+			// Old browsers only understand X-Frame-Options, which can't allow specific origins
+			if len(cfg.FrameAncestors) == 0 {
+				header.Set(echo.HeaderXFrameOptions, "DENY")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// CSPViolation is a violation of the Content Security Policy reported by a browser
+type CSPViolation struct {
+	Directive   string
+	BlockedURI  string
+	DocumentURI string
+}
+
+// ParseCSPReport returns the violations of a report sent to the report-uri (application/csp-report)
+// or to the report-to endpoint (application/reports+json)
+func ParseCSPReport(body []byte) ([]CSPViolation, error) {
+	legacy := struct {
+		Report *struct {
+			DocumentURI        string `json:"document-uri"`
+			BlockedURI         string `json:"blocked-uri"`
+			ViolatedDirective  string `json:"violated-directive"`
+			EffectiveDirective string `json:"effective-directive"`
+		} `json:"csp-report"`
+	}{}
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		directive := legacy.Report.EffectiveDirective
+		if directive == "" {
+			directive, _, _ = strings.Cut(legacy.Report.ViolatedDirective, " ")
+		}
+		return []CSPViolation{newCSPViolation(directive, legacy.Report.BlockedURI, legacy.Report.DocumentURI)}, nil
+	}
+
+	reports := []struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			BlockedURL         string `json:"blockedURL"`
+			EffectiveDirective string `json:"effectiveDirective"`
+		} `json:"body"`
+	}{}
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, errors.New("the body is not a CSP report")
+	}
+
+	violations := []CSPViolation{}
+	for _, r := range reports {
+		if r.Type != "csp-violation" {
+			continue
+		}
+		violations = append(violations, newCSPViolation(r.Body.EffectiveDirective, r.Body.BlockedURL, r.Body.DocumentURL))
+	}
+	return violations, nil
+}
+
+// newCSPViolation removes the query and the fragment of the URLs, they may have tokens and they
+// would prevent grouping the same violation
+func newCSPViolation(directive, blockedURI, documentURI string) CSPViolation {
+	return CSPViolation{
+		Directive:   truncate(strings.TrimSpace(directive), 100),
+		BlockedURI:  truncate(stripURLQuery(blockedURI), 500),
+		DocumentURI: truncate(stripURLQuery(documentURI), 500),
+	}
+}
+
+func stripURLQuery(value string) string {
+	value = strings.TrimSpace(value)
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		// Keywords like inline or eval
+		return value
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	return u.String()
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
+
+// CSPReportHandler passes the reported violations to save. Invalid reports are ignored, browsers
+// don't do anything with the response
+func CSPReportHandler(save func(v CSPViolation) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxCSPReportSize))
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		violations, err := ParseCSPReport(body)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		for _, v := range violations {
+			if v.Directive == "" {
+				continue
+			}
+			if err := save(v); err != nil {
+				log.Printf("[ERROR]: could not save the CSP violation, reason: %v", err)
+				return c.NoContent(http.StatusInternalServerError)
+			}
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithSecurityHeaders(cfg SecurityHeadersConfig, path string) http.Header {
+	e := echo.New()
+	e.Use(SecurityHeaders(func() SecurityHeadersConfig { return cfg }))
+	e.GET(path, func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Header()
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	header := serveWithSecurityHeaders(SecurityHeadersConfig{}, "/agents")
+
+	assert.Equal(t, DefaultContentSecurityPolicy+"; frame-ancestors 'none'; report-uri /csp-report; report-to csp", header.Get(echo.HeaderContentSecurityPolicy))
+	assert.Empty(t, header.Get(echo.HeaderContentSecurityPolicyReportOnly))
+	assert.Equal(t, "DENY", header.Get(echo.HeaderXFrameOptions))
+	assert.Equal(t, "nosniff", header.Get(echo.HeaderXContentTypeOptions))
+	assert.Equal(t, "same-origin", header.Get(echo.HeaderReferrerPolicy))
+	assert.Contains(t, header.Get(echo.HeaderContentSecurityPolicy), "img-src 'self' data:", "should allow the branding logos")
+}
+
+func TestSecurityHeadersReportOnly(t *testing.T) {
+	header := serveWithSecurityHeaders(SecurityHeadersConfig{ContentSecurityPolicy: "default-src 'self';", ReportOnly: true}, "/agents")
+
+	assert.Equal(t, "default-src 'self'; frame-ancestors 'none'; report-uri /csp-report; report-to csp", header.Get(echo.HeaderContentSecurityPolicyReportOnly))
+	assert.Equal(t, "frame-ancestors 'none'", header.Get(echo.HeaderContentSecurityPolicy), "should still enforce frame-ancestors")
+	assert.Equal(t, "DENY", header.Get(echo.HeaderXFrameOptions))
+}
+
+func TestSecurityHeadersFrameAncestors(t *testing.T) {
+	header := serveWithSecurityHeaders(SecurityHeadersConfig{FrameAncestors: []string{"'self'", "https://intranet.example.com"}}, "/agents")
+
+	assert.Contains(t, header.Get(echo.HeaderContentSecurityPolicy), "frame-ancestors 'self' https://intranet.example.com;")
+	assert.Empty(t, header.Get(echo.HeaderXFrameOptions), "X-Frame-Options would block the allowed origins")
+}
+
+func TestSecurityHeadersPublicEndpoints(t *testing.T) {
+	header := serveWithSecurityHeaders(SecurityHeadersConfig{FrameAncestors: []string{"https://intranet.example.com"}, ReportOnly: true}, "/api/enroll/token/config")
+
+	assert.Equal(t, publicContentSecurityPolicy, header.Get(echo.HeaderContentSecurityPolicy))
+	assert.Empty(t, header.Get(echo.HeaderContentSecurityPolicyReportOnly))
+	assert.Equal(t, "DENY", header.Get(echo.HeaderXFrameOptions))
+	assert.Equal(t, "no-referrer", header.Get(echo.HeaderReferrerPolicy))
+	assert.Equal(t, "nosniff", header.Get(echo.HeaderXContentTypeOptions))
+}
+
+func TestParseCSPReport(t *testing.T) {
+	violations, err := ParseCSPReport([]byte(`{"csp-report": {"document-uri": "https://console.example.com/agents?page=2", "blocked-uri": "https://cdn.example.com/lib.js?v=1#x", "violated-directive": "script-src-elem 'self'"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []CSPViolation{{Directive: "script-src-elem", BlockedURI: "https://cdn.example.com/lib.js", DocumentURI: "https://console.example.com/agents"}}, violations)
+
+	violations, err = ParseCSPReport([]byte(`[
+		{"type": "csp-violation", "body": {"documentURL": "https://console.example.com/", "blockedURL": "inline", "effectiveDirective": "style-src-attr"}},
+		{"type": "deprecation", "body": {}}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []CSPViolation{{Directive: "style-src-attr", BlockedURI: "inline", DocumentURI: "https://console.example.com/"}}, violations, "should ignore other reports")
+
+	_, err = ParseCSPReport([]byte(`not a report`))
+	assert.Error(t, err)
+}
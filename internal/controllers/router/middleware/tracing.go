@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/sessions"
+	"github.com/open-uem/openuem-console/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a span for each request, continuing the trace of the reverse proxy if it sent one.
+// The span is named after the route, not the path, as paths have the IDs of tenants and agents. The
+// user and tenant are added once the request has been handled, as they're known after authorization.
+// It must run after the sessions middleware to read the user from the session
+func Tracing(s *sessions.SessionManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = "unknown route"
+			}
+
+			ctx, span := telemetry.Tracer().Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", req.URL.Path),
+					attribute.String("client.address", c.RealIP()),
+					attribute.String("user_agent.original", req.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response so its status is recorded
+				c.Error(err)
+				span.RecordError(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			span.SetAttributes(requestAttributes(c, s)...)
+			return nil
+		}
+	}
+}
+
+// requestAttributes returns the user, tenant and site the request was made for, the ones checked
+// by the tenant access and API token middlewares if they ran
+func requestAttributes(c echo.Context, s *sessions.SessionManager) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+
+	user, _ := c.Get("user_id").(string)
+	if user == "" && s != nil {
+		user = s.Manager.GetString(c.Request().Context(), "uid")
+	}
+	if user != "" {
+		attrs = append(attrs, telemetry.UserIDKey.String(user))
+	}
+
+	if tenantID, ok := c.Get("tenant_id").(int); ok {
+		attrs = append(attrs, telemetry.TenantIDKey.String(strconv.Itoa(tenantID)))
+	} else if tenantID := c.Param("tenant"); tenantID != "" {
+		attrs = append(attrs, telemetry.TenantIDKey.String(tenantID))
+	}
+
+	if siteID := c.Param("site"); siteID != "" {
+		attrs = append(attrs, telemetry.SiteIDKey.String(siteID))
+	}
+	return attrs
+}
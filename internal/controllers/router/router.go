@@ -8,22 +8,35 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	session "github.com/canidam/echo-scs-session"
 	"github.com/invopop/ctxi18n"
 	"github.com/labstack/echo/v4"
 	mw "github.com/labstack/echo/v4/middleware"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
 	"github.com/open-uem/openuem-console/internal/controllers/router/middleware"
 	"github.com/open-uem/openuem-console/internal/controllers/sessions"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 	"github.com/open-uem/openuem-console/internal/views"
 	"github.com/open-uem/openuem-console/internal/views/locales"
 	"github.com/open-uem/utils"
 )
 
-func New(s *sessions.SessionManager, server, port, maxUploadSize string) *echo.Echo {
+const (
+	// cspReportRate and cspReportBurst are how many CSP reports are accepted from an IP per second
+	cspReportRate  = 1
+	cspReportBurst = 20
+)
+
+func New(m *models.Model, s *sessions.SessionManager, server, port, maxUploadSize, trustedProxies string) *echo.Echo {
 
 	e := echo.New()
 
+	// Resolve the client IP taking into account trusted reverse proxies
+	e.IPExtractor = NewIPExtractor(trustedProxies)
+
 	cwd, err := utils.GetWd()
 	if err != nil {
 		log.Fatalf("[FATAL]: could not get working directory: %v", err)
@@ -35,11 +48,41 @@ func New(s *sessions.SessionManager, server, port, maxUploadSize string) *echo.E
 	// Favicon
 	faviconHandler(e, assetsPath)
 
-	// Add i18n middleware
-	if err := ctxi18n.LoadWithDefault(locales.Content, "en"); err != nil {
+	// Load translations, keys missing in a translation use the default language
+	translations, err := locales.WithFallback("en")
+	if err != nil {
+		log.Fatalf("[FATAL]: could not prepare translations: %v", err)
+	}
+	if err := ctxi18n.LoadWithDefault(translations, "en"); err != nil {
 		log.Fatalf("[FATAL]: could not load translations: %v", err)
 	}
-	e.Use(middleware.GetLocale)
+
+	// Add a request ID so unexpected errors shown to users can be found in the logs
+	e.Use(mw.RequestID())
+
+	// Add the Content Security Policy and the other security headers
+	e.Use(middleware.SecurityHeaders(func() middleware.SecurityHeadersConfig { return securityHeadersConfig(m) }))
+
+	// Collect the violations of the policy reported by the browsers, it's public so it's limited by IP
+	e.POST(middleware.CSPReportPath, middleware.CSPReportHandler(func(v middleware.CSPViolation) error {
+		return m.SaveCSPViolation(v.Directive, v.BlockedURI, v.DocumentURI)
+	}), mw.RateLimiterWithConfig(mw.RateLimiterConfig{
+		Store: mw.NewRateLimiterMemoryStoreWithConfig(mw.RateLimiterMemoryStoreConfig{Rate: cspReportRate, Burst: cspReportBurst, ExpiresIn: 3 * time.Minute}),
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			return c.NoContent(http.StatusTooManyRequests)
+		},
+	}))
+
+	// Add sessions middleware
+	e.Use(session.LoadAndSave(s.Manager))
+
+	// Trace the requests if traces are exported, it must run after the sessions middleware
+	if telemetry.Enabled() {
+		e.Use(middleware.Tracing(s))
+	}
+
+	// Add i18n middleware, it must run after the sessions middleware to use the user's language
+	e.Use(middleware.GetLocale(s))
 
 	// Limit uploads
 	e.Use(mw.BodyLimit(maxUploadSize))
@@ -52,6 +95,7 @@ func New(s *sessions.SessionManager, server, port, maxUploadSize string) *echo.E
 
 	// Add CSRF middleware
 	e.Use(mw.CSRFWithConfig(mw.CSRFConfig{
+		Skipper:        skipCSRF,
 		TokenLookup:    "cookie:_csrf",
 		CookiePath:     "/",
 		CookieSecure:   true,
@@ -59,9 +103,6 @@ func New(s *sessions.SessionManager, server, port, maxUploadSize string) *echo.E
 		CookieSameSite: http.SameSiteStrictMode,
 	}))
 
-	// Add sessions middleware
-	e.Use(session.LoadAndSave(s.Manager))
-
 	// Custom HTTP Error Handler
 	e.HTTPErrorHandler = customHTTPErrorHandler
 
@@ -71,6 +112,28 @@ func New(s *sessions.SessionManager, server, port, maxUploadSize string) *echo.E
 	return e
 }
 
+// skipCSRF skips the CSRF check for the API, whose requests are authenticated with tokens instead of
+// cookies, and for the CSP reports sent by the browsers
+func skipCSRF(c echo.Context) bool {
+	return api.IsAPIRequest(c) || c.Request().URL.Path == middleware.CSPReportPath
+}
+
+// securityHeadersConfig returns the security headers set by the admins or the defaults if the settings
+// can't be read
+func securityHeadersConfig(m *models.Model) middleware.SecurityHeadersConfig {
+	s, err := m.GetSecurityHeadersSettings()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the security headers settings, the defaults are used: %v", err)
+		return middleware.SecurityHeadersConfig{}
+	}
+
+	return middleware.SecurityHeadersConfig{
+		ContentSecurityPolicy: s.ContentSecurityPolicy,
+		ReportOnly:            s.ReportOnly,
+		FrameAncestors:        strings.Fields(s.FrameAncestors),
+	}
+}
+
 func faviconHandler(e *echo.Echo, assetsPath string) string {
 
 	// TODO - Replace with a better cache approach like immutable
@@ -88,16 +151,18 @@ func faviconHandler(e *echo.Echo, assetsPath string) string {
 	return assetsPath
 }
 
-func staticAssets(e *echo.Echo, cwd string) string {
-	var assetsPath string
-
-	// Static assets + Headers (Ref: https://github.com/labstack/echo/issues/1902#issuecomment-2435145166)
+// AssetsPath returns the directory served as static assets, the web root of the console
+func AssetsPath(cwd string) string {
 	if strings.HasSuffix(cwd, "tmp") {
 		// DEVEL
-		assetsPath = filepath.Join(filepath.Dir(cwd), "assets")
-	} else {
-		assetsPath = filepath.Join(cwd, "assets")
+		return filepath.Join(filepath.Dir(cwd), "assets")
 	}
+	return filepath.Join(cwd, "assets")
+}
+
+func staticAssets(e *echo.Echo, cwd string) string {
+	// Static assets + Headers (Ref: https://github.com/labstack/echo/issues/1902#issuecomment-2435145166)
+	assetsPath := AssetsPath(cwd)
 
 	// TODO Etag middleware so no-cache can make use of no-cache
 	// Ref: https://github.com/pablor21/echo-etag
@@ -120,6 +185,17 @@ func staticAssets(e *echo.Echo, cwd string) string {
 }
 
 func customHTTPErrorHandler(err error, c echo.Context) {
+	// The handler has already answered, e.g. with a redirect
+	if c.Response().Committed {
+		return
+	}
+
+	// API clients get the errors as JSON
+	if api.IsAPIRequest(c) {
+		api.HandleError(err, c)
+		return
+	}
+
 	if he, ok := err.(*echo.HTTPError); ok {
 		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTML)
 		switch he.Code {
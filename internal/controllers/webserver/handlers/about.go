@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"runtime"
+
+	"github.com/labstack/echo/v4"
+	model "github.com/open-uem/openuem-console/internal/models/servers"
+	"github.com/open-uem/openuem-console/internal/version"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+)
+
+// About shows the version of the console and the latest release published in its update channel
+func (h *Handler) About(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	info := admin_views.AboutInfo{
+		Version:      h.Version,
+		Commit:       version.GetCommit(),
+		GoVersion:    runtime.Version(),
+		CheckUpdates: h.CheckUpdates,
+	}
+
+	// The latest release isn't known until the first check has finished
+	if latestRelease, err := model.GetLatestServerReleaseFromAPI(h.ServerReleasesFolder); err == nil {
+		info.LatestRelease = latestRelease
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.AboutIndex(" | About", admin_views.About(c, info, agentsExists, serversExists, commonInfo), commonInfo))
+}
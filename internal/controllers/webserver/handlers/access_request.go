@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/login_views"
+)
+
+// accessRequestURL is the page where the users without tenants can ask for access
+const accessRequestURL = "/access-request"
+
+// AccessRequest shows the users without tenants that they must be assigned to one, and lets them ask the admins
+func (h *Handler) AccessRequest(c echo.Context) error {
+	return h.renderAccessRequest(c, false, false)
+}
+
+// SendAccessRequest asks the admins of the main tenant to assign a tenant to the user
+func (h *Handler) SendAccessRequest(c echo.Context) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+
+	sent, err := h.Model.RequestTenantAccess(username)
+	if err != nil {
+		log.Printf("[ERROR]: could not send the access request of user %s, reason: %v", username, err)
+	}
+
+	h.auditTenantData(c, "has requested access to a tenant as they aren't assigned to any")
+
+	return h.renderAccessRequest(c, true, sent)
+}
+
+func (h *Handler) renderAccessRequest(c echo.Context, requested, sent bool) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+
+	// Users assigned to a tenant since they were sent here don't need to ask anymore
+	if _, err := h.Model.GetUserDefaultTenant(username); !errors.Is(err, models.ErrNoTenantsAssigned) {
+		return c.Redirect(http.StatusFound, "/")
+	}
+
+	csrfToken, _ := c.Get("csrf").(string)
+	branding, _ := h.Model.GetOrCreateBranding()
+	return RenderLogin(c, login_views.LoginIndex(login_views.AccessRequest(username, requested, sent, branding), csrfToken, branding))
+}
+
+// redirectToAccessRequest sends the user without tenants to the access request page. It returns
+// ErrNoTenantsAssigned so the handler stops once the redirect has been sent
+func redirectToAccessRequest(c echo.Context) error {
+	if c.Request().Header.Get("HX-Request") == "true" {
+		c.Response().Header().Set("HX-Redirect", accessRequestURL)
+		if err := c.NoContent(http.StatusOK); err != nil {
+			return err
+		}
+		return models.ErrNoTenantsAssigned
+	}
+
+	if err := c.Redirect(http.StatusFound, accessRequestURL); err != nil {
+		return err
+	}
+	return models.ErrNoTenantsAssigned
+}
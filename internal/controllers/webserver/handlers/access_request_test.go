@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectToAccessRequest(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	c := at.context(t, "nobody", http.MethodGet, "/computers", nil)
+	assert.ErrorIs(t, redirectToAccessRequest(c), models.ErrNoTenantsAssigned, "should stop the handler")
+	assert.Equal(t, http.StatusFound, c.Response().Status)
+	assert.Equal(t, accessRequestURL, c.Response().Header().Get("Location"))
+
+	c = at.context(t, "nobody", http.MethodGet, "/computers", nil)
+	c.Request().Header.Set("HX-Request", "true")
+	assert.ErrorIs(t, redirectToAccessRequest(c), models.ErrNoTenantsAssigned)
+	assert.Equal(t, accessRequestURL, c.Response().Header().Get("HX-Redirect"), "should load the page instead of swapping it")
+}
+
+func TestAccessRequestWithTenants(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	c := at.context(t, "operator", http.MethodGet, accessRequestURL, nil)
+	assert.NoError(t, at.h.AccessRequest(c))
+	assert.Equal(t, http.StatusFound, c.Response().Status)
+	assert.Equal(t, "/", c.Response().Header().Get("Location"), "should send the users with tenants to the dashboard")
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// AdminAllowlistMiddleware checks that the client IP is allowed to reach the admin area.
+// The global allowlist applies to every admin route and the tenant allowlist applies to
+// the tenant's admin routes. Main tenant admins can be exempted (break-glass) globally
+func (h *Handler) AdminAllowlistMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Global admin routes like /admin/tenants/:tenant also use the tenant param
+		tenantID := -1
+		if tID := c.Param("tenant"); isTenantAdminPath(c.Path()) && tID != "-1" {
+			id, err := strconv.Atoi(tID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+			}
+			tenantID = id
+		}
+
+		allowed, err := h.isIPAllowedInAdminArea(c.RealIP(), tenantID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if !allowed {
+			if h.isAdminAllowlistExempt(c) {
+				return next(c)
+			}
+			log.Printf("[WARN]: access to %s from %s has been blocked by the admin allowlist", c.Request().URL.Path, c.RealIP())
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "allowlist.forbidden", c.RealIP()))
+		}
+
+		return next(c)
+	}
+}
+
+// isTenantAdminPath returns true for the admin routes of a tenant, where its allowlist applies
+func isTenantAdminPath(path string) bool {
+	return strings.HasPrefix(path, "/tenant/:tenant/admin") || strings.HasPrefix(path, "/admin/:tenant/:site")
+}
+
+func (h *Handler) AdminAllowlist(c echo.Context) error {
+	var err error
+	successMessage := ""
+	lockoutWarning := false
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID := -1
+	if commonInfo.TenantID != "-1" {
+		tenantID, err = strconv.Atoi(commonInfo.TenantID)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+		}
+	}
+
+	allowlist, err := h.Model.GetAdminAllowlist(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "allowlist.could_not_get", err.Error()), true))
+	}
+
+	exempt, err := h.Model.GetAdminAllowlistExemption()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "allowlist.could_not_get", err.Error()), true))
+	}
+
+	if c.Request().Method == "POST" {
+		newAllowlist, err := normalizeCIDRList(c.FormValue("admin-allowlist"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "allowlist.invalid_cidr", err.Error()), true))
+		}
+
+		newExempt := exempt
+		if tenantID == -1 {
+			newExempt = c.FormValue("admin-allowlist-exempt") == "on"
+		}
+
+		// Warn the admin if the new rules would lock out the current session
+		if c.FormValue("confirm-lockout") != "true" && !h.wouldKeepAdminAccess(c, tenantID, newAllowlist, newExempt) {
+			lockoutWarning = true
+			allowlist = newAllowlist
+			exempt = newExempt
+		} else {
+			if err := h.Model.SaveAdminAllowlist(tenantID, newAllowlist); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "allowlist.could_not_save", err.Error()), true))
+			}
+
+			if tenantID == -1 {
+				if err := h.Model.UpdateAdminAllowlistExemption(newExempt); err != nil {
+					return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "allowlist.could_not_save", err.Error()), true))
+				}
+			}
+
+			if h.AuthLogger != nil {
+				uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+				scope := "global"
+				if tenantID != -1 {
+					scope = fmt.Sprintf("tenant %d", tenantID)
+				}
+				h.AuthLogger.Printf("user %s has changed the %s admin allowlist from %q to %q (main tenant admins exempt: %t) from %s", uid, scope, allowlist, newAllowlist, newExempt, c.RealIP())
+			}
+
+			allowlist = newAllowlist
+			exempt = newExempt
+			successMessage = i18n.T(c.Request().Context(), "allowlist.saved")
+		}
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.AdminAllowlistIndex(" | Admin Allowlist", admin_views.AdminAllowlist(c, allowlist, exempt, c.RealIP(), lockoutWarning, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo), successMessage), commonInfo))
+}
+
+// isIPAllowedInAdminArea checks the IP against the global allowlist and, if a tenant is set, the tenant's allowlist
+func (h *Handler) isIPAllowedInAdminArea(ip string, tenantID int) (bool, error) {
+	globalAllowlist, err := h.Model.GetAdminAllowlist(-1)
+	if err != nil {
+		return false, err
+	}
+
+	if !isIPInCIDRList(ip, globalAllowlist) {
+		return false, nil
+	}
+
+	if tenantID == -1 {
+		return true, nil
+	}
+
+	tenantAllowlist, err := h.Model.GetAdminAllowlist(tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	return isIPInCIDRList(ip, tenantAllowlist), nil
+}
+
+func (h *Handler) isAdminAllowlistExempt(c echo.Context) bool {
+	exempt, err := h.Model.GetAdminAllowlistExemption()
+	if err != nil || !exempt {
+		return false
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if uid == "" {
+		return false
+	}
+
+	isMainAdmin, err := h.isMainTenantAdmin(c, uid)
+	if err != nil {
+		return false
+	}
+
+	return isMainAdmin
+}
+
+// wouldKeepAdminAccess checks if the current client would still reach the admin area with the new allowlist
+func (h *Handler) wouldKeepAdminAccess(c echo.Context, tenantID int, newAllowlist string, newExempt bool) bool {
+	ip := c.RealIP()
+
+	if !isIPInCIDRList(ip, newAllowlist) {
+		if !newExempt {
+			return false
+		}
+		uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+		isMainAdmin, err := h.isMainTenantAdmin(c, uid)
+		return err == nil && isMainAdmin
+	}
+
+	// A tenant allowlist is always combined with the global one
+	if tenantID != -1 {
+		allowed, err := h.isIPAllowedInAdminArea(ip, -1)
+		if err != nil {
+			return false
+		}
+		return allowed || h.isAdminAllowlistExempt(c)
+	}
+
+	return true
+}
+
+// normalizeCIDRList validates a comma or newline separated list of IPs or CIDRs
+// and returns it as a comma-separated list. Single IPs are converted to host CIDRs
+func normalizeCIDRList(list string) (string, error) {
+	cidrs := []string{}
+
+	entries := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return "", fmt.Errorf("%s", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return "", fmt.Errorf("%s", entry)
+		}
+		cidrs = append(cidrs, network.String())
+	}
+
+	return strings.Join(cidrs, ","), nil
+}
+
+// isIPInCIDRList returns true if the list is empty (no restriction) or the IP belongs to one of its networks
+func isIPInCIDRList(ip string, list string) bool {
+	if strings.TrimSpace(list) == "" {
+		return true
+	}
+
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+
+	for _, entry := range strings.Split(list, ",") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		if network.Contains(clientIP) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newAllowlistTest adds the global settings and the settings of both tenants to the authorization test
+func newAllowlistTest(t *testing.T) *authorizationTest {
+	at := newAuthorizationTest(t)
+
+	client := at.h.Model.Client
+	assert.NoError(t, client.Settings.Create().Exec(context.Background()))
+	assert.NoError(t, client.Settings.Create().SetTenantID(at.mainTenantID).Exec(context.Background()))
+	assert.NoError(t, client.Settings.Create().SetTenantID(at.secondTenantID).Exec(context.Background()))
+
+	return at
+}
+
+func (at *authorizationTest) setAllowlists(t *testing.T, global, tenant string, exempt bool) {
+	assert.NoError(t, at.h.Model.SaveAdminAllowlist(-1, global))
+	assert.NoError(t, at.h.Model.SaveAdminAllowlist(at.secondTenantID, tenant))
+	assert.NoError(t, at.h.Model.UpdateAdminAllowlistExemption(exempt))
+}
+
+// allowlistContext returns a request of the user from the ip
+func (at *authorizationTest) allowlistContext(t *testing.T, uid, ip, path string, params map[string]string) echo.Context {
+	c := at.context(t, uid, http.MethodGet, path, params)
+	c.Request().Header.Set(echo.HeaderXRealIP, ip)
+	return c
+}
+
+func TestNormalizeCIDRList(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    string
+		invalid string
+	}{
+		{name: "empty", list: "", want: ""},
+		{name: "only separators", list: " ,\n\t", want: ""},
+		{name: "bare IPv4", list: "192.168.1.10", want: "192.168.1.10/32"},
+		{name: "bare IPv6", list: "2001:db8::1", want: "2001:db8::1/128"},
+		{name: "IPv4 CIDR", list: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{name: "IPv6 CIDR", list: "2001:db8::/32", want: "2001:db8::/32"},
+		{name: "host bits are cleared", list: "10.1.2.3/16", want: "10.1.0.0/16"},
+		{name: "mixed separators", list: "10.0.0.1, 172.16.0.0/12\r\n192.168.0.0/16\t::1 ,2001:db8::/48", want: "10.0.0.1/32,172.16.0.0/12,192.168.0.0/16,::1/128,2001:db8::/48"},
+		{name: "invalid IP", list: "10.0.0.1,10.0.0.300", invalid: "10.0.0.300"},
+		{name: "invalid hostname", list: "intranet.example.com", invalid: "intranet.example.com"},
+		{name: "invalid prefix", list: "10.0.0.0/33", invalid: "10.0.0.0/33"},
+		{name: "invalid CIDR", list: "10.0.0/8", invalid: "10.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeCIDRList(tt.list)
+			if tt.invalid != "" {
+				assert.EqualError(t, err, tt.invalid, "should return the invalid entry")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsIPInCIDRList(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		list string
+		want bool
+	}{
+		{name: "empty list allows every IP", ip: "203.0.113.5", list: "", want: true},
+		{name: "blank list allows every IP", ip: "203.0.113.5", list: "  ", want: true},
+		{name: "host CIDR", ip: "192.168.1.10", list: "192.168.1.10/32", want: true},
+		{name: "other host", ip: "192.168.1.11", list: "192.168.1.10/32", want: false},
+		{name: "network", ip: "10.20.30.40", list: "172.16.0.0/12,10.0.0.0/8", want: true},
+		{name: "outside the networks", ip: "11.0.0.1", list: "172.16.0.0/12,10.0.0.0/8", want: false},
+		{name: "IPv6 network", ip: "2001:db8::42", list: "2001:db8::/32", want: true},
+		{name: "IPv6 outside the network", ip: "2001:db9::42", list: "2001:db8::/32", want: false},
+		{name: "IPv4 in an IPv6 list", ip: "10.0.0.1", list: "2001:db8::/32", want: false},
+		{name: "spaces around entries", ip: "10.0.0.1", list: "192.168.0.0/16, 10.0.0.0/8", want: true},
+		{name: "invalid entries are skipped", ip: "10.0.0.1", list: "bogus,10.0.0.0/8", want: true},
+		{name: "invalid IP", ip: "not-an-ip", list: "10.0.0.0/8", want: false},
+		{name: "empty IP", ip: "", list: "10.0.0.0/8", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isIPInCIDRList(tt.ip, tt.list))
+		})
+	}
+}
+
+func TestAdminAllowlistMiddleware(t *testing.T) {
+	at := newAllowlistTest(t)
+	second := map[string]string{"tenant": strconv.Itoa(at.secondTenantID)}
+
+	tests := []struct {
+		name   string
+		global string
+		tenant string
+		exempt bool
+		uid    string
+		ip     string
+		path   string
+		params map[string]string
+		code   int
+	}{
+		{name: "no allowlists", uid: "admin", ip: "203.0.113.5", path: "/admin/branding"},
+		{name: "allowed by the global allowlist", global: "10.0.0.0/8", uid: "admin", ip: "10.1.2.3", path: "/admin/branding"},
+		{name: "blocked by the global allowlist", global: "10.0.0.0/8", uid: "admin", ip: "203.0.113.5", path: "/admin/branding", code: http.StatusForbidden},
+		{name: "global allowlist applies to tenant admin routes", global: "10.0.0.0/8", uid: "operator", ip: "203.0.113.5", path: "/tenant/:tenant/admin/enrollment", params: second, code: http.StatusForbidden},
+		{name: "allowed by both allowlists", global: "10.0.0.0/8", tenant: "10.1.0.0/16", uid: "operator", ip: "10.1.2.3", path: "/tenant/:tenant/admin/enrollment", params: second},
+		{name: "blocked by the tenant allowlist", global: "10.0.0.0/8", tenant: "10.1.0.0/16", uid: "operator", ip: "10.2.0.1", path: "/tenant/:tenant/admin/enrollment", params: second, code: http.StatusForbidden},
+		{name: "tenant allowlist doesn't apply to global routes", tenant: "10.1.0.0/16", uid: "admin", ip: "10.2.0.1", path: "/admin/tenants/:tenant", params: second},
+		{name: "tenant allowlist doesn't apply to the tenant placeholder", tenant: "10.1.0.0/16", uid: "admin", ip: "10.2.0.1", path: "/tenant/:tenant/admin/enrollment", params: map[string]string{"tenant": "-1"}},
+		{name: "main tenant admins are exempt", global: "10.0.0.0/8", exempt: true, uid: "admin", ip: "203.0.113.5", path: "/admin/branding"},
+		{name: "main tenant admins are exempt from tenant allowlists", tenant: "10.1.0.0/16", exempt: true, uid: "admin", ip: "10.2.0.1", path: "/tenant/:tenant/admin/enrollment", params: second},
+		{name: "exemption is only for main tenant admins", global: "10.0.0.0/8", exempt: true, uid: "operator", ip: "203.0.113.5", path: "/tenant/:tenant/admin/enrollment", params: second, code: http.StatusForbidden},
+		{name: "exemption requires a session", global: "10.0.0.0/8", exempt: true, uid: "", ip: "203.0.113.5", path: "/admin/branding", code: http.StatusForbidden},
+		{name: "invalid tenant", uid: "admin", ip: "10.1.2.3", path: "/tenant/:tenant/admin/enrollment", params: map[string]string{"tenant": "abc"}, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at.setAllowlists(t, tt.global, tt.tenant, tt.exempt)
+
+			reached := false
+			next := func(c echo.Context) error {
+				reached = true
+				return nil
+			}
+
+			err := at.h.AdminAllowlistMiddleware(next)(at.allowlistContext(t, tt.uid, tt.ip, tt.path, tt.params))
+			if tt.code != 0 {
+				assertHTTPError(t, tt.code, err, tt.name)
+				assert.False(t, reached, "should not reach the handler")
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, reached, "should reach the handler")
+		})
+	}
+}
+
+func TestAdminAllowlistOfTenantWithoutSettings(t *testing.T) {
+	at := newAllowlistTest(t)
+
+	// The settings of a tenant are only created once they're saved
+	tenant, err := at.h.Model.Client.Tenant.Create().SetDescription("Third").Save(context.Background())
+	assert.NoError(t, err)
+	params := map[string]string{"tenant": strconv.Itoa(tenant.ID)}
+
+	allowlist, err := at.h.Model.GetAdminAllowlist(tenant.ID)
+	assert.NoError(t, err, "a tenant without settings should have an empty allowlist")
+	assert.Empty(t, allowlist)
+
+	next := func(c echo.Context) error { return nil }
+	err = at.h.AdminAllowlistMiddleware(next)(at.allowlistContext(t, "admin", "203.0.113.5", "/tenant/:tenant/admin/enrollment", params))
+	assert.NoError(t, err, "should reach the admin area of a tenant without settings")
+
+	c := at.allowlistContext(t, "admin", "203.0.113.5", "/admin/allowlist", nil)
+	assert.False(t, at.h.wouldKeepAdminAccess(c, tenant.ID, "10.0.0.0/8", false), "should check the lockout of a tenant without settings")
+
+	assert.NoError(t, at.h.Model.SaveAdminAllowlist(tenant.ID, "10.0.0.0/8"), "should create the settings of the tenant")
+	allowlist, err = at.h.Model.GetAdminAllowlist(tenant.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", allowlist, "should keep the saved allowlist")
+
+	err = at.h.AdminAllowlistMiddleware(next)(at.allowlistContext(t, "admin", "203.0.113.5", "/tenant/:tenant/admin/enrollment", params))
+	assertHTTPError(t, http.StatusForbidden, err, "should apply the saved allowlist")
+}
+
+func TestIsAdminAllowlistExempt(t *testing.T) {
+	at := newAllowlistTest(t)
+
+	tests := []struct {
+		name   string
+		exempt bool
+		uid    string
+		want   bool
+	}{
+		{name: "main tenant admin", exempt: true, uid: "admin", want: true},
+		{name: "exemption disabled", exempt: false, uid: "admin", want: false},
+		{name: "not a main tenant admin", exempt: true, uid: "operator", want: false},
+		{name: "unknown user", exempt: true, uid: "nobody", want: false},
+		{name: "no session", exempt: true, uid: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at.setAllowlists(t, "10.0.0.0/8", "", tt.exempt)
+			assert.Equal(t, tt.want, at.h.isAdminAllowlistExempt(at.allowlistContext(t, tt.uid, "203.0.113.5", "/admin/branding", nil)))
+		})
+	}
+}
+
+func TestWouldKeepAdminAccess(t *testing.T) {
+	at := newAllowlistTest(t)
+
+	tests := []struct {
+		name         string
+		global       string
+		exempt       bool
+		uid          string
+		ip           string
+		tenantID     int
+		newAllowlist string
+		newExempt    bool
+		want         bool
+	}{
+		{name: "empty allowlist", uid: "admin", ip: "203.0.113.5", tenantID: -1, want: true},
+		{name: "client in the new global allowlist", uid: "admin", ip: "10.1.2.3", tenantID: -1, newAllowlist: "10.0.0.0/8", want: true},
+		{name: "new global allowlist locks out the client", uid: "admin", ip: "203.0.113.5", tenantID: -1, newAllowlist: "10.0.0.0/8", want: false},
+		{name: "new exemption keeps the main tenant admin", uid: "admin", ip: "203.0.113.5", tenantID: -1, newAllowlist: "10.0.0.0/8", newExempt: true, want: true},
+		{name: "new exemption doesn't keep other users", uid: "operator", ip: "203.0.113.5", tenantID: -1, newAllowlist: "10.0.0.0/8", newExempt: true, want: false},
+		{name: "new tenant allowlist locks out the client", uid: "operator", ip: "10.2.0.1", tenantID: 0, newAllowlist: "10.1.0.0/16", want: false},
+		{name: "client in the new tenant allowlist", uid: "operator", ip: "10.1.2.3", tenantID: 0, newAllowlist: "10.1.0.0/16", want: true},
+		{name: "tenant allowlist is combined with the global one", global: "192.168.0.0/16", uid: "operator", ip: "10.1.2.3", tenantID: 0, newAllowlist: "10.1.0.0/16", want: false},
+		{name: "global exemption keeps the main tenant admin in tenants", global: "192.168.0.0/16", exempt: true, uid: "admin", ip: "10.1.2.3", tenantID: 0, newAllowlist: "10.1.0.0/16", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at.setAllowlists(t, tt.global, "", tt.exempt)
+
+			// 0 stands for the second tenant as its ID is only known once it's created
+			tenantID := tt.tenantID
+			if tenantID == 0 {
+				tenantID = at.secondTenantID
+			}
+
+			c := at.allowlistContext(t, tt.uid, tt.ip, "/admin/allowlist", nil)
+			assert.Equal(t, tt.want, at.h.wouldKeepAdminAccess(c, tenantID, tt.newAllowlist, tt.newExempt), "the self-lockout warning should be shown when the client would lose access")
+		})
+	}
+}
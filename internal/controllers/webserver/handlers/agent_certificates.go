@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/views/computers_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"golang.org/x/crypto/ocsp"
+)
+
+// certManagerPingTimeout is how long the certificate manager has to answer before it's considered unreachable
+const certManagerPingTimeout = 2 * time.Second
+
+// AgentCertificate shows the status of the certificate that the agent uses to connect to NATS
+func (h *Handler) AgentCertificate(c echo.Context) error {
+	return h.agentCertificate(c, "")
+}
+
+// RevokeAgentCertificate revokes the certificate of a lost or stolen agent. The revocation is recorded in the
+// database that the OCSP responder reads, so it doesn't depend on the certificate manager being reachable
+func (h *Handler) RevokeAgentCertificate(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	reason, ok := decommissionReason(c.FormValue("reason"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.certificate_reason_required", maxDecommissionReasonLength), true))
+	}
+
+	if err := h.Model.RevokeAgentCertificates(agent.ID, tenantID, reason, ocsp.KeyCompromise); err != nil {
+		log.Printf("[ERROR]: could not revoke the certificate of agent %s, reason: %v", agent.ID, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_revoke_certificate", err.Error()), true))
+	}
+
+	h.auditTenantData(c, "has revoked the certificate of agent %s (%s) of tenant %d, reason: %q", agent.ID, agent.Hostname, tenantID, reason)
+
+	return h.agentCertificate(c, i18n.T(c.Request().Context(), "agents.certificate_revoked"))
+}
+
+// ReissueAgentCertificate requests a new certificate for the agent to the certificate manager, e.g. when a
+// stolen device has been recovered. It fails without changes if the certificate manager can't be reached
+func (h *Handler) ReissueAgentCertificate(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	reason, ok := decommissionReason(c.FormValue("reason"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.certificate_reason_required", maxDecommissionReasonLength), true))
+	}
+
+	if err := h.checkCertManager(c.Request().Context()); err != nil {
+		log.Printf("[ERROR]: could not request a new certificate for agent %s, reason: %v", agent.ID, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.certificate_authority_unreachable"), true))
+	}
+
+	data, err := json.Marshal(h.agentCertificateRequest(agent, commonInfo.TenantID))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	if err := h.natsPublish(c.Request().Context(), "certificates.agent."+agent.ID, data); err != nil {
+		log.Printf("[ERROR]: could not request a new certificate for agent %s, reason: %v", agent.ID, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.certificate_authority_unreachable"), true))
+	}
+
+	if err := h.Model.SetAgentCertificateRequested(agent.ID, tenantID, reason); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has requested a new certificate for agent %s (%s) of tenant %d, reason: %q", agent.ID, agent.Hostname, tenantID, reason)
+
+	return h.agentCertificate(c, i18n.T(c.Request().Context(), "agents.certificate_requested"))
+}
+
+// RevokeTenantAgentCertificates revokes the certificates of all the agents of the tenant, so none of its devices
+// can connect anymore when the tenant is offboarded. The agents affected are shown first and the name of the
+// tenant must be typed to revoke them
+func (h *Handler) RevokeTenantAgentCertificates(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	t, err := h.Model.GetTenantByID(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	reason, ok := decommissionReason(c.FormValue("reason"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.certificate_reason_required", maxDecommissionReasonLength), false))
+	}
+
+	agentIDs, err := h.Model.GetTenantAgentsToRevoke(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	scope := dangerousActionScope{Action: "revoke-tenant-certificates", Target: strconv.Itoa(tenantID), Items: agentIDs}
+
+	if c.FormValue(partials.DangerousActionTokenField) == "" {
+		token, err := h.newDangerousAction(c, scope)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(dangerousActionErrorMessage(c, err), false))
+		}
+
+		return RenderConfirm(c, partials.ConfirmDangerousAction(c, partials.DangerousAction{
+			Title: i18n.T(c.Request().Context(), "tenants.revoke_certificates_title"),
+			Consequences: []string{
+				i18n.T(c.Request().Context(), "tenants.revoke_certificates_agents", len(agentIDs), t.Description),
+				i18n.T(c.Request().Context(), "dangerous_action.irreversible"),
+			},
+			Acknowledgement: t.Description,
+			Token:           token,
+			Method:          http.MethodPost,
+			URL:             fmt.Sprintf("/admin/tenants/%d/revoke-certificates", tenantID),
+			CancelURL:       fmt.Sprintf("/admin/tenants/%d", tenantID),
+			Fields:          map[string]string{"reason": reason},
+		}))
+	}
+
+	if err := h.checkDangerousAction(c, scope, t.Description); err != nil {
+		return RenderError(c, partials.ErrorMessage(dangerousActionErrorMessage(c, err), false))
+	}
+
+	count, err := h.Model.RevokeTenantAgentCertificates(tenantID, agentIDs, reason, ocsp.CessationOfOperation)
+	if err != nil {
+		log.Printf("[ERROR]: could not revoke the certificates of the agents of tenant %d, reason: %v", tenantID, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_revoke_certificate", err.Error()), false))
+	}
+
+	h.auditTenantData(c, "has revoked the certificates of %d agents of tenant %d, reason: %q", count, tenantID, reason)
+
+	return h.ListTenants(c, i18n.T(c.Request().Context(), "tenants.certificates_revoked", count), "", false)
+}
+
+func (h *Handler) agentCertificate(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agentId := c.Param("uuid")
+	if agentId == "" {
+		return RenderView(c, computers_views.InventoryIndex(" | Inventory", partials.Error(c, "an error occurred getting uuid param", "Computer", partials.GetNavigationUrl(commonInfo, "/computers"), commonInfo), commonInfo))
+	}
+
+	agent, err := h.Model.GetAgentById(agentId, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
+	}
+
+	certificate, err := h.Model.GetAgentCertificate(agent)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	settings, err := h.Model.GetNetbirdSettings(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "netbird.could_not_get_settings", err.Error()), true))
+	}
+	netbird := settings.AccessToken != ""
+
+	offline := h.IsAgentOffline(c)
+
+	p := partials.PaginationAndSort{}
+	confirmDelete := c.QueryParam("delete") != ""
+
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.AgentCertificate(c, p, agent, certificate, successMessage, confirmDelete, commonInfo, netbird, offline), commonInfo))
+}
+
+// errCertManagerNotConnected is returned when the certificate manager can't be pinged because NATS is not connected
+var errCertManagerNotConnected = errors.New("NATS is not connected")
+
+// checkCertManager pings the certificate manager, which issues the certificates of the agents
+func (h *Handler) checkCertManager(ctx context.Context) error {
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return errCertManagerNotConnected
+	}
+	_, err := h.natsRequest(ctx, "ping.certmanagerworker", nil, certManagerPingTimeout)
+	return err
+}
+
+// agentCertificateRequest is the request of a new certificate for the agent, with the domain of its site
+func (h *Handler) agentCertificateRequest(agent *ent.Agent, tenantID string) openuem_nats.CertificateRequest {
+	domain := h.Domain
+	if len(agent.Edges.Site) == 1 && agent.Edges.Site[0].Domain != "" {
+		domain = agent.Edges.Site[0].Domain
+	}
+
+	return openuem_nats.CertificateRequest{
+		AgentId:      agent.ID,
+		DNSName:      agent.Hostname + "." + domain,
+		Organization: h.OrgName,
+		Province:     h.OrgProvince,
+		Locality:     h.OrgLocality,
+		Address:      h.OrgAddress,
+		Country:      h.Country,
+		YearsValid:   2,
+		TenantID:     tenantID,
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/nats-io/nats.go"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentlogcollection"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/computers_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/open-uem/utils"
+)
+
+// agentLogCollectionSubject is the subject where the agent packages its log directory, followed by the agent's ID
+const agentLogCollectionSubject = "agent.collectlogs."
+
+// maxAgentLogCollectionSize is the maximum size of the logs package of an agent, bigger packages aren't downloaded
+const maxAgentLogCollectionSize int64 = 100 << 20
+
+// agentLogCollectionTTL is how long the collected logs can be downloaded before they're removed
+const agentLogCollectionTTL = 24 * time.Hour
+
+// agentLogCollectionRequestTimeout is how long the agent has to package its logs
+const agentLogCollectionRequestTimeout = 5 * time.Minute
+
+// agentLogCollectionsInterval is how often the queued collections of the online agents are started and the expired logs removed
+const agentLogCollectionsInterval = 1 * time.Minute
+
+// errAgentLogCollectionOffline is returned when the agent can't be asked for its logs, the collection is queued again
+var errAgentLogCollectionOffline = errors.New("the agent is offline")
+
+// agentLogCollectionRequest is sent to the agent so it doesn't package more logs than the console accepts
+type agentLogCollectionRequest struct {
+	MaxSize int64 `json:"max_size"`
+}
+
+// agentLogCollectionResponse is the package created by the agent, which is downloaded with SFTP
+type agentLogCollectionResponse struct {
+	File  string `json:"file"`
+	Size  int64  `json:"size"`
+	Error string `json:"error"`
+}
+
+// AgentLogCollections shows the log collections requested for an agent and the links to download the collected logs
+func (h *Handler) AgentLogCollections(c echo.Context) error {
+	return h.agentLogCollections(c, "")
+}
+
+// CollectAgentLogs requests the agent to package its logs. If the agent is offline the request is queued until
+// the agent is online again or the timeout of the settings is reached
+func (h *Handler) CollectAgentLogs(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	timeout, err := h.Model.GetAgentLogCollectionTimeout()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the log collection timeout, reason: %v", err)
+		timeout = models.DefaultAgentLogCollectionTimeout
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	collection, err := h.Model.CreateAgentLogCollection(agent.ID, tenantID, uid, time.Now().Add(timeout))
+	if err != nil {
+		if errors.Is(err, models.ErrAlreadyExists) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.log_collection_pending"), true))
+		}
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has requested the logs of agent %s (%s) of tenant %d", agent.ID, agent.Hostname, tenantID)
+
+	if !h.Presence.IsOnline(agent.ID) {
+		return h.agentLogCollections(c, i18n.T(c.Request().Context(), "agents.log_collection_queued"))
+	}
+
+	h.startAgentLogCollection(collection)
+	return h.agentLogCollections(c, i18n.T(c.Request().Context(), "agents.log_collection_requested"))
+}
+
+// DownloadAgentLogCollection sends the logs collected from an agent
+func (h *Handler) DownloadAgentLogCollection(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "agents.could_not_get_agent"))
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()))
+	}
+
+	id, err := strconv.Atoi(c.Param("collection"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "agents.log_collection_not_available"))
+	}
+
+	collection, err := h.Model.GetAgentLogCollection(id, agent.ID, tenantID)
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+
+	if collection.Status != agentlogcollection.StatusReady || collection.FileName == "" {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "agents.log_collection_not_available"))
+	}
+
+	// The logs are kept by the console replica that collected them
+	path := filepath.Join(agentLogCollectionsDir(), filepath.Base(collection.FileName))
+	if _, err := os.Stat(path); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "agents.log_collection_not_available"))
+	}
+
+	h.auditTenantData(c, "has downloaded the logs of agent %s (%s) of tenant %d", agent.ID, agent.Hostname, tenantID)
+
+	return c.Attachment(path, fmt.Sprintf("openuem-logs-%s-%s.zip", agent.Hostname, collection.CompletedAt.Format("20060102150405")))
+}
+
+func (h *Handler) agentLogCollections(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agentId := c.Param("uuid")
+	if agentId == "" {
+		return RenderView(c, computers_views.InventoryIndex(" | Inventory", partials.Error(c, "an error occurred getting uuid param", "Computer", partials.GetNavigationUrl(commonInfo, "/computers"), commonInfo), commonInfo))
+	}
+
+	agent, err := h.Model.GetAgentById(agentId, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	collections, err := h.Model.GetAgentLogCollections(agent.ID, tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the log collections of agent %s, reason: %v", agent.ID, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_log_collections"), true))
+	}
+
+	settings, err := h.Model.GetNetbirdSettings(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "netbird.could_not_get_settings", err.Error()), true))
+	}
+	netbird := settings.AccessToken != ""
+
+	offline := h.IsAgentOffline(c)
+
+	p := partials.PaginationAndSort{}
+	confirmDelete := c.QueryParam("delete") != ""
+
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.LogCollections(c, p, agent, collections, successMessage, confirmDelete, commonInfo, netbird, offline), commonInfo))
+}
+
+// startAgentLogCollection collects the logs in a background job so the request doesn't wait for the agent
+func (h *Handler) startAgentLogCollection(collection *ent.AgentLogCollection) {
+	_, err := h.TaskScheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+		gocron.NewTask(func() {
+			h.runAgentLogCollection(collection)
+		}),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the log collection of agent %s, it stays queued, reason: %v", collection.AgentID, err)
+	}
+}
+
+func (h *Handler) runAgentLogCollection(collection *ent.AgentLogCollection) {
+	// Every console replica receives the heartbeats, only one of them collects the logs
+	claimed, err := h.Model.ClaimAgentLogCollection(collection.ID)
+	if err != nil {
+		log.Printf("[ERROR]: could not start the log collection of agent %s, reason: %v", collection.AgentID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	fileName, size, err := h.collectAgentLogs(collection)
+	if errors.Is(err, errAgentLogCollectionOffline) {
+		log.Printf("[INFO]: agent %s is offline, its log collection stays queued", collection.AgentID)
+		if err := h.Model.RequeueAgentLogCollection(collection.ID); err != nil {
+			log.Printf("[ERROR]: could not queue again the log collection of agent %s, reason: %v", collection.AgentID, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR]: could not collect the logs of agent %s, reason: %v", collection.AgentID, err)
+		if err := h.Model.FailAgentLogCollection(collection.ID, err.Error()); err != nil {
+			log.Printf("[ERROR]: could not save the log collection error of agent %s, reason: %v", collection.AgentID, err)
+		}
+		return
+	}
+
+	if err := h.Model.CompleteAgentLogCollection(collection.ID, fileName, size, time.Now().Add(agentLogCollectionTTL)); err != nil {
+		log.Printf("[ERROR]: could not save the logs collected from agent %s, reason: %v", collection.AgentID, err)
+		removeAgentLogCollectionFile(fileName)
+		return
+	}
+	log.Printf("[INFO]: the logs of agent %s have been collected, %d bytes", collection.AgentID, size)
+}
+
+// collectAgentLogs asks the agent to package its log directory and downloads the package with SFTP,
+// it returns the name of the file where the logs have been stored and its size
+func (h *Handler) collectAgentLogs(collection *ent.AgentLogCollection) (string, int64, error) {
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return "", 0, errAgentLogCollectionOffline
+	}
+
+	agent, err := h.Model.GetAgentById(collection.AgentID, &partials.CommonInfo{TenantID: strconv.Itoa(collection.TenantID), SiteID: "-1"})
+	if err != nil {
+		return "", 0, err
+	}
+
+	data, err := json.Marshal(agentLogCollectionRequest{MaxSize: maxAgentLogCollectionSize})
+	if err != nil {
+		return "", 0, err
+	}
+
+	msg, err := h.natsRequest(context.Background(), agentLogCollectionSubject+agent.ID, data, agentLogCollectionRequestTimeout)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return "", 0, errAgentLogCollectionOffline
+		}
+		return "", 0, err
+	}
+
+	response := agentLogCollectionResponse{}
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return "", 0, fmt.Errorf("could not decode the response of the agent: %w", err)
+	}
+	if response.Error != "" {
+		return "", 0, errors.New(response.Error)
+	}
+	if response.File == "" {
+		return "", 0, errors.New("the agent hasn't sent the logs package")
+	}
+	if response.Size > maxAgentLogCollectionSize {
+		return "", 0, fmt.Errorf("the logs package is bigger than %d MB", maxAgentLogCollectionSize>>20)
+	}
+
+	key, err := utils.ReadPEMPrivateKey(h.SFTPKeyPath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	client, sshConn, err := dialSFTP(agent.IP, key, agent.SftpPort, agent.Os, agent.Edges.Netbird)
+	if err != nil {
+		return "", 0, err
+	}
+	defer client.Close()
+	defer sshConn.Close()
+
+	src, err := client.OpenFile(response.File, os.O_RDONLY)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	dir := agentLogCollectionsDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	dst, err := os.CreateTemp(dir, agent.ID+"-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The size sent by the agent isn't trusted, the package is cut one byte after the limit to detect it
+	size, err := io.Copy(dst, io.LimitReader(src, maxAgentLogCollectionSize+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > maxAgentLogCollectionSize {
+		err = fmt.Errorf("the logs package is bigger than %d MB", maxAgentLogCollectionSize>>20)
+	}
+	if err != nil {
+		removeAgentLogCollectionFile(filepath.Base(dst.Name()))
+		return "", 0, err
+	}
+
+	// The package is no longer needed by the agent
+	if err := client.Remove(response.File); err != nil {
+		log.Printf("[WARN]: could not remove the logs package %s from agent %s, reason: %v", response.File, agent.ID, err)
+	}
+
+	return filepath.Base(dst.Name()), size, nil
+}
+
+// StartAgentLogCollectionsJob starts the queued log collections when their agent comes online and
+// removes the collected logs that have expired
+func (h *Handler) StartAgentLogCollectionsJob() error {
+	var err error
+
+	h.Presence.OnChange(func(agentID string, online bool) {
+		if online {
+			h.startQueuedAgentLogCollections(func(id string) bool { return id == agentID })
+		}
+	})
+
+	h.LogCollectionsJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			agentLogCollectionsInterval,
+		),
+		gocron.NewTask(
+			func() {
+				h.removeExpiredAgentLogCollections()
+				h.startQueuedAgentLogCollections(h.Presence.IsOnline)
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the agent log collections job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// startQueuedAgentLogCollections starts the queued log collections of the agents that are online
+func (h *Handler) startQueuedAgentLogCollections(online func(agentID string) bool) {
+	collections, err := h.Model.GetQueuedAgentLogCollections()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the queued log collections, reason: %v", err)
+		return
+	}
+
+	for _, collection := range collections {
+		if online(collection.AgentID) {
+			h.startAgentLogCollection(collection)
+		}
+	}
+}
+
+// removeExpiredAgentLogCollections times out the collections of the agents that haven't been online and removes the expired logs
+func (h *Handler) removeExpiredAgentLogCollections() {
+	files, err := h.Model.ExpireAgentLogCollections(time.Now())
+	if err != nil {
+		log.Printf("[ERROR]: could not expire the agent log collections, reason: %v", err)
+		return
+	}
+
+	for _, f := range files {
+		removeAgentLogCollectionFile(f)
+	}
+}
+
+// agentLogCollectionsDir returns the folder where the collected logs are kept until they expire. The
+// download folder isn't used as its files are removed a few minutes after they're created
+func agentLogCollectionsDir() string {
+	return filepath.Join(os.TempDir(), "openuem-agent-logs")
+}
+
+// removeAgentLogCollectionFile removes the logs collected from an agent, they may have been collected by another replica
+func removeAgentLogCollectionFile(fileName string) {
+	if fileName == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(agentLogCollectionsDir(), filepath.Base(fileName))); err != nil && !os.IsNotExist(err) {
+		log.Printf("[ERROR]: could not remove the agent logs %s, reason: %v", fileName, err)
+	}
+}
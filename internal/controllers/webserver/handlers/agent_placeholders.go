@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// agentPlaceholdersInterval is how often the placeholders are checked for the first report of their agents
+const agentPlaceholdersInterval = 5 * time.Minute
+
+// StartAgentPlaceholdersJob completes the placeholders whose agents have reported and removes those whose
+// agents haven't reported within the retention
+func (h *Handler) StartAgentPlaceholdersJob() error {
+	var err error
+
+	h.AgentPlaceholdersJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			agentPlaceholdersInterval,
+		),
+		gocron.NewTask(
+			func() {
+				completed, err := h.Model.CompleteAgentPlaceholders()
+				if err != nil {
+					log.Printf("[ERROR]: could not complete the agent placeholders, reason: %v", err)
+				}
+				if completed > 0 {
+					log.Printf("[INFO]: %d agents have reported for the first time with a pre-generated UUID", completed)
+				}
+
+				purged, err := h.Model.PurgeAgentPlaceholders()
+				if err != nil {
+					log.Printf("[ERROR]: could not purge the agent placeholders, reason: %v", err)
+				}
+				if purged > 0 {
+					log.Printf("[INFO]: %d agent placeholders have been purged as their agents never reported", purged)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the agent placeholders job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	openuem_ent "github.com/open-uem/ent"
+)
+
+// agentPresenceTimeout is the time after the last heartbeat when an agent is considered offline
+const agentPresenceTimeout = 90 * time.Second
+
+// agentPresenceSweepInterval is how often heartbeats are saved and missed events are reconciled
+const agentPresenceSweepInterval = 30 * time.Second
+
+// subscribeAgentPresence listens to the agents heartbeats using the current NATS connection
+func (h *Handler) subscribeAgentPresence() {
+	if h.NATSConnection == nil {
+		return
+	}
+
+	if err := h.Presence.Subscribe(h.NATSConnection); err != nil {
+		log.Printf("[ERROR]: could not subscribe to agents heartbeats, reason: %v", err)
+		return
+	}
+	log.Println("[INFO]: subscribed to agents heartbeats")
+}
+
+// StartAgentPresenceJob saves the heartbeats received and reconciles the agents presence periodically
+func (h *Handler) StartAgentPresenceJob() error {
+	var err error
+
+	h.reconcileAgentPresence()
+
+	h.AgentPresenceJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			agentPresenceSweepInterval,
+		),
+		gocron.NewTask(
+			func() {
+				if err := h.Model.SaveAgentsLastSeen(h.Presence.Pending()); err != nil {
+					log.Printf("[ERROR]: could not save agents last seen date, reason: %v", err)
+				}
+				h.reconcileAgentPresence()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the agents presence job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// reconcileAgentPresence loads the heartbeats saved by other console replicas and
+// marks as offline the agents whose heartbeats have timed out
+func (h *Handler) reconcileAgentPresence() {
+	lastSeen, err := h.Model.GetAgentsLastSeenSince(time.Now().Add(-agentPresenceTimeout))
+	if err != nil {
+		log.Printf("[ERROR]: could not get agents last seen date, reason: %v", err)
+	} else {
+		h.Presence.Load(lastSeen)
+	}
+
+	h.Presence.Sweep(time.Now())
+}
+
+// onlineAgents returns which of the agents are online, to be shown in the agents list
+func (h *Handler) onlineAgents(agents []*openuem_ent.Agent) map[string]bool {
+	online := map[string]bool{}
+	for _, a := range agents {
+		online[a.ID] = h.Presence.IsOnline(a.ID)
+	}
+	return online
+}
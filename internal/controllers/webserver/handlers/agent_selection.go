@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/agents_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// agentSelectionSessionKey is the session key where the "select all matching" selection is stored
+const agentSelectionSessionKey = "agents-selection"
+
+// agentSelection stores the filter used to select all the matching agents instead of their IDs,
+// so bulk actions apply to every agent matching the filter at the time they are executed.
+// Agents manually unselected by the user are kept in the exclusion list
+type agentSelection struct {
+	TenantID string
+	SiteID   string
+	Filter   filters.AgentFilter
+	Excluded []string
+}
+
+// SelectAllMatchingAgents selects every agent matching the current filter, not only the ones in the visible page
+func (h *Handler) SelectAllMatchingAgents(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(c.Request().Header.Get("Hx-Current-Url"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_select_all", err.Error()), true))
+	}
+
+	f, err := h.getAgentFilterFromQuery(u.Query(), commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_select_all", err.Error()), true))
+	}
+
+	if err := h.saveAgentSelection(c, &agentSelection{TenantID: commonInfo.TenantID, SiteID: commonInfo.SiteID, Filter: f}); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_select_all", err.Error()), true))
+	}
+
+	return h.ListAgents(c, "", "", true)
+}
+
+// ToggleAgentInSelection adds or removes an agent from the exclusion list when all the matching agents are selected
+func (h *Handler) ToggleAgentInSelection(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	selection := h.getAgentSelection(c, commonInfo)
+	if selection == nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.selection_expired"), true))
+	}
+
+	agentID := c.Param("uuid")
+	if c.FormValue("selected") == "" {
+		if !slices.Contains(selection.Excluded, agentID) {
+			selection.Excluded = append(selection.Excluded, agentID)
+		}
+	} else {
+		selection.Excluded = slices.DeleteFunc(selection.Excluded, func(id string) bool { return id == agentID })
+	}
+
+	if err := h.saveAgentSelection(c, selection); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), true))
+	}
+
+	count, err := h.Model.CountAgentsBySelection(selection.Filter, selection.Excluded, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), true))
+	}
+
+	return RenderView(c, agents_views.AgentsSelectedCount(count, commonInfo))
+}
+
+// ClearAgentSelection removes the "select all matching" selection
+func (h *Handler) ClearAgentSelection(c echo.Context) error {
+	h.clearAgentSelection(c)
+	return h.ListAgents(c, "", "", true)
+}
+
+// getSelectedAgentIDs returns the agents a bulk action applies to. If all the matching agents are selected
+// the stored filter is resolved now, otherwise the IDs checked in the page are used
+func (h *Handler) getSelectedAgentIDs(c echo.Context, commonInfo *partials.CommonInfo) ([]string, error) {
+	if selection := h.getAgentSelection(c, commonInfo); selection != nil {
+		return h.Model.GetAgentIDsBySelection(selection.Filter, selection.Excluded, commonInfo)
+	}
+	return strings.Split(c.FormValue("agents"), ","), nil
+}
+
+func (h *Handler) getAgentSelection(c echo.Context, commonInfo *partials.CommonInfo) *agentSelection {
+	data := h.SessionManager.Manager.GetString(c.Request().Context(), agentSelectionSessionKey)
+	if data == "" {
+		return nil
+	}
+
+	selection := agentSelection{}
+	if err := json.Unmarshal([]byte(data), &selection); err != nil {
+		log.Printf("[ERROR]: could not decode the agents selection, reason: %v", err)
+		return nil
+	}
+
+	// The selection only applies to the tenant and site where it was made
+	if selection.TenantID != commonInfo.TenantID || selection.SiteID != commonInfo.SiteID {
+		return nil
+	}
+
+	return &selection
+}
+
+func (h *Handler) saveAgentSelection(c echo.Context, selection *agentSelection) error {
+	data, err := json.Marshal(selection)
+	if err != nil {
+		return err
+	}
+	h.SessionManager.Manager.Put(c.Request().Context(), agentSelectionSessionKey, string(data))
+	return nil
+}
+
+func (h *Handler) clearAgentSelection(c echo.Context) {
+	h.SessionManager.Manager.Remove(c.Request().Context(), agentSelectionSessionKey)
+}
+
+// getAgentFilterFromQuery reads the agents list filters from the query of the list's URL
+func (h *Handler) getAgentFilterFromQuery(q url.Values, commonInfo *partials.CommonInfo) (filters.AgentFilter, error) {
+	f := filters.AgentFilter{}
+
+	f.Nickname = q.Get("filterByNickname")
+
+	for index := range agents_views.AgentStatus {
+		value := q.Get(fmt.Sprintf("filterByStatusAgent%d", index))
+		if value != "" {
+			if value == "No Contact" {
+				f.NoContact = true
+			}
+			if value == "Late" {
+				f.Late = true
+			}
+			f.AgentStatusOptions = append(f.AgentStatusOptions, value)
+		}
+	}
+
+	availableOSes, err := h.Model.GetAgentsUsedOSes(commonInfo, f, false)
+	if err != nil {
+		return f, err
+	}
+	for index := range availableOSes {
+		value := q.Get(fmt.Sprintf("filterByAgentOS%d", index))
+		if value != "" {
+			f.AgentOSVersions = append(f.AgentOSVersions, value)
+		}
+	}
+
+	for index := range []string{"Remote", "Local"} {
+		value := q.Get(fmt.Sprintf("filterByIsRemote%d", index))
+		if value != "" {
+			f.IsRemote = append(f.IsRemote, value)
+		}
+	}
+
+	f.ContactFrom = q.Get("filterByContactDateFrom")
+	f.ContactTo = q.Get("filterByContactDateTo")
+
+	appliedTags, err := h.Model.GetAppliedTags(commonInfo)
+	if err != nil {
+		return f, err
+	}
+	for _, tag := range appliedTags {
+		if q.Get(fmt.Sprintf("filterByTag%d", tag.ID)) != "" {
+			f.Tags = append(f.Tags, tag.ID)
+		}
+	}
+
+	return f, nil
+}
+
+// sameAgentSelectionFilter returns true if both filters select the same agents
+func sameAgentSelectionFilter(a, b filters.AgentFilter) bool {
+	return a.Nickname == b.Nickname &&
+		slices.Equal(a.AgentStatusOptions, b.AgentStatusOptions) &&
+		slices.Equal(a.AgentOSVersions, b.AgentOSVersions) &&
+		slices.Equal(a.IsRemote, b.IsRemote) &&
+		slices.Equal(a.Tags, b.Tags) &&
+		a.ContactFrom == b.ContactFrom &&
+		a.ContactTo == b.ContactTo
+}
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/nats-io/nats.go"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentsettingsprofileassignment"
+	"github.com/open-uem/ent/agentsettingsprofilestatus"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// agentSettingsProfileSubject is the subject where the agent applies a settings profile, followed by the agent's ID
+const agentSettingsProfileSubject = "agent.settingsprofile."
+
+// agentSettingsProfileTimeout is how long the agent has to apply a settings profile
+const agentSettingsProfileTimeout = 30 * time.Second
+
+// agentSettingsProfilesInterval is how often the pending profiles are pushed to the online agents
+const agentSettingsProfilesInterval = 5 * time.Minute
+
+// agentSettingsProfileRequest are the settings of the profile sent to the agent, which writes them in its openuem.ini
+type agentSettingsProfileRequest struct {
+	ProfileID        int    `json:"profile_id"`
+	Revision         int    `json:"revision"`
+	Frequency        int    `json:"frequency"`
+	DebugMode        bool   `json:"debug_mode"`
+	SFTPService      bool   `json:"sftp_service"`
+	SFTPPort         string `json:"sftp_port"`
+	VNCProxyPort     string `json:"vnc_proxy_port"`
+	RemoteAssistance bool   `json:"remote_assistance"`
+}
+
+// agentSettingsProfileResponse tells if the agent has applied the profile
+type agentSettingsProfileResponse struct {
+	Error string `json:"error"`
+}
+
+// SettingsProfiles shows the settings profiles of the tenant, where they're assigned, the profile applied by
+// each agent and the agents whose settings differ from their profile
+func (h *Handler) SettingsProfiles(c echo.Context) error {
+	return h.renderSettingsProfiles(c, "")
+}
+
+// SaveSettingsProfile creates a settings profile or saves the changes of a profile, which is pushed again to its agents
+func (h *Handler) SaveSettingsProfile(c echo.Context) error {
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	v, errKey := settingsProfileValues(c)
+	if errKey != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), errKey), true))
+	}
+
+	var p *openuem_ent.AgentSettingsProfile
+	if id := c.FormValue("profile-id"); id != "" {
+		profileID, err := strconv.Atoi(id)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_profile"), true))
+		}
+		p, err = h.Model.UpdateSettingsProfile(profileID, tenantID, v)
+		if err != nil {
+			return h.settingsProfileError(c, err)
+		}
+		h.auditTenantData(c, "has changed the agent settings profile %s of tenant %d, revision %d", p.Name, tenantID, p.Revision)
+	} else {
+		p, err = h.Model.CreateSettingsProfile(tenantID, v)
+		if err != nil {
+			return h.settingsProfileError(c, err)
+		}
+		h.auditTenantData(c, "has created the agent settings profile %s of tenant %d", p.Name, tenantID)
+	}
+
+	h.startAgentSettingsProfilesPush(tenantID)
+	return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.saved"))
+}
+
+// DeleteSettingsProfile removes a settings profile, its agents keep the settings they have applied
+func (h *Handler) DeleteSettingsProfile(c echo.Context) error {
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_profile"), true))
+	}
+
+	p, err := h.Model.GetSettingsProfile(id, tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	if err := h.Model.DeleteSettingsProfile(id, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has deleted the agent settings profile %s of tenant %d", p.Name, tenantID)
+
+	// The agents of the profile may have another profile now
+	h.startAgentSettingsProfilesPush(tenantID)
+	return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.deleted"))
+}
+
+// AssignSettingsProfile assigns a profile to a site, tag or agent, replacing the profile assigned to it
+func (h *Handler) AssignSettingsProfile(c echo.Context) error {
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	profileID, err := strconv.Atoi(c.FormValue("profile"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_profile"), true))
+	}
+
+	target := agentsettingsprofileassignment.TargetType(c.FormValue("target-type"))
+	if err := agentsettingsprofileassignment.TargetTypeValidator(target); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_target"), true))
+	}
+
+	targetID := strings.TrimSpace(c.FormValue("target-" + string(target)))
+	if targetID == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_target"), true))
+	}
+
+	if err := h.Model.AssignSettingsProfile(profileID, tenantID, target, targetID); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_target"), true))
+		}
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has assigned the agent settings profile %d of tenant %d to %s %s", profileID, tenantID, target, targetID)
+
+	h.startAgentSettingsProfilesPush(tenantID)
+	return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.assigned"))
+}
+
+// UnassignSettingsProfile removes an assignment of a profile
+func (h *Handler) UnassignSettingsProfile(c echo.Context) error {
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.invalid_target"), true))
+	}
+
+	if err := h.Model.UnassignSettingsProfile(id, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has removed the assignment %d of an agent settings profile of tenant %d", id, tenantID)
+
+	h.startAgentSettingsProfilesPush(tenantID)
+	return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.unassigned"))
+}
+
+// PushSettingsProfile pushes the effective profile to an agent again, e.g. after it has failed or drifted
+func (h *Handler) PushSettingsProfile(c echo.Context) error {
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	state, err := h.Model.GetAgentSettingsProfileState(c.Param("uuid"), tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	if state.Profile() == nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.no_profile"), true))
+	}
+
+	if !h.Presence.IsOnline(state.Agent.ID) {
+		if err := h.Model.SetAgentSettingsProfileStatus(state.Agent.ID, tenantID, state.Profile(), agentsettingsprofilestatus.StatusPending, ""); err != nil {
+			return RenderModelError(c, err)
+		}
+		return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.push_queued"))
+	}
+
+	if err := h.pushAgentSettingsProfile(tenantID, state); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.push_failed", err.Error()), true))
+	}
+	h.auditTenantData(c, "has pushed the agent settings profile %s to agent %s (%s) of tenant %d", state.Profile().Name, state.Agent.ID, state.Agent.Hostname, tenantID)
+
+	return h.renderSettingsProfiles(c, i18n.T(c.Request().Context(), "settings_profiles.pushed"))
+}
+
+func (h *Handler) renderSettingsProfiles(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.settingsProfilesTenant(c)
+	if err != nil {
+		return err
+	}
+
+	profiles, err := h.Model.GetSettingsProfiles(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	assignments, err := h.Model.GetSettingsProfileAssignments(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	states, err := h.Model.GetAgentSettingsProfileStates(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	tags, err := h.Model.GetTagsForTenant(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	var edit *openuem_ent.AgentSettingsProfile
+	if id, err := strconv.Atoi(c.QueryParam("edit")); err == nil {
+		edit, _ = h.Model.GetSettingsProfile(id, tenantID)
+	}
+
+	return RenderView(c, admin_views.SettingsProfilesIndex(" | Agent Settings Profiles",
+		admin_views.SettingsProfiles(c, profiles, edit, assignments, states, sites, tags, successMessage, agentsExists, serversExists, commonInfo),
+		commonInfo))
+}
+
+func (h *Handler) settingsProfilesTenant(c echo.Context) (int, error) {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return 0, err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+	}
+	return tenantID, nil
+}
+
+func (h *Handler) settingsProfileError(c echo.Context, err error) error {
+	if errors.Is(err, models.ErrAlreadyExists) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings_profiles.name_exists"), true))
+	}
+	return RenderModelError(c, err)
+}
+
+// settingsProfileValues reads the profile editor, it returns the key of the message if a value isn't valid
+func settingsProfileValues(c echo.Context) (models.SettingsProfileValues, string) {
+	v := models.SettingsProfileValues{
+		Name:             strings.TrimSpace(c.FormValue("name")),
+		Description:      strings.TrimSpace(c.FormValue("description")),
+		DebugMode:        c.FormValue("debug-mode") != "",
+		SFTPService:      c.FormValue("sftp-service") != "",
+		SFTPPort:         strings.TrimSpace(c.FormValue("sftp-port")),
+		VNCProxyPort:     strings.TrimSpace(c.FormValue("vnc-proxy-port")),
+		RemoteAssistance: c.FormValue("remote-assistance") != "",
+	}
+
+	if v.Name == "" {
+		return v, "settings_profiles.name_empty"
+	}
+
+	frequency, err := strconv.Atoi(c.FormValue("frequency"))
+	if err != nil || frequency <= 0 {
+		return v, "settings.agent_frequency_invalid"
+	}
+	v.Frequency = frequency
+
+	priority := c.FormValue("priority")
+	if priority != "" {
+		v.Priority, err = strconv.Atoi(priority)
+		if err != nil {
+			return v, "settings_profiles.priority_invalid"
+		}
+	}
+
+	for _, port := range []string{v.SFTPPort, v.VNCProxyPort} {
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return v, "agents.port_must_be_a_number"
+		}
+		if portNumber <= 0 || portNumber > 65535 {
+			return v, "agents.port_is_not_valid"
+		}
+	}
+
+	return v, ""
+}
+
+// enrollmentSettingsProfile returns the profile of the site where the agents enrolled with the token are
+// placed, the config of the agents uses the defaults of the agent if it can't be found
+func (h *Handler) enrollmentSettingsProfile(token *openuem_ent.EnrollmentToken) *openuem_ent.AgentSettingsProfile {
+	if token.Edges.Tenant == nil {
+		return nil
+	}
+
+	var siteID *int
+	if token.Edges.Site != nil {
+		siteID = &token.Edges.Site.ID
+	}
+
+	p, err := h.Model.GetEnrollmentSettingsProfile(token.Edges.Tenant.ID, siteID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the settings profile of the enrollment token %d, reason: %v", token.ID, err)
+		return nil
+	}
+	return p
+}
+
+// StartAgentSettingsProfilesJob pushes the pending profiles when their agent comes online and periodically,
+// in case an agent was online but the push failed
+func (h *Handler) StartAgentSettingsProfilesJob() error {
+	var err error
+
+	h.Presence.OnChange(func(agentID string, online bool) {
+		if online {
+			h.pushPendingSettingsProfile(agentID)
+		}
+	})
+
+	h.SettingsProfilesJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			agentSettingsProfilesInterval,
+		),
+		gocron.NewTask(
+			func() {
+				tenants, err := h.Model.GetTenants()
+				if err != nil {
+					log.Printf("[ERROR]: could not get the tenants to push the agent settings profiles, reason: %v", err)
+					return
+				}
+				for _, t := range tenants {
+					h.pushAgentSettingsProfiles(t.ID)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the agent settings profiles job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// startAgentSettingsProfilesPush pushes the profiles in a background job so the request doesn't wait for the agents
+func (h *Handler) startAgentSettingsProfilesPush(tenantID int) {
+	_, err := h.TaskScheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+		gocron.NewTask(func() {
+			h.pushAgentSettingsProfiles(tenantID)
+		}),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the push of the agent settings profiles of tenant %d, reason: %v", tenantID, err)
+	}
+}
+
+// pushAgentSettingsProfiles pushes the profiles that haven't been applied to the online agents of the tenant
+func (h *Handler) pushAgentSettingsProfiles(tenantID int) {
+	states, err := h.Model.GetAgentSettingsProfileStates(tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the agent settings profiles of tenant %d, reason: %v", tenantID, err)
+		return
+	}
+
+	for _, state := range states {
+		if !state.NeedsPush() {
+			continue
+		}
+		if !h.Presence.IsOnline(state.Agent.ID) {
+			if state.Status == nil || state.Status.Status != agentsettingsprofilestatus.StatusPending {
+				if err := h.Model.SetAgentSettingsProfileStatus(state.Agent.ID, tenantID, state.Profile(), agentsettingsprofilestatus.StatusPending, ""); err != nil {
+					log.Printf("[ERROR]: could not save the settings profile status of agent %s, reason: %v", state.Agent.ID, err)
+				}
+			}
+			continue
+		}
+		if err := h.pushAgentSettingsProfile(tenantID, state); err != nil {
+			log.Printf("[ERROR]: could not push the settings profile %s to agent %s, reason: %v", state.Profile().Name, state.Agent.ID, err)
+		}
+	}
+}
+
+// pushPendingSettingsProfile pushes the profile of an agent that has come online if it hasn't applied it
+func (h *Handler) pushPendingSettingsProfile(agentID string) {
+	tenantID, err := h.Model.GetAgentTenantID(agentID)
+	if err != nil {
+		return
+	}
+
+	state, err := h.Model.GetAgentSettingsProfileState(agentID, tenantID)
+	if err != nil || !state.NeedsPush() {
+		return
+	}
+
+	if err := h.pushAgentSettingsProfile(tenantID, state); err != nil {
+		log.Printf("[ERROR]: could not push the settings profile %s to agent %s, reason: %v", state.Profile().Name, agentID, err)
+	}
+}
+
+// pushAgentSettingsProfile sends the effective profile to the agent and records whether the agent has applied
+// it. The profile stays pending if the agent doesn't answer
+func (h *Handler) pushAgentSettingsProfile(tenantID int, state models.AgentSettingsProfileState) error {
+	p := state.Profile()
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return errors.New("NATS is not connected")
+	}
+
+	data, err := json.Marshal(agentSettingsProfileRequest{
+		ProfileID:        p.ID,
+		Revision:         p.Revision,
+		Frequency:        p.Frequency,
+		DebugMode:        p.DebugMode,
+		SFTPService:      p.SftpService,
+		SFTPPort:         p.SftpPort,
+		VNCProxyPort:     p.VncProxyPort,
+		RemoteAssistance: p.RemoteAssistance,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := h.natsRequest(context.Background(), agentSettingsProfileSubject+state.Agent.ID, data, agentSettingsProfileTimeout)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) || errors.Is(err, nats.ErrTimeout) {
+			return h.Model.SetAgentSettingsProfileStatus(state.Agent.ID, tenantID, p, agentsettingsprofilestatus.StatusPending, "")
+		}
+		return err
+	}
+
+	response := agentSettingsProfileResponse{}
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		response.Error = "could not decode the response of the agent: " + err.Error()
+	}
+	if response.Error != "" {
+		if err := h.Model.SetAgentSettingsProfileStatus(state.Agent.ID, tenantID, p, agentsettingsprofilestatus.StatusFailed, response.Error); err != nil {
+			return err
+		}
+		return errors.New(response.Error)
+	}
+
+	if err := h.Model.SaveAppliedSettingsProfile(state.Agent.ID, tenantID, p); err != nil {
+		return err
+	}
+	log.Printf("[INFO]: agent %s has applied revision %d of the settings profile %s", state.Agent.ID, p.Revision, p.Name)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/open-uem/ent"
 	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/agents_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -80,6 +82,9 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 					if value == "No Contact" {
 						f.NoContact = true
 					}
+					if value == "Late" {
+						f.Late = true
+					}
 					filteredAgentStatusOptions = append(filteredAgentStatusOptions, value)
 				}
 			}
@@ -89,6 +94,9 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 				if value == "No Contact" {
 					f.NoContact = true
 				}
+				if value == "Late" {
+					f.Late = true
+				}
 				filteredAgentStatusOptions = append(filteredAgentStatusOptions, value)
 			}
 		}
@@ -120,9 +128,19 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 
 	filteredIsRemote := []string{}
 	for index := range []string{"Remote", "Local"} {
-		value := c.FormValue(fmt.Sprintf("filterByIsRemote%d", index))
-		if value != "" {
-			filteredIsRemote = append(filteredIsRemote, value)
+		if comesFromDialog {
+			u, err := url.Parse(c.Request().Header.Get("Hx-Current-Url"))
+			if err == nil {
+				value := u.Query().Get(fmt.Sprintf("filterByIsRemote%d", index))
+				if value != "" {
+					filteredIsRemote = append(filteredIsRemote, value)
+				}
+			}
+		} else {
+			value := c.FormValue(fmt.Sprintf("filterByIsRemote%d", index))
+			if value != "" {
+				filteredIsRemote = append(filteredIsRemote, value)
+			}
 		}
 	}
 	f.IsRemote = filteredIsRemote
@@ -181,6 +199,12 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 
 	tagId := c.FormValue("tagId")
 	agentId := c.FormValue("agentId")
+	if (c.Request().Method == "POST" || c.Request().Method == "DELETE") && tagId != "" && agentId != "" {
+		if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+			return err
+		}
+	}
+
 	if c.Request().Method == "POST" && tagId != "" && agentId != "" {
 		err := h.Model.AddTagToAgent(agentId, tagId, commonInfo)
 		if err != nil {
@@ -195,6 +219,11 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 		}
 	}
 
+	// The cards of the list for small screens are loaded by cursor as they're scrolled into view
+	if c.Request().Method == "GET" && c.QueryParams().Has("cursor") {
+		return h.renderAgentCards(c, c.QueryParam("cursor"), p.PageSize, f, requestListFilters(c), commonInfo)
+	}
+
 	if comesFromDialog {
 		u, err := url.Parse(c.Request().Header.Get("Hx-Current-Url"))
 		if err == nil {
@@ -212,24 +241,34 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 		}
 	}
 
-	tmpAllAgents := []string{}
-	allAgents, err := h.Model.GetAllAgents(f, commonInfo)
+	if selection := h.getAgentSelection(c, commonInfo); selection != nil {
+		if sameAgentSelectionFilter(selection.Filter, f) {
+			f.AllMatchingSelected = true
+			f.ExcludedAgents = selection.Excluded
+			f.SelectedItems, err = h.Model.CountAgentsBySelection(selection.Filter, selection.Excluded, commonInfo)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			}
+		} else {
+			// The filter has changed so the selection no longer matches the agents shown
+			h.clearAgentSelection(c)
+		}
+	}
+
+	p.NItems, err = h.Model.CountAllAgents(f, false, commonInfo)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
-	for _, a := range allAgents {
-		tmpAllAgents = append(tmpAllAgents, "\""+a.ID+"\"")
-	}
-	f.SelectedAllAgents = "[" + strings.Join(tmpAllAgents, ",") + "]"
+	p.ClampCurrentPage()
 
 	agents, err = h.Model.GetAgentsByPage(p, f, false, commonInfo)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
-	p.NItems, err = h.Model.CountAllAgents(f, false, commonInfo)
-	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	// After a dialog the URL the browser was showing is replaced instead
+	if !comesFromDialog {
+		pushListState(c, partials.GetNavigationUrl(commonInfo, "/agents"), p, requestListFilters(c))
 	}
 
 	refreshTime, err := h.Model.GetDefaultRefreshTime()
@@ -243,6 +282,11 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.could_not_get_sftp_general_setting"), true))
 	}
 
+	reportStatus, err := h.Model.GetAgentsReportStatus(commonInfo, time.Now())
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
 	if comesFromDialog {
 		currentUrl := c.Request().Header.Get("Hx-Current-Url")
 		if currentUrl != "" {
@@ -251,15 +295,97 @@ func (h *Handler) ListAgents(c echo.Context, successMessage, errMessage string,
 				q.Del("page")
 				q.Add("page", "1")
 				u.RawQuery = q.Encode()
-				return RenderViewWithReplaceUrl(c, agents_views.AgentsIndex("| Agents", agents_views.Agents(c, p, f, agents, availableTags, appliedTags, availableOSes, sftpDisabled, successMessage, errMessage, refreshTime, itemsPerPage, commonInfo), commonInfo), u)
+				cardsURL := agentCardsURL(commonInfo, listFilters(q), "")
+				return RenderViewWithReplaceUrl(c, agents_views.AgentsIndex("| Agents", agents_views.Agents(c, p, f, agents, h.onlineAgents(agents), reportStatus, availableTags, appliedTags, availableOSes, sftpDisabled, successMessage, errMessage, refreshTime, itemsPerPage, cardsURL, commonInfo), commonInfo), u)
 			}
 		}
 	}
 
-	return RenderView(c, agents_views.AgentsIndex("| Agents", agents_views.Agents(c, p, f, agents, availableTags, appliedTags, availableOSes, sftpDisabled, successMessage, errMessage, refreshTime, itemsPerPage, commonInfo), commonInfo))
+	cardsURL := agentCardsURL(commonInfo, requestListFilters(c), "")
+	return RenderView(c, agents_views.AgentsIndex("| Agents", agents_views.Agents(c, p, f, agents, h.onlineAgents(agents), reportStatus, availableTags, appliedTags, availableOSes, sftpDisabled, successMessage, errMessage, refreshTime, itemsPerPage, cardsURL, commonInfo), commonInfo))
+}
+
+// renderAgentCards renders the next cards of the agent list for small screens and, if there may be more
+// agents, the row that loads them when it's revealed
+func (h *Handler) renderAgentCards(c echo.Context, cursor string, pageSize int, f filters.AgentFilter, values url.Values, commonInfo *partials.CommonInfo) error {
+	agents, err := h.Model.GetAgentsAfter(cursor, pageSize, f, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	reportStatus, err := h.Model.GetAgentsReportStatus(commonInfo, time.Now())
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	nextURL := ""
+	if len(agents) == pageSize {
+		nextURL = agentCardsURL(commonInfo, values, agents[len(agents)-1].ID)
+	}
+
+	return RenderView(c, agents_views.AgentCards(agents, h.onlineAgents(agents), reportStatus, nextURL, commonInfo))
+}
+
+// agentCardsURL returns the URL that loads the cards of the agents after the cursor with the filters of the list
+func agentCardsURL(commonInfo *partials.CommonInfo, values url.Values, cursor string) string {
+	query := url.Values{}
+	for name, v := range values {
+		query[name] = v
+	}
+	query.Set("cursor", cursor)
+	return partials.GetNavigationUrl(commonInfo, "/agents") + "?" + query.Encode()
+}
+
+// FilterAgentsByHardware renders the rows of the agents with at least the CPU cores
+// and the RAM in GB given in the minCPUCores and minRAMGB query params
+func (h *Handler) FilterAgentsByHardware(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	minimums := map[string]int{"minCPUCores": 0, "minRAMGB": 0}
+	for param := range minimums {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		minimums[param], err = strconv.Atoi(value)
+		if err != nil || minimums[param] < 0 {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.invalid_hardware_filter", param), true))
+		}
+	}
+
+	agents, err := h.Model.GetAgentsByHardware(commonInfo, minimums["minCPUCores"], minimums["minRAMGB"])
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	availableTags, err := h.Model.GetAllTags(commonInfo, filters.AgentFilter{})
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	sftpDisabled, err := h.Model.GetDefaultSFTPDisabled(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.could_not_get_sftp_general_setting"), true))
+	}
+
+	reportStatus, err := h.Model.GetAgentsReportStatus(commonInfo, time.Now())
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	p := partials.NewPaginationAndSort(len(agents))
+	p.NItems = len(agents)
+	return RenderView(c, agents_views.AgentsTableBody(p, filters.AgentFilter{}, agents, h.onlineAgents(agents), reportStatus, availableTags, sftpDisabled, commonInfo))
 }
 
 func (h *Handler) AgentDelete(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	var err error
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -281,6 +407,10 @@ func (h *Handler) AgentDelete(c echo.Context) error {
 }
 
 func (h *Handler) AgentConfirmDelete(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -300,7 +430,7 @@ func (h *Handler) AgentConfirmDelete(c echo.Context) error {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		if _, err := h.JetStream.Publish(ctx, "agent.uninstall."+agentId, nil); err != nil {
+		if _, err := h.jetStreamPublish(ctx, "agent.uninstall."+agentId, nil); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_send_request_to_uninstall"), true))
 		}
 	}
@@ -316,6 +446,10 @@ func (h *Handler) AgentConfirmDelete(c echo.Context) error {
 }
 
 func (h *Handler) AgentEnable(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -333,7 +467,7 @@ func (h *Handler) AgentEnable(c echo.Context) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if _, err := h.JetStream.Publish(ctx, "agent.enable."+agentId, nil); err != nil {
+	if _, err := h.jetStreamPublish(ctx, "agent.enable."+agentId, nil); err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
@@ -345,6 +479,10 @@ func (h *Handler) AgentEnable(c echo.Context) error {
 }
 
 func (h *Handler) AgentDisable(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	var err error
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -362,6 +500,10 @@ func (h *Handler) AgentDisable(c echo.Context) error {
 }
 
 func (h *Handler) AgentsAdmit(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	errorsFound := false
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -370,9 +512,12 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
-		agents := c.FormValue("agents")
+		agentIDs, err := h.getSelectedAgentIDs(c, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), false))
+		}
 
-		for agentId := range strings.SplitSeq(agents, ",") {
+		for _, agentId := range agentIDs {
 
 			agent, err := h.Model.GetAgentById(agentId, commonInfo)
 			if err != nil {
@@ -389,8 +534,19 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 					continue
 				}
 
+				// The agent may be moved to the site of its pre-staged device or of its network, whose domain
+				// is used in the certificate
+				prestaged := h.preStagedDevice(agent, commonInfo)
+				newSite := preStagedSite(agent, prestaged)
+				if newSite == nil {
+					newSite = h.admissionSite(agent, commonInfo)
+				}
 				domain := h.Domain
-				if len(agent.Edges.Site) == 1 && agent.Edges.Site[0].Domain != "" {
+				if newSite != nil {
+					if newSite.Domain != "" {
+						domain = newSite.Domain
+					}
+				} else if len(agent.Edges.Site) == 1 && agent.Edges.Site[0].Domain != "" {
 					domain = agent.Edges.Site[0].Domain
 				}
 
@@ -417,7 +573,7 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 					continue
 				}
 
-				if err := h.NATSConnection.Publish("certificates.agent."+agentId, data); err != nil {
+				if err := h.natsPublish(c.Request().Context(), "certificates.agent."+agentId, data); err != nil {
 					log.Println("[ERROR]: ", i18n.T(c.Request().Context(), "nats.no_responder"))
 					errorsFound = true
 					continue
@@ -443,6 +599,14 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 					}
 				}
 
+				if prestaged != nil {
+					h.applyPreStagedDevice(agent, prestaged)
+				}
+
+				if newSite != nil {
+					h.moveAdmittedAgent(agent, newSite, commonInfo)
+				}
+
 			} else {
 				log.Printf("[ERROR]: agent %s is not in a valid state\n", agentId)
 				errorsFound = true
@@ -450,6 +614,8 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 			}
 		}
 
+		h.clearAgentSelection(c)
+
 		if errorsFound {
 			return h.ListAgents(c, "", i18n.T(c.Request().Context(), "agents.some_could_not_be_admitted"), true)
 		}
@@ -460,6 +626,10 @@ func (h *Handler) AgentsAdmit(c echo.Context) error {
 }
 
 func (h *Handler) AgentsEnable(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	errorsFound := false
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -468,9 +638,12 @@ func (h *Handler) AgentsEnable(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
-		agents := c.FormValue("agents")
+		agentIDs, err := h.getSelectedAgentIDs(c, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), false))
+		}
 
-		for agentId := range strings.SplitSeq(agents, ",") {
+		for _, agentId := range agentIDs {
 			agent, err := h.Model.GetAgentById(agentId, commonInfo)
 			if err != nil {
 				log.Println("[ERROR]: ", err.Error())
@@ -487,7 +660,7 @@ func (h *Handler) AgentsEnable(c echo.Context) error {
 
 				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 				defer cancel()
-				if _, err := h.JetStream.Publish(ctx, "agent.enable."+agentId, nil); err != nil {
+				if _, err := h.jetStreamPublish(ctx, "agent.enable."+agentId, nil); err != nil {
 					log.Println("[ERROR]: ", err.Error())
 					errorsFound = true
 					continue
@@ -504,6 +677,8 @@ func (h *Handler) AgentsEnable(c echo.Context) error {
 				continue
 			}
 		}
+		h.clearAgentSelection(c)
+
 		if errorsFound {
 			return h.ListAgents(c, "", i18n.T(c.Request().Context(), "agents.some_could_not_be_enabled"), true)
 		}
@@ -514,6 +689,10 @@ func (h *Handler) AgentsEnable(c echo.Context) error {
 }
 
 func (h *Handler) AgentsDisable(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	errorsFound := false
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -523,9 +702,12 @@ func (h *Handler) AgentsDisable(c echo.Context) error {
 
 	if c.Request().Method == "POST" {
 
-		agents := c.FormValue("agents")
+		agentIDs, err := h.getSelectedAgentIDs(c, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), false))
+		}
 
-		for agentId := range strings.SplitSeq(agents, ",") {
+		for _, agentId := range agentIDs {
 			agent, err := h.Model.GetAgentById(agentId, commonInfo)
 			if err != nil {
 				log.Println("[ERROR]: ", err.Error())
@@ -540,7 +722,7 @@ func (h *Handler) AgentsDisable(c echo.Context) error {
 
 				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 				defer cancel()
-				if _, err := h.JetStream.Publish(ctx, "agent.disable."+agentId, nil); err != nil {
+				if _, err := h.jetStreamPublish(ctx, "agent.disable."+agentId, nil); err != nil {
 					return RenderError(c, partials.ErrorMessage(err.Error(), false))
 				}
 
@@ -553,6 +735,8 @@ func (h *Handler) AgentsDisable(c echo.Context) error {
 				continue
 			}
 		}
+		h.clearAgentSelection(c)
+
 		if errorsFound {
 			return h.ListAgents(c, "", i18n.T(c.Request().Context(), "agents.some_could_not_be_disabled"), true)
 		}
@@ -563,7 +747,89 @@ func (h *Handler) AgentsDisable(c echo.Context) error {
 	return RenderConfirm(c, partials.ConfirmDisableAgents(c, commonInfo))
 }
 
+// AgentsDelete moves the selected agents to the recycle bin. The number of agents and of inventory rows
+// affected is shown first and must be typed to delete them
+func (h *Handler) AgentsDelete(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	selected, err := h.getSelectedAgentIDs(c, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_selection", err.Error()), false))
+	}
+
+	// Only the agents of the tenant, or of the site, can be deleted
+	agentIDs := []string{}
+	for _, agentId := range selected {
+		if agentId == "" {
+			continue
+		}
+		if _, err := h.Model.GetAgentById(agentId, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not get agent %s to delete it, reason: %v", agentId, err)
+			continue
+		}
+		agentIDs = append(agentIDs, agentId)
+	}
+
+	if len(agentIDs) == 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.none_selected"), false))
+	}
+
+	scope := dangerousActionScope{Action: "delete-agents", Target: commonInfo.TenantID + "/" + commonInfo.SiteID, Items: agentIDs}
+	acknowledgement := strconv.Itoa(len(agentIDs))
+
+	if c.Request().Method == http.MethodPost {
+		if err := h.checkDangerousAction(c, scope, acknowledgement); err != nil {
+			return RenderError(c, partials.ErrorMessage(dangerousActionErrorMessage(c, err), false))
+		}
+
+		count, err := h.Model.DeleteAgents(agentIDs, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		h.clearAgentSelection(c)
+
+		h.auditTenantData(c, "has moved %d agents of tenant %s to the recycle bin", count, commonInfo.TenantID)
+
+		return h.ListAgents(c, i18n.T(c.Request().Context(), "agents.have_been_deleted", count), "", true)
+	}
+
+	inventory, err := h.Model.CountAgentsInventory(agentIDs)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	token, err := h.newDangerousAction(c, scope)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(dangerousActionErrorMessage(c, err), false))
+	}
+
+	return RenderConfirm(c, partials.ConfirmDangerousAction(c, partials.DangerousAction{
+		Title: i18n.T(c.Request().Context(), "agents.delete_selected_title"),
+		Consequences: []string{
+			i18n.T(c.Request().Context(), "agents.delete_selected_consequence", len(agentIDs), inventory),
+			i18n.T(c.Request().Context(), "agents.delete_selected_not_uninstalled"),
+		},
+		Acknowledgement: acknowledgement,
+		Token:           token,
+		Method:          http.MethodPost,
+		URL:             partials.GetNavigationUrl(commonInfo, "/agents/delete"),
+		CancelURL:       partials.GetNavigationUrl(commonInfo, "/agents"),
+		SelectedAgents:  true,
+	}))
+}
+
 func (h *Handler) AgentAdmit(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	var err error
 
 	commonInfo, err := h.GetCommonInfo(c)
@@ -577,10 +843,23 @@ func (h *Handler) AgentAdmit(c echo.Context) error {
 		return h.ListAgents(c, "", err.Error(), true)
 	}
 
-	return RenderView(c, agents_views.AgentsIndex(" | Agents", agents_views.AgentConfirmAdmission(c, agent, commonInfo), commonInfo))
+	// The agent can be moved to the other sites allowed by its enrollment token before it's admitted
+	sites := []*ent.Site{}
+	if tenantID, err := strconv.Atoi(commonInfo.TenantID); err == nil && tenantID != -1 {
+		sites, err = h.Model.GetAgentEnrollmentSites(agentId, tenantID)
+		if err != nil {
+			log.Printf("[ERROR]: could not get the enrollment sites of agent %s, reason: %v", agentId, err)
+		}
+	}
+
+	return RenderView(c, agents_views.AgentsIndex(" | Agents", agents_views.AgentConfirmAdmission(c, agent, sites, commonInfo), commonInfo))
 }
 
 func (h *Handler) AgentForceRun(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 
 	go func() {
@@ -590,7 +869,7 @@ func (h *Handler) AgentForceRun(c echo.Context) error {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		if _, err := h.JetStream.Publish(ctx, "agent.report."+agentId, nil); err != nil {
+		if _, err := h.jetStreamPublish(ctx, "agent.report."+agentId, nil); err != nil {
 			log.Printf("[ERROR]: %v", err)
 		}
 	}()
@@ -599,6 +878,10 @@ func (h *Handler) AgentForceRun(c echo.Context) error {
 }
 
 func (h *Handler) AgentConfirmDisable(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -612,7 +895,7 @@ func (h *Handler) AgentConfirmDisable(c echo.Context) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if _, err := h.JetStream.Publish(ctx, "agent.disable."+agentId, nil); err != nil {
+	if _, err := h.jetStreamPublish(ctx, "agent.disable."+agentId, nil); err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
@@ -624,6 +907,10 @@ func (h *Handler) AgentConfirmDisable(c echo.Context) error {
 }
 
 func (h *Handler) AgentConfirmAdmission(c echo.Context, regenerate bool) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -639,8 +926,23 @@ func (h *Handler) AgentConfirmAdmission(c echo.Context, regenerate bool) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
+	// An agent being admitted may be moved to the site of its pre-staged device or of its network, whose
+	// domain is used in the certificate
+	var prestaged *ent.PreStagedDevice
+	var newSite *ent.Site
+	if !regenerate {
+		prestaged = h.preStagedDevice(agent, commonInfo)
+		newSite = preStagedSite(agent, prestaged)
+		if newSite == nil {
+			newSite = h.admissionSite(agent, commonInfo)
+		}
+	}
 	domain := h.Domain
-	if len(agent.Edges.Site) == 1 && agent.Edges.Site[0].Domain != "" {
+	if newSite != nil {
+		if newSite.Domain != "" {
+			domain = newSite.Domain
+		}
+	} else if len(agent.Edges.Site) == 1 && agent.Edges.Site[0].Domain != "" {
 		domain = agent.Edges.Site[0].Domain
 	}
 
@@ -663,7 +965,7 @@ func (h *Handler) AgentConfirmAdmission(c echo.Context, regenerate bool) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	if err := h.NATSConnection.Publish("certificates.agent."+agentId, data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "certificates.agent."+agentId, data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.no_responder"), false))
 	}
 
@@ -685,6 +987,14 @@ func (h *Handler) AgentConfirmAdmission(c echo.Context, regenerate bool) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
+	if prestaged != nil {
+		h.applyPreStagedDevice(agent, prestaged)
+	}
+
+	if newSite != nil {
+		h.moveAdmittedAgent(agent, newSite, commonInfo)
+	}
+
 	if regenerate {
 		return h.ListAgents(c, i18n.T(c.Request().Context(), "agents.certs_regenerated"), "", true)
 	}
@@ -692,6 +1002,10 @@ func (h *Handler) AgentConfirmAdmission(c echo.Context, regenerate bool) error {
 }
 
 func (h *Handler) AgentForceRestart(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 
 	if c.Request().Method == "POST" {
@@ -699,7 +1013,7 @@ func (h *Handler) AgentForceRestart(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.restart."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err := h.natsRequest(c.Request().Context(), "agent.restart."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.no_responder"), false))
 		}
 	}
@@ -872,6 +1186,10 @@ func (h *Handler) AgentSettings(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
+		if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+			return err
+		}
+
 		s := openuem_nats.AgentSetting{}
 
 		s.DebugMode = false
@@ -924,7 +1242,7 @@ func (h *Handler) AgentSettings(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 		}
 
-		err = h.NATSConnection.Publish("agent.settings."+agentId, data)
+		err = h.natsPublish(c.Request().Context(), "agent.settings."+agentId, data)
 		if err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_nats_error", err.Error()), true))
 		}
@@ -945,7 +1263,7 @@ func (h *Handler) AgentSettings(c echo.Context) error {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_data_error"), true))
 			}
 
-			err = h.NATSConnection.Publish("agent.settings."+agentId, data)
+			err = h.natsPublish(c.Request().Context(), "agent.settings."+agentId, data)
 			if err != nil {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_nats_error", err.Error()), true))
 			}
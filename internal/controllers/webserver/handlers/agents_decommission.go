@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+// maxDecommissionReasonLength is the maximum number of characters of the reason to decommission agents
+const maxDecommissionReasonLength = 255
+
+type decommissionAgentsResponse struct {
+	Decommissioned int `json:"decommissioned"`
+}
+
+// DecommissionAgents sets the agents in the JSON array of the body as decommissioned, the reason
+// is required and is recorded with every agent
+func (h *Handler) DecommissionAgents(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+	}
+
+	reason, ok := decommissionReason(c.QueryParam("reason"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "agents.decommission_reason_required", maxDecommissionReasonLength))
+	}
+
+	var agentIDs []string
+	if err := json.NewDecoder(c.Request().Body).Decode(&agentIDs); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "agents.decommission_invalid_body"))
+	}
+	if len(agentIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "agents.decommission_no_agents"))
+	}
+
+	count, err := h.Model.BulkDecommissionAgents(agentIDs, tenantID, reason)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "agents.could_not_decommission", err.Error()))
+	}
+
+	h.auditTenantData(c, "has decommissioned %d of %d agents of tenant %d, reason: %q", count, len(agentIDs), tenantID, reason)
+
+	return c.JSON(http.StatusOK, decommissionAgentsResponse{Decommissioned: count})
+}
+
+// decommissionReason returns the trimmed reason and whether it's not empty and not too long
+func decommissionReason(reason string) (string, bool) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len([]rune(reason)) > maxDecommissionReasonLength {
+		return "", false
+	}
+	return reason, true
+}
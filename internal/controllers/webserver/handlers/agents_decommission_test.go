@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecommissionReason(t *testing.T) {
+	reason, ok := decommissionReason("  Hardware replaced ")
+	assert.True(t, ok, "should accept a reason")
+	assert.Equal(t, "Hardware replaced", reason, "should trim the reason")
+
+	_, ok = decommissionReason(" \t ")
+	assert.False(t, ok, "should require a reason")
+
+	_, ok = decommissionReason(strings.Repeat("é", maxDecommissionReasonLength))
+	assert.True(t, ok, "should count characters, not bytes")
+
+	_, ok = decommissionReason(strings.Repeat("a", maxDecommissionReasonLength+1))
+	assert.False(t, ok, "should not accept a reason too long")
+}
+
+func TestIsTenantAdminPath(t *testing.T) {
+	assert.True(t, isTenantAdminPath("/tenant/:tenant/admin/enrollment"))
+	assert.True(t, isTenantAdminPath("/admin/:tenant/:site/agents/decommission"))
+	assert.False(t, isTenantAdminPath("/admin/tenants/:tenant"))
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/agents_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// AgentMigrate shows the form to move an agent to another site of its organization
+func (h *Handler) AgentMigrate(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.tenant_cannot_be_empty"), true))
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return h.ListAgents(c, "", err.Error(), true)
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return h.ListAgents(c, "", err.Error(), true)
+	}
+
+	return RenderView(c, agents_views.AgentsIndex(" | Agents", agents_views.AgentMigrate(c, agent, sites, commonInfo), commonInfo))
+}
+
+// AgentConfirmMigration moves the agent to the site chosen in the form
+func (h *Handler) AgentConfirmMigration(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.tenant_cannot_be_empty"), true))
+	}
+
+	siteID, err := strconv.Atoi(c.FormValue("site"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.could_not_convert_site_to_int", c.FormValue("site")), true))
+	}
+
+	// The agent must be in the site the user is working in
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return h.ListAgents(c, "", err.Error(), true)
+	}
+
+	if err := h.Model.MigrateAgentToSite(agent.ID, siteID, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has moved agent %s (%s) of tenant %d to site %d", agent.ID, agent.Hostname, tenantID, siteID)
+
+	return h.ListAgents(c, i18n.T(c.Request().Context(), "agents.has_been_migrated"), "", true)
+}
+
+// AgentMoveToEnrollmentSite moves an agent waiting for admission to another site allowed by the token
+// it was enrolled with, and shows the admission again
+func (h *Handler) AgentMoveToEnrollmentSite(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.tenant_cannot_be_empty"), true))
+	}
+
+	siteID, err := strconv.Atoi(c.FormValue("site"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.could_not_convert_site_to_int", c.FormValue("site")), true))
+	}
+
+	agent, err := h.Model.GetAgentById(c.Param("uuid"), commonInfo)
+	if err != nil {
+		return h.ListAgents(c, "", err.Error(), true)
+	}
+
+	sites, err := h.Model.GetAgentEnrollmentSites(agent.ID, tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	if !slices.ContainsFunc(sites, func(s *ent.Site) bool { return s.ID == siteID }) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.site_not_allowed_by_token"), true))
+	}
+
+	if err := h.Model.MigrateAgentToSite(agent.ID, siteID, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has moved agent %s (%s) of tenant %d to site %d of its enrollment token", agent.ID, agent.Hostname, tenantID, siteID)
+
+	return h.AgentAdmit(c)
+}
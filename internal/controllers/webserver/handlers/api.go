@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+)
+
+// apiV1Path is the base path of the versioned API
+const apiV1Path = "/api/v1"
+
+// apiRoutes are the endpoints of the versioned API. The OpenAPI document is built from them
+func (h *Handler) apiRoutes() []api.Route {
+	platform := func(values ...string) api.Parameter {
+		return api.Parameter{Name: "platform", In: "query", Description: "Platform of the agent, defaults to linux", Schema: &api.Schema{Type: "string", Enum: values}}
+	}
+
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/enroll/:token/config",
+			OperationID: "downloadEnrollmentConfig",
+			Summary:     "Download the agent configuration and certificates for an enrollment token",
+			Tag:         "enrollment",
+			Security:    []string{api.SecurityEnrollmentToken},
+			Query:       []api.Parameter{platform("linux", "macos", "windows")},
+			Responses:   map[int]api.Response{http.StatusOK: api.FileResponse("ZIP with openuem.ini and the certificates", "application/zip")},
+			Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+			Handler:     h.PublicDownloadConfig,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/enroll/:token/install",
+			OperationID: "getInstallScript",
+			Summary:     "Get the script that installs and enrolls the agent",
+			Tag:         "enrollment",
+			Security:    []string{api.SecurityEnrollmentToken},
+			Query:       []api.Parameter{platform("linux", "macos-amd64", "macos-arm64", "windows")},
+			Responses: map[int]api.Response{http.StatusOK: {
+				Description: "Install script",
+				Content:     map[string]api.MediaType{"text/x-shellscript": {Schema: &api.Schema{Type: "string"}}, echo.MIMETextPlain: {Schema: &api.Schema{Type: "string"}}},
+			}},
+			Errors:  []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+			Handler: h.PublicInstallScript,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/tenants/:tenant/agents",
+			OperationID: "listAgents",
+			Summary:     "List the agents of a tenant",
+			Tag:         "agents",
+			Security:    []string{api.SecurityAPIToken},
+			Query: []api.Parameter{
+				{Name: "site", In: "query", Description: "Only the agents of this site", Schema: &api.Schema{Type: "integer"}},
+				{Name: "tag", In: "query", Description: "Only the agents with these tags, it can be repeated", Schema: &api.Schema{Type: "array", Items: &api.Schema{Type: "integer"}}},
+				{Name: "os", In: "query", Description: "Only the agents with these operating systems, it can be repeated", Schema: &api.Schema{Type: "array", Items: &api.Schema{Type: "string"}}},
+				{Name: "status", In: "query", Description: "Only the agents with this status", Schema: &api.Schema{Type: "string", Enum: apiAgentStatuses}},
+				{Name: "lastContactSince", In: "query", Description: "Only the agents that have contacted since then", Schema: &api.Schema{Type: "string", Format: "date-time"}},
+				{Name: "fields", In: "query", Description: "Comma separated fields of the agents to return, all if empty", Schema: &api.Schema{Type: "string"}},
+			},
+			Paginated:  true,
+			Responses:  map[int]api.Response{http.StatusOK: api.JSONResponse("Page of agents", api.PageSchema(api.SchemaOf(apiAgent{})))},
+			Errors:     []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError},
+			Handler:    h.ListAPIAgents,
+			Middleware: []echo.MiddlewareFunc{h.APITokenAuth, h.APIRateLimit},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/agents/:id",
+			OperationID: "getAgent",
+			Summary:     "Get an agent with its hardware, software, updates and printers",
+			Tag:         "agents",
+			Security:    []string{api.SecurityAPIToken},
+			Responses:   map[int]api.Response{http.StatusOK: api.JSONResponse("Agent inventory", api.SchemaOf(apiAgentInventory{}))},
+			Errors:      []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError},
+			Handler:     h.GetAPIAgent,
+			Middleware:  []echo.MiddlewareFunc{h.APITokenAuth, h.APIRateLimit},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/tenants/:tenant/enrollment-tokens",
+			OperationID: "createEnrollmentToken",
+			Summary:     "Create an enrollment token for a tenant, the user of the API token must be an admin of the tenant",
+			Tag:         "enrollment",
+			Security:    []string{api.SecurityAPIToken},
+			RequestBody: api.SchemaOf(apiEnrollmentTokenRequest{}),
+			Responses:   map[int]api.Response{http.StatusCreated: api.JSONResponse("Enrollment token", api.SchemaOf(apiEnrollmentToken{}))},
+			Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError},
+			Handler:     h.CreateAPIEnrollmentToken,
+			Middleware:  []echo.MiddlewareFunc{h.APITokenAuth, h.APIRateLimit},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/openapi.json",
+			OperationID: "getOpenAPI",
+			Summary:     "Get the OpenAPI document of the API",
+			Tag:         "meta",
+			Responses:   map[int]api.Response{http.StatusOK: api.JSONResponse("OpenAPI 3 document", &api.Schema{Type: "object"})},
+			Handler:     h.OpenAPI,
+		},
+	}
+}
+
+// OpenAPI serves the OpenAPI document of the versioned API
+func (h *Handler) OpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, api.Spec(h.Version, apiV1Path, h.apiRoutes()))
+}
+
+func (h *Handler) registerAPI(e *echo.Echo) {
+	api.Register(e.Group(apiV1Path), h.apiRoutes())
+
+	// Unversioned enrollment endpoints used by the commands generated by previous releases
+	e.GET("/api/enroll/:token/config", h.PublicDownloadConfig)
+	e.GET("/api/enroll/:token/install", h.PublicInstallScript)
+}
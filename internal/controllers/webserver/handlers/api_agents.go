@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+const (
+	defaultAPIPageSize = 100
+	maxAPIPageSize     = 500
+)
+
+// apiAgentStatuses are the values of the status filter, they're the ones of the agents list
+var apiAgentStatuses = []string{"Enabled", "Disabled", "WaitingForAdmission"}
+
+// apiAgent is an agent in the API. The JSON field names are part of the API, don't rename them
+type apiAgent struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	Nickname    string    `json:"nickname"`
+	OS          string    `json:"os"`
+	IP          string    `json:"ip"`
+	Status      string    `json:"status"`
+	Version     string    `json:"version"`
+	IsRemote    bool      `json:"is_remote"`
+	LastContact time.Time `json:"last_contact"`
+	Site        *apiSite  `json:"site"`
+	Tags        []apiTag  `json:"tags"`
+}
+
+type apiSite struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiTag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// apiAgentInventory is an agent with its full inventory
+type apiAgentInventory struct {
+	apiAgent
+	Hardware        apiHardware        `json:"hardware"`
+	OperatingSystem apiOperatingSystem `json:"operating_system"`
+	Software        []apiSoftware      `json:"software"`
+	Updates         []apiUpdate        `json:"updates"`
+	Printers        []apiPrinter       `json:"printers"`
+}
+
+type apiHardware struct {
+	Manufacturer   string          `json:"manufacturer"`
+	Model          string          `json:"model"`
+	Serial         string          `json:"serial"`
+	Processor      string          `json:"processor"`
+	ProcessorArch  string          `json:"processor_arch"`
+	ProcessorCores int64           `json:"processor_cores"`
+	MemoryMB       uint64          `json:"memory_mb"`
+	MemorySlots    []apiMemorySlot `json:"memory_slots"`
+}
+
+type apiMemorySlot struct {
+	Slot         string `json:"slot"`
+	Type         string `json:"type"`
+	Size         string `json:"size"`
+	Speed        string `json:"speed"`
+	Manufacturer string `json:"manufacturer"`
+	PartNumber   string `json:"part_number"`
+	SerialNumber string `json:"serial_number"`
+}
+
+type apiOperatingSystem struct {
+	Version        string    `json:"version"`
+	Description    string    `json:"description"`
+	Arch           string    `json:"arch"`
+	Username       string    `json:"username"`
+	InstallDate    time.Time `json:"install_date"`
+	LastBootupTime time.Time `json:"last_bootup_time"`
+}
+
+type apiSoftware struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Publisher   string `json:"publisher"`
+	InstallDate string `json:"install_date"`
+}
+
+type apiUpdate struct {
+	Title      string    `json:"title"`
+	Date       time.Time `json:"date"`
+	SupportURL string    `json:"support_url"`
+}
+
+type apiPrinter struct {
+	Name      string `json:"name"`
+	Port      string `json:"port"`
+	IsDefault bool   `json:"is_default"`
+	IsNetwork bool   `json:"is_network"`
+	IsShared  bool   `json:"is_shared"`
+}
+
+// apiPage is a page of items, the items are maps when the client selects the fields
+type apiPage struct {
+	Items    any `json:"items"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"`
+}
+
+// ListAPIAgents returns a page of the agents of a tenant. It uses the same queries and filters as the agents list
+func (h *Handler) ListAPIAgents(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return api.NewError(http.StatusBadRequest, "invalid_parameter", "invalid tenant", api.FieldError{Field: "tenant", Message: "must be a tenant ID"})
+	}
+
+	if err := h.apiTenantAccess(c, tenantID); err != nil {
+		return err
+	}
+
+	p, err := apiPagination(c)
+	if err != nil {
+		return err
+	}
+
+	f, siteID, err := apiAgentFilter(c)
+	if err != nil {
+		return err
+	}
+
+	fields, err := apiFields(c, apiAgent{})
+	if err != nil {
+		return err
+	}
+
+	commonInfo := &partials.CommonInfo{TenantID: strconv.Itoa(tenantID), SiteID: strconv.Itoa(siteID)}
+
+	agents, err := h.Model.GetAgentsByPage(p, f, false, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	total, err := h.Model.CountAllAgents(f, false, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	items := []any{}
+	for _, a := range agents {
+		item, err := apiSelectFields(newAPIAgent(a), fields)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	return c.JSON(http.StatusOK, apiPage{Items: items, Page: p.CurrentPage, PageSize: p.PageSize, Total: total})
+}
+
+// GetAPIAgent returns an agent with its hardware, software, updates and printers
+func (h *Handler) GetAPIAgent(c echo.Context) error {
+	agentID := c.Param("id")
+
+	// Agents of tenants the user can't access can't be told apart from agents that don't exist
+	notFound := api.NewError(http.StatusNotFound, "agent_not_found", "agent not found")
+
+	tenantID, err := h.Model.GetAgentTenantID(agentID)
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return notFound
+		}
+		return err
+	}
+
+	if err := h.apiTenantAccess(c, tenantID); err != nil {
+		apiErr := &api.Error{}
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusForbidden {
+			return notFound
+		}
+		return err
+	}
+
+	commonInfo := &partials.CommonInfo{TenantID: strconv.Itoa(tenantID), SiteID: "-1"}
+
+	agent, err := h.Model.GetAgentById(agentID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	computer, err := h.Model.GetAgentComputerInfo(agentID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	apps, err := h.Model.GetAgentAppsInfo(agentID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	updates, err := h.Model.GetAllLatestUpdates(agentID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	printers, err := h.Model.GetAgentPrintersInfo(agentID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	inventory := apiAgentInventory{
+		apiAgent: newAPIAgent(agent),
+		Software: []apiSoftware{},
+		Updates:  []apiUpdate{},
+		Printers: []apiPrinter{},
+	}
+
+	inventory.Hardware.MemorySlots = []apiMemorySlot{}
+	if cmp := computer.Edges.Computer; cmp != nil {
+		inventory.Hardware = apiHardware{
+			Manufacturer:   cmp.Manufacturer,
+			Model:          cmp.Model,
+			Serial:         cmp.Serial,
+			Processor:      cmp.Processor,
+			ProcessorArch:  cmp.ProcessorArch,
+			ProcessorCores: cmp.ProcessorCores,
+			MemoryMB:       cmp.Memory,
+			MemorySlots:    []apiMemorySlot{},
+		}
+	}
+	for _, slot := range computer.Edges.Memoryslots {
+		inventory.Hardware.MemorySlots = append(inventory.Hardware.MemorySlots, apiMemorySlot{
+			Slot:         slot.Slot,
+			Type:         slot.Type,
+			Size:         slot.Size,
+			Speed:        slot.Speed,
+			Manufacturer: slot.Manufacturer,
+			PartNumber:   slot.PartNumber,
+			SerialNumber: slot.SerialNumber,
+		})
+	}
+
+	if osInfo := agent.Edges.Operatingsystem; osInfo != nil {
+		inventory.OperatingSystem = apiOperatingSystem{
+			Version:        osInfo.Version,
+			Description:    osInfo.Description,
+			Arch:           osInfo.Arch,
+			Username:       osInfo.Username,
+			InstallDate:    osInfo.InstallDate,
+			LastBootupTime: osInfo.LastBootupTime,
+		}
+	}
+
+	for _, a := range apps {
+		inventory.Software = append(inventory.Software, apiSoftware{Name: a.Name, Version: a.Version, Publisher: a.Publisher, InstallDate: a.InstallDate})
+	}
+
+	for _, u := range updates {
+		inventory.Updates = append(inventory.Updates, apiUpdate{Title: u.Title, Date: u.Date, SupportURL: u.SupportURL})
+	}
+
+	for _, p := range printers {
+		inventory.Printers = append(inventory.Printers, apiPrinter{Name: p.Name, Port: p.Port, IsDefault: p.IsDefault, IsNetwork: p.IsNetwork, IsShared: p.IsShared})
+	}
+
+	return c.JSON(http.StatusOK, inventory)
+}
+
+// APITokenAuth authenticates the requests with the API token in the Authorization header
+func (h *Handler) APITokenAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		value, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || value == "" {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return api.NewError(http.StatusUnauthorized, "unauthorized", "missing API token")
+		}
+
+		token, err := h.Model.AuthenticateAPIToken(value)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidAPIToken) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return api.NewError(http.StatusUnauthorized, "unauthorized", "invalid or expired API token")
+			}
+			return err
+		}
+
+		c.Set("api_token_id", token.ID)
+		c.Set("user_id", token.UserID)
+		return next(c)
+	}
+}
+
+// APIRateLimit limits the requests of each API token and counts them in the usage of the API, it must run
+// after APITokenAuth. The requests to a tenant are also limited in apiTenantAccess
+func (h *Handler) APIRateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tokenID, _ := c.Get("api_token_id").(int)
+		userID, _ := c.Get("user_id").(string)
+
+		limits, err := h.Model.GetAPIRateLimits()
+		if err != nil {
+			return err
+		}
+		c.Set("api_rate_limits", limits)
+
+		decision, err := h.apiRateLimiter.Allow("token:"+strconv.Itoa(tokenID), api.RateLimit{PerMinute: limits.TokenRate, Burst: limits.TokenBurst})
+		if err != nil {
+			return err
+		}
+		api.SetRateLimitHeaders(c, decision)
+		c.Set("api_rate_decision", decision)
+
+		if decision.Allowed {
+			err = next(c)
+		} else {
+			err = api.NewError(http.StatusTooManyRequests, "rate_limited", "too many requests for this API token")
+		}
+
+		tenantID, _ := c.Get("api_tenant_id").(int)
+		apiErr := &api.Error{}
+		h.apiUsage.add(tokenID, userID, tenantID, errors.As(err, &apiErr) && apiErr.Status == http.StatusTooManyRequests)
+
+		return err
+	}
+}
+
+// apiTenantAccess runs the checks of TenantAccessMiddleware for the user of the API token
+func (h *Handler) apiTenantAccess(c echo.Context, tenantID int) error {
+	userID, _ := c.Get("user_id").(string)
+
+	hasAccess, err := h.Model.UserHasAccessToTenant(userID, tenantID)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return api.NewError(http.StatusForbidden, "no_tenant_access", "you don't have access to this tenant")
+	}
+
+	ipAllowed, err := h.isIPAllowedInTenant(c, tenantID)
+	if err != nil {
+		return err
+	}
+	if !ipAllowed {
+		return api.NewError(http.StatusForbidden, "ip_not_allowed", "the tenant doesn't allow access from "+c.RealIP())
+	}
+
+	c.Set("api_tenant_id", tenantID)
+
+	limits, _ := c.Get("api_rate_limits").(models.APIRateLimits)
+	decision, err := h.apiRateLimiter.Allow("tenant:"+strconv.Itoa(tenantID), api.RateLimit{PerMinute: limits.TenantRate, Burst: limits.TenantBurst})
+	if err != nil {
+		return err
+	}
+
+	// The headers show the limit that is closer to be reached
+	tokenDecision, _ := c.Get("api_rate_decision").(api.RateDecision)
+	if !decision.Allowed || tokenDecision.Limit == 0 || (decision.Limit > 0 && decision.Remaining < tokenDecision.Remaining) {
+		api.SetRateLimitHeaders(c, decision)
+	}
+	if !decision.Allowed {
+		return api.NewError(http.StatusTooManyRequests, "tenant_rate_limited", "too many requests for this tenant")
+	}
+
+	return nil
+}
+
+func newAPIAgent(a *openuem_ent.Agent) apiAgent {
+	item := apiAgent{
+		ID:          a.ID,
+		Hostname:    a.Hostname,
+		Nickname:    a.Nickname,
+		OS:          a.Os,
+		IP:          a.IP,
+		Status:      string(a.AgentStatus),
+		IsRemote:    a.IsRemote,
+		LastContact: a.LastContact,
+		Tags:        []apiTag{},
+	}
+	if a.Edges.Release != nil {
+		item.Version = a.Edges.Release.Version
+	}
+	if len(a.Edges.Site) > 0 {
+		item.Site = &apiSite{ID: a.Edges.Site[0].ID, Name: a.Edges.Site[0].Description}
+	}
+	for _, t := range a.Edges.Tags {
+		item.Tags = append(item.Tags, apiTag{ID: t.ID, Name: t.Tag})
+	}
+	return item
+}
+
+func apiPagination(c echo.Context) (partials.PaginationAndSort, error) {
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: defaultAPIPageSize}
+	fields := []api.FieldError{}
+
+	if page := c.QueryParam("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			fields = append(fields, api.FieldError{Field: "page", Message: "must be a number greater than 0"})
+		}
+		p.CurrentPage = n
+	}
+
+	if pageSize := c.QueryParam("pageSize"); pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 || n > maxAPIPageSize {
+			fields = append(fields, api.FieldError{Field: "pageSize", Message: "must be a number from 1 to " + strconv.Itoa(maxAPIPageSize)})
+		}
+		p.PageSize = n
+	}
+
+	if len(fields) > 0 {
+		return p, api.NewError(http.StatusBadRequest, "invalid_parameter", "invalid pagination", fields...)
+	}
+	return p, nil
+}
+
+// apiAgentFilter reads the filters of the agents list from the query, it returns -1 as the site if there's no site filter
+func apiAgentFilter(c echo.Context) (filters.AgentFilter, int, error) {
+	f := filters.AgentFilter{}
+	siteID := -1
+	fields := []api.FieldError{}
+	query := c.QueryParams()
+
+	if site := c.QueryParam("site"); site != "" {
+		id, err := strconv.Atoi(site)
+		if err != nil {
+			fields = append(fields, api.FieldError{Field: "site", Message: "must be a site ID"})
+		}
+		siteID = id
+	}
+
+	for _, tag := range query["tag"] {
+		id, err := strconv.Atoi(tag)
+		if err != nil {
+			fields = append(fields, api.FieldError{Field: "tag", Message: "must be a tag ID"})
+			continue
+		}
+		f.Tags = append(f.Tags, id)
+	}
+
+	f.AgentOSVersions = query["os"]
+
+	if status := c.QueryParam("status"); status != "" {
+		if !slices.Contains(apiAgentStatuses, status) {
+			fields = append(fields, api.FieldError{Field: "status", Message: "must be one of " + strings.Join(apiAgentStatuses, ", ")})
+		}
+		f.AgentStatusOptions = []string{status}
+	}
+
+	if since := c.QueryParam("lastContactSince"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			fields = append(fields, api.FieldError{Field: "lastContactSince", Message: "must be an RFC 3339 date and time"})
+		}
+		f.ContactSince = t
+	}
+
+	if len(fields) > 0 {
+		return f, siteID, api.NewError(http.StatusBadRequest, "invalid_parameter", "invalid filter", fields...)
+	}
+	return f, siteID, nil
+}
+
+// apiFields returns the fields selected in the fields param, none means all the fields
+func apiFields(c echo.Context, v any) ([]string, error) {
+	param := c.QueryParam("fields")
+	if param == "" {
+		return nil, nil
+	}
+
+	available := api.FieldNames(v)
+	fields := []string{}
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(available, field) {
+			return nil, api.NewError(http.StatusBadRequest, "invalid_parameter", "unknown field "+field, api.FieldError{Field: "fields", Message: "must be a list of " + strings.Join(available, ", ")})
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func apiSelectFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := map[string]json.RawMessage{}
+	for _, field := range fields {
+		selected[field] = all[field]
+	}
+	return selected, nil
+}
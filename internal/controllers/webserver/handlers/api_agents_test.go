@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+type apiAgentsTest struct {
+	e          *echo.Echo
+	h          *Handler
+	token      string
+	commonInfo *partials.CommonInfo
+	otherID    int
+}
+
+// newAPIAgentsTest creates a tenant with five agents, the operator user has access to it and has an API
+// token. The agents of a second tenant, which the user can't access, must never be returned
+func newAPIAgentsTest(t *testing.T) *apiAgentsTest {
+	client := enttest.Open(t, "sqlite3", "file:apiagents?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	at := &apiAgentsTest{
+		e: echo.New(),
+		h: &Handler{
+			Model:          &models.Model{Client: client},
+			apiRateLimiter: api.NewMemoryRateLimiter(time.Minute),
+			apiUsage:       newAPIUsageCounter(),
+		},
+	}
+	at.e.HTTPErrorHandler = api.HandleError
+	at.h.registerAPI(at.e)
+
+	for i, name := range []string{"Tenant", "Other"} {
+		tenant, err := client.Tenant.Create().SetDescription(name).SetIsDefault(i == 0).Save(context.Background())
+		assert.NoError(t, err)
+		s, err := at.h.Model.CreateDefaultSite(tenant)
+		assert.NoError(t, err)
+
+		if i == 0 {
+			at.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(tenant.ID), SiteID: "-1"}
+		} else {
+			at.otherID = tenant.ID
+		}
+
+		for j := range 5 {
+			id := fmt.Sprintf("%s%d", name, j)
+			osName := "windows"
+			if j%2 == 1 {
+				osName = "linux"
+			}
+			err := client.Agent.Create().SetID(id).SetHostname(id).SetNickname(id).SetOs(osName).SetAgentStatus(agent.AgentStatusEnabled).
+				SetLastContact(time.Now().Add(-time.Duration(j) * time.Hour)).AddSiteIDs(s.ID).Exec(context.Background())
+			assert.NoError(t, err)
+			err = client.Computer.Create().SetManufacturer("manufacturer").SetModel("model").SetProcessorCores(4).SetMemory(8192).SetOwnerID(id).Exec(context.Background())
+			assert.NoError(t, err)
+			err = client.App.Create().SetName("App").SetVersion("1.0").SetOwnerID(id).Exec(context.Background())
+			assert.NoError(t, err)
+			err = client.Printer.Create().SetName("printer").SetOwnerID(id).Exec(context.Background())
+			assert.NoError(t, err)
+		}
+	}
+
+	err := client.User.Create().SetID("operator").SetName("operator").SetEmail("operator@example.com").SetCreated(time.Now()).Exec(context.Background())
+	assert.NoError(t, err)
+	tenantID, _ := strconv.Atoi(at.commonInfo.TenantID)
+	assert.NoError(t, at.h.Model.AssignUserToTenant("operator", tenantID, models.UserTenantRoleOperator, true))
+
+	at.token, _, err = at.h.Model.CreateAPIToken("operator", "CMDB", nil)
+	assert.NoError(t, err)
+
+	// The requests are not limited unless a test sets the limits
+	assert.NoError(t, at.h.Model.CreateInitialSettings())
+	assert.NoError(t, at.h.Model.SaveAPIRateLimits(models.APIRateLimits{}))
+
+	return at
+}
+
+func (at *apiAgentsTest) get(path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	at.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestListAPIAgentsMatchesAgentsList(t *testing.T) {
+	at := newAPIAgentsTest(t)
+
+	for query, f := range map[string]filters.AgentFilter{
+		"":                    {},
+		"os=linux":            {AgentOSVersions: []string{"linux"}},
+		"status=Disabled":     {AgentStatusOptions: []string{"Disabled"}},
+		"page=2&pageSize=2":   {},
+		"os=windows&os=linux": {AgentOSVersions: []string{"windows", "linux"}},
+		"pageSize=1&os=linux": {AgentOSVersions: []string{"linux"}},
+	} {
+		rec := at.get(fmt.Sprintf("/api/v1/tenants/%s/agents?%s", at.commonInfo.TenantID, query), at.token)
+		if !assert.Equal(t, http.StatusOK, rec.Code, query) {
+			continue
+		}
+
+		page := struct {
+			Items    []apiAgent `json:"items"`
+			Page     int        `json:"page"`
+			PageSize int        `json:"page_size"`
+			Total    int        `json:"total"`
+		}{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+		// The agents list of the web UI must show the same agents
+		p := partials.PaginationAndSort{CurrentPage: page.Page, PageSize: page.PageSize}
+		agents, err := at.h.Model.GetAgentsByPage(p, f, false, at.commonInfo)
+		assert.NoError(t, err)
+		total, err := at.h.Model.CountAllAgents(f, false, at.commonInfo)
+		assert.NoError(t, err)
+
+		assert.Equal(t, total, page.Total, query)
+		if assert.Len(t, page.Items, len(agents), query) {
+			for i, a := range agents {
+				assert.Equal(t, a.ID, page.Items[i].ID, query)
+				assert.Equal(t, a.Os, page.Items[i].OS, query)
+				assert.Equal(t, a.Edges.Site[0].ID, page.Items[i].Site.ID, query)
+			}
+		}
+	}
+}
+
+func TestListAPIAgentsParams(t *testing.T) {
+	at := newAPIAgentsTest(t)
+	path := fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID)
+
+	rec := at.get(path+"?fields=id,hostname&lastContactSince="+time.Now().Add(-90*time.Minute).UTC().Format(time.RFC3339), at.token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := struct {
+		Items []map[string]any `json:"items"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 2, "should only return the agents that have contacted since then")
+	for _, item := range page.Items {
+		assert.Len(t, item, 2, "should only return the selected fields")
+		assert.Contains(t, item, "hostname")
+	}
+
+	for query, field := range map[string]string{
+		"?fields=password":          "fields",
+		"?pageSize=1000":            "pageSize",
+		"?status=Unknown":           "status",
+		"?lastContactSince=today":   "lastContactSince",
+		"?tag=production":           "tag",
+		"?page=0&site=headquarters": "page",
+	} {
+		rec := at.get(path+query, at.token)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		apiErr := api.Error{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+		if assert.NotEmpty(t, apiErr.Fields, query) {
+			assert.Equal(t, field, apiErr.Fields[0].Field, query)
+		}
+	}
+}
+
+func TestAPIAgentsAuthorization(t *testing.T) {
+	at := newAPIAgentsTest(t)
+
+	rec := at.get(fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID), "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "should require an API token")
+
+	rec = at.get(fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID), models.APITokenPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "should not accept unknown API tokens")
+
+	rec = at.get(fmt.Sprintf("/api/v1/tenants/%d/agents", at.otherID), at.token)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "should not list the agents of tenants the user can't access")
+
+	rec = at.get("/api/v1/agents/Other0", at.token)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "should not return the agents of tenants the user can't access")
+
+	rec = at.get("/api/v1/agents/unknown", at.token)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetAPIAgentMatchesInventory(t *testing.T) {
+	at := newAPIAgentsTest(t)
+
+	rec := at.get("/api/v1/agents/Tenant0", at.token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	inventory := apiAgentInventory{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inventory))
+
+	// The computer views of the web UI must show the same inventory
+	computer, err := at.h.Model.GetAgentComputerInfo("Tenant0", at.commonInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tenant0", inventory.ID)
+	assert.Equal(t, computer.Edges.Computer.Manufacturer, inventory.Hardware.Manufacturer)
+	assert.Equal(t, computer.Edges.Computer.ProcessorCores, inventory.Hardware.ProcessorCores)
+	assert.Equal(t, computer.Edges.Computer.Memory, inventory.Hardware.MemoryMB)
+
+	apps, err := at.h.Model.GetAgentAppsInfo("Tenant0", at.commonInfo)
+	assert.NoError(t, err)
+	if assert.Len(t, inventory.Software, len(apps)) {
+		assert.Equal(t, apps[0].Name, inventory.Software[0].Name)
+		assert.Equal(t, apps[0].Version, inventory.Software[0].Version)
+	}
+
+	printers, err := at.h.Model.GetAgentPrintersInfo("Tenant0", at.commonInfo)
+	assert.NoError(t, err)
+	if assert.Len(t, inventory.Printers, len(printers)) {
+		assert.Equal(t, printers[0].Name, inventory.Printers[0].Name)
+	}
+	assert.Empty(t, inventory.Updates)
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	at := newAPIAgentsTest(t)
+	assert.NoError(t, at.h.Model.SaveAPIRateLimits(models.APIRateLimits{TokenRate: 1, TokenBurst: 2}))
+
+	path := fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID)
+	for i := range 2 {
+		rec := at.get(path, at.token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get(api.HeaderRateLimitLimit))
+		assert.Equal(t, strconv.Itoa(1-i), rec.Header().Get(api.HeaderRateLimitRemaining))
+	}
+	rec := at.get(path, at.token)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "should limit the requests of the token")
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, "0", rec.Header().Get(api.HeaderRateLimitRemaining))
+
+	other, _, err := at.h.Model.CreateAPIToken("operator", "Other", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, at.get(path, other).Code, "should limit each token on its own")
+}
+
+func TestAPITenantRateLimit(t *testing.T) {
+	at := newAPIAgentsTest(t)
+	assert.NoError(t, at.h.Model.SaveAPIRateLimits(models.APIRateLimits{TenantRate: 1, TenantBurst: 2}))
+
+	other, _, err := at.h.Model.CreateAPIToken("operator", "Other", nil)
+	assert.NoError(t, err)
+
+	path := fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID)
+	assert.Equal(t, http.StatusOK, at.get(path, at.token).Code)
+	assert.Equal(t, http.StatusOK, at.get(path, other).Code)
+
+	rec := at.get(path, other)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "should limit the requests of all the tokens to the tenant")
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+
+	apiErr := api.Error{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+	assert.Equal(t, "tenant_rate_limited", apiErr.Code)
+}
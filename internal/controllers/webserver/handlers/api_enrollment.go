@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
+)
+
+// apiEnrollmentTokenRequest is the body to create an enrollment token. The JSON field names are part
+// of the API, don't rename them
+type apiEnrollmentTokenRequest struct {
+	Description string     `json:"description"`
+	MaxUses     int        `json:"max_uses,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// SiteID is the default site of the agents, SiteIDs the other sites they can be moved to
+	SiteID  int   `json:"site_id,omitempty"`
+	SiteIDs []int `json:"site_ids,omitempty"`
+	// AllowedOS restricts the token to these platforms, linux, macos or windows, any if empty
+	AllowedOS []string `json:"allowed_os,omitempty"`
+}
+
+// apiEnrollmentToken is a new enrollment token, DownloadURL serves the agent configuration for it
+type apiEnrollmentToken struct {
+	ID          int    `json:"id"`
+	Token       string `json:"token"`
+	DownloadURL string `json:"download_url"`
+}
+
+// CreateAPIEnrollmentToken creates an enrollment token of a tenant without a browser session, e.g. for
+// the ephemeral environments of a CI/CD pipeline. As in the console, only the admins of the tenant can
+// create them and the admin allowlist applies
+func (h *Handler) CreateAPIEnrollmentToken(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return api.NewError(http.StatusBadRequest, "invalid_parameter", "invalid tenant", api.FieldError{Field: "tenant", Message: "must be a tenant ID"})
+	}
+
+	if err := h.apiTenantAccess(c, tenantID); err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id").(string)
+	isAdmin, err := h.Model.IsUserTenantAdmin(userID, tenantID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return api.NewError(http.StatusForbidden, "admin_required", "you must be an admin of this tenant")
+	}
+
+	allowed, err := h.isIPAllowedInAdminArea(c.RealIP(), tenantID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return api.NewError(http.StatusForbidden, "ip_not_allowed", "the admin allowlist doesn't allow access from "+c.RealIP())
+	}
+
+	req := apiEnrollmentTokenRequest{}
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return api.NewError(http.StatusBadRequest, "invalid_body", "the body must be a JSON object with the enrollment token")
+	}
+
+	var fieldErrors []api.FieldError
+	description, ok := enrollmentTokenDescription(req.Description)
+	if !ok {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "description", Message: "is required"})
+	}
+	if req.MaxUses < 0 {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "max_uses", Message: "must be 0, for unlimited uses, or more"})
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+
+	sites := models.EnrollmentTokenSites{DefaultID: req.SiteID, IDs: req.SiteIDs}
+	if req.SiteID != 0 {
+		if _, err := h.Model.GetSite(req.SiteID, tenantID); err != nil {
+			if !openuem_ent.IsNotFound(err) {
+				return err
+			}
+			fieldErrors = append(fieldErrors, api.FieldError{Field: "site_id", Message: "must be a site of the tenant"})
+		}
+	}
+	for _, id := range req.SiteIDs {
+		if _, err := h.Model.GetSite(id, tenantID); err != nil {
+			if !openuem_ent.IsNotFound(err) {
+				return err
+			}
+			fieldErrors = append(fieldErrors, api.FieldError{Field: "site_ids", Message: "must be sites of the tenant"})
+			break
+		}
+	}
+
+	for _, platform := range req.AllowedOS {
+		if !slices.Contains(models.EnrollmentTokenPlatforms, platform) {
+			fieldErrors = append(fieldErrors, api.FieldError{Field: "allowed_os", Message: "must be linux, macos or windows"})
+			break
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return api.NewError(http.StatusBadRequest, "invalid_parameter", "invalid enrollment token", fieldErrors...)
+	}
+
+	token, err := h.Model.CreateEnrollmentToken(tenantID, sites, description, uuid.New().String(), req.MaxUses, req.ExpiresAt, req.AllowedOS)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, apiEnrollmentToken{
+		ID:          token.ID,
+		Token:       token.Token,
+		DownloadURL: fmt.Sprintf("https://%s%s/enroll/%s/config", c.Request().Host, apiV1Path, token.Token),
+	})
+}
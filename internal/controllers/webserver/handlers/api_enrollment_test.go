@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAPIEnrollmentToken(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:apienrollment?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	e := echo.New()
+	e.HTTPErrorHandler = api.HandleError
+	h := &Handler{
+		Model:          &models.Model{Client: client},
+		apiRateLimiter: api.NewMemoryRateLimiter(time.Minute),
+		apiUsage:       newAPIUsageCounter(),
+	}
+	h.registerAPI(e)
+
+	siteIDs := []int{}
+	tenantID := 0
+	for i, name := range []string{"Tenant", "Other"} {
+		tenant, err := client.Tenant.Create().SetDescription(name).SetIsDefault(i == 0).Save(context.Background())
+		assert.NoError(t, err)
+		s, err := h.Model.CreateDefaultSite(tenant)
+		assert.NoError(t, err)
+		siteIDs = append(siteIDs, s.ID)
+		if i == 0 {
+			tenantID = tenant.ID
+		}
+	}
+
+	tokens := map[models.UserTenantRole]string{}
+	for _, role := range []models.UserTenantRole{models.UserTenantRoleAdmin, models.UserTenantRoleOperator} {
+		err := client.User.Create().SetID(string(role)).SetName(string(role)).SetEmail(string(role) + "@example.com").SetCreated(time.Now()).Exec(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, h.Model.AssignUserToTenant(string(role), tenantID, role, true))
+		tokens[role], _, err = h.Model.CreateAPIToken(string(role), "CI", nil)
+		assert.NoError(t, err)
+	}
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/tenants/%d/enrollment-tokens", tenantID), strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(tokens[models.UserTenantRoleAdmin], fmt.Sprintf(`{"description": " Pipeline ", "max_uses": 1, "site_id": %d}`, siteIDs[0]))
+	if assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		created := apiEnrollmentToken{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		assert.NotEmpty(t, created.Token)
+		assert.Equal(t, "https://example.com/api/v1/enroll/"+created.Token+"/config", created.DownloadURL)
+
+		token, _, err := h.Model.GetEnrollmentTokenByValue(created.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, token.ID)
+		assert.Equal(t, "Pipeline", token.Description)
+		assert.Equal(t, 1, token.MaxUses)
+	}
+
+	for body, field := range map[string]string{
+		`{"description": " "}`:                                                "description",
+		`{"description": "Pipeline", "max_uses": -1}`:                         "max_uses",
+		`{"description": "Pipeline", "expires_at": "2020-01-01T00:00:00Z"}`:   "expires_at",
+		fmt.Sprintf(`{"description": "Pipeline", "site_id": %d}`, siteIDs[1]): "site_id",
+	} {
+		rec := post(tokens[models.UserTenantRoleAdmin], body)
+		if assert.Equal(t, http.StatusBadRequest, rec.Code, body) {
+			apiErr := api.Error{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+			if assert.Len(t, apiErr.Fields, 1, body) {
+				assert.Equal(t, field, apiErr.Fields[0].Field, body)
+			}
+		}
+	}
+
+	rec = post(tokens[models.UserTenantRoleAdmin], `{"description": "Pipeline", "unknown": true}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = post(tokens[models.UserTenantRoleOperator], `{"description": "Pipeline"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = post("", `{"description": "Pipeline"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
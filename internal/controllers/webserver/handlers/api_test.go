@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	mw "github.com/labstack/echo/v4/middleware"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// pathParam matches the params of the routes, they're replaced with values that don't exist
+var pathParam = regexp.MustCompile(`:[a-zA-Z]+`)
+
+func TestAPIRoutesMatchOpenAPI(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:api?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	h := &Handler{Model: &models.Model{Client: client}, Version: "test"}
+	e := echo.New()
+	e.Use(mw.RequestID())
+	e.HTTPErrorHandler = api.HandleError
+	h.registerAPI(e)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiV1Path+"/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	doc := api.Document{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc), "should serve the OpenAPI document")
+
+	registered := map[string][]string{}
+	for _, r := range e.Routes() {
+		path, ok := strings.CutPrefix(r.Path, apiV1Path)
+		if !ok {
+			continue
+		}
+		path = api.SpecPath(path)
+		registered[path] = append(registered[path], r.Method)
+		sort.Strings(registered[path])
+
+		// Every route answers, successfully or with the envelope, with a documented status
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(r.Method, pathParam.ReplaceAllString(r.Path, "unknown"), nil))
+		op, ok := doc.Paths[path][strings.ToLower(r.Method)]
+		if !assert.True(t, ok, "%s %s should be documented", r.Method, path) {
+			continue
+		}
+		assert.Contains(t, op.Responses, strconv.Itoa(rec.Code), "%s %s should document status %d", r.Method, path, rec.Code)
+
+		if rec.Code >= http.StatusBadRequest {
+			apiErr := api.Error{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr), "%s %s should send the error envelope", r.Method, path)
+			assert.NotEmpty(t, apiErr.Code)
+			assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), apiErr.RequestID, "should send the request ID")
+		}
+	}
+	assert.Equal(t, doc.Methods(), registered, "should document the registered routes only")
+}
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:apierrors?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	h := &Handler{Model: &models.Model{Client: client}}
+	e := echo.New()
+	e.HTTPErrorHandler = api.HandleError
+	h.registerAPI(e)
+
+	m := models.Model{Client: client}
+	tenant, err := m.CreateDefaultTenant()
+	assert.NoError(t, err)
+	_, err = m.CreateEnrollmentToken(tenant.ID, models.EnrollmentTokenSites{}, "Office", "11111111-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(t, err)
+	err = client.EnrollmentToken.Update().SetActive(false).Exec(context.Background())
+	assert.NoError(t, err)
+	_, err = m.CreateEnrollmentToken(tenant.ID, models.EnrollmentTokenSites{}, "Windows desktops", "66666666-2222-3333-4444-555555555555", 0, nil, []string{"windows"})
+	assert.NoError(t, err)
+
+	for path, want := range map[string]api.Error{
+		"/api/v1/enroll/unknown/install":                                                   {Code: "token_not_found", Message: "invalid token"},
+		"/api/v1/enroll/11111111-2222-3333-4444-555555555555/install":                      {Code: "token_inactive", Message: "token is inactive"},
+		"/api/v1/enroll/66666666-2222-3333-4444-555555555555/config?platform=linux":        {Code: "platform_not_allowed", Message: "the token can't enroll agents of this platform"},
+		"/api/v1/enroll/66666666-2222-3333-4444-555555555555/install?platform=macos-arm64": {Code: "platform_not_allowed", Message: "the token can't enroll agents of this platform"},
+		"/api/v1/unknown": {Code: "not_found", Message: "Not Found"},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		got := api.Error{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, want, got, path)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/account_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// ListAPITokens shows the API tokens of the user
+func (h *Handler) ListAPITokens(c echo.Context) error {
+	return h.renderAPITokens(c, "")
+}
+
+// CreateAPIToken creates an API token for the user and shows its value
+func (h *Handler) CreateAPIToken(c echo.Context) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if username == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.username_empty"), true))
+	}
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "api_tokens.name_required"), true))
+	}
+
+	days, err := strconv.Atoi(c.FormValue("expiration"))
+	if err != nil || days < 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "api_tokens.invalid_expiration"), true))
+	}
+
+	var expiresAt *time.Time
+	if days > 0 {
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	value, token, err := h.Model.CreateAPIToken(username, name, expiresAt)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	log.Printf("[INFO]: user %s has created the API token %d (%s)", username, token.ID, name)
+
+	return h.renderAPITokens(c, value)
+}
+
+// DeleteAPIToken revokes an API token of the user
+func (h *Handler) DeleteAPIToken(c echo.Context) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if username == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.username_empty"), true))
+	}
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "api_tokens.invalid_id"), true))
+	}
+
+	if err := h.Model.DeleteAPIToken(username, tokenID); err != nil {
+		return RenderModelError(c, err)
+	}
+	log.Printf("[INFO]: user %s has revoked the API token %d", username, tokenID)
+
+	return h.renderAPITokens(c, "")
+}
+
+func (h *Handler) renderAPITokens(c echo.Context, newTokenValue string) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if username == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.username_empty"), true))
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := h.Model.GetAPITokens(username)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, account_views.MyAccountIndex("| API Tokens", account_views.APITokens(c, tokens, newTokenValue, commonInfo), commonInfo))
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+const (
+	// apiUsageFlushInterval is how often the API requests counted in memory are added to the stored usage
+	apiUsageFlushInterval = time.Minute
+
+	// apiRateLimiterExpiration is how long the buckets of the tokens and tenants that make no requests are kept
+	apiRateLimiterExpiration = 10 * time.Minute
+)
+
+// apiUsagePeriods are the days of usage that can be shown, the first one by default
+var apiUsagePeriods = []int{30, 7, 90}
+
+type apiUsageKey struct {
+	day      time.Time
+	tokenID  int
+	userID   string
+	tenantID int
+}
+
+// apiUsageCounter counts the API requests in memory until the API usage job stores them, so the requests
+// don't write to the database
+type apiUsageCounter struct {
+	mu     sync.Mutex
+	counts map[apiUsageKey]*models.APIUsage
+}
+
+func newAPIUsageCounter() *apiUsageCounter {
+	return &apiUsageCounter{counts: map[apiUsageKey]*models.APIUsage{}}
+}
+
+func (u *apiUsageCounter) add(tokenID int, userID string, tenantID int, limited bool) {
+	key := apiUsageKey{day: time.Now().UTC().Truncate(24 * time.Hour), tokenID: tokenID, userID: userID, tenantID: tenantID}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage, ok := u.counts[key]
+	if !ok {
+		usage = &models.APIUsage{Day: key.day, TokenID: tokenID, UserID: userID, TenantID: tenantID}
+		u.counts[key] = usage
+	}
+	usage.Requests++
+	if limited {
+		usage.Limited++
+	}
+}
+
+// take returns the requests counted and starts counting again
+func (u *apiUsageCounter) take() []models.APIUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage := []models.APIUsage{}
+	for _, c := range u.counts {
+		usage = append(usage, *c)
+	}
+	u.counts = map[apiUsageKey]*models.APIUsage{}
+	return usage
+}
+
+// restore counts again the requests taken that couldn't be stored
+func (u *apiUsageCounter) restore(usage []models.APIUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, r := range usage {
+		key := apiUsageKey{day: r.Day, tokenID: r.TokenID, userID: r.UserID, tenantID: r.TenantID}
+		if c, ok := u.counts[key]; ok {
+			c.Requests += r.Requests
+			c.Limited += r.Limited
+		} else {
+			u.counts[key] = &r
+		}
+	}
+}
+
+// StartAPIUsageJob stores every minute the API requests counted since the last run
+func (h *Handler) StartAPIUsageJob() error {
+	var err error
+
+	h.APIUsageJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			apiUsageFlushInterval,
+		),
+		gocron.NewTask(h.flushAPIUsage),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the API usage job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// flushAPIUsage adds the counted requests to the stored usage, they're counted again if they can't be stored
+func (h *Handler) flushAPIUsage() {
+	usage := h.apiUsage.take()
+	if len(usage) == 0 {
+		return
+	}
+
+	if err := h.Model.AddAPIUsage(usage); err != nil {
+		log.Printf("[ERROR]: could not store the usage of the API, reason: %v", err)
+		h.apiUsage.restore(usage)
+	}
+}
+
+// APIUsage shows the rate limits of the API and the requests made by each tenant and API token
+func (h *Handler) APIUsage(c echo.Context) error {
+	if c.Request().Method != http.MethodPost {
+		return h.renderAPIUsage(c, "")
+	}
+
+	current, err := h.Model.GetAPIRateLimits()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	limits := models.APIRateLimits{}
+	for field, value := range map[string]*int{
+		"token-rate":   &limits.TokenRate,
+		"token-burst":  &limits.TokenBurst,
+		"tenant-rate":  &limits.TenantRate,
+		"tenant-burst": &limits.TenantBurst,
+	} {
+		n, err := strconv.Atoi(c.FormValue(field))
+		if err != nil || n < 0 {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "api_usage.invalid_limit"), true))
+		}
+		*value = n
+	}
+
+	if err := h.Model.SaveAPIRateLimits(limits); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	if h.AuthLogger != nil {
+		uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+		h.AuthLogger.Printf("user %s has changed the API rate limits from %+v to %+v from %s", uid, current, limits, c.RealIP())
+	}
+
+	return h.renderAPIUsage(c, i18n.T(c.Request().Context(), "api_usage.saved"))
+}
+
+// APIUsageCSV sends the requests of each API token to each tenant by day as a CSV file
+func (h *Handler) APIUsageCSV(c echo.Context) error {
+	days := apiUsagePeriod(c)
+
+	// The requests of the last minute are included
+	h.flushAPIUsage()
+
+	entries, err := h.Model.GetAPIUsage(time.Now().AddDate(0, 0, -days+1))
+	if err != nil {
+		log.Printf("[ERROR]: could not get the usage of the API, reason: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "api_usage.could_not_get"))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="openuem-api-usage-%s.csv"`, time.Now().Format("20060102")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write([]string{"day", "tenant_id", "tenant", "token_id", "token", "user", "requests", "limited"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := w.Write([]string{e.Day.Format("2006-01-02"), strconv.Itoa(e.TenantID), e.TenantName, strconv.Itoa(e.TokenID), e.TokenName, e.UserID, strconv.Itoa(e.Requests), strconv.Itoa(e.Limited)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (h *Handler) renderAPIUsage(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	limits, err := h.Model.GetAPIRateLimits()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	days := apiUsagePeriod(c)
+	h.flushAPIUsage()
+
+	entries, err := h.Model.GetAPIUsage(time.Now().AddDate(0, 0, -days+1))
+	if err != nil {
+		log.Printf("[ERROR]: could not get the usage of the API, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "api_usage.could_not_get"), false))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.APIUsageIndex(" | API usage",
+		admin_views.APIUsage(c, limits, days, apiUsagePeriods, models.APIUsageByTenant(entries), models.APIUsageByToken(entries), successMessage, agentsExists, serversExists, commonInfo),
+		commonInfo))
+}
+
+// apiUsagePeriod returns the days of usage requested, the default period if it's not one of the periods shown
+func apiUsagePeriod(c echo.Context) int {
+	days, err := strconv.Atoi(c.QueryParam("days"))
+	if err != nil || !slices.Contains(apiUsagePeriods, days) {
+		return apiUsagePeriods[0]
+	}
+	return days
+}
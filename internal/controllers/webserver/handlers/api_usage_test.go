@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIUsage(t *testing.T) {
+	at := newAPIAgentsTest(t)
+	assert.NoError(t, at.h.Model.SaveAPIRateLimits(models.APIRateLimits{TokenRate: 1, TokenBurst: 3}))
+
+	path := fmt.Sprintf("/api/v1/tenants/%s/agents", at.commonInfo.TenantID)
+	for range 4 {
+		at.get(path, at.token)
+	}
+	assert.Equal(t, http.StatusNotFound, at.get("/api/v1/agents/unknown", at.token).Code)
+	assert.Equal(t, http.StatusUnauthorized, at.get(path, "").Code)
+
+	at.h.flushAPIUsage()
+
+	entries, err := at.h.Model.GetAPIUsage(time.Now())
+	assert.NoError(t, err)
+
+	tenantID, _ := strconv.Atoi(at.commonInfo.TenantID)
+	byTenant := models.APIUsageByTenant(entries)
+	if assert.Len(t, byTenant, 2, "should count the requests that were not made to a tenant apart") {
+		assert.Equal(t, models.APIUsageTotal{ID: tenantID, Name: "Tenant", Requests: 3}, byTenant[0])
+		assert.Equal(t, models.APIUsageTotal{ID: 0, Requests: 2, Limited: 1}, byTenant[1], "should count the requests limited before the tenant was checked apart")
+	}
+
+	byToken := models.APIUsageByToken(entries)
+	if assert.Len(t, byToken, 1, "should not count the requests without a token") {
+		assert.Equal(t, "CMDB", byToken[0].Name)
+		assert.Equal(t, "operator", byToken[0].UserID)
+		assert.Equal(t, 5, byToken[0].Requests)
+		assert.Equal(t, 1, byToken[0].Limited, "should count the rate limited requests")
+	}
+
+	at.get(path, at.token)
+	at.h.flushAPIUsage()
+	entries, err = at.h.Model.GetAPIUsage(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, models.APIUsageByToken(entries)[0].Requests, "should add the requests to the stored usage")
+}
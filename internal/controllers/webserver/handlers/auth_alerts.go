@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// authAlertsInterval is how often the authentication events are evaluated
+const authAlertsInterval = 5 * time.Minute
+
+// authAlertsShown is how many of the latest alerts are shown
+const authAlertsShown = 50
+
+// recordAuthEvent saves an authentication event for the alerts, a login must not fail if it can't be saved
+func (h *Handler) recordAuthEvent(c echo.Context, eventType, userID string) {
+	if err := h.Model.SaveAuthEvent(eventType, userID, c.RealIP()); err != nil {
+		log.Printf("[ERROR]: could not save the authentication event %s of user %s, reason: %v", eventType, userID, err)
+	}
+}
+
+// StartAuthAlertsJob evaluates the authentication alert rules of the tenants periodically
+func (h *Handler) StartAuthAlertsJob() error {
+	var err error
+
+	lastRun := time.Now().Add(-authAlertsInterval)
+
+	h.AuthAlertsJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			authAlertsInterval,
+		),
+		gocron.NewTask(
+			func() {
+				now := time.Now()
+				h.evaluateAuthAlerts(lastRun)
+				lastRun = now
+
+				if err := h.Model.DeleteOldAuthEvents(); err != nil {
+					log.Printf("[ERROR]: could not delete the old authentication events, reason: %v", err)
+				}
+
+				if err := h.Model.DeleteOldLoginAttempts(); err != nil {
+					log.Printf("[ERROR]: could not delete the old login attempts, reason: %v", err)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the authentication alerts job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// evaluateAuthAlerts raises the alerts of the tenants that have them enabled and notifies the new ones
+// to the admins of the tenant
+func (h *Handler) evaluateAuthAlerts(since time.Time) {
+	tenants, err := h.Model.GetAuthAlertTenants()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the authentication alerts settings, reason: %v", err)
+		return
+	}
+
+	for tenantID, s := range tenants {
+		alerts, err := h.Model.EvaluateAuthAlerts(tenantID, s, since)
+		if err != nil {
+			log.Printf("[ERROR]: could not evaluate the authentication alerts of tenant %d, reason: %v", tenantID, err)
+		}
+		for _, a := range alerts {
+			h.AuthLogger.Printf("authentication alert %s raised for %s in tenant %d", a.Rule, a.Subject, tenantID)
+			h.notifyAuthAlert(tenantID, a)
+		}
+	}
+}
+
+func (h *Handler) notifyAuthAlert(tenantID int, a *openuem_ent.AuthAlert) {
+	emails, err := h.Model.GetTenantAdminEmails(tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the admins of tenant %d to notify the authentication alert, reason: %v", tenantID, err)
+		return
+	}
+
+	server := fmt.Sprintf("%s:%s", h.ServerName, h.ConsolePort)
+	if h.ReverseProxyServer != "" {
+		server = h.ReverseProxyServer
+	}
+
+	for _, email := range emails {
+		notification := openuem_nats.Notification{
+			To:               email,
+			Subject:          "Authentication alert",
+			MessageTitle:     "OpenUEM | Authentication alert",
+			MessageText:      models.AuthAlertDescription(a) + ". Acknowledge the alert once it has been reviewed so it isn't notified again",
+			MessageGreeting:  "Suspicious authentication activity has been detected",
+			MessageAction:    "Review the alert",
+			MessageActionURL: fmt.Sprintf("https://%s/tenant/%d/admin/auth-alerts", server, tenantID),
+		}
+
+		if err := h.publishEmailNotification(notification); err != nil {
+			log.Printf("[ERROR]: could not notify the authentication alert %d to %s, reason: %v", a.ID, email, err)
+		}
+	}
+}
+
+// AuthAlerts shows the alerts of the tenant, its rules and the authentication events of its members
+func (h *Handler) AuthAlerts(c echo.Context) error {
+	return h.renderAuthAlerts(c, "")
+}
+
+func (h *Handler) SaveAuthAlertSettings(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	accountFailures, err := strconv.Atoi(c.FormValue("account-failures"))
+	if err != nil || accountFailures < 1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "auth_alerts.invalid_threshold"), true))
+	}
+
+	ipFailures, err := strconv.Atoi(c.FormValue("ip-failures"))
+	if err != nil || ipFailures < 1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "auth_alerts.invalid_threshold"), true))
+	}
+
+	s := models.AuthAlertSettings{
+		Enabled:         c.FormValue("enabled") == "on",
+		AccountFailures: accountFailures,
+		IPFailures:      ipFailures,
+		NewNetworks:     c.FormValue("new-networks") == "on",
+	}
+	if err := h.Model.SaveAuthAlertSettings(tenantID, s); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "auth_alerts.could_not_save", err.Error()), true))
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	h.AuthLogger.Printf("user %s has changed the authentication alerts of tenant %d to %+v from %s", uid, tenantID, s, c.RealIP())
+
+	return h.renderAuthAlerts(c, i18n.T(c.Request().Context(), "auth_alerts.saved"))
+}
+
+func (h *Handler) AcknowledgeAuthAlert(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	alertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return resourceNotFound(c)
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if err := h.Model.AcknowledgeAuthAlert(tenantID, alertID, uid); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.AuthLogger.Printf("user %s has acknowledged the authentication alert %d of tenant %d from %s", uid, alertID, tenantID, c.RealIP())
+
+	return h.renderAuthAlerts(c, i18n.T(c.Request().Context(), "auth_alerts.acknowledged"))
+}
+
+func (h *Handler) renderAuthAlerts(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	f := filters.AuthEventFilter{
+		Type:      c.FormValue("filterByType"),
+		Username:  c.FormValue("filterByUsername"),
+		IPAddress: c.FormValue("filterByIPAddress"),
+		From:      c.FormValue("filterByDateFrom"),
+		To:        c.FormValue("filterByDateTo"),
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), "", "", "", itemsPerPage)
+
+	p.NItems, err = h.Model.CountAuthEvents(tenantID, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	events, err := h.Model.GetAuthEventsByPage(tenantID, p, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	alerts, err := h.Model.GetAuthAlerts(tenantID, authAlertsShown)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	settings, err := h.Model.GetAuthAlertSettings(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.AuthAlertsIndex(" | Authentication Alerts",
+		admin_views.AuthAlerts(c, p, f, settings, alerts, events, successMessage, agentsExists, serversExists, itemsPerPage, commonInfo),
+		commonInfo))
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
@@ -12,14 +13,8 @@ import (
 )
 
 func (h *Handler) AuthenticationSettings(c echo.Context) error {
-	var err error
 	var successMessage string
 
-	commonInfo, err := h.GetCommonInfo(c)
-	if err != nil {
-		return err
-	}
-
 	if c.Request().Method == "POST" {
 		oidcProvider := c.FormValue("authentication-oidc-provider")
 		oidcServer := c.FormValue("authentication-oidc-server")
@@ -48,7 +43,20 @@ func (h *Handler) AuthenticationSettings(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.could_not_parse_use_passwords"), true))
 		}
 
-		if !useCertificates && !useOIDC {
+		authOrder := c.FormValue("authentication-order")
+		if authOrder == "" {
+			authOrder = strings.Join(auth.DefaultAuthOrder, ",")
+		}
+		if !auth.IsValidAuthOrder(authOrder) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.order_not_valid"), true))
+		}
+
+		current, err := h.Model.GetAuthenticationSettings()
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.could_not_get_settings", err.Error()), true))
+		}
+
+		if !useCertificates && !useOIDC && !current.UseLDAP {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.at_least_one_auth_method"), true))
 		}
 
@@ -102,13 +110,22 @@ func (h *Handler) AuthenticationSettings(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.role_required"), true))
 		}
 
-		if err := h.Model.SaveAuthenticationSettings(useCertificates, allowRegister, useOIDC, oidcProvider, oidcServer, oidcClientID, oidcRoleAdmin, oidcRoleOperator, oidcRoleUser, autoCreate, autoApprove, usePasswd); err != nil {
+		if err := h.Model.SaveAuthenticationSettings(useCertificates, allowRegister, useOIDC, oidcProvider, oidcServer, oidcClientID, oidcRoleAdmin, oidcRoleOperator, oidcRoleUser, autoCreate, autoApprove, usePasswd, authOrder); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.settings_not_saved", err.Error()), true))
 		}
 
 		successMessage = i18n.T(c.Request().Context(), "authentication.settings_saved")
 	}
 
+	return h.renderAuthenticationSettings(c, successMessage)
+}
+
+func (h *Handler) renderAuthenticationSettings(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
 	settings, err := h.Model.GetAuthenticationSettings()
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.could_not_get_settings", err.Error()), true))
@@ -126,3 +143,40 @@ func (h *Handler) AuthenticationSettings(c echo.Context) error {
 
 	return RenderView(c, admin_views.AuthenticationSettingsIndex(" | Authentication Settings", admin_views.AuthenticationSettings(c, settings, agentsExists, serversExists, commonInfo, successMessage), commonInfo))
 }
+
+// SavePasswordPolicy stores the requirements of the passwords of the local accounts, they're checked
+// when the passwords are changed
+func (h *Handler) SavePasswordPolicy(c echo.Context) error {
+	p := auth.PasswordPolicy{}
+
+	var err error
+	if p.MinLength, err = strconv.Atoi(c.FormValue("password-min-length")); err != nil || p.MinLength < auth.MinPasswordMinLength || p.MinLength > auth.PasswordMaxLength {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy.min_length_not_valid", auth.MinPasswordMinLength, auth.PasswordMaxLength), true))
+	}
+
+	if p.History, err = strconv.Atoi(c.FormValue("password-history")); err != nil || p.History < 0 || p.History > auth.MaxPasswordHistory {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy.history_not_valid", auth.MaxPasswordHistory), true))
+	}
+
+	if p.MaxAgeDays, err = strconv.Atoi(c.FormValue("password-max-age")); err != nil || p.MaxAgeDays < 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy.max_age_not_valid"), true))
+	}
+
+	for field, value := range map[string]*bool{
+		"password-require-uppercase": &p.RequireUppercase,
+		"password-require-lowercase": &p.RequireLowercase,
+		"password-require-digit":     &p.RequireDigit,
+		"password-require-symbol":    &p.RequireSymbol,
+		"password-disallow-common":   &p.DisallowCommon,
+	} {
+		if *value, err = strconv.ParseBool(c.FormValue(field)); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy.could_not_parse_requirement"), true))
+		}
+	}
+
+	if err := h.Model.SavePasswordPolicy(p); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.settings_not_saved", err.Error()), true))
+	}
+
+	return h.renderAuthenticationSettings(c, i18n.T(c.Request().Context(), "authentication.settings_saved"))
+}
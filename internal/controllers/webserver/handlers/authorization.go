@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+)
+
+// RequireRole returns a forbidden error unless the current user has at least the role in the
+// tenant of the request. Global admin routes have no tenant or use the tenant param for the
+// tenant being edited (e.g. /admin/tenants/:tenant), so they're checked against the main tenant
+func (h *Handler) RequireRole(c echo.Context, role models.UserTenantRole) error {
+	ctx := c.Request().Context()
+
+	username := h.SessionManager.Manager.GetString(ctx, "uid")
+	if username == "" {
+		return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.no_access"))
+	}
+
+	tenantID, isMainTenant, err := h.authorizationTenantID(c)
+	if err != nil {
+		return err
+	}
+
+	userRole, err := h.Model.GetUserRoleInTenant(username, tenantID)
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.no_access"))
+		}
+		return ModelHTTPError(c, err)
+	}
+
+	if !userRole.AtLeast(role) {
+		switch {
+		case isMainTenant:
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.main_admin_required"))
+		case role == models.UserTenantRoleAdmin:
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.admin_required"))
+		case role == models.UserTenantRoleOperator:
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.operator_required"))
+		default:
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(ctx, "tenants.no_access"))
+		}
+	}
+
+	return nil
+}
+
+// RequireTenantResource returns a not found error if the resource doesn't belong to the tenant of
+// the request, so IDs from other tenants can't be told apart from IDs that don't exist
+func (h *Handler) RequireTenantResource(c echo.Context, resourceTenantID int) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil || tenantID != resourceTenantID {
+		return resourceNotFound(c)
+	}
+	return nil
+}
+
+// authorizationTenantID returns the tenant whose role applies to the request and
+// whether it's the main tenant because the route is a global one. The pages without
+// a tenant in the URL use the tenant of the session checked by TenantAccessMiddleware
+func (h *Handler) authorizationTenantID(c echo.Context) (int, bool, error) {
+	if tID := c.Param("tenant"); strings.HasPrefix(c.Path(), "/tenant/:tenant") && tID != "-1" {
+		tenantID, err := strconv.Atoi(tID)
+		if err != nil {
+			return 0, false, echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+		}
+		return tenantID, false, nil
+	}
+
+	if tenantID, ok := c.Get(tenantIDContextKey).(int); ok && !strings.HasPrefix(c.Path(), "/admin") {
+		return tenantID, false, nil
+	}
+
+	mainTenantID, err := h.getMainTenantID()
+	if err != nil {
+		return 0, false, ModelHTTPError(c, err)
+	}
+	return mainTenantID, true, nil
+}
+
+func resourceNotFound(c echo.Context) error {
+	return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "tenants.resource_not_found"))
+}
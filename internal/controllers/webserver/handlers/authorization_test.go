@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/controllers/sessions"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type authorizationTest struct {
+	h              *Handler
+	mainTenantID   int
+	secondTenantID int
+	secondTokenID  int
+}
+
+// newAuthorizationTest creates a main and a second tenant. The admin user is an admin of the main
+// tenant and the operator user is an operator of the second tenant, which has an enrollment token
+func newAuthorizationTest(t *testing.T) *authorizationTest {
+	client := enttest.Open(t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	at := &authorizationTest{
+		h: &Handler{
+			Model:          &models.Model{Client: client},
+			SessionManager: &sessions.SessionManager{Manager: scs.New()},
+		},
+	}
+
+	mainTenant, err := client.Tenant.Create().SetDescription("Main").SetIsDefault(true).Save(context.Background())
+	assert.NoError(t, err)
+	at.mainTenantID = mainTenant.ID
+
+	secondTenant, err := client.Tenant.Create().SetDescription("Second").Save(context.Background())
+	assert.NoError(t, err)
+	at.secondTenantID = secondTenant.ID
+
+	for uid, membership := range map[string]struct {
+		tenantID int
+		role     models.UserTenantRole
+	}{
+		"admin":    {at.mainTenantID, models.UserTenantRoleAdmin},
+		"operator": {at.secondTenantID, models.UserTenantRoleOperator},
+	} {
+		err := client.User.Create().SetID(uid).SetName(uid).SetEmail(uid + "@example.com").SetCreated(time.Now()).Exec(context.Background())
+		assert.NoError(t, err)
+		err = at.h.Model.AssignUserToTenant(uid, membership.tenantID, membership.role, true)
+		assert.NoError(t, err)
+	}
+
+	token, err := at.h.Model.CreateEnrollmentToken(at.secondTenantID, models.EnrollmentTokenSites{}, "Second office", "11111111-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(t, err)
+	at.secondTokenID = token.ID
+
+	return at
+}
+
+// context returns a request for the route of the user with the given session
+func (at *authorizationTest) context(t *testing.T, uid, method, path string, params map[string]string) echo.Context {
+	req := httptest.NewRequest(method, "/", nil)
+	ctx, err := at.h.SessionManager.Manager.Load(req.Context(), "")
+	assert.NoError(t, err)
+	at.h.SessionManager.Manager.Put(ctx, "uid", uid)
+
+	c := echo.New().NewContext(req.WithContext(ctx), httptest.NewRecorder())
+	c.SetPath(path)
+
+	names, values := []string{}, []string{}
+	for name, value := range params {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c
+}
+
+func assertHTTPError(t *testing.T, code int, err error, msg string) {
+	he, ok := err.(*echo.HTTPError)
+	if assert.True(t, ok, msg) {
+		assert.Equal(t, code, he.Code, msg)
+	}
+}
+
+func TestRequireTenantResource(t *testing.T) {
+	at := newAuthorizationTest(t)
+	c := at.context(t, "operator", http.MethodGet, "/tenant/:tenant/admin/enrollment", map[string]string{"tenant": strconv.Itoa(at.secondTenantID)})
+
+	assert.NoError(t, at.h.RequireTenantResource(c, at.secondTenantID), "should allow resources of the tenant")
+	assertHTTPError(t, http.StatusNotFound, at.h.RequireTenantResource(c, at.mainTenantID), "should not find resources of another tenant")
+}
+
+func TestRequireRole(t *testing.T) {
+	at := newAuthorizationTest(t)
+	second := map[string]string{"tenant": strconv.Itoa(at.secondTenantID)}
+
+	c := at.context(t, "operator", http.MethodGet, "/tenant/:tenant/admin/enrollment", second)
+	assert.NoError(t, at.h.RequireRole(c, models.UserTenantRoleOperator), "operator should have operator role")
+	assertHTTPError(t, http.StatusForbidden, at.h.RequireRole(c, models.UserTenantRoleAdmin), "operator should not have admin role")
+
+	c = at.context(t, "admin", http.MethodGet, "/tenant/:tenant/admin/enrollment", second)
+	assertHTTPError(t, http.StatusForbidden, at.h.RequireRole(c, models.UserTenantRoleUser), "should not have a role in tenants the user isn't a member of")
+
+	c = at.context(t, "admin", http.MethodGet, "/admin/tenants/:tenant", second)
+	assert.NoError(t, at.h.RequireRole(c, models.UserTenantRoleAdmin), "global routes should be checked against the main tenant")
+
+	c = at.context(t, "operator", http.MethodGet, "/admin/branding", nil)
+	assertHTTPError(t, http.StatusForbidden, at.h.RequireRole(c, models.UserTenantRoleAdmin), "only main tenant admins should reach global routes")
+
+	c = at.context(t, "", http.MethodGet, "/admin/branding", nil)
+	assertHTTPError(t, http.StatusForbidden, at.h.RequireRole(c, models.UserTenantRoleUser), "should require a session")
+}
+
+func TestGetCurrentUserTenantRole(t *testing.T) {
+	at := newAuthorizationTest(t)
+	second := map[string]string{"tenant": strconv.Itoa(at.secondTenantID)}
+
+	role, err := at.h.GetCurrentUserTenantRole(at.context(t, "operator", http.MethodGet, "/tenant/:tenant/agents", second))
+	assert.NoError(t, err)
+	assert.Equal(t, "operator", role)
+
+	role, err = at.h.GetCurrentUserTenantRole(at.context(t, "admin", http.MethodGet, "/tenant/:tenant/agents", second))
+	assert.NoError(t, err)
+	assert.Empty(t, role, "should not have a role in tenants the user isn't a member of")
+
+	role, err = at.h.GetCurrentUserTenantRole(at.context(t, "admin", http.MethodGet, "/admin/branding", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", role, "global routes should use the role in the main tenant like RequireRole")
+}
+
+func TestEnrollmentTokenFromAnotherTenant(t *testing.T) {
+	at := newAuthorizationTest(t)
+	params := map[string]string{"tenant": strconv.Itoa(at.mainTenantID), "id": strconv.Itoa(at.secondTokenID)}
+
+	c := at.context(t, "admin", http.MethodDelete, "/tenant/:tenant/admin/enrollment/:id", params)
+	assertHTTPError(t, http.StatusNotFound, at.h.DeleteEnrollmentToken(c), "should not delete tokens of another tenant")
+
+	c = at.context(t, "admin", http.MethodPost, "/tenant/:tenant/admin/enrollment/:id/toggle", params)
+	assertHTTPError(t, http.StatusNotFound, at.h.ToggleEnrollmentToken(c), "should not toggle tokens of another tenant")
+
+	c = at.context(t, "admin", http.MethodGet, "/tenant/:tenant/admin/enrollment/:id/command", params)
+	assertHTTPError(t, http.StatusNotFound, at.h.GetInstallCommand(c), "should not show install commands of tokens of another tenant")
+
+	token, err := at.h.Model.GetEnrollmentTokenByID(at.secondTokenID)
+	assert.NoError(t, err, "token should not have been deleted")
+	assert.True(t, token.Active, "token should not have been toggled")
+}
+
+func TestTenantMemberFromAnotherTenant(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	params := map[string]string{"tenant": strconv.Itoa(at.secondTenantID), "uid": "admin"}
+	c := at.context(t, "operator", http.MethodDelete, "/tenant/:tenant/admin/members/:uid", params)
+	assertHTTPError(t, http.StatusForbidden, at.h.RemoveTenantMember(c), "operators should not remove members")
+
+	params = map[string]string{"tenant": strconv.Itoa(at.mainTenantID), "uid": "operator"}
+	c = at.context(t, "admin", http.MethodDelete, "/tenant/:tenant/admin/members/:uid", params)
+	assertHTTPError(t, http.StatusNotFound, at.h.RemoveTenantMember(c), "should not find members of another tenant")
+
+	isMember, err := at.h.Model.UserHasAccessToTenant("operator", at.secondTenantID)
+	assert.NoError(t, err)
+	assert.True(t, isMember, "member of the other tenant should not have been removed")
+}
+
+func TestMainTenantIDCache(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	queries := 0
+	at.h.Model.Client.Tenant.Intercept(openuem_ent.InterceptFunc(func(next openuem_ent.Querier) openuem_ent.Querier {
+		return openuem_ent.QuerierFunc(func(ctx context.Context, q openuem_ent.Query) (openuem_ent.Value, error) {
+			queries++
+			return next.Query(ctx, q)
+		})
+	}))
+
+	next := func(c echo.Context) error { return nil }
+	request := func() error {
+		return at.h.MainTenantAdminMiddleware(next)(at.context(t, "admin", http.MethodGet, "/admin/branding", nil))
+	}
+
+	assert.NoError(t, request(), "main tenant admin should reach global routes")
+	assert.NoError(t, request(), "main tenant admin should reach global routes")
+	assert.Equal(t, 1, queries, "should query the main tenant only on the first request")
+
+	at.h.invalidateMainTenantID()
+	assert.NoError(t, request())
+	assert.Equal(t, 2, queries, "should query the main tenant again after invalidating it")
+
+	// Another replica may have deleted the main tenant
+	at.h.mainTenantID.Store(int64(at.secondTenantID))
+	assert.NoError(t, request(), "should check again a stale main tenant")
+	assert.Equal(t, int64(at.mainTenantID), at.h.mainTenantID.Load(), "should cache the current main tenant")
+}
+
+func TestMainTenantIDCacheIsKeptForNonAdmins(t *testing.T) {
+	at := newAuthorizationTest(t)
+	assert.NoError(t, at.h.Model.AssignUserToTenant("operator", at.mainTenantID, models.UserTenantRoleUser, false))
+
+	mainTenantID, err := at.h.getMainTenantID()
+	assert.NoError(t, err)
+
+	for _, uid := range []string{"operator", "unknown"} {
+		isMainAdmin, err := at.h.checkMainTenantAdmin(uid)
+		assert.False(t, isMainAdmin, uid)
+		assert.Equal(t, int64(mainTenantID), at.h.mainTenantID.Load(), "a check of %s should keep the cached main tenant", uid)
+		if uid == "operator" {
+			assert.NoError(t, err, "a user of the main tenant isn't an admin")
+		}
+	}
+}
+
+func TestMainTenantAdminCheckOfNonAdmins(t *testing.T) {
+	at := newAuthorizationTest(t)
+	assert.NoError(t, at.h.Model.AssignUserToTenant("operator", at.mainTenantID, models.UserTenantRoleUser, false))
+
+	queries := 0
+	at.h.Model.Client.Tenant.Intercept(openuem_ent.InterceptFunc(func(next openuem_ent.Querier) openuem_ent.Querier {
+		return openuem_ent.QuerierFunc(func(ctx context.Context, q openuem_ent.Query) (openuem_ent.Value, error) {
+			queries++
+			return next.Query(ctx, q)
+		})
+	}))
+
+	// The header checks the user on every page
+	for i := 0; i < 3; i++ {
+		c := at.context(t, "operator", http.MethodGet, "/tenant/:tenant/computers", nil)
+		isMainAdmin, err := at.h.isMainTenantAdmin(c, "operator")
+		assert.NoError(t, err)
+		assert.False(t, isMainAdmin)
+		_, _ = at.h.isMainTenantAdmin(c, "operator")
+	}
+	assert.Equal(t, 1, queries, "the checks of a user who isn't an admin should not query the main tenant again")
+	assert.Equal(t, int64(at.mainTenantID), at.h.mainTenantID.Load())
+}
+
+func TestMainTenantAdminIsCheckedOncePerRequest(t *testing.T) {
+	at := newAuthorizationTest(t)
+	c := at.context(t, "admin", http.MethodGet, "/admin/branding", nil)
+
+	isMainAdmin, err := at.h.isMainTenantAdmin(c, "admin")
+	assert.NoError(t, err)
+	assert.True(t, isMainAdmin)
+
+	assert.NoError(t, at.h.Model.UpdateUserTenantRole("admin", at.mainTenantID, models.UserTenantRoleUser))
+	isMainAdmin, err = at.h.isMainTenantAdmin(c, "admin")
+	assert.NoError(t, err)
+	assert.True(t, isMainAdmin, "should remember the check until the request ends")
+
+	ClearMainTenantAdminCache(c, "admin")
+	isMainAdmin, err = at.h.isMainTenantAdmin(c, "admin")
+	assert.NoError(t, err)
+	assert.False(t, isMainAdmin, "should check the role again once the cache is cleared")
+
+	isMainAdmin, err = at.h.isMainTenantAdmin(at.context(t, "admin", http.MethodGet, "/admin/branding", nil), "admin")
+	assert.NoError(t, err)
+	assert.False(t, isMainAdmin, "should not remember the checks of other requests")
+}
+
+func TestRequireFeature(t *testing.T) {
+	at := newAuthorizationTest(t)
+	second := map[string]string{"tenant": strconv.Itoa(at.secondTenantID)}
+
+	next := func(c echo.Context) error { return nil }
+	request := func() error {
+		c := at.context(t, "operator", http.MethodGet, "/tenant/:tenant/saml", second)
+		return at.h.RequireFeature(models.FeatureSAML)(next)(c)
+	}
+
+	assertHTTPError(t, http.StatusNotFound, request(), "should not find disabled features")
+
+	assert.NoError(t, at.h.Model.SetFeatureEnabled(at.mainTenantID, models.FeatureSAML, true))
+	assertHTTPError(t, http.StatusNotFound, request(), "should check the feature in the current tenant")
+
+	assert.NoError(t, at.h.Model.SetFeatureEnabled(at.secondTenantID, models.FeatureSAML, true))
+	assert.NoError(t, request(), "should reach enabled features")
+}
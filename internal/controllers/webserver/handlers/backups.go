@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// databaseBackupsHistory is how many backups are shown in the history
+const databaseBackupsHistory = 20
+
+// databaseBackupScheduleInterval is how often the job checks if the scheduled backup is due
+const databaseBackupScheduleInterval = 5 * time.Minute
+
+// DatabaseBackups shows the backup settings and the history of the backups
+func (h *Handler) DatabaseBackups(c echo.Context) error {
+	return h.renderDatabaseBackups(c, "")
+}
+
+func (h *Handler) renderDatabaseBackups(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.Model.GetBackupSettings()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	backups, err := h.Model.GetDatabaseBackups(databaseBackupsHistory)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	repos, err := h.Model.GetSoftwareRepos(-1)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	form := admin_views.DatabaseBackupsForm{
+		Enabled:      s.Enabled,
+		Hour:         s.Hour,
+		Retention:    s.Retention,
+		RepoID:       s.RepoID,
+		NotifyEmails: strings.Join(s.NotifyEmails, ", "),
+		Dir:          h.Model.Backups.Dir,
+	}
+
+	return RenderView(c, admin_views.DatabaseBackupsIndex(" | Backups", admin_views.DatabaseBackups(c, form, backups, repos, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// DatabaseBackupsHistory renders the history only, it's polled while a backup is running
+func (h *Handler) DatabaseBackupsHistory(c echo.Context) error {
+	backups, err := h.Model.GetDatabaseBackups(databaseBackupsHistory)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	repos, err := h.Model.GetSoftwareRepos(-1)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	return RenderView(c, admin_views.DatabaseBackupsHistory(backups, repos, commonInfo))
+}
+
+// SaveDatabaseBackupSettings stores the schedule, retention, destination and failure notifications of the backups
+func (h *Handler) SaveDatabaseBackupSettings(c echo.Context) error {
+	hour, err := strconv.Atoi(c.FormValue("hour"))
+	if err != nil || hour < 0 || hour > 23 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.invalid_hour"), true))
+	}
+
+	retention, err := strconv.Atoi(c.FormValue("retention"))
+	if err != nil || retention < 1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.invalid_retention"), true))
+	}
+
+	repoID := 0
+	if v := c.FormValue("repo"); v != "" {
+		repoID, err = strconv.Atoi(v)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.invalid_repo"), true))
+		}
+	}
+	if repoID == 0 && h.Model.Backups.Dir == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.no_backup_dir"), true))
+	}
+
+	notifyEmails, ok := backupNotifyEmails(c.FormValue("notify_emails"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.invalid_email"), true))
+	}
+
+	s := models.BackupSettings{
+		Enabled:      c.FormValue("enabled") == "on",
+		Hour:         hour,
+		Retention:    retention,
+		RepoID:       repoID,
+		NotifyEmails: notifyEmails,
+	}
+	if err := h.Model.SaveBackupSettings(&s); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return h.renderDatabaseBackups(c, i18n.T(c.Request().Context(), "backups.settings_saved"))
+}
+
+// backupNotifyEmails parses the comma separated recipients of the failure notifications
+func backupNotifyEmails(value string) ([]string, bool) {
+	emails := []string{}
+	for _, email := range strings.Split(value, ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(email); err != nil {
+			return nil, false
+		}
+		emails = append(emails, email)
+	}
+	return emails, true
+}
+
+// StartDatabaseBackup starts a backup now with the current settings
+func (h *Handler) StartDatabaseBackup(c echo.Context) error {
+	s, err := h.Model.GetBackupSettings()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	if err := h.startDatabaseBackup(models.BackupTriggerManual, s); err != nil {
+		if errors.Is(err, models.ErrBackupRunning) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.already_running"), true))
+		}
+		log.Printf("[ERROR]: could not start the database backup, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "backups.could_not_start"), true))
+	}
+
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	log.Printf("[INFO]: user %s has started a database backup", username)
+
+	return h.renderDatabaseBackups(c, i18n.T(c.Request().Context(), "backups.started"))
+}
+
+// startDatabaseBackup records the backup and runs it in a background job
+func (h *Handler) startDatabaseBackup(trigger string, s *models.BackupSettings) error {
+	if s.RepoID == 0 && h.Model.Backups.Dir == "" {
+		return fmt.Errorf("the backups directory is not configured")
+	}
+
+	b, err := h.Model.StartDatabaseBackup(trigger, s.RepoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.TaskScheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+		gocron.NewTask(func() {
+			if err := h.Model.RunDatabaseBackup(context.Background(), b, s); err != nil {
+				log.Printf("[ERROR]: the database backup %d has failed, reason: %v", b.ID, err)
+				return
+			}
+			log.Printf("[INFO]: the database backup %d has finished", b.ID)
+		}),
+	)
+	if err != nil {
+		if failErr := h.Model.FailDatabaseBackup(b.ID, err); failErr != nil {
+			log.Printf("[ERROR]: could not update the database backup %d, reason: %v", b.ID, failErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// StartDatabaseBackupJob starts the scheduled backups, once a day at the configured hour
+func (h *Handler) StartDatabaseBackupJob() error {
+	var err error
+
+	// The backups that were running when the console was stopped will never finish
+	if err := h.Model.FailInterruptedBackups(); err != nil {
+		log.Printf("[ERROR]: could not update the interrupted database backups, reason: %v", err)
+	}
+
+	h.DatabaseBackupJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			databaseBackupScheduleInterval,
+		),
+		gocron.NewTask(
+			func() {
+				h.runScheduledDatabaseBackup(time.Now())
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the database backup job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Handler) runScheduledDatabaseBackup(now time.Time) {
+	s, err := h.Model.GetBackupSettings()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the database backup settings, reason: %v", err)
+		return
+	}
+	if !s.Enabled {
+		return
+	}
+
+	last, err := h.Model.GetLastDatabaseBackup(models.BackupTriggerScheduled)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the last scheduled database backup, reason: %v", err)
+		return
+	}
+
+	lastStartedAt := time.Time{}
+	if last != nil {
+		lastStartedAt = last.StartedAt
+	}
+
+	// The hour of the backup is in the timezone of the global settings
+	dt, err := h.Model.GetDateTimeSettings("-1")
+	if err != nil {
+		log.Printf("[ERROR]: could not get the global date and time settings, reason: %v", err)
+	}
+	if !databaseBackupDue(s.Hour, lastStartedAt, now.In(helpers.LoadTimezone(dt.Timezone))) {
+		return
+	}
+
+	// A manual backup may be running, the scheduled one is tried again in the next run
+	if err := h.startDatabaseBackup(models.BackupTriggerScheduled, s); err != nil && !errors.Is(err, models.ErrBackupRunning) {
+		log.Printf("[ERROR]: could not start the scheduled database backup, reason: %v", err)
+	}
+}
+
+// databaseBackupDue returns if the scheduled backup of the day has to start. It's due from the
+// configured hour, in the location of now, until it has started, so a backup missed while the console
+// was stopped still runs
+func databaseBackupDue(hour int, lastStartedAt, now time.Time) bool {
+	due := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	return !now.Before(due) && lastStartedAt.Before(due)
+}
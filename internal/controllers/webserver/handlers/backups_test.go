@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseBackupDue(t *testing.T) {
+	now := time.Date(2026, 10, 16, 2, 30, 0, 0, time.Local)
+
+	assert.True(t, databaseBackupDue(2, time.Time{}, now), "should run the first backup")
+	assert.True(t, databaseBackupDue(2, now.Add(-24*time.Hour), now), "should run once a day")
+	assert.True(t, databaseBackupDue(1, now.Add(-25*time.Hour), now), "should run a backup missed while the console was stopped")
+	assert.False(t, databaseBackupDue(2, now.Add(-20*time.Minute), now), "should not run twice the same day")
+	assert.False(t, databaseBackupDue(3, now.Add(-24*time.Hour), now), "should wait for the configured hour")
+}
+
+func TestDatabaseBackupDueDSTTransitions(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	assert.NoError(t, err)
+	yesterday := time.Date(2026, 3, 28, 2, 0, 0, 0, madrid)
+
+	// 02:00 doesn't exist on 29 March 2026, the clocks go from 02:00 CET to 03:00 CEST
+	assert.False(t, databaseBackupDue(2, yesterday, time.Date(2026, 3, 29, 0, 59, 0, 0, time.UTC).In(madrid)), "should wait until the clocks go forward")
+	assert.True(t, databaseBackupDue(2, yesterday, time.Date(2026, 3, 29, 1, 5, 0, 0, time.UTC).In(madrid)), "should run once the clocks go forward")
+
+	// 02:00 happens twice on 25 October 2026, the clocks go from 03:00 CEST to 02:00 CET
+	yesterday = time.Date(2026, 10, 24, 2, 0, 0, 0, madrid)
+	ranAt := time.Date(2026, 10, 25, 1, 5, 0, 0, time.UTC)
+	assert.True(t, databaseBackupDue(2, yesterday, ranAt.In(madrid)))
+	assert.False(t, databaseBackupDue(2, ranAt, time.Date(2026, 10, 25, 2, 30, 0, 0, time.UTC).In(madrid)), "should run once when the hour happens twice")
+}
+
+func TestBackupNotifyEmails(t *testing.T) {
+	emails, ok := backupNotifyEmails(" admin@example.com,, ops@example.com ")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"admin@example.com", "ops@example.com"}, emails)
+
+	emails, ok = backupNotifyEmails("")
+	assert.True(t, ok, "should allow no recipients")
+	assert.Empty(t, emails)
+
+	_, ok = backupNotifyEmails("admin@example.com, not an email")
+	assert.False(t, ok)
+}
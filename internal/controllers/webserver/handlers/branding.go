@@ -1,15 +1,24 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
 	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
@@ -18,10 +27,31 @@ const (
 	maxLogoSize = 2 * 1024 * 1024
 	// maxBackgroundSize is the maximum file size for background images (5MB)
 	maxBackgroundSize = 5 * 1024 * 1024
+	// minBrandingContrast is the WCAG 2.1 contrast ratio required for large text and UI components
+	minBrandingContrast = 3.0
 )
 
+var hexColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// brandingImages are the images served by GET /branding/:image
+var brandingImages = map[string]func(b *ent.Branding) string{
+	"logo-light":       func(b *ent.Branding) string { return b.LogoLight },
+	"logo-dark":        func(b *ent.Branding) string { return b.LogoDark },
+	"logo-small":       func(b *ent.Branding) string { return b.LogoSmall },
+	"login-background": func(b *ent.Branding) string { return b.LoginBackgroundImage },
+}
+
 // GetBrandingSettings handles GET /admin/branding
 func (h *Handler) GetBrandingSettings(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -29,7 +59,7 @@ func (h *Handler) GetBrandingSettings(c echo.Context) error {
 
 	branding, err := h.Model.GetOrCreateBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return RenderView(c, admin_views.BrandingSettingsIndex(" | Branding", admin_views.BrandingSettings(c, branding, commonInfo, ""), commonInfo))
@@ -37,32 +67,52 @@ func (h *Handler) GetBrandingSettings(c echo.Context) error {
 
 // PostBrandingLogo handles POST /admin/branding/logo (single logo)
 func (h *Handler) PostBrandingLogo(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	return h.handleLogoUpload(c, "light")
 }
 
 // DeleteBrandingLogo handles DELETE /admin/branding/logo
 func (h *Handler) DeleteBrandingLogo(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	if err := h.Model.DeleteLogoLight(); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.logo_deleted"))
 }
 
 // PostBrandingFavicon handles POST /admin/branding/favicon
 func (h *Handler) PostBrandingFavicon(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	return h.handleLogoUpload(c, "small")
 }
 
 // DeleteBrandingFavicon handles DELETE /admin/branding/favicon
 func (h *Handler) DeleteBrandingFavicon(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	if err := h.Model.DeleteLogoSmall(); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.favicon_deleted"))
 }
 
 // PostBrandingProductName handles POST /admin/branding/product-name
 func (h *Handler) PostBrandingProductName(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	productName := c.FormValue("product_name")
 	if productName == "" {
 		productName = "OpenUEM"
@@ -70,12 +120,12 @@ func (h *Handler) PostBrandingProductName(c echo.Context) error {
 
 	branding, err := h.Model.GetOrCreateBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	branding.ProductName = productName
 	if err := h.Model.UpdateBranding(branding); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	// Force a full page reload to update the header
@@ -85,6 +135,10 @@ func (h *Handler) PostBrandingProductName(c echo.Context) error {
 
 // PostBrandingColors handles POST /admin/branding/colors
 func (h *Handler) PostBrandingColors(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	// The text input is synced with the color picker via JavaScript
 	// Use the text input value as it is always up-to-date
 	primary := c.FormValue("primary_color_text")
@@ -94,8 +148,12 @@ func (h *Handler) PostBrandingColors(c echo.Context) error {
 		primary = c.FormValue("primary_color")
 	}
 
+	if key := primaryColorError(primary); key != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), key), true))
+	}
+
 	if err := h.Model.UpdatePrimaryColor(primary); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	// Force a full page reload by redirecting to the same page
@@ -106,15 +164,19 @@ func (h *Handler) PostBrandingColors(c echo.Context) error {
 
 // PostBrandingLogin handles POST /admin/branding/login (welcome text only)
 func (h *Handler) PostBrandingLogin(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	branding, err := h.Model.GetOrCreateBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	branding.LoginWelcomeText = c.FormValue("login_welcome_text")
 
 	if err := h.Model.UpdateBranding(branding); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.saved"))
@@ -122,9 +184,13 @@ func (h *Handler) PostBrandingLogin(c echo.Context) error {
 
 // PostBrandingLoginBackground handles POST /admin/branding/login-background
 func (h *Handler) PostBrandingLoginBackground(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	branding, err := h.Model.GetOrCreateBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	// Handle background image upload
@@ -140,13 +206,13 @@ func (h *Handler) PostBrandingLoginBackground(c echo.Context) error {
 
 	src, err := file.Open()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	defer src.Close()
 
 	data, err := io.ReadAll(src)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	mimeType := http.DetectContentType(data)
@@ -158,7 +224,7 @@ func (h *Handler) PostBrandingLoginBackground(c echo.Context) error {
 	branding.LoginBackgroundImage = "data:" + mimeType + ";base64," + base64Data
 
 	if err := h.Model.UpdateBranding(branding); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.saved"))
@@ -166,14 +232,18 @@ func (h *Handler) PostBrandingLoginBackground(c echo.Context) error {
 
 // DeleteBrandingLoginBackground handles DELETE /admin/branding/login-background
 func (h *Handler) DeleteBrandingLoginBackground(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	branding, err := h.Model.GetBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	branding.LoginBackgroundImage = ""
 	if err := h.Model.UpdateBranding(branding); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.logo_deleted"))
@@ -197,13 +267,13 @@ func (h *Handler) handleLogoUpload(c echo.Context, logoType string) error {
 
 	src, err := file.Open()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	defer src.Close()
 
 	data, err := io.ReadAll(src)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	mimeType := http.DetectContentType(data)
@@ -227,12 +297,56 @@ func (h *Handler) handleLogoUpload(c echo.Context, logoType string) error {
 	}
 
 	if saveErr != nil {
-		return RenderError(c, partials.ErrorMessage(saveErr.Error(), true))
+		return RenderModelError(c, saveErr)
 	}
 
+	// The ETag of the new logo, GET /branding/:image answers 304 Not Modified while it doesn't change
+	c.Response().Header().Set(headerETag, brandingImageETag(dataURL))
+
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.logo_uploaded"))
 }
 
+// GetBrandingImage handles GET /branding/:image. It's public because the login pages show the branding.
+// The images are stored as data URLs, their hash is the ETag so browsers can revalidate their copy
+// instead of downloading it again
+func (h *Handler) GetBrandingImage(c echo.Context) error {
+	image, ok := brandingImages[c.Param("image")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	branding, err := h.Model.GetBranding()
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return err
+	}
+
+	dataURL := image(branding)
+	if dataURL == "" {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	etag := brandingImageETag(dataURL)
+	header := c.Response().Header()
+	header.Set(headerETag, etag)
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	// SVG images can have scripts, they must not run if the image is opened in the console origin
+	header.Set(echo.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+
+	if etagMatches(c.Request().Header.Get(headerIfNoneMatch), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	mimeType, data, err := decodeDataURL(dataURL)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, mimeType, data)
+}
+
 // renderBrandingWithSuccess renders the branding page with a success message
 func (h *Handler) renderBrandingWithSuccess(c echo.Context, message string) error {
 	commonInfo, err := h.GetCommonInfo(c)
@@ -242,7 +356,7 @@ func (h *Handler) renderBrandingWithSuccess(c echo.Context, message string) erro
 
 	branding, err := h.Model.GetOrCreateBranding()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return RenderView(c, admin_views.BrandingSettingsIndex(" | Branding", admin_views.BrandingSettings(c, branding, commonInfo, message), commonInfo))
@@ -250,9 +364,13 @@ func (h *Handler) renderBrandingWithSuccess(c echo.Context, message string) erro
 
 // PostBrandingShowVersion handles POST /admin/branding/show-version
 func (h *Handler) PostBrandingShowVersion(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	showVersion := c.FormValue("show_version") == "on"
 	if err := h.Model.UpdateShowVersion(showVersion); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	c.Response().Header().Set("HX-Redirect", "/admin/branding")
 	return c.NoContent(http.StatusOK)
@@ -260,42 +378,149 @@ func (h *Handler) PostBrandingShowVersion(c echo.Context) error {
 
 // PostBrandingBugReportLink handles POST /admin/branding/bug-report-link
 func (h *Handler) PostBrandingBugReportLink(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	link := strings.TrimSpace(c.FormValue("bug_report_link"))
-	if link != "" && !isValidLinkOrEmail(link) {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "branding.invalid_link"), true))
+	if key := brandingLinkError(link); key != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), key), true))
 	}
 	if err := h.Model.UpdateBugReportLink(link); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.saved"))
 }
 
 // PostBrandingHelpLink handles POST /admin/branding/help-link
 func (h *Handler) PostBrandingHelpLink(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	link := strings.TrimSpace(c.FormValue("help_link"))
-	if link != "" && !isValidLinkOrEmail(link) {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "branding.invalid_link"), true))
+	if key := brandingLinkError(link); key != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), key), true))
 	}
 	if err := h.Model.UpdateHelpLink(link); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	return h.renderBrandingWithSuccess(c, i18n.T(c.Request().Context(), "branding.saved"))
 }
 
-func isValidLinkOrEmail(link string) bool {
-	if strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://") {
-		return true
+// brandingLinkError returns the key of the error if the link is neither a URL nor an email address,
+// the email addresses are shown as mailto: links
+func brandingLinkError(link string) string {
+	if link == "" || strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://") {
+		return ""
 	}
-	if strings.HasPrefix(link, "mailto:") {
-		return true
+	if email, ok := strings.CutPrefix(link, "mailto:"); ok || strings.Contains(link, "@") {
+		if validateEmail(email) != nil {
+			return "branding.invalid_email"
+		}
+		return ""
 	}
-	if strings.Contains(link, "@") && strings.Contains(link, ".") {
-		return true
+	return "branding.invalid_link"
+}
+
+// validateEmail checks that s is a bare email address, without a display name
+func validateEmail(s string) error {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return err
 	}
-	return false
+	if addr.Address != s {
+		return errors.New("the email address has a display name")
+	}
+	return nil
 }
 
 // GetBrandingForViews returns branding data for use in views
 func (h *Handler) GetBrandingForViews() (*ent.Branding, error) {
 	return h.Model.GetOrCreateBranding()
 }
+
+// primaryColorError returns the translation key of the reason why the primary color
+// can't be used, an empty color is valid as it restores the default theme colors
+func primaryColorError(primary string) string {
+	if primary == "" {
+		return ""
+	}
+	if !hexColorRegexp.MatchString(primary) {
+		return "branding.invalid_color"
+	}
+
+	// The text on buttons is white or black depending on the luminance of the primary color
+	foreground := "#000000"
+	if helpers.GetContrastColor(primary) == "0 0% 98%" {
+		foreground = "#ffffff"
+	}
+	if wcagContrast(foreground, primary) < minBrandingContrast {
+		return "branding.insufficient_contrast"
+	}
+	return ""
+}
+
+// wcagContrast returns the WCAG 2.1 contrast ratio, from 1 to 21, of two #rrggbb colors
+func wcagContrast(fg, bg string) float64 {
+	l1, l2 := relativeLuminance(fg), relativeLuminance(bg)
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+// relativeLuminance returns the WCAG 2.1 relative luminance of a #rrggbb color
+func relativeLuminance(hex string) float64 {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return 0
+	}
+
+	luminance := 0.0
+	for i, weight := range []float64{0.2126, 0.7152, 0.0722} {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return 0
+		}
+		channel := float64(v) / 255
+		if channel <= 0.03928 {
+			channel /= 12.92
+		} else {
+			channel = math.Pow((channel+0.055)/1.055, 2.4)
+		}
+		luminance += weight * channel
+	}
+	return luminance
+}
+
+// brandingImageETag is the strong ETag of an image stored as a data URL
+func brandingImageETag(dataURL string) string {
+	sum := sha256.Sum256([]byte(dataURL))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches reports if the If-None-Match header has the ETag, it can be a list of ETags or *
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, value := range strings.Split(ifNoneMatch, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == etag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeDataURL returns the MIME type and the content of a base64 data URL
+func decodeDataURL(dataURL string) (string, []byte, error) {
+	mimeType, encoded, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ";base64,")
+	if !ok || !strings.HasPrefix(dataURL, "data:") {
+		return "", nil, errors.New("the image is not a base64 data URL")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, err
+	}
+	return mimeType, data, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWCAGContrast(t *testing.T) {
+	assert.InDelta(t, 21.0, wcagContrast("#ffffff", "#000000"), 0.01, "should be the maximum ratio")
+	assert.InDelta(t, 1.0, wcagContrast("#1e3a8a", "#1e3a8a"), 0.01, "should be the minimum ratio")
+	assert.InDelta(t, 4.54, wcagContrast("#767676", "#ffffff"), 0.01, "should not depend on the order of the colors")
+	assert.Equal(t, wcagContrast("#ffffff", "#767676"), wcagContrast("#767676", "#ffffff"))
+}
+
+func TestPrimaryColorError(t *testing.T) {
+	assert.Empty(t, primaryColorError("#1e3a8a"), "should accept dark colors with white text")
+	assert.Empty(t, primaryColorError("#ffff99"), "should accept light colors with black text")
+	assert.Empty(t, primaryColorError(""), "should accept restoring the default color")
+	assert.Equal(t, "branding.invalid_color", primaryColorError("1e3a8a"))
+	assert.Equal(t, "branding.invalid_color", primaryColorError("#1e3a8g"))
+	assert.Equal(t, "branding.insufficient_contrast", primaryColorError("#00cc00"), "should reject white text on bright green")
+}
+
+func TestBrandingLinkError(t *testing.T) {
+	for link, want := range map[string]string{
+		"":                              "",
+		"https://support.example.com":   "",
+		"support@example.com":           "",
+		"mailto:support@example.com":    "",
+		"support@":                      "branding.invalid_email",
+		"mailto:":                       "branding.invalid_email",
+		"mailto: support@example.com":   "branding.invalid_email",
+		"Support <support@example.com>": "branding.invalid_email",
+		"support.example.com":           "branding.invalid_link",
+	} {
+		assert.Equal(t, want, brandingLinkError(link), link)
+	}
+}
+
+func TestGetBrandingImageETag(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:brandingimage?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	h := &Handler{Model: &models.Model{Client: client}}
+	e := echo.New()
+	e.GET("/branding/:image", h.GetBrandingImage)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set(headerIfNoneMatch, ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/branding/logo-light", "").Code, "should not find the logo without branding")
+
+	_, err := h.Model.GetOrCreateBranding()
+	assert.NoError(t, err)
+	assert.NoError(t, h.Model.SaveLogoLight("data:image/png;base64,"+base64.StdEncoding.EncodeToString([]byte("logo"))))
+
+	rec := get("/branding/logo-light", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "logo", rec.Body.String())
+	etag := rec.Header().Get(headerETag)
+	assert.NotEmpty(t, etag)
+
+	rec = get("/branding/logo-light", `"other", `+etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code, "should not send the logo again")
+	assert.Empty(t, rec.Body.String())
+
+	assert.NoError(t, h.Model.SaveLogoLight("data:image/png;base64,"+base64.StdEncoding.EncodeToString([]byte("new logo"))))
+	rec = get("/branding/logo-light", etag)
+	assert.Equal(t, http.StatusOK, rec.Code, "should send the new logo")
+	assert.NotEqual(t, etag, rec.Header().Get(headerETag))
+
+	assert.Equal(t, http.StatusNotFound, get("/branding/logo-small", "").Code, "should not find images that haven't been uploaded")
+	assert.Equal(t, http.StatusNotFound, get("/branding/unknown", "").Code)
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	mimeType, data, err := decodeDataURL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte("<svg/>")))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", mimeType)
+	assert.Equal(t, "<svg/>", string(data))
+
+	_, _, err = decodeDataURL("https://example.com/logo.png")
+	assert.Error(t, err)
+}
@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"errors"
+	"log"
 	"strconv"
 	"strings"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
 	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
 	model "github.com/open-uem/openuem-console/internal/models/servers"
 	"github.com/open-uem/openuem-console/internal/views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
@@ -30,6 +33,7 @@ func (h *Handler) GetCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
 		IsAdmin:        strings.Contains(c.Request().URL.String(), "admin"),
 		IsProfile:      strings.Contains(c.Request().URL.String(), "profiles"),
 		CSRFToken:      csrfToken,
+		Theme:          h.SessionManager.Manager.GetString(c.Request().Context(), "theme"),
 	}
 
 	if strings.Contains(c.Request().URL.String(), "computers") && !strings.HasSuffix(c.Request().URL.String(), "computers") {
@@ -43,13 +47,12 @@ func (h *Handler) GetCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
 	}
 	info.IsDocker = len(allUpdateServers) == 0
 
-	latestRelease, err := model.GetLatestServerReleaseFromAPI(h.ServerReleasesFolder)
-	if err != nil {
-		return nil, err
+	// The latest release is unknown until it's downloaded, and never known if the checks are disabled
+	if latestRelease, err := model.GetLatestServerReleaseFromAPI(h.ServerReleasesFolder); err == nil {
+		info.LatestVersion = latestRelease.Version
+		info.LatestReleaseSummary = latestRelease.Summary
 	}
 
-	info.LatestVersion = latestRelease.Version
-
 	tenantID := c.Param("tenant")
 	siteID := c.Param("site")
 
@@ -71,11 +74,30 @@ func (h *Handler) GetCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
 			info.SiteID = "-1"
 			// Load branding settings for admin pages
 			info.Branding, _ = h.Model.GetOrCreateBranding()
+			info.Dates = h.getDateFormatter(c, &info)
+			info.UserRole, _ = h.GetCurrentUserTenantRole(c)
 			return &info, nil
 		}
-		tenant, err = h.Model.GetDefaultTenant()
-		if err != nil {
-			return nil, err
+		// The pages without a tenant in the URL show the tenant of the session, checked by TenantAccessMiddleware
+		if id, ok := c.Get(tenantIDContextKey).(int); ok {
+			tenant, err = h.Model.GetTenantByID(id)
+			if err == nil {
+				if sessionSiteID := h.SessionManager.Manager.GetInt(c.Request().Context(), sessionSiteKey); sessionSiteID != 0 {
+					siteID = strconv.Itoa(sessionSiteID)
+				}
+			}
+		}
+		// The users without tenants can't see any tenant, they can only ask for access
+		if tenant == nil && username != "" {
+			if _, err := h.Model.GetUserDefaultTenant(username); errors.Is(err, models.ErrNoTenantsAssigned) {
+				return nil, redirectToAccessRequest(c)
+			}
+		}
+		if tenant == nil {
+			tenant, err = h.Model.GetDefaultTenant()
+			if err != nil {
+				return nil, err
+			}
 		}
 		info.TenantID = strconv.Itoa(tenant.ID)
 	} else {
@@ -139,11 +161,14 @@ func (h *Handler) GetCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
 	// Load branding settings
 	info.Branding, _ = h.Model.GetOrCreateBranding()
 
+	info.Dates = h.getDateFormatter(c, &info)
+
 	// Multi-tenancy: Populate additional user/tenant context
 	// username already defined earlier for tenant filtering
 	if username != "" {
-		// Check if user is admin in main tenant
-		info.IsMainTenantAdmin, _ = h.Model.IsMainTenantAdmin(username)
+		// Check if user is admin in main tenant, the result is shared with the middlewares of the request
+		// and a user who isn't an admin doesn't clear the cached main tenant
+		info.IsMainTenantAdmin, _ = h.isMainTenantAdmin(c, username)
 
 		// Get user's role in current tenant
 		info.UserRole, _ = h.GetCurrentUserTenantRole(c)
@@ -160,6 +185,24 @@ func (h *Handler) GetCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
 	return &info, nil
 }
 
+// getDateFormatter returns the formatter for the timezone and the date format chosen by the user,
+// the values the user hasn't chosen are taken from the settings of the tenant
+func (h *Handler) getDateFormatter(c echo.Context, info *partials.CommonInfo) helpers.DateFormatter {
+	s, err := h.Model.GetDateTimeSettings(info.TenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the date and time settings of tenant %s, reason: %v", info.TenantID, err)
+	}
+
+	if timezone := h.SessionManager.Manager.GetString(c.Request().Context(), "timezone"); timezone != "" {
+		s.Timezone = timezone
+	}
+	if dateFormat := h.SessionManager.Manager.GetString(c.Request().Context(), "date-format"); dateFormat != "" {
+		s.DateFormat = dateFormat
+	}
+
+	return helpers.NewDateFormatter(info.Translator, s.Timezone, s.DateFormat)
+}
+
 func (h *Handler) GetAdminTenantName(commonInfo *partials.CommonInfo) string {
 	tenantName := ""
 	if commonInfo.TenantID != "-1" {
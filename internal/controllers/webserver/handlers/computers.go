@@ -25,6 +25,7 @@ import (
 	"github.com/open-uem/ent/task"
 	openuem_nats "github.com/open-uem/nats"
 	ansiblecfg "github.com/open-uem/openuem-ansible-config/ansible"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/computers_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -157,7 +158,14 @@ func (h *Handler) Overview(c echo.Context) error {
 
 	offline := h.IsAgentOffline(c)
 
-	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.Overview(c, p, agent, higherVersion, confirmDelete, successMessage, commonInfo, currentTenant, currentSite, allTenants, allSites, netbird, offline), commonInfo))
+	networkMatches := models.MatchSiteNetworks(agent.IP, allSites)
+
+	thresholds, err := h.Model.GetAgentThresholds(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.Overview(c, p, agent, higherVersion, confirmDelete, successMessage, commonInfo, currentTenant, currentSite, allTenants, allSites, networkMatches, thresholds.For(agent), netbird, offline), commonInfo))
 }
 
 func (h *Handler) Computer(c echo.Context) error {
@@ -1106,7 +1114,7 @@ func (h *Handler) ComputerDeployInstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.installpackage."+agentId, data)
+	err = h.natsPublish(c.Request().Context(), "agent.installpackage."+agentId, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -1171,7 +1179,7 @@ func (h *Handler) ComputerDeployUpdate(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.updatepackage."+agentId, data)
+	err = h.natsPublish(c.Request().Context(), "agent.updatepackage."+agentId, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -1251,7 +1259,7 @@ func (h *Handler) ComputerDeployUninstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.uninstallpackage."+agentId, data)
+	err = h.natsPublish(c.Request().Context(), "agent.uninstallpackage."+agentId, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -1338,10 +1346,10 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 		var whenTime time.Time
 		when := c.FormValue("when")
 		if when != "" {
-			whenTime, err = time.ParseInLocation("2006-01-02T15:04", when, time.Local)
+			whenTime, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04", when)
 			if err != nil {
 				log.Println("[INFO]: could not parse scheduled time as 24h time")
-				whenTime, err = time.Parse("2006-01-02T15:04PM", when)
+				whenTime, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04PM", when)
 				if err != nil {
 					return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_parse_action_time"), false))
 				}
@@ -1355,7 +1363,7 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.poweroff_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.poweroff."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err := h.natsRequest(c.Request().Context(), "agent.poweroff."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 		}
 
@@ -1369,10 +1377,10 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 		var whenTime time.Time
 		when := c.FormValue("when")
 		if when != "" {
-			whenTime, err = time.ParseInLocation("2006-01-02T15:04", when, time.Local)
+			whenTime, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04", when)
 			if err != nil {
 				log.Println("[INFO]: could not parse scheduled time as 24h time")
-				whenTime, err = time.Parse("2006-01-02T15:04PM", when)
+				whenTime, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04PM", when)
 				if err != nil {
 					return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_parse_action_time"), false))
 				}
@@ -1386,7 +1394,7 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.reboot_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.reboot."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err := h.natsRequest(c.Request().Context(), "agent.reboot."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 		}
 
@@ -1614,7 +1622,7 @@ func (h *Handler) ComputerStartVNC(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.vnc_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.startvnc."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err := h.natsRequest(c.Request().Context(), "agent.startvnc."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), true))
 		}
 
@@ -1681,7 +1689,7 @@ func (h *Handler) ComputerStopVNC(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	if _, err := h.NATSConnection.Request("agent.stopvnc."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+	if _, err := h.natsRequest(c.Request().Context(), "agent.stopvnc."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.no_responder"), false))
 	}
 
@@ -1765,7 +1773,7 @@ func (h *Handler) SetDefaultPrinter(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.defaultprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
+	msg, err := h.natsRequest(c.Request().Context(), "agent.defaultprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 	}
@@ -1830,7 +1838,7 @@ func (h *Handler) RemovePrinter(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.removeprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
+	msg, err := h.natsRequest(c.Request().Context(), "agent.removeprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 	}
@@ -1920,7 +1928,7 @@ func (h *Handler) IsAgentOffline(c echo.Context) bool {
 		return true
 	}
 
-	if _, err := h.NATSConnection.Request(fmt.Sprintf("agent.ping.%s", agentId), nil, 1*time.Second); err != nil {
+	if _, err := h.natsRequest(c.Request().Context(), fmt.Sprintf("agent.ping.%s", agentId), nil, 1*time.Second); err != nil {
 		return true
 	}
 
@@ -2060,7 +2068,7 @@ func (h *Handler) RunTask(c echo.Context) error {
 		}
 
 		// send request to agent
-		if _, err = h.NATSConnection.Request("agent.ansible."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err = h.natsRequest(c.Request().Context(), "agent.ansible."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tasks.could_not_send_ansible_playbook_request", err), true))
 		}
 	}
@@ -2084,7 +2092,7 @@ func (h *Handler) RunTask(c echo.Context) error {
 		}
 
 		// send request to agent
-		if _, err = h.NATSConnection.Request("agent.windowstask."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if _, err = h.natsRequest(c.Request().Context(), "agent.windowstask."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tasks.could_not_send_windows_task_request", err), true))
 		}
 	}
@@ -2135,7 +2143,7 @@ func (h *Handler) RunProfile(c echo.Context) error {
 	}
 
 	// send request to agent
-	if _, err = h.NATSConnection.Request("agent.runprofile."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+	if _, err = h.natsRequest(c.Request().Context(), "agent.runprofile."+agentID, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "profiles.could_not_send_profile_request", err), true))
 	}
 
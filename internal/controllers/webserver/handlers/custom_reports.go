@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/open-uem/openuem-console/internal/views/reports_views"
+)
+
+// customReportFileName keeps the characters of the name of a report that are safe in a file name
+var customReportFileName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// CustomReports shows the reports saved in the tenant and the report builder, with a saved report loaded if
+// one is requested
+func (h *Handler) CustomReports(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.customReportsTenant(c, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	builder := reports_views.CustomReportBuilder{Definition: models.CustomReportDefinition{Entity: models.CustomReportEntityAgents}}
+	if id := c.QueryParam("report"); id != "" {
+		r, err := h.customReport(c, tenantID, id)
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+		if builder, err = h.customReportBuilder(c, r, commonInfo); err != nil {
+			return RenderModelError(c, err)
+		}
+		return h.renderCustomReports(c, tenantID, builder, true, "", commonInfo)
+	}
+
+	return h.renderCustomReports(c, tenantID, builder, false, "", commonInfo)
+}
+
+// PreviewCustomReport shows the first rows of the report defined in the report builder
+func (h *Handler) PreviewCustomReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.customReportsTenant(c, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	builder, err := customReportBuilderValues(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	if builder.ReportID != 0 {
+		r, err := h.customReport(c, tenantID, strconv.Itoa(builder.ReportID))
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+		builder.CreatedBy = r.CreatedBy
+		builder.CanEdit = h.canEditCustomReport(c, r, commonInfo)
+	} else {
+		builder.CanEdit = true
+	}
+
+	return h.renderCustomReports(c, tenantID, builder, true, "", commonInfo)
+}
+
+// SaveCustomReport saves a new report or the changes of a report. Every user of the tenant can run the saved
+// reports but only their creator and the admins of the tenant can change them
+func (h *Handler) SaveCustomReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.customReportsTenant(c, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	builder, err := customReportBuilderValues(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	if builder.Name == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "custom_reports.name_empty"), true))
+	}
+
+	v := models.CustomReportValues{Name: builder.Name, Description: builder.Description, Definition: builder.Definition, Scheduled: builder.Scheduled}
+
+	var r *openuem_ent.CustomReport
+	if builder.ReportID != 0 {
+		current, err := h.customReport(c, tenantID, strconv.Itoa(builder.ReportID))
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+		if !h.canEditCustomReport(c, current, commonInfo) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "custom_reports.cannot_edit"), true))
+		}
+		if r, err = h.Model.UpdateCustomReport(current.ID, tenantID, v); err != nil {
+			return RenderModelError(c, err)
+		}
+		h.auditTenantData(c, "has changed the custom report %s of tenant %d", r.Name, tenantID)
+	} else {
+		uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+		if r, err = h.Model.CreateCustomReport(tenantID, uid, v); err != nil {
+			return RenderModelError(c, err)
+		}
+		h.auditTenantData(c, "has created the custom report %s of tenant %d", r.Name, tenantID)
+	}
+
+	if builder, err = h.customReportBuilder(c, r, commonInfo); err != nil {
+		return RenderModelError(c, err)
+	}
+	return h.renderCustomReports(c, tenantID, builder, true, i18n.T(c.Request().Context(), "custom_reports.saved"), commonInfo)
+}
+
+// DeleteCustomReport removes a saved report, only its creator and the admins of the tenant can remove it
+func (h *Handler) DeleteCustomReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.customReportsTenant(c, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	r, err := h.customReport(c, tenantID, c.Param("id"))
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	if !h.canEditCustomReport(c, r, commonInfo) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "custom_reports.cannot_edit"), true))
+	}
+
+	if err := h.Model.DeleteCustomReport(r.ID, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has deleted the custom report %s of tenant %d", r.Name, tenantID)
+
+	builder := reports_views.CustomReportBuilder{Definition: models.CustomReportDefinition{Entity: models.CustomReportEntityAgents}}
+	return h.renderCustomReports(c, tenantID, builder, false, i18n.T(c.Request().Context(), "custom_reports.deleted"), commonInfo)
+}
+
+// ExportCustomReport sends all the rows of a saved report as a CSV file
+func (h *Handler) ExportCustomReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := h.customReportsTenant(c, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	r, err := h.customReport(c, tenantID, c.Param("id"))
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+
+	d, err := models.CustomReportDefinitionOf(r)
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+
+	result, err := h.Model.RunCustomReport(d, 0, commonInfo)
+	if err != nil {
+		log.Printf("[ERROR]: could not run the custom report %d, reason: %v", r.ID, err)
+		return ModelHTTPError(c, err)
+	}
+
+	fileName := strings.Trim(customReportFileName.ReplaceAllString(r.Name, "-"), "-")
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="openuem-report-%s-%s.csv"`, fileName, time.Now().Format("20060102")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	header := []string{}
+	for _, column := range result.Columns {
+		if column.Custom {
+			header = append(header, column.Label)
+		} else {
+			header = append(header, i18n.T(c.Request().Context(), column.Label))
+		}
+	}
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (h *Handler) renderCustomReports(c echo.Context, tenantID int, builder reports_views.CustomReportBuilder, preview bool, successMessage string, commonInfo *partials.CommonInfo) error {
+	fields, err := h.Model.CustomReportFields(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	reports, err := h.Model.GetCustomReports(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	var result *models.CustomReportResult
+	if preview {
+		if result, err = h.Model.RunCustomReport(builder.Definition, models.CustomReportPreviewRows, commonInfo); err != nil {
+			return RenderModelError(c, err)
+		}
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	return RenderView(c, reports_views.ReportsIndex("| Custom reports", reports_views.CustomReports(c, fields, reports, builder, result, uid, successMessage, commonInfo), commonInfo))
+}
+
+// customReportsTenant returns the tenant where the reports are saved
+func (h *Handler) customReportsTenant(c echo.Context, commonInfo *partials.CommonInfo) (int, error) {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+	}
+	return tenantID, nil
+}
+
+func (h *Handler) customReport(c echo.Context, tenantID int, id string) (*openuem_ent.CustomReport, error) {
+	reportID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, models.ErrNotFound
+	}
+	return h.Model.GetCustomReport(reportID, tenantID)
+}
+
+// canEditCustomReport returns if the user created the report or is an admin of the tenant
+func (h *Handler) canEditCustomReport(c echo.Context, r *openuem_ent.CustomReport, commonInfo *partials.CommonInfo) bool {
+	return commonInfo.CanAdminister() || r.CreatedBy == h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+}
+
+// customReportBuilder loads a saved report in the report builder
+func (h *Handler) customReportBuilder(c echo.Context, r *openuem_ent.CustomReport, commonInfo *partials.CommonInfo) (reports_views.CustomReportBuilder, error) {
+	d, err := models.CustomReportDefinitionOf(r)
+	if err != nil {
+		return reports_views.CustomReportBuilder{}, err
+	}
+
+	return reports_views.CustomReportBuilder{
+		ReportID:    r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Scheduled:   r.Scheduled,
+		CreatedBy:   r.CreatedBy,
+		Definition:  d,
+		CanEdit:     h.canEditCustomReport(c, r, commonInfo),
+	}, nil
+}
+
+// customReportBuilderValues reads the report builder. The filters are sent as rows of field, operator and
+// value, the rows without a field are ignored
+func customReportBuilderValues(c echo.Context) (reports_views.CustomReportBuilder, error) {
+	form, err := c.FormParams()
+	if err != nil {
+		return reports_views.CustomReportBuilder{}, err
+	}
+
+	builder := reports_views.CustomReportBuilder{
+		Name:        strings.TrimSpace(form.Get("name")),
+		Description: strings.TrimSpace(form.Get("description")),
+		Scheduled:   form.Get("scheduled") != "",
+		Definition: models.CustomReportDefinition{
+			Entity:  models.CustomReportEntityAgents,
+			Columns: form["columns"],
+			Filters: []models.CustomReportFilter{},
+			GroupBy: form.Get("group-by"),
+		},
+	}
+
+	if id := form.Get("report-id"); id != "" {
+		if builder.ReportID, err = strconv.Atoi(id); err != nil {
+			return builder, fmt.Errorf("%s", i18n.T(c.Request().Context(), "custom_reports.invalid_report"))
+		}
+	}
+
+	fields, operators, values := form["filter-field"], form["filter-operator"], form["filter-value"]
+	if len(operators) != len(fields) || len(values) != len(fields) {
+		return builder, fmt.Errorf("%s", i18n.T(c.Request().Context(), "custom_reports.invalid_filters"))
+	}
+	for i, field := range fields {
+		if field == "" {
+			continue
+		}
+		builder.Definition.Filters = append(builder.Definition.Filters, models.CustomReportFilter{Field: field, Operator: operators[i], Value: strings.TrimSpace(values[i])})
+	}
+
+	return builder, nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomReportBuilderValues(t *testing.T) {
+	form := url.Values{}
+	form.Set("report-id", "7")
+	form.Set("name", " Disabled agents ")
+	form.Set("scheduled", "on")
+	form["columns"] = []string{"hostname", "custom:3"}
+	form["filter-field"] = []string{"status", ""}
+	form["filter-operator"] = []string{models.CustomReportEquals, models.CustomReportEquals}
+	form["filter-value"] = []string{" Disabled ", ""}
+	form.Set("group-by", "site")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	builder, err := customReportBuilderValues(c)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, builder.ReportID)
+	assert.Equal(t, "Disabled agents", builder.Name)
+	assert.True(t, builder.Scheduled)
+	assert.Equal(t, models.CustomReportDefinition{
+		Entity:  models.CustomReportEntityAgents,
+		Columns: []string{"hostname", "custom:3"},
+		Filters: []models.CustomReportFilter{{Field: "status", Operator: models.CustomReportEquals, Value: "Disabled"}},
+		GroupBy: "site",
+	}, builder.Definition, "the filter rows without a field should be ignored")
+}
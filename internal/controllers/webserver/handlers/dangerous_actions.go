@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// dangerousActionsSessionKey is the session key where the tokens of the dangerous actions previewed are stored
+const dangerousActionsSessionKey = "dangerous-actions"
+
+// dangerousActionExpiration is how long the preview of a dangerous action can be confirmed
+const dangerousActionExpiration = 10 * time.Minute
+
+// Errors returned when a dangerous action can't be confirmed, the action must be previewed again
+var (
+	errDangerousActionExpired         = errors.New("the confirmation has expired or has already been used")
+	errDangerousActionScopeChanged    = errors.New("the affected items have changed since the confirmation was shown")
+	errDangerousActionNotAcknowledged = errors.New("the confirmation typed doesn't match")
+)
+
+// dangerousActionScope is what a dangerous action applies to. The token of the preview is tied to its digest,
+// so the action is rejected if it would apply to a different set of items than the one shown
+type dangerousActionScope struct {
+	Action string
+	Target string
+	Items  []string
+}
+
+type pendingDangerousAction struct {
+	Digest  string
+	Expires time.Time
+}
+
+func (s dangerousActionScope) digest() string {
+	items := slices.Clone(s.Items)
+	slices.Sort(items)
+
+	hash := sha256.New()
+	hash.Write([]byte(s.Action + "\n" + s.Target + "\n"))
+	for _, item := range items {
+		hash.Write([]byte(item + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newDangerousAction stores a single-use token for the scope shown in the preview of the action
+func (h *Handler) newDangerousAction(c echo.Context, scope dangerousActionScope) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	pending := h.getDangerousActions(c)
+	pending[token] = pendingDangerousAction{Digest: scope.digest(), Expires: time.Now().Add(dangerousActionExpiration)}
+	if err := h.saveDangerousActions(c, pending); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// checkDangerousAction consumes the token sent with the action and checks that it was issued for the same scope
+// and that the acknowledgement typed matches the expected one
+func (h *Handler) checkDangerousAction(c echo.Context, scope dangerousActionScope, acknowledgement string) error {
+	token := c.FormValue(partials.DangerousActionTokenField)
+
+	pending := h.getDangerousActions(c)
+	action, ok := pending[token]
+	if ok {
+		// The token can only be used once, even if the confirmation fails
+		delete(pending, token)
+		if err := h.saveDangerousActions(c, pending); err != nil {
+			return err
+		}
+	}
+
+	if !ok || token == "" || time.Now().After(action.Expires) {
+		return errDangerousActionExpired
+	}
+
+	if action.Digest != scope.digest() {
+		return errDangerousActionScopeChanged
+	}
+
+	if normalizeAcknowledgement(c.FormValue(partials.DangerousActionAcknowledgementField)) != normalizeAcknowledgement(acknowledgement) {
+		return errDangerousActionNotAcknowledged
+	}
+
+	return nil
+}
+
+// dangerousActionErrorMessage is the message shown when a dangerous action can't be confirmed
+func dangerousActionErrorMessage(c echo.Context, err error) string {
+	switch {
+	case errors.Is(err, errDangerousActionExpired):
+		return i18n.T(c.Request().Context(), "dangerous_action.expired")
+	case errors.Is(err, errDangerousActionScopeChanged):
+		return i18n.T(c.Request().Context(), "dangerous_action.scope_changed")
+	case errors.Is(err, errDangerousActionNotAcknowledged):
+		return i18n.T(c.Request().Context(), "dangerous_action.not_acknowledged")
+	default:
+		return i18n.T(c.Request().Context(), "dangerous_action.could_not_confirm", err.Error())
+	}
+}
+
+// getDangerousActions returns the tokens of the session that have not expired
+func (h *Handler) getDangerousActions(c echo.Context) map[string]pendingDangerousAction {
+	pending := map[string]pendingDangerousAction{}
+
+	data := h.SessionManager.Manager.GetString(c.Request().Context(), dangerousActionsSessionKey)
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &pending); err != nil {
+			log.Printf("[ERROR]: could not decode the dangerous actions, reason: %v", err)
+		}
+	}
+
+	for token, action := range pending {
+		if time.Now().After(action.Expires) {
+			delete(pending, token)
+		}
+	}
+
+	return pending
+}
+
+func (h *Handler) saveDangerousActions(c echo.Context, pending map[string]pendingDangerousAction) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	h.SessionManager.Manager.Put(c.Request().Context(), dangerousActionsSessionKey, string(data))
+	return nil
+}
+
+// normalizeAcknowledgement ignores the spaces around the acknowledgement and the separators typed in a count
+func normalizeAcknowledgement(s string) string {
+	s = strings.TrimSpace(s)
+	if n := strings.NewReplacer(",", "", ".", "", " ", "", "'", "").Replace(s); n != "" && strings.Trim(n, "0123456789") == "" {
+		return n
+	}
+	return s
+}
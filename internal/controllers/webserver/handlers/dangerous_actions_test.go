@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/sessions"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+// dangerousActionContext returns a request of the session with the token and the acknowledgement typed
+func dangerousActionContext(ctx context.Context, token, acknowledgement string) echo.Context {
+	form := url.Values{}
+	form.Set(partials.DangerousActionTokenField, token)
+	form.Set(partials.DangerousActionAcknowledgementField, acknowledgement)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return echo.New().NewContext(req.WithContext(ctx), httptest.NewRecorder())
+}
+
+func TestDangerousAction(t *testing.T) {
+	h := &Handler{SessionManager: &sessions.SessionManager{Manager: scs.New()}}
+	ctx, err := h.SessionManager.Manager.Load(context.Background(), "")
+	assert.NoError(t, err)
+
+	scope := dangerousActionScope{Action: "delete-agents", Target: "1/-1", Items: []string{"agent-2", "agent-1"}}
+
+	token, err := h.newDangerousAction(dangerousActionContext(ctx, "", ""), scope)
+	assert.NoError(t, err)
+
+	c := dangerousActionContext(ctx, token, "3")
+	assert.ErrorIs(t, h.checkDangerousAction(c, scope, "2"), errDangerousActionNotAcknowledged, "should be rejected if the acknowledgement doesn't match")
+
+	c = dangerousActionContext(ctx, token, "2")
+	assert.ErrorIs(t, h.checkDangerousAction(c, scope, "2"), errDangerousActionExpired, "the token should be used only once, even if the confirmation failed")
+
+	token, err = h.newDangerousAction(dangerousActionContext(ctx, "", ""), scope)
+	assert.NoError(t, err)
+	changed := dangerousActionScope{Action: "delete-agents", Target: "1/-1", Items: []string{"agent-1", "agent-3"}}
+	c = dangerousActionContext(ctx, token, "2")
+	assert.ErrorIs(t, h.checkDangerousAction(c, changed, "2"), errDangerousActionScopeChanged, "should be rejected if the items have changed since the preview")
+
+	token, err = h.newDangerousAction(dangerousActionContext(ctx, "", ""), scope)
+	assert.NoError(t, err)
+	reordered := dangerousActionScope{Action: "delete-agents", Target: "1/-1", Items: []string{"agent-1", "agent-2"}}
+	c = dangerousActionContext(ctx, token, " 2 ")
+	assert.NoError(t, h.checkDangerousAction(c, reordered, "2"), "the order of the items should not matter")
+
+	c = dangerousActionContext(ctx, "unknown", "2")
+	assert.ErrorIs(t, h.checkDangerousAction(c, scope, "2"), errDangerousActionExpired, "should be rejected without a token issued for the session")
+}
+
+func TestNormalizeAcknowledgement(t *testing.T) {
+	assert.Equal(t, "3204", normalizeAcknowledgement("3,204"))
+	assert.Equal(t, "3204", normalizeAcknowledgement(" 3.204 "))
+	assert.Equal(t, "Acme, Inc.", normalizeAcknowledgement(" Acme, Inc. "))
+}
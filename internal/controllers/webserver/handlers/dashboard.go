@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/charts"
 	"github.com/open-uem/openuem-console/internal/views/dashboard_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
@@ -149,10 +152,16 @@ func (h *Handler) Dashboard(c echo.Context) error {
 		data.RefreshTime = 5
 	}
 
-	data.NAgentsNotReportedIn24h, err = h.Model.CountAgentsNotReportedLast24h(commonInfo)
+	reportCounts, err := h.Model.CountAgentsByReportStatus(commonInfo)
 	if err != nil {
-		log.Println("[ERROR]: could not get refresh time from database")
-		data.RefreshTime = 5
+		log.Printf("[ERROR]: could not count the agents by report status, reason: %v", err)
+	}
+	data.NLateAgents = reportCounts[models.AgentReportLate]
+	data.NOfflineAgents = reportCounts[models.AgentReportOffline]
+
+	data.NOnlineAgents, err = h.Model.CountOnlineAgents(h.Presence.Online(), commonInfo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	data.NCertificatesAboutToExpire, err = h.Model.CountCertificatesAboutToexpire()
@@ -165,6 +174,42 @@ func (h *Handler) Dashboard(c echo.Context) error {
 	return RenderView(c, dashboard_views.DashboardIndex("| Dashboard", dashboard_views.Dashboard(c, data, commonInfo), commonInfo))
 }
 
+// defaultHeatmapDays and maxHeatmapDays limit the period used to compute the check-ins heatmap
+const (
+	defaultHeatmapDays = 30
+	maxHeatmapDays     = 365
+)
+
+// CheckinHeatmap returns as JSON the number of agent check-ins by hour and day of the week
+// so a calendar heatmap can show when agents are most active
+func (h *Handler) CheckinHeatmap(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()))
+	}
+
+	days := defaultHeatmapDays
+	if value := c.QueryParam("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxHeatmapDays {
+			return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "dashboard.invalid_heatmap_days", maxHeatmapDays))
+		}
+	}
+
+	buckets, err := h.Model.GetCheckinHeatmap(tenantID, days)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the agents check-ins heatmap, reason: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "dashboard.could_not_get_heatmap"))
+	}
+
+	return c.JSON(http.StatusOK, buckets)
+}
+
 func (h *Handler) generateCharts(c echo.Context) (*dashboard_views.DashboardCharts, error) {
 	ch := dashboard_views.DashboardCharts{}
 
@@ -191,12 +236,12 @@ func (h *Handler) generateCharts(c echo.Context) (*dashboard_views.DashboardChar
 
 	ch.AgentByOsVersion = charts.AgentsByOsVersion(c.Request().Context(), agents, countAllAgents)
 
-	countAgents, err := h.Model.CountAgentsReportedLast24h(commonInfo)
+	reportCounts, err := h.Model.CountAgentsByReportStatus(commonInfo)
 	if err != nil {
 		return nil, err
 	}
 
-	ch.AgentByLastReport = charts.AgentsByLastReportDate(c.Request().Context(), countAgents, countAllAgents)
+	ch.AgentByLastReport = charts.AgentsByLastReportDate(c.Request().Context(), reportCounts[models.AgentReportOnTime], reportCounts[models.AgentReportLate], reportCounts[models.AgentReportOffline])
 
 	return &ch, nil
 }
@@ -217,19 +262,19 @@ func (h *Handler) CheckNATSComponentStatus(data *dashboard_views.DashboardData)
 		var wg sync.WaitGroup
 
 		wg.Go(func() {
-			if _, err := h.NATSConnection.Request("ping.agentworker", nil, 1*time.Second); err != nil {
+			if _, err := h.natsRequest(context.Background(), "ping.agentworker", nil, 1*time.Second); err != nil {
 				data.AgentWorkerStatus = "down"
 			}
 		})
 
 		wg.Go(func() {
-			if _, err := h.NATSConnection.Request("ping.notificationworker", nil, 1*time.Second); err != nil {
+			if _, err := h.natsRequest(context.Background(), "ping.notificationworker", nil, 1*time.Second); err != nil {
 				data.NotificationWorkerStatus = "down"
 			}
 		})
 
 		wg.Go(func() {
-			if _, err := h.NATSConnection.Request("ping.certmanagerworker", nil, 1*time.Second); err != nil {
+			if _, err := h.natsRequest(context.Background(), "ping.certmanagerworker", nil, 1*time.Second); err != nil {
 				data.CertManagerWorkerStatus = "down"
 			}
 		})
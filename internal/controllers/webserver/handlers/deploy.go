@@ -298,7 +298,7 @@ func (h *Handler) DeployPackageToSelectedAgents(c echo.Context) error {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 			}
 
-			if err := h.NATSConnection.Publish("agent.installpackage."+agent, actionBytes); err != nil {
+			if err := h.natsPublish(c.Request().Context(), "agent.installpackage."+agent, actionBytes); err != nil {
 				return RenderError(c, partials.ErrorMessage(err.Error(), true))
 			}
 
@@ -310,7 +310,7 @@ func (h *Handler) DeployPackageToSelectedAgents(c echo.Context) error {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 			}
 
-			if err := h.NATSConnection.Publish("agent.uninstallpackage."+agent, actionBytes); err != nil {
+			if err := h.natsPublish(c.Request().Context(), "agent.uninstallpackage."+agent, actionBytes); err != nil {
 				return RenderError(c, partials.ErrorMessage(err.Error(), true))
 			}
 
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/open-uem/openuem-console/internal/views/reports_views"
+)
+
+// diskUsageReportPage is a page of the disk usage report in JSON
+type diskUsageReportPage struct {
+	Items    []models.DiskUsageEntry `json:"items"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+	Total    int                     `json:"total"`
+}
+
+// DiskUsageReport shows the disk usage of the agents of the tenant or site, the fullest first.
+// It returns JSON if the client accepts it, e.g. curl -H "Accept: application/json"
+func (h *Handler) DiskUsageReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), "", "", "", itemsPerPage)
+
+	entries, err := h.Model.GetDiskUsageReport(commonInfo)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the disk usage report, reason: %v", err)
+		if acceptsJSON(c) {
+			return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "reports.could_not_get_disk_usage"))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_disk_usage"), true))
+	}
+	p.NItems = len(entries)
+	page := diskUsagePage(entries, p)
+
+	if acceptsJSON(c) {
+		return c.JSON(http.StatusOK, diskUsageReportPage{Items: page, Page: p.CurrentPage, PageSize: p.PageSize, Total: p.NItems})
+	}
+
+	return RenderView(c, reports_views.ReportsIndex("| Disk usage", reports_views.DiskUsage(c, p, page, itemsPerPage, commonInfo), commonInfo))
+}
+
+// acceptsJSON returns if the client prefers JSON to HTML, browsers and htmx requests accept HTML
+func acceptsJSON(c echo.Context) bool {
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	return strings.Contains(accept, echo.MIMEApplicationJSON) && !strings.Contains(accept, echo.MIMETextHTML)
+}
+
+// diskUsagePage returns the entries of the current page
+func diskUsagePage(entries []models.DiskUsageEntry, p partials.PaginationAndSort) []models.DiskUsageEntry {
+	start := (p.CurrentPage - 1) * p.PageSize
+	if start < 0 || start >= len(entries) {
+		return []models.DiskUsageEntry{}
+	}
+	return entries[start:min(start+p.PageSize, len(entries))]
+}
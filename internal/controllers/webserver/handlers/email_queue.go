@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+const (
+	// emailQueueInterval is how often the worker looks for emails to deliver
+	emailQueueInterval = 30 * time.Second
+	// emailQueueBatchSize is how many emails the worker claims each time
+	emailQueueBatchSize = 20
+	// emailQueueConcurrency is how many emails are delivered at the same time, so the SMTP provider
+	// doesn't reject them for going over its rate limits
+	emailQueueConcurrency = 2
+)
+
+// StartEmailQueueJob delivers the queued emails, retrying those that fail until they run out of attempts
+func (h *Handler) StartEmailQueueJob() error {
+	var err error
+
+	if err := h.Model.ReleaseInterruptedEmails(); err != nil {
+		log.Printf("[ERROR]: could not release the emails interrupted when the console stopped, reason: %v", err)
+	}
+
+	h.EmailQueueJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			emailQueueInterval,
+		),
+		gocron.NewTask(h.deliverQueuedEmails),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the email queue job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// deliverQueuedEmails sends the emails whose attempt is due, a few at a time
+func (h *Handler) deliverQueuedEmails() {
+	now := time.Now()
+
+	emails, err := h.Model.ClaimEmails(now, emailQueueBatchSize)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the queued emails, reason: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, emailQueueConcurrency)
+	for _, e := range emails {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			h.deliverQueuedEmail(e)
+		})
+	}
+	wg.Wait()
+
+	if err := h.Model.DeleteOldEmails(now); err != nil {
+		log.Printf("[ERROR]: could not delete the old emails, reason: %v", err)
+	}
+}
+
+func (h *Handler) deliverQueuedEmail(e *openuem_ent.EmailMessage) {
+	if deliveryErr := h.Model.DeliverEmail(e); deliveryErr != nil {
+		log.Printf("[ERROR]: could not send the email %d (attempt %d of %d), reason: %v", e.ID, e.Attempts+1, models.EmailMaxAttempts, deliveryErr)
+		if err := h.Model.EmailDeliveryFailed(e, deliveryErr, time.Now()); err != nil {
+			log.Printf("[ERROR]: could not save the failed attempt of the email %d, reason: %v", e.ID, err)
+		}
+		return
+	}
+
+	if err := h.Model.EmailSent(e.ID, time.Now()); err != nil {
+		log.Printf("[ERROR]: could not save that the email %d has been sent, reason: %v", e.ID, err)
+	}
+}
+
+// Emails shows the history of the queued emails
+func (h *Handler) Emails(c echo.Context) error {
+	return h.renderEmails(c, "")
+}
+
+func (h *Handler) renderEmails(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	f := filters.EmailFilter{}
+	for index := range admin_views.EmailStatuses {
+		value := c.FormValue(fmt.Sprintf("filterByStatus%d", index))
+		if slices.Contains(admin_views.EmailStatuses, value) {
+			f.StatusOptions = append(f.StatusOptions, strings.TrimPrefix(value, "emails.status_"))
+		}
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"), itemsPerPage)
+
+	p.NItems, err = h.Model.CountEmails(f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	p.ClampCurrentPage()
+
+	emails, err := h.Model.GetEmailsByPage(p, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.EmailsIndex(" | Emails", admin_views.Emails(c, p, f, emails, successMessage, itemsPerPage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// RetryEmail queues again a failed or cancelled email
+func (h *Handler) RetryEmail(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "emails.invalid_id"), true))
+	}
+
+	if err := h.Model.RetryEmail(id); err != nil {
+		return h.emailChangeError(c, err)
+	}
+	h.auditTenantData(c, "has queued again the email %d", id)
+
+	return h.renderEmails(c, i18n.T(c.Request().Context(), "emails.retried"))
+}
+
+// CancelEmail stops the delivery of a pending email
+func (h *Handler) CancelEmail(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "emails.invalid_id"), true))
+	}
+
+	if err := h.Model.CancelEmail(id); err != nil {
+		return h.emailChangeError(c, err)
+	}
+	h.auditTenantData(c, "has cancelled the email %d", id)
+
+	return h.renderEmails(c, i18n.T(c.Request().Context(), "emails.cancelled"))
+}
+
+func (h *Handler) emailChangeError(c echo.Context, err error) error {
+	if errors.Is(err, models.ErrEmailNotRetryable) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "emails.status_changed"), true))
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		return resourceNotFound(c)
+	}
+	return RenderModelError(c, err)
+}
@@ -3,10 +3,17 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,43 +21,92 @@ import (
 	"github.com/google/uuid"
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
+// enrollmentTokenDownloadDays is how many days of downloads are shown for a token
+const enrollmentTokenDownloadDays = 30
+
 func (h *Handler) ListEnrollmentTokens(c echo.Context) error {
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
 	}
 
+	return h.renderEnrollmentTokens(c, commonInfo, 0, "")
+}
+
+// renderEnrollmentTokens shows the page of tokens of the tenant requested. The token with revealedTokenID
+// is shown in full, which is only done right after creating it
+func (h *Handler) renderEnrollmentTokens(c echo.Context, commonInfo *partials.CommonInfo, revealedTokenID int, errMessage string) error {
 	tenantID, err := strconv.Atoi(commonInfo.TenantID)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
 	}
 
-	tokens, err := h.Model.GetEnrollmentTokens(tenantID)
+	f := filters.EnrollmentTokenFilter{Description: c.FormValue("filterByDescription")}
+	for index := range models.EnrollmentTokenStatuses {
+		value := c.FormValue(fmt.Sprintf("filterByStatus%d", index))
+		if slices.Contains(models.EnrollmentTokenStatuses, value) {
+			f.StatusOptions = append(f.StatusOptions, value)
+		}
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"), itemsPerPage)
+
+	p.NItems, err = h.Model.CountEnrollmentTokens(tenantID, f)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
+	}
+	p.ClampCurrentPage()
+
+	tokens, err := h.Model.GetEnrollmentTokensByPage(tenantID, p, f)
+	if err != nil {
+		return RenderModelError(c, err)
 	}
 
 	sites, err := h.Model.GetSites(tenantID)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
+	}
+
+	tenant, err := h.Model.GetTenantByID(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	placeholders, err := h.Model.GetPendingAgentPlaceholders(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
 	}
 
+	pushListState(c, fmt.Sprintf("/tenant/%d/admin/enrollment", tenantID), p, requestListFilters(c))
+
 	return RenderView(c, admin_views.EnrollmentTokensIndex(" | Enrollment",
-		admin_views.EnrollmentTokens(c, tokens, sites, "", agentsExists, serversExists, commonInfo),
+		admin_views.EnrollmentTokens(c, p, f, tokens, sites, placeholders, h.Model.GetAgentPlaceholderRetention(), tenant, revealedTokenID, errMessage, itemsPerPage, agentsExists, serversExists, commonInfo),
 		commonInfo))
 }
 
@@ -65,7 +121,10 @@ func (h *Handler) CreateEnrollmentToken(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
 	}
 
-	description := c.FormValue("description")
+	description, ok := enrollmentTokenDescription(c.FormValue("description"))
+	if !ok {
+		return h.listEnrollmentTokensWithError(c, commonInfo, i18n.T(c.Request().Context(), "enrollment.description_required"))
+	}
 	tokenValue := uuid.New().String()
 
 	maxUses := 0
@@ -73,67 +132,282 @@ func (h *Handler) CreateEnrollmentToken(c echo.Context) error {
 		maxUses, _ = strconv.Atoi(v)
 	}
 
-	var siteID *int
-	if v := c.FormValue("site_id"); v != "" {
-		id, err := strconv.Atoi(v)
-		if err == nil && id > 0 {
-			siteID = &id
+	sites, err := h.enrollmentTokenSites(c, tenantID)
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return resourceNotFound(c)
 		}
+		return RenderModelError(c, err)
 	}
 
 	var expiresAt *time.Time
 	if v := c.FormValue("expires_at"); v != "" {
-		t, err := time.Parse("2006-01-02", v)
+		t, err := commonInfo.Dates.ParseLocal("2006-01-02", v)
 		if err == nil {
 			expiresAt = &t
 		}
 	}
 
-	_, err = h.Model.CreateEnrollmentToken(tenantID, siteID, description, tokenValue, maxUses, expiresAt)
+	params, err := c.FormParams()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	token, err := h.Model.CreateEnrollmentToken(tenantID, sites, description, tokenValue, maxUses, expiresAt, params["allowed_os"])
 	if err != nil {
 		log.Printf("[ERROR]: could not create enrollment token: %v", err)
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
-	return h.ListEnrollmentTokens(c)
+	return h.renderEnrollmentTokens(c, commonInfo, token.ID, "")
 }
 
-func (h *Handler) DeleteEnrollmentToken(c echo.Context) error {
+// enrollmentTokenSites reads the allowed sites and the default site of a token from the form, they
+// must be sites of the tenant
+func (h *Handler) enrollmentTokenSites(c echo.Context, tenantID int) (models.EnrollmentTokenSites, error) {
+	sites := models.EnrollmentTokenSites{}
+
+	params, err := c.FormParams()
+	if err != nil {
+		return sites, err
+	}
+	for _, v := range params["site_ids"] {
+		if id, err := strconv.Atoi(v); err == nil && id > 0 {
+			sites.IDs = append(sites.IDs, id)
+		}
+	}
+	if id, err := strconv.Atoi(c.FormValue("default_site_id")); err == nil && id > 0 {
+		sites.DefaultID = id
+	}
+
+	for _, id := range append(slices.Clone(sites.IDs), sites.DefaultID) {
+		if id == 0 {
+			continue
+		}
+		if _, err := h.Model.GetSite(id, tenantID); err != nil {
+			return sites, err
+		}
+	}
+	return sites, nil
+}
+
+// EditEnrollmentTokenSites shows the form to change the sites of a token below the tokens
+func (h *Handler) EditEnrollmentTokenSites(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	sites, err := h.Model.GetSites(token.Edges.Tenant.ID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.EnrollmentTokenSitesForm(token, sites, commonInfo))
+}
+
+// EnrollmentTokenDownloads shows how many times the configuration of the token was downloaded in the last days
+func (h *Handler) EnrollmentTokenDownloads(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	stats, err := h.Model.GetTokenDownloadStats(token.ID, enrollmentTokenDownloadDays)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	counts := make([]int, 0, len(stats))
+	for _, s := range stats {
+		counts = append(counts, s.Count)
+	}
+
+	return RenderView(c, admin_views.EnrollmentTokenDownloads(token, counts))
+}
+
+// SaveEnrollmentTokenSites replaces the sites of a token, the agents already enrolled stay in their sites
+func (h *Handler) SaveEnrollmentTokenSites(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID := token.Edges.Tenant.ID
+	sites, err := h.enrollmentTokenSites(c, tenantID)
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return resourceNotFound(c)
+		}
+		return RenderModelError(c, err)
+	}
+
+	if err := h.Model.UpdateEnrollmentTokenSites(token.ID, tenantID, sites); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return h.renderEnrollmentTokens(c, commonInfo, 0, "")
+}
+
+// tenantEnrollmentToken returns the token in the URL, if it belongs to the tenant of the request
+func (h *Handler) tenantEnrollmentToken(c echo.Context) (*openuem_ent.EnrollmentToken, error) {
 	tokenID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage("Invalid token ID", true))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "enrollment.invalid_token_id"))
 	}
 
-	err = h.Model.DeleteEnrollmentToken(tokenID)
+	token, err := h.Model.GetEnrollmentTokenByID(tokenID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, resourceNotFound(c)
+		}
+		return nil, ModelHTTPError(c, err)
+	}
+
+	if token.Edges.Tenant == nil {
+		return nil, resourceNotFound(c)
+	}
+
+	if err := h.RequireTenantResource(c, token.Edges.Tenant.ID); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// enrollmentTokenDescription returns the description for a new token without surrounding spaces.
+// Unnamed tokens are hard to tell apart in the list so the description is required
+func enrollmentTokenDescription(value string) (string, bool) {
+	description := strings.TrimSpace(value)
+	return description, description != ""
+}
+
+func (h *Handler) DeleteEnrollmentToken(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.Model.DeleteEnrollmentToken(token.ID)
 	if err != nil {
 		log.Printf("[ERROR]: could not delete enrollment token: %v", err)
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	return h.ListEnrollmentTokens(c)
 }
 
 func (h *Handler) ToggleEnrollmentToken(c echo.Context) error {
-	tokenID, err := strconv.Atoi(c.Param("id"))
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage("Invalid token ID", true))
+		return err
 	}
 
 	active := c.FormValue("active") == "true"
 
-	err = h.Model.ToggleEnrollmentToken(tokenID, active)
+	err = h.Model.ToggleEnrollmentToken(token.ID, active)
 	if err != nil {
 		log.Printf("[ERROR]: could not toggle enrollment token: %v", err)
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
-	return h.ListEnrollmentTokens(c)
+	token, err = h.Model.GetEnrollmentTokenByIDForTenant(token.ID, token.Edges.Tenant.ID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get enrollment token: %v", err)
+		return RenderModelError(c, err)
+	}
+
+	// Only the toggled row is sent back, swapped out of band
+	return RenderView(c, admin_views.EnrollmentTokenRow(token, true, false, commonInfo))
 }
 
-// buildConfigZIP creates an in-memory ZIP with openuem.ini and all certificates.
-func (h *Handler) buildConfigZIP(iniContent string) ([]byte, error) {
+// tokenCACertPath returns the CA certificate of the token's tenant if it has its own,
+// like an intermediate CA per tenant, or the global CA certificate
+func (h *Handler) tokenCACertPath(token *openuem_ent.EnrollmentToken) string {
+	if t := token.Edges.Tenant; t != nil && t.CACertPath != nil && *t.CACertPath != "" {
+		return *t.CACertPath
+	}
+	return h.CACertPath
+}
+
+// configPackageHash returns the hash of the settings and certificates in the config packages of a
+// token, the same for every platform. The packages change when it changes
+func (h *Handler) configPackageHash(natsServers, token string, profile *openuem_ent.AgentSettingsProfile, logLevel, caCertPath string) string {
+	hash := sha256.New()
+	for _, platform := range []string{"linux", "macos", "windows"} {
+		hash.Write([]byte(generatePlatformConfigINI(platform, natsServers, token, profile, logLevel)))
+	}
+
+	for _, path := range []string{caCertPath, h.AgentCertPath, h.AgentKeyPath, h.SFTPCertPath} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// The packages are created without the files that can't be read
+			continue
+		}
+		hash.Write(data)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// configNotModified checks if the conditional headers of the request match the config package. If-None-Match
+// takes precedence over If-Modified-Since, as in RFC 9110
+func configNotModified(r *http.Request, etag string, modified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !modified.After(since)
+	}
+	return false
+}
+
+// configManifestName is the file of the config ZIP that lists the other files
+const configManifestName = "manifest.json"
+
+// configManifest lists the files of a config ZIP and their sizes, so installers can check them without
+// extracting the ZIP
+type configManifest struct {
+	Files        []configManifestFile `json:"files"`
+	AgentVersion string               `json:"agent_version"`
+}
+
+type configManifestFile struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// buildConfigZIP creates an in-memory ZIP with openuem.ini, all certificates and the manifest listing them.
+func (h *Handler) buildConfigZIP(iniContent string, caCertPath string) ([]byte, error) {
 	var buf bytes.Buffer
 	zw := zip.NewWriter(&buf)
+	manifest := configManifest{Files: []configManifestFile{}, AgentVersion: h.enrollmentAgentVersion()}
 
 	// Add openuem.ini
 	fw, err := zw.Create("openuem.ini")
@@ -143,31 +417,45 @@ func (h *Handler) buildConfigZIP(iniContent string) ([]byte, error) {
 	if _, err := fw.Write([]byte(iniContent)); err != nil {
 		return nil, fmt.Errorf("could not write config: %w", err)
 	}
-
-	// Add certificate files
-	certFiles := map[string]string{
-		"certificates/ca.cer":    h.CACertPath,
-		"certificates/agent.cer": h.AgentCertPath,
-		"certificates/agent.key": h.AgentKeyPath,
-		"certificates/sftp.cer":  h.SFTPCertPath,
+	manifest.Files = append(manifest.Files, configManifestFile{Name: "openuem.ini", Size: len(iniContent)})
+
+	// Add certificate files, in the same order so the manifest doesn't change between downloads
+	certFiles := []struct{ zipPath, filePath string }{
+		{"certificates/ca.cer", caCertPath},
+		{"certificates/agent.cer", h.AgentCertPath},
+		{"certificates/agent.key", h.AgentKeyPath},
+		{"certificates/sftp.cer", h.SFTPCertPath},
 	}
 
-	for zipPath, filePath := range certFiles {
-		if filePath == "" {
+	for _, f := range certFiles {
+		if f.filePath == "" {
 			continue
 		}
-		data, err := os.ReadFile(filePath)
+		data, err := os.ReadFile(f.filePath)
 		if err != nil {
-			log.Printf("[WARN]: could not read %s: %v", filePath, err)
+			log.Printf("[WARN]: could not read %s: %v", f.filePath, err)
 			continue
 		}
-		fw, err := zw.Create(zipPath)
+		fw, err := zw.Create(f.zipPath)
 		if err != nil {
-			return nil, fmt.Errorf("could not create ZIP entry %s: %w", zipPath, err)
+			return nil, fmt.Errorf("could not create ZIP entry %s: %w", f.zipPath, err)
 		}
 		if _, err := fw.Write(data); err != nil {
-			return nil, fmt.Errorf("could not write %s: %w", zipPath, err)
+			return nil, fmt.Errorf("could not write %s: %w", f.zipPath, err)
 		}
+		manifest.Files = append(manifest.Files, configManifestFile{Name: f.zipPath, Size: len(data)})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the manifest: %w", err)
+	}
+	fw, err = zw.Create(configManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("could not create ZIP entry %s: %w", configManifestName, err)
+	}
+	if _, err := fw.Write(manifestData); err != nil {
+		return nil, fmt.Errorf("could not write %s: %w", configManifestName, err)
 	}
 
 	if err := zw.Close(); err != nil {
@@ -177,52 +465,121 @@ func (h *Handler) buildConfigZIP(iniContent string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (h *Handler) DownloadConfigZIP(c echo.Context) error {
-	tokenID, err := strconv.Atoi(c.Param("id"))
+// enrollmentAgentVersion returns the latest agent release of the default update channel, the one the install
+// commands install, or an empty string if it isn't known
+func (h *Handler) enrollmentAgentVersion() string {
+	channel, err := h.Model.GetDefaultUpdateChannel()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage("Invalid token ID", true))
+		log.Println("[ERROR]: could not get updates channel settings")
+		channel = "stable"
 	}
 
-	token, err := h.Model.GetEnrollmentTokenByID(tokenID)
+	r, err := h.Model.GetLatestAgentRelease(channel)
+	if err != nil || r == nil {
+		return ""
+	}
+	return r.Version
+}
+
+func (h *Handler) DownloadConfigZIP(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return err
+	}
+
+	// The UUID of the agent can be pre-generated to tell which agent was installed with the configuration
+	agentID := ""
+	if c.QueryParam("placeholder") == "true" {
+		username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+		placeholder, err := h.Model.CreateAgentPlaceholder(token.ID, token.Edges.Tenant.ID, username)
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+		agentID = placeholder.ID
+		h.auditTenantData(c, "has downloaded the configuration of enrollment token %d for the new agent %s", token.ID, agentID)
 	}
 
 	externalNATS := agentNATSURL(h.NATSServers)
-	iniContent := generateConfigINI(externalNATS, token.Token)
+	iniContent := generateConfigINI(externalNATS, token.Token, agentID, h.enrollmentSettingsProfile(token))
 
-	zipData, err := h.buildConfigZIP(iniContent)
+	zipData, err := h.buildConfigZIP(iniContent, h.tokenCACertPath(token))
 	if err != nil {
 		log.Printf("[ERROR]: could not build config ZIP: %v", err)
-		return RenderError(c, partials.ErrorMessage("Could not create ZIP file", true))
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "enrollment.could_not_create_zip"), true))
 	}
 
 	filename := fmt.Sprintf("openuem-config-%s.zip", token.Token[:8])
+	if agentID != "" {
+		filename = fmt.Sprintf("openuem-config-%s-%s.zip", token.Token[:8], agentID[:8])
+	}
 	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	return c.Blob(200, "application/zip", zipData)
 }
 
-// PublicDownloadConfig serves config ZIP without session auth.
-// The enrollment token value in the URL acts as authentication.
-func (h *Handler) PublicDownloadConfig(c echo.Context) error {
+// publicEnrollmentToken returns the token in the path of the public enrollment endpoints
+// if it can still enroll agents, otherwise the API error telling why it can't
+func (h *Handler) publicEnrollmentToken(c echo.Context) (*openuem_ent.EnrollmentToken, error) {
+	tokenValue, err := publicEnrollmentTokenValue(c)
+	if err != nil {
+		return nil, err
+	}
+
+	token, remainingUses, err := h.Model.GetEnrollmentTokenByValue(tokenValue)
+	if err != nil {
+		return nil, publicEnrollmentTokenNotFound(err)
+	}
+
+	if err := checkPublicEnrollmentToken(token, remainingUses); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func publicEnrollmentTokenValue(c echo.Context) (string, error) {
 	tokenValue := c.Param("token")
 	if tokenValue == "" {
-		return c.String(http.StatusBadRequest, "missing token")
+		return "", api.NewError(http.StatusBadRequest, "token_required", "missing token", api.FieldError{Field: "token", Message: "required"})
 	}
+	return tokenValue, nil
+}
 
-	token, err := h.Model.GetEnrollmentTokenByValue(tokenValue)
-	if err != nil {
-		return c.String(http.StatusNotFound, "invalid token")
+func publicEnrollmentTokenNotFound(err error) error {
+	if openuem_ent.IsNotFound(err) {
+		return api.NewError(http.StatusNotFound, "token_not_found", "invalid token")
 	}
+	return err
+}
 
+// checkPublicEnrollmentToken returns the API error telling why the token can't enroll agents, if it can't
+func checkPublicEnrollmentToken(token *openuem_ent.EnrollmentToken, remainingUses int) error {
 	if !token.Active {
-		return c.String(http.StatusForbidden, "token is inactive")
+		return api.NewError(http.StatusForbidden, "token_inactive", "token is inactive")
 	}
-	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
-		return c.String(http.StatusForbidden, "token has expired")
+	if models.EnrollmentTokenIsExpired(token) {
+		return api.NewError(http.StatusForbidden, "token_expired", "token has expired")
 	}
-	if token.MaxUses > 0 && token.CurrentUses >= token.MaxUses {
-		return c.String(http.StatusForbidden, "token usage limit reached")
+	if remainingUses == 0 {
+		return api.NewError(http.StatusForbidden, "token_usage_limit", "token usage limit reached")
+	}
+	return nil
+}
+
+// checkEnrollmentTokenPlatform returns the API error if the token is restricted to other operating systems,
+// the macOS platforms of the install script are checked as macos
+func checkEnrollmentTokenPlatform(token *openuem_ent.EnrollmentToken, platform string) error {
+	system, _, _ := strings.Cut(platform, "-")
+	if !models.EnrollmentTokenAllowsPlatform(token, system) {
+		return api.NewError(http.StatusForbidden, "platform_not_allowed", "the token can't enroll agents of this platform")
+	}
+	return nil
+}
+
+// PublicDownloadConfig serves config ZIP without session auth.
+// The enrollment token value in the URL acts as authentication.
+func (h *Handler) PublicDownloadConfig(c echo.Context) error {
+	tokenValue, err := publicEnrollmentTokenValue(c)
+	if err != nil {
+		return err
 	}
 
 	platform := c.QueryParam("platform")
@@ -232,17 +589,51 @@ func (h *Handler) PublicDownloadConfig(c echo.Context) error {
 		platform = "linux"
 	}
 
+	token, remainingUses, err := h.Model.GetEnrollmentTokenByValue(tokenValue)
+	if err != nil {
+		return publicEnrollmentTokenNotFound(err)
+	}
+	if err := checkPublicEnrollmentToken(token, remainingUses); err != nil {
+		return err
+	}
+	if err := checkEnrollmentTokenPlatform(token, platform); err != nil {
+		return err
+	}
+
 	externalNATS := agentNATSURL(h.NATSServers)
-	iniContent := generatePlatformConfigINI(platform, externalNATS, token.Token)
+	profile := h.enrollmentSettingsProfile(token)
+	logLevel := h.enrollmentLogLevel(token)
+	caCertPath := h.tokenCACertPath(token)
 
-	zipData, err := h.buildConfigZIP(iniContent)
+	// Agents downloading the config again, e.g. on boot, only get it if it has changed
+	hash := h.configPackageHash(externalNATS, token.Token, profile, logLevel, caCertPath)
+	modified, err := h.Model.SetEnrollmentTokenConfigHash(token, hash)
+	if err != nil {
+		log.Printf("[ERROR]: could not save the config hash of the enrollment token %d, reason: %v", token.ID, err)
+	} else {
+		etag := fmt.Sprintf(`"%s-%s"`, hash, platform)
+		c.Response().Header().Set("ETag", etag)
+		c.Response().Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		if configNotModified(c.Request(), etag, modified) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+
+	iniContent := generatePlatformConfigINI(platform, externalNATS, token.Token, profile, logLevel)
+	zipData, err := h.buildConfigZIP(iniContent, caCertPath)
 	if err != nil {
 		log.Printf("[ERROR]: could not build config ZIP: %v", err)
-		return c.String(http.StatusInternalServerError, "could not create config package")
+		return api.NewError(http.StatusInternalServerError, "config_package_failed", "could not create config package")
 	}
 
-	if err := h.Model.IncrementEnrollmentTokenUses(tokenValue); err != nil {
-		log.Printf("[WARN]: could not increment token usage count: %v", err)
+	// The use is counted in the same transaction the limit is checked, so concurrent downloads
+	// can't exceed it. It isn't counted if the package can't be created or hasn't changed
+	if err := h.Model.UseEnrollmentToken(tokenValue, checkPublicEnrollmentToken); err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			return apiErr
+		}
+		return publicEnrollmentTokenNotFound(err)
 	}
 
 	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="openuem-config-%s.zip"`, tokenValue[:8]))
@@ -250,23 +641,18 @@ func (h *Handler) PublicDownloadConfig(c echo.Context) error {
 }
 
 func (h *Handler) GetInstallCommand(c echo.Context) error {
-	tokenID, err := strconv.Atoi(c.Param("id"))
+	token, err := h.tenantEnrollmentToken(c)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage("Invalid token ID", true))
+		return err
 	}
 
 	platform := c.QueryParam("platform")
 	switch platform {
-	case "linux", "macos-amd64", "macos-arm64", "windows":
+	case "linux", "macos-amd64", "macos-arm64", "windows", "docker":
 	default:
 		platform = "linux"
 	}
 
-	token, err := h.Model.GetEnrollmentTokenByID(tokenID)
-	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
-	}
-
 	consoleURL := fmt.Sprintf("https://%s", c.Request().Host)
 
 	var command string
@@ -274,44 +660,95 @@ func (h *Handler) GetInstallCommand(c echo.Context) error {
 
 	switch platform {
 	case "linux":
-		command = fmt.Sprintf(`curl -fsSL "%s/api/enroll/%s/install?platform=linux" | sudo bash`, consoleURL, token.Token)
+		command = installOneLiner(consoleURL, token.Token, platform)
 		platformLabel = "Linux"
 	case "macos-amd64":
-		command = fmt.Sprintf(`curl -fsSL "%s/api/enroll/%s/install?platform=macos-amd64" | sudo bash`, consoleURL, token.Token)
+		command = installOneLiner(consoleURL, token.Token, platform)
 		platformLabel = "macOS Intel"
 	case "macos-arm64":
-		command = fmt.Sprintf(`curl -fsSL "%s/api/enroll/%s/install?platform=macos-arm64" | sudo bash`, consoleURL, token.Token)
+		command = installOneLiner(consoleURL, token.Token, platform)
 		platformLabel = "macOS ARM"
 	case "windows":
-		command = fmt.Sprintf(`irm "%s/api/enroll/%s/install?platform=windows" | iex`, consoleURL, token.Token)
+		command = installOneLiner(consoleURL, token.Token, platform)
 		platformLabel = "Windows"
+	case "docker":
+		command = generateDockerCommand(agentNATSURL(h.NATSServers), token.Token)
+		platformLabel = "Docker"
+	}
+
+	// The install scripts are shell commands so they're only run by the bash one-liners
+	if platform != "windows" && platform != "docker" {
+		tenant, err := h.Model.GetTenantByID(token.Edges.Tenant.ID)
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+		command = wrapInstallCommand(command, tenant.PreInstallScript, tenant.PostInstallScript)
 	}
 
 	return RenderView(c, admin_views.InstallCommand(command, platformLabel))
 }
 
-// PublicInstallScript serves a platform-specific install script.
-// The enrollment token value in the URL acts as authentication.
-func (h *Handler) PublicInstallScript(c echo.Context) error {
-	tokenValue := c.Param("token")
-	if tokenValue == "" {
-		return c.String(http.StatusBadRequest, "missing token")
+// installOneLiner returns the command that downloads and runs the install script of the platform
+func installOneLiner(consoleURL, token, platform string) string {
+	if platform == "windows" {
+		return fmt.Sprintf(`irm "%s/api/v1/enroll/%s/install?platform=windows" | iex`, consoleURL, token)
 	}
+	return fmt.Sprintf(`curl -fsSL "%s/api/v1/enroll/%s/install?platform=%s" | sudo bash`, consoleURL, token, platform)
+}
 
-	token, err := h.Model.GetEnrollmentTokenByValue(tokenValue)
+// SaveInstallScripts sets the shell commands that the install command of the tenant runs before and
+// after installing the agent, e.g. to join a domain
+func (h *Handler) SaveInstallScripts(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
-		return c.String(http.StatusNotFound, "invalid token")
+		return err
 	}
 
-	if !token.Active {
-		return c.String(http.StatusForbidden, "token is inactive")
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	preInstall := installScript(c.FormValue("pre_install_script"))
+	postInstall := installScript(c.FormValue("post_install_script"))
+	if err := h.Model.UpdateTenantInstallScripts(tenantID, preInstall, postInstall); err != nil {
+		log.Printf("[ERROR]: could not save the install scripts: %v", err)
+		return RenderModelError(c, err)
 	}
-	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
-		return c.String(http.StatusForbidden, "token has expired")
+
+	return h.renderEnrollmentTokens(c, commonInfo, 0, "")
+}
+
+// installScript normalizes the line endings sent by the browser, bash fails with carriage returns
+func installScript(value string) string {
+	return strings.TrimSpace(strings.ReplaceAll(value, "\r\n", "\n"))
+}
+
+// wrapInstallCommand runs the pre and post install scripts around the install command. Each script
+// is passed as a single quoted argument to bash -c so it can't break out of the one-liner
+func wrapInstallCommand(command, preInstall, postInstall string) string {
+	if preInstall != "" {
+		command = fmt.Sprintf("sudo bash -c %s && %s", shellQuote(preInstall), command)
 	}
-	if token.MaxUses > 0 && token.CurrentUses >= token.MaxUses {
-		return c.String(http.StatusForbidden, "token usage limit reached")
+	if postInstall != "" {
+		command = fmt.Sprintf("%s && sudo bash -c %s", command, shellQuote(postInstall))
 	}
+	return command
+}
+
+// shellQuote quotes s as a single word for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// PublicInstallScript serves a platform-specific install script.
+// The enrollment token value in the URL acts as authentication.
+func (h *Handler) PublicInstallScript(c echo.Context) error {
+	token, err := h.publicEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+	tokenValue := token.Token
 
 	platform := c.QueryParam("platform")
 	switch platform {
@@ -319,6 +756,9 @@ func (h *Handler) PublicInstallScript(c echo.Context) error {
 	default:
 		platform = "linux"
 	}
+	if err := checkEnrollmentTokenPlatform(token, platform); err != nil {
+		return err
+	}
 
 	consoleURL := fmt.Sprintf("https://%s", c.Request().Host)
 
@@ -327,73 +767,86 @@ func (h *Handler) PublicInstallScript(c echo.Context) error {
 
 	switch platform {
 	case "linux":
-		script = generateLinuxScript(consoleURL, tokenValue)
+		script = generateLinuxScript(h.AgentReleaseBaseURL, consoleURL, tokenValue, h.ChecksumURL)
 		contentType = "text/x-shellscript"
 	case "macos-amd64":
-		script = generateMacOSScript(consoleURL, tokenValue, "amd64")
+		script = generateMacOSScript(h.AgentReleaseBaseURL, consoleURL, tokenValue, "amd64", h.ChecksumURL)
 		contentType = "text/x-shellscript"
 	case "macos-arm64":
-		script = generateMacOSScript(consoleURL, tokenValue, "arm64")
+		script = generateMacOSScript(h.AgentReleaseBaseURL, consoleURL, tokenValue, "arm64", h.ChecksumURL)
 		contentType = "text/x-shellscript"
 	case "windows":
-		script = generateWindowsScript(consoleURL, tokenValue)
+		script = generateWindowsScript(h.AgentReleaseBaseURL, consoleURL, tokenValue, h.ChecksumURL)
 		contentType = "text/plain"
 	}
 
 	return c.Blob(http.StatusOK, contentType, []byte(script))
 }
 
-const agentReleaseBaseURL = "https://github.com/open-uem/openuem-agent/releases/latest/download"
+// DefaultAgentReleaseBaseURL is where the install scripts download the agent if no mirror is configured
+const DefaultAgentReleaseBaseURL = "https://github.com/open-uem/openuem-agent/releases/latest/download"
+
+// agentDockerImage is the container image used to run the agent as a sidecar
+const agentDockerImage = "ghcr.io/eigercode/openuem-agent:latest"
 
-func generateLinuxScript(consoleURL, token string) string {
+// generateDockerCommand returns a docker run one-liner for the agent. The container
+// reads the enrollment token and NATS servers from environment variables so no
+// config file is needed, config and certificates are kept in named volumes
+func generateDockerCommand(natsServers, token string) string {
+	return fmt.Sprintf(`docker run -d --name openuem-agent --restart unless-stopped -v openuem-agent-config:/etc/openuem-agent -v openuem-agent-certificates:/etc/openuem-agent/certificates -e OPENUEM_ENROLLMENT_TOKEN=%s -e OPENUEM_NATS_SERVERS=%s %s`, token, natsServers, agentDockerImage)
+}
+
+func generateLinuxScript(releaseURL, consoleURL, token, checksumURL string) string {
+	const agentFile = "openuem-agent-linux-amd64.deb"
 	return fmt.Sprintf(`#!/bin/bash
 set -e
 
 CONFIG_DIR="/etc/openuem-agent"
-RELEASE_URL="%s"
+RELEASE_URL="%[1]s"
 
 echo "Installing OpenUEM Agent..."
 
 # Download and extract config + certificates
 mkdir -p "$CONFIG_DIR"
-curl -fsSL "%s/api/enroll/%s/config?platform=linux" -o /tmp/openuem-config.zip
+curl -fsSL "%[2]s/api/v1/enroll/%[3]s/config?platform=linux" -o /tmp/openuem-config.zip
 unzip -o /tmp/openuem-config.zip -d "$CONFIG_DIR"
 rm /tmp/openuem-config.zip
 
 # Download and install agent
-curl -fsSL "$RELEASE_URL/openuem-agent-linux-amd64.deb" -o /tmp/openuem-agent.deb
-dpkg -i /tmp/openuem-agent.deb
-rm /tmp/openuem-agent.deb
+curl -fsSL "$RELEASE_URL/%[4]s" -o /tmp/%[4]s
+%[5]sdpkg -i /tmp/%[4]s
+rm /tmp/%[4]s
 
 echo "OpenUEM Agent installed successfully."
-`, agentReleaseBaseURL, consoleURL, token)
+`, releaseURL, consoleURL, token, agentFile, shellChecksumStep(checksumURL, agentFile, "sha256sum"))
 }
 
-func generateMacOSScript(consoleURL, token, arch string) string {
+func generateMacOSScript(releaseURL, consoleURL, token, arch, checksumURL string) string {
+	agentFile := fmt.Sprintf("openuem-agent-darwin-%s.pkg", arch)
 	return fmt.Sprintf(`#!/bin/bash
 set -e
 
 CONFIG_DIR="/Library/OpenUEMAgent/etc/openuem-agent"
-RELEASE_URL="%s"
+RELEASE_URL="%[1]s"
 
 echo "Installing OpenUEM Agent..."
 
 # Download and extract config + certificates
 mkdir -p "$CONFIG_DIR"
-curl -fsSL "%s/api/enroll/%s/config?platform=macos" -o /tmp/openuem-config.zip
+curl -fsSL "%[2]s/api/v1/enroll/%[3]s/config?platform=macos" -o /tmp/openuem-config.zip
 unzip -o /tmp/openuem-config.zip -d "$CONFIG_DIR"
 rm /tmp/openuem-config.zip
 
 # Download and install agent
-curl -fsSL "$RELEASE_URL/openuem-agent-darwin-%s.pkg" -o /tmp/openuem-agent.pkg
-installer -pkg /tmp/openuem-agent.pkg -target /
-rm /tmp/openuem-agent.pkg
+curl -fsSL "$RELEASE_URL/%[4]s" -o /tmp/%[4]s
+%[5]sinstaller -pkg /tmp/%[4]s -target /
+rm /tmp/%[4]s
 
 echo "OpenUEM Agent installed successfully."
-`, agentReleaseBaseURL, consoleURL, token, arch)
+`, releaseURL, consoleURL, token, agentFile, shellChecksumStep(checksumURL, agentFile, "shasum -a 256"))
 }
 
-func generateWindowsScript(consoleURL, token string) string {
+func generateWindowsScript(releaseURL, consoleURL, token, checksumURL string) string {
 	return fmt.Sprintf(`$ErrorActionPreference = 'Stop'
 
 $InstallDir = "$env:ProgramFiles\OpenUEM\Agent"
@@ -403,34 +856,89 @@ Write-Host "Installing OpenUEM Agent..."
 
 # Download and extract config + certificates
 New-Item -ItemType Directory -Force -Path $InstallDir | Out-Null
-Invoke-WebRequest "%s/api/enroll/%s/config?platform=windows" -OutFile "$env:TEMP\openuem-config.zip"
+Invoke-WebRequest "%s/api/v1/enroll/%s/config?platform=windows" -OutFile "$env:TEMP\openuem-config.zip"
 Expand-Archive "$env:TEMP\openuem-config.zip" $InstallDir -Force
 Remove-Item "$env:TEMP\openuem-config.zip"
 
 # Download and install agent
 Invoke-WebRequest "$ReleaseURL/openuem-agent-windows-amd64.msi" -OutFile "$env:TEMP\openuem-agent.msi"
-Start-Process msiexec -ArgumentList "/i `+"\""+`$env:TEMP\openuem-agent.msi`+"\""+` /qn" -Wait
+%sStart-Process msiexec -ArgumentList "/i `+"\""+`$env:TEMP\openuem-agent.msi`+"\""+` /qn" -Wait
 Remove-Item "$env:TEMP\openuem-agent.msi"
 
 Write-Host "OpenUEM Agent installed successfully."
-`, agentReleaseBaseURL, consoleURL, token)
+`, releaseURL, consoleURL, token, windowsChecksumStep(checksumURL, "openuem-agent-windows-amd64.msi", `$env:TEMP\openuem-agent.msi`))
+}
+
+// shellChecksumStep returns the commands that check the agent downloaded to /tmp against the checksums
+// file of the release, nothing if there's no checksums file. sumCommand is sha256sum or its macOS
+// counterpart, both read the expected checksum from stdin
+func shellChecksumStep(checksumURL, agentFile, sumCommand string) string {
+	if checksumURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(`curl -fsSL %[1]s -o /tmp/openuem-sha256sums.txt
+CHECKSUM=$(grep -E "[ *]%[2]s$" /tmp/openuem-sha256sums.txt | head -n 1 | cut -d " " -f 1)
+rm /tmp/openuem-sha256sums.txt
+if [ -z "$CHECKSUM" ] || ! echo "$CHECKSUM  /tmp/%[4]s" | %[3]s -c -; then
+  rm -f /tmp/%[4]s
+  echo "The SHA-256 checksum of %[4]s doesn't match, the agent won't be installed." >&2
+  exit 1
+fi
+`, shellQuote(checksumURL), regexp.QuoteMeta(agentFile), sumCommand, agentFile)
+}
+
+// windowsChecksumStep returns the PowerShell commands that compare the hash of the downloaded agent
+// with the one in the checksums file of the release, nothing if there's no checksums file
+func windowsChecksumStep(checksumURL, agentFile, path string) string {
+	if checksumURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(`Invoke-WebRequest %[1]s -OutFile "$env:TEMP\openuem-sha256sums.txt"
+$Checksum = Select-String -Path "$env:TEMP\openuem-sha256sums.txt" -Pattern "[ *]%[2]s$" | Select-Object -First 1
+Remove-Item "$env:TEMP\openuem-sha256sums.txt"
+$Expected = if ($Checksum) { $Checksum.Line.Split(" ")[0] } else { "" }
+if ((Get-FileHash "%[3]s" -Algorithm SHA256).Hash -ne $Expected) {
+    Remove-Item "%[3]s"
+    throw "The SHA-256 checksum of %[4]s doesn't match, the agent won't be installed."
+}
+`, powerShellQuote(checksumURL), regexp.QuoteMeta(agentFile), path, agentFile)
+}
+
+// powerShellQuote quotes s as a single quoted PowerShell string
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// agentLogMaxSizeMB is the size the log file of the agent can reach before it's rotated
+const agentLogMaxSizeMB = 10
+
+// enrollmentLogLevel returns the log level of the site where the agents enrolled with the token are placed,
+// the default site of the tenant if the token has no site
+func (h *Handler) enrollmentLogLevel(token *openuem_ent.EnrollmentToken) string {
+	if token.Edges.Site != nil {
+		return token.Edges.Site.LogLevel.String()
+	}
+
+	if token.Edges.Tenant != nil {
+		s, err := h.Model.GetDefaultSite(token.Edges.Tenant)
+		if err == nil {
+			return s.LogLevel.String()
+		}
+		log.Printf("[ERROR]: could not get the default site of the enrollment token %d, reason: %v", token.ID, err)
+	}
+	return site.LogLevelInfo.String()
 }
 
-func generatePlatformConfigINI(platform, natsServers, token string) string {
+func generatePlatformConfigINI(platform, natsServers, token string, profile *openuem_ent.AgentSettingsProfile, logLevel string) string {
 	var sb strings.Builder
 	sb.WriteString("[Agent]\n")
 	sb.WriteString("UUID=\n")
 	sb.WriteString("Enabled=true\n")
 	sb.WriteString("ExecuteTaskEveryXMinutes=5\n")
-	sb.WriteString("Debug=false\n")
-	sb.WriteString("DefaultFrequency=5\n")
-	sb.WriteString("SFTPPort=2022\n")
-	sb.WriteString("VNCProxyPort=5900\n")
-	sb.WriteString("SFTPDisabled=false\n")
-	sb.WriteString("RemoteAssistanceDisabled=false\n")
+	writeAgentINISettings(&sb, profile)
 	sb.WriteString(fmt.Sprintf("EnrollmentToken=%s\n", token))
 	sb.WriteString("\n[NATS]\n")
-	sb.WriteString(fmt.Sprintf("NATSServers=%s\n", natsServers))
+	sb.WriteString(fmt.Sprintf("NATSServers=%s\n", strings.Join(splitNATSServers(natsServers), ",")))
 	sb.WriteString("\n[Certificates]\n")
 	if platform == "windows" {
 		sb.WriteString("CACert=C:\\Program Files\\OpenUEM\\Agent\\certificates\\ca.cer\n")
@@ -443,24 +951,62 @@ func generatePlatformConfigINI(platform, natsServers, token string) string {
 		sb.WriteString("AgentKey=certificates/agent.key\n")
 		sb.WriteString("SFTPCert=certificates/sftp.cer\n")
 	}
+	writeAgentLoggingINI(&sb, platform, logLevel)
 	return sb.String()
 }
 
-func generateConfigINI(natsServers, token string) string {
+// writeAgentLoggingINI writes the level and the file of the agent log, which is kept in the log folder of the platform
+func writeAgentLoggingINI(sb *strings.Builder, platform, logLevel string) {
+	if logLevel == "" {
+		logLevel = site.LogLevelInfo.String()
+	}
+
+	sb.WriteString("\n[Logging]\n")
+	sb.WriteString(fmt.Sprintf("Level=%s\n", logLevel))
+	switch platform {
+	case "windows":
+		sb.WriteString("FilePath=%ProgramData%\\EigerCode\\logs\\\n")
+	case "macos":
+		sb.WriteString("FilePath=/Library/OpenUEMAgent/var/log/openuem-agent/\n")
+	default:
+		sb.WriteString("FilePath=/var/log/openuem-agent/\n")
+	}
+	sb.WriteString(fmt.Sprintf("MaxSizeMB=%d\n", agentLogMaxSizeMB))
+}
+
+// writeAgentINISettings writes the settings of the agent, those of the settings profile that new agents pick
+// up or the defaults of the agent if there's no profile
+func writeAgentINISettings(sb *strings.Builder, profile *openuem_ent.AgentSettingsProfile) {
+	if profile == nil {
+		sb.WriteString("Debug=false\n")
+		sb.WriteString("DefaultFrequency=5\n")
+		sb.WriteString("SFTPPort=2022\n")
+		sb.WriteString("VNCProxyPort=5900\n")
+		sb.WriteString("SFTPDisabled=false\n")
+		sb.WriteString("RemoteAssistanceDisabled=false\n")
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("Debug=%t\n", profile.DebugMode))
+	sb.WriteString(fmt.Sprintf("DefaultFrequency=%d\n", profile.Frequency))
+	sb.WriteString(fmt.Sprintf("SFTPPort=%s\n", profile.SftpPort))
+	sb.WriteString(fmt.Sprintf("VNCProxyPort=%s\n", profile.VncProxyPort))
+	sb.WriteString(fmt.Sprintf("SFTPDisabled=%t\n", !profile.SftpService))
+	sb.WriteString(fmt.Sprintf("RemoteAssistanceDisabled=%t\n", !profile.RemoteAssistance))
+}
+
+// generateConfigINI returns the configuration of the agent, agentID is the pre-generated UUID of the agent or
+// empty to let the agent generate it
+func generateConfigINI(natsServers, token, agentID string, profile *openuem_ent.AgentSettingsProfile) string {
 	var sb strings.Builder
 	sb.WriteString("[Agent]\n")
-	sb.WriteString("UUID=\n")
+	sb.WriteString(fmt.Sprintf("UUID=%s\n", agentID))
 	sb.WriteString("Enabled=true\n")
 	sb.WriteString("ExecuteTaskEveryXMinutes=5\n")
-	sb.WriteString("Debug=false\n")
-	sb.WriteString("DefaultFrequency=5\n")
-	sb.WriteString("SFTPPort=2022\n")
-	sb.WriteString("VNCProxyPort=5900\n")
-	sb.WriteString("SFTPDisabled=false\n")
-	sb.WriteString("RemoteAssistanceDisabled=false\n")
+	writeAgentINISettings(&sb, profile)
 	sb.WriteString(fmt.Sprintf("EnrollmentToken=%s\n", token))
 	sb.WriteString("\n[NATS]\n")
-	sb.WriteString(fmt.Sprintf("NATSServers=%s\n", natsServers))
+	sb.WriteString(fmt.Sprintf("NATSServers=%s\n", strings.Join(splitNATSServers(natsServers), ",")))
 	sb.WriteString("\n[Certificates]\n")
 	sb.WriteString("CACert=certificates/ca.cer\n")
 	sb.WriteString("AgentCert=certificates/agent.cer\n")
@@ -469,34 +1015,50 @@ func generateConfigINI(natsServers, token string) string {
 	return sb.String()
 }
 
-// agentNATSURL returns the external NATS URL for agent configs.
-// It combines NATS_SERVER (external host) and NATS_PORT (external port),
+// agentNATSURL returns the external NATS URLs for agent configs as a comma-separated list.
+// Each server in NATS_SERVER (external hosts) is combined with NATS_PORT (external port),
 // falling back to the internal NATS_SERVERS value.
 func agentNATSURL(fallback string) string {
 	server := os.Getenv("NATS_SERVER")
 	port := os.Getenv("NATS_PORT")
 
 	if server == "" {
-		return fallback
+		return strings.Join(splitNATSServers(fallback), ",")
+	}
+
+	servers := []string{}
+	for _, s := range splitNATSServers(server) {
+		servers = append(servers, deriveExternalNATSURL(s, port))
 	}
+	return strings.Join(servers, ",")
+}
 
+// deriveExternalNATSURL returns the tls:// URL of an external NATS server,
+// replacing its port with the external port if one is set
+func deriveExternalNATSURL(server, port string) string {
 	// Strip scheme if present, we'll add tls:// ourselves
 	host := strings.TrimPrefix(strings.TrimPrefix(server, "tls://"), "nats://")
 
 	if port != "" {
-		return "tls://" + host + ":" + port
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return "tls://" + net.JoinHostPort(host, port)
 	}
 	return "tls://" + host
 }
 
-func (h *Handler) listEnrollmentTokensWithError(c echo.Context, commonInfo *partials.CommonInfo, errMsg string) error {
-	tenantID, _ := strconv.Atoi(commonInfo.TenantID)
-	tokens, _ := h.Model.GetEnrollmentTokens(tenantID)
-	sites, _ := h.Model.GetSites(tenantID)
-	agentsExists, _ := h.Model.AgentsExists(commonInfo)
-	serversExists, _ := h.Model.ServersExists()
+// splitNATSServers splits a comma-separated list of NATS servers, ignoring empty entries
+func splitNATSServers(natsServers string) []string {
+	servers := []string{}
+	for _, s := range strings.Split(natsServers, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
 
-	return RenderView(c, admin_views.EnrollmentTokensIndex(" | Enrollment",
-		admin_views.EnrollmentTokens(c, tokens, sites, errMsg, agentsExists, serversExists, commonInfo),
-		commonInfo))
+func (h *Handler) listEnrollmentTokensWithError(c echo.Context, commonInfo *partials.CommonInfo, errMsg string) error {
+	return h.renderEnrollmentTokens(c, commonInfo, 0, errMsg)
 }
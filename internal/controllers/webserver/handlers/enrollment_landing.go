@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/enroll_views"
+)
+
+// landingPlatforms are the platforms offered in the enrollment landing page, in the order they're shown
+var landingPlatforms = []enroll_views.LandingPlatform{
+	{ID: "windows", Label: "Windows", Badge: "windows", Installer: "openuem-agent-windows-amd64.msi", ConfigDir: `%ProgramFiles%\OpenUEM\Agent`},
+	{ID: "macos-arm64", Label: "macOS Apple Silicon", Badge: "darwin", Installer: "openuem-agent-darwin-arm64.pkg", ConfigDir: "/Library/OpenUEMAgent/etc/openuem-agent"},
+	{ID: "macos-amd64", Label: "macOS Intel", Badge: "darwin", Installer: "openuem-agent-darwin-amd64.pkg", ConfigDir: "/Library/OpenUEMAgent/etc/openuem-agent"},
+	{ID: "linux", Label: "Linux (Debian, Ubuntu)", Badge: "debian", Installer: "openuem-agent-linux-amd64.deb", ConfigDir: "/etc/openuem-agent"},
+}
+
+// EnrollmentLandingPage is the public page where end users pick their operating system and get the
+// instructions to enroll their device with the token. It only exists while the token can enroll agents
+// and has the page enabled, and it shows nothing about the tenant but the branding of the console
+func (h *Handler) EnrollmentLandingPage(c echo.Context) error {
+	token, remainingUses, err := h.Model.GetEnrollmentTokenByValue(c.Param("token"))
+	if err != nil || !token.LandingPage || checkPublicEnrollmentToken(token, remainingUses) != nil {
+		return echo.ErrNotFound
+	}
+
+	consoleURL := fmt.Sprintf("https://%s", c.Request().Host)
+	platforms := []enroll_views.LandingPlatform{}
+	var selected *enroll_views.LandingPlatform
+	for _, p := range landingPlatforms {
+		if checkEnrollmentTokenPlatform(token, p.ID) != nil {
+			continue
+		}
+		p.URL = fmt.Sprintf("/enroll/%s?platform=%s", token.Token, p.ID)
+		p.Command = installOneLiner(consoleURL, token.Token, p.ID)
+		p.InstallerURL = h.AgentReleaseBaseURL + "/" + p.Installer
+		system, _, _ := strings.Cut(p.ID, "-")
+		p.ConfigURL = fmt.Sprintf("%s/api/v1/enroll/%s/config?platform=%s", consoleURL, token.Token, system)
+		platforms = append(platforms, p)
+		if p.ID == c.QueryParam("platform") {
+			selected = &platforms[len(platforms)-1]
+		}
+	}
+
+	branding, err := h.Model.GetOrCreateBranding()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the branding for the enrollment landing page, reason: %v", err)
+	}
+
+	// The page holds the token, it mustn't be kept by shared caches or leak in the referrer
+	c.Response().Header().Set("Referrer-Policy", "no-referrer")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTML)
+	c.Response().Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	return enroll_views.LandingIndex(enroll_views.Landing(platforms, selected, branding), branding).Render(c.Request().Context(), c.Response().Writer)
+}
+
+// ToggleEnrollmentTokenLandingPage publishes or hides the landing page of the token
+func (h *Handler) ToggleEnrollmentTokenLandingPage(c echo.Context) error {
+	token, err := h.tenantEnrollmentToken(c)
+	if err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Model.ToggleEnrollmentTokenLandingPage(token.ID, c.FormValue("landing_page") == "true"); err != nil {
+		log.Printf("[ERROR]: could not toggle the landing page of the enrollment token: %v", err)
+		return RenderModelError(c, err)
+	}
+
+	token, err = h.Model.GetEnrollmentTokenByIDForTenant(token.ID, token.Edges.Tenant.ID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get enrollment token: %v", err)
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.EnrollmentTokenRow(token, true, false, commonInfo))
+}
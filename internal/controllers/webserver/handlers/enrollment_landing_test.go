@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent/enrollmenttoken"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrollmentLandingPage(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:landing?mode=memory&_fk=1")
+	t.Cleanup(func() { client.Close() })
+
+	h := &Handler{Model: &models.Model{Client: client}}
+	e := echo.New()
+	e.GET("/enroll/:token", h.EnrollmentLandingPage)
+
+	m := models.Model{Client: client}
+	tenant, err := m.CreateDefaultTenant()
+	assert.NoError(t, err)
+	for value, allowedOS := range map[string][]string{
+		"11111111-2222-3333-4444-555555555555": nil,
+		"66666666-2222-3333-4444-555555555555": {"windows"},
+		"77777777-2222-3333-4444-555555555555": nil,
+	} {
+		_, err := m.CreateEnrollmentToken(tenant.ID, models.EnrollmentTokenSites{}, "Branch "+value[:1], value, 0, nil, allowedOS)
+		assert.NoError(t, err)
+	}
+	err = client.EnrollmentToken.Update().Where(enrollmenttoken.TokenNEQ("77777777-2222-3333-4444-555555555555")).SetLandingPage(true).Exec(context.Background())
+	assert.NoError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/enroll/unknown").Code, "should not find an unknown token")
+	assert.Equal(t, http.StatusNotFound, get("/enroll/77777777-2222-3333-4444-555555555555").Code, "should not find the page if it's not enabled")
+
+	rec := get("/enroll/66666666-2222-3333-4444-555555555555?platform=windows")
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		doc, err := goquery.NewDocumentFromReader(rec.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, doc.Find("#landing-platforms a").Length(), "should only offer the platforms the token allows")
+		assert.Contains(t, doc.Find("#landing-command").Text(), "/api/v1/enroll/66666666-2222-3333-4444-555555555555/install?platform=windows")
+		assert.Contains(t, doc.Find("#landing-config").AttrOr("href", ""), "config?platform=windows")
+		assert.NotContains(t, doc.Text(), "Branch 6", "should not show the description of the token")
+		assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"))
+	}
+
+	rec = get("/enroll/11111111-2222-3333-4444-555555555555")
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		doc, err := goquery.NewDocumentFromReader(rec.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, len(landingPlatforms), doc.Find("#landing-platforms a").Length())
+		assert.Equal(t, 0, doc.Find("#landing-instructions").Length(), "should wait for the user to choose a platform")
+	}
+
+	err = client.EnrollmentToken.Update().SetActive(false).Exec(context.Background())
+	assert.NoError(t, err)
+	rec = get("/enroll/11111111-2222-3333-4444-555555555555")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "should not find the page of a disabled token")
+	assert.False(t, strings.Contains(rec.Body.String(), "11111111"), "should not echo the token")
+}
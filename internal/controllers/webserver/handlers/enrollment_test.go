@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/release"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrollmentTokenDescription(t *testing.T) {
+	description, ok := enrollmentTokenDescription("  Office Berlin ")
+	assert.True(t, ok, "should accept a description")
+	assert.Equal(t, "Office Berlin", description, "should trim the description")
+
+	_, ok = enrollmentTokenDescription("")
+	assert.False(t, ok, "should require a description")
+
+	_, ok = enrollmentTokenDescription(" \t\n ")
+	assert.False(t, ok, "should not accept a blank description")
+}
+
+func TestGenerateConfigINIWithMultipleNATSServers(t *testing.T) {
+	t.Setenv("NATS_SERVER", "nats1.example.com, tls://nats2.example.com:4222")
+	t.Setenv("NATS_PORT", "4433")
+
+	ini := generateConfigINI(agentNATSURL("nats-internal:4222"), "token", "", nil)
+	assert.Contains(t, ini, "NATSServers=tls://nats1.example.com:4433,tls://nats2.example.com:4433\n", "should derive the external URL of each server")
+
+	t.Setenv("NATS_SERVER", "")
+	ini = generateConfigINI(agentNATSURL("tls://nats1:4433, tls://nats2:4433"), "token", "", nil)
+	assert.Contains(t, ini, "NATSServers=tls://nats1:4433,tls://nats2:4433\n", "should keep the internal servers")
+}
+
+func TestGenerateConfigINIWithAgentID(t *testing.T) {
+	ini := generateConfigINI("tls://nats:4433", "token", "", nil)
+	assert.Contains(t, ini, "[Agent]\nUUID=\n", "should let the agent generate its UUID")
+
+	ini = generateConfigINI("tls://nats:4433", "token", "2b7c7d1e-3f4a-4b5c-8d6e-7f8091a2b3c4", nil)
+	assert.Contains(t, ini, "[Agent]\nUUID=2b7c7d1e-3f4a-4b5c-8d6e-7f8091a2b3c4\n", "should embed the pre-generated UUID")
+}
+
+func TestGenerateConfigINIWithSettingsProfile(t *testing.T) {
+	ini := generateConfigINI("tls://nats:4433", "token", "", nil)
+	assert.Contains(t, ini, "DefaultFrequency=5\nSFTPPort=2022\nVNCProxyPort=5900\nSFTPDisabled=false\n", "should use the defaults of the agent")
+
+	profile := &openuem_ent.AgentSettingsProfile{Frequency: 30, DebugMode: true, SftpPort: "2222", VncProxyPort: "1443", RemoteAssistance: true}
+	ini = generatePlatformConfigINI("windows", "tls://nats:4433", "token", profile, "info")
+	assert.Contains(t, ini, "Debug=true\nDefaultFrequency=30\nSFTPPort=2222\nVNCProxyPort=1443\nSFTPDisabled=true\nRemoteAssistanceDisabled=false\n", "should use the settings of the profile")
+}
+
+func TestGeneratePlatformConfigINILogging(t *testing.T) {
+	ini := generatePlatformConfigINI("linux", "tls://nats:4433", "token", nil, "debug")
+	assert.Contains(t, ini, "\n[Logging]\nLevel=debug\nFilePath=/var/log/openuem-agent/\nMaxSizeMB=10\n", "should use the log level of the site")
+
+	ini = generatePlatformConfigINI("windows", "tls://nats:4433", "token", nil, "")
+	assert.Contains(t, ini, "\n[Logging]\nLevel=info\nFilePath=%ProgramData%\\EigerCode\\logs\\\nMaxSizeMB=10\n", "should log at info level in the log folder of Windows")
+}
+
+func TestConfigNotModified(t *testing.T) {
+	etag := `"abc-linux"`
+	modified := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+
+	request := func(headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/enroll/token/config", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	assert.False(t, configNotModified(request(nil), etag, modified), "should send the config without conditional headers")
+	assert.True(t, configNotModified(request(map[string]string{"If-None-Match": etag}), etag, modified), "should match the ETag")
+	assert.True(t, configNotModified(request(map[string]string{"If-None-Match": `"old", W/"abc-linux"`}), etag, modified), "should match any of the ETags")
+	assert.False(t, configNotModified(request(map[string]string{"If-None-Match": `"abc-windows"`}), etag, modified), "should not match the config of another platform")
+
+	assert.True(t, configNotModified(request(map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}), etag, modified), "should not send the config if it hasn't changed since")
+	assert.False(t, configNotModified(request(map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}), etag, modified), "should send the config if it has changed since")
+	assert.False(t, configNotModified(request(map[string]string{"If-Modified-Since": "yesterday"}), etag, modified), "should ignore invalid dates")
+
+	headers := map[string]string{"If-None-Match": `"old"`, "If-Modified-Since": modified.Format(http.TimeFormat)}
+	assert.False(t, configNotModified(request(headers), etag, modified), "should ignore If-Modified-Since if If-None-Match is sent")
+}
+
+func TestTokenCACertPath(t *testing.T) {
+	h := Handler{CACertPath: "/etc/openuem/ca.cer"}
+
+	token := &openuem_ent.EnrollmentToken{}
+	assert.Equal(t, "/etc/openuem/ca.cer", h.tokenCACertPath(token), "should use the global CA without a tenant")
+
+	token.Edges.Tenant = &openuem_ent.Tenant{}
+	assert.Equal(t, "/etc/openuem/ca.cer", h.tokenCACertPath(token), "should use the global CA if the tenant has none")
+
+	tenantCA := "/etc/openuem/tenants/1/ca.cer"
+	token.Edges.Tenant.CACertPath = &tenantCA
+	assert.Equal(t, tenantCA, h.tokenCACertPath(token), "should use the CA of the tenant")
+}
+
+func TestWrapInstallCommand(t *testing.T) {
+	command := `curl -fsSL "https://console/api/v1/enroll/token/install?platform=linux" | sudo bash`
+	assert.Equal(t, command, wrapInstallCommand(command, "", ""), "should not change the command without scripts")
+
+	wrapped := wrapInstallCommand(command, "realm join --user='admin' example.com", "systemctl restart sssd")
+	assert.Equal(t, `sudo bash -c 'realm join --user='\''admin'\'' example.com' && `+command+` && sudo bash -c 'systemctl restart sssd'`, wrapped, "should quote the scripts")
+
+	assert.Equal(t, "'a\nb'", shellQuote(installScript("a\r\nb\r\n")), "should remove carriage returns")
+}
+
+func TestInstallScriptsVerifyChecksum(t *testing.T) {
+	checksumURL := "https://example.com/releases/sha256sums.txt"
+
+	script := generateLinuxScript(DefaultAgentReleaseBaseURL, "https://console", "token", checksumURL)
+	assert.Contains(t, script, "curl -fsSL 'https://example.com/releases/sha256sums.txt' -o /tmp/openuem-sha256sums.txt")
+	assert.Contains(t, script, `grep -E "[ *]openuem-agent-linux-amd64\.deb$"`)
+	assert.Contains(t, script, "| sha256sum -c -")
+	assert.Less(t, strings.Index(script, "sha256sum -c"), strings.Index(script, "dpkg -i"), "should verify the agent before installing it")
+
+	script = generateMacOSScript(DefaultAgentReleaseBaseURL, "https://console", "token", "arm64", checksumURL)
+	assert.Contains(t, script, `echo "$CHECKSUM  /tmp/openuem-agent-darwin-arm64.pkg" | shasum -a 256 -c -`)
+	assert.Less(t, strings.Index(script, "shasum"), strings.Index(script, "installer -pkg"), "should verify the agent before installing it")
+
+	script = generateWindowsScript(DefaultAgentReleaseBaseURL, "https://console", "token", checksumURL)
+	assert.Contains(t, script, "Invoke-WebRequest 'https://example.com/releases/sha256sums.txt'")
+	assert.Contains(t, script, `(Get-FileHash "$env:TEMP\openuem-agent.msi" -Algorithm SHA256).Hash -ne $Expected`)
+	assert.Less(t, strings.Index(script, "Get-FileHash"), strings.Index(script, "Start-Process msiexec"), "should verify the agent before installing it")
+
+	assert.NotContains(t, generateLinuxScript(DefaultAgentReleaseBaseURL, "https://console", "token", ""), "sha256sums", "should not verify the agent without a checksums file")
+	assert.NotContains(t, generateWindowsScript(DefaultAgentReleaseBaseURL, "https://console", "token", ""), "Get-FileHash", "should not verify the agent without a checksums file")
+}
+
+func TestInstallScriptsReleaseURL(t *testing.T) {
+	mirror := "https://mirror.example.com/openuem-agent"
+
+	assert.Contains(t, generateLinuxScript(mirror, "https://console", "token", ""), `RELEASE_URL="https://mirror.example.com/openuem-agent"`, "should download the agent from the mirror")
+	assert.Contains(t, generateMacOSScript(mirror, "https://console", "token", "amd64", ""), `RELEASE_URL="https://mirror.example.com/openuem-agent"`)
+	assert.Contains(t, generateWindowsScript(mirror, "https://console", "token", ""), `$ReleaseURL = "https://mirror.example.com/openuem-agent"`)
+}
+
+func TestBuildConfigZIPManifest(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	dir := t.TempDir()
+	caCertPath := filepath.Join(dir, "ca.cer")
+	assert.NoError(t, os.WriteFile(caCertPath, []byte("ca certificate"), 0600))
+	at.h.AgentCertPath = filepath.Join(dir, "agent.cer")
+	assert.NoError(t, os.WriteFile(at.h.AgentCertPath, []byte("agent"), 0600))
+	at.h.AgentKeyPath = filepath.Join(dir, "missing.key")
+
+	err := at.h.Model.Client.Release.Create().
+		SetArch("amd64").
+		SetChannel("stable").
+		SetChecksum("checksum").
+		SetFileURL("fileurl").
+		SetIsCritical(false).
+		SetReleaseDate(time.Now()).
+		SetReleaseNotes("url").
+		SetVersion("0.9.1").
+		SetReleaseType(release.ReleaseTypeAgent).
+		Exec(context.Background())
+	assert.NoError(t, err)
+
+	data, err := at.h.buildConfigZIP("[Agent]\n", caCertPath)
+	assert.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+
+	var manifest configManifest
+	for _, f := range zr.File {
+		if f.Name != configManifestName {
+			continue
+		}
+		r, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(content, &manifest))
+	}
+
+	assert.Equal(t, "0.9.1", manifest.AgentVersion, "should include the latest agent release")
+	assert.Equal(t, []configManifestFile{
+		{Name: "openuem.ini", Size: len("[Agent]\n")},
+		{Name: "certificates/ca.cer", Size: len("ca certificate")},
+		{Name: "certificates/agent.cer", Size: len("agent")},
+	}, manifest.Files, "should list the files of the ZIP but not those that couldn't be read")
+}
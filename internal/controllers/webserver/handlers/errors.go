@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// modelError returns the HTTP status and the translated message for an error returned by the model.
+// Database errors may leak schema details so unexpected errors are logged and replaced by a
+// generic message with the request ID, which can be used to find the error in the logs
+func modelError(c echo.Context, err error) (int, string) {
+	ctx := c.Request().Context()
+
+	var validationErr *models.ValidationError
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound, i18n.T(ctx, "errors.not_found")
+	case errors.Is(err, models.ErrAlreadyExists):
+		return http.StatusConflict, i18n.T(ctx, "errors.already_exists")
+	case errors.Is(err, models.ErrForeignKeyInUse):
+		return http.StatusConflict, i18n.T(ctx, "errors.in_use")
+	case errors.Is(err, models.ErrDefaultTenantRequired):
+		return http.StatusConflict, i18n.T(ctx, "tenants.default_required")
+	case errors.Is(err, models.ErrTenantHasNoAdmin):
+		return http.StatusConflict, i18n.T(ctx, "tenants.main_tenant_no_admin")
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity, i18n.T(ctx, "errors.invalid_field", validationErr.Field)
+	}
+
+	requestID := requestID(c)
+	log.Printf("[ERROR]: request %s to %s failed: %v", requestID, c.Request().URL.Path, err)
+	return http.StatusInternalServerError, i18n.T(ctx, "errors.unexpected", requestID)
+}
+
+// RenderModelError shows an error returned by the model in the error message partial.
+// The response keeps the 200 status as htmx doesn't swap error responses
+func RenderModelError(c echo.Context, err error) error {
+	_, message := modelError(c, err)
+	return RenderError(c, partials.ErrorMessage(message, true))
+}
+
+// ModelHTTPError returns an error returned by the model as an HTTP error,
+// for middlewares and endpoints that don't render a view
+func ModelHTTPError(c echo.Context, err error) *echo.HTTPError {
+	return echo.NewHTTPError(modelError(c, err))
+}
+
+// requestID returns the ID set by the request ID middleware, or sets a new one
+func requestID(c echo.Context) string {
+	id := c.Response().Header().Get(echo.HeaderXRequestID)
+	if id == "" {
+		id = uuid.New().String()
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+	}
+	return id
+}
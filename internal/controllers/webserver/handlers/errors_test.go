@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/locales"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelError(t *testing.T) {
+	translations, err := locales.WithFallback("en")
+	assert.NoError(t, err)
+	assert.NoError(t, ctxi18n.LoadWithDefault(translations, "en"))
+
+	dbErr := errors.New("ent: constraint failed: UNIQUE constraint failed: users.email")
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"not found", fmt.Errorf("%w: %w", models.ErrNotFound, dbErr), http.StatusNotFound, "The requested item does not exist or has been removed"},
+		{"already exists", fmt.Errorf("%w: %w", models.ErrAlreadyExists, dbErr), http.StatusConflict, "An item with the same values already exists"},
+		{"in use", fmt.Errorf("%w: %w", models.ErrForeignKeyInUse, dbErr), http.StatusConflict, "This item is still in use and cannot be removed"},
+		{"default tenant", models.ErrDefaultTenantRequired, http.StatusConflict, "This is the current default organization, please choose another organization as default first"},
+		{"main tenant without admin", models.ErrTenantHasNoAdmin, http.StatusConflict, "The organization that would become the main organization has no admins, please assign an admin to it first"},
+		{"validation", &models.ValidationError{Field: "email", Err: dbErr}, http.StatusUnprocessableEntity, "The value of email is not valid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newErrorTestContext(t)
+			status, message := modelError(c, tt.err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.message, message)
+		})
+	}
+
+	t.Run("unexpected", func(t *testing.T) {
+		c := newErrorTestContext(t)
+		c.Response().Header().Set(echo.HeaderXRequestID, "request-1")
+
+		status, message := modelError(c, dbErr)
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Contains(t, message, "request-1", "should show the request ID")
+		assert.NotContains(t, message, "constraint", "should not show the database error")
+
+		he := ModelHTTPError(c, dbErr)
+		assert.Equal(t, http.StatusInternalServerError, he.Code)
+	})
+
+	t.Run("unexpected without request ID", func(t *testing.T) {
+		c := newErrorTestContext(t)
+		_, message := modelError(c, dbErr)
+		id := c.Response().Header().Get(echo.HeaderXRequestID)
+		assert.NotEmpty(t, id, "should set a request ID")
+		assert.Contains(t, message, id)
+	})
+}
+
+func newErrorTestContext(t *testing.T) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, err := ctxi18n.WithLocale(req.Context(), "en")
+	assert.NoError(t, err)
+	return echo.New().NewContext(req.WithContext(ctx), httptest.NewRecorder())
+}
@@ -7,14 +7,20 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/ali-assar/NATS-Leader-Election/leader"
 	"github.com/go-co-op/gocron/v2"
+	mw "github.com/labstack/echo/v4/middleware"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/controllers/api"
+	"github.com/open-uem/openuem-console/internal/controllers/natsstatus"
+	"github.com/open-uem/openuem-console/internal/controllers/nicknames"
+	"github.com/open-uem/openuem-console/internal/controllers/presence"
 	"github.com/open-uem/openuem-console/internal/controllers/sessions"
 	"github.com/open-uem/openuem-console/internal/models"
 )
@@ -22,45 +28,78 @@ import (
 type Handler struct {
 	Model *models.Model
 
-	SessionManager       *sessions.SessionManager
-	JWTKey               string
-	CertPath             string
-	KeyPath              string
-	SFTPKeyPath          string
-	CACertPath           string
-	AgentCertPath        string
-	AgentKeyPath         string
-	SFTPCertPath         string
-	DownloadDir          string
-	ServerName           string
-	AuthPort             string
-	ConsolePort          string
-	Domain               string
-	TaskScheduler        gocron.Scheduler
-	NATSServers          string
-	NATSTimeout          int
-	NATSConnection       *nats.Conn
-	NATSConnectJob       gocron.Job
-	JetStream            jetstream.JetStream
-	JetStreamCancelFunc  context.CancelFunc
-	AgentStream          jetstream.Stream
-	ServerStream         jetstream.Stream
-	OrgName              string
-	OrgProvince          string
-	OrgLocality          string
-	OrgAddress           string
-	Country              string
-	ReverseProxyAuthPort string
-	ReverseProxyServer   string
-	LatestServerRelease  openuem_nats.OpenUEMRelease
-	Replicas             int
-	ServerReleasesFolder string
-	Version              string
-	ReenableCertAuth     bool
-	ReenablePasswdAuth   bool
-	AuthLogger           *log.Logger
-	OIDCRedirectURI      string
-	CommonAppsJob        gocron.Job
+	SessionManager        *sessions.SessionManager
+	JWTKey                string
+	CertPath              string
+	KeyPath               string
+	SFTPKeyPath           string
+	CACertPath            string
+	AgentCertPath         string
+	AgentKeyPath          string
+	SFTPCertPath          string
+	DownloadDir           string
+	ServerName            string
+	AuthPort              string
+	ConsolePort           string
+	Domain                string
+	TaskScheduler         gocron.Scheduler
+	NATSServers           string
+	NATSTimeout           int
+	NATSConnection        *nats.Conn
+	NATSConnectJob        gocron.Job
+	JetStream             jetstream.JetStream
+	JetStreamCancelFunc   context.CancelFunc
+	AgentStream           jetstream.Stream
+	ServerStream          jetstream.Stream
+	OrgName               string
+	OrgProvince           string
+	OrgLocality           string
+	OrgAddress            string
+	Country               string
+	ReverseProxyAuthPort  string
+	ReverseProxyServer    string
+	LatestServerRelease   openuem_nats.OpenUEMRelease
+	Replicas              int
+	ServerReleasesFolder  string
+	Version               string
+	CheckUpdates          bool
+	ReenableCertAuth      bool
+	ReenablePasswdAuth    bool
+	ChecksumURL           string
+	AgentReleaseBaseURL   string
+	AuthLogger            *log.Logger
+	OIDCRedirectURI       string
+	CommonAppsJob         gocron.Job
+	Presence              *presence.Tracker
+	Nicknames             *nicknames.Cache
+	NATSMonitor           *natsstatus.Monitor
+	AgentPresenceJob      gocron.Job
+	PurgeDeletedAgentsJob gocron.Job
+	TenantExportsCleanJob gocron.Job
+	DatabaseBackupJob     gocron.Job
+	AuthAlertsJob         gocron.Job
+	LogCollectionsJob     gocron.Job
+	SettingsProfilesJob   gocron.Job
+	RetentionJob          gocron.Job
+	EmailQueueJob         gocron.Job
+	AgentPlaceholdersJob  gocron.Job
+	APIUsageJob           gocron.Job
+
+	tenantExports *tenantExports
+	tenantImports *tenantImports
+
+	// mainTenantID caches the ID of the main tenant, zero if it hasn't been loaded
+	mainTenantID atomic.Int64
+
+	// apiRateLimiter keeps the requests made with each API token and to each tenant
+	apiRateLimiter api.RateLimiter
+
+	// apiUsage counts the API requests until they're stored
+	apiUsage *apiUsageCounter
+
+	// passwordResetIPLimiter and passwordResetAccountLimiter keep the password resets of each IP and account
+	passwordResetIPLimiter      mw.RateLimiterStore
+	passwordResetAccountLimiter mw.RateLimiterStore
 }
 
 func NewHandler(model *models.Model, natsServers string, s *sessions.SessionManager, ts gocron.Scheduler, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, agentCertPath, agentKeyPath, sftpCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, version string, reEnableCertAuth, reEnablePasswdAuth bool, authLogger *log.Logger) *Handler {
@@ -103,26 +142,114 @@ func NewHandler(model *models.Model, natsServers string, s *sessions.SessionMana
 		ReverseProxyServer:   reverseProxyServer,
 		Replicas:             len(replicas),
 		ServerReleasesFolder: serverReleasesFolder,
+		AgentReleaseBaseURL:  DefaultAgentReleaseBaseURL,
 		Version:              version,
 		ReenableCertAuth:     reEnableCertAuth,
 		ReenablePasswdAuth:   reEnablePasswdAuth,
 		AuthLogger:           authLogger,
+		Presence:             presence.New(agentPresenceTimeout),
+		Nicknames:            nicknames.New(),
+		NATSMonitor:          natsstatus.New(),
+		tenantExports:        newTenantExports(),
+		tenantImports:        newTenantImports(),
+		apiRateLimiter:       api.NewMemoryRateLimiter(apiRateLimiterExpiration),
+		apiUsage:             newAPIUsageCounter(),
 	}
 
+	// Password resets are limited for each IP and account, per minute and per hour
+	h.passwordResetIPLimiter = mw.NewRateLimiterMemoryStoreWithConfig(mw.RateLimiterMemoryStoreConfig{Rate: passwordResetIPRate / 60.0, Burst: passwordResetIPRate, ExpiresIn: 10 * time.Minute})
+	h.passwordResetAccountLimiter = mw.NewRateLimiterMemoryStoreWithConfig(mw.RateLimiterMemoryStoreConfig{Rate: passwordResetAccountRate / 3600.0, Burst: passwordResetAccountRate, ExpiresIn: 2 * time.Hour})
+
 	// Try to create the NATS Connection and start a job if it can't be possible to connect
 	if err := h.StartNATSConnectJob(); err != nil {
 		log.Fatalf("[FATAL]: could not start NATS Connect job")
 	}
 
+	// Keep track of the agents presence from their heartbeats
+	if err := h.StartAgentPresenceJob(); err != nil {
+		log.Printf("[ERROR]: could not start the agents presence job, reason: %v", err)
+	}
+
+	// Remove the agents that have been in the recycle bin longer than the retention
+	if err := h.StartPurgeDeletedAgentsJob(); err != nil {
+		log.Printf("[ERROR]: could not start the deleted agents purge job, reason: %v", err)
+	}
+
+	// Remove the tenant exports that haven't been downloaded before they expired
+	if err := h.StartTenantExportsCleanJob(); err != nil {
+		log.Printf("[ERROR]: could not start the tenant exports clean job, reason: %v", err)
+	}
+
+	// Run the scheduled database backups
+	if err := h.StartDatabaseBackupJob(); err != nil {
+		log.Printf("[ERROR]: could not start the database backup job, reason: %v", err)
+	}
+
+	// Raise the authentication alerts of the tenants
+	if err := h.StartAuthAlertsJob(); err != nil {
+		log.Printf("[ERROR]: could not start the authentication alerts job, reason: %v", err)
+	}
+
+	// Collect the logs of the agents when they come online and remove the expired logs
+	if err := h.StartAgentLogCollectionsJob(); err != nil {
+		log.Printf("[ERROR]: could not start the agent log collections job, reason: %v", err)
+	}
+
+	// Push the settings profiles to the agents that haven't applied them
+	if err := h.StartAgentSettingsProfilesJob(); err != nil {
+		log.Printf("[ERROR]: could not start the agent settings profiles job, reason: %v", err)
+	}
+
+	// Purge the historical data older than the retention set by the admins
+	if err := h.StartRetentionJob(); err != nil {
+		log.Printf("[ERROR]: could not start the data retention job, reason: %v", err)
+	}
+
+	// Deliver the queued emails
+	if err := h.StartEmailQueueJob(); err != nil {
+		log.Printf("[ERROR]: could not start the email queue job, reason: %v", err)
+	}
+
+	// Complete the pre-generated agent UUIDs when their agents report and remove those never used
+	if err := h.StartAgentPlaceholdersJob(); err != nil {
+		log.Printf("[ERROR]: could not start the agent placeholders job, reason: %v", err)
+	}
+
+	// Store the usage of the API counted in memory
+	if err := h.StartAPIUsageJob(); err != nil {
+		log.Printf("[ERROR]: could not start the API usage job, reason: %v", err)
+	}
+
 	return &h
 }
 
+// Close cancels the JetStream context and drains the NATS connection so
+// pending messages are delivered before the connection is closed
+func (h *Handler) Close() {
+	h.flushAPIUsage()
+
+	if h.JetStreamCancelFunc != nil {
+		h.JetStreamCancelFunc()
+	}
+
+	if h.NATSConnection != nil && !h.NATSConnection.IsClosed() {
+		if err := h.NATSConnection.Drain(); err != nil {
+			log.Printf("[ERROR]: could not drain the NATS connection, reason: %v", err)
+			h.NATSConnection.Close()
+		}
+	}
+}
+
 func (h *Handler) StartNATSConnectJob() error {
 	var err error
 	var ctx context.Context
 
 	h.NATSConnection, err = openuem_nats.ConnectWithNATS(h.NATSServers, h.CertPath, h.KeyPath, h.CACertPath, "")
 	if err == nil {
+		h.watchNATSConnection()
+		h.subscribeAgentPresence()
+		h.subscribeNicknameChanges()
+
 		h.JetStream, err = jetstream.New(h.NATSConnection)
 		if err == nil {
 			ctx, h.JetStreamCancelFunc = context.WithTimeout(context.Background(), 60*time.Minute)
@@ -184,6 +311,9 @@ func (h *Handler) StartNATSConnectJob() error {
 						log.Printf("[ERROR]: could not connect to NATS %v", err)
 						return
 					}
+					h.watchNATSConnection()
+					h.subscribeAgentPresence()
+					h.subscribeNicknameChanges()
 				}
 
 				if h.JetStream == nil {
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/presence"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/version"
+)
+
+type natsHealth struct {
+	Connected       bool            `json:"connected"`
+	Reconnects      uint64          `json:"reconnects"`
+	LastDisconnect  time.Time       `json:"last_disconnect"`
+	LastReconnect   time.Time       `json:"last_reconnect"`
+	FailedPublishes uint64          `json:"failed_publishes"`
+	Presence        presence.Status `json:"presence"`
+}
+
+type databaseHealth struct {
+	Connected bool `json:"connected"`
+	models.DBStats
+}
+
+type health struct {
+	Status   string            `json:"status"`
+	Version  string            `json:"version"`
+	Commit   string            `json:"commit,omitempty"`
+	NATS     natsHealth        `json:"nats"`
+	Cache    models.CacheStats `json:"cache"`
+	Database databaseHealth    `json:"database"`
+}
+
+// healthPingTimeout is how long the health check waits for the database
+const healthPingTimeout = 2 * time.Second
+
+// HealthCheck reports if the console is up, its version, the state of its NATS connection and agents presence subscription
+// and metrics about the NATS reconnections and failed publishes, the queries cache and the database connection pool. The console can't work without
+// its database so it answers 503 if the database can't be reached
+func (h *Handler) HealthCheck(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), healthPingTimeout)
+	defer cancel()
+	pingErr := h.Model.Ping(ctx)
+	natsStatus := h.NATSMonitor.Status(h.NATSConnection, "")
+
+	status := health{
+		Status:  "ok",
+		Version: h.Version,
+		Commit:  version.GetCommit(),
+		NATS: natsHealth{
+			Connected:       natsStatus.Connected,
+			Reconnects:      natsStatus.Reconnects,
+			LastDisconnect:  natsStatus.LastDisconnect,
+			LastReconnect:   natsStatus.LastReconnect,
+			FailedPublishes: natsStatus.FailedPublishes,
+			Presence:        h.Presence.Status(),
+		},
+		Cache:    h.Model.Cache.Stats(),
+		Database: databaseHealth{Connected: pingErr == nil, DBStats: h.Model.DBStats()},
+	}
+
+	if pingErr != nil {
+		log.Printf("[ERROR]: health check failed, %v", pingErr)
+		status.Status = "down"
+		return c.JSON(http.StatusServiceUnavailable, status)
+	}
+
+	if !status.NATS.Connected || !status.NATS.Presence.Subscribed {
+		status.Status = "degraded"
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
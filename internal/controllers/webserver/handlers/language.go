@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/router/middleware"
+	"github.com/open-uem/openuem-console/internal/views/locales"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// SetLanguage stores the language chosen by the user in a cookie so it can be used
+// before logging in, and in the user's profile if there's a session
+func (h *Handler) SetLanguage(c echo.Context) error {
+	lang := c.FormValue("lang")
+	if !locales.IsSupported(lang) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "language.not_supported"), true))
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     middleware.LanguageCookie,
+		Value:    lang,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	if uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid"); uid != "" {
+		if err := h.Model.UpdateUserLanguage(uid, lang); err != nil {
+			log.Printf("[ERROR]: could not save the language for user %s, reason: %v", uid, err)
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "language.could_not_save"), true))
+		}
+		h.SessionManager.Manager.Put(c.Request().Context(), "lang", lang)
+	}
+
+	// Reload the page so it's rendered with the new language
+	c.Response().Header().Set("HX-Refresh", "true")
+	return c.NoContent(http.StatusOK)
+}
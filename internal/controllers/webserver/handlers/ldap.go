@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// SaveLDAPSettings stores the LDAP connection, search and role mapping settings. The password of the
+// service account is encrypted by the model, the passwords of the users are never stored
+func (h *Handler) SaveLDAPSettings(c echo.Context) error {
+	l, _, errKey := h.ldapSettingsFromForm(c, false)
+	if errKey != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), errKey), true))
+	}
+
+	if l.Enabled && (l.AutoCreate || l.AutoApprove) && l.RoleAdmin == "" && l.RoleOperator == "" && l.RoleUser == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.role_required"), true))
+	}
+
+	l.BindPassword = c.FormValue("ldap-bind-password")
+
+	if err := h.Model.SaveLDAPSettings(l); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.settings_not_saved", err.Error()), true))
+	}
+
+	return h.renderAuthenticationSettings(c, i18n.T(c.Request().Context(), "authentication.settings_saved"))
+}
+
+// TestLDAPConnection binds with the service account of the form, the stored password is used if it's empty
+func (h *Handler) TestLDAPConnection(c echo.Context) error {
+	_, cfg, errKey := h.ldapSettingsFromForm(c, true)
+	if errKey != "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), errKey), true))
+	}
+
+	if err := auth.LDAPTestConnection(cfg); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "ldap.test_failed", err.Error()), true))
+	}
+
+	return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "ldap.test_success")))
+}
+
+// ldapSettingsFromForm validates the LDAP form. It returns the settings to store and the config to connect
+// with the directory, or the key of the error message. The stored bind password is only read to test the connection
+func (h *Handler) ldapSettingsFromForm(c echo.Context, test bool) (models.LDAPSettings, auth.LDAPConfig, string) {
+	l := models.LDAPSettings{
+		Host:         strings.TrimSpace(c.FormValue("ldap-host")),
+		Security:     c.FormValue("ldap-security"),
+		BindDN:       strings.TrimSpace(c.FormValue("ldap-bind-dn")),
+		UserBaseDN:   strings.TrimSpace(c.FormValue("ldap-user-base-dn")),
+		UserFilter:   strings.TrimSpace(c.FormValue("ldap-user-filter")),
+		GroupBaseDN:  strings.TrimSpace(c.FormValue("ldap-group-base-dn")),
+		GroupFilter:  strings.TrimSpace(c.FormValue("ldap-group-filter")),
+		RoleAdmin:    strings.TrimSpace(c.FormValue("ldap-role-admin")),
+		RoleOperator: strings.TrimSpace(c.FormValue("ldap-role-operator")),
+		RoleUser:     strings.TrimSpace(c.FormValue("ldap-role-user")),
+	}
+
+	var err error
+	if l.Enabled, err = strconv.ParseBool(c.FormValue("ldap-enabled")); err != nil {
+		return l, auth.LDAPConfig{}, "ldap.could_not_parse_enabled"
+	}
+	if l.AutoCreate, err = strconv.ParseBool(c.FormValue("ldap-auto-create")); err != nil {
+		return l, auth.LDAPConfig{}, "ldap.could_not_parse_auto_create"
+	}
+	if l.AutoApprove, err = strconv.ParseBool(c.FormValue("ldap-auto-approve")); err != nil {
+		return l, auth.LDAPConfig{}, "ldap.could_not_parse_auto_approve"
+	}
+
+	if !slices.Contains([]string{auth.LDAPSecurityNone, auth.LDAPSecurityStartTLS, auth.LDAPSecurityLDAPS}, l.Security) {
+		return l, auth.LDAPConfig{}, "ldap.security_not_valid"
+	}
+
+	l.Port = 389
+	if l.Security == auth.LDAPSecurityLDAPS {
+		l.Port = 636
+	}
+	if port := strings.TrimSpace(c.FormValue("ldap-port")); port != "" {
+		l.Port, err = strconv.Atoi(port)
+		if err != nil || l.Port < 1 || l.Port > 65535 {
+			return l, auth.LDAPConfig{}, "ldap.port_not_valid"
+		}
+	}
+
+	// The settings can be saved disabled while they're being completed
+	if l.Enabled || test {
+		if l.Host == "" {
+			return l, auth.LDAPConfig{}, "ldap.host_required"
+		}
+		if l.UserBaseDN == "" {
+			return l, auth.LDAPConfig{}, "ldap.user_base_dn_required"
+		}
+	}
+	if l.UserFilter != "" && !strings.Contains(l.UserFilter, "{username}") {
+		return l, auth.LDAPConfig{}, "ldap.user_filter_not_valid"
+	}
+	if l.GroupFilter != "" && !strings.Contains(l.GroupFilter, "{dn}") && !strings.Contains(l.GroupFilter, "{username}") {
+		return l, auth.LDAPConfig{}, "ldap.group_filter_not_valid"
+	}
+
+	cfg := auth.LDAPConfig{
+		Host:         l.Host,
+		Port:         l.Port,
+		Security:     l.Security,
+		BindDN:       l.BindDN,
+		BindPassword: c.FormValue("ldap-bind-password"),
+		UserBaseDN:   l.UserBaseDN,
+		UserFilter:   l.UserFilter,
+		GroupBaseDN:  l.GroupBaseDN,
+		GroupFilter:  l.GroupFilter,
+	}
+
+	// The stored password is not shown in the form, it's used if a new one is not entered
+	if test && cfg.BindPassword == "" && cfg.BindDN != "" {
+		settings, err := h.Model.GetAuthenticationSettings()
+		if err != nil {
+			return l, cfg, "authentication.could_not_get_settings"
+		}
+		if cfg.BindPassword, err = h.ldapBindPassword(settings.LDAPBindPassword); err != nil {
+			log.Printf("[ERROR]: could not decrypt the LDAP bind password, reason: %v", err)
+			return l, cfg, "ldap.could_not_decrypt_password"
+		}
+	}
+
+	return l, cfg, ""
+}
+
+// ldapConfig returns the connection settings stored in the database with the decrypted bind password
+func (h *Handler) ldapConfig(settings *ent.Authentication) (auth.LDAPConfig, error) {
+	password, err := h.ldapBindPassword(settings.LDAPBindPassword)
+	if err != nil {
+		return auth.LDAPConfig{}, err
+	}
+
+	return auth.LDAPConfig{
+		Host:         settings.LDAPHost,
+		Port:         settings.LDAPPort,
+		Security:     settings.LDAPSecurity,
+		BindDN:       settings.LDAPBindDN,
+		BindPassword: password,
+		UserBaseDN:   settings.LDAPUserBaseDN,
+		UserFilter:   settings.LDAPUserFilter,
+		GroupBaseDN:  settings.LDAPGroupBaseDN,
+		GroupFilter:  settings.LDAPGroupFilter,
+	}, nil
+}
+
+// ldapBindPassword decrypts the stored password of the service account. Until the encrypt-secrets
+// command is run, the password may still be encrypted with the JWT key
+func (h *Handler) ldapBindPassword(stored string) (string, error) {
+	if stored == "" || auth.IsEncryptedSecret(stored) {
+		return h.Model.OpenSecret(stored)
+	}
+	return auth.DecryptSecret(h.JWTKey, stored)
+}
+
+func ldapGroupRoles(settings *ent.Authentication) groupRoles {
+	return groupRoles{Admin: settings.LDAPRoleAdmin, Operator: settings.LDAPRoleOperator, User: settings.LDAPRoleUser}
+}
+
+// ldapPasswordUser authenticates the user with a bind to the directory and returns its account, which is
+// created the first time if auto-provisioning is enabled. It returns nil if the directory doesn't accept the
+// password, so the next method can be tried, and the key of the error message if the user is denied access
+func (h *Handler) ldapPasswordUser(c echo.Context, username, password string, settings *ent.Authentication) (*ent.User, string) {
+	cfg, err := h.ldapConfig(settings)
+	if err != nil {
+		log.Printf("[ERROR]: could not decrypt the LDAP bind password, reason: %v", err)
+		return nil, ""
+	}
+
+	ldapUser, err := auth.LDAPAuthenticate(cfg, username, password)
+	if err != nil {
+		if errors.Is(err, auth.ErrLDAPInvalidCredentials) {
+			h.AuthLogger.Printf("user %s entered a wrong LDAP password from %s", username, c.RealIP())
+		} else {
+			log.Printf("[ERROR]: could not authenticate %s with LDAP, reason: %v", username, err)
+		}
+		return nil, ""
+	}
+
+	roles := ldapGroupRoles(settings)
+	if !roles.allowed(ldapUser.Groups) {
+		h.AuthLogger.Printf("user %s is not a member of the LDAP groups allowed to log in, from %s", username, c.RealIP())
+		return nil, "ldap.user_not_allowed"
+	}
+
+	uid := strings.ToLower(strings.TrimSpace(username))
+	account, err := h.Model.GetUserById(uid)
+	if err != nil {
+		if !ent.IsNotFound(err) {
+			log.Printf("[ERROR]: could not get user account for username %s, reason: %v", uid, err)
+			return nil, "authentication.cannot_check_if_user_exists"
+		}
+		if !settings.LDAPAutoCreateAccount {
+			return nil, "authentication.an_admin_must_create_your_account"
+		}
+		if err := h.Model.AddLDAPUser(uid, ldapUser.Name, ldapUser.Email, ldapUser.Phone, settings.LDAPAutoApprove); err != nil {
+			log.Printf("[ERROR]: could not create the LDAP user %s, reason: %v", uid, err)
+			return nil, "ldap.could_not_create_user"
+		}
+		log.Printf("[INFO]: the account of the LDAP user %s has been created", uid)
+		if account, err = h.Model.GetUserById(uid); err != nil {
+			log.Printf("[ERROR]: could not get user account for username %s, reason: %v", uid, err)
+			return nil, "authentication.cannot_check_if_user_exists"
+		}
+	}
+
+	// A local or OpenID account with the same name can't be taken over from the directory
+	if !account.Ldap {
+		h.AuthLogger.Printf("user %s logged in with LDAP but the account is not an LDAP account, from %s", uid, c.RealIP())
+		return nil, ""
+	}
+
+	if err := h.assignTenantByGroups(uid, ldapUser.Groups, roles); err != nil {
+		log.Printf("[WARN]: could not assign tenant from LDAP groups for user %s: %v", uid, err)
+	}
+
+	if account.Register != openuem_nats.REGISTER_APPROVED && account.Register != openuem_nats.REGISTER_COMPLETE && !settings.LDAPAutoApprove {
+		return nil, "ldap.account_not_approved"
+	}
+
+	h.AuthLogger.Printf("user %s has logged in with LDAP from %s", uid, c.RealIP())
+	return account, ""
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// listFilters returns the filters of a list found in the values of a request. The number of selected
+// items isn't part of the state of the list as the selection isn't shared
+func listFilters(values url.Values) url.Values {
+	filters := url.Values{}
+	for name, v := range values {
+		if !strings.HasPrefix(name, "filterBy") || name == "filterBySelectedItems" {
+			continue
+		}
+		for _, value := range v {
+			if value != "" {
+				filters.Add(name, value)
+			}
+		}
+	}
+	return filters
+}
+
+// listStateURL returns the URL that shows the list in path with the same page, sort and filters
+func listStateURL(path string, p partials.PaginationAndSort, filters url.Values) string {
+	query := url.Values{}
+	for name, v := range filters {
+		query[name] = v
+	}
+	query.Set("page", strconv.Itoa(p.CurrentPage))
+	query.Set("pageSize", strconv.Itoa(p.PageSize))
+	if p.SortBy != "" {
+		query.Set("sortBy", p.SortBy)
+		query.Set("sortOrder", p.SortOrder)
+	}
+
+	// Encode sorts the parameters so the same state always has the same URL
+	return path + "?" + query.Encode()
+}
+
+// pushListState sets the URL of the browser to the URL of the list with its current state after an
+// HTMX swap, so the list is shown again as it is if the page is refreshed or the URL is shared. The
+// views use the path pushed for their sort and filter forms, see partials.GetListUrl
+func pushListState(c echo.Context, path string, p partials.PaginationAndSort, filters url.Values) {
+	if c.Request().Header.Get("HX-Request") != "true" {
+		return
+	}
+
+	// Lists refreshed periodically would fill the history with the same URL
+	stateURL := listStateURL(path, p, filters)
+	if u, err := url.Parse(c.Request().Header.Get("Hx-Current-Url")); err == nil && u.Path+"?"+u.Query().Encode() == stateURL {
+		return
+	}
+	c.Response().Header().Set("HX-Push-Url", stateURL)
+}
+
+// requestListFilters returns the filters of the list sent with the request
+func requestListFilters(c echo.Context) url.Values {
+	values, err := c.FormParams()
+	if err != nil {
+		return url.Values{}
+	}
+	return listFilters(values)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListStateURL(t *testing.T) {
+	values := url.Values{
+		"filterByNickname":      {"desk"},
+		"filterByStatusAgent1":  {"Enabled", ""},
+		"filterBySelectedItems": {"3"},
+		"filterByAgentOS0":      {""},
+		"tagId":                 {"1"},
+	}
+	p := partials.PaginationAndSort{CurrentPage: 2, PageSize: 10, SortBy: "nickname", SortOrder: "asc"}
+
+	assert.Equal(t,
+		"/tenant/1/agents?filterByNickname=desk&filterByStatusAgent1=Enabled&page=2&pageSize=10&sortBy=nickname&sortOrder=asc",
+		listStateURL("/tenant/1/agents", p, listFilters(values)),
+		"should only keep the filters applied and the pagination and sort")
+
+	p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+	assert.Equal(t, "/tenant/1/admin/members?page=1&pageSize=5", listStateURL("/tenant/1/admin/members", p, url.Values{}))
+}
+
+func TestPushListState(t *testing.T) {
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+	push := func(currentURL string, htmx bool) string {
+		req := httptest.NewRequest(http.MethodGet, "/tenant/1/admin/enrollment", nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+			req.Header.Set("HX-Current-URL", currentURL)
+		}
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		pushListState(c, "/tenant/1/admin/enrollment", p, url.Values{"filterByDescription": {"office"}})
+		return c.Response().Header().Get("HX-Push-Url")
+	}
+
+	assert.Equal(t, "/tenant/1/admin/enrollment?filterByDescription=office&page=1&pageSize=5", push("http://localhost/tenant/1/admin/enrollment", true))
+	assert.Empty(t, push("", false), "should not push URLs for full page loads")
+	assert.Empty(t, push("http://localhost/tenant/1/admin/enrollment?pageSize=5&page=1&filterByDescription=office", true), "should not push the URL already shown")
+}
+
+func TestClampCurrentPage(t *testing.T) {
+	p := partials.NewPaginationAndSort(5)
+	p.GetPaginationAndSortParams("-3", "0", "", "sideways", "", 5)
+	assert.Equal(t, 1, p.CurrentPage, "should go to the first page if the page is invalid")
+	assert.Equal(t, 5, p.PageSize, "should use the default page size if it's invalid")
+	assert.Equal(t, "desc", p.SortOrder)
+
+	p.GetPaginationAndSortParams("40", "5", "", "", "", 5)
+	p.NItems = 12
+	p.ClampCurrentPage()
+	assert.Equal(t, 3, p.CurrentPage, "should go to the last page if the page doesn't exist")
+
+	p.NItems = 0
+	p.ClampCurrentPage()
+	assert.Equal(t, 1, p.CurrentPage, "empty lists have one page")
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"image/png"
 	"io"
@@ -22,6 +21,8 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/open-uem/ent"
 	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/login_views"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 	"github.com/pquerna/otp/totp"
@@ -72,31 +73,44 @@ func (h *Handler) LoginPasswordAuth(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.password_empty"), true))
 	}
 
-	user, err := h.Model.GetUserById(username)
-	if err != nil {
-		log.Printf("[ERROR]: could not get user account for username %s, reason: %v", username, err)
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.wrong_username_or_password"), true))
-	}
-
-	if user.Hash == "" {
-		log.Println("[ERROR]: hash is empty, maybe there was an issue with migration!")
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.wrong_username_or_password"), true))
+	// The password isn't checked while the account is locked, so it can't be guessed
+	if h.loginLockedOut(c, username) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.account_locked"), true))
 	}
 
-	// Check if passwords match
-	match, err := argon2id.ComparePasswordAndHash(password, user.Hash)
+	settings, err := h.Model.GetAuthenticationSettings()
 	if err != nil {
-		log.Printf("[ERROR]: could not compare password and hash for user %s, reason: %v", username, err)
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.wrong_username_or_password"), true))
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.could_not_get_settings"), true))
+	}
+
+	// Local accounts and LDAP are tried in the configured order, the first one that accepts the password wins
+	var user *ent.User
+	for _, method := range auth.ParseAuthOrder(settings.AuthOrder) {
+		switch {
+		case method == auth.LOCAL && settings.UsePasswd:
+			user = h.localPasswordUser(c, username, password, settings)
+		case method == auth.LDAP && settings.UseLDAP:
+			var denied string
+			user, denied = h.ldapPasswordUser(c, username, password, settings)
+			if denied != "" {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), denied), true))
+			}
+		}
+		if user != nil {
+			break
+		}
 	}
 
-	if !match {
-		h.AuthLogger.Printf("user %s entered a wrong password", username)
+	if user == nil {
+		h.recordAuthEvent(c, models.AuthEventLoginFailed, username)
+		h.recordLoginAttempt(c, username, false)
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.wrong_username_or_password"), true))
 	}
 
+	h.recordLoginAttempt(c, username, true)
+
 	// Check if user is forced to change password
-	if user.Register == openuem_nats.REGISTER_FORCE_PASSWORD_CHANGE {
+	if user.Register == openuem_nats.REGISTER_FORCE_PASSWORD_CHANGE && !user.Ldap {
 		csrfToken, ok := c.Get("csrf").(string)
 		if !ok || csrfToken == "" {
 			return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "authentication.csrf_token_not_found"))
@@ -119,7 +133,7 @@ func (h *Handler) LoginPasswordAuth(c echo.Context) error {
 
 	if user.Use2fa {
 		if user.TotpSecretConfirmed {
-			return RenderLoginPartial(c, login_views.Use2FA(username))
+			return RenderLoginPartial(c, login_views.Use2FA(user.ID))
 		} else {
 			return h.Register2FA(c)
 		}
@@ -128,12 +142,63 @@ func (h *Handler) LoginPasswordAuth(c echo.Context) error {
 	return h.AccessGranted(c, user)
 }
 
+// localPasswordUser returns the local account if the password matches its hash. The user must change
+// the password if it's older than the maximum age of the password policy
+func (h *Handler) localPasswordUser(c echo.Context, username, password string, settings *ent.Authentication) *ent.User {
+	user, err := h.Model.GetUserById(username)
+	if err != nil {
+		log.Printf("[ERROR]: could not get user account for username %s, reason: %v", username, err)
+		return nil
+	}
+
+	// LDAP and OpenID accounts have no hash
+	if user.Hash == "" {
+		if !user.Ldap && !user.Openid {
+			log.Println("[ERROR]: hash is empty, maybe there was an issue with migration!")
+		}
+		return nil
+	}
+
+	// Check if passwords match
+	match, err := argon2id.ComparePasswordAndHash(password, user.Hash)
+	if err != nil {
+		log.Printf("[ERROR]: could not compare password and hash for user %s, reason: %v", username, err)
+		return nil
+	}
+
+	if !match {
+		h.AuthLogger.Printf("user %s entered a wrong password from %s", username, c.RealIP())
+		return nil
+	}
+
+	// Accounts whose password was set before it was recorded are checked from their creation
+	changedAt := user.PasswordChangedAt
+	if changedAt.IsZero() {
+		changedAt = user.Created
+	}
+	if user.Register != openuem_nats.REGISTER_FORCE_PASSWORD_CHANGE && models.PasswordPolicyFromSettings(settings).Expired(changedAt, time.Now()) {
+		if err := h.Model.ForcePasswordChange(user.ID); err != nil {
+			log.Printf("[ERROR]: could not force the password change of user %s, reason: %v", user.ID, err)
+		} else {
+			h.AuthLogger.Printf("the password of user %s has expired", user.ID)
+			user.Register = openuem_nats.REGISTER_FORCE_PASSWORD_CHANGE
+		}
+	}
+
+	return user
+}
+
 func (h *Handler) LoginPasswordChange(c echo.Context) error {
 	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
 	if username == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.username_empty"), true))
 	}
 
+	// Only the sessions created to set a new password can change it without the current password
+	if !h.SessionManager.Manager.GetBool(c.Request().Context(), "forgot") {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.password_change_not_allowed"), true))
+	}
+
 	password := c.FormValue("password")
 	if password == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.password_empty"), true))
@@ -148,8 +213,8 @@ func (h *Handler) LoginPasswordChange(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.passwords_dont_match"), true))
 	}
 
-	if err := ValidatePasswordComplexity(password); err != nil {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.password_complexity_invalid"), true))
+	if err := h.Model.CheckPasswordPolicy(username, password); err != nil {
+		return RenderPasswordPolicyError(c, err)
 	}
 
 	if err := h.Model.ChangePassword(username, password); err != nil {
@@ -164,7 +229,16 @@ func (h *Handler) LoginPasswordChange(c echo.Context) error {
 	}
 
 	// Password has been changed
-	h.AuthLogger.Printf("user %s has changed the password", username)
+	h.AuthLogger.Printf("user %s has changed the password from %s", username, c.RealIP())
+
+	// Close the sessions of the user, anyone who knew the old password is logged out
+	if err := h.Model.DeleteUserSessions(username); err != nil {
+		log.Printf("[ERROR]: could not close the sessions of user %s, reason: %v", username, err)
+	}
+
+	if user, err := h.Model.GetUserById(username); err == nil {
+		go h.notifyPasswordChanged(user)
+	}
 
 	// Redirect to login
 	return h.Login(c)
@@ -248,7 +322,7 @@ func (h *Handler) LoginTOTPConfirm(c echo.Context) error {
 	}
 
 	// 2FA has been enabled
-	h.AuthLogger.Printf("user %s has enabled 2FA", username)
+	h.AuthLogger.Printf("user %s has enabled 2FA from %s", username, c.RealIP())
 
 	if err := h.SessionManager.Manager.RenewToken(c.Request().Context()); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -257,9 +331,13 @@ func (h *Handler) LoginTOTPConfirm(c echo.Context) error {
 	h.SessionManager.Manager.Put(c.Request().Context(), "uid", user.ID)
 	h.SessionManager.Manager.Put(c.Request().Context(), "username", user.Name)
 	h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
-	h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+	h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 	h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 	h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
+	h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+	h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+	h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+	h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
 	h.SessionManager.Manager.Put(c.Request().Context(), "twofa", true)
 	token, expiry, err := h.SessionManager.Manager.Commit(c.Request().Context())
 	if err != nil {
@@ -276,6 +354,7 @@ func (h *Handler) LoginTOTPConfirm(c echo.Context) error {
 	if err := h.Model.ConfirmLogIn(user.ID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	h.updateUserLastSeen(user)
 
 	// TODO - Get user's default tenant and site
 	myTenant, err := h.Model.GetDefaultTenant()
@@ -331,6 +410,7 @@ func (h *Handler) LoginTOTPValidate(c echo.Context) error {
 	valid := totp.Validate(passcode, user.TotpSecret)
 	if !valid {
 		log.Println("[ERROR]: the TOTP code is not valid")
+		h.recordAuthEvent(c, models.AuthEventLoginFailed, user.ID)
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.totp_wrong_setup"), true))
 	}
 
@@ -361,6 +441,7 @@ func (h *Handler) LoginTOTPBackupCheck(c echo.Context) error {
 
 	isValid := h.Model.ConsumeRecoveryCode(username, code)
 	if !isValid {
+		h.recordAuthEvent(c, models.AuthEventLoginFailed, username)
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.totp_wrong_recovery_code"), true))
 	}
 
@@ -389,9 +470,13 @@ func (h *Handler) NewSession(c echo.Context, user *ent.User) error {
 		h.SessionManager.Manager.Put(c.Request().Context(), "uid", user.ID)
 		h.SessionManager.Manager.Put(c.Request().Context(), "username", user.Name)
 		h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
-		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 		h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 		h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
+		h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+		h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+		h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+		h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
 		h.SessionManager.Manager.Put(c.Request().Context(), "twofa", false)
 		token, expiry, err := h.SessionManager.Manager.Commit(c.Request().Context())
 		if err != nil {
@@ -414,7 +499,11 @@ func (h *Handler) AccessGranted(c echo.Context, user *ent.User) error {
 	h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
 	h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 	h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
-	h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+	h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+	h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+	h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+	h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
+	h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 	if user.Use2fa {
 		h.SessionManager.Manager.Put(c.Request().Context(), "twofa", true)
 	}
@@ -433,6 +522,7 @@ func (h *Handler) AccessGranted(c echo.Context, user *ent.User) error {
 	if err := h.Model.ConfirmLogIn(user.ID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	h.updateUserLastSeen(user)
 
 	// TODO - Get user's default tenant and site
 	myTenant, err := h.Model.GetDefaultTenant()
@@ -447,16 +537,18 @@ func (h *Handler) AccessGranted(c echo.Context, user *ent.User) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
+	h.recordAuthEvent(c, models.AuthEventLoginSucceeded, user.ID)
+
 	if h.AuthLogger != nil {
 		if user.Passwd {
 			if user.Use2fa {
-				h.AuthLogger.Printf("user %s has logged in with a password and using 2FA", user.ID)
+				h.AuthLogger.Printf("user %s has logged in with a password and using 2FA from %s", user.ID, c.RealIP())
 			} else {
-				h.AuthLogger.Printf("user %s has logged in with a password", user.ID)
+				h.AuthLogger.Printf("user %s has logged in with a password from %s", user.ID, c.RealIP())
 			}
 		} else {
 			if user.Use2fa {
-				h.AuthLogger.Printf("user %s has logged in with a certificate and using 2FA", user.ID)
+				h.AuthLogger.Printf("user %s has logged in with a certificate and using 2FA from %s", user.ID, c.RealIP())
 			}
 		}
 	}
@@ -498,104 +590,62 @@ func generateRecoveryCode() (string, error) {
 	return fmt.Sprintf("%s-%s-%s-%s", randomCode[0:4], randomCode[4:8], randomCode[8:12], randomCode[12:16]), nil
 }
 
+// ForgotPasswordEmail emails a code to reset the password of a local account, found by its username or email.
+// The response is the same whether the account exists or not so it can't be used to find the accounts
 func (h *Handler) ForgotPasswordEmail(c echo.Context) error {
-
-	email := c.FormValue("email")
-	if email == "" {
+	identifier := strings.TrimSpace(c.FormValue("email"))
+	if identifier == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.email_empty"), true))
 	}
 
-	username := h.Model.GetUserIDByEmail(email)
-	if username != "" {
-		code, err := generateForgotCode()
-		if err != nil {
-			return err
-		}
-
-		hash, err := argon2id.CreateHash(code, argon2id.DefaultParams)
-		if err != nil {
-			return err
-		}
-
-		if err := h.Model.SaveForgotCode(username, hash); err != nil {
-			return err
-		}
-
-		notification := openuem_nats.Notification{
-			To:               email,
-			Subject:          "Request to set a new password",
-			MessageTitle:     "OpenUEM | Your code to create a new password",
-			MessageText:      fmt.Sprintf("Here’s your confirmation code: %s. You can copy it into the open browser window or click the link below to confirm this request", code),
-			MessageGreeting:  "You or someone else has indicated that you have forgotten your login password",
-			MessageAction:    "Generate a new password",
-			MessageActionURL: c.Request().Header.Get("Origin") + fmt.Sprintf("/login/forgotverify?code=%s", code),
-		}
-
-		data, err := json.Marshal(notification)
-		if err != nil {
-			return err
-		}
-
-		if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
-			return fmt.Errorf("%s", i18n.T(c.Request().Context(), "nats.not_connected"))
-		}
-
-		if err := h.NATSConnection.Publish("notification.confirm_email", data); err != nil {
-			return err
-		}
-
-		user, err := h.Model.GetUserById(username)
-		if err != nil {
-			log.Printf("[ERROR]: could not get user account for username %s, reason: %v", username, err)
-			return err
-		}
+	if !h.passwordResetIPAllowed(c) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.too_many_password_resets"), true))
+	}
 
-		// Create a session as we'll require the username to change the password
-		if err := h.CreateForgotPasswordSession(c, user); err != nil {
-			return err
-		}
+	// The email is sent in the background so the response takes the same time whether the account exists or not
+	if user := h.Model.GetPasswordResetUser(identifier); user != nil && h.passwordResetAccountAllowed(c, "email:"+user.ID) {
+		go h.sendPasswordResetCode(user)
 	}
 
-	return RenderLoginPartial(c, login_views.LostPasswordCode(email))
+	return RenderLoginPartial(c, login_views.LostPasswordCode(identifier))
 }
 
+// VerifyForgotPasswordCode checks the code sent by email, from the form or the link of the email, and
+// shows the form to set the new password. The code can only be used once
 func (h *Handler) VerifyForgotPasswordCode(c echo.Context) error {
-	confirmCode := ""
-	if c.Request().Method == "GET" {
-		confirmCode = c.QueryParam("code")
-	}
-
+	identifier := c.QueryParam("user")
+	confirmCode := c.QueryParam("code")
 	if c.Request().Method == "POST" {
+		identifier = c.FormValue("user")
 		confirmCode = c.FormValue("confirm-code")
 	}
 
-	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
-	if username == "" {
-		log.Println("[ERROR]: could not find a valid username in the session")
+	verifyError := func(key string) error {
 		if c.Request().Method == "GET" {
-			return echo.NewHTTPError(http.StatusUnauthorized, i18n.T(c.Request().Context(), "login.forgot_verify_error"))
-		} else {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.forgot_verify_error"), true))
+			return echo.NewHTTPError(http.StatusUnauthorized, i18n.T(c.Request().Context(), key))
 		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), key), true))
 	}
 
-	confirmCode = strings.ToUpper(confirmCode)
+	confirmCode = strings.ToUpper(strings.TrimSpace(confirmCode))
 	if confirmCode == "" {
-		if c.Request().Method == "GET" {
-			return echo.NewHTTPError(http.StatusUnauthorized, i18n.T(c.Request().Context(), "login.forgot_code_empty"))
-		} else {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.forgot_code_empty"), true))
-		}
+		return verifyError("login.forgot_code_empty")
 	}
 
-	valid := h.Model.IsForgotCodeValid(username, confirmCode)
-	if !valid {
-		log.Printf("[ERROR]: %s is not a valid code", confirmCode)
-		if c.Request().Method == "GET" {
-			return echo.NewHTTPError(http.StatusUnauthorized, i18n.T(c.Request().Context(), "login.forgot_verify_error"))
-		} else {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.forgot_verify_error"), true))
-		}
+	if !h.passwordResetIPAllowed(c) {
+		return verifyError("login.too_many_password_resets")
+	}
+
+	user := h.Model.GetPasswordResetUser(strings.TrimSpace(identifier))
+	if user == nil || !h.passwordResetAccountAllowed(c, "code:"+user.ID) || !h.Model.IsForgotCodeValid(user.ID, confirmCode) {
+		h.AuthLogger.Printf("a wrong password reset code was entered for %s from %s", identifier, c.RealIP())
+		h.recordAuthEvent(c, models.AuthEventResetCodeFailed, identifier)
+		return verifyError("login.forgot_verify_error")
+	}
+
+	if err := h.Model.RemoveForgotCode(user.ID); err != nil {
+		log.Printf("[ERROR]: could not remove forgot code, reason: %v", err)
+		return verifyError("login.could_not_remove_forgot_code")
 	}
 
 	csrfToken, ok := c.Get("csrf").(string)
@@ -603,11 +653,20 @@ func (h *Handler) VerifyForgotPasswordCode(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "authentication.csrf_token_not_found"))
 	}
 
+	// Create a session as we'll require the username to change the password
+	if err := h.CreateForgotPasswordSession(c, user); err != nil {
+		return err
+	}
+
 	branding, _ := h.Model.GetOrCreateBranding()
 	return RenderLogin(c, login_views.LoginIndex(login_views.ChangePassword(branding), csrfToken, branding))
 }
 
 func (h *Handler) CreateForgotPasswordSession(c echo.Context, user *ent.User) error {
+	// The session may already belong to the user, as when the password has to be changed after logging in,
+	// but it must be allowed to set the new password anyway
+	h.SessionManager.Manager.Put(c.Request().Context(), "forgot", true)
+
 	msg := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
 	if msg != user.ID {
 		err := h.SessionManager.Manager.RenewToken(c.Request().Context())
@@ -618,10 +677,13 @@ func (h *Handler) CreateForgotPasswordSession(c echo.Context, user *ent.User) er
 		h.SessionManager.Manager.Put(c.Request().Context(), "uid", user.ID)
 		h.SessionManager.Manager.Put(c.Request().Context(), "username", user.Name)
 		h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
-		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 		h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 		h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
-		h.SessionManager.Manager.Put(c.Request().Context(), "forgot", true)
+		h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+		h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+		h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+		h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
 		token, expiry, err := h.SessionManager.Manager.Commit(c.Request().Context())
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -727,3 +789,18 @@ func generateForgotCode() (string, error) {
 
 	return fmt.Sprintf("%s", randomCode.String()[0:6]), nil
 }
+
+// userLastSeenInterval limits how often the last activity of a user is stored while the session is used
+const userLastSeenInterval = time.Minute
+
+// updateUserLastSeen records the activity of the user, at most once per interval so every request of the
+// session doesn't write to the database
+func (h *Handler) updateUserLastSeen(user *ent.User) {
+	if user.LastSeenAt != nil && time.Since(*user.LastSeenAt) < userLastSeenInterval {
+		return
+	}
+
+	if err := h.Model.UpdateUserLastSeen(user.ID, time.Now(), userLastSeenInterval); err != nil {
+		log.Printf("[ERROR]: could not update when user %s was last seen, reason: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/models"
+)
+
+// loginLockedOut returns if the account can't log in with a password because of its failed logins. A login
+// isn't blocked if the lockout can't be checked
+func (h *Handler) loginLockedOut(c echo.Context, username string) bool {
+	locked, until, err := h.Model.IsUserLockedOut(username)
+	if err != nil {
+		log.Printf("[ERROR]: could not check if user %s is locked out, reason: %v", username, err)
+		return false
+	}
+	if locked {
+		h.AuthLogger.Printf("user %s tried to log in from %s while locked out until %s", username, c.RealIP(), until.Format(time.RFC3339))
+	}
+	return locked
+}
+
+// recordLoginAttempt saves a password login and notifies the user if the account has been locked
+func (h *Handler) recordLoginAttempt(c echo.Context, username string, success bool) {
+	if err := h.Model.RecordLoginAttempt(username, success, c.RealIP()); err != nil {
+		log.Printf("[ERROR]: could not save the login attempt of user %s, reason: %v", username, err)
+		return
+	}
+	if success {
+		return
+	}
+
+	// The locked accounts can't log in with a password so the lockout is only notified once
+	locked, until, err := h.Model.IsUserLockedOut(username)
+	if err != nil || !locked {
+		return
+	}
+	h.AuthLogger.Printf("user %s has been locked out until %s after %d failed logins, the last one from %s", username, until.Format(time.RFC3339), models.LoginLockoutFailures, c.RealIP())
+
+	user, err := h.Model.GetUserById(username)
+	if err != nil {
+		return
+	}
+	go h.notifyAccountLocked(user, c.RealIP())
+}
+
+// notifyAccountLocked tells the user that the account has been locked, someone may be trying to guess the password
+func (h *Handler) notifyAccountLocked(user *ent.User, ip string) {
+	if user.Email == "" {
+		return
+	}
+
+	notification := openuem_nats.Notification{
+		To:              user.Email,
+		Subject:         "Your account has been locked",
+		MessageTitle:    "OpenUEM | Your account has been locked",
+		MessageText:     fmt.Sprintf("There have been %d failed logins to your OpenUEM account, the last one from %s, and it has been locked for %d minutes. If it wasn't you, contact your administrator as soon as possible", models.LoginLockoutFailures, ip, int(models.LoginLockoutDuration.Minutes())),
+		MessageGreeting: strings.TrimSpace("Hi " + user.Name),
+	}
+
+	if err := h.publishEmailNotification(notification); err != nil {
+		log.Printf("[ERROR]: could not notify user %s about the lockout, reason: %v", user.ID, err)
+	}
+}
@@ -32,7 +32,7 @@ func (h *Handler) Logout(c echo.Context) error {
 	}
 
 	if h.AuthLogger != nil {
-		h.AuthLogger.Printf("user %s has logged out from the console", u.ID)
+		h.AuthLogger.Printf("user %s has logged out of the console from %s", u.ID, c.RealIP())
 	}
 
 	if u.Openid {
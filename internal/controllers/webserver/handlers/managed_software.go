@@ -79,7 +79,7 @@ func (h *Handler) ComputerSoftwareCheck(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	if _, err := h.NATSConnection.Request("agent.softwarecheck."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+	if _, err := h.natsRequest(c.Request().Context(), "agent.softwarecheck."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 	}
 
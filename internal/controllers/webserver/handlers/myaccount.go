@@ -3,15 +3,19 @@ package handlers
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"image/png"
 	"log"
 	"strings"
 
 	"github.com/alexedwards/argon2id"
-	validator "github.com/go-passwd/validator"
+	"github.com/invopop/ctxi18n"
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/auth"
 	"github.com/open-uem/openuem-console/internal/views/account_views"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
+	"github.com/open-uem/openuem-console/internal/views/locales"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 	"github.com/pquerna/otp/totp"
 )
@@ -57,12 +61,49 @@ func (h *Handler) UpdatePersonalInfo(c echo.Context) error {
 		return err
 	}
 
+	// An empty language means that the browser's language is used
+	language := c.FormValue("language")
+	if language != "" && !locales.IsSupported(language) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "language.not_supported"), true))
+	}
+
+	// An empty timezone or date format means that the settings of the tenant are used
+	timezone := c.FormValue("timezone")
+	if !helpers.IsValidTimezone(timezone) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "datetime.timezone_invalid"), true))
+	}
+
+	dateFormat := c.FormValue("date-format")
+	if !helpers.IsValidDateFormat(dateFormat) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "datetime.date_format_invalid"), true))
+	}
+
 	if err := h.Model.UpdateUser(username, c.FormValue("name"), c.FormValue("email"), c.FormValue("phone"), c.FormValue("country")); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.could_not_update_personal_info", err.Error()), true))
 	}
 
 	h.SessionManager.Manager.Put(c.Request().Context(), "email", c.FormValue("email"))
 
+	if err := h.Model.UpdateUserLanguage(username, language); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.could_not_update_personal_info", err.Error()), true))
+	}
+	h.SessionManager.Manager.Put(c.Request().Context(), "lang", language)
+
+	if err := h.Model.UpdateUserDateTime(username, timezone, dateFormat); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.could_not_update_personal_info", err.Error()), true))
+	}
+	h.SessionManager.Manager.Put(c.Request().Context(), "timezone", timezone)
+	h.SessionManager.Manager.Put(c.Request().Context(), "date-format", dateFormat)
+
+	// Render the page with the new language
+	lang := language
+	if lang == "" {
+		lang = c.Request().Header.Get("Accept-Language")
+	}
+	if ctx, err := ctxi18n.WithLocale(c.Request().Context(), lang); err == nil {
+		c.SetRequest(c.Request().WithContext(ctx))
+	}
+
 	user, err := h.Model.GetUserById(username)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.could_not_find_user"), true))
@@ -102,10 +143,6 @@ func (h *Handler) MyAccountPassword(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.passwords_dont_match"), true))
 	}
 
-	if err := ValidatePasswordComplexity(newPassword); err != nil {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.password_complexity_invalid"), true))
-	}
-
 	// Check if current password is valid
 	match, err := argon2id.ComparePasswordAndHash(currentPassword, user.Hash)
 	if err != nil {
@@ -117,13 +154,17 @@ func (h *Handler) MyAccountPassword(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.current_password_not_valid"), true))
 	}
 
+	if err := h.Model.CheckPasswordPolicy(username, newPassword); err != nil {
+		return RenderPasswordPolicyError(c, err)
+	}
+
 	// Change password in database
 	if err := h.Model.ChangePassword(username, newPassword); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "login.could_not_save_new_password"), true))
 	}
 
 	// Log this change
-	h.AuthLogger.Printf("user %s has changed the password", username)
+	h.AuthLogger.Printf("user %s has changed the password from %s", username, c.RealIP())
 
 	// Password has been changed, log out
 	return h.Logout(c)
@@ -231,7 +272,7 @@ func (h *Handler) Enabled2FA(c echo.Context) error {
 	}
 
 	// 2FA has been enabled
-	h.AuthLogger.Printf("user %s has enabled 2FA", username)
+	h.AuthLogger.Printf("user %s has enabled 2FA from %s", username, c.RealIP())
 
 	return RenderAccountPartial(c, account_views.Enabled2FA(strings.Join(codes, "\n")))
 }
@@ -278,21 +319,22 @@ func (h *Handler) Disable2FA(c echo.Context) error {
 	}
 
 	// 2FA has been disabled
-	h.AuthLogger.Printf("user %s has disabled 2FA", username)
+	h.AuthLogger.Printf("user %s has disabled 2FA from %s", username, c.RealIP())
 
 	// 2FA has been disabled, log out
 	return h.Logout(c)
 }
 
-func ValidatePasswordComplexity(password string) error {
-	passwordValidator := validator.New(
-		validator.MinLength(15, nil),
-		validator.MaxLength(64, nil),
-	)
-
-	if err := passwordValidator.Validate(password); err != nil {
-		return err
+// RenderPasswordPolicyError shows the rule of the password policy that the new password doesn't comply with
+func RenderPasswordPolicyError(c echo.Context, err error) error {
+	var policyErr *auth.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		log.Printf("[ERROR]: could not check the password policy, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy.could_not_check"), true))
 	}
 
-	return nil
+	if policyErr.Value > 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy."+policyErr.Rule, policyErr.Value), true))
+	}
+	return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "password_policy."+policyErr.Rule), true))
 }
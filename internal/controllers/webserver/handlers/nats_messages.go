@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/open-uem/openuem-console/internal/telemetry"
+)
+
+// natsPublish publishes the message to the subject, traced as part of the trace of ctx if traces are exported.
+// The messages that can't be published are counted in the NATS status
+func (h *Handler) natsPublish(ctx context.Context, subject string, data []byte) error {
+	if !telemetry.Enabled() {
+		err := h.NATSConnection.Publish(subject, data)
+		h.NATSMonitor.PublishFailed(err)
+		return err
+	}
+
+	_, span, msg := telemetry.StartNATS(ctx, telemetry.NATSPublish, subject, data)
+	err := h.NATSConnection.PublishMsg(msg)
+	h.NATSMonitor.PublishFailed(err)
+	telemetry.End(span, err)
+	return err
+}
+
+// natsRequest sends the request to the subject and waits for the reply until the timeout, traced
+// as part of the trace of ctx if traces are exported
+func (h *Handler) natsRequest(ctx context.Context, subject string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	if !telemetry.Enabled() {
+		reply, err := h.NATSConnection.Request(subject, data, timeout)
+		h.NATSMonitor.PublishFailed(err)
+		return reply, err
+	}
+
+	_, span, msg := telemetry.StartNATS(ctx, telemetry.NATSRequest, subject, data)
+	reply, err := h.NATSConnection.RequestMsg(msg, timeout)
+	h.NATSMonitor.PublishFailed(err)
+	telemetry.End(span, err)
+	return reply, err
+}
+
+// jetStreamPublish publishes the message to the stream of the subject, traced as part of the trace
+// of ctx if traces are exported
+func (h *Handler) jetStreamPublish(ctx context.Context, subject string, data []byte) (*jetstream.PubAck, error) {
+	if !telemetry.Enabled() {
+		ack, err := h.JetStream.Publish(ctx, subject, data)
+		h.NATSMonitor.PublishFailed(err)
+		return ack, err
+	}
+
+	ctx, span, msg := telemetry.StartNATS(ctx, telemetry.NATSPublish, subject, data)
+	ack, err := h.JetStream.PublishMsg(ctx, msg)
+	h.NATSMonitor.PublishFailed(err)
+	telemetry.End(span, err)
+	return ack, err
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/natsstatus"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// natsDiagnosticTimeout is how long the NATS page waits for the round trip and the echo check
+const natsDiagnosticTimeout = 5 * time.Second
+
+// watchNATSConnection records the events of the current NATS connection in the NATS status
+func (h *Handler) watchNATSConnection() {
+	if h.NATSConnection == nil {
+		return
+	}
+
+	// Subscriptions are restored by the NATS client, but heartbeats may have been missed while disconnected
+	h.NATSMonitor.Watch(h.NATSConnection, func() {
+		log.Println("[INFO]: reconciling agents presence after the NATS reconnection")
+		h.reconcileAgentPresence()
+	})
+}
+
+// NATSStatus shows the state of the NATS connection of this console and its recent history
+func (h *Handler) NATSStatus(c echo.Context) error {
+	return h.renderNATSStatus(c, "")
+}
+
+func (h *Handler) renderNATSStatus(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	status := h.NATSMonitor.Status(h.NATSConnection, h.NATSServers)
+
+	var rtt time.Duration
+	if status.Connected {
+		if rtt, err = natsstatus.RTT(h.NATSConnection, natsDiagnosticTimeout); err != nil {
+			log.Printf("[WARN]: could not measure the NATS round trip time, reason: %v", err)
+		}
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.NATSStatusIndex(" | NATS", admin_views.NATSStatus(c, status, rtt, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// ReconnectNATS drops the NATS connection and connects again, to the same server or another one of
+// the cluster. If the console never connected, it tries to connect now instead of waiting for the next attempt
+func (h *Handler) ReconnectNATS(c echo.Context) error {
+	if h.NATSConnection == nil {
+		if h.NATSConnectJob == nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
+		}
+		if err := h.NATSConnectJob.RunNow(); err != nil {
+			log.Printf("[ERROR]: could not run the NATS connect job, reason: %v", err)
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.reconnect_error", err.Error()), false))
+		}
+		return h.renderNATSStatus(c, i18n.T(c.Request().Context(), "nats.connect_requested"))
+	}
+
+	if err := h.NATSConnection.ForceReconnect(); err != nil {
+		log.Printf("[ERROR]: could not reconnect to NATS, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.reconnect_error", err.Error()), false))
+	}
+
+	log.Println("[INFO]: NATS reconnection requested from the NATS page")
+	return h.renderNATSStatus(c, i18n.T(c.Request().Context(), "nats.reconnect_requested"))
+}
+
+// EchoNATS checks that the console can publish a message and receive it back through NATS
+func (h *Handler) EchoNATS(c echo.Context) error {
+	elapsed, err := natsstatus.Echo(h.NATSConnection, natsDiagnosticTimeout)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.echo_failed", err.Error()), false))
+	}
+
+	return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "nats.echo_success", elapsed.Round(time.Microsecond).String())))
+}
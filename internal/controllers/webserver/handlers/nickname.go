@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"log"
+
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/nicknames"
 	"github.com/open-uem/openuem-console/internal/views/computers_views"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
@@ -29,5 +34,41 @@ func (h *Handler) Nickname(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.nickname_not_saved", err.Error()), true))
 	}
 
+	h.announceNickname(c.Request().Context(), nicknames.Event{AgentID: agentID, Nickname: nickname, TenantID: commonInfo.TenantID, SiteID: commonInfo.SiteID})
+
 	return RenderView(c, partials.EndpointName(agentID, nickname, commonInfo))
 }
+
+// announceNickname stores the new nickname and tells the other console replicas, the nickname has
+// already been saved so a failure only delays the change in the other replicas until they query it
+func (h *Handler) announceNickname(ctx context.Context, e nicknames.Event) {
+	h.Nicknames.Set(e.AgentID, e.Nickname)
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		log.Printf("[WARN]: could not announce the new nickname of agent %s, NATS is not connected", e.AgentID)
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[ERROR]: could not encode the nickname change of agent %s, reason: %v", e.AgentID, err)
+		return
+	}
+
+	if err := h.natsPublish(ctx, nicknames.ChangedSubject, data); err != nil {
+		log.Printf("[ERROR]: could not announce the new nickname of agent %s, reason: %v", e.AgentID, err)
+	}
+}
+
+// subscribeNicknameChanges listens to the nickname changes of the console replicas using the current NATS connection
+func (h *Handler) subscribeNicknameChanges() {
+	if h.NATSConnection == nil {
+		return
+	}
+
+	if err := h.Nicknames.Subscribe(h.NATSConnection); err != nil {
+		log.Printf("[ERROR]: could not subscribe to the agents nickname changes, reason: %v", err)
+		return
+	}
+	log.Println("[INFO]: subscribed to the agents nickname changes")
+}
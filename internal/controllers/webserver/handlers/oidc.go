@@ -404,9 +404,13 @@ func (h *Handler) CreateSession(c echo.Context, user *ent.User, pictureURL strin
 		h.SessionManager.Manager.Put(c.Request().Context(), "uid", user.ID)
 		h.SessionManager.Manager.Put(c.Request().Context(), "username", user.Name)
 		h.SessionManager.Manager.Put(c.Request().Context(), "user-agent", c.Request().UserAgent())
-		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.Request().RemoteAddr)
+		h.SessionManager.Manager.Put(c.Request().Context(), "ip-address", c.RealIP())
 		h.SessionManager.Manager.Put(c.Request().Context(), "usepasswd", user.Passwd)
 		h.SessionManager.Manager.Put(c.Request().Context(), "email", user.Email)
+		h.SessionManager.Manager.Put(c.Request().Context(), "lang", user.Language)
+		h.SessionManager.Manager.Put(c.Request().Context(), "theme", user.Theme)
+		h.SessionManager.Manager.Put(c.Request().Context(), "timezone", user.Timezone)
+		h.SessionManager.Manager.Put(c.Request().Context(), "date-format", user.DateFormat)
 		if pictureURL != "" {
 			h.SessionManager.Manager.Put(c.Request().Context(), "picture", pictureURL)
 		}
@@ -505,8 +509,9 @@ func (h *Handler) ManageOIDCSession(c echo.Context, u *ent.User, oidcInfo OIDCTe
 		}
 
 		if h.AuthLogger != nil {
-			h.AuthLogger.Printf("user %s has logged in with OpenID (%s)", u.ID, settings.OIDCProvider)
+			h.AuthLogger.Printf("user %s has logged in with OpenID (%s) from %s", u.ID, settings.OIDCProvider, c.RealIP())
 		}
+		h.recordAuthEvent(c, models.AuthEventLoginSucceeded, u.ID)
 
 		myTenant, err := h.Model.GetDefaultTenant()
 		if err != nil {
@@ -687,7 +692,7 @@ func (h *Handler) AssignTenantFromOIDC(userID string, info OIDCTenantInfo, setti
 
 	// Strategy 2: Generic OIDC groups (fallback for Authelia etc.)
 	if len(info.Groups) > 0 {
-		return h.assignTenantByGroups(userID, info.Groups, settings)
+		return h.assignTenantByGroups(userID, info.Groups, oidcGroupRoles(settings))
 	}
 
 	// No org ID or groups found - user may be manually assigned to tenants
@@ -728,23 +733,39 @@ func (h *Handler) assignTenantByOrgID(userID, orgID string, roles []string, sett
 	return nil
 }
 
+// groupRoles are the names of the roles or groups that give the admin, operator and user roles
+type groupRoles struct {
+	Admin    string
+	Operator string
+	User     string
+}
+
+func oidcGroupRoles(settings *ent.Authentication) groupRoles {
+	return groupRoles{Admin: settings.OIDCRoleAdmin, Operator: settings.OIDCRoleOperator, User: settings.OIDCRoleUser}
+}
+
 // userHasAllowedOIDCRole checks if the user has any of the configured OIDC roles/groups
 // Returns true if no roles are configured (allow all), or if user has at least one matching role
 func (h *Handler) userHasAllowedOIDCRole(userRoles []string, settings *ent.Authentication) bool {
+	return oidcGroupRoles(settings).allowed(userRoles)
+}
+
+// allowed returns true if no roles are configured (allow all), or if the user has at least one of them
+func (r groupRoles) allowed(userRoles []string) bool {
 	// If no roles are configured, allow all users
-	if settings.OIDCRoleAdmin == "" && settings.OIDCRoleOperator == "" && settings.OIDCRoleUser == "" {
+	if r.Admin == "" && r.Operator == "" && r.User == "" {
 		return true
 	}
 
 	// Check if user has any of the configured roles
 	for _, role := range userRoles {
-		if settings.OIDCRoleAdmin != "" && role == settings.OIDCRoleAdmin {
+		if r.Admin != "" && role == r.Admin {
 			return true
 		}
-		if settings.OIDCRoleOperator != "" && role == settings.OIDCRoleOperator {
+		if r.Operator != "" && role == r.Operator {
 			return true
 		}
-		if settings.OIDCRoleUser != "" && role == settings.OIDCRoleUser {
+		if r.User != "" && role == r.User {
 			return true
 		}
 	}
@@ -755,18 +776,23 @@ func (h *Handler) userHasAllowedOIDCRole(userRoles []string, settings *ent.Authe
 // resolveOIDCRoleFromSettings maps user's OIDC roles to OpenUEM role based on authentication settings
 // Returns the highest privilege role the user has (admin > operator > user)
 func (h *Handler) resolveOIDCRoleFromSettings(userRoles []string, settings *ent.Authentication) models.UserTenantRole {
+	return oidcGroupRoles(settings).resolve(userRoles)
+}
+
+// resolve returns the highest privilege role the user has (admin > operator > user)
+func (r groupRoles) resolve(userRoles []string) models.UserTenantRole {
 	hasAdmin := false
 	hasOperator := false
 	hasUser := false
 
 	for _, role := range userRoles {
-		if settings.OIDCRoleAdmin != "" && role == settings.OIDCRoleAdmin {
+		if r.Admin != "" && role == r.Admin {
 			hasAdmin = true
 		}
-		if settings.OIDCRoleOperator != "" && role == settings.OIDCRoleOperator {
+		if r.Operator != "" && role == r.Operator {
 			hasOperator = true
 		}
-		if settings.OIDCRoleUser != "" && role == settings.OIDCRoleUser {
+		if r.User != "" && role == r.User {
 			hasUser = true
 		}
 	}
@@ -786,9 +812,9 @@ func (h *Handler) resolveOIDCRoleFromSettings(userRoles []string, settings *ent.
 	return models.UserTenantRoleUser
 }
 
-// assignTenantByGroups handles generic OIDC group-based tenant assignment (Authelia etc.)
+// assignTenantByGroups handles group-based tenant assignment for OIDC (Authelia etc.) and LDAP
 // Expected group format: openuem:<organization>:<role>
-func (h *Handler) assignTenantByGroups(userID string, groups []string, settings *ent.Authentication) error {
+func (h *Handler) assignTenantByGroups(userID string, groups []string, roles groupRoles) error {
 	for _, group := range groups {
 		parts := strings.Split(group, ":")
 		if len(parts) != 3 || parts[0] != "openuem" {
@@ -801,13 +827,13 @@ func (h *Handler) assignTenantByGroups(userID string, groups []string, settings
 		// Resolve role using configured settings or fallback to standard names
 		var role models.UserTenantRole
 		var roleFound bool
-		if settings.OIDCRoleAdmin != "" && roleName == settings.OIDCRoleAdmin {
+		if roles.Admin != "" && roleName == roles.Admin {
 			role = models.UserTenantRoleAdmin
 			roleFound = true
-		} else if settings.OIDCRoleOperator != "" && roleName == settings.OIDCRoleOperator {
+		} else if roles.Operator != "" && roleName == roles.Operator {
 			role = models.UserTenantRoleOperator
 			roleFound = true
-		} else if settings.OIDCRoleUser != "" && roleName == settings.OIDCRoleUser {
+		} else if roles.User != "" && roleName == roles.User {
 			role = models.UserTenantRoleUser
 			roleFound = true
 		}
@@ -845,7 +871,7 @@ func (h *Handler) assignTenantByGroups(userID string, groups []string, settings
 				log.Printf("[ERROR]: could not assign user %s to org '%s': %v", userID, orgName, err)
 				continue
 			}
-			log.Printf("[INFO]: assigned user %s as %s to '%s' from OIDC group", userID, role, orgName)
+			log.Printf("[INFO]: assigned user %s as %s to '%s' from group %s", userID, role, orgName, group)
 		}
 	}
 	return nil
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	openuem_nats "github.com/open-uem/nats"
+)
+
+const (
+	// passwordResetIPRate is how many password reset requests and code checks are allowed per minute from an IP
+	passwordResetIPRate = 10
+	// passwordResetAccountRate is how many reset emails and code checks are allowed per hour for an account
+	passwordResetAccountRate = 5
+)
+
+// passwordResetIPAllowed returns if the IP of the request hasn't exceeded the rate of password resets.
+// It must be checked once per request, before the account is looked up
+func (h *Handler) passwordResetIPAllowed(c echo.Context) bool {
+	if h.passwordResetIPLimiter == nil {
+		return true
+	}
+
+	allowed, err := h.passwordResetIPLimiter.Allow(c.RealIP())
+	if err != nil || !allowed {
+		h.AuthLogger.Printf("too many password reset requests from %s", c.RealIP())
+		return false
+	}
+	return true
+}
+
+// passwordResetAccountAllowed returns if the account hasn't exceeded the rate of password resets. The key
+// of the account tells the reset emails and the code checks apart
+func (h *Handler) passwordResetAccountAllowed(c echo.Context, accountKey string) bool {
+	if h.passwordResetAccountLimiter == nil {
+		return true
+	}
+
+	allowed, err := h.passwordResetAccountLimiter.Allow(accountKey)
+	if err != nil || !allowed {
+		h.AuthLogger.Printf("too many password reset requests for %s from %s", accountKey, c.RealIP())
+		return false
+	}
+	return true
+}
+
+// passwordResetLink returns the link of the email to verify the code. It's built from the configured
+// server as the headers of the request can be forged
+func (h *Handler) passwordResetLink(uid, code string) string {
+	server := fmt.Sprintf("%s:%s", h.ServerName, h.ConsolePort)
+	if h.ReverseProxyServer != "" {
+		server = h.ReverseProxyServer
+	}
+
+	return fmt.Sprintf("https://%s/login/forgotverify?%s", server, url.Values{"user": {uid}, "code": {code}}.Encode())
+}
+
+// sendPasswordResetCode emails a new code to reset the password, the previous code is no longer valid
+func (h *Handler) sendPasswordResetCode(user *ent.User) {
+	code, err := generateForgotCode()
+	if err != nil {
+		log.Printf("[ERROR]: could not generate the password reset code for user %s, reason: %v", user.ID, err)
+		return
+	}
+
+	hash, err := argon2id.CreateHash(code, argon2id.DefaultParams)
+	if err != nil {
+		log.Printf("[ERROR]: could not hash the password reset code for user %s, reason: %v", user.ID, err)
+		return
+	}
+
+	if err := h.Model.SaveForgotCode(user.ID, hash); err != nil {
+		log.Printf("[ERROR]: could not save the password reset code for user %s, reason: %v", user.ID, err)
+		return
+	}
+
+	notification := openuem_nats.Notification{
+		To:               user.Email,
+		Subject:          "Request to set a new password",
+		MessageTitle:     "OpenUEM | Your code to create a new password",
+		MessageText:      fmt.Sprintf("Here’s your confirmation code: %s. You can copy it into the open browser window or click the link below to confirm this request. The code can only be used once", code),
+		MessageGreeting:  "You or someone else has indicated that you have forgotten your login password",
+		MessageAction:    "Generate a new password",
+		MessageActionURL: h.passwordResetLink(user.ID, code),
+	}
+
+	if err := h.publishEmailNotification(notification); err != nil {
+		log.Printf("[ERROR]: could not send the password reset code to user %s, reason: %v", user.ID, err)
+		return
+	}
+
+	h.AuthLogger.Printf("a password reset code has been sent to user %s", user.ID)
+}
+
+// notifyPasswordChanged tells the user that the password has been changed, in case it wasn't the user
+func (h *Handler) notifyPasswordChanged(user *ent.User) {
+	if user.Email == "" {
+		return
+	}
+
+	notification := openuem_nats.Notification{
+		To:              user.Email,
+		Subject:         "Your password has been changed",
+		MessageTitle:    "OpenUEM | Your password has been changed",
+		MessageText:     "The password of your OpenUEM account has been changed and your open sessions have been closed. If you didn't change it, contact your administrator as soon as possible",
+		MessageGreeting: strings.TrimSpace("Hi " + user.Name),
+	}
+
+	if err := h.publishEmailNotification(notification); err != nil {
+		log.Printf("[ERROR]: could not notify user %s about the password change, reason: %v", user.ID, err)
+	}
+}
+
+func (h *Handler) publishEmailNotification(notification openuem_nats.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return fmt.Errorf("NATS is not connected")
+	}
+
+	return h.natsPublish(context.Background(), "notification.confirm_email", data)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	mw "github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetAllowed(t *testing.T) {
+	h := Handler{
+		AuthLogger:                  log.New(io.Discard, "", 0),
+		passwordResetIPLimiter:      mw.NewRateLimiterMemoryStoreWithConfig(mw.RateLimiterMemoryStoreConfig{Rate: 1 / 60.0, Burst: 3, ExpiresIn: time.Minute}),
+		passwordResetAccountLimiter: mw.NewRateLimiterMemoryStoreWithConfig(mw.RateLimiterMemoryStoreConfig{Rate: 1 / 3600.0, Burst: 1, ExpiresIn: time.Hour}),
+	}
+
+	e := echo.New()
+	newContext := func(ip string) echo.Context {
+		req := httptest.NewRequest(http.MethodPost, "/login/forgot", nil)
+		req.RemoteAddr = ip + ":50000"
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	assert.True(t, h.passwordResetAccountAllowed(newContext("192.0.2.1"), "email:user1"))
+	assert.False(t, h.passwordResetAccountAllowed(newContext("192.0.2.2"), "email:user1"), "should limit the account from any IP")
+	assert.True(t, h.passwordResetAccountAllowed(newContext("192.0.2.1"), "code:user1"), "should limit the emails and the codes apart")
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, h.passwordResetIPAllowed(newContext("192.0.2.1")), "the account checks should not use the IP rate")
+	}
+	assert.False(t, h.passwordResetIPAllowed(newContext("192.0.2.1")), "should limit the IP")
+	assert.True(t, h.passwordResetIPAllowed(newContext("192.0.2.2")), "should limit each IP apart")
+
+	assert.True(t, (&Handler{}).passwordResetIPAllowed(newContext("192.0.2.1")), "should not limit without limiters")
+	assert.True(t, (&Handler{}).passwordResetAccountAllowed(newContext("192.0.2.1"), "email:user1"), "should not limit without limiters")
+}
+
+func TestPasswordResetLink(t *testing.T) {
+	h := Handler{ServerName: "console.example.com", ConsolePort: "1323"}
+	assert.Equal(t, "https://console.example.com:1323/login/forgotverify?code=ABCD-EFGH&user=user1", h.passwordResetLink("user1", "ABCD-EFGH"))
+
+	h.ReverseProxyServer = "uem.example.com"
+	assert.Equal(t, "https://uem.example.com/login/forgotverify?code=ABCD-EFGH&user=user1", h.passwordResetLink("user1", "ABCD-EFGH"), "should use the reverse proxy")
+}
+
+func TestCreateForgotPasswordSessionOfLoggedInUser(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	user, err := at.h.Model.Client.User.Get(context.Background(), "operator")
+	assert.NoError(t, err)
+
+	c := at.context(t, "operator", http.MethodPost, "/login", nil)
+	assert.NoError(t, at.h.CreateForgotPasswordSession(c, user))
+	assert.Equal(t, "operator", at.h.SessionManager.Manager.GetString(c.Request().Context(), "uid"))
+	assert.True(t, at.h.SessionManager.Manager.GetBool(c.Request().Context(), "forgot"), "should allow the user to set a new password")
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// ListPreStagedDevices shows the devices expected to enroll in the tenant and whether they have enrolled
+func (h *Handler) ListPreStagedDevices(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	return h.renderPreStagedDevices(c, commonInfo, "", nil)
+}
+
+// ImportPreStagedDevices reads the CSV file with the devices expected to enroll, if a row has errors
+// the file is rejected and the offending rows are shown
+func (h *Handler) ImportPreStagedDevices(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	file, err := c.FormFile("csvFile")
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "prestaged.file_required"), true))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+	defer src.Close()
+
+	records, recordErrors, err := models.ParsePreStagedDevices(src)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "prestaged.import_read_error", err.Error()), false))
+	}
+
+	n, err := h.Model.ImportPreStagedDevices(tenantID, records, recordErrors)
+	if err != nil {
+		var importErr *models.PreStagedImportError
+		if errors.As(err, &importErr) {
+			return h.renderPreStagedDevices(c, commonInfo, "", importErr.Records)
+		}
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has imported %d pre-staged devices", n)
+
+	return h.renderPreStagedDevices(c, commonInfo, i18n.T(c.Request().Context(), "prestaged.imported", n), nil)
+}
+
+// DeletePreStagedDevice removes a device that is no longer expected to enroll
+func (h *Handler) DeletePreStagedDevice(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "prestaged.invalid_id"), true))
+	}
+
+	if err := h.Model.DeletePreStagedDevice(id, tenantID); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return resourceNotFound(c)
+		}
+		return RenderModelError(c, err)
+	}
+	h.auditTenantData(c, "has deleted the pre-staged device %d", id)
+
+	return h.renderPreStagedDevices(c, commonInfo, i18n.T(c.Request().Context(), "prestaged.deleted"), nil)
+}
+
+func (h *Handler) renderPreStagedDevices(c echo.Context, commonInfo *partials.CommonInfo, successMessage string, importErrors []models.PreStagedImportRecordError) error {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	f := filters.PreStagedDeviceFilter{Serial: c.FormValue("filterBySerial")}
+	for index := range admin_views.PreStagedDeviceStatuses {
+		value := c.FormValue(fmt.Sprintf("filterByStatus%d", index))
+		if slices.Contains(admin_views.PreStagedDeviceStatuses, value) {
+			f.StatusOptions = append(f.StatusOptions, strings.TrimPrefix(value, "prestaged.status_"))
+		}
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"), itemsPerPage)
+
+	p.NItems, err = h.Model.CountPreStagedDevices(tenantID, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+	p.ClampCurrentPage()
+
+	devices, err := h.Model.GetPreStagedDevicesByPage(tenantID, p, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	pending, err := h.Model.CountPreStagedDevices(tenantID, filters.PreStagedDeviceFilter{StatusOptions: []string{models.PreStagedDevicePending}})
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.PreStagedDevicesIndex(" | Pre-staged devices",
+		admin_views.PreStagedDevices(c, p, f, devices, pending, importErrors, successMessage, itemsPerPage, agentsExists, serversExists, commonInfo),
+		commonInfo))
+}
+
+// preStagedDevice returns the pending pre-staged device matching the agent being admitted, or nil if the
+// agent wasn't pre-staged and it's admitted as usual
+func (h *Handler) preStagedDevice(a *ent.Agent, commonInfo *partials.CommonInfo) *ent.PreStagedDevice {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return nil
+	}
+
+	d, err := h.Model.MatchPreStagedDevice(a.ID, tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not match agent %s with the pre-staged devices, reason: %v", a.ID, err)
+		return nil
+	}
+	return d
+}
+
+// preStagedSite returns the site of the pre-staged device if the agent has to be moved to it, it takes
+// precedence over the site of the agent's network
+func preStagedSite(a *ent.Agent, d *ent.PreStagedDevice) *ent.Site {
+	if d == nil || d.Edges.Site == nil {
+		return nil
+	}
+	if len(a.Edges.Site) == 1 && a.Edges.Site[0].ID == d.Edges.Site.ID {
+		return nil
+	}
+	return d.Edges.Site
+}
+
+// applyPreStagedDevice sets the nickname and tags of the pre-staged device to the admitted agent, the
+// admission isn't undone if it fails
+func (h *Handler) applyPreStagedDevice(a *ent.Agent, d *ent.PreStagedDevice) {
+	if err := h.Model.ApplyPreStagedDevice(a.ID, d); err != nil {
+		log.Printf("[ERROR]: could not apply the pre-staged device %d to agent %s, reason: %v", d.ID, a.ID, err)
+		return
+	}
+	log.Printf("[INFO]: agent %s has been matched with the pre-staged device %d", a.ID, d.ID)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/open-uem/openuem-console/internal/views/reports_views"
+)
+
+// PrinterDrivers shows the driver version approved for each printer model of the tenant
+func (h *Handler) PrinterDrivers(c echo.Context) error {
+	return h.renderPrinterDrivers(c, "")
+}
+
+// SavePrinterDriver approves the driver version of a printer model of the tenant
+func (h *Handler) SavePrinterDriver(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	model := strings.TrimSpace(c.FormValue("model"))
+	driverVersion := strings.TrimSpace(c.FormValue("driver-version"))
+	if model == "" || driverVersion == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "printer_drivers.model_and_version_required"), true))
+	}
+
+	if err := h.Model.SaveApprovedPrinterDriver(tenantID, model, driverVersion); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has approved the driver version %q of printer model %q in tenant %d", driverVersion, model, tenantID)
+
+	return h.renderPrinterDrivers(c, i18n.T(c.Request().Context(), "printer_drivers.saved"))
+}
+
+// DeletePrinterDriver removes the approved driver of a printer model of the tenant
+func (h *Handler) DeletePrinterDriver(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return resourceNotFound(c)
+	}
+
+	if err := h.Model.DeleteApprovedPrinterDriver(tenantID, id); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	h.auditTenantData(c, "has removed the approved printer driver %d of tenant %d", id, tenantID)
+
+	return h.renderPrinterDrivers(c, i18n.T(c.Request().Context(), "printer_drivers.deleted"))
+}
+
+func (h *Handler) renderPrinterDrivers(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
+	}
+
+	drivers, err := h.Model.GetApprovedPrinterDrivers(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	// The models reported by the agents of the tenant are suggested
+	allSites := *commonInfo
+	allSites.SiteID = "-1"
+	versions, err := h.Model.GetPrinterDriverVersions(&allSites)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.PrinterDriversIndex(" | Printer drivers",
+		admin_views.PrinterDrivers(c, drivers, versions, successMessage, agentsExists, serversExists, commonInfo),
+		commonInfo))
+}
+
+// PrinterDriverComplianceReport shows the printer models with an approved driver and how many printers don't use
+// it. The printers of a model that don't use it are listed once the model is chosen
+func (h *Handler) PrinterDriverComplianceReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	summary, err := h.Model.GetPrinterDriverComplianceSummary(commonInfo)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the printer driver compliance, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_printer_drivers"), true))
+	}
+
+	printerModel := c.QueryParam("model")
+	if printerModel == "" {
+		return RenderView(c, reports_views.ReportsIndex("| Printer drivers", reports_views.PrinterDrivers(c, summary, printerModel, nil, commonInfo), commonInfo))
+	}
+
+	entries, err := h.Model.GetPrinterDriverCompliance(commonInfo, printerModel)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the printers without the approved driver, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_printer_drivers"), true))
+	}
+
+	return RenderView(c, reports_views.ReportsIndex("| Printer drivers", reports_views.PrinterDrivers(c, summary, printerModel, entries, commonInfo), commonInfo))
+}
+
+// PrinterDriverComplianceCSV sends the printers that don't use the approved driver of their model as a CSV file,
+// only those of a model if it's chosen
+func (h *Handler) PrinterDriverComplianceCSV(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.Model.GetPrinterDriverCompliance(commonInfo, c.QueryParam("model"))
+	if err != nil {
+		log.Printf("[ERROR]: could not get the printers without the approved driver, reason: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "reports.could_not_get_printer_drivers"))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="openuem-printer-drivers-%s.csv"`, time.Now().Format("20060102")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write([]string{"site", "agent_id", "hostname", "printer", "is_default", "default_printer", "model", "driver_version", "approved_version"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := w.Write([]string{e.SiteName, e.AgentID, e.Hostname, e.Printer, strconv.FormatBool(e.IsDefault), e.DefaultPrinter, e.Model, e.DriverVersion, e.ApprovedVersion}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
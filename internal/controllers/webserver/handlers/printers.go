@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/charts"
 	"github.com/open-uem/openuem-console/internal/views/printers_views"
 )
 
@@ -11,5 +14,15 @@ func (h *Handler) NetworkPrinters(c echo.Context) error {
 		return err
 	}
 
-	return RenderView(c, printers_views.PrintersIndex("| Network Printers", printers_views.Printers(c, commonInfo), commonInfo))
+	byModel, err := h.Model.GetPrinterModelDistribution(commonInfo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	nPrinters := 0
+	for _, p := range byModel {
+		nPrinters += p.Count
+	}
+
+	return RenderView(c, printers_views.PrintersIndex("| Network Printers", printers_views.Printers(c, commonInfo, nPrinters, charts.PrintersByModel(c.Request().Context(), byModel)), commonInfo))
 }
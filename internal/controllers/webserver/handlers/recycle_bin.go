@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/agents_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// purgeDeletedAgentsInterval is how often the agents past the recycle bin retention are purged
+const purgeDeletedAgentsInterval = 1 * time.Hour
+
+func (h *Handler) ListDeletedAgents(c echo.Context, successMessage, errMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agents, err := h.Model.GetDeletedAgents(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, agents_views.AgentsIndex(" | Agents", agents_views.RecycleBin(c, agents, h.Model.GetDeletedAgentsRetention(), successMessage, errMessage, commonInfo), commonInfo))
+}
+
+// RestoreAgent takes an agent out of the recycle bin. If its site no longer exists,
+// a new site of the tenant is asked for before restoring it
+func (h *Handler) RestoreAgent(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleOperator); err != nil {
+		return err
+	}
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agentId := c.Param("uuid")
+	if agentId == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), true))
+	}
+
+	siteID := 0
+	if v := c.FormValue("site_id"); v != "" {
+		siteID, err = strconv.Atoi(v)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "recycle_bin.invalid_site"), true))
+		}
+	}
+
+	if err := h.Model.RestoreAgent(agentId, siteID, commonInfo); err != nil {
+		if !errors.Is(err, models.ErrSiteRequired) {
+			_, errMessage := modelError(c, err)
+			return h.ListDeletedAgents(c, "", errMessage)
+		}
+
+		agent, err := h.Model.GetDeletedAgentById(agentId, commonInfo)
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+
+		tenantID, err := strconv.Atoi(commonInfo.TenantID)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+		}
+
+		sites, err := h.Model.GetSites(tenantID)
+		if err != nil {
+			return RenderModelError(c, err)
+		}
+
+		return RenderView(c, agents_views.AgentsIndex(" | Agents", agents_views.RestoreAgentSelectSite(c, agent, sites, commonInfo), commonInfo))
+	}
+
+	return h.ListDeletedAgents(c, i18n.T(c.Request().Context(), "recycle_bin.restored"), "")
+}
+
+// StartPurgeDeletedAgentsJob removes periodically the agents that have been in the
+// recycle bin longer than the retention, revoking their certificates
+func (h *Handler) StartPurgeDeletedAgentsJob() error {
+	var err error
+
+	h.PurgeDeletedAgentsJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			purgeDeletedAgentsInterval,
+		),
+		gocron.NewTask(
+			func() {
+				purged, err := h.Model.PurgeDeletedAgents()
+				if err != nil {
+					log.Printf("[ERROR]: could not purge deleted agents, reason: %v", err)
+				}
+				if purged > 0 {
+					log.Printf("[INFO]: %d deleted agents have been purged", purged)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the deleted agents purge job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
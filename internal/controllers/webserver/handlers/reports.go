@@ -29,6 +29,7 @@ import (
 	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/agents_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 	"github.com/open-uem/openuem-console/internal/views/reports_views"
 	"github.com/open-uem/utils"
@@ -106,7 +107,7 @@ func (h *Handler) GenerateAgentsCSVReport(c echo.Context, w *csv.Writer, fileNam
 	w.Write([]string{"name", "status", "os", "version", "ip", "last_contact"})
 
 	for _, agent := range allAgents {
-		record := []string{agent.Nickname, string(agent.AgentStatus), agent.Os, agent.Edges.Release.Version, agent.IP, agent.LastContact.Format("2006-01-02T15:03:04")}
+		record := []string{agent.Nickname, string(agent.AgentStatus), agent.Os, agent.Edges.Release.Version, agent.IP, commonInfo.Dates.In(agent.LastContact).Format(time.RFC3339)}
 		if err := w.Write(record); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
 		}
@@ -294,12 +295,12 @@ func (h *Handler) GenerateUpdatesCSVReport(c echo.Context, w *csv.Writer, fileNa
 	w.Write([]string{"name", "os", "antivirus", "antivirus_enabled", "antivirus_updated"})
 
 	for _, update := range allSystemUpdates {
-		lastSearch := update.LastSearch.Format("2006-01-02T15:03:04")
+		lastSearch := commonInfo.Dates.In(update.LastSearch).Format(time.RFC3339)
 		if update.LastSearch.IsZero() {
 			lastSearch = "-"
 		}
 
-		lastInstall := update.LastInstall.Format("2006-01-02T15:03:04")
+		lastInstall := commonInfo.Dates.In(update.LastInstall).Format(time.RFC3339)
 		if update.LastInstall.IsZero() {
 			lastInstall = "-"
 		}
@@ -351,7 +352,7 @@ func (h *Handler) GenerateAgentsReport(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_agents"), false))
 	}
 
-	m, err := GetAgentsReport(c, allAgents)
+	m, err := GetAgentsReport(c, allAgents, commonInfo.Dates)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_initiate_report"), false))
 	}
@@ -373,7 +374,7 @@ func (h *Handler) GenerateAgentsReport(c echo.Context) error {
 	return c.String(http.StatusOK, "")
 }
 
-func GetAgentsReport(c echo.Context, agents []*ent.Agent) (core.Maroto, error) {
+func GetAgentsReport(c echo.Context, agents []*ent.Agent, dates helpers.DateFormatter) (core.Maroto, error) {
 	cfg := config.NewBuilder().
 		WithPageNumber().
 		WithLeftMargin(10).
@@ -401,12 +402,12 @@ func GetAgentsReport(c echo.Context, agents []*ent.Agent) (core.Maroto, error) {
 		return nil, err
 	}
 
-	m.AddRows(getAgentsTransactions(agents)...)
+	m.AddRows(getAgentsTransactions(agents, dates)...)
 
 	return m, nil
 }
 
-func getAgentsTransactions(agents []*ent.Agent) []core.Row {
+func getAgentsTransactions(agents []*ent.Agent, dates helpers.DateFormatter) []core.Row {
 	rows := []core.Row{}
 
 	var contentsRow []core.Row
@@ -423,7 +424,7 @@ func getAgentsTransactions(agents []*ent.Agent) []core.Row {
 			}),
 			text.NewCol(2, agent.Edges.Release.Version, props.Text{Size: 8, Align: align.Center}),
 			text.NewCol(2, agent.IP, props.Text{Size: 8, Align: align.Center}),
-			text.NewCol(2, agent.LastContact.Format("2006-01-02 15:03"), props.Text{Size: 8, Align: align.Center}),
+			text.NewCol(2, dates.DateTime(agent.LastContact), props.Text{Size: 8, Align: align.Center}),
 		)
 		if i%2 == 0 {
 			gray := getLightGreenColor()
@@ -455,6 +456,9 @@ func (h *Handler) GetAgentFilters(c echo.Context) (*filters.AgentFilter, error)
 			if value == "No Contact" {
 				f.NoContact = true
 			}
+			if value == "Late" {
+				f.Late = true
+			}
 			filteredAgentStatusOptions = append(filteredAgentStatusOptions, value)
 		}
 	}
@@ -849,7 +853,7 @@ func (h *Handler) GenerateUpdatesReport(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_system_updates"), false))
 	}
 
-	m, err := GetSystemUpdatesReport(c, allSystemUpdates)
+	m, err := GetSystemUpdatesReport(c, allSystemUpdates, commonInfo.Dates)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_initiate_report"), false))
 	}
@@ -871,7 +875,7 @@ func (h *Handler) GenerateUpdatesReport(c echo.Context) error {
 	return c.String(http.StatusOK, "")
 }
 
-func GetSystemUpdatesReport(c echo.Context, updates []models.SystemUpdate) (core.Maroto, error) {
+func GetSystemUpdatesReport(c echo.Context, updates []models.SystemUpdate, dates helpers.DateFormatter) (core.Maroto, error) {
 	cfg := config.NewBuilder().
 		WithPageNumber().
 		WithLeftMargin(10).
@@ -899,12 +903,12 @@ func GetSystemUpdatesReport(c echo.Context, updates []models.SystemUpdate) (core
 		return nil, err
 	}
 
-	m.AddRows(getSystemUpdatesTransactions(c, updates)...)
+	m.AddRows(getSystemUpdatesTransactions(c, updates, dates)...)
 
 	return m, nil
 }
 
-func getSystemUpdatesTransactions(c echo.Context, updates []models.SystemUpdate) []core.Row {
+func getSystemUpdatesTransactions(c echo.Context, updates []models.SystemUpdate, dates helpers.DateFormatter) []core.Row {
 	rows := []core.Row{}
 
 	var contentsRow []core.Row
@@ -912,12 +916,12 @@ func getSystemUpdatesTransactions(c echo.Context, updates []models.SystemUpdate)
 	for i, update := range updates {
 		osImage := getOperatingSystemPNG(update.OS)
 
-		lastSearch := update.LastSearch.Format("2006-01-02T15:03:04")
+		lastSearch := dates.DateTime(update.LastSearch)
 		if update.LastSearch.IsZero() {
 			lastSearch = "-"
 		}
 
-		lastInstall := update.LastInstall.Format("2006-01-02T15:03:04")
+		lastInstall := dates.DateTime(update.LastInstall)
 		if update.LastInstall.IsZero() {
 			lastInstall = "-"
 		}
@@ -1369,7 +1373,7 @@ func (h *Handler) GetComputerReport(c echo.Context, agentID string, commonInfo *
 	m := maroto.NewMetricsDecorator(mrt)
 
 	header := []core.Row{
-		getPageHeader(i18n.T(c.Request().Context(), "reports.computer_inventory") + " - " + commonInfo.Translator.FmtDateMedium(time.Now())),
+		getPageHeader(i18n.T(c.Request().Context(), "reports.computer_inventory") + " - " + commonInfo.Dates.Date(time.Now())),
 	}
 
 	if err := m.RegisterHeader(header...); err != nil {
@@ -1522,9 +1526,9 @@ func (h *Handler) getComputerInfo(c echo.Context, agentID string, commonInfo *pa
 			text.NewCol(1, i18n.T(c.Request().Context(), "inventory.os.username"), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BackgroundColor: lightGreen, BorderColor: &props.BlackColor, BorderType: border.Full}),
 			text.NewCol(3, osInfo.Edges.Operatingsystem.Username, props.Text{Size: 7, Align: align.Center, Top: 0.7}).WithStyle(&props.Cell{BorderColor: &props.BlackColor, BorderType: border.Full}),
 			text.NewCol(2, i18n.T(c.Request().Context(), "inventory.os.installation"), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BackgroundColor: lightGreen, BorderColor: &props.BlackColor, BorderType: border.Full}),
-			text.NewCol(2, commonInfo.Translator.FmtDateMedium(osInfo.Edges.Operatingsystem.InstallDate.Local()), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BorderColor: &props.BlackColor, BorderType: border.Full}),
+			text.NewCol(2, commonInfo.Dates.Date(osInfo.Edges.Operatingsystem.InstallDate), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BorderColor: &props.BlackColor, BorderType: border.Full}),
 			text.NewCol(1, i18n.T(c.Request().Context(), "inventory.os.last_bootup"), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BackgroundColor: lightGreen, BorderColor: &props.BlackColor, BorderType: border.Full}),
-			text.NewCol(3, commonInfo.Translator.FmtDateMedium(osInfo.Edges.Operatingsystem.LastBootupTime.Local())+" "+commonInfo.Translator.FmtTimeShort(osInfo.Edges.Operatingsystem.LastBootupTime.Local()), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BorderColor: &props.BlackColor, BorderType: border.Full}),
+			text.NewCol(3, commonInfo.Dates.DateTime(osInfo.Edges.Operatingsystem.LastBootupTime), props.Text{Size: 7, Align: align.Left, Left: 1, Top: 1}).WithStyle(&props.Cell{BorderColor: &props.BlackColor, BorderType: border.Full}),
 		)
 		rows = append(rows, r)
 	}
@@ -2107,10 +2111,10 @@ func (h *Handler) GenerateComputerODSReport(c echo.Context) error {
 		if err := f.SetCellValue(osSheetName, "B9", osInfo.Edges.Operatingsystem.Username); err != nil {
 			return err
 		}
-		if err := f.SetCellValue(osSheetName, "C9", commonInfo.Translator.FmtDateMedium(osInfo.Edges.Operatingsystem.InstallDate.Local())); err != nil {
+		if err := f.SetCellValue(osSheetName, "C9", commonInfo.Dates.Date(osInfo.Edges.Operatingsystem.InstallDate)); err != nil {
 			return err
 		}
-		if err := f.SetCellValue(osSheetName, "D9", commonInfo.Translator.FmtDateMedium(osInfo.Edges.Operatingsystem.LastBootupTime.Local())+" "+commonInfo.Translator.FmtTimeShort(osInfo.Edges.Operatingsystem.LastBootupTime.Local())); err != nil {
+		if err := f.SetCellValue(osSheetName, "D9", commonInfo.Dates.DateTime(osInfo.Edges.Operatingsystem.LastBootupTime)); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// retentionPurgeHour is the hour of the night when the data past its retention is purged
+const retentionPurgeHour = 3
+
+// DataRetention shows the retention of the historical data and the storage used by each category
+func (h *Handler) DataRetention(c echo.Context) error {
+	return h.renderDataRetention(c, "")
+}
+
+func (h *Handler) renderDataRetention(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	policies, err := h.Model.GetRetentionPolicies()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	usage, err := h.Model.GetStorageUsage()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return RenderView(c, admin_views.DataRetentionIndex(" | Data retention", admin_views.DataRetention(c, policies, usage, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// SaveDataRetention stores the retention of each category, 0 days keeps the data forever
+func (h *Handler) SaveDataRetention(c echo.Context) error {
+	policies := make([]models.RetentionPolicy, 0, len(models.RetentionCategories))
+	for _, category := range models.RetentionCategories {
+		days := 0
+		if v := c.FormValue("days_" + category); v != "" {
+			var err error
+			days, err = strconv.Atoi(v)
+			if err != nil || days < 0 {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "retention.invalid_days"), true))
+			}
+		}
+
+		policies = append(policies, models.RetentionPolicy{
+			Category: category,
+			Days:     days,
+			Rollup:   c.FormValue("rollup_"+category) == "on",
+		})
+	}
+
+	current, err := h.Model.GetRetentionPolicies()
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	for i, p := range policies {
+		if p == current[i] {
+			continue
+		}
+		if err := h.Model.SaveRetentionPolicy(p); err != nil {
+			return RenderModelError(c, err)
+		}
+		h.auditTenantData(c, "has set the retention of %s to %d days (rollup: %t)", p.Category, p.Days, p.Rollup)
+	}
+
+	return h.renderDataRetention(c, i18n.T(c.Request().Context(), "retention.saved"))
+}
+
+// StartRetentionJob purges every night the historical data older than the retention of its category
+func (h *Handler) StartRetentionJob() error {
+	var err error
+
+	h.RetentionJob, err = h.TaskScheduler.NewJob(
+		gocron.DailyJob(
+			1,
+			gocron.NewAtTimes(gocron.NewAtTime(retentionPurgeHour, 0, 0)),
+		),
+		gocron.NewTask(h.purgeHistory),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the data retention job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// purgeHistory applies the retention of each category, the categories without a retention are skipped
+func (h *Handler) purgeHistory() {
+	policies, err := h.Model.GetRetentionPolicies()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the data retention policies, reason: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, p := range policies {
+		if p.Days == 0 {
+			continue
+		}
+
+		purged, err := h.Model.PurgeHistory(p, now, func(purged int) {
+			log.Printf("[INFO]: data retention, %d rows of %s purged so far", purged, p.Category)
+		})
+		if err != nil {
+			log.Printf("[ERROR]: could not purge the %s older than %d days, reason: %v", p.Category, p.Days, err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("[INFO]: %d rows of %s older than %d days have been purged", purged, p.Category, p.Days)
+		}
+	}
+}
@@ -8,662 +8,916 @@ import (
 	"github.com/open-uem/openuem-console/internal/views/login_views"
 )
 
+// routeAccess is who can request a route, every route must declare it so a route can't be
+// registered without the middleware that protects it
+type routeAccess int
+
+const (
+	accessUndeclared routeAccess = iota
+	// accessPublic routes don't require a session, e.g. the login and the health check
+	accessPublic
+	// accessUser routes require a logged in user
+	accessUser
+	// accessTenantOperator routes require an operator or admin of the tenant
+	accessTenantOperator
+	// accessAllowlistedTenantOperator routes also require the request to come from the admin allowlist
+	accessAllowlistedTenantOperator
+	// accessTenantAdmin routes require an admin of the tenant from the admin allowlist
+	accessTenantAdmin
+	// accessMainTenantAdmin routes require an admin of the main tenant from the admin allowlist
+	accessMainTenantAdmin
+)
+
+func (a routeAccess) String() string {
+	switch a {
+	case accessPublic:
+		return "public"
+	case accessUser:
+		return "user"
+	case accessTenantOperator:
+		return "tenant operator"
+	case accessAllowlistedTenantOperator:
+		return "tenant operator (admin allowlist)"
+	case accessTenantAdmin:
+		return "tenant admin"
+	case accessMainTenantAdmin:
+		return "main tenant admin"
+	default:
+		return "undeclared"
+	}
+}
+
+// route is an entry of the route table of the console
+type route struct {
+	Method  string
+	Path    string
+	Handler echo.HandlerFunc
+	Access  routeAccess
+}
+
 func (h *Handler) Register(e *echo.Echo) {
-	e.GET("/", h.Dashboard, h.IsAuthenticated)
-	e.GET("/tenant/:tenant", h.Dashboard, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site", h.Dashboard, h.IsAuthenticated)
-
-	e.GET("/auth", h.Auth)
-	e.GET("/auth/confirm/:token", h.ConfirmEmail)
-
-	e.GET("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.POST("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.DELETE("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.GET("/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.POST("/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.GET("/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.POST("/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.GET("/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.POST("/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.GET("/agents/:uuid/delete", h.AgentDelete, h.IsAuthenticated)
-	e.GET("/agents/:uuid/disable", h.AgentDisable, h.IsAuthenticated)
-	e.GET("/agents/:uuid/admit", h.AgentAdmit, h.IsAuthenticated)
-	e.GET("/agents/:uuid/logs", h.AgentLogs, h.IsAuthenticated)
-	e.GET("/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/agents/:uuid/enabled", h.AgentEnable, h.IsAuthenticated)
-	e.POST("/agents/:uuid/forcereport", h.AgentForceRun, h.IsAuthenticated)
-	e.POST("/agents/:uuid/disable", h.AgentConfirmDisable, h.IsAuthenticated)
-	e.POST("/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, h.IsAuthenticated)
-	e.POST("/agents/:uuid/forcerestart", h.AgentForceRestart, h.IsAuthenticated)
-	e.POST("/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, h.IsAuthenticated)
-	e.DELETE("/agents/:uuid", h.AgentConfirmDelete, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/:uuid/delete", h.AgentDelete, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/:uuid/disable", h.AgentDisable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/:uuid/admit", h.AgentAdmit, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/:uuid/logs", h.AgentLogs, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/enabled", h.AgentEnable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/forcereport", h.AgentForceRun, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/disable", h.AgentConfirmDisable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/forcerestart", h.AgentForceRestart, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/agents/:uuid", h.AgentConfirmDelete, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/enable", h.AgentsEnable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/disable", h.AgentsDisable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/:uuid/delete", h.AgentDelete, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/:uuid/disable", h.AgentDisable, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/:uuid/admit", h.AgentAdmit, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/:uuid/logs", h.AgentLogs, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/settings", h.AgentSettings, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/enabled", h.AgentEnable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/forcereport", h.AgentForceRun, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/disable", h.AgentConfirmDisable, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/forcerestart", h.AgentForceRestart, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/agents/:uuid", h.AgentConfirmDelete, h.IsAuthenticated)
-
-	// Global Admin routes - only Main Tenant Admins
-	e.GET("/admin", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/tenants", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/tenants", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-
-	// Global User Management - only Main Tenant Admins (user CRUD)
-	e.GET("/admin/users", func(c echo.Context) error { return h.ListUsers(c, "", "") }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users", func(c echo.Context) error { return h.ListUsers(c, "", "") }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/users/new", h.NewUser, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/new", h.AddUser, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/import", h.ImportUsers, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/certificate", h.RequestUserCertificate, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/renewcertificate", h.RenewUserCertificate, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/askconfirm", h.AskForConfirmation, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/confirmemail", h.SetEmailConfirmed, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/approve", h.ApproveAccount, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/users/:uid/resendpasslink", h.ResendPasswordLink, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/users/:uid", h.DeleteUser, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-
-	// Tenant management routes - only Main Tenant Admins
-	e.GET("/admin/tenants/new", h.NewTenant, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/tenants/new", h.AddTenant, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/tenants/import", h.ImportTenants, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/tenants/:tenant", h.EditTenant, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/tenants/:tenant", h.EditTenant, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/tenants/:tenant/confirm-delete", func(c echo.Context) error { return h.ListTenants(c, "", "", true) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/tenants/:tenant", h.DeleteTenant, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-
-	// Global Settings routes - only Main Tenant Admins
-	e.GET("/admin/sessions", func(c echo.Context) error { successMessage := ""; return h.ListSessions(c, successMessage) }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/sessions/:token/delete", h.SessionDelete)
-	e.DELETE("/admin/sessions/:token", h.SessionConfirmDelete, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/smtp", h.SMTPSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/smtp", h.SMTPSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/smtp/test", h.TestSMTPSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/settings", h.GeneralSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/settings", h.GeneralSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/branding", h.GetBrandingSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/logo", h.PostBrandingLogo, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/branding/logo", h.DeleteBrandingLogo, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/favicon", h.PostBrandingFavicon, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/branding/favicon", h.DeleteBrandingFavicon, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/product-name", h.PostBrandingProductName, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/colors", h.PostBrandingColors, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/login", h.PostBrandingLogin, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/login-background", h.PostBrandingLoginBackground, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/branding/login-background", h.DeleteBrandingLoginBackground, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/show-version", h.PostBrandingShowVersion, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/bug-report-link", h.PostBrandingBugReportLink, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/branding/help-link", h.PostBrandingHelpLink, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/certificates", h.ListCertificates, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/certificates", h.CertificateConfirmRevocation, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/certificates", h.RevocateCertificate, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/authentication", h.AuthenticationSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/authentication", h.AuthenticationSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/update-servers", h.UpdateServers, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/update-servers", h.UpdateServers, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/update-servers/:serverId", h.UpdateServers, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/update-servers/confirm", h.UpdateServersConfirm, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/confirm-delete-server/:serverId", h.DeleteServerConfirm, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/rustdesk", h.RustDeskSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/rustdesk", h.RustDeskSettings, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/software-repos", func(c echo.Context) error { return h.SoftwareRepos(c, "") }, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/software-repos/new", h.SoftwareRepoNew, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/software-repos", h.SoftwareRepoCreate, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.GET("/admin/software-repos/:repoId/edit", h.SoftwareRepoEdit, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.PUT("/admin/software-repos/:repoId", h.SoftwareRepoUpdate, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.DELETE("/admin/software-repos/:repoId", h.SoftwareRepoDelete, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-	e.POST("/admin/software-repos/:repoId/test", h.SoftwareRepoTestConnection, h.IsAuthenticated, h.MainTenantAdminMiddleware)
-
-	// Tenant-specific Settings routes - Admins and Operators (can manage settings but not users)
-	e.GET("/tenant/:tenant/admin", h.TagManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin", h.TagManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/tags", h.TagManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/tags", h.TagManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/admin/tags", h.TagManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/metadata", h.OrgMetadataManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/metadata", h.OrgMetadataManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/admin/metadata", h.OrgMetadataManager, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/rustdesk", h.RustDeskSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/rustdesk", h.RustDeskSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/smtp", h.SMTPSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/smtp", h.SMTPSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/smtp/test", h.TestSMTPSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/settings", h.GeneralSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/settings", h.GeneralSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/update-agents", h.UpdateAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/update-agents", h.UpdateAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/admin/update-agents", h.UpdateAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/update-agents/confirm", h.UpdateAgentsConfirm, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/inherit", h.ApplyGlobalSettings, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/software-repos", func(c echo.Context) error { return h.SoftwareRepos(c, "") }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/software-repos/new", h.SoftwareRepoNew, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/software-repos", h.SoftwareRepoCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/admin/software-repos/:repoId/edit", h.SoftwareRepoEdit, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.PUT("/tenant/:tenant/admin/software-repos/:repoId", h.SoftwareRepoUpdate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/admin/software-repos/:repoId", h.SoftwareRepoDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/admin/software-repos/:repoId/test", h.SoftwareRepoTestConnection, h.IsAuthenticated, h.TenantOperatorMiddleware)
-
-	// Tenant Members routes - Tenant Admins can assign/remove users and change roles (NOT create/delete)
-	e.GET("/tenant/:tenant/admin/members", h.ListTenantMembers, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/members", h.AddTenantMember, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.DELETE("/tenant/:tenant/admin/members/:uid", h.RemoveTenantMember, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/members/:uid/role", h.UpdateTenantMemberRole, h.IsAuthenticated, h.TenantAdminMiddleware)
-
-	// Enrollment Token routes - Tenant Admins can create/manage enrollment tokens
-	e.GET("/tenant/:tenant/admin/enrollment", h.ListEnrollmentTokens, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/enrollment", h.CreateEnrollmentToken, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.DELETE("/tenant/:tenant/admin/enrollment/:id", h.DeleteEnrollmentToken, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/enrollment/:id/toggle", h.ToggleEnrollmentToken, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/enrollment/:id/config", h.DownloadConfigZIP, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/enrollment/:id/command", h.GetInstallCommand, h.IsAuthenticated, h.TenantAdminMiddleware)
-
-	e.GET("/tenant/:tenant/admin/sites", func(c echo.Context) error { return h.ListSites(c, "", "", false) }, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/sites/new", h.NewSite, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/sites/new", h.AddSite, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/sites/import", h.ImportSites, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/sites/:site", h.EditSite, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/sites/:site", h.EditSite, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/sites/:site/confirm-delete", func(c echo.Context) error { return h.ListSites(c, "", "", true) }, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.DELETE("/tenant/:tenant/admin/sites/:site", h.DeleteSite, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/rustdesk/inherit", h.ApplyGlobalRustDeskSettings, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.GET("/tenant/:tenant/admin/netbird", h.NetbirdSettings, h.IsAuthenticated, h.TenantAdminMiddleware)
-	e.POST("/tenant/:tenant/admin/netbird", h.NetbirdSettings, h.IsAuthenticated, h.TenantAdminMiddleware)
-
-	e.GET("/dashboard", h.Dashboard, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/dashboard", h.Dashboard, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/dashboard", h.Dashboard, h.IsAuthenticated)
-
-	e.GET("/deploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/deploy/quickdeploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/deploy/install", h.DeployInstall, h.IsAuthenticated)
-	e.GET("/deploy/uninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.GET("/deploy/searchinstall", h.DeployInstall, h.IsAuthenticated)
-	e.POST("/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/searchuninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.POST("/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
-	e.POST("/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, h.IsAuthenticated)
-	e.GET("/deploy/packages/new", h.DeployPackageNew, h.IsAuthenticated)
-	e.GET("/deploy/packages/family", h.DeployPackageFamily, h.IsAuthenticated)
-	e.DELETE("/deploy/packages/family", h.DeployPackageFamilyDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/packages/import", h.DeployPackageImport, h.IsAuthenticated)
-	e.POST("/deploy/packages/import", h.DeployPackageImportCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/deploy/packages/analyze", h.DeployPackageAnalyze, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/deploy/packages", h.DeployPackageCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/packages/icon/:iconName", h.DeployPackageIcon, h.IsAuthenticated)
-	e.GET("/deploy/packages/:packageId", h.DeployPackageDetail, h.IsAuthenticated)
-	e.GET("/deploy/packages/:packageId/edit", h.DeployPackageEdit, h.IsAuthenticated)
-	e.PUT("/deploy/packages/:packageId", h.DeployPackageUpdate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/deploy/packages/:packageId", h.DeployPackageDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-
-	e.POST("/deploy/catalogs/init", h.DeployCatalogsInit, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, h.IsAuthenticated)
-	e.GET("/deploy/assignments/new", h.DeployAssignmentNew, h.IsAuthenticated)
-	e.POST("/deploy/assignments", h.DeployAssignmentCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/deploy/dashboard", h.DeployDashboardView, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/deploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/quickdeploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/install", h.DeployInstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/uninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/searchinstall", h.DeployInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/searchuninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/packages/new", h.DeployPackageNew, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/packages/family", h.DeployPackageFamily, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/deploy/packages/family", h.DeployPackageFamilyDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/packages/import", h.DeployPackageImport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/deploy/packages/import", h.DeployPackageImportCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/deploy/packages/analyze", h.DeployPackageAnalyze, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/deploy/packages", h.DeployPackageCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/packages/icon/:iconName", h.DeployPackageIcon, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageDetail, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/packages/:packageId/edit", h.DeployPackageEdit, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageUpdate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/deploy/catalogs/init", h.DeployCatalogsInit, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/deploy/assignments/new", h.DeployAssignmentNew, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/deploy/assignments", h.DeployAssignmentCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/deploy/dashboard", h.DeployDashboardView, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/deploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/quickdeploy", h.DeployQuickDeploy, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/install", h.DeployInstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/uninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/searchinstall", h.DeployInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/searchuninstall", h.DeployUninstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/new", h.DeployPackageNew, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/family", h.DeployPackageFamily, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/deploy/packages/family", h.DeployPackageFamilyDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/import", h.DeployPackageImport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/deploy/packages/import", h.DeployPackageImportCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/site/:site/deploy/packages/analyze", h.DeployPackageAnalyze, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/site/:site/deploy/packages", h.DeployPackageCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/icon/:iconName", h.DeployPackageIcon, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageDetail, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/packages/:packageId/edit", h.DeployPackageEdit, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageUpdate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/site/:site/deploy/catalogs/init", h.DeployCatalogsInit, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.POST("/tenant/:tenant/site/:site/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/deploy/assignments/new", h.DeployAssignmentNew, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/deploy/assignments", h.DeployAssignmentCreate, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.DELETE("/tenant/:tenant/site/:site/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, h.IsAuthenticated, h.TenantOperatorMiddleware)
-	e.GET("/tenant/:tenant/site/:site/deploy/dashboard", h.DeployDashboardView, h.IsAuthenticated)
-
-	e.GET("/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.POST("/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.DELETE("/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.GET("/computers/:uuid", h.Overview, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid", h.ComputerConfirmDelete, h.IsAuthenticated)
-	e.GET("/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.POST("/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.GET("/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.POST("/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.GET("/computers/:uuid/hardware", h.Computer, h.IsAuthenticated)
-	e.GET("/computers/:uuid/logical-disks", h.LogicalDisks, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks", h.BrowseLogicalDisk, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks/file", h.UploadFile, h.IsAuthenticated)
-	e.PUT("/computers/:uuid/logical-disks/file", h.RenameItem, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid/logical-disks/file", h.DeleteItem, h.IsAuthenticated)
-	e.POST("/computers/:uuid/logical-disks/folder", h.NewFolder, h.IsAuthenticated)
-	e.PUT("/computers/:uuid/logical-disks/folder", h.RenameItem, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid/logical-disks/folder", h.DeleteItem, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid/logical-disks/many", h.DeleteMany, h.IsAuthenticated)
-	e.GET("/computers/:uuid/monitors", h.Monitors, h.IsAuthenticated)
-	e.GET("/computers/:uuid/network-adapters", h.NetworkAdapters, h.IsAuthenticated)
-	e.GET("/computers/:uuid/os", h.OperatingSystem, h.IsAuthenticated)
-	e.GET("/computers/:uuid/printers", h.Printers, h.IsAuthenticated)
-	e.GET("/computers/:uuid/physical-disks", h.PhysicalDisks, h.IsAuthenticated)
-	e.GET("/computers/:uuid/shares", h.Shares, h.IsAuthenticated)
-	e.GET("/computers/:uuid/remote-assistance", h.RemoteAssistance, h.IsAuthenticated)
-	e.GET("/computers/:uuid/power", h.PowerManagement, h.IsAuthenticated)
-	e.POST("/computers/:uuid/power/:action", h.PowerManagement, h.IsAuthenticated)
-	e.GET("/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.POST("/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.GET("/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.GET("/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
-	e.POST("/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
-	e.POST("/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
-	e.POST("/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
-	e.GET("/computers/:uuid/managed-software", h.ComputerManagedSoftware, h.IsAuthenticated)
-	e.POST("/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, h.IsAuthenticated)
-	e.GET("/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.POST("/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.GET("/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/computers/:uuid/stopvnc", h.ComputerStopVNC, h.IsAuthenticated)
-	e.POST("/computers/:uuid/generaterdp", h.GenerateRDPFile, h.IsAuthenticated)
-	e.POST("/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, h.IsAuthenticated)
-	e.DELETE("/computers/:uuid/printers/:printer", h.RemovePrinter, h.IsAuthenticated)
-	e.POST("/computers/:uuid/sites", h.GetDropdownSites, h.IsAuthenticated)
-	e.POST("/computers/:uuid/nickname", h.Nickname, h.IsAuthenticated)
-	e.GET("/computers/:uuid/rustdesk", h.ComputerStartRustDesk, h.IsAuthenticated)
-	e.POST("/computers/:uuid/startrustdesk", h.RustDeskStart, h.IsAuthenticated)
-	e.POST("/computers/:uuid/stoprustdesk", h.RustDeskStop, h.IsAuthenticated)
-	e.GET("/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/install", h.NetbirdInstall, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/register", h.NetbirdRegister, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/refresh", h.NetbirdRefresh, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/connect", h.NetbirdConnect, h.IsAuthenticated)
-	e.POST("/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, h.IsAuthenticated)
-	e.GET("/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid", h.Overview, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid", h.ComputerConfirmDelete, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/hardware", h.Computer, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/logical-disks", h.LogicalDisks, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks", h.BrowseLogicalDisk, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks/file", h.UploadFile, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/computers/:uuid/logical-disks/file", h.RenameItem, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid/logical-disks/file", h.DeleteItem, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/logical-disks/folder", h.NewFolder, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/computers/:uuid/logical-disks/folder", h.RenameItem, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid/logical-disks/folder", h.DeleteItem, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid/logical-disks/many", h.DeleteMany, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/monitors", h.Monitors, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/network-adapters", h.NetworkAdapters, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/os", h.OperatingSystem, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/printers", h.Printers, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/physical-disks", h.PhysicalDisks, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/shares", h.Shares, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/remote-assistance", h.RemoteAssistance, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/power", h.PowerManagement, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/power/:action", h.PowerManagement, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/managed-software", h.ComputerManagedSoftware, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/stopvnc", h.ComputerStopVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/generaterdp", h.GenerateRDPFile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/computers/:uuid/printers/:printer", h.RemovePrinter, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/nickname", h.Nickname, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/rustdesk", h.ComputerStartRustDesk, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/startrustdesk", h.RustDeskStart, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/stoprustdesk", h.RustDeskStop, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/install", h.NetbirdInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/register", h.NetbirdRegister, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/refresh", h.NetbirdRefresh, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/connect", h.NetbirdConnect, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid", h.Overview, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid", h.ComputerConfirmDelete, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/overview", h.Overview, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/software", h.Apps, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/hardware", h.Computer, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/logical-disks", h.LogicalDisks, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks", h.BrowseLogicalDisk, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.UploadFile, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.RenameItem, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.DeleteItem, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.NewFolder, h.IsAuthenticated)
-	e.PUT("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.RenameItem, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.DeleteItem, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid/logical-disks/many", h.DeleteMany, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/monitors", h.Monitors, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/network-adapters", h.NetworkAdapters, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/os", h.OperatingSystem, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/printers", h.Printers, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/physical-disks", h.PhysicalDisks, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/shares", h.Shares, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/remote-assistance", h.RemoteAssistance, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/power", h.PowerManagement, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/power/:action", h.PowerManagement, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/notes", h.Notes, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/managed-software", h.ComputerManagedSoftware, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/startvnc", h.ComputerStartVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/stopvnc", h.ComputerStopVNC, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/generaterdp", h.GenerateRDPFile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/computers/:uuid/printers/:printer", h.RemovePrinter, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/nickname", h.Nickname, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/rustdesk", h.ComputerStartRustDesk, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/startrustdesk", h.RustDeskStart, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/stoprustdesk", h.RustDeskStop, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/install", h.NetbirdInstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/register", h.NetbirdRegister, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/refresh", h.NetbirdRefresh, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/connect", h.NetbirdConnect, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/status", h.AgentStatus, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/runtask", h.RunTask, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/computers/:uuid/runprofile", h.RunProfile, h.IsAuthenticated)
-
-	e.GET("/download/:filename", h.Download, h.IsAuthenticated)
-
-	e.POST("/logout", h.Logout, h.IsAuthenticated)
-
-	e.GET("/network-printers", h.NetworkPrinters, h.IsAuthenticated)
-
-	e.POST("/packages", h.SearchWingetPackages, h.IsAuthenticated)
-	e.POST("/flatpak", h.SearchFlatpakPackages, h.IsAuthenticated)
-	e.POST("/brew-formulae", h.SearchHomeBrewFormulaePackages, h.IsAuthenticated)
-	e.POST("/brew-casks", h.SearchHomeBrewCasksPackages, h.IsAuthenticated)
-
-	e.GET("/profiles", func(c echo.Context) error { return h.Profiles(c, "") }, h.IsAuthenticated)
-	e.GET("/profiles/new", h.NewProfile, h.IsAuthenticated)
-	e.POST("/profiles/new", h.NewProfile, h.IsAuthenticated)
-	e.GET("/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "GET", "", "") }, h.IsAuthenticated)
-	e.POST("/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "POST", "", "") }, h.IsAuthenticated)
-	e.DELETE("/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "DELETE", "", "") }, h.IsAuthenticated)
-	e.POST("/profiles/:uuid/tags", h.ProfileTags, h.IsAuthenticated)
-	e.DELETE("/profiles/:uuid/tags", h.ProfileTags, h.IsAuthenticated)
-	e.GET("/profiles/:uuid/confirm-delete", h.ConfirmDeleteProfile, h.IsAuthenticated)
-	e.GET("/profiles/:uuid/issues", h.ProfileIssues, h.IsAuthenticated)
-	e.GET("/profiles/task-types", h.ProfileTaskTypes)
-	e.GET("/profiles/task-subtypes", h.ProfileTaskSubTypes)
-	e.GET("/profiles/task-definition", h.ProfileTaskDefinition)
-	e.POST("/profiles/:uuid/enable", func(c echo.Context) error { return h.EnableProfile(c, true) }, h.IsAuthenticated)
-	e.POST("/profiles/:uuid/disable", func(c echo.Context) error { return h.EnableProfile(c, false) }, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/profiles", func(c echo.Context) error { return h.Profiles(c, "") }, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/profiles/new", h.NewProfile, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/profiles/new", h.NewProfile, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "GET", "", "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "POST", "", "") }, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "DELETE", "", "") }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/profiles/:uuid/tags", h.ProfileTags, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/profiles/:uuid/tags", h.ProfileTags, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/profiles/:uuid/confirm-delete", h.ConfirmDeleteProfile, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/profiles/:uuid/issues", h.ProfileIssues, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/profiles/:uuid/enable", func(c echo.Context) error { return h.EnableProfile(c, true) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/profiles/:uuid/disable", func(c echo.Context) error { return h.EnableProfile(c, false) }, h.IsAuthenticated)
+	h.registerRoutes(e, h.routes())
 
 	// Public API — enrollment endpoints (token value acts as auth)
-	e.GET("/api/enroll/:token/config", h.PublicDownloadConfig)
-	e.GET("/api/enroll/:token/install", h.PublicInstallScript)
-
-	e.GET("/register", h.SignIn)
-	e.POST("/register", h.SendRegister)
-
-	e.POST("/reports/agents", h.GenerateAgentsReport, h.IsAuthenticated)
-	e.POST("/reports/computers", h.GenerateComputersReport, h.IsAuthenticated)
-	e.POST("/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
-	e.POST("/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
-	e.POST("/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
-	e.POST("/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
-	e.POST("/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
-	e.POST("/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
-
-	e.POST("/tenant/:tenant/reports/agents", h.GenerateAgentsReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/computers", h.GenerateComputersReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
-
-	e.POST("/tenant/:tenant/site/:site/reports/agents", h.GenerateAgentsReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/computers", h.GenerateComputersReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
-
-	e.GET("/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.POST("/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.GET("/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-	e.POST("/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/security/:uuid/updates", h.ListLatestUpdates, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
-
-	e.GET("/software", h.Software, h.IsAuthenticated)
-	e.POST("/software", h.Software, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/software", h.Software, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/software", h.Software, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/software", h.Software, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/software", h.Software, h.IsAuthenticated)
-
-	e.GET("/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
-	e.POST("/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
-	e.GET("/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.POST("/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.DELETE("/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.GET("/tasks/:profile/confirm-delete/:task", h.ConfirmDeleteTask, h.IsAuthenticated)
-
-	e.GET("/tenant/:tenant/site/:site/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.DELETE("/tenant/:tenant/site/:site/tasks/:id", h.EditTask, h.IsAuthenticated)
-	e.GET("/tenant/:tenant/site/:site/tasks/:profile/confirm-delete/:task", h.ConfirmDeleteTask, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id/enable", func(c echo.Context) error { return h.EnableTask(c, true) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id/disable", func(c echo.Context) error { return h.EnableTask(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id/moveup/:order", func(c echo.Context) error { return h.MoveTask(c, true) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id/movedown/:order", func(c echo.Context) error { return h.MoveTask(c, false) }, h.IsAuthenticated)
-	e.POST("/tenant/:tenant/site/:site/tasks/:id/movefrom/:from/to/:to", h.MoveTaskFromTo, h.IsAuthenticated)
-
-	e.POST("/render-markdown", h.RenderMarkdown, h.IsAuthenticated)
-
-	e.GET("/oidc", h.OIDCLogIn)
-	e.GET("/oidc/callback", h.OIDCCallback)
-
-	e.POST("/login/userpass", h.LoginPasswordAuth)
-	e.POST("/login/changepass", h.LoginPasswordChange)
-	e.GET("/login/forgot", h.LoginForgotPass)
-	e.POST("/login/forgot", h.ForgotPasswordEmail)
-	e.GET("/login/forgotverify", h.VerifyForgotPasswordCode)
-	e.POST("/login/forgotverify", h.VerifyForgotPasswordCode)
-	e.POST("/login/totpregister", h.Register2FA)
-	e.POST("/login/totpconfirm", h.LoginTOTPConfirm)
-	e.POST("/login/totpvalidate", h.LoginTOTPValidate)
-	e.POST("/login/totpbackuprequested", h.LoginTOTPBackupRequest)
-	e.POST("/login/totpbackupcheck", h.LoginTOTPBackupCheck)
-	e.GET("/login/new", h.LoginNewUser)
-
-	e.GET("/myaccount", h.MyAccount, h.IsAuthenticated)
-	e.POST("/myaccount/info", h.UpdatePersonalInfo, h.IsAuthenticated)
-	e.POST("/myaccount/password", h.MyAccountPassword, h.IsAuthenticated)
-	e.POST("/myaccount/enable2fa", h.Enable2FA, h.IsAuthenticated)
-	e.POST("/myaccount/disable2fa", h.Disable2FA, h.IsAuthenticated)
-	e.POST("/myaccount/register2fa", h.Enabled2FA, h.IsAuthenticated)
+	h.registerAPI(e)
+}
+
+// registerRoutes adds the routes with the middleware required by their access, it panics if a route
+// doesn't declare it as that's a programming error
+func (h *Handler) registerRoutes(e *echo.Echo, routes []route) {
+	for _, r := range routes {
+		middleware, ok := h.accessMiddleware(r.Access)
+		if !ok {
+			panic("route " + r.Method + " " + r.Path + " doesn't declare who can request it")
+		}
+		e.Add(r.Method, r.Path, r.Handler, middleware...)
+	}
+}
+
+// accessMiddleware returns the middleware that enforces the access of a route
+func (h *Handler) accessMiddleware(a routeAccess) ([]echo.MiddlewareFunc, bool) {
+	switch a {
+	case accessPublic:
+		return nil, true
+	case accessUser:
+		return []echo.MiddlewareFunc{h.IsAuthenticated, h.TenantAccessMiddleware}, true
+	case accessTenantOperator:
+		return []echo.MiddlewareFunc{h.IsAuthenticated, h.TenantAccessMiddleware, h.TenantOperatorMiddleware}, true
+	case accessAllowlistedTenantOperator:
+		return []echo.MiddlewareFunc{h.IsAuthenticated, h.TenantAccessMiddleware, h.AdminAllowlistMiddleware, h.TenantOperatorMiddleware}, true
+	case accessTenantAdmin:
+		return []echo.MiddlewareFunc{h.IsAuthenticated, h.TenantAccessMiddleware, h.AdminAllowlistMiddleware, h.TenantAdminMiddleware}, true
+	case accessMainTenantAdmin:
+		return []echo.MiddlewareFunc{h.IsAuthenticated, h.AdminAllowlistMiddleware, h.MainTenantAdminMiddleware}, true
+	default:
+		return nil, false
+	}
+}
+
+// routes is the route table of the console, the versioned API has its own table in apiRoutes
+func (h *Handler) routes() []route {
+	return []route{
+		{http.MethodGet, "/", h.Dashboard, accessUser},
+		{http.MethodGet, "/tenant/:tenant", h.Dashboard, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site", h.Dashboard, accessUser},
+
+		{http.MethodGet, "/health", h.HealthCheck, accessPublic},
+
+		{http.MethodGet, "/auth", h.Auth, accessPublic},
+		{http.MethodGet, "/auth/confirm/:token", h.ConfirmEmail, accessPublic},
+
+		{http.MethodGet, "/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodPost, "/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodDelete, "/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodGet, "/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodPost, "/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodGet, "/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodPost, "/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodGet, "/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodPost, "/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodGet, "/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodPost, "/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodGet, "/agents/filter", h.FilterAgentsByHardware, accessUser},
+		{http.MethodPost, "/agents/selection", h.SelectAllMatchingAgents, accessUser},
+		{http.MethodDelete, "/agents/selection", h.ClearAgentSelection, accessUser},
+		{http.MethodPost, "/agents/selection/:uuid", h.ToggleAgentInSelection, accessUser},
+		{http.MethodGet, "/agents/recycle-bin", func(c echo.Context) error { return h.ListDeletedAgents(c, "", "") }, accessUser},
+		{http.MethodPost, "/agents/recycle-bin/:uuid/restore", h.RestoreAgent, accessUser},
+		{http.MethodGet, "/agents/:uuid/delete", h.AgentDelete, accessUser},
+		{http.MethodGet, "/agents/:uuid/disable", h.AgentDisable, accessUser},
+		{http.MethodGet, "/agents/:uuid/admit", h.AgentAdmit, accessUser},
+		{http.MethodGet, "/agents/:uuid/logs", h.AgentLogs, accessUser},
+		{http.MethodGet, "/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/agents/:uuid/enabled", h.AgentEnable, accessUser},
+		{http.MethodPost, "/agents/:uuid/forcereport", h.AgentForceRun, accessUser},
+		{http.MethodPost, "/agents/:uuid/disable", h.AgentConfirmDisable, accessUser},
+		{http.MethodGet, "/agents/:uuid/migrate", h.AgentMigrate, accessUser},
+		{http.MethodPost, "/agents/:uuid/migrate", h.AgentConfirmMigration, accessUser},
+		{http.MethodPost, "/agents/:uuid/enrollment-site", h.AgentMoveToEnrollmentSite, accessUser},
+		{http.MethodPost, "/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, accessUser},
+		{http.MethodPost, "/agents/:uuid/forcerestart", h.AgentForceRestart, accessUser},
+		{http.MethodPost, "/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, accessUser},
+		{http.MethodDelete, "/agents/:uuid", h.AgentConfirmDelete, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/filter", h.FilterAgentsByHardware, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/selection", h.SelectAllMatchingAgents, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/agents/selection", h.ClearAgentSelection, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/selection/:uuid", h.ToggleAgentInSelection, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/recycle-bin", func(c echo.Context) error { return h.ListDeletedAgents(c, "", "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/recycle-bin/:uuid/restore", h.RestoreAgent, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/delete", h.AgentDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/disable", h.AgentDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/admit", h.AgentAdmit, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/logs", h.AgentLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/enabled", h.AgentEnable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/forcereport", h.AgentForceRun, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/disable", h.AgentConfirmDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/agents/:uuid/migrate", h.AgentMigrate, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/migrate", h.AgentConfirmMigration, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/enrollment-site", h.AgentMoveToEnrollmentSite, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/forcerestart", h.AgentForceRestart, accessUser},
+		{http.MethodPost, "/tenant/:tenant/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/agents/:uuid", h.AgentConfirmDelete, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/enable", h.AgentsEnable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/disable", h.AgentsDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/delete", h.AgentsDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/filter", h.FilterAgentsByHardware, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/selection", h.SelectAllMatchingAgents, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/agents/selection", h.ClearAgentSelection, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/selection/:uuid", h.ToggleAgentInSelection, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/recycle-bin", func(c echo.Context) error { return h.ListDeletedAgents(c, "", "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/recycle-bin/:uuid/restore", h.RestoreAgent, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/delete", h.AgentDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/disable", h.AgentDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/admit", h.AgentAdmit, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/logs", h.AgentLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/settings", h.AgentSettings, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/enabled", h.AgentEnable, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/forcereport", h.AgentForceRun, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/disable", h.AgentConfirmDisable, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/agents/:uuid/migrate", h.AgentMigrate, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/migrate", h.AgentConfirmMigration, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/enrollment-site", h.AgentMoveToEnrollmentSite, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/admit", func(c echo.Context) error { return h.AgentConfirmAdmission(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/forcerestart", h.AgentForceRestart, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/agents/:uuid/regeneratecerts", func(c echo.Context) error { return h.AgentConfirmAdmission(c, true) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/agents/:uuid", h.AgentConfirmDelete, accessUser},
+
+		// Global Admin routes - only Main Tenant Admins
+		{http.MethodGet, "/admin", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, accessMainTenantAdmin},
+		{http.MethodPost, "/admin", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants", func(c echo.Context) error { return h.ListTenants(c, "", "", false) }, accessMainTenantAdmin},
+
+		// Global User Management - only Main Tenant Admins (user CRUD)
+		{http.MethodGet, "/admin/users", func(c echo.Context) error { return h.ListUsers(c, "", "") }, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users", func(c echo.Context) error { return h.ListUsers(c, "", "") }, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/users/new", h.NewUser, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/new", h.AddUser, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/import", h.ImportUsers, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/certificate", h.RequestUserCertificate, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/renewcertificate", h.RenewUserCertificate, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/askconfirm", h.AskForConfirmation, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/confirmemail", h.SetEmailConfirmed, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/approve", h.ApproveAccount, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/resendpasslink", h.ResendPasswordLink, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/users/:uid/forcepasswordchange", h.ForceUserPasswordChange, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/users/:uid", h.DeleteUser, accessMainTenantAdmin},
+
+		// Tenant management routes - only Main Tenant Admins
+		{http.MethodGet, "/admin/tenants/new", h.NewTenant, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/new", h.AddTenant, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/import", h.ImportTenants, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/import-bundle", h.NewTenantImport, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/import-bundle", h.UploadTenantImport, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/import-bundle/:import", h.ImportTenantBundle, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/import-bundle/:import/preview", h.PreviewTenantImport, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/import-bundle/:import/report", h.DownloadTenantImportReport, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/:tenant", h.EditTenant, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/:tenant", h.EditTenant, accessMainTenantAdmin},
+		{http.MethodPut, "/admin/tenants/:tenant", h.EditTenant, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/:tenant/confirm-delete", func(c echo.Context) error { return h.ListTenants(c, "", "", true) }, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/tenants/:tenant", h.DeleteTenant, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/:tenant/export", h.StartTenantExport, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/tenants/:tenant/revoke-certificates", h.RevokeTenantAgentCertificates, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/:tenant/export/:export", h.TenantExportStatus, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/tenants/:tenant/export/:export/download", h.DownloadTenantExport, accessMainTenantAdmin},
+
+		// Global Settings routes - only Main Tenant Admins
+		{http.MethodGet, "/admin/sessions", func(c echo.Context) error { successMessage := ""; return h.ListSessions(c, successMessage) }, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/sessions/:token/delete", h.SessionDelete, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/sessions/:token", h.SessionConfirmDelete, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/smtp", h.SMTPSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/smtp", h.SMTPSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/smtp/test", h.TestSMTPSettings, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/settings", h.GeneralSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/settings", h.GeneralSettings, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/allowlist", h.AdminAllowlist, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/allowlist", h.AdminAllowlist, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/security-headers", h.SecurityHeadersSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/security-headers", h.SecurityHeadersSettings, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/security-headers/violations", h.DeleteCSPViolations, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/api-usage", h.APIUsage, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/api-usage", h.APIUsage, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/api-usage/csv", h.APIUsageCSV, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/backups", h.DatabaseBackups, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/backups", h.StartDatabaseBackup, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/backups/history", h.DatabaseBackupsHistory, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/backups/settings", h.SaveDatabaseBackupSettings, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/retention", h.DataRetention, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/retention", h.SaveDataRetention, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/emails", h.Emails, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/emails/:id/retry", h.RetryEmail, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/emails/:id/cancel", h.CancelEmail, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/about", h.About, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/nats", h.NATSStatus, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/nats/reconnect", h.ReconnectNATS, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/nats/echo", h.EchoNATS, accessMainTenantAdmin},
+
+		{http.MethodGet, "/branding/:image", h.GetBrandingImage, accessPublic},
+		{http.MethodGet, "/admin/branding", h.GetBrandingSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/logo", h.PostBrandingLogo, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/branding/logo", h.DeleteBrandingLogo, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/favicon", h.PostBrandingFavicon, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/branding/favicon", h.DeleteBrandingFavicon, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/product-name", h.PostBrandingProductName, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/colors", h.PostBrandingColors, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/login", h.PostBrandingLogin, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/login-background", h.PostBrandingLoginBackground, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/branding/login-background", h.DeleteBrandingLoginBackground, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/show-version", h.PostBrandingShowVersion, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/bug-report-link", h.PostBrandingBugReportLink, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/branding/help-link", h.PostBrandingHelpLink, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/certificates", h.ListCertificates, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/certificates", h.CertificateConfirmRevocation, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/certificates", h.RevocateCertificate, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/authentication", h.AuthenticationSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/authentication", h.AuthenticationSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/authentication/ldap", h.SaveLDAPSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/authentication/ldap/test", h.TestLDAPConnection, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/authentication/password-policy", h.SavePasswordPolicy, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/update-servers", h.UpdateServers, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/update-servers", h.UpdateServers, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/update-servers/:serverId", h.UpdateServers, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/update-servers/confirm", h.UpdateServersConfirm, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/confirm-delete-server/:serverId", h.DeleteServerConfirm, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/rustdesk", h.RustDeskSettings, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/rustdesk", h.RustDeskSettings, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/software-repos", func(c echo.Context) error { return h.SoftwareRepos(c, "") }, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/software-repos/new", h.SoftwareRepoNew, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/software-repos", h.SoftwareRepoCreate, accessMainTenantAdmin},
+		{http.MethodGet, "/admin/software-repos/:repoId/edit", h.SoftwareRepoEdit, accessMainTenantAdmin},
+		{http.MethodPut, "/admin/software-repos/:repoId", h.SoftwareRepoUpdate, accessMainTenantAdmin},
+		{http.MethodDelete, "/admin/software-repos/:repoId", h.SoftwareRepoDelete, accessMainTenantAdmin},
+		{http.MethodPost, "/admin/software-repos/:repoId/test", h.SoftwareRepoTestConnection, accessMainTenantAdmin},
+
+		// Tenant-specific Settings routes - Admins and Operators (can manage settings but not users)
+		{http.MethodGet, "/tenant/:tenant/admin", h.TagManager, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin", h.TagManager, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/tags", h.TagManager, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/tags", h.TagManager, accessAllowlistedTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/admin/tags", h.TagManager, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/metadata", h.OrgMetadataManager, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/metadata", h.OrgMetadataManager, accessAllowlistedTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/admin/metadata", h.OrgMetadataManager, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/rustdesk", h.RustDeskSettings, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/rustdesk", h.RustDeskSettings, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/smtp", h.SMTPSettings, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/smtp", h.SMTPSettings, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/smtp", h.DeleteTenantSMTPSettings, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/smtp/test", h.TestSMTPSettings, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/settings", h.GeneralSettings, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/settings", h.GeneralSettings, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/update-agents", h.UpdateAgents, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/update-agents", h.UpdateAgents, accessAllowlistedTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/admin/update-agents", h.UpdateAgents, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/update-agents/confirm", h.UpdateAgentsConfirm, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/inherit", h.ApplyGlobalSettings, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/software-repos", func(c echo.Context) error { return h.SoftwareRepos(c, "") }, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/software-repos/new", h.SoftwareRepoNew, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/software-repos", h.SoftwareRepoCreate, accessAllowlistedTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/admin/software-repos/:repoId/edit", h.SoftwareRepoEdit, accessAllowlistedTenantOperator},
+		{http.MethodPut, "/tenant/:tenant/admin/software-repos/:repoId", h.SoftwareRepoUpdate, accessAllowlistedTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/admin/software-repos/:repoId", h.SoftwareRepoDelete, accessAllowlistedTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/admin/software-repos/:repoId/test", h.SoftwareRepoTestConnection, accessAllowlistedTenantOperator},
+
+		// Tenant Members routes - Tenant Admins can assign/remove users and change roles (NOT create/delete)
+		{http.MethodGet, "/tenant/:tenant/admin/members", h.ListTenantMembers, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/members", h.AddTenantMember, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/members/:uid", h.RemoveTenantMember, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/members/:uid/role", h.UpdateTenantMemberRole, accessTenantAdmin},
+
+		// Enrollment Token routes - Tenant Admins can create/manage enrollment tokens
+		{http.MethodGet, "/tenant/:tenant/admin/enrollment", h.ListEnrollmentTokens, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/enrollment", h.CreateEnrollmentToken, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/enrollment/:id", h.DeleteEnrollmentToken, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/enrollment/:id/toggle", h.ToggleEnrollmentToken, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/enrollment/:id/landing", h.ToggleEnrollmentTokenLandingPage, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/enrollment/:id/sites", h.EditEnrollmentTokenSites, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/enrollment/:id/sites", h.SaveEnrollmentTokenSites, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/enrollment/:id/downloads", h.EnrollmentTokenDownloads, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/enrollment/:id/config", h.DownloadConfigZIP, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/enrollment/:id/command", h.GetInstallCommand, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/enrollment/scripts", h.SaveInstallScripts, accessTenantAdmin},
+
+		// Pre-staged devices - Tenant Admins import the devices expected to enroll and review those that never did
+		{http.MethodGet, "/tenant/:tenant/admin/prestaged", h.ListPreStagedDevices, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/prestaged/import", h.ImportPreStagedDevices, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/prestaged/:id", h.DeletePreStagedDevice, accessTenantAdmin},
+
+		// Authentication alerts - Tenant Admins review the alerts and the authentication events of the members
+		{http.MethodGet, "/tenant/:tenant/admin/printer-drivers", h.PrinterDrivers, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/printer-drivers", h.SavePrinterDriver, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/printer-drivers/:id", h.DeletePrinterDriver, accessTenantAdmin},
+
+		{http.MethodGet, "/tenant/:tenant/admin/auth-alerts", h.AuthAlerts, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/auth-alerts", h.AuthAlerts, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/auth-alerts/settings", h.SaveAuthAlertSettings, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/auth-alerts/:id/acknowledge", h.AcknowledgeAuthAlert, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/settings-profiles", h.SettingsProfiles, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/settings-profiles", h.SaveSettingsProfile, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/settings-profiles/:id", h.DeleteSettingsProfile, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/settings-profiles/assignments", h.AssignSettingsProfile, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/settings-profiles/assignments/:id", h.UnassignSettingsProfile, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/settings-profiles/push/:uuid", h.PushSettingsProfile, accessTenantAdmin},
+
+		// Bulk decommission - Tenant Admins remove the agents of old hardware, the body is a JSON array of agent IDs
+		{http.MethodPost, "/admin/:tenant/:site/agents/decommission", h.DecommissionAgents, accessTenantAdmin},
+
+		{http.MethodGet, "/tenant/:tenant/admin/sites", func(c echo.Context) error { return h.ListSites(c, "", "", false) }, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/sites/new", h.NewSite, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/sites/new", h.AddSite, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/sites/import", h.ImportSites, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/sites/:site", h.EditSite, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/sites/:site", h.EditSite, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/sites/:site/confirm-delete", func(c echo.Context) error { return h.ListSites(c, "", "", true) }, accessTenantAdmin},
+		{http.MethodDelete, "/tenant/:tenant/admin/sites/:site", h.DeleteSite, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/rustdesk/inherit", h.ApplyGlobalRustDeskSettings, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/netbird", h.NetbirdSettings, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/netbird", h.NetbirdSettings, accessTenantAdmin},
+		{http.MethodGet, "/tenant/:tenant/admin/allowlist", h.AdminAllowlist, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/admin/allowlist", h.AdminAllowlist, accessTenantAdmin},
+
+		{http.MethodGet, "/dashboard", h.Dashboard, accessUser},
+		{http.MethodGet, "/dashboard/checkins", h.CheckinHeatmap, accessUser},
+		{http.MethodGet, "/tenant/:tenant/dashboard", h.Dashboard, accessUser},
+		{http.MethodGet, "/tenant/:tenant/dashboard/checkins", h.CheckinHeatmap, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/dashboard", h.Dashboard, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/dashboard/checkins", h.CheckinHeatmap, accessUser},
+
+		{http.MethodGet, "/deploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/deploy/quickdeploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/deploy/install", h.DeployInstall, accessUser},
+		{http.MethodGet, "/deploy/uninstall", h.DeployUninstall, accessUser},
+		{http.MethodGet, "/deploy/searchinstall", h.DeployInstall, accessUser},
+		{http.MethodPost, "/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, accessTenantOperator},
+		{http.MethodGet, "/deploy/searchuninstall", h.DeployUninstall, accessUser},
+		{http.MethodPost, "/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, accessTenantOperator},
+		{http.MethodGet, "/deploy/selectpackagedeployment", h.SelectPackageDeployment, accessUser},
+		{http.MethodPost, "/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, accessTenantOperator},
+		{http.MethodGet, "/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, accessUser},
+		{http.MethodGet, "/deploy/packages/new", h.DeployPackageNew, accessUser},
+		{http.MethodGet, "/deploy/packages/family", h.DeployPackageFamily, accessUser},
+		{http.MethodDelete, "/deploy/packages/family", h.DeployPackageFamilyDelete, accessTenantOperator},
+		{http.MethodGet, "/deploy/packages/import", h.DeployPackageImport, accessUser},
+		{http.MethodPost, "/deploy/packages/import", h.DeployPackageImportCreate, accessTenantOperator},
+		{http.MethodPost, "/deploy/packages/analyze", h.DeployPackageAnalyze, accessTenantOperator},
+		{http.MethodPost, "/deploy/packages", h.DeployPackageCreate, accessTenantOperator},
+		{http.MethodGet, "/deploy/packages/icon/:iconName", h.DeployPackageIcon, accessUser},
+		{http.MethodGet, "/deploy/packages/:packageId", h.DeployPackageDetail, accessUser},
+		{http.MethodGet, "/deploy/packages/:packageId/edit", h.DeployPackageEdit, accessUser},
+		{http.MethodPut, "/deploy/packages/:packageId", h.DeployPackageUpdate, accessTenantOperator},
+		{http.MethodDelete, "/deploy/packages/:packageId", h.DeployPackageDelete, accessTenantOperator},
+
+		{http.MethodPost, "/deploy/catalogs/init", h.DeployCatalogsInit, accessTenantOperator},
+		{http.MethodPost, "/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, accessTenantOperator},
+		{http.MethodGet, "/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, accessUser},
+		{http.MethodGet, "/deploy/assignments/new", h.DeployAssignmentNew, accessUser},
+		{http.MethodPost, "/deploy/assignments", h.DeployAssignmentCreate, accessTenantOperator},
+		{http.MethodDelete, "/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, accessTenantOperator},
+		{http.MethodGet, "/deploy/dashboard", h.DeployDashboardView, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/deploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/quickdeploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/install", h.DeployInstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/uninstall", h.DeployUninstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/searchinstall", h.DeployInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/searchuninstall", h.DeployUninstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/selectpackagedeployment", h.SelectPackageDeployment, accessUser},
+		{http.MethodPost, "/tenant/:tenant/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/new", h.DeployPackageNew, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/family", h.DeployPackageFamily, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/deploy/packages/family", h.DeployPackageFamilyDelete, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/import", h.DeployPackageImport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/deploy/packages/import", h.DeployPackageImportCreate, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/deploy/packages/analyze", h.DeployPackageAnalyze, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/deploy/packages", h.DeployPackageCreate, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/icon/:iconName", h.DeployPackageIcon, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageDetail, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/packages/:packageId/edit", h.DeployPackageEdit, accessUser},
+		{http.MethodPut, "/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageUpdate, accessTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/deploy/packages/:packageId", h.DeployPackageDelete, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/deploy/catalogs/init", h.DeployCatalogsInit, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/deploy/assignments/new", h.DeployAssignmentNew, accessUser},
+		{http.MethodPost, "/tenant/:tenant/deploy/assignments", h.DeployAssignmentCreate, accessTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/deploy/dashboard", h.DeployDashboardView, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/quickdeploy", h.DeployQuickDeploy, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/install", h.DeployInstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/uninstall", h.DeployUninstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/searchinstall", h.DeployInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/searchinstall", func(c echo.Context) error { return h.SearchPackagesAction(c, true) }, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/searchuninstall", h.DeployUninstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.SelectPackageDeployment, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages", func(c echo.Context) error { return h.DeployPackages(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/new", h.DeployPackageNew, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/family", h.DeployPackageFamily, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/deploy/packages/family", h.DeployPackageFamilyDelete, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/import", h.DeployPackageImport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/packages/import", h.DeployPackageImportCreate, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/packages/analyze", h.DeployPackageAnalyze, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/packages", h.DeployPackageCreate, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/icon/:iconName", h.DeployPackageIcon, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageDetail, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/packages/:packageId/edit", h.DeployPackageEdit, accessUser},
+		{http.MethodPut, "/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageUpdate, accessTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/deploy/packages/:packageId", h.DeployPackageDelete, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/catalogs/init", h.DeployCatalogsInit, accessTenantOperator},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/catalogs/:catalogId/promote", h.DeployCatalogPromote, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/assignments", func(c echo.Context) error { return h.DeployAssignments(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/assignments/new", h.DeployAssignmentNew, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/deploy/assignments", h.DeployAssignmentCreate, accessTenantOperator},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/deploy/assignments/:assignmentId", h.DeployAssignmentDelete, accessTenantOperator},
+		{http.MethodGet, "/tenant/:tenant/site/:site/deploy/dashboard", h.DeployDashboardView, accessUser},
+
+		{http.MethodGet, "/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodPost, "/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodDelete, "/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodGet, "/computers/:uuid", h.Overview, accessUser},
+		{http.MethodDelete, "/computers/:uuid", h.ComputerConfirmDelete, accessUser},
+		{http.MethodGet, "/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodPost, "/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodGet, "/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodPost, "/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodGet, "/computers/:uuid/hardware", h.Computer, accessUser},
+		{http.MethodGet, "/computers/:uuid/logical-disks", h.LogicalDisks, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks", h.BrowseLogicalDisk, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks/file", h.UploadFile, accessUser},
+		{http.MethodPut, "/computers/:uuid/logical-disks/file", h.RenameItem, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, accessUser},
+		{http.MethodDelete, "/computers/:uuid/logical-disks/file", h.DeleteItem, accessUser},
+		{http.MethodPost, "/computers/:uuid/logical-disks/folder", h.NewFolder, accessUser},
+		{http.MethodPut, "/computers/:uuid/logical-disks/folder", h.RenameItem, accessUser},
+		{http.MethodDelete, "/computers/:uuid/logical-disks/folder", h.DeleteItem, accessUser},
+		{http.MethodDelete, "/computers/:uuid/logical-disks/many", h.DeleteMany, accessUser},
+		{http.MethodGet, "/computers/:uuid/monitors", h.Monitors, accessUser},
+		{http.MethodGet, "/computers/:uuid/network-adapters", h.NetworkAdapters, accessUser},
+		{http.MethodGet, "/computers/:uuid/os", h.OperatingSystem, accessUser},
+		{http.MethodGet, "/computers/:uuid/printers", h.Printers, accessUser},
+		{http.MethodGet, "/computers/:uuid/physical-disks", h.PhysicalDisks, accessUser},
+		{http.MethodGet, "/computers/:uuid/shares", h.Shares, accessUser},
+		{http.MethodGet, "/computers/:uuid/remote-assistance", h.RemoteAssistance, accessUser},
+		{http.MethodGet, "/computers/:uuid/power", h.PowerManagement, accessUser},
+		{http.MethodPost, "/computers/:uuid/power/:action", h.PowerManagement, accessUser},
+		{http.MethodGet, "/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodPost, "/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodGet, "/computers/:uuid/commands", h.AgentCommandLogs, accessUser},
+		{http.MethodGet, "/computers/:uuid/log-collections", h.AgentLogCollections, accessUser},
+		{http.MethodGet, "/computers/:uuid/certificate", h.AgentCertificate, accessUser},
+		{http.MethodPost, "/computers/:uuid/log-collections", h.CollectAgentLogs, accessUser},
+		{http.MethodGet, "/computers/:uuid/log-collections/:collection/download", h.DownloadAgentLogCollection, accessUser},
+		{http.MethodGet, "/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodGet, "/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, accessUser},
+		{http.MethodPost, "/computers/:uuid/deploy/install", h.ComputerDeployInstall, accessUser},
+		{http.MethodPost, "/computers/:uuid/deploy/update", h.ComputerDeployUpdate, accessUser},
+		{http.MethodPost, "/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, accessUser},
+		{http.MethodGet, "/computers/:uuid/managed-software", h.ComputerManagedSoftware, accessUser},
+		{http.MethodPost, "/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, accessUser},
+		{http.MethodGet, "/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodPost, "/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodDelete, "/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodGet, "/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/computers/:uuid/stopvnc", h.ComputerStopVNC, accessUser},
+		{http.MethodPost, "/computers/:uuid/generaterdp", h.GenerateRDPFile, accessUser},
+		{http.MethodPost, "/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, accessUser},
+		{http.MethodDelete, "/computers/:uuid/printers/:printer", h.RemovePrinter, accessUser},
+		{http.MethodPost, "/computers/:uuid/sites", h.GetDropdownSites, accessUser},
+		{http.MethodPost, "/computers/:uuid/nickname", h.Nickname, accessUser},
+		{http.MethodGet, "/computers/:uuid/rustdesk", h.ComputerStartRustDesk, accessUser},
+		{http.MethodPost, "/computers/:uuid/startrustdesk", h.RustDeskStart, accessUser},
+		{http.MethodPost, "/computers/:uuid/stoprustdesk", h.RustDeskStop, accessUser},
+		{http.MethodGet, "/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/install", h.NetbirdInstall, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/register", h.NetbirdRegister, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/refresh", h.NetbirdRefresh, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/connect", h.NetbirdConnect, accessUser},
+		{http.MethodPost, "/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, accessUser},
+		{http.MethodGet, "/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid", h.Overview, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid", h.ComputerConfirmDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/hardware", h.Computer, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/logical-disks", h.LogicalDisks, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks", h.BrowseLogicalDisk, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks/file", h.UploadFile, accessUser},
+		{http.MethodPut, "/tenant/:tenant/computers/:uuid/logical-disks/file", h.RenameItem, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid/logical-disks/file", h.DeleteItem, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/logical-disks/folder", h.NewFolder, accessUser},
+		{http.MethodPut, "/tenant/:tenant/computers/:uuid/logical-disks/folder", h.RenameItem, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid/logical-disks/folder", h.DeleteItem, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid/logical-disks/many", h.DeleteMany, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/monitors", h.Monitors, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/network-adapters", h.NetworkAdapters, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/os", h.OperatingSystem, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/printers", h.Printers, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/physical-disks", h.PhysicalDisks, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/shares", h.Shares, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/remote-assistance", h.RemoteAssistance, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/power", h.PowerManagement, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/power/:action", h.PowerManagement, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/commands", h.AgentCommandLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/log-collections", h.AgentLogCollections, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/certificate", h.AgentCertificate, accessUser},
+		// Revoke and re-issue the certificate of an agent - Tenant Admins only, e.g. when a laptop is stolen
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/certificate/revoke", h.RevokeAgentCertificate, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/certificate/reissue", h.ReissueAgentCertificate, accessTenantAdmin},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/log-collections", h.CollectAgentLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/log-collections/:collection/download", h.DownloadAgentLogCollection, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/deploy/install", h.ComputerDeployInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/deploy/update", h.ComputerDeployUpdate, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/managed-software", h.ComputerManagedSoftware, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/stopvnc", h.ComputerStopVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/generaterdp", h.GenerateRDPFile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/computers/:uuid/printers/:printer", h.RemovePrinter, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/nickname", h.Nickname, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/rustdesk", h.ComputerStartRustDesk, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/startrustdesk", h.RustDeskStart, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/stoprustdesk", h.RustDeskStop, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/install", h.NetbirdInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/register", h.NetbirdRegister, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/refresh", h.NetbirdRefresh, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/connect", h.NetbirdConnect, accessUser},
+		{http.MethodPost, "/tenant/:tenant/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid", h.Overview, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid", h.ComputerConfirmDelete, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/overview", h.Overview, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/software", h.Apps, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/hardware", h.Computer, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks", h.LogicalDisks, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks", h.BrowseLogicalDisk, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.UploadFile, accessUser},
+		{http.MethodPut, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.RenameItem, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadfile", h.DownloadFile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadfolder", h.DownloadFolderAsZIP, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/downloadmany", h.DownloadManyAsZIP, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/file", h.DeleteItem, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.NewFolder, accessUser},
+		{http.MethodPut, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.RenameItem, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/folder", h.DeleteItem, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid/logical-disks/many", h.DeleteMany, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/monitors", h.Monitors, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/network-adapters", h.NetworkAdapters, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/os", h.OperatingSystem, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/printers", h.Printers, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/physical-disks", h.PhysicalDisks, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/shares", h.Shares, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/remote-assistance", h.RemoteAssistance, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/power", h.PowerManagement, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/power/:action", h.PowerManagement, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/notes", h.Notes, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/commands", h.AgentCommandLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/log-collections", h.AgentLogCollections, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/certificate", h.AgentCertificate, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/log-collections", h.CollectAgentLogs, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/log-collections/:collection/download", h.DownloadAgentLogCollection, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/deploy", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/deploy/searchinstall", func(c echo.Context) error { return h.ComputerDeploy(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/deploy/install", h.ComputerDeployInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/deploy/update", h.ComputerDeployUpdate, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/managed-software", h.ComputerManagedSoftware, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/managed-software/check", h.ComputerSoftwareCheck, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/startvnc", h.ComputerStartVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/stopvnc", h.ComputerStopVNC, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/generaterdp", h.GenerateRDPFile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/printers/:printer/default", h.SetDefaultPrinter, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/computers/:uuid/printers/:printer", h.RemovePrinter, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/nickname", h.Nickname, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/rustdesk", h.ComputerStartRustDesk, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/startrustdesk", h.RustDeskStart, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/stoprustdesk", h.RustDeskStop, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/netbird", func(c echo.Context) error { return h.Netbird(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/install", h.NetbirdInstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/uninstall", h.NetbirdUninstall, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/register", h.NetbirdRegister, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/switchprofile", h.NetbirdSwitchProfile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/refresh", h.NetbirdRefresh, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/deletepeer", func(c echo.Context) error { return h.NetbirdDeletePeer(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/connect", h.NetbirdConnect, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/netbird/disconnect", func(c echo.Context) error { return h.NetbirdDisconnect(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/status", h.AgentStatus, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/computers/:uuid/tasks", func(c echo.Context) error { return h.ComputerTasks(c, "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/runtask", h.RunTask, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/computers/:uuid/runprofile", h.RunProfile, accessUser},
+
+		{http.MethodGet, "/download/:filename", h.Download, accessUser},
+
+		{http.MethodPost, "/logout", h.Logout, accessUser},
+
+		{http.MethodGet, "/network-printers", h.NetworkPrinters, accessUser},
+
+		{http.MethodPost, "/packages", h.SearchWingetPackages, accessUser},
+		{http.MethodPost, "/flatpak", h.SearchFlatpakPackages, accessUser},
+		{http.MethodPost, "/brew-formulae", h.SearchHomeBrewFormulaePackages, accessUser},
+		{http.MethodPost, "/brew-casks", h.SearchHomeBrewCasksPackages, accessUser},
+
+		{http.MethodGet, "/profiles", func(c echo.Context) error { return h.Profiles(c, "") }, accessUser},
+		{http.MethodGet, "/profiles/new", h.NewProfile, accessUser},
+		{http.MethodPost, "/profiles/new", h.NewProfile, accessUser},
+		{http.MethodGet, "/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "GET", "", "") }, accessUser},
+		{http.MethodPost, "/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "POST", "", "") }, accessUser},
+		{http.MethodDelete, "/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "DELETE", "", "") }, accessUser},
+		{http.MethodPost, "/profiles/:uuid/tags", h.ProfileTags, accessUser},
+		{http.MethodDelete, "/profiles/:uuid/tags", h.ProfileTags, accessUser},
+		{http.MethodGet, "/profiles/:uuid/confirm-delete", h.ConfirmDeleteProfile, accessUser},
+		{http.MethodGet, "/profiles/:uuid/issues", h.ProfileIssues, accessUser},
+		{http.MethodGet, "/profiles/task-types", h.ProfileTaskTypes, accessPublic},
+		{http.MethodGet, "/profiles/task-subtypes", h.ProfileTaskSubTypes, accessPublic},
+		{http.MethodGet, "/profiles/task-definition", h.ProfileTaskDefinition, accessPublic},
+		{http.MethodPost, "/profiles/:uuid/enable", func(c echo.Context) error { return h.EnableProfile(c, true) }, accessUser},
+		{http.MethodPost, "/profiles/:uuid/disable", func(c echo.Context) error { return h.EnableProfile(c, false) }, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/profiles", func(c echo.Context) error { return h.Profiles(c, "") }, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/profiles/new", h.NewProfile, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/profiles/new", h.NewProfile, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "GET", "", "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "POST", "", "") }, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/profiles/:uuid", func(c echo.Context) error { return h.EditProfile(c, "DELETE", "", "") }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/profiles/:uuid/tags", h.ProfileTags, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/profiles/:uuid/tags", h.ProfileTags, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/profiles/:uuid/confirm-delete", h.ConfirmDeleteProfile, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/profiles/:uuid/issues", h.ProfileIssues, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/profiles/:uuid/enable", func(c echo.Context) error { return h.EnableProfile(c, true) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/profiles/:uuid/disable", func(c echo.Context) error { return h.EnableProfile(c, false) }, accessUser},
+
+		{http.MethodGet, "/register", h.SignIn, accessPublic},
+		{http.MethodPost, "/register", h.SendRegister, accessPublic},
+
+		// Enrollment landing page - the token in the path lets end users enroll their devices
+		{http.MethodGet, "/enroll/:token", h.EnrollmentLandingPage, accessPublic},
+
+		{http.MethodPost, "/reports/agents", h.GenerateAgentsReport, accessUser},
+		{http.MethodPost, "/reports/computers", h.GenerateComputersReport, accessUser},
+		{http.MethodPost, "/reports/antivirus", h.GenerateAntivirusReport, accessUser},
+		{http.MethodPost, "/reports/updates", h.GenerateUpdatesReport, accessUser},
+		{http.MethodPost, "/reports/software", h.GenerateSoftwareReport, accessUser},
+		{http.MethodPost, "/reports/computer/:uuid", h.GenerateComputerReport, accessUser},
+		{http.MethodPost, "/reports/:report/csv", h.GenerateCSVReports, accessUser},
+		{http.MethodPost, "/reports/computer/:uuid/ods", h.GenerateComputerODSReport, accessUser},
+		{http.MethodGet, "/reports/disk", h.DiskUsageReport, accessUser},
+		{http.MethodGet, "/reports/printer-drivers", h.PrinterDriverComplianceReport, accessUser},
+		{http.MethodGet, "/reports/printer-drivers/csv", h.PrinterDriverComplianceCSV, accessUser},
+		{http.MethodGet, "/reports/custom", h.CustomReports, accessUser},
+		{http.MethodPost, "/reports/custom", h.SaveCustomReport, accessUser},
+		{http.MethodPost, "/reports/custom/preview", h.PreviewCustomReport, accessUser},
+		{http.MethodDelete, "/reports/custom/:id", h.DeleteCustomReport, accessUser},
+		{http.MethodGet, "/reports/custom/:id/csv", h.ExportCustomReport, accessUser},
+
+		{http.MethodPost, "/tenant/:tenant/reports/agents", h.GenerateAgentsReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/computers", h.GenerateComputersReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/antivirus", h.GenerateAntivirusReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/updates", h.GenerateUpdatesReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/software", h.GenerateSoftwareReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/computer/:uuid", h.GenerateComputerReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/:report/csv", h.GenerateCSVReports, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/computer/:uuid/ods", h.GenerateComputerODSReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/disk", h.DiskUsageReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/printer-drivers", h.PrinterDriverComplianceReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/printer-drivers/csv", h.PrinterDriverComplianceCSV, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/custom", h.CustomReports, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/custom", h.SaveCustomReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/custom/preview", h.PreviewCustomReport, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/reports/custom/:id", h.DeleteCustomReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/custom/:id/csv", h.ExportCustomReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/reports/misplaced", h.MisplacedAgentsReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/reports/misplaced/:uuid", h.MoveMisplacedAgent, accessUser},
+
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/agents", h.GenerateAgentsReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/computers", h.GenerateComputersReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/antivirus", h.GenerateAntivirusReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/updates", h.GenerateUpdatesReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/software", h.GenerateSoftwareReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/computer/:uuid", h.GenerateComputerReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/:report/csv", h.GenerateCSVReports, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/computer/:uuid/ods", h.GenerateComputerODSReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/disk", h.DiskUsageReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/printer-drivers", h.PrinterDriverComplianceReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/printer-drivers/csv", h.PrinterDriverComplianceCSV, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/custom", h.CustomReports, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/custom", h.SaveCustomReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/custom/preview", h.PreviewCustomReport, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/reports/custom/:id", h.DeleteCustomReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/custom/:id/csv", h.ExportCustomReport, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/reports/misplaced", h.MisplacedAgentsReport, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/reports/misplaced/:uuid", h.MoveMisplacedAgent, accessUser},
+
+		{http.MethodGet, "/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodPost, "/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodGet, "/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+		{http.MethodPost, "/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/tenant/:tenant/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodPost, "/tenant/:tenant/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodGet, "/tenant/:tenant/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/security/:uuid/updates", h.ListLatestUpdates, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/security/antivirus", h.ListAntivirusStatus, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, accessUser},
+
+		{http.MethodGet, "/software", h.Software, accessUser},
+		{http.MethodPost, "/software", h.Software, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/software", h.Software, accessUser},
+		{http.MethodPost, "/tenant/:tenant/software", h.Software, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/software", h.Software, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/software", h.Software, accessUser},
+
+		{http.MethodGet, "/tasks/:profile/new", h.NewTask, accessUser},
+		{http.MethodPost, "/tasks/:profile/new", h.NewTask, accessUser},
+		{http.MethodGet, "/tasks/:id", h.EditTask, accessUser},
+		{http.MethodPost, "/tasks/:id", h.EditTask, accessUser},
+		{http.MethodDelete, "/tasks/:id", h.EditTask, accessUser},
+		{http.MethodGet, "/tasks/:profile/confirm-delete/:task", h.ConfirmDeleteTask, accessUser},
+
+		{http.MethodGet, "/tenant/:tenant/site/:site/tasks/:profile/new", h.NewTask, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:profile/new", h.NewTask, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/tasks/:id", h.EditTask, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id", h.EditTask, accessUser},
+		{http.MethodDelete, "/tenant/:tenant/site/:site/tasks/:id", h.EditTask, accessUser},
+		{http.MethodGet, "/tenant/:tenant/site/:site/tasks/:profile/confirm-delete/:task", h.ConfirmDeleteTask, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id/enable", func(c echo.Context) error { return h.EnableTask(c, true) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id/disable", func(c echo.Context) error { return h.EnableTask(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id/moveup/:order", func(c echo.Context) error { return h.MoveTask(c, true) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id/movedown/:order", func(c echo.Context) error { return h.MoveTask(c, false) }, accessUser},
+		{http.MethodPost, "/tenant/:tenant/site/:site/tasks/:id/movefrom/:from/to/:to", h.MoveTaskFromTo, accessUser},
+
+		{http.MethodPost, "/render-markdown", h.RenderMarkdown, accessUser},
+
+		{http.MethodGet, "/oidc", h.OIDCLogIn, accessPublic},
+		{http.MethodGet, "/oidc/callback", h.OIDCCallback, accessPublic},
+
+		{http.MethodPost, "/lang", h.SetLanguage, accessPublic},
+		{http.MethodPost, "/theme", h.SetTheme, accessUser},
+
+		{http.MethodGet, "/access-request", h.AccessRequest, accessUser},
+		{http.MethodPost, "/access-request", h.SendAccessRequest, accessUser},
+
+		{http.MethodGet, "/tenant-switcher", h.TenantSwitcher, accessUser},
+		{http.MethodGet, "/tenant-switcher/:id/sites", h.TenantSwitcherSites, accessUser},
+		{http.MethodPost, "/tenant-switcher/switch", h.SwitchTenant, accessUser},
+
+		{http.MethodPost, "/login/userpass", h.LoginPasswordAuth, accessPublic},
+		{http.MethodPost, "/login/changepass", h.LoginPasswordChange, accessPublic},
+		{http.MethodGet, "/login/forgot", h.LoginForgotPass, accessPublic},
+		{http.MethodPost, "/login/forgot", h.ForgotPasswordEmail, accessPublic},
+		{http.MethodGet, "/login/forgotverify", h.VerifyForgotPasswordCode, accessPublic},
+		{http.MethodPost, "/login/forgotverify", h.VerifyForgotPasswordCode, accessPublic},
+		{http.MethodPost, "/login/totpregister", h.Register2FA, accessPublic},
+		{http.MethodPost, "/login/totpconfirm", h.LoginTOTPConfirm, accessPublic},
+		{http.MethodPost, "/login/totpvalidate", h.LoginTOTPValidate, accessPublic},
+		{http.MethodPost, "/login/totpbackuprequested", h.LoginTOTPBackupRequest, accessPublic},
+		{http.MethodPost, "/login/totpbackupcheck", h.LoginTOTPBackupCheck, accessPublic},
+		{http.MethodGet, "/login/new", h.LoginNewUser, accessPublic},
+
+		{http.MethodGet, "/myaccount", h.MyAccount, accessUser},
+		{http.MethodPost, "/myaccount/info", h.UpdatePersonalInfo, accessUser},
+		{http.MethodPost, "/myaccount/password", h.MyAccountPassword, accessUser},
+		{http.MethodPost, "/myaccount/enable2fa", h.Enable2FA, accessUser},
+		{http.MethodPost, "/myaccount/disable2fa", h.Disable2FA, accessUser},
+		{http.MethodPost, "/myaccount/register2fa", h.Enabled2FA, accessUser},
+		{http.MethodGet, "/myaccount/api-tokens", h.ListAPITokens, accessUser},
+		{http.MethodPost, "/myaccount/api-tokens", h.CreateAPIToken, accessUser},
+		{http.MethodDelete, "/myaccount/api-tokens/:id", h.DeleteAPIToken, accessUser},
+	}
 }
 
 func (h *Handler) IsAuthenticated(next echo.HandlerFunc) echo.HandlerFunc {
@@ -709,6 +963,8 @@ func (h *Handler) IsAuthenticated(next echo.HandlerFunc) echo.HandlerFunc {
 			}
 		}
 
+		h.updateUserLastSeen(user)
+
 		return next(c)
 	}
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// publicRoutes are the only routes that can be requested without a session or a token, a new
+// public route must be added here on purpose
+var publicRoutes = []string{
+	"GET /health",
+	"GET /auth",
+	"GET /auth/confirm/:token",
+	"GET /branding/:image",
+	"GET /profiles/task-types",
+	"GET /profiles/task-subtypes",
+	"GET /profiles/task-definition",
+	"GET /register",
+	"POST /register",
+	"GET /oidc",
+	"GET /oidc/callback",
+	"POST /lang",
+	"POST /login/userpass",
+	"POST /login/changepass",
+	"GET /login/forgot",
+	"POST /login/forgot",
+	"GET /login/forgotverify",
+	"POST /login/forgotverify",
+	"POST /login/totpregister",
+	"POST /login/totpconfirm",
+	"POST /login/totpvalidate",
+	"POST /login/totpbackuprequested",
+	"POST /login/totpbackupcheck",
+	"GET /login/new",
+	"GET /enroll/:token",
+	// the token in the path authenticates the enrollment commands of previous releases
+	"GET /api/enroll/:token/config",
+	"GET /api/enroll/:token/install",
+	"GET " + apiV1Path + "/openapi.json",
+}
+
+func TestRoutesDeclareAccess(t *testing.T) {
+	h := &Handler{}
+
+	declared := map[string]bool{}
+	for _, r := range h.routes() {
+		key := r.Method + " " + r.Path
+		assert.False(t, declared[key], "%s should be registered once", key)
+		declared[key] = true
+
+		_, ok := h.accessMiddleware(r.Access)
+		assert.True(t, ok, "%s should declare who can request it", key)
+		if r.Access == accessPublic {
+			assert.Contains(t, publicRoutes, key, "%s should not be public", key)
+		}
+	}
+
+	secured := map[string]bool{}
+	for _, r := range h.apiRoutes() {
+		if len(r.Security) > 0 {
+			secured[r.Method+" "+apiV1Path+r.Path] = true
+		}
+	}
+
+	e := echo.New()
+	h.Register(e)
+	registered := map[string]bool{}
+	for _, r := range e.Routes() {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if strings.HasPrefix(r.Path, "/api/") {
+			assert.True(t, secured[key] || slices.Contains(publicRoutes, key), "%s should declare a security scheme", key)
+			continue
+		}
+		assert.True(t, declared[key], "%s should be registered from the route table", key)
+	}
+
+	for _, key := range publicRoutes {
+		assert.True(t, registered[key], "public route %s should exist", key)
+	}
+}
+
+func TestRoutesAccessMiddleware(t *testing.T) {
+	h := &Handler{}
+	for _, r := range h.routes() {
+		if r.Method == http.MethodGet && r.Path == "/admin/sessions/:token/delete" {
+			assert.Equal(t, accessMainTenantAdmin, r.Access, "should only let main tenant admins confirm the deletion of a session")
+		}
+	}
+
+	middleware, ok := h.accessMiddleware(accessUndeclared)
+	assert.False(t, ok)
+	assert.Empty(t, middleware)
+	assert.Panics(t, func() {
+		h.registerRoutes(echo.New(), []route{{Method: http.MethodGet, Path: "/undeclared", Handler: h.HealthCheck}})
+	}, "should not register a route without access")
+}
@@ -71,7 +71,7 @@ func (h *Handler) RustDeskStart(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rustdesk.could_not_prepare_request", err.Error()), true))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.rustdesk.start."+agentId, data, time.Duration(h.NATSTimeout)*time.Second)
+	msg, err := h.natsRequest(c.Request().Context(), "agent.rustdesk.start."+agentId, data, time.Duration(h.NATSTimeout)*time.Second)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rustdesk.could_not_send_request", err.Error()), true))
 	}
@@ -128,7 +128,7 @@ func (h *Handler) RustDeskStop(c echo.Context) error {
 		return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.RemoteAssistance(c, p, agent, confirmDelete, hasRustDeskSettings, false, commonInfo, err.Error(), netbird, offline), commonInfo))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.rustdesk.stop."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second)
+	msg, err := h.natsRequest(c.Request().Context(), "agent.rustdesk.stop."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second)
 	if err != nil {
 		return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.RemoteAssistance(c, p, agent, confirmDelete, hasRustDeskSettings, false, commonInfo, err.Error(), netbird, offline), commonInfo))
 	}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/controllers/router/middleware"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// cspViolationsShown is how many of the most reported violations are shown in the summary
+const cspViolationsShown = 100
+
+var cspDirectiveRegexp = regexp.MustCompile(`^[a-z-]+$`)
+
+// cspDirectiveError is a directive that can't be used in a custom policy
+type cspDirectiveError struct {
+	directive string
+	reserved  bool
+}
+
+func (e *cspDirectiveError) Error() string {
+	return e.directive
+}
+
+func (h *Handler) SecurityHeadersSettings(c echo.Context) error {
+	if c.Request().Method != "POST" {
+		return h.renderSecurityHeaders(c, "")
+	}
+
+	current, err := h.Model.GetSecurityHeadersSettings()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.could_not_get", err.Error()), true))
+	}
+
+	policy, err := normalizeContentSecurityPolicy(c.FormValue("content-security-policy"))
+	if err != nil {
+		key := "security_headers.invalid_directive"
+		if e, ok := err.(*cspDirectiveError); ok && e.reserved {
+			key = "security_headers.reserved_directive"
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), key, err.Error()), true))
+	}
+
+	frameAncestors, err := normalizeFrameAncestors(c.FormValue("frame-ancestors"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.invalid_origin", err.Error()), true))
+	}
+
+	settings := models.SecurityHeadersSettings{
+		ContentSecurityPolicy: policy,
+		ReportOnly:            c.FormValue("report-only") == "on",
+		FrameAncestors:        frameAncestors,
+	}
+	if err := h.Model.SaveSecurityHeadersSettings(settings); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.could_not_save", err.Error()), true))
+	}
+
+	if h.AuthLogger != nil {
+		uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+		h.AuthLogger.Printf("user %s has changed the security headers from %+v to %+v from %s", uid, current, settings, c.RealIP())
+	}
+
+	return h.renderSecurityHeaders(c, i18n.T(c.Request().Context(), "security_headers.saved"))
+}
+
+func (h *Handler) DeleteCSPViolations(c echo.Context) error {
+	if err := h.Model.DeleteCSPViolations(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.could_not_delete_violations", err.Error()), true))
+	}
+
+	return h.renderSecurityHeaders(c, i18n.T(c.Request().Context(), "security_headers.violations_deleted"))
+}
+
+func (h *Handler) renderSecurityHeaders(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := h.Model.GetSecurityHeadersSettings()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.could_not_get", err.Error()), true))
+	}
+
+	violations, err := h.Model.GetCSPViolations(cspViolationsShown)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "security_headers.could_not_get", err.Error()), true))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.SecurityHeadersIndex(" | Security Headers", admin_views.SecurityHeaders(c, settings, middleware.DefaultContentSecurityPolicy, violations, agentsExists, serversExists, commonInfo, successMessage), commonInfo))
+}
+
+// normalizeContentSecurityPolicy joins the lines of a custom policy and checks its directives. The
+// frame-ancestors and reporting directives can't be used, they're added from the other settings
+func normalizeContentSecurityPolicy(value string) (string, error) {
+	directives := []string{}
+	for _, d := range strings.Split(value, ";") {
+		d = strings.Join(strings.Fields(d), " ")
+		if d == "" {
+			continue
+		}
+
+		// A comma would start a second policy
+		if strings.ContainsFunc(d, func(r rune) bool { return r == ',' || r < 0x20 || r > 0x7e }) {
+			return "", &cspDirectiveError{directive: d}
+		}
+
+		name, _, _ := strings.Cut(d, " ")
+		name = strings.ToLower(name)
+		if !cspDirectiveRegexp.MatchString(name) {
+			return "", &cspDirectiveError{directive: name}
+		}
+		if name == "frame-ancestors" || name == "report-uri" || name == "report-to" {
+			return "", &cspDirectiveError{directive: name, reserved: true}
+		}
+
+		directives = append(directives, d)
+	}
+
+	return strings.Join(directives, "; "), nil
+}
+
+// normalizeFrameAncestors checks that the list has 'self' or HTTPS origins, separated by spaces, commas
+// or new lines, and returns them separated by spaces
+func normalizeFrameAncestors(value string) (string, error) {
+	origins := []string{}
+	for _, origin := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }) {
+		if origin == "'self'" {
+			origins = append(origins, origin)
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || strings.ContainsAny(u.Host, "'\";") {
+			return "", errors.New(origin)
+		}
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+
+	return strings.Join(origins, " "), nil
+}
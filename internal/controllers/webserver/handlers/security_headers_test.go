@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeContentSecurityPolicy(t *testing.T) {
+	policy, err := normalizeContentSecurityPolicy("default-src 'self';\n  img-src   'self' data: ;\r\n\n;")
+	assert.NoError(t, err)
+	assert.Equal(t, "default-src 'self'; img-src 'self' data:", policy)
+
+	policy, err = normalizeContentSecurityPolicy("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, policy, "should use the default policy")
+
+	for value, reserved := range map[string]bool{
+		"default-src 'self'; frame-ancestors *": true,
+		"Report-URI https://example.com":        true,
+		"report-to csp":                         true,
+		"default_src 'self'":                    false,
+		"default-src 'self', script-src *":      false,
+		"default-src 'self'\x00":                false,
+	} {
+		_, err := normalizeContentSecurityPolicy(value)
+		directiveErr, ok := err.(*cspDirectiveError)
+		if assert.True(t, ok, value) {
+			assert.Equal(t, reserved, directiveErr.reserved, value)
+		}
+	}
+}
+
+func TestNormalizeFrameAncestors(t *testing.T) {
+	origins, err := normalizeFrameAncestors("'self'\r\nhttps://intranet.example.com/, https://portal.example.com:8443")
+	assert.NoError(t, err)
+	assert.Equal(t, "'self' https://intranet.example.com https://portal.example.com:8443", origins)
+
+	origins, err = normalizeFrameAncestors("")
+	assert.NoError(t, err)
+	assert.Empty(t, origins)
+
+	for _, value := range []string{"*", "http://intranet.example.com", "https://intranet.example.com/app", "intranet.example.com", "https://user@intranet.example.com", "'none'"} {
+		_, err := normalizeFrameAncestors(value)
+		assert.EqualError(t, err, value)
+	}
+}
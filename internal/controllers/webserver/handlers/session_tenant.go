@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+)
+
+// The session keys of the tenant and the site chosen in the tenant switcher, used by the pages whose URL
+// doesn't include a tenant
+const (
+	sessionTenantKey = "tenant"
+	sessionSiteKey   = "site"
+)
+
+// tenantIDContextKey is where TenantAccessMiddleware stores the tenant of the request once it's checked
+const tenantIDContextKey = "tenant_id"
+
+// setSessionTenant stores the tenant and the site, zero if the tenant is used without a site, that the
+// pages without a tenant in the URL use
+func (h *Handler) setSessionTenant(c echo.Context, tenantID, siteID int) {
+	ctx := c.Request().Context()
+	h.SessionManager.Manager.Put(ctx, sessionTenantKey, tenantID)
+	if siteID == 0 {
+		h.SessionManager.Manager.Remove(ctx, sessionSiteKey)
+		return
+	}
+	h.SessionManager.Manager.Put(ctx, sessionSiteKey, siteID)
+}
+
+// sessionTenant returns the tenant and the site of the session, after checking that the user can still
+// access them. The user's default tenant is used, and stored in the session, if no tenant was chosen yet
+// or the user has lost access to it. The site is dropped if it no longer belongs to the tenant
+func (h *Handler) sessionTenant(c echo.Context, username string) (int, int, error) {
+	ctx := c.Request().Context()
+	tenantID := h.SessionManager.Manager.GetInt(ctx, sessionTenantKey)
+	siteID := h.SessionManager.Manager.GetInt(ctx, sessionSiteKey)
+
+	if tenantID != 0 {
+		hasAccess, err := h.Model.UserHasAccessToTenant(username, tenantID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if hasAccess {
+			if siteID != 0 {
+				if _, err := h.Model.GetSite(siteID, tenantID); err != nil {
+					if !openuem_ent.IsNotFound(err) {
+						return 0, 0, err
+					}
+					siteID = 0
+					h.setSessionTenant(c, tenantID, siteID)
+				}
+			}
+			return tenantID, siteID, nil
+		}
+	}
+
+	tenant, err := h.Model.GetUserDefaultTenant(username)
+	if err != nil {
+		return 0, 0, err
+	}
+	h.setSessionTenant(c, tenant.ID, 0)
+	return tenant.ID, 0, nil
+}
+
+// canonicalTenantURL returns the URL of the page without the tenant and the site, so a bookmark of a
+// tenant the user no longer has access to opens the same page in the tenant of the session. It's the
+// dashboard if the page only exists in a tenant
+func canonicalTenantURL(c echo.Context) string {
+	routePath := stripTenantPrefix(c.Path())
+	if routePath == "" {
+		return "/"
+	}
+
+	exists := false
+	for _, r := range c.Echo().Routes() {
+		if r.Method == http.MethodGet && r.Path == routePath {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		return "/"
+	}
+
+	u := stripTenantPrefix(c.Request().URL.Path)
+	if u == "" {
+		return "/"
+	}
+	if c.Request().URL.RawQuery != "" {
+		u += "?" + c.Request().URL.RawQuery
+	}
+	return u
+}
+
+// stripTenantPrefix removes the /tenant/{id} and /site/{id} segments at the start of the path
+func stripTenantPrefix(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "tenant" {
+		return path
+	}
+	segments = segments[2:]
+	if len(segments) >= 2 && segments[0] == "site" {
+		segments = segments[2:]
+	}
+	if len(segments) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(segments, "/")
+}
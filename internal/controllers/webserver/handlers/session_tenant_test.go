@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// tenantAccessContext returns a request of the user to the URL of the route, registered with the routes
+// without a tenant that the pages can be redirected to
+func (at *authorizationTest) tenantAccessContext(t *testing.T, uid, target, path string, params map[string]string) echo.Context {
+	e := echo.New()
+	e.GET("/computers", at.h.HealthCheck)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	ctx, err := at.h.SessionManager.Manager.Load(req.Context(), "")
+	assert.NoError(t, err)
+	at.h.SessionManager.Manager.Put(ctx, "uid", uid)
+
+	c := e.NewContext(req.WithContext(ctx), httptest.NewRecorder())
+	c.SetPath(path)
+	names, values := []string{}, []string{}
+	for name, value := range params {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c
+}
+
+func TestSessionTenant(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	c := at.context(t, "operator", http.MethodGet, "/computers", nil)
+	tenantID, siteID, err := at.h.sessionTenant(c, "operator")
+	assert.NoError(t, err)
+	assert.Equal(t, at.secondTenantID, tenantID, "should use the default tenant of the user")
+	assert.Equal(t, 0, siteID)
+	assert.Equal(t, at.secondTenantID, at.h.SessionManager.Manager.GetInt(c.Request().Context(), sessionTenantKey), "should store the default tenant in the session")
+
+	mainSite, err := at.h.Model.Client.Site.Create().SetDescription("Main office").SetTenantID(at.mainTenantID).Save(context.Background())
+	assert.NoError(t, err)
+	at.h.setSessionTenant(c, at.secondTenantID, mainSite.ID)
+	tenantID, siteID, err = at.h.sessionTenant(c, "operator")
+	assert.NoError(t, err)
+	assert.Equal(t, at.secondTenantID, tenantID)
+	assert.Equal(t, 0, siteID, "should drop a site of another tenant")
+
+	at.h.setSessionTenant(c, at.mainTenantID, 0)
+	tenantID, _, err = at.h.sessionTenant(c, "operator")
+	assert.NoError(t, err)
+	assert.Equal(t, at.secondTenantID, tenantID, "should downgrade to the default tenant when the user has lost access")
+}
+
+func TestTenantAccessMiddleware(t *testing.T) {
+	at := newAuthorizationTest(t)
+	next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	c := at.tenantAccessContext(t, "operator", "/computers", "/computers", nil)
+	assert.NoError(t, at.h.TenantAccessMiddleware(next)(c))
+	assert.Equal(t, at.secondTenantID, c.Get(tenantIDContextKey), "should use the tenant of the session when the URL has no tenant")
+
+	main := map[string]string{"tenant": strconv.Itoa(at.mainTenantID)}
+	c = at.tenantAccessContext(t, "operator", "/tenant/"+main["tenant"]+"/computers?page=2", "/tenant/:tenant/computers", main)
+	assert.NoError(t, at.h.TenantAccessMiddleware(next)(c))
+	assert.Equal(t, http.StatusFound, c.Response().Status)
+	assert.Equal(t, "/computers?page=2", c.Response().Header().Get("Location"), "should open the bookmark in the tenant of the session")
+
+	c = at.tenantAccessContext(t, "operator", "/tenant/"+main["tenant"]+"/admin/enrollment", "/tenant/:tenant/admin/enrollment", main)
+	assert.NoError(t, at.h.TenantAccessMiddleware(next)(c))
+	assert.Equal(t, "/", c.Response().Header().Get("Location"), "should open the dashboard if the page only exists in a tenant")
+
+	c = at.tenantAccessContext(t, "operator", "/tenant/"+main["tenant"]+"/computers", "/tenant/:tenant/computers", main)
+	c.Request().Header.Set("HX-Request", "true")
+	assertHTTPError(t, http.StatusForbidden, at.h.TenantAccessMiddleware(next)(c), "should not redirect HTMX requests")
+
+	c = at.tenantAccessContext(t, "admin", "/admin/tenants/"+strconv.Itoa(at.secondTenantID), "/admin/tenants/:tenant", map[string]string{"tenant": strconv.Itoa(at.secondTenantID)})
+	assert.NoError(t, at.h.TenantAccessMiddleware(next)(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status, "should not check the tenant managed by global routes")
+}
+
+func TestStripTenantPrefix(t *testing.T) {
+	for path, want := range map[string]string{
+		"/tenant/1/computers":          "/computers",
+		"/tenant/1/site/2/computers/x": "/computers/x",
+		"/tenant/:tenant/site/:site":   "",
+		"/tenant/1":                    "",
+		"/admin/tenants/1":             "/admin/tenants/1",
+	} {
+		assert.Equal(t, want, stripTenantPrefix(path), path)
+	}
+}
+
+func TestIsIPAllowedInTenant(t *testing.T) {
+	at := newAllowlistTest(t)
+	second := map[string]string{"tenant": strconv.Itoa(at.secondTenantID)}
+	path := "/tenant/:tenant/computers"
+
+	allowed, err := at.h.isIPAllowedInTenant(at.allowlistContext(t, "operator", "203.0.113.5", path, second), at.secondTenantID)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "a tenant without allowed networks should accept any IP")
+
+	assert.NoError(t, at.h.Model.UpdateTenantAllowedIPs(at.secondTenantID, []string{"10.0.0.0/8"}))
+
+	tests := []struct {
+		name   string
+		exempt bool
+		uid    string
+		ip     string
+		want   bool
+	}{
+		{name: "IP in the allowed networks", uid: "operator", ip: "10.1.2.3", want: true},
+		{name: "IP outside the allowed networks", uid: "operator", ip: "203.0.113.5", want: false},
+		{name: "invalid IP", uid: "operator", ip: "not-an-ip", want: false},
+		{name: "main tenant admins are exempt", exempt: true, uid: "admin", ip: "203.0.113.5", want: true},
+		{name: "exemption is only for main tenant admins", exempt: true, uid: "operator", ip: "203.0.113.5", want: false},
+		{name: "exemption disabled", uid: "admin", ip: "203.0.113.5", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at.setAllowlists(t, "", "", tt.exempt)
+			allowed, err := at.h.isIPAllowedInTenant(at.allowlistContext(t, tt.uid, tt.ip, path, second), at.secondTenantID)
+			assert.NoError(t, err, "should not fail for any IP")
+			assert.Equal(t, tt.want, allowed)
+		})
+	}
+
+	_, err = at.h.isIPAllowedInTenant(at.allowlistContext(t, "operator", "10.1.2.3", path, second), 9999)
+	assert.Error(t, err, "should fail for non existing tenant")
+}
+
+func TestWouldKeepTenantAccess(t *testing.T) {
+	at := newAllowlistTest(t)
+
+	tests := []struct {
+		name       string
+		exempt     bool
+		uid        string
+		ip         string
+		allowedIPs string
+		want       bool
+	}{
+		{name: "no allowed networks", uid: "operator", ip: "203.0.113.5", want: true},
+		{name: "client in the allowed networks", uid: "operator", ip: "10.1.2.3", allowedIPs: "10.0.0.0/8", want: true},
+		{name: "allowed networks lock out the client", uid: "operator", ip: "203.0.113.5", allowedIPs: "10.0.0.0/8", want: false},
+		{name: "invalid IP", uid: "operator", ip: "not-an-ip", allowedIPs: "10.0.0.0/8", want: false},
+		{name: "users without access to the tenant can't be locked out", uid: "admin", ip: "203.0.113.5", allowedIPs: "10.0.0.0/8", want: true},
+		{name: "exemption doesn't keep other users", exempt: true, uid: "operator", ip: "203.0.113.5", allowedIPs: "10.0.0.0/8", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at.setAllowlists(t, "", "", tt.exempt)
+			c := at.allowlistContext(t, tt.uid, tt.ip, "/admin/tenants/:tenant", nil)
+			assert.Equal(t, tt.want, at.h.wouldKeepTenantAccess(c, at.secondTenantID, tt.allowedIPs), "the lockout warning should be shown when the client would lose access")
+		})
+	}
+}
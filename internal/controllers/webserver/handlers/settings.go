@@ -14,6 +14,7 @@ import (
 	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
@@ -73,6 +74,12 @@ func (h *Handler) GeneralSettings(c echo.Context) error {
 			}
 		}
 
+		if settings.LogCollectionTimeout != 0 && commonInfo.TenantID == "-1" {
+			if err := h.Model.UpdateAgentLogCollectionTimeout(settings.ID, settings.LogCollectionTimeout); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.log_collection_timeout_could_not_be_saved"), true))
+			}
+		}
+
 		if settings.SessionLifetime != 0 {
 			if err := h.Model.UpdateSessionLifetime(settings.ID, settings.SessionLifetime); err != nil {
 				return RenderError(c, partials.ErrorMessage(err.Error(), true))
@@ -90,6 +97,12 @@ func (h *Handler) GeneralSettings(c echo.Context) error {
 			return h.ChangeAgentFrequency(c, settings)
 		}
 
+		if c.Request().Form.Has("agent-late-threshold") && c.Request().Form.Has("agent-offline-threshold") {
+			if err := h.Model.UpdateAgentThresholds(settings.ID, settings.AgentLateThreshold, settings.AgentOfflineThreshold); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_thresholds_could_not_be_saved"), true))
+			}
+		}
+
 		if c.FormValue("request-pin") != "" {
 			if err := h.Model.UpdateRequestVNCPIN(settings.ID, settings.RequestVNCPIN); err != nil {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.request_pin_could_not_be_saved"), true))
@@ -150,6 +163,26 @@ func (h *Handler) GeneralSettings(c echo.Context) error {
 			}
 		}
 
+		// An empty timezone or date format is a valid value, the form is checked to know if they were sent
+		if c.Request().Form.Has("timezone") {
+			if err := h.Model.UpdateTimezoneSetting(settings.ID, settings.Timezone); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.timezone_could_not_be_saved"), true))
+			}
+		}
+
+		if c.Request().Form.Has("date-format") {
+			if err := h.Model.UpdateDateFormatSetting(settings.ID, settings.DateFormat); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.date_format_could_not_be_saved"), true))
+			}
+		}
+
+		// The site assignment rules only apply to the sites of a tenant
+		if c.Request().Form.Has("site-assignment") && commonInfo.TenantID != "-1" {
+			if err := h.Model.UpdateSiteAssignmentMode(settings.ID, settings.SiteAssignment); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.site_assignment_could_not_be_saved"), true))
+			}
+		}
+
 		successMessage = i18n.T(c.Request().Context(), "settings.saved")
 	}
 
@@ -203,6 +236,12 @@ func validateGeneralSettings(c echo.Context) (*models.GeneralSettings, error) {
 	autoAdmitAgents := c.FormValue("auto-admit-agents")
 	netbird := c.FormValue("netbird")
 	itemsPerPage := c.FormValue("items-per-page")
+	timezone := c.FormValue("timezone")
+	dateFormat := c.FormValue("date-format")
+	siteAssignment := c.FormValue("site-assignment")
+	logCollectionTimeout := c.FormValue("log-collection-timeout")
+	agentLateThreshold := c.FormValue("agent-late-threshold")
+	agentOfflineThreshold := c.FormValue("agent-offline-threshold")
 
 	if settingsId == "" {
 		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.id_cannot_be_empty"))
@@ -271,6 +310,36 @@ func validateGeneralSettings(c echo.Context) (*models.GeneralSettings, error) {
 		}
 	}
 
+	if logCollectionTimeout != "" {
+		settings.LogCollectionTimeout, err = strconv.Atoi(logCollectionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.log_collection_timeout_invalid"))
+		}
+
+		if settings.LogCollectionTimeout <= 0 {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.log_collection_timeout_invalid"))
+		}
+	}
+
+	// Zero inherits the threshold from the global settings or the default
+	if agentLateThreshold != "" {
+		settings.AgentLateThreshold, err = strconv.Atoi(agentLateThreshold)
+		if err != nil || settings.AgentLateThreshold < 0 {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.agent_thresholds_invalid"))
+		}
+	}
+
+	if agentOfflineThreshold != "" {
+		settings.AgentOfflineThreshold, err = strconv.Atoi(agentOfflineThreshold)
+		if err != nil || settings.AgentOfflineThreshold < 0 {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.agent_thresholds_invalid"))
+		}
+	}
+
+	if settings.AgentLateThreshold > 0 && settings.AgentOfflineThreshold > 0 && settings.AgentLateThreshold >= settings.AgentOfflineThreshold {
+		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.agent_late_over_offline"))
+	}
+
 	if sessionLifetime != "" {
 		settings.SessionLifetime, err = strconv.Atoi(sessionLifetime)
 		if err != nil {
@@ -389,6 +458,21 @@ func validateGeneralSettings(c echo.Context) (*models.GeneralSettings, error) {
 		}
 	}
 
+	if !helpers.IsValidTimezone(timezone) {
+		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "datetime.timezone_invalid"))
+	}
+	settings.Timezone = timezone
+
+	if !helpers.IsValidDateFormat(dateFormat) {
+		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "datetime.date_format_invalid"))
+	}
+	settings.DateFormat = dateFormat
+
+	if !models.IsValidSiteAssignmentMode(siteAssignment) {
+		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.site_assignment_invalid"))
+	}
+	settings.SiteAssignment = siteAssignment
+
 	return &settings, nil
 }
 
@@ -436,7 +520,7 @@ func (h *Handler) ChangeAgentFrequency(c echo.Context, settings *models.GeneralS
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_error"), true))
 	}
 
@@ -452,7 +536,7 @@ func (h *Handler) ChangeAgentFrequency(c echo.Context, settings *models.GeneralS
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_could_not_be_saved"), true))
@@ -505,7 +589,7 @@ func (h *Handler) ChangeWingetFrequency(c echo.Context, settings *models.General
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_error"), true))
 	}
 
@@ -521,7 +605,7 @@ func (h *Handler) ChangeWingetFrequency(c echo.Context, settings *models.General
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_could_not_be_saved"), true))
@@ -574,7 +658,7 @@ func (h *Handler) ChangeSFTPSetting(c echo.Context, settings *models.GeneralSett
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_error"), true))
 	}
 
@@ -590,7 +674,7 @@ func (h *Handler) ChangeSFTPSetting(c echo.Context, settings *models.GeneralSett
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_could_not_be_saved"), true))
@@ -648,7 +732,7 @@ func (h *Handler) ChangeRemoteAssistanceSetting(c echo.Context, settings *models
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_error"), true))
 	}
 
@@ -664,7 +748,7 @@ func (h *Handler) ChangeRemoteAssistanceSetting(c echo.Context, settings *models
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.natsPublish(c.Request().Context(), "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_could_not_be_saved"), true))
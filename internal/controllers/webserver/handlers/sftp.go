@@ -840,7 +840,20 @@ func sortFiles(files []fs.FileInfo) {
 
 }
 
+// errSFTPConnectionFailed is returned when the SFTP server of the agent can't be reached
+var errSFTPConnectionFailed = errors.New("could not connect to the SFTP server of the agent")
+
 func connectWithSFTP(c echo.Context, IPAddress string, key *rsa.PrivateKey, sftpPort, os string, nb *ent.Netbird) (*sftp.Client, *ssh.Client, error) {
+	client, conn, err := dialSFTP(IPAddress, key, sftpPort, os, nb)
+	if errors.Is(err, errSFTPConnectionFailed) {
+		return nil, nil, errors.New(i18n.T(c.Request().Context(), "agents.sftp_connection_failed"))
+	}
+	return client, conn, err
+}
+
+// dialSFTP connects to the SFTP server of the agent, using the NetBird address if the agent can't be reached
+// with its IP address. It doesn't need a request so it can be used by background jobs
+func dialSFTP(IPAddress string, key *rsa.PrivateKey, sftpPort, os string, nb *ent.Netbird) (*sftp.Client, *ssh.Client, error) {
 	signer, err := ssh.NewSignerFromKey(key)
 	if err != nil {
 		return nil, nil, err
@@ -871,13 +884,13 @@ func connectWithSFTP(c echo.Context, IPAddress string, key *rsa.PrivateKey, sftp
 				sftpAdress = net.JoinHostPort(nbIPComponents[0], sftpPort)
 				_, err = net.DialTimeout("tcp", sftpAdress, 2*time.Second)
 				if err != nil {
-					return nil, nil, errors.New(i18n.T(c.Request().Context(), "agents.sftp_connection_failed"))
+					return nil, nil, errSFTPConnectionFailed
 				}
 			} else {
-				return nil, nil, errors.New(i18n.T(c.Request().Context(), "agents.sftp_connection_failed"))
+				return nil, nil, errSFTPConnectionFailed
 			}
 		} else {
-			return nil, nil, errors.New(i18n.T(c.Request().Context(), "agents.sftp_connection_failed"))
+			return nil, nil, errSFTPConnectionFailed
 		}
 	}
 
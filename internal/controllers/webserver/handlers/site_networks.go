@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/open-uem/openuem-console/internal/views/reports_views"
+)
+
+// misplacedAgentsReport is the misplaced agents report in JSON
+type misplacedAgentsReport struct {
+	Items     []models.MisplacedAgent      `json:"items"`
+	Conflicts []models.SiteNetworkConflict `json:"conflicts"`
+	Mode      string                       `json:"mode"`
+}
+
+// MisplacedAgentsReport shows the agents whose IP address is in the networks of another site and the networks
+// that overlap. It returns JSON if the client accepts it, e.g. curl -H "Accept: application/json"
+func (h *Handler) MisplacedAgentsReport(c echo.Context) error {
+	return h.misplacedAgentsReport(c, "")
+}
+
+// MoveMisplacedAgent moves an agent to the site chosen by an operator in the misplaced agents report
+func (h *Handler) MoveMisplacedAgent(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), true))
+	}
+
+	mode, err := h.Model.GetSiteAssignmentMode(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_misplaced_agents"), true))
+	}
+	if mode == models.SiteAssignmentDisabled {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.site_assignment_disabled"), true))
+	}
+
+	siteID, err := strconv.Atoi(c.FormValue("site"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.could_not_convert_site_to_int", c.FormValue("site")), true))
+	}
+
+	agentID := c.Param("uuid")
+	if err := h.Model.MigrateAgentToSite(agentID, siteID, tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	log.Printf("[INFO]: user %s has moved agent %s to site %d of tenant %d", uid, agentID, siteID, tenantID)
+
+	return h.misplacedAgentsReport(c, i18n.T(c.Request().Context(), "reports.agent_moved"))
+}
+
+func (h *Handler) misplacedAgentsReport(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		if acceptsJSON(c) {
+			return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "sites.tenant_cannot_be_empty"))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.tenant_cannot_be_empty"), true))
+	}
+
+	agents, err := h.Model.GetMisplacedAgents(commonInfo)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the misplaced agents, reason: %v", err)
+		if acceptsJSON(c) {
+			return echo.NewHTTPError(http.StatusInternalServerError, i18n.T(c.Request().Context(), "reports.could_not_get_misplaced_agents"))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_misplaced_agents"), true))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_misplaced_agents"), true))
+	}
+	conflicts := models.SiteNetworkConflicts(sites)
+
+	mode, err := h.Model.GetSiteAssignmentMode(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_misplaced_agents"), true))
+	}
+
+	if acceptsJSON(c) {
+		return c.JSON(http.StatusOK, misplacedAgentsReport{Items: agents, Conflicts: conflicts, Mode: mode})
+	}
+
+	return RenderView(c, reports_views.ReportsIndex("| Misplaced agents", reports_views.MisplacedAgents(c, agents, conflicts, mode, successMessage, commonInfo), commonInfo))
+}
+
+// admissionSite returns the site an agent being admitted has to be moved to, if the tenant assigns the
+// agents to the site of their network at admission, or nil if it stays in its site. Agents in the
+// networks of several sites stay in their site and are listed in the misplaced agents report
+func (h *Handler) admissionSite(a *ent.Agent, commonInfo *partials.CommonInfo) *ent.Site {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil || tenantID == -1 {
+		return nil
+	}
+
+	mode, err := h.Model.GetSiteAssignmentMode(tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the site assignment mode of tenant %d, reason: %v", tenantID, err)
+		return nil
+	}
+	if mode != models.SiteAssignmentAdmission {
+		return nil
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the sites of tenant %d, reason: %v", tenantID, err)
+		return nil
+	}
+
+	matches := models.MatchSiteNetworks(a.IP, sites)
+	if len(matches) > 1 {
+		log.Printf("[WARN]: agent %s with IP %s is in the networks of %d sites, an operator has to choose its site", a.ID, a.IP, len(matches))
+		return nil
+	}
+	if len(matches) == 0 || (len(a.Edges.Site) == 1 && a.Edges.Site[0].ID == matches[0].SiteID) {
+		return nil
+	}
+
+	for _, s := range sites {
+		if s.ID == matches[0].SiteID {
+			return s
+		}
+	}
+	return nil
+}
+
+// moveAdmittedAgent moves an admitted agent to the site of its pre-staged device or of its network, the
+// admission isn't undone if it fails
+func (h *Handler) moveAdmittedAgent(a *ent.Agent, s *ent.Site, commonInfo *partials.CommonInfo) {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return
+	}
+
+	if err := h.Model.MigrateAgentToSite(a.ID, s.ID, tenantID); err != nil {
+		log.Printf("[ERROR]: could not move agent %s to site %d, reason: %v", a.ID, s.ID, err)
+		return
+	}
+	log.Printf("[INFO]: agent %s with IP %s has been assigned to site %d at admission", a.ID, a.IP, s.ID)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -189,13 +190,20 @@ func (h *Handler) AddSite(c echo.Context) error {
 
 	domain := c.FormValue("domain")
 	catalogRing := c.FormValue("catalog-ring")
+	logLevel := c.FormValue("log-level")
 
-	err = h.Model.AddSite(tenantID, name, isDefault, domain, catalogRing)
+	networks, err := models.ParseSiteNetworks(c.FormValue("networks"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.network_invalid", err.Error()), true))
+	}
+
+	err = h.Model.AddSite(tenantID, name, isDefault, domain, catalogRing, logLevel, networks)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.new_error"), true))
 	}
 
 	successMessage = i18n.T(c.Request().Context(), "sites.new_success")
+	errMessage = h.siteNetworkConflictsMessage(c, tenantID)
 	return h.ListSites(c, successMessage, errMessage, false)
 }
 
@@ -255,12 +263,18 @@ func (h *Handler) EditSite(c echo.Context) error {
 		}
 
 		catalogRing := c.FormValue("catalog-ring")
+		logLevel := c.FormValue("log-level")
 
-		if err := h.Model.UpdateSite(tenantID, s.ID, name, domain, isDefault, catalogRing); err != nil {
+		networks, err := models.ParseSiteNetworks(c.FormValue("networks"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.network_invalid", err.Error()), true))
+		}
+
+		if err := h.Model.UpdateSite(tenantID, s.ID, name, domain, isDefault, catalogRing, logLevel, networks); err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), false))
 		}
 
-		return h.ListSites(c, i18n.T(c.Request().Context(), "sites.edit_success"), "", false)
+		return h.ListSites(c, i18n.T(c.Request().Context(), "sites.edit_success"), h.siteNetworkConflictsMessage(c, tenantID), false)
 	}
 
 	defaultCountry, err := h.Model.GetDefaultCountry()
@@ -339,7 +353,7 @@ func (h *Handler) DeleteSite(c echo.Context) error {
 	defer cancel()
 
 	for _, a := range agents {
-		if _, err := h.JetStream.Publish(ctx, "agent.uninstall."+a.ID, nil); err != nil {
+		if _, err := h.jetStreamPublish(ctx, "agent.uninstall."+a.ID, nil); err != nil {
 			return h.ListSites(c, "", i18n.T(c.Request().Context(), "agents.could_not_send_request_to_uninstall"), false)
 		}
 	}
@@ -419,7 +433,7 @@ func (h *Handler) ImportSites(c echo.Context) error {
 			continue
 		}
 
-		err = h.Model.AddSite(tenantID, record[0], false, record[1], "")
+		err = h.Model.AddSite(tenantID, record[0], false, record[1], "", "", nil)
 		if err != nil {
 			errors = append(errors, err.Error())
 			continue
@@ -432,3 +446,24 @@ func (h *Handler) ImportSites(c echo.Context) error {
 
 	return h.ListSites(c, i18n.T(c.Request().Context(), "sites.import_success"), "", false)
 }
+
+// siteNetworkConflictsMessage returns the networks that overlap the networks of other sites, as the
+// agents in them can't be assigned to a site automatically, or an empty message if there are none
+func (h *Handler) siteNetworkConflictsMessage(c echo.Context, tenantID int) string {
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the sites to check their networks, reason: %v", err)
+		return ""
+	}
+
+	conflicts := models.SiteNetworkConflicts(sites)
+	if len(conflicts) == 0 {
+		return ""
+	}
+
+	overlaps := []string{}
+	for _, conflict := range conflicts {
+		overlaps = append(overlaps, fmt.Sprintf("%s (%s) - %s (%s)", conflict.Network, conflict.SiteName, conflict.OtherNetwork, conflict.OtherSiteName))
+	}
+	return i18n.T(c.Request().Context(), "sites.networks_conflict", strings.Join(overlaps, ", "))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/ent"
 	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -24,11 +25,23 @@ func (h *Handler) SMTPSettings(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
-
-		settings, err := validateSMTPSettings(c)
+		settings, err := validateSMTPSettings(c, commonInfo.TenantID == "-1")
 		if err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), false))
 		}
+
+		// A tenant sends its emails with its own SMTP server instead of the global settings
+		if commonInfo.TenantID != "-1" {
+			tenantID, err := strconv.Atoi(commonInfo.TenantID)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			}
+			if err := h.Model.SaveTenantSMTPConfig(tenantID, settings); err != nil {
+				return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			}
+			return h.renderSMTPSettings(c, commonInfo, i18n.T(c.Request().Context(), "smtp.saved"))
+		}
+
 		if err := h.Model.UpdateSMTPSettings(settings); err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), false))
 		}
@@ -38,18 +51,66 @@ func (h *Handler) SMTPSettings(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 		}
 
-		if err := h.NATSConnection.Publish("notification.reload_settings", nil); err != nil {
+		if err := h.natsPublish(c.Request().Context(), "notification.reload_settings", nil); err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), false))
 		}
 
 		return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "smtp.saved")))
 	}
 
-	settings, err := h.Model.GetSMTPSettings(commonInfo.TenantID)
+	return h.renderSMTPSettings(c, commonInfo, "")
+}
+
+// DeleteTenantSMTPSettings removes the SMTP server of the tenant, its emails are sent with the global settings again
+func (h *Handler) DeleteTenantSMTPSettings(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
+	if err := h.Model.DeleteTenantSMTPConfig(tenantID); err != nil {
+		return RenderModelError(c, err)
+	}
+
+	return h.renderSMTPSettings(c, commonInfo, i18n.T(c.Request().Context(), "smtp.global_restored"))
+}
+
+func (h *Handler) renderSMTPSettings(c echo.Context, commonInfo *partials.CommonInfo, successMessage string) error {
+	settings := models.SMTPSettings{}
+	passwordStored := false
+	inherited := false
+
+	if commonInfo.TenantID == "-1" {
+		s, err := h.Model.GetSMTPSettings("-1")
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		settings = models.SMTPSettings{ID: s.ID, Server: s.SMTPServer, Port: s.SMTPPort, User: s.SMTPUser, Auth: s.SMTPAuth, MailFrom: s.MessageFrom}
+		passwordStored = s.SMTPPassword != ""
+	} else {
+		tenantID, err := strconv.Atoi(commonInfo.TenantID)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		config, err := h.Model.GetTenantSMTPConfig(tenantID)
+		switch {
+		case ent.IsNotFound(err):
+			settings = models.SMTPSettings{Port: 587, Auth: "LOGIN"}
+			inherited = true
+		case err != nil:
+			return RenderModelError(c, err)
+		default:
+			settings = models.SMTPSettings{ID: config.ID, Server: config.Host, Port: config.Port, User: config.Username, Auth: config.Auth, MailFrom: config.FromAddress, FromName: config.FromName}
+			passwordStored = config.Password != ""
+		}
+	}
+
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
@@ -60,24 +121,44 @@ func (h *Handler) SMTPSettings(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
-	return RenderView(c, admin_views.SMTPSettingsIndex(" | SMTP Settings", admin_views.SMTPSettings(c, settings, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
+	return RenderView(c, admin_views.SMTPSettingsIndex(" | SMTP Settings", admin_views.SMTPSettings(c, settings, passwordStored, inherited, successMessage, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
 }
 
 func (h *Handler) TestSMTPSettings(c echo.Context) error {
 	var err error
 
-	settings, err := validateSMTPSettings(c)
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := validateSMTPSettings(c, commonInfo.TenantID == "-1")
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
+	// The stored password is not shown in the form, it's used if a new one is not entered
+	if settings.Password == "" && settings.User != "" {
+		if commonInfo.TenantID == "-1" {
+			settings.Password, err = h.Model.GetSMTPPassword(settings.ID)
+		} else if tenantID, atoiErr := strconv.Atoi(commonInfo.TenantID); atoiErr != nil {
+			err = atoiErr
+		} else if settings.Password, err = h.Model.GetTenantSMTPPassword(tenantID); ent.IsNotFound(err) {
+			err = nil
+		}
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+	}
+
 	if err := sendEmailTest(settings, settings.MailFrom); err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 	return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "smtp.test_success", settings.MailFrom)))
 }
 
-func validateSMTPSettings(c echo.Context) (*models.SMTPSettings, error) {
+// validateSMTPSettings reads the SMTP settings from the form, the ID of the settings is only sent for the global settings
+func validateSMTPSettings(c echo.Context, global bool) (*models.SMTPSettings, error) {
 	var err error
 
 	validate := validator.New()
@@ -90,14 +171,17 @@ func validateSMTPSettings(c echo.Context) (*models.SMTPSettings, error) {
 	settings.Password = c.FormValue("password")
 	settings.Auth = c.FormValue("auth")
 	settings.MailFrom = c.FormValue("mail-from")
+	settings.FromName = strings.TrimSpace(c.FormValue("from-name"))
 
-	if settingsId == "" {
-		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "smtp.id_cannot_be_empty"))
-	}
+	if global {
+		if settingsId == "" {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "smtp.id_cannot_be_empty"))
+		}
 
-	settings.ID, err = strconv.Atoi(settingsId)
-	if err != nil {
-		return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "smtp.id_invalid"))
+		settings.ID, err = strconv.Atoi(settingsId)
+		if err != nil {
+			return nil, fmt.Errorf("%s", i18n.T(c.Request().Context(), "smtp.id_invalid"))
+		}
 	}
 
 	if settings.Server == "" {
@@ -137,20 +221,18 @@ func validateSMTPSettings(c echo.Context) (*models.SMTPSettings, error) {
 }
 
 func sendEmailTest(settings *models.SMTPSettings, to string) error {
-	var err error
-	var c *mail.Client
-	if settings.Auth == "NOAUTH" || (settings.User == "" && settings.Password == "") {
-		c, err = mail.NewClient(settings.Server, mail.WithPort(settings.Port))
-	} else {
-		c, err = mail.NewClient(settings.Server, mail.WithPort(settings.Port), mail.WithSMTPAuth(mail.SMTPAuthType(settings.Auth)),
-			mail.WithUsername(settings.User), mail.WithPassword(settings.Password))
-	}
+	c, err := models.NewMailClient(settings)
 	if err != nil {
 		return err
 	}
 
 	m := mail.NewMsg()
-	if err := m.From(settings.MailFrom); err != nil {
+	if settings.FromName != "" {
+		err = m.FromFormat(settings.FromName, settings.MailFrom)
+	} else {
+		err = m.From(settings.MailFrom)
+	}
+	if err != nil {
 		return err
 	}
 	if err := m.To(to); err != nil {
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"strconv"
 
+	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -48,6 +50,15 @@ func (h *Handler) TagManager(c echo.Context) error {
 				if err := h.Model.UpdateTag(id, tag, description, color, catalogRing, commonInfo); err != nil {
 					return RenderError(c, partials.ErrorMessage(err.Error(), false))
 				}
+				if c.Request().Form.Has("late-threshold") && c.Request().Form.Has("offline-threshold") {
+					late, offline, err := tagThresholds(c)
+					if err != nil {
+						return RenderError(c, partials.ErrorMessage(err.Error(), false))
+					}
+					if err := h.Model.UpdateTagThresholds(id, late, offline, commonInfo); err != nil {
+						return RenderError(c, partials.ErrorMessage(err.Error(), false))
+					}
+				}
 			}
 
 		}
@@ -91,3 +102,23 @@ func (h *Handler) TagManager(c echo.Context) error {
 
 	return RenderView(c, admin_views.TagsIndex(" | Tags", admin_views.Tags(c, p, tags, agentsExists, serversExists, itemsPerPage, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
 }
+
+// tagThresholds reads the hours after which the agents with the tag are late or offline,
+// zero inherits the threshold of the tenant
+func tagThresholds(c echo.Context) (int, int, error) {
+	late, err := strconv.Atoi(c.FormValue("late-threshold"))
+	if err != nil || late < 0 {
+		return 0, 0, fmt.Errorf("%s", i18n.T(c.Request().Context(), "tags.thresholds_invalid"))
+	}
+
+	offline, err := strconv.Atoi(c.FormValue("offline-threshold"))
+	if err != nil || offline < 0 {
+		return 0, 0, fmt.Errorf("%s", i18n.T(c.Request().Context(), "tags.thresholds_invalid"))
+	}
+
+	if late > 0 && offline > 0 && late >= offline {
+		return 0, 0, fmt.Errorf("%s", i18n.T(c.Request().Context(), "settings.agent_late_over_offline"))
+	}
+
+	return late, offline, nil
+}
@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
 	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
-// TenantAccessMiddleware checks if the authenticated user has access to the requested tenant
+// TenantAccessMiddleware checks if the authenticated user has access to the requested tenant. The pages
+// without a tenant in the URL use the tenant of the session, which is checked on each request too
 func (h *Handler) TenantAccessMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		// Get user ID from session
@@ -19,6 +24,21 @@ func (h *Handler) TenantAccessMiddleware(next echo.HandlerFunc) echo.HandlerFunc
 			return h.Login(c)
 		}
 
+		// Global routes use the tenant param for the tenant being managed (e.g. /admin/tenants/:tenant)
+		if !strings.HasPrefix(c.Path(), "/tenant/:tenant") {
+			if !strings.HasPrefix(c.Path(), "/admin") {
+				// The users without tenants are sent to the access request page by GetCommonInfo
+				tenantID, _, err := h.sessionTenant(c, username)
+				if err != nil && !errors.Is(err, models.ErrNoTenantsAssigned) {
+					return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+				}
+				if err == nil {
+					c.Set(tenantIDContextKey, tenantID)
+				}
+			}
+			return next(c)
+		}
+
 		// Get tenant ID from URL parameter
 		tenantIDStr := c.Param("tenant")
 		if tenantIDStr == "" || tenantIDStr == "-1" {
@@ -38,17 +58,62 @@ func (h *Handler) TenantAccessMiddleware(next echo.HandlerFunc) echo.HandlerFunc
 		}
 
 		if !hasAccess {
+			// A bookmarked page of a tenant the user has lost access to is opened in the tenant of the session
+			if c.Request().Method == http.MethodGet && c.Request().Header.Get("HX-Request") != "true" {
+				return c.Redirect(http.StatusFound, canonicalTenantURL(c))
+			}
 			return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "tenants.no_access"))
 		}
 
+		// Check if the tenant restricts console access to some networks
+		ipAllowed, err := h.isIPAllowedInTenant(c, tenantID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if !ipAllowed {
+			return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "tenants.ip_not_allowed", c.RealIP()))
+		}
+
 		// Store tenant access info in context for later use
-		c.Set("tenant_id", tenantID)
+		c.Set(tenantIDContextKey, tenantID)
 		c.Set("user_id", username)
 
 		return next(c)
 	}
 }
 
+// isIPAllowedInTenant checks the IP of the request against the networks allowed to access the tenant. An invalid
+// IP isn't allowed, and the main tenant admins are exempt if the admin allowlist exempts them
+func (h *Handler) isIPAllowedInTenant(c echo.Context, tenantID int) (bool, error) {
+	allowedIPs, err := h.Model.GetTenantAllowedIPs(tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	if isIPInCIDRList(c.RealIP(), strings.Join(allowedIPs, ",")) {
+		return true, nil
+	}
+
+	return h.isAdminAllowlistExempt(c), nil
+}
+
+// wouldKeepTenantAccess checks if the current client would still reach the tenant with the new allowed networks.
+// Users without access to the tenant can't be locked out of it
+func (h *Handler) wouldKeepTenantAccess(c echo.Context, tenantID int, allowedIPs string) bool {
+	if isIPInCIDRList(c.RealIP(), allowedIPs) {
+		return true
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	hasAccess, err := h.Model.UserHasAccessToTenant(uid, tenantID)
+	if err != nil {
+		return false
+	}
+
+	return !hasAccess || h.isAdminAllowlistExempt(c)
+}
+
 // TenantAdminMiddleware checks if the user is an admin in the current tenant
 func (h *Handler) TenantAdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -93,14 +158,8 @@ func (h *Handler) MainTenantAdminMiddleware(next echo.HandlerFunc) echo.HandlerF
 			return h.Login(c)
 		}
 
-		// Get main tenant (lowest ID)
-		mainTenant, err := h.Model.GetMainTenant()
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		}
-
 		// Check if user is admin in the main tenant
-		isMainAdmin, err := h.Model.IsUserTenantAdmin(username, mainTenant.ID)
+		isMainAdmin, err := h.isMainTenantAdmin(c, username)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
@@ -113,6 +172,93 @@ func (h *Handler) MainTenantAdminMiddleware(next echo.HandlerFunc) echo.HandlerF
 	}
 }
 
+// getMainTenantID returns the ID of the main tenant, which is cached as it's checked on every
+// global admin request and only changes when the main tenant is deleted
+func (h *Handler) getMainTenantID() (int, error) {
+	if id := h.mainTenantID.Load(); id != 0 {
+		return int(id), nil
+	}
+
+	mainTenant, err := h.Model.GetMainTenant()
+	if err != nil {
+		return 0, err
+	}
+	h.mainTenantID.Store(int64(mainTenant.ID))
+	return mainTenant.ID, nil
+}
+
+// invalidateMainTenantID must be called when a tenant is deleted, as it may be the main tenant
+func (h *Handler) invalidateMainTenantID() {
+	h.mainTenantID.Store(0)
+}
+
+// mainTenantAdminCacheKey is the key of the echo context where the checks of the main tenant admins are
+// remembered for the rest of the request
+const mainTenantAdminCacheKey = "main_tenant_admin_cache"
+
+// mainTenantAdminResult is the result of a check of a main tenant admin, errors are remembered as well
+type mainTenantAdminResult struct {
+	isMainAdmin bool
+	err         error
+}
+
+// isMainTenantAdmin checks if the user is an admin of the main tenant. It's checked by the middleware,
+// the header and the handlers of the same request, so the result is remembered until the request ends
+func (h *Handler) isMainTenantAdmin(c echo.Context, username string) (bool, error) {
+	results := mainTenantAdminCache(c)
+	if r, ok := results.Load(username); ok {
+		result := r.(mainTenantAdminResult)
+		return result.isMainAdmin, result.err
+	}
+
+	isMainAdmin, err := h.checkMainTenantAdmin(username)
+	results.Store(username, mainTenantAdminResult{isMainAdmin: isMainAdmin, err: err})
+	return isMainAdmin, err
+}
+
+// ClearMainTenantAdminCache forgets the check of the user in this request, it must be called when
+// the roles of the user change
+func ClearMainTenantAdminCache(c echo.Context, userID string) {
+	mainTenantAdminCache(c).Delete(userID)
+}
+
+func mainTenantAdminCache(c echo.Context) *sync.Map {
+	if results, ok := c.Get(mainTenantAdminCacheKey).(*sync.Map); ok {
+		return results
+	}
+	results := &sync.Map{}
+	c.Set(mainTenantAdminCacheKey, results)
+	return results
+}
+
+// checkMainTenantAdmin checks the role of the user in the cached main tenant. If the user isn't found in
+// it, the main tenant may have been deleted by another console replica, so the main tenant is queried
+// again and the role is checked once more only if it has changed. A user who isn't an admin keeps the cache
+func (h *Handler) checkMainTenantAdmin(username string) (bool, error) {
+	cached := h.mainTenantID.Load() != 0
+
+	mainTenantID, err := h.getMainTenantID()
+	if err != nil {
+		return false, err
+	}
+
+	isMainAdmin, err := h.Model.IsUserTenantAdmin(username, mainTenantID)
+	if openuem_ent.IsNotFound(err) && cached && h.refreshMainTenantID(mainTenantID) {
+		return h.checkMainTenantAdmin(username)
+	}
+	return isMainAdmin, err
+}
+
+// refreshMainTenantID queries the main tenant and returns true if it's no longer the cached one
+func (h *Handler) refreshMainTenantID(cachedID int) bool {
+	mainTenant, err := h.Model.GetMainTenant()
+	if err != nil || mainTenant.ID == cachedID {
+		return false
+	}
+	h.mainTenantID.Store(int64(mainTenant.ID))
+	return true
+}
+
 // TenantOperatorMiddleware checks if the user is an admin OR operator in the tenant (for settings access)
 func (h *Handler) TenantOperatorMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -147,26 +293,48 @@ func (h *Handler) TenantOperatorMiddleware(next echo.HandlerFunc) echo.HandlerFu
 	}
 }
 
-// GetCurrentUserTenantRole returns the role of the current user in the current tenant
+// RequireFeature hides the routes of an experimental feature in the tenants where it hasn't been enabled,
+// they're not found as if the feature didn't exist. Global routes check the feature in the main tenant
+func (h *Handler) RequireFeature(feature string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID, _, err := h.authorizationTenantID(c)
+			if err != nil {
+				return err
+			}
+
+			enabled, err := h.Model.IsFeatureEnabled(tenantID, feature)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			if !enabled {
+				return echo.ErrNotFound
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetCurrentUserTenantRole returns the role of the current user in the tenant that RequireRole checks,
+// so the views hide the same actions the handlers forbid. It's empty if the user has no role
 func (h *Handler) GetCurrentUserTenantRole(c echo.Context) (string, error) {
 	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
 	if username == "" {
 		return "", nil
 	}
 
-	// Get tenant ID
-	tenantIDStr := c.Param("tenant")
-	if tenantIDStr == "" || tenantIDStr == "-1" {
-		return "", nil
-	}
-
-	tenantID, err := strconv.Atoi(tenantIDStr)
+	tenantID, _, err := h.authorizationTenantID(c)
 	if err != nil {
 		return "", err
 	}
 
 	role, err := h.Model.GetUserRoleInTenant(username, tenantID)
 	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return "", nil
+		}
 		return "", err
 	}
 
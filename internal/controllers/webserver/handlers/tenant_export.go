@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// tenantExportTTL is how long a tenant export can be downloaded before its file is removed
+const tenantExportTTL = 1 * time.Hour
+
+// tenantExportCleanInterval is how often the expired tenant exports are removed
+const tenantExportCleanInterval = 5 * time.Minute
+
+// tenantExportTokenSubject identifies the tokens of the tenant export download links
+const tenantExportTokenSubject = "tenant-export"
+
+// tenantExports keeps the tenant exports of this console replica until they're downloaded or expire
+type tenantExports struct {
+	mu      sync.Mutex
+	exports map[string]*admin_views.TenantExport
+}
+
+func newTenantExports() *tenantExports {
+	return &tenantExports{exports: map[string]*admin_views.TenantExport{}}
+}
+
+// get returns a copy of the export so it can be rendered while the job updates it
+func (e *tenantExports) get(id string, tenantID int) (admin_views.TenantExport, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	export, ok := e.exports[id]
+	if !ok || export.TenantID != tenantID {
+		return admin_views.TenantExport{}, false
+	}
+	return *export, true
+}
+
+func (e *tenantExports) add(export *admin_views.TenantExport) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exports[export.ID] = export
+}
+
+func (e *tenantExports) update(id string, f func(export *admin_views.TenantExport)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if export, ok := e.exports[id]; ok {
+		f(export)
+	}
+}
+
+// remove deletes the export and its file
+func (e *tenantExports) remove(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if export, ok := e.exports[id]; ok {
+		if err := os.Remove(export.Path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR]: could not remove tenant export %s, reason: %v", export.Path, err)
+		}
+		delete(e.exports, id)
+	}
+}
+
+// removeExpired deletes the exports that are no longer running and have expired
+func (e *tenantExports) removeExpired(now time.Time) {
+	e.mu.Lock()
+	expired := []string{}
+	for id, export := range e.exports {
+		if export.Status != admin_views.TenantExportRunning && now.After(export.ExpiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, id := range expired {
+		e.remove(id)
+	}
+}
+
+// StartTenantExport creates a ZIP with the data of the tenant in a background job
+func (h *Handler) StartTenantExport(c echo.Context) error {
+	commonInfo, tenantID, err := h.tenantExportRequest(c)
+	if err != nil {
+		return err
+	}
+
+	t, err := h.Model.GetTenantByID(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	f, err := os.CreateTemp("", "openuem-tenant-export-*.zip")
+	if err != nil {
+		log.Printf("[ERROR]: could not create the tenant export file, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_export.could_not_start"), true))
+	}
+
+	export := &admin_views.TenantExport{
+		ID:         uuid.New().String(),
+		TenantID:   t.ID,
+		TenantName: t.Description,
+		Path:       f.Name(),
+		Status:     admin_views.TenantExportRunning,
+		FilesTotal: len(models.TenantExportFiles),
+		ExpiresAt:  time.Now().Add(tenantExportTTL),
+	}
+	h.tenantExports.add(export)
+
+	_, err = h.TaskScheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+		gocron.NewTask(func() {
+			h.runTenantExport(export.ID, t.ID, f)
+		}),
+	)
+	if err != nil {
+		f.Close()
+		h.tenantExports.remove(export.ID)
+		log.Printf("[ERROR]: could not schedule the tenant export job, reason: %v", err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_export.could_not_start"), true))
+	}
+
+	h.auditTenantData(c, "has requested an export of tenant %d (%s)", t.ID, t.Description)
+
+	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.TenantExportPage(c, *export, "", commonInfo), commonInfo))
+}
+
+func (h *Handler) runTenantExport(id string, tenantID int, f *os.File) {
+	zw := zip.NewWriter(f)
+	_, err := h.Model.ExportTenant(tenantID, zw, func(file string) {
+		h.tenantExports.update(id, func(export *admin_views.TenantExport) {
+			export.FilesDone++
+		})
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	h.tenantExports.update(id, func(export *admin_views.TenantExport) {
+		export.ExpiresAt = time.Now().Add(tenantExportTTL)
+		if err != nil {
+			log.Printf("[ERROR]: could not export tenant %d, reason: %v", tenantID, err)
+			export.Status = admin_views.TenantExportFailed
+			return
+		}
+		export.Status = admin_views.TenantExportDone
+	})
+
+	// The partial file is useless if the export failed
+	if err != nil {
+		if removeErr := os.Remove(f.Name()); removeErr != nil {
+			log.Printf("[ERROR]: could not remove tenant export %s, reason: %v", f.Name(), removeErr)
+		}
+	}
+}
+
+// TenantExportStatus shows the progress of an export and the download link when it's done
+func (h *Handler) TenantExportStatus(c echo.Context) error {
+	commonInfo, tenantID, err := h.tenantExportRequest(c)
+	if err != nil {
+		return err
+	}
+
+	export, ok := h.tenantExports.get(c.Param("export"), tenantID)
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_export.not_found"), true))
+	}
+
+	downloadURL := ""
+	if export.Status == admin_views.TenantExportDone {
+		token, err := h.tenantExportToken(export)
+		if err != nil {
+			log.Printf("[ERROR]: could not sign the tenant export download link, reason: %v", err)
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_export.could_not_start"), true))
+		}
+		downloadURL = fmt.Sprintf("/admin/tenants/%d/export/%s/download?token=%s", export.TenantID, export.ID, token)
+	}
+
+	return RenderView(c, admin_views.TenantExportStatus(export, downloadURL))
+}
+
+// DownloadTenantExport sends the export if the link hasn't expired and removes it once downloaded
+func (h *Handler) DownloadTenantExport(c echo.Context) error {
+	_, tenantID, err := h.tenantExportRequest(c)
+	if err != nil {
+		return err
+	}
+
+	id := c.Param("export")
+	if !h.validTenantExportToken(c.QueryParam("token"), id) {
+		return echo.NewHTTPError(http.StatusForbidden, i18n.T(c.Request().Context(), "tenant_export.link_expired"))
+	}
+
+	export, ok := h.tenantExports.get(id, tenantID)
+	if !ok || export.Status != admin_views.TenantExportDone {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "tenant_export.not_found"))
+	}
+	defer h.tenantExports.remove(id)
+
+	h.auditTenantData(c, "has downloaded the export of tenant %d (%s)", export.TenantID, export.TenantName)
+
+	return c.Attachment(export.Path, fmt.Sprintf("openuem-tenant-%d-export-%s.zip", export.TenantID, time.Now().Format("20060102")))
+}
+
+// StartTenantExportsCleanJob removes the exports that haven't been downloaded before they expired
+// and the uploaded bundles that haven't been imported
+func (h *Handler) StartTenantExportsCleanJob() error {
+	var err error
+
+	h.TenantExportsCleanJob, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			tenantExportCleanInterval,
+		),
+		gocron.NewTask(
+			func() {
+				h.tenantExports.removeExpired(time.Now())
+				h.tenantImports.removeExpired(time.Now())
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule the tenant exports clean job, reason: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// tenantExportRequest returns the common info for the global config and the tenant in the URL
+func (h *Handler) tenantExportRequest(c echo.Context) (*partials.CommonInfo, int, error) {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Override tenant and site ids as we're working in global config
+	commonInfo.TenantID = "-1"
+	commonInfo.SiteID = "-1"
+
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return nil, 0, RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", c.Param("tenant")), true))
+	}
+
+	return commonInfo, tenantID, nil
+}
+
+func (h *Handler) tenantExportToken(export admin_views.TenantExport) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(export.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		Issuer:    "OpenUEM",
+		Subject:   tenantExportTokenSubject,
+		ID:        export.ID,
+	})
+
+	return token.SignedString([]byte(h.JWTKey))
+}
+
+func (h *Handler) validTenantExportToken(tokenString, exportID string) bool {
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(h.JWTKey), nil
+	}, jwt.WithSubject(tenantExportTokenSubject), jwt.WithExpirationRequired())
+
+	return err == nil && claims.ID == exportID
+}
+
+// auditTenantData logs who exported, imported or changed the data of a tenant in the auth log
+func (h *Handler) auditTenantData(c echo.Context, format string, args ...any) {
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	msg := fmt.Sprintf("user %s ", uid) + fmt.Sprintf(format, args...) + fmt.Sprintf(" from %s", c.RealIP())
+
+	log.Printf("[INFO]: %s", msg)
+	if h.AuthLogger != nil {
+		h.AuthLogger.Print(msg)
+	}
+}
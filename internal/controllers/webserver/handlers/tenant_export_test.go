@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantExportToken(t *testing.T) {
+	h := &Handler{JWTKey: "secret"}
+
+	export := admin_views.TenantExport{ID: "export1", ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := h.tenantExportToken(export)
+	assert.NoError(t, err)
+	assert.True(t, h.validTenantExportToken(token, "export1"), "should accept the link of the export")
+	assert.False(t, h.validTenantExportToken(token, "export2"), "should not accept the link of another export")
+	assert.False(t, (&Handler{JWTKey: "other"}).validTenantExportToken(token, "export1"), "should not accept links signed with another key")
+
+	export.ExpiresAt = time.Now().Add(-time.Minute)
+	token, err = h.tenantExportToken(export)
+	assert.NoError(t, err)
+	assert.False(t, h.validTenantExportToken(token, "export1"), "should not accept expired links")
+
+	token, err = h.generateEmailToken("export1", "email", 1)
+	assert.NoError(t, err)
+	assert.False(t, h.validTenantExportToken(token, "export1"), "should not accept email confirmation tokens")
+}
+
+func TestTenantExportsRemoveExpired(t *testing.T) {
+	exports := newTenantExports()
+
+	newExport := func(id, status string, expiresAt time.Time) string {
+		f, err := os.CreateTemp(t.TempDir(), "export-*.zip")
+		assert.NoError(t, err)
+		f.Close()
+		exports.add(&admin_views.TenantExport{ID: id, TenantID: 1, Path: f.Name(), Status: status, ExpiresAt: expiresAt})
+		return f.Name()
+	}
+
+	expired := newExport("expired", admin_views.TenantExportDone, time.Now().Add(-time.Minute))
+	running := newExport("running", admin_views.TenantExportRunning, time.Now().Add(-time.Minute))
+	valid := newExport("valid", admin_views.TenantExportDone, time.Now().Add(time.Hour))
+
+	exports.removeExpired(time.Now())
+
+	_, ok := exports.get("expired", 1)
+	assert.False(t, ok, "should remove expired exports")
+	assert.NoFileExists(t, expired, "should remove the file of expired exports")
+
+	_, ok = exports.get("running", 1)
+	assert.True(t, ok, "should keep running exports")
+	assert.FileExists(t, running)
+
+	_, ok = exports.get("valid", 1)
+	assert.True(t, ok, "should keep exports that haven't expired")
+	assert.FileExists(t, valid)
+
+	_, ok = exports.get("valid", 2)
+	assert.False(t, ok, "should not get exports of another tenant")
+}
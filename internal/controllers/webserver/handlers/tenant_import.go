@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// tenantImportTTL is how long an uploaded bundle waits to be imported before it's discarded
+const tenantImportTTL = 1 * time.Hour
+
+// tenantImport is a bundle uploaded to this console replica waiting for the admin to confirm the import
+type tenantImport struct {
+	bundle    *models.TenantImportBundle
+	view      admin_views.TenantImport
+	expiresAt time.Time
+}
+
+type tenantImports struct {
+	mu      sync.Mutex
+	imports map[string]*tenantImport
+}
+
+func newTenantImports() *tenantImports {
+	return &tenantImports{imports: map[string]*tenantImport{}}
+}
+
+// get returns a copy of the import, so a request can change its view without racing with other requests
+func (i *tenantImports) get(id string) (*tenantImport, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	imp, ok := i.imports[id]
+	if !ok || time.Now().After(imp.expiresAt) {
+		return nil, false
+	}
+	snapshot := *imp
+	return &snapshot, true
+}
+
+func (i *tenantImports) add(id string, imp *tenantImport) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.imports[id] = imp
+}
+
+// update stores the changes of the import unless it has been taken to be imported in the meantime
+func (i *tenantImports) update(id string, imp *tenantImport) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.imports[id]; ok {
+		i.imports[id] = imp
+	}
+}
+
+// take removes the import so it can only be imported once, e.g. if the admin clicks twice. It must be
+// added again if the import fails
+func (i *tenantImports) take(id string) (*tenantImport, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	imp, ok := i.imports[id]
+	if !ok {
+		return nil, false
+	}
+	delete(i.imports, id)
+	if time.Now().After(imp.expiresAt) {
+		return nil, false
+	}
+	return imp, true
+}
+
+func (i *tenantImports) removeExpired(now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for id, imp := range i.imports {
+		if now.After(imp.expiresAt) {
+			delete(i.imports, id)
+		}
+	}
+}
+
+// NewTenantImport shows the form to upload a tenant export
+func (h *Handler) NewTenantImport(c echo.Context) error {
+	commonInfo, err := h.tenantImportCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.TenantImportPage(c, nil, commonInfo), commonInfo))
+}
+
+// UploadTenantImport reads the uploaded bundle and shows the preview of the import
+func (h *Handler) UploadTenantImport(c echo.Context) error {
+	commonInfo, err := h.tenantImportCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	file, err := c.FormFile("bundle")
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.file_required"), true))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.invalid_bundle", err.Error()), true))
+	}
+	defer src.Close()
+
+	zr, err := zip.NewReader(src, file.Size)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.invalid_bundle", err.Error()), true))
+	}
+
+	bundle, err := models.ReadTenantImportBundle(zr)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.invalid_bundle", err.Error()), true))
+	}
+
+	imp := &tenantImport{
+		bundle: bundle,
+		view: admin_views.TenantImport{
+			ID:            uuid.New().String(),
+			TenantName:    bundle.Tenant.Description,
+			SchemaVersion: bundle.Manifest.SchemaVersion,
+			ExportedAt:    bundle.Manifest.CreatedAt,
+		},
+		expiresAt: time.Now().Add(tenantImportTTL),
+	}
+	if err := h.previewTenantImport(imp); err != nil {
+		return RenderModelError(c, err)
+	}
+	h.tenantImports.add(imp.view.ID, imp)
+
+	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.TenantImportPage(c, &imp.view, commonInfo), commonInfo))
+}
+
+// PreviewTenantImport refreshes the preview when the tenant name or the users option change
+func (h *Handler) PreviewTenantImport(c echo.Context) error {
+	imp, ok := h.tenantImports.get(c.Param("import"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.not_found"), true))
+	}
+
+	imp.view.TenantName = c.FormValue("tenant-name")
+	imp.view.CreateUsers = c.FormValue("create-users") == "on"
+	imp.view.Failed = false
+	if err := h.previewTenantImport(imp); err != nil {
+		return RenderModelError(c, err)
+	}
+	h.tenantImports.update(imp.view.ID, imp)
+
+	return RenderView(c, admin_views.TenantImportPreview(imp.view))
+}
+
+// ImportTenantBundle imports the bundle in a new tenant. If any record fails nothing is saved
+// and the preview shows the link to download the report with the offending records
+func (h *Handler) ImportTenantBundle(c echo.Context) error {
+	imp, ok := h.tenantImports.take(c.Param("import"))
+	if !ok {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.not_found"), true))
+	}
+
+	imp.view.TenantName = c.FormValue("tenant-name")
+	imp.view.CreateUsers = c.FormValue("create-users") == "on"
+	if imp.view.TenantName == "" {
+		h.tenantImports.add(imp.view.ID, imp)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_import.name_required"), true))
+	}
+
+	tenantID, err := h.Model.ImportTenant(imp.bundle, models.TenantImportOptions{TenantName: imp.view.TenantName, CreateUsers: imp.view.CreateUsers})
+	if err != nil {
+		importErr := &models.TenantImportError{}
+		if !errors.As(err, &importErr) {
+			h.tenantImports.add(imp.view.ID, imp)
+			return RenderModelError(c, err)
+		}
+
+		imp.view.Failed = true
+		imp.view.Conflicts = tenantImportRecords(importErr.Records)
+		h.tenantImports.add(imp.view.ID, imp)
+		h.auditTenantData(c, "has failed to import tenant %s with %d record errors", imp.view.TenantName, len(importErr.Records))
+
+		// Keep the preview with the offending records instead of replacing the page
+		c.Response().Header().Set("HX-Retarget", "#tenant-import-preview")
+		c.Response().Header().Set("HX-Reswap", "outerHTML")
+		return RenderView(c, admin_views.TenantImportPreview(imp.view))
+	}
+
+	h.auditTenantData(c, "has imported tenant %d (%s) from an export of %s", tenantID, imp.view.TenantName, imp.bundle.Manifest.CreatedAt.Format(time.RFC3339))
+
+	return h.ListTenants(c, i18n.T(c.Request().Context(), "tenant_import.success", imp.view.TenantName), "", false)
+}
+
+// DownloadTenantImportReport sends the offending records of the last import attempt as a CSV file
+func (h *Handler) DownloadTenantImportReport(c echo.Context) error {
+	imp, ok := h.tenantImports.get(c.Param("import"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "tenant_import.not_found"))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="openuem-tenant-import-errors-%s.csv"`, time.Now().Format("20060102")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write([]string{"file", "record", "reason"}); err != nil {
+		return err
+	}
+	for _, r := range imp.view.Conflicts {
+		if err := w.Write([]string{r.File, r.Record, r.Reason}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (h *Handler) previewTenantImport(imp *tenantImport) error {
+	preview, err := h.Model.PreviewTenantImport(imp.bundle, models.TenantImportOptions{TenantName: imp.view.TenantName, CreateUsers: imp.view.CreateUsers})
+	if err != nil {
+		log.Printf("[ERROR]: could not preview the tenant import, reason: %v", err)
+		return err
+	}
+
+	imp.view.Counts = preview.Counts
+	imp.view.MatchedUsers = preview.MatchedUsers
+	imp.view.NewUsers = preview.NewUsers
+	imp.view.Conflicts = tenantImportRecords(preview.Conflicts)
+	return nil
+}
+
+func (h *Handler) tenantImportCommonInfo(c echo.Context) (*partials.CommonInfo, error) {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Override tenant and site ids as we're working in global config
+	commonInfo.TenantID = "-1"
+	commonInfo.SiteID = "-1"
+	return commonInfo, nil
+}
+
+func tenantImportRecords(records []models.TenantImportRecordError) []admin_views.TenantImportRecord {
+	view := []admin_views.TenantImportRecord{}
+	for _, r := range records {
+		view = append(view, admin_views.TenantImportRecord{File: r.File, Record: r.Record, Reason: r.Reason})
+	}
+	return view
+}
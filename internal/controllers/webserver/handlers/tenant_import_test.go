@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadTenantImportReport(t *testing.T) {
+	h := &Handler{tenantImports: newTenantImports()}
+	h.tenantImports.add("import1", &tenantImport{
+		view: admin_views.TenantImport{
+			ID:        "import1",
+			Failed:    true,
+			Conflicts: []admin_views.TenantImportRecord{{File: "agents.json", Record: "agent1", Reason: "an agent with this ID already exists"}},
+		},
+		expiresAt: time.Now().Add(tenantImportTTL),
+	})
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.SetParamNames("import")
+	c.SetParamValues("import1")
+
+	assert.NoError(t, h.DownloadTenantImportReport(c))
+	assert.Equal(t, "file,record,reason\nagents.json,agent1,an agent with this ID already exists\n", rec.Body.String(), "should list the offending records")
+
+	h.tenantImports.removeExpired(time.Now().Add(2 * tenantImportTTL))
+	_, ok := h.tenantImports.get("import1")
+	assert.False(t, ok, "should remove expired imports")
+}
+
+func TestTenantImports(t *testing.T) {
+	imports := newTenantImports()
+	imports.add("import1", &tenantImport{view: admin_views.TenantImport{ID: "import1", TenantName: "Uploaded"}, expiresAt: time.Now().Add(tenantImportTTL)})
+	imports.add("expired", &tenantImport{view: admin_views.TenantImport{ID: "expired"}, expiresAt: time.Now().Add(-time.Minute)})
+
+	imp, ok := imports.get("import1")
+	assert.True(t, ok)
+	imp.view.TenantName = "Changed"
+	stored, _ := imports.get("import1")
+	assert.Equal(t, "Uploaded", stored.view.TenantName, "the view should only change when the import is updated")
+
+	imports.update("import1", imp)
+	stored, _ = imports.get("import1")
+	assert.Equal(t, "Changed", stored.view.TenantName, "should store the updated view")
+
+	_, ok = imports.get("expired")
+	assert.False(t, ok, "should not get expired imports")
+	_, ok = imports.take("expired")
+	assert.False(t, ok, "should not import expired imports")
+
+	imp, ok = imports.take("import1")
+	assert.True(t, ok)
+	_, ok = imports.take("import1")
+	assert.False(t, ok, "should only import once if the admin clicks twice")
+	_, ok = imports.get("import1")
+	assert.False(t, ok, "should not preview an import while it runs")
+
+	imports.update("import1", imp)
+	_, ok = imports.get("import1")
+	assert.False(t, ok, "a preview should not add back a running import")
+
+	imports.add("import1", imp)
+	_, ok = imports.take("import1")
+	assert.True(t, ok, "should import again after a failed import")
+}
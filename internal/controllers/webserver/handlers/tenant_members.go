@@ -1,52 +1,125 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
 	"github.com/open-uem/openuem-console/internal/models"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
 // ListTenantMembers shows the members (users) assigned to a tenant with their roles
 func (h *Handler) ListTenantMembers(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
 	}
 
+	return h.renderTenantMembers(c, commonInfo, "", "")
+}
+
+// renderTenantMembers shows the page of members of the tenant requested
+func (h *Handler) renderTenantMembers(c echo.Context, commonInfo *partials.CommonInfo, identifier, errMessage string) error {
 	tenantID, err := strconv.Atoi(commonInfo.TenantID)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"), false))
 	}
 
-	members, err := h.Model.GetTenantUsersWithRoles(tenantID)
+	f := filters.TenantMemberFilter{Name: c.FormValue("filterByName")}
+	for index := range admin_views.TenantMemberRoles {
+		value := c.FormValue(fmt.Sprintf("filterByRole%d", index))
+		if slices.Contains(admin_views.TenantMemberRoles, value) {
+			f.RoleOptions = append(f.RoleOptions, strings.TrimPrefix(value, "tenants.role_"))
+		}
+	}
+
+	itemsPerPage, err := h.Model.GetDefaultItemsPerPage()
+	if err != nil {
+		log.Println("[ERROR]: could not get items per page from database")
+		itemsPerPage = 5
+	}
+
+	p := partials.NewPaginationAndSort(itemsPerPage)
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"), itemsPerPage)
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "last_seen"
+		p.SortOrder = "desc"
+	}
+
+	p.NItems, err = h.Model.CountTenantMembers(tenantID, f)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
+	}
+	p.ClampCurrentPage()
+
+	members, err := h.Model.GetTenantMembersByPage(tenantID, p, f)
+	if err != nil {
+		return RenderModelError(c, err)
+	}
+
+	inherited, err := h.inheritedTenantUsers(tenantID)
+	if err != nil {
+		return RenderModelError(c, err)
 	}
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	currentUsername := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
 
+	pushListState(c, fmt.Sprintf("/tenant/%d/admin/members", tenantID), p, requestListFilters(c))
+
 	return RenderView(c, admin_views.TenantMembersIndex(" | Members",
-		admin_views.TenantMembers(c, members, "", "", agentsExists, serversExists, commonInfo, currentUsername),
+		admin_views.TenantMembers(c, p, f, members, inherited, identifier, errMessage, itemsPerPage, agentsExists, serversExists, commonInfo, currentUsername),
 		commonInfo))
 }
 
+// inheritedTenantUsers returns the users with access to the tenant who aren't assigned to it, the admins of
+// the hoster tenant
+func (h *Handler) inheritedTenantUsers(tenantID int) ([]*openuem_ent.User, error) {
+	effective, err := h.Model.GetEffectiveTenantUsers(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := h.Model.GetTenantUsers(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(effective, func(u *openuem_ent.User) bool {
+		return slices.ContainsFunc(assigned, func(a *openuem_ent.User) bool { return a.ID == u.ID })
+	}), nil
+}
+
 // AddTenantMember looks up a user by email or username and assigns them to the tenant
 func (h *Handler) AddTenantMember(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -89,27 +162,29 @@ func (h *Handler) AddTenantMember(c echo.Context) error {
 	err = h.Model.AssignUserToTenant(userID, tenantID, models.UserTenantRole(role), false)
 	if err != nil {
 		log.Printf("[ERROR]: could not add member to tenant: %v", err)
-		return h.listTenantMembersWithError(c, commonInfo, identifier, err.Error())
+		_, errMessage := modelError(c, err)
+		return h.listTenantMembersWithError(c, commonInfo, identifier, errMessage)
 	}
+	ClearMainTenantAdminCache(c, userID)
 
 	return h.ListTenantMembers(c)
 }
 
 // listTenantMembersWithError re-renders the members view with an error message
 func (h *Handler) listTenantMembersWithError(c echo.Context, commonInfo *partials.CommonInfo, identifier, errMsg string) error {
-	tenantID, _ := strconv.Atoi(commonInfo.TenantID)
-	members, _ := h.Model.GetTenantUsersWithRoles(tenantID)
-	agentsExists, _ := h.Model.AgentsExists(commonInfo)
-	serversExists, _ := h.Model.ServersExists()
-	currentUsername := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
-
-	return RenderView(c, admin_views.TenantMembersIndex(" | Members",
-		admin_views.TenantMembers(c, members, identifier, errMsg, agentsExists, serversExists, commonInfo, currentUsername),
-		commonInfo))
+	return h.renderTenantMembers(c, commonInfo, identifier, errMsg)
 }
 
 // RemoveTenantMember removes a user from the current tenant
 func (h *Handler) RemoveTenantMember(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
+	if err := h.requireTenantMember(c); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -134,14 +209,23 @@ func (h *Handler) RemoveTenantMember(c echo.Context) error {
 	err = h.Model.RemoveUserFromTenant(userID, tenantID)
 	if err != nil {
 		log.Printf("[ERROR]: could not remove member from tenant: %v", err)
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
+	ClearMainTenantAdminCache(c, userID)
 
 	return h.ListTenantMembers(c)
 }
 
 // UpdateTenantMemberRole updates the role of a user within the current tenant
 func (h *Handler) UpdateTenantMemberRole(c echo.Context) error {
+	if err := h.RequireRole(c, models.UserTenantRoleAdmin); err != nil {
+		return err
+	}
+
+	if err := h.requireTenantMember(c); err != nil {
+		return err
+	}
+
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
 		return err
@@ -171,8 +255,26 @@ func (h *Handler) UpdateTenantMemberRole(c echo.Context) error {
 	err = h.Model.UpdateUserTenantRole(userID, tenantID, models.UserTenantRole(role))
 	if err != nil {
 		log.Printf("[ERROR]: could not update member role: %v", err)
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
+	ClearMainTenantAdminCache(c, userID)
 
 	return h.ListTenantMembers(c)
 }
+
+// requireTenantMember returns a not found error if the user in the URL isn't a member of the tenant
+func (h *Handler) requireTenantMember(c echo.Context) error {
+	tenantID, err := strconv.Atoi(c.Param("tenant"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+	}
+
+	isMember, err := h.Model.UserHasAccessToTenant(c.Param("uid"), tenantID)
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+	if !isMember {
+		return echo.NewHTTPError(http.StatusNotFound, i18n.T(c.Request().Context(), "members.member_not_found"))
+	}
+	return nil
+}
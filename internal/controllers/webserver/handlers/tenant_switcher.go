@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/models"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// TenantSwitcher lists the tenants of the user whose name contains the search. The tenants used
+// recently are listed first when the user hasn't typed anything yet
+func (h *Handler) TenantSwitcher(c echo.Context) error {
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	search := strings.TrimSpace(c.QueryParam("search"))
+	admin := c.QueryParam("admin") == "true"
+
+	recent := []*partials.TenantInfo{}
+	if search == "" {
+		userTenants, err := h.Model.GetRecentUserTenants(username)
+		if err != nil {
+			log.Printf("[ERROR]: could not get the recent tenants of user %s, reason: %v", username, err)
+		}
+		recent = h.tenantSwitcherInfo(userTenants)
+	}
+
+	userTenants, err := h.Model.SearchUserTenants(username, search, models.TenantSwitcherLimit)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenant_switcher.could_not_search", err.Error()), true))
+	}
+
+	return RenderView(c, partials.TenantSwitcherResults(recent, h.tenantSwitcherInfo(userTenants), admin))
+}
+
+// TenantSwitcherSites lists the sites of a tenant of the user to switch directly to one of them
+func (h *Handler) TenantSwitcherSites(c echo.Context) error {
+	tenantID, err := h.tenantSwitcherTenantID(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	t, err := h.Model.GetTenantByID(tenantID)
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+
+	sites, err := h.Model.GetAssociatedSites(t)
+	if err != nil {
+		return ModelHTTPError(c, err)
+	}
+
+	return RenderView(c, partials.TenantSwitcherSites(tenantID, sites))
+}
+
+// SwitchTenant saves the tenant as recently used and as the tenant of the session, used by the pages without
+// a tenant in the URL, and tells HTMX to load the page of the tenant, or of one of its sites, in the body so
+// every region that depends on the current tenant is rendered again
+func (h *Handler) SwitchTenant(c echo.Context) error {
+	tenantID, err := h.tenantSwitcherTenantID(c, c.FormValue("tenant"))
+	if err != nil {
+		return err
+	}
+
+	sessionSiteID := 0
+	url := fmt.Sprintf("/tenant/%d", tenantID)
+	if c.FormValue("admin") == "true" {
+		url += "/admin"
+	} else if siteID := c.FormValue("site"); siteID != "" {
+		id, err := strconv.Atoi(siteID)
+		if err != nil {
+			return resourceNotFound(c)
+		}
+		if _, err := h.Model.GetSite(id, tenantID); err != nil {
+			if openuem_ent.IsNotFound(err) {
+				return resourceNotFound(c)
+			}
+			return ModelHTTPError(c, err)
+		}
+		url += fmt.Sprintf("/site/%d", id)
+		sessionSiteID = id
+	}
+	h.setSessionTenant(c, tenantID, sessionSiteID)
+
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if err := h.Model.AddRecentTenant(username, tenantID); err != nil {
+		log.Printf("[ERROR]: could not save the recent tenants of user %s, reason: %v", username, err)
+	}
+
+	location, err := json.Marshal(map[string]string{"path": url, "target": "body"})
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("HX-Location", string(location))
+	return c.NoContent(http.StatusOK)
+}
+
+// tenantSwitcherTenantID returns the tenant chosen in the switcher if the user is assigned to it, otherwise
+// a not found error so the tenants of other users can't be told apart from tenants that don't exist
+func (h *Handler) tenantSwitcherTenantID(c echo.Context, param string) (int, error) {
+	tenantID, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, i18n.T(c.Request().Context(), "tenants.invalid_tenant_id"))
+	}
+
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	hasAccess, err := h.Model.UserHasAccessToTenant(username, tenantID)
+	if err != nil {
+		return 0, ModelHTTPError(c, err)
+	}
+	if !hasAccess {
+		return 0, resourceNotFound(c)
+	}
+	return tenantID, nil
+}
+
+// tenantSwitcherInfo returns the tenants of the assignments with the role of the user in each of them
+func (h *Handler) tenantSwitcherInfo(userTenants []*openuem_ent.UserTenant) []*partials.TenantInfo {
+	mainTenantID, err := h.getMainTenantID()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the main tenant, reason: %v", err)
+	}
+
+	tenants := make([]*partials.TenantInfo, 0, len(userTenants))
+	for _, ut := range userTenants {
+		if ut.Edges.Tenant == nil {
+			continue
+		}
+		tenants = append(tenants, &partials.TenantInfo{
+			ID:          ut.TenantID,
+			Description: ut.Edges.Tenant.Description,
+			IsDefault:   ut.Edges.Tenant.IsDefault,
+			IsMain:      ut.TenantID == mainTenantID,
+			UserRole:    string(ut.Role),
+		})
+	}
+	return tenants
+}
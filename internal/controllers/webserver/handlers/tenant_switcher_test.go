@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// switchTenantContext returns a request to switch to a tenant posted by the tenant switcher
+func (at *authorizationTest) switchTenantContext(t *testing.T, uid string, form url.Values) echo.Context {
+	c := at.context(t, uid, http.MethodPost, "/tenant-switcher/switch", nil)
+	c.Request().Form = form
+	return c
+}
+
+func TestSwitchTenant(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	c := at.switchTenantContext(t, "operator", url.Values{"tenant": {strconv.Itoa(at.secondTenantID)}})
+	assert.NoError(t, at.h.SwitchTenant(c))
+	assert.Equal(t, fmt.Sprintf(`{"path":"/tenant/%d","target":"body"}`, at.secondTenantID), c.Response().Header().Get("HX-Location"))
+
+	recent, err := at.h.Model.GetRecentUserTenants("operator")
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, at.secondTenantID, recent[0].TenantID, "should save the tenant as recently used")
+	}
+	assert.Equal(t, at.secondTenantID, at.h.SessionManager.Manager.GetInt(c.Request().Context(), sessionTenantKey), "should save the tenant in the session")
+
+	c = at.switchTenantContext(t, "operator", url.Values{"tenant": {strconv.Itoa(at.mainTenantID)}})
+	assertHTTPError(t, http.StatusNotFound, at.h.SwitchTenant(c), "should not switch to tenants the user isn't a member of")
+
+	c = at.switchTenantContext(t, "operator", url.Values{"tenant": {"main"}})
+	assertHTTPError(t, http.StatusBadRequest, at.h.SwitchTenant(c), "should reject invalid tenants")
+
+	mainSite, err := at.h.Model.Client.Site.Create().SetDescription("Main office").SetTenantID(at.mainTenantID).Save(context.Background())
+	assert.NoError(t, err)
+	c = at.switchTenantContext(t, "operator", url.Values{"tenant": {strconv.Itoa(at.secondTenantID)}, "site": {strconv.Itoa(mainSite.ID)}})
+	assertHTTPError(t, http.StatusNotFound, at.h.SwitchTenant(c), "should not switch to sites of another tenant")
+}
+
+func TestTenantSwitcherSitesFromAnotherTenant(t *testing.T) {
+	at := newAuthorizationTest(t)
+
+	c := at.context(t, "operator", http.MethodGet, "/tenant-switcher/:id/sites", map[string]string{"id": strconv.Itoa(at.mainTenantID)})
+	assertHTTPError(t, http.StatusNotFound, at.h.TenantSwitcherSites(c), "should not list sites of tenants the user isn't a member of")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -36,8 +37,14 @@ func (h *Handler) ListTenants(c echo.Context, successMessage, errMessage string,
 	}
 
 	// if we confirm an action let's save the tenantID
+	var confirm *partials.DangerousAction
 	if confirmDelete {
 		commonInfo.ActionTenantID = commonInfo.TenantID
+		confirm, err = h.confirmTenantDeletion(c, commonInfo.ActionTenantID)
+		if err != nil {
+			successMessage = ""
+			_, errMessage = modelError(c, err)
+		}
 	}
 	// Override tenant and site ids as we're working in global config
 	commonInfo.TenantID = "-1"
@@ -89,13 +96,13 @@ func (h *Handler) ListTenants(c echo.Context, successMessage, errMessage string,
 	p.NItems, err = h.Model.CountAllTenants(f)
 	if err != nil {
 		successMessage = ""
-		errMessage = err.Error()
+		_, errMessage = modelError(c, err)
 	}
 
 	tenants, err := h.Model.GetTenantsByPage(p, f)
 	if err != nil {
 		successMessage = ""
-		errMessage = err.Error()
+		_, errMessage = modelError(c, err)
 	}
 
 	refreshTime, err := h.Model.GetDefaultRefreshTime()
@@ -106,15 +113,15 @@ func (h *Handler) ListTenants(c echo.Context, successMessage, errMessage string,
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
-	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.Tenants(c, p, f, tenants, successMessage, errMessage, refreshTime, itemsPerPage, agentsExists, serversExists, confirmDelete, commonInfo), commonInfo))
+	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.Tenants(c, p, f, tenants, successMessage, errMessage, refreshTime, itemsPerPage, agentsExists, serversExists, confirm, commonInfo), commonInfo))
 }
 
 func (h *Handler) NewTenant(c echo.Context) error {
@@ -132,12 +139,12 @@ func (h *Handler) NewTenant(c echo.Context) error {
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	// Get all users for admin selection
@@ -160,7 +167,7 @@ func (h *Handler) AddTenant(c echo.Context) error {
 
 	exists, err := h.Model.TenantNameTaken(name)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_check_name", err.Error()), true))
+		return RenderModelError(c, err)
 	}
 
 	if exists {
@@ -235,10 +242,10 @@ func (h *Handler) EditTenant(c echo.Context) error {
 
 	t, err := h.Model.GetTenantByID(tenantID)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.tenant_not_found", err.Error()), true))
+		return RenderModelError(c, err)
 	}
 
-	if c.Request().Method == "POST" {
+	if c.Request().Method == "POST" || c.Request().Method == "PUT" {
 		name := c.FormValue("name")
 		if name == "" {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.name_cannot_be_empty"), true))
@@ -247,7 +254,7 @@ func (h *Handler) EditTenant(c echo.Context) error {
 		if t.Description != name {
 			exists, err := h.Model.TenantNameTaken(name)
 			if err != nil {
-				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_check_name", err.Error()), true))
+				return RenderModelError(c, err)
 			}
 
 			if exists {
@@ -260,15 +267,42 @@ func (h *Handler) EditTenant(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_bool", err.Error()), true))
 		}
 
+		// The networks allowed to access the tenant are checked before saving anything
+		allowedIPs, err := normalizeCIDRList(c.FormValue("allowed-ips"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.invalid_allowed_ip", err.Error()), true))
+		}
+
+		// Warn the admin if the new networks would lock out the current session
+		if c.FormValue("confirm-lockout") != "true" && !h.wouldKeepTenantAccess(c, t.ID, allowedIPs) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.allowed_ips_lockout", c.RealIP()), true))
+		}
+
 		if err := h.Model.UpdateTenant(t.ID, name, isDefault); err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 
 		// Update OIDC settings
 		oidcOrgID := c.FormValue("oidc-org-id")
 		oidcDefaultRole := c.FormValue("oidc-default-role")
 		if err := h.Model.UpdateTenantOIDC(t.ID, oidcOrgID, oidcDefaultRole); err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
+		}
+
+		// Update the networks allowed to access the tenant
+		networks := []string{}
+		if allowedIPs != "" {
+			networks = strings.Split(allowedIPs, ",")
+		}
+		if err := h.Model.UpdateTenantAllowedIPs(t.ID, networks); err != nil {
+			return RenderModelError(c, err)
+		}
+
+		// Enable the experimental features checked
+		for _, feature := range models.Features {
+			if err := h.Model.SetFeatureEnabled(t.ID, feature, c.FormValue("feature-"+feature) == "on"); err != nil {
+				return RenderModelError(c, err)
+			}
 		}
 
 		return h.ListTenants(c, i18n.T(c.Request().Context(), "tenants.edit_success"), "", false)
@@ -276,20 +310,25 @@ func (h *Handler) EditTenant(c echo.Context) error {
 
 	defaultCountry, err := h.Model.GetDefaultCountry()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
+	}
+
+	features, err := h.Model.GetFeatureFlags(t.ID)
+	if err != nil {
+		return RenderModelError(c, err)
 	}
 
-	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.EditTenant(c, t, defaultCountry, agentsExists, serversExists, commonInfo), commonInfo))
+	return RenderView(c, admin_views.TenantsIndex(" | Tenants", admin_views.EditTenant(c, t, features, defaultCountry, agentsExists, serversExists, commonInfo), commonInfo))
 }
 
 func (h *Handler) DeleteTenant(c echo.Context) error {
@@ -331,12 +370,22 @@ func (h *Handler) DeleteTenant(c echo.Context) error {
 		return h.ListTenants(c, "", i18n.T(c.Request().Context(), "tenants.default_cannot_be_deleted"), false)
 	}
 
-	// Send a request to uninstall agents associated with this organization
+	// Check it before the agents are uninstalled, the model checks it again when the tenant is removed
+	if err := h.Model.CheckMainTenantDeletion(tenantID); err != nil {
+		_, errMessage := modelError(c, err)
+		return h.ListTenants(c, "", errMessage, false)
+	}
+
+	// The agents to uninstall must be the ones shown when the deletion was confirmed
 	agents, err := h.Model.GetAgentsByTenant(tenantID)
 	if err != nil {
 		return h.ListTenants(c, "", i18n.T(c.Request().Context(), "tenants.could_not_get_agents"), false)
 	}
 
+	if err := h.checkDangerousAction(c, tenantDeletionScope(tenantID, agents), t.Description); err != nil {
+		return h.ListTenants(c, "", dangerousActionErrorMessage(c, err), false)
+	}
+
 	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
 		return h.ListTenants(c, "", i18n.T(c.Request().Context(), "nats.not_connected"), false)
 	}
@@ -345,20 +394,80 @@ func (h *Handler) DeleteTenant(c echo.Context) error {
 	defer cancel()
 
 	for _, a := range agents {
-		if _, err := h.JetStream.Publish(ctx, "agent.uninstall."+a.ID, nil); err != nil {
+		if _, err := h.jetStreamPublish(ctx, "agent.uninstall."+a.ID, nil); err != nil {
 			return h.ListTenants(c, "", i18n.T(c.Request().Context(), "agents.could_not_send_request_to_uninstall"), false)
 		}
 	}
 
 	// Remove the tenant with cascade
 	if err := h.Model.DeleteTenant(tenantID); err != nil {
-		return h.ListTenants(c, "", i18n.T(c.Request().Context(), "tenants.delete_error", err.Error()), false)
+		_, errMessage := modelError(c, err)
+		return h.ListTenants(c, "", errMessage, false)
 	}
+	h.invalidateMainTenantID()
 
 	successMessage := i18n.T(c.Request().Context(), "tenants.deleted")
 	return h.ListTenants(c, successMessage, "", false)
 }
 
+// confirmTenantDeletion shows the agents, inventory and sites that are removed with the tenant, its name must be
+// typed to delete it
+func (h *Handler) confirmTenantDeletion(c echo.Context, id string) (*partials.DangerousAction, error) {
+	tenantID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := h.Model.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := h.Model.GetAgentsByTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := tenantDeletionScope(tenantID, agents)
+	inventory, err := h.Model.CountAgentsInventory(scope.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	nSites, err := h.Model.CountSites(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := h.newDangerousAction(c, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &partials.DangerousAction{
+		Title: i18n.T(c.Request().Context(), "tenants.delete_title", t.Description),
+		Consequences: []string{
+			i18n.T(c.Request().Context(), "tenants.delete_agents", len(agents), inventory),
+			i18n.T(c.Request().Context(), "tenants.delete_sites", nSites),
+			i18n.T(c.Request().Context(), "dangerous_action.irreversible"),
+		},
+		Acknowledgement: t.Description,
+		Token:           token,
+		Method:          http.MethodDelete,
+		URL:             fmt.Sprintf("/admin/tenants/%d", tenantID),
+		CancelURL:       "/admin/tenants",
+	}, nil
+}
+
+// tenantDeletionScope is the tenant and the agents that are uninstalled when it's deleted
+func tenantDeletionScope(tenantID int, agents []*ent.Agent) dangerousActionScope {
+	ids := []string{}
+	for _, a := range agents {
+		ids = append(ids, a.ID)
+	}
+	return dangerousActionScope{Action: "delete-tenant", Target: strconv.Itoa(tenantID), Items: ids}
+}
+
 func (h *Handler) ImportTenants(c echo.Context) error {
 	var errors = []string{}
 
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// SetTheme stores the theme chosen by the user in its profile so the pages are
+// rendered with the right theme in any browser
+func (h *Handler) SetTheme(c echo.Context) error {
+	theme := c.FormValue("theme")
+	if !helpers.IsValidTheme(theme) {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "theme.not_supported"), true))
+	}
+
+	uid := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if err := h.Model.UpdateUserTheme(uid, theme); err != nil {
+		log.Printf("[ERROR]: could not save the theme for user %s, reason: %v", uid, err)
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "theme.could_not_save"), true))
+	}
+	h.SessionManager.Manager.Put(c.Request().Context(), "theme", theme)
+
+	return c.NoContent(http.StatusOK)
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
@@ -97,10 +96,10 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 				updateRequest.UpdateNow = true
 			} else {
 				scheduledTime := c.FormValue("update-agent-date")
-				updateRequest.UpdateAt, err = time.ParseInLocation("2006-01-02T15:04", scheduledTime, time.Local)
+				updateRequest.UpdateAt, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04", scheduledTime)
 				if err != nil {
 					log.Println("[INFO]: could not parse scheduled time as 24h time")
-					updateRequest.UpdateAt, err = time.Parse("2006-01-02T15:04PM", scheduledTime)
+					updateRequest.UpdateAt, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04PM", scheduledTime)
 					if err != nil {
 						log.Println("[INFO]: could not parse scheduled time as AM/PM time")
 						// Fallback to update now
@@ -126,7 +125,7 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 				continue
 			}
 
-			if _, err := h.JetStream.Publish(context.Background(), "agent.update."+a, data); err != nil {
+			if _, err := h.jetStreamPublish(context.Background(), "agent.update."+a, data); err != nil {
 				errorMessage = i18n.T(c.Request().Context(), "admin.update.agents.cannot_send_request")
 				if err := h.Model.SaveAgentUpdateInfo(a, "admin.update.agents.task_status_error", "admin.update.agents.cannot_send_request", releaseToBeApplied.Version, commonInfo); err != nil {
 					log.Println("[ERROR]: could not save update task info")
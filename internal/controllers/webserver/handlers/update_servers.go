@@ -7,7 +7,6 @@ import (
 	"log"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
@@ -39,6 +38,12 @@ func (h *Handler) UpdateServers(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
+		// The scheduled date is entered in the timezone of the user
+		commonInfo, err := h.GetCommonInfo(c)
+		if err != nil {
+			return err
+		}
+
 		servers := c.FormValue("servers")
 		if servers == "" {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "admin.update.servers.servers_cant_be_empty"), false))
@@ -77,10 +82,10 @@ func (h *Handler) UpdateServers(c echo.Context) error {
 				updateRequest.UpdateNow = true
 			} else {
 				scheduledTime := c.FormValue("update-server-date")
-				updateRequest.UpdateAt, err = time.ParseInLocation("2006-01-02T15:04", scheduledTime, time.Local)
+				updateRequest.UpdateAt, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04", scheduledTime)
 				if err != nil {
 					log.Println("[INFO]: could not parse scheduled time as 24h time")
-					updateRequest.UpdateAt, err = time.Parse("2006-01-02T15:04PM", scheduledTime)
+					updateRequest.UpdateAt, err = commonInfo.Dates.ParseLocal("2006-01-02T15:04PM", scheduledTime)
 					if err != nil {
 						log.Println("[INFO]: could not parse scheduled time as AM/PM time")
 						// Fallback to update now
@@ -106,7 +111,7 @@ func (h *Handler) UpdateServers(c echo.Context) error {
 				continue
 			}
 
-			if _, err := h.JetStream.Publish(context.Background(), "server.update."+serverInfo.Hostname, data); err != nil {
+			if _, err := h.jetStreamPublish(context.Background(), "server.update."+serverInfo.Hostname, data); err != nil {
 				errorMessage = i18n.T(c.Request().Context(), "admin.update.servers.cannot_send_request")
 				if err := h.Model.SaveServerUpdateInfo(serverId, server.UpdateStatusError, errorMessage, releaseToBeApplied.Version); err != nil {
 					log.Println("[ERROR]: could not save update task info")
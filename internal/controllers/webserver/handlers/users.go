@@ -17,6 +17,7 @@ import (
 	"github.com/labstack/echo/v4"
 	openuem_ent "github.com/open-uem/ent"
 	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/auth"
 	"github.com/open-uem/openuem-console/internal/views/admin_views"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -108,13 +109,13 @@ func (h *Handler) ListUsers(c echo.Context, successMessage, errMessage string) e
 	p.NItems, err = h.Model.CountAllUsers(f, tenantID)
 	if err != nil {
 		successMessage = ""
-		errMessage = err.Error()
+		_, errMessage = modelError(c, err)
 	}
 
 	users, err := h.Model.GetUsersByPage(p, f, tenantID)
 	if err != nil {
 		successMessage = ""
-		errMessage = err.Error()
+		_, errMessage = modelError(c, err)
 	}
 
 	refreshTime, err := h.Model.GetDefaultRefreshTime()
@@ -125,12 +126,12 @@ func (h *Handler) ListUsers(c echo.Context, successMessage, errMessage string) e
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	warnAboutSMTP := h.Model.IsPasswdAuthEnabled() && !h.Model.IsSMTPConfigured()
@@ -158,12 +159,12 @@ func (h *Handler) NewUser(c echo.Context) error {
 
 	agentsExists, err := h.Model.AgentsExists(commonInfo)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	serversExists, err := h.Model.ServersExists()
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	return RenderView(c, admin_views.UsersIndex(" | Users", admin_views.NewUser(c, defaultCountry, agentsExists, serversExists, commonInfo, settings), commonInfo))
@@ -176,46 +177,46 @@ func (h *Handler) AddUser(c echo.Context) error {
 
 	decoder := form.NewDecoder()
 	if err := c.Request().ParseForm(); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 	err := decoder.Decode(&u, c.Request().Form)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	if err := validate.Struct(u); err != nil {
 		// TODO Try to translate and create a nice error message
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	exists, err := h.Model.UserExists(u.UID)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		return RenderModelError(c, err)
 	}
 
 	if exists {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.user_id_exists"), true))
 	}
 
-	if !slices.Contains([]string{admin_views.CERTIFICATES_AUTH, admin_views.PASSWORD_AUTH, admin_views.OIDC_AUTH}, u.AuthType) {
+	if !slices.Contains([]string{auth.CERTIFICATES_AUTH, auth.PASSWORD_AUTH, auth.OIDC_AUTH}, u.AuthType) {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.invalid_type", u.AuthType), true))
 	}
 
 	addedUser, err := h.Model.AddUser(u.UID, u.Name, u.Email, u.Phone, u.Country, u.AuthType)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	switch u.AuthType {
-	case admin_views.CERTIFICATES_AUTH:
+	case auth.CERTIFICATES_AUTH:
 		if err := h.sendConfirmationEmail(c, addedUser); err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 		successMessage = i18n.T(c.Request().Context(), "new.user.success")
-	case admin_views.OIDC_AUTH:
+	case auth.OIDC_AUTH:
 		successMessage = i18n.T(c.Request().Context(), "new.user.success_oidc")
-	case admin_views.PASSWORD_AUTH:
+	case auth.PASSWORD_AUTH:
 		if err := h.sendLinkToGeneratePassword(c, addedUser); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.could_not_send_new_account_email"), false))
 		}
@@ -231,11 +232,11 @@ func (h *Handler) RequestUserCertificate(c echo.Context) error {
 
 	user, err := h.Model.GetUserById(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	if err := h.SendCertificateRequestToNATS(c, user); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	successMessage := i18n.T(c.Request().Context(), "users.certificate_requested")
@@ -272,7 +273,7 @@ func (h *Handler) SendCertificateRequestToNATS(c echo.Context, user *openuem_ent
 		return fmt.Errorf("%s", i18n.T(c.Request().Context(), "nats.not_connected"))
 	}
 
-	if err := h.NATSConnection.Publish("certificates.user", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "certificates.user", data); err != nil {
 		return err
 	}
 	return nil
@@ -286,31 +287,31 @@ func (h *Handler) DeleteUser(c echo.Context) error {
 	}
 	_, err := h.Model.GetUserById(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	// Delete user
 	if err := h.Model.DeleteUser(uid); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	// Revoke certificate
 	cert, err := h.Model.GetCertificateByUID(uid)
 	if err != nil {
 		if !openuem_ent.IsNotFound(err) {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 		successMessage := i18n.T(c.Request().Context(), "users.deleted")
 		return h.ListUsers(c, successMessage, "")
 	}
 
 	if err := h.Model.RevokeCertificate(cert, "user has been deleted", ocsp.CessationOfOperation); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	// Delete certificate information
 	if err := h.Model.DeleteCertificate(cert.ID); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	successMessage := i18n.T(c.Request().Context(), "users.deleted")
@@ -321,7 +322,7 @@ func (h *Handler) RenewUserCertificate(c echo.Context) error {
 	uid := c.Param("uid")
 	user, err := h.Model.GetUserById(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	// Revoke certificate if exists
@@ -330,12 +331,12 @@ func (h *Handler) RenewUserCertificate(c echo.Context) error {
 		log.Printf("[INFO]: could not revoke certificate, no certificate was found for user %s", uid)
 	} else {
 		if err := h.Model.RevokeCertificate(cert, "a new certificate has been requested", ocsp.CessationOfOperation); err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 
 		// Now delete certificate
 		if err := h.Model.DeleteCertificate(cert.ID); err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 	}
 
@@ -355,15 +356,15 @@ func (h *Handler) RenewUserCertificate(c echo.Context) error {
 
 	data, err := json.Marshal(certRequest)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	if err := h.NATSConnection.Publish("certificates.user", data); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	if err := h.natsPublish(c.Request().Context(), "certificates.user", data); err != nil {
+		return RenderModelError(c, err)
 	}
 
 	successMessage := i18n.T(c.Request().Context(), "users.certificate_requested")
@@ -374,7 +375,7 @@ func (h *Handler) SetEmailConfirmed(c echo.Context) error {
 	uid := c.Param("uid")
 	exists, err := h.Model.UserExists(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	if !exists {
@@ -383,7 +384,7 @@ func (h *Handler) SetEmailConfirmed(c echo.Context) error {
 
 	err = h.Model.Client.User.UpdateOneID(uid).SetEmailVerified(true).SetRegister(openuem_nats.REGISTER_IN_REVIEW).Exec(context.Background())
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	return h.ListUsers(c, i18n.T(c.Request().Context(), "users.email_confirmed"), "")
@@ -393,7 +394,7 @@ func (h *Handler) ApproveAccount(c echo.Context) error {
 	uid := c.Param("uid")
 	exists, err := h.Model.UserExists(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	if !exists {
@@ -408,6 +409,28 @@ func (h *Handler) ApproveAccount(c echo.Context) error {
 	return h.ListUsers(c, i18n.T(c.Request().Context(), "users.approved"), "")
 }
 
+// ForceUserPasswordChange makes a local user change the password the next time it logs in
+func (h *Handler) ForceUserPasswordChange(c echo.Context) error {
+	uid := c.Param("uid")
+	u, err := h.Model.GetUserById(uid)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "users.user_not_found"), true))
+	}
+
+	if !u.Passwd || u.Ldap {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "users.not_a_password_account"), true))
+	}
+
+	if err := h.Model.ForcePasswordChange(uid); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "users.could_not_update_register", err.Error()), true))
+	}
+
+	username := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	h.AuthLogger.Printf("user %s has forced user %s to change the password from %s", username, uid, c.RealIP())
+
+	return h.ListUsers(c, i18n.T(c.Request().Context(), "users.password_change_forced"), "")
+}
+
 func (h *Handler) ResendPasswordLink(c echo.Context) error {
 	uid := c.Param("uid")
 	u, err := h.Model.GetUserById(uid)
@@ -431,11 +454,11 @@ func (h *Handler) AskForConfirmation(c echo.Context) error {
 	uid := c.Param("uid")
 	user, err := h.Model.GetUserById(uid)
 	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	if err := h.sendConfirmationEmail(c, user); err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		return RenderModelError(c, err)
 	}
 
 	return h.ListUsers(c, i18n.T(c.Request().Context(), "users.new_confirmation_email_sent", user.Email), "")
@@ -466,7 +489,7 @@ func (h *Handler) sendConfirmationEmail(c echo.Context, user *openuem_ent.User)
 		return fmt.Errorf("%s", i18n.T(c.Request().Context(), "nats.not_connected"))
 	}
 
-	if err := h.NATSConnection.Publish("notification.confirm_email", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "notification.confirm_email", data); err != nil {
 		return err
 	}
 
@@ -502,7 +525,7 @@ func (h *Handler) sendLinkToGeneratePassword(c echo.Context, user *openuem_ent.U
 		return fmt.Errorf("%s", i18n.T(c.Request().Context(), "nats.not_connected"))
 	}
 
-	if err := h.NATSConnection.Publish("notification.confirm_email", data); err != nil {
+	if err := h.natsPublish(c.Request().Context(), "notification.confirm_email", data); err != nil {
 		return err
 	}
 
@@ -534,7 +557,7 @@ func (h *Handler) ImportUsers(c echo.Context) error {
 			break
 		}
 		if err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+			return RenderModelError(c, err)
 		}
 
 		user := openuem_ent.User{}
@@ -570,7 +593,7 @@ func (h *Handler) ImportUsers(c echo.Context) error {
 
 		authType := record[5]
 
-		if !slices.Contains([]string{admin_views.CERTIFICATES_AUTH, admin_views.PASSWORD_AUTH, admin_views.OIDC_AUTH}, authType) {
+		if !slices.Contains([]string{auth.CERTIFICATES_AUTH, auth.PASSWORD_AUTH, auth.OIDC_AUTH}, authType) {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "authentication.invalid_type"), false))
 		}
 
@@ -583,12 +606,12 @@ func (h *Handler) ImportUsers(c echo.Context) error {
 		}
 
 		switch authType {
-		case admin_views.CERTIFICATES_AUTH:
+		case auth.CERTIFICATES_AUTH:
 			u.CertClearPassword = pkcs12.DefaultPassword
 			if err := h.SendCertificateRequestToNATS(c, u); err != nil {
 				errors = append(errors, err.Error())
 			}
-		case admin_views.PASSWORD_AUTH:
+		case auth.PASSWORD_AUTH:
 			if err := h.sendLinkToGeneratePassword(c, u); err != nil {
 				errors = append(errors, err.Error())
 			}
@@ -1,6 +1,7 @@
 package webserver
 
 import (
+	"context"
 	"log"
 	"net/http"
 
@@ -19,7 +20,7 @@ type WebServer struct {
 	SessionManager *sessions.SessionManager
 }
 
-func New(m *models.Model, natsServers string, s *sessions.SessionManager, ts gocron.Scheduler, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, agentCertPath, agentKeyPath, sftpCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, version string, reEnableCertAuth, reEnablePasswdAuth, reOpenUEMUser bool, authLogger *log.Logger) *WebServer {
+func New(m *models.Model, natsServers string, s *sessions.SessionManager, ts gocron.Scheduler, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, agentCertPath, agentKeyPath, sftpCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, trustedProxies, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, version string, reEnableCertAuth, reEnablePasswdAuth, reOpenUEMUser bool, authLogger *log.Logger) *WebServer {
 	var err error
 	w := WebServer{}
 
@@ -31,7 +32,7 @@ func New(m *models.Model, natsServers string, s *sessions.SessionManager, ts goc
 	}
 
 	// Router
-	w.Router = router.New(s, server, consolePort, maxUploadSize)
+	w.Router = router.New(m, s, server, consolePort, maxUploadSize, trustedProxies)
 
 	// Create Handler and register its router
 	w.Handler = handlers.NewHandler(m, natsServers, s, ts, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, agentCertPath, agentKeyPath, sftpCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, version, reEnableCertAuth, reEnablePasswdAuth, authLogger)
@@ -52,6 +53,20 @@ func (w *WebServer) Serve(address, certFile, certKey string) error {
 	return w.Server.ListenAndServeTLS(certFile, certKey)
 }
 
+// Shutdown stops accepting new connections and waits for the in-flight requests, like
+// downloads and report exports, to finish until the context expires. Then the handler
+// closes its NATS connection
+func (w *WebServer) Shutdown(ctx context.Context) error {
+	if w.Handler != nil {
+		defer w.Handler.Close()
+	}
+
+	if w.Server == nil {
+		return nil
+	}
+	return w.Server.Shutdown(ctx)
+}
+
 func (w *WebServer) Close() error {
 	return w.Server.Close()
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/tenant"
+)
+
+// GetAdminAllowlist returns the comma-separated list of CIDRs allowed to reach the admin area.
+// A tenantID of -1 returns the global allowlist. The settings of a tenant are created the first time
+// they're saved, a tenant without them has no allowlist
+func (m *Model) GetAdminAllowlist(tenantID int) (string, error) {
+	query := m.Client.Settings.Query().Select(settings.FieldAdminAllowlist)
+
+	if tenantID == -1 {
+		query.Where(settings.Not(settings.HasTenant()))
+	} else {
+		query.Where(settings.HasTenantWith(tenant.ID(tenantID)))
+	}
+
+	s, err := query.Only(context.Background())
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return s.AdminAllowlist, nil
+}
+
+// SaveAdminAllowlist stores the admin allowlist. A tenantID of -1 updates the global allowlist
+func (m *Model) SaveAdminAllowlist(tenantID int, allowlist string) error {
+	if err := m.ensureAdminAllowlistSettings(tenantID); err != nil {
+		return err
+	}
+
+	query := m.Client.Settings.Update().SetAdminAllowlist(allowlist)
+
+	if tenantID == -1 {
+		query.Where(settings.Not(settings.HasTenant()))
+	} else {
+		query.Where(settings.HasTenantWith(tenant.ID(tenantID)))
+	}
+
+	return query.Exec(context.Background())
+}
+
+// GetAdminAllowlistExemption returns if main tenant admins can bypass the admin allowlists (break-glass)
+func (m *Model) GetAdminAllowlistExemption() (bool, error) {
+	s, err := m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Select(settings.FieldAdminAllowlistExemptMainAdmins).Only(context.Background())
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return s.AdminAllowlistExemptMainAdmins, nil
+}
+
+// UpdateAdminAllowlistExemption sets if main tenant admins can bypass the admin allowlists
+func (m *Model) UpdateAdminAllowlistExemption(exempt bool) error {
+	if err := m.ensureAdminAllowlistSettings(-1); err != nil {
+		return err
+	}
+	return m.Client.Settings.Update().Where(settings.Not(settings.HasTenant())).SetAdminAllowlistExemptMainAdmins(exempt).Exec(context.Background())
+}
+
+// ensureAdminAllowlistSettings creates the settings of the tenant, or the global ones for -1, if they don't
+// exist yet so the allowlist isn't lost when it's saved
+func (m *Model) ensureAdminAllowlistSettings(tenantID int) error {
+	query := m.Client.Settings.Query()
+	if tenantID == -1 {
+		query.Where(settings.Not(settings.HasTenant()))
+	} else {
+		query.Where(settings.HasTenantWith(tenant.ID(tenantID)))
+	}
+
+	exists, err := query.Exist(context.Background())
+	if err != nil || exists {
+		return err
+	}
+
+	if tenantID == -1 {
+		return m.Client.Settings.Create().Exec(context.Background())
+	}
+	return m.CloneGlobalSettings(tenantID)
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/certificate"
+	"github.com/open-uem/ent/revocation"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+)
+
+// The status of the certificate that an agent uses to connect to NATS
+const (
+	AgentCertificateValid   = "valid"
+	AgentCertificateExpired = "expired"
+	AgentCertificateRevoked = "revoked"
+	// AgentCertificatePending is a certificate that has been requested to the certificate manager but not issued yet
+	AgentCertificatePending = "pending"
+	// AgentCertificateUnknown is an agent whose certificate is not recorded by the console
+	AgentCertificateUnknown = "unknown"
+)
+
+// The types of the events recorded when the certificate of an agent is revoked or issued again
+const (
+	AgentEventCertificateRevoked  = "certificate_revoked"
+	AgentEventCertificateReissued = "certificate_reissued"
+)
+
+// AgentCertificate is the status of the certificate of an agent
+type AgentCertificate struct {
+	Status      string
+	Serial      int64
+	Expiry      time.Time
+	RevokedAt   *time.Time
+	RequestedAt *time.Time
+}
+
+// GetAgentCertificate returns the status of the certificate of the agent. A revoked agent stays revoked until
+// a new certificate is requested, which is pending until the certificate manager records the new certificate
+func (m *Model) GetAgentCertificate(a *ent.Agent) (*AgentCertificate, error) {
+	status := AgentCertificate{Status: AgentCertificateUnknown, RevokedAt: a.CertificateRevokedAt, RequestedAt: a.CertificateRequestedAt}
+	if a.CertificateRevokedAt != nil {
+		status.Status = AgentCertificateRevoked
+		return &status, nil
+	}
+
+	cert, err := m.Client.Certificate.Query().Where(certificate.UID(a.ID)).Order(ent.Desc(certificate.FieldExpiry)).First(context.Background())
+	if err != nil {
+		if !ent.IsNotFound(err) {
+			return nil, err
+		}
+		if a.CertificateRequestedAt != nil {
+			status.Status = AgentCertificatePending
+		}
+		return &status, nil
+	}
+
+	status.Serial = cert.ID
+	status.Expiry = cert.Expiry
+	status.Status = AgentCertificateValid
+	if cert.Expiry.Before(time.Now()) {
+		status.Status = AgentCertificateExpired
+	}
+	return &status, nil
+}
+
+// RevokeAgentCertificates revokes the certificates of the agent of the tenant, so the OCSP responder reports
+// them as revoked and the agent can't connect to NATS anymore. The reason is recorded in an event of the agent
+func (m *Model) RevokeAgentCertificates(agentID string, tenantID int, reason string, ocspReason int) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	a, err := tx.Agent.Query().Where(agent.ID(agentID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Only(ctx)
+	if err != nil {
+		return dbError(rollback(tx, err))
+	}
+
+	if err := revokeAgentCertificates(ctx, tx, a.ID, tenantID, reason, ocspReason); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// GetTenantAgentsToRevoke returns the agents of the tenant whose certificates haven't been revoked yet, sorted
+func (m *Model) GetTenantAgentsToRevoke(tenantID int) ([]string, error) {
+	return m.Client.Agent.Query().
+		Where(agent.CertificateRevokedAtIsNil(), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+		Order(ent.Asc(agent.FieldID)).
+		IDs(context.Background())
+}
+
+// RevokeTenantAgentCertificates revokes the certificates of the agents of the tenant that haven't been
+// revoked yet, e.g. when the tenant is offboarded. Only the agents given are revoked, those the admin has
+// confirmed. It returns how many agents have been revoked
+func (m *Model) RevokeTenantAgentCertificates(tenantID int, agentIDs []string, reason string, ocspReason int) (int, error) {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := tx.Agent.Query().Where(agent.IDIn(agentIDs...), agent.CertificateRevokedAtIsNil(), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).IDs(ctx)
+	if err != nil {
+		return 0, rollback(tx, err)
+	}
+
+	for _, id := range ids {
+		if err := revokeAgentCertificates(ctx, tx, id, tenantID, reason, ocspReason); err != nil {
+			return 0, rollback(tx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// SetAgentCertificateRequested records that a new certificate has been requested for the agent of the tenant,
+// it's no longer revoked but pending until the certificate manager issues the certificate
+func (m *Model) SetAgentCertificateRequested(agentID string, tenantID int, reason string) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	n, err := tx.Agent.Update().
+		Where(agent.ID(agentID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+		ClearCertificateRevokedAt().
+		SetCertificateRequestedAt(now).
+		Save(ctx)
+	if err != nil {
+		return rollback(tx, err)
+	}
+	if n == 0 {
+		return rollback(tx, ErrNotFound)
+	}
+
+	if err := tx.AgentEvent.Create().SetAgentID(agentID).SetTenantID(tenantID).SetType(AgentEventCertificateReissued).SetReason(reason).SetCreatedAt(now).Exec(ctx); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// revokeAgentCertificates adds the certificates of the agent to the revocation list and removes them, as the
+// certificates revoked by an admin. A certificate that was already in the revocation list is only removed
+func revokeAgentCertificates(ctx context.Context, tx *ent.Tx, agentID string, tenantID int, reason string, ocspReason int) error {
+	certs, err := tx.Certificate.Query().Where(certificate.UID(agentID)).All(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, cert := range certs {
+		exists, err := tx.Revocation.Query().Where(revocation.ID(cert.ID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := tx.Revocation.Create().SetID(cert.ID).SetExpiry(cert.Expiry).SetRevoked(now).SetReason(ocspReason).SetInfo(reason).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Certificate.DeleteOneID(cert.ID).Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Agent.UpdateOneID(agentID).SetCertificateRevokedAt(now).ClearCertificateRequestedAt().Exec(ctx); err != nil {
+		return err
+	}
+
+	return tx.AgentEvent.Create().SetAgentID(agentID).SetTenantID(tenantID).SetType(AgentEventCertificateRevoked).SetReason(reason).SetCreatedAt(now).Exec(ctx)
+}
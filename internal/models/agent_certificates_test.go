@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentevent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/ent/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ocsp"
+)
+
+type AgentCertificatesTestSuite struct {
+	suite.Suite
+	t           enttest.TestingT
+	model       Model
+	tenantID    int
+	otherTenant int
+}
+
+func (suite *AgentCertificatesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	other, err := client.Tenant.Create().SetDescription("Other").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create other tenant")
+	suite.otherTenant = other.ID
+
+	otherSite, err := client.Site.Create().SetDescription("Other").SetTenantID(other.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site of other tenant")
+
+	for i, id := range []string{"agent1", "agent2", "other1"} {
+		siteID := s.ID
+		if id == "other1" {
+			siteID = otherSite.ID
+		}
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).AddSiteIDs(siteID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.Certificate.Create().SetID(int64(i + 1)).SetType("agent").SetDescription(id).SetExpiry(time.Now().AddDate(1, 0, 0)).SetUID(id).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create certificate")
+	}
+}
+
+func (suite *AgentCertificatesTestSuite) status(id string) string {
+	a, err := suite.model.Client.Agent.Get(context.Background(), id)
+	assert.NoError(suite.T(), err)
+	cert, err := suite.model.GetAgentCertificate(a)
+	assert.NoError(suite.T(), err)
+	return cert.Status
+}
+
+func (suite *AgentCertificatesTestSuite) TestRevokeAndReissue() {
+	assert.Equal(suite.T(), AgentCertificateValid, suite.status("agent1"))
+
+	err := suite.model.RevokeAgentCertificates("agent1", suite.otherTenant, "stolen", ocsp.KeyCompromise)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not revoke the agents of other tenants")
+
+	err = suite.model.RevokeAgentCertificates("agent1", suite.tenantID, "stolen", ocsp.KeyCompromise)
+	assert.NoError(suite.T(), err, "should revoke the certificate")
+	assert.Equal(suite.T(), AgentCertificateRevoked, suite.status("agent1"))
+	assert.Equal(suite.T(), AgentCertificateValid, suite.status("agent2"), "should only revoke the certificate of the agent")
+
+	r, err := suite.model.Client.Revocation.Query().Where(revocation.ID(1)).Only(context.Background())
+	assert.NoError(suite.T(), err, "should add the certificate to the revocation list")
+	assert.Equal(suite.T(), ocsp.KeyCompromise, r.Reason)
+
+	err = suite.model.SetAgentCertificateRequested("agent1", suite.tenantID, "recovered")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), AgentCertificatePending, suite.status("agent1"), "should wait for the new certificate")
+
+	err = suite.model.Client.Certificate.Create().SetID(10).SetType("agent").SetDescription("agent1").SetExpiry(time.Now().AddDate(2, 0, 0)).SetUID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), AgentCertificateValid, suite.status("agent1"), "should be valid once the certificate is issued")
+
+	err = suite.model.SetAgentCertificateRequested("other1", suite.tenantID, "recovered")
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not request certificates for the agents of other tenants")
+
+	events, err := suite.model.Client.AgentEvent.Query().Where(agentevent.AgentID("agent1")).Order(ent.Asc(agentevent.FieldID)).All(context.Background())
+	assert.NoError(suite.T(), err)
+	if assert.Equal(suite.T(), 2, len(events), "should record the revocation and the new certificate") {
+		assert.Equal(suite.T(), AgentEventCertificateRevoked, events[0].Type)
+		assert.Equal(suite.T(), "stolen", events[0].Reason)
+		assert.Equal(suite.T(), AgentEventCertificateReissued, events[1].Type)
+	}
+}
+
+func (suite *AgentCertificatesTestSuite) TestRevokeTenantAgentCertificates() {
+	err := suite.model.RevokeAgentCertificates("agent1", suite.tenantID, "stolen", ocsp.KeyCompromise)
+	assert.NoError(suite.T(), err)
+
+	ids, err := suite.model.GetTenantAgentsToRevoke(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"agent2"}, ids, "should skip the agents already revoked")
+
+	count, err := suite.model.RevokeTenantAgentCertificates(suite.tenantID, []string{"agent1", "agent2", "other1"}, "offboarding", ocsp.CessationOfOperation)
+	assert.NoError(suite.T(), err, "should revoke the certificates of the tenant")
+	assert.Equal(suite.T(), 1, count, "should skip the agents already revoked")
+
+	assert.Equal(suite.T(), AgentCertificateRevoked, suite.status("agent2"))
+	assert.Equal(suite.T(), AgentCertificateValid, suite.status("other1"), "should not revoke the agents of other tenants")
+
+	n, err := suite.model.Client.Revocation.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, n)
+}
+
+func TestAgentCertificatesTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentCertificatesTestSuite))
+}
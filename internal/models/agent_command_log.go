@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentcommandlog"
+)
+
+// maxAgentCommandLength is the maximum number of characters of a command stored in the audit trail
+const maxAgentCommandLength = 4096
+
+// SaveAgentCommandLog stores a remote command executed on an agent. Only a SHA-256 hash of the
+// command's standard output is kept so the audit trail doesn't store sensitive output
+func (m *Model) SaveAgentCommandLog(agentID string, tenantID int, userID, command string, exitCode int, stdout []byte) error {
+	if runes := []rune(command); len(runes) > maxAgentCommandLength {
+		command = string(runes[:maxAgentCommandLength])
+	}
+
+	hash := sha256.Sum256(stdout)
+
+	return m.Client.AgentCommandLog.Create().
+		SetAgentID(agentID).
+		SetTenantID(tenantID).
+		SetUserID(userID).
+		SetCommand(command).
+		SetExitCode(exitCode).
+		SetOutputHash(hex.EncodeToString(hash[:])).
+		SetExecutedAt(time.Now()).
+		Exec(context.Background())
+}
+
+// GetAgentCommandLogs returns a page of the commands executed on an agent, newest first, and the total number of commands
+func (m *Model) GetAgentCommandLogs(agentID string, page, size int) ([]*ent.AgentCommandLog, int, error) {
+	query := m.Client.AgentCommandLog.Query().Where(agentcommandlog.AgentID(agentID))
+
+	total, err := query.Clone().Count(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	logs, err := query.
+		Order(ent.Desc(agentcommandlog.FieldExecutedAt), ent.Desc(agentcommandlog.FieldID)).
+		Limit(size).
+		Offset((page - 1) * size).
+		All(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentCommandLogTestSuite struct {
+	suite.Suite
+	t     enttest.TestingT
+	model Model
+}
+
+func (suite *AgentCommandLogTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	for i := 0; i <= 6; i++ {
+		err := suite.model.SaveAgentCommandLog("agent1", 1, "admin", fmt.Sprintf("command%d", i), i, []byte(fmt.Sprintf("output%d", i)))
+		assert.NoError(suite.T(), err, "should save command log")
+	}
+
+	err := suite.model.SaveAgentCommandLog("agent2", 1, "admin", "whoami", 0, []byte("admin"))
+	assert.NoError(suite.T(), err, "should save command log")
+}
+
+func (suite *AgentCommandLogTestSuite) TestSaveAgentCommandLog() {
+	command := strings.Repeat("é", maxAgentCommandLength+10)
+	err := suite.model.SaveAgentCommandLog("agent3", 1, "admin", command, 1, []byte("output"))
+	assert.NoError(suite.T(), err, "should save command log")
+
+	logs, total, err := suite.model.GetAgentCommandLogs("agent3", 1, 5)
+	assert.NoError(suite.T(), err, "should get command logs")
+	assert.Equal(suite.T(), 1, total)
+	assert.Equal(suite.T(), maxAgentCommandLength, len([]rune(logs[0].Command)), "should truncate the command")
+	assert.Equal(suite.T(), 1, logs[0].ExitCode)
+	assert.Equal(suite.T(), "admin", logs[0].UserID)
+	assert.Equal(suite.T(), 1, logs[0].TenantID)
+
+	hash := sha256.Sum256([]byte("output"))
+	assert.Equal(suite.T(), hex.EncodeToString(hash[:]), logs[0].OutputHash, "should store the SHA-256 of the output")
+}
+
+func (suite *AgentCommandLogTestSuite) TestGetAgentCommandLogs() {
+	logs, total, err := suite.model.GetAgentCommandLogs("agent1", 1, 5)
+	assert.NoError(suite.T(), err, "should get first page")
+	assert.Equal(suite.T(), 7, total, "should count all the commands for the agent")
+	assert.Equal(suite.T(), 5, len(logs), "should get a page of commands")
+	assert.Equal(suite.T(), "command6", logs[0].Command, "should get newest commands first")
+
+	logs, total, err = suite.model.GetAgentCommandLogs("agent1", 2, 5)
+	assert.NoError(suite.T(), err, "should get second page")
+	assert.Equal(suite.T(), 7, total)
+	assert.Equal(suite.T(), 2, len(logs), "should get the remaining commands")
+	assert.Equal(suite.T(), "command0", logs[1].Command)
+
+	logs, total, err = suite.model.GetAgentCommandLogs("agent4", 1, 5)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, total, "agent without commands should have no logs")
+	assert.Equal(suite.T(), 0, len(logs))
+}
+
+func TestAgentCommandLogTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentCommandLogTestSuite))
+}
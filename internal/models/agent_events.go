@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+)
+
+// AgentEventDecommissioned is the type of the event recorded when an agent is decommissioned
+const AgentEventDecommissioned = "decommissioned"
+
+// BulkDecommissionAgents sets the agents of the tenant as decommissioned and records the reason in an
+// event for each of them. Agents of other tenants or already decommissioned are skipped, it returns
+// how many agents have been decommissioned
+func (m *Model) BulkDecommissionAgents(agentIDs []string, tenantID int, reason string) (int, error) {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	agentIDs = slices.Compact(slices.Sorted(slices.Values(agentIDs)))
+	if len(agentIDs) == 0 {
+		return 0, nil
+	}
+
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := tx.Agent.Query().
+		Where(
+			agent.IDIn(agentIDs...),
+			agent.AgentStatusNEQ(agent.AgentStatusDecommissioned),
+			agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))),
+		).
+		IDs(ctx)
+	if err != nil {
+		return 0, rollback(tx, err)
+	}
+	if len(ids) == 0 {
+		return 0, tx.Rollback()
+	}
+
+	if err := tx.Agent.Update().Where(agent.IDIn(ids...)).SetAgentStatus(agent.AgentStatusDecommissioned).Exec(ctx); err != nil {
+		return 0, rollback(tx, err)
+	}
+
+	now := time.Now()
+	events := make([]*ent.AgentEventCreate, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, tx.AgentEvent.Create().
+			SetAgentID(id).
+			SetTenantID(tenantID).
+			SetType(AgentEventDecommissioned).
+			SetReason(reason).
+			SetCreatedAt(now))
+	}
+	if err := tx.AgentEvent.CreateBulk(events...).Exec(ctx); err != nil {
+		return 0, rollback(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentevent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentEventsTestSuite struct {
+	suite.Suite
+	t           enttest.TestingT
+	model       Model
+	tenantID    int
+	otherTenant int
+}
+
+func (suite *AgentEventsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	other, err := client.Tenant.Create().SetDescription("Other").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create other tenant")
+	suite.otherTenant = other.ID
+
+	otherSite, err := client.Site.Create().SetDescription("Other").SetTenantID(other.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site of other tenant")
+
+	for _, id := range []string{"agent1", "agent2", "agent3"} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	err = client.Agent.Create().SetID("other1").SetHostname("other1").SetOs("windows").SetNickname("other1").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(otherSite.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent of other tenant")
+}
+
+func (suite *AgentEventsTestSuite) TestBulkDecommissionAgents() {
+	count, err := suite.model.BulkDecommissionAgents([]string{"agent1", "agent2", "agent2", "other1", "unknown"}, suite.tenantID, "hardware replaced")
+	assert.NoError(suite.T(), err, "should decommission agents")
+	assert.Equal(suite.T(), 2, count, "should only decommission the agents of the tenant")
+
+	for id, status := range map[string]agent.AgentStatus{
+		"agent1": agent.AgentStatusDecommissioned,
+		"agent2": agent.AgentStatusDecommissioned,
+		"agent3": agent.AgentStatusEnabled,
+		"other1": agent.AgentStatusEnabled,
+	} {
+		a, err := suite.model.Client.Agent.Get(context.Background(), id)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), status, a.AgentStatus, "unexpected status of %s", id)
+	}
+
+	events, err := suite.model.Client.AgentEvent.Query().Where(agentevent.TenantID(suite.tenantID)).All(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(events), "should record an event for each agent")
+	for _, e := range events {
+		assert.Equal(suite.T(), AgentEventDecommissioned, e.Type)
+		assert.Equal(suite.T(), "hardware replaced", e.Reason)
+		assert.False(suite.T(), e.CreatedAt.IsZero(), "should record when the agent was decommissioned")
+	}
+
+	count, err = suite.model.BulkDecommissionAgents([]string{"agent1"}, suite.tenantID, "again")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count, "should skip agents already decommissioned")
+
+	total, err := suite.model.Client.AgentEvent.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, total, "should not record events for skipped agents")
+}
+
+func (suite *AgentEventsTestSuite) TestBulkDecommissionNoAgents() {
+	count, err := suite.model.BulkDecommissionAgents(nil, suite.tenantID, "none")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	count, err = suite.model.BulkDecommissionAgents([]string{"agent1"}, suite.otherTenant, "wrong tenant")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count, "should not decommission agents of other tenants")
+}
+
+func TestAgentEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentEventsTestSuite))
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentlogcollection"
+)
+
+// DefaultAgentLogCollectionTimeout is how long a log collection waits for an offline agent if the setting isn't valid
+const DefaultAgentLogCollectionTimeout = 24 * time.Hour
+
+// maxAgentLogCollectionsShown is the number of log collections of an agent shown to the operators
+const maxAgentLogCollectionsShown = 10
+
+// CreateAgentLogCollection queues a request to collect the logs of an agent. The request waits until
+// the deadline for the agent to be online, only one request of an agent can be pending at a time
+func (m *Model) CreateAgentLogCollection(agentID string, tenantID int, userID string, deadline time.Time) (*ent.AgentLogCollection, error) {
+	pending, err := m.Client.AgentLogCollection.Query().
+		Where(
+			agentlogcollection.AgentID(agentID),
+			agentlogcollection.StatusIn(agentlogcollection.StatusQueued, agentlogcollection.StatusCollecting),
+		).
+		Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrAlreadyExists
+	}
+
+	return m.Client.AgentLogCollection.Create().
+		SetAgentID(agentID).
+		SetTenantID(tenantID).
+		SetUserID(userID).
+		SetStatus(agentlogcollection.StatusQueued).
+		SetCreatedAt(time.Now()).
+		SetDeadline(deadline).
+		Save(context.Background())
+}
+
+// GetAgentLogCollections returns the latest log collections of an agent of the tenant, newest first
+func (m *Model) GetAgentLogCollections(agentID string, tenantID int) ([]*ent.AgentLogCollection, error) {
+	return m.Client.AgentLogCollection.Query().
+		Where(agentlogcollection.AgentID(agentID), agentlogcollection.TenantID(tenantID)).
+		Order(ent.Desc(agentlogcollection.FieldCreatedAt), ent.Desc(agentlogcollection.FieldID)).
+		Limit(maxAgentLogCollectionsShown).
+		All(context.Background())
+}
+
+// GetAgentLogCollection returns a log collection of an agent of the tenant
+func (m *Model) GetAgentLogCollection(id int, agentID string, tenantID int) (*ent.AgentLogCollection, error) {
+	collection, err := m.Client.AgentLogCollection.Query().
+		Where(
+			agentlogcollection.ID(id),
+			agentlogcollection.AgentID(agentID),
+			agentlogcollection.TenantID(tenantID),
+		).
+		Only(context.Background())
+	return collection, dbError(err)
+}
+
+// GetQueuedAgentLogCollections returns the log collections waiting for their agent to be online
+func (m *Model) GetQueuedAgentLogCollections() ([]*ent.AgentLogCollection, error) {
+	return m.Client.AgentLogCollection.Query().
+		Where(agentlogcollection.StatusEQ(agentlogcollection.StatusQueued), agentlogcollection.DeadlineGT(time.Now())).
+		Order(ent.Asc(agentlogcollection.FieldCreatedAt)).
+		All(context.Background())
+}
+
+// ClaimAgentLogCollection marks a queued log collection as collecting. It returns false if the collection
+// isn't queued anymore, e.g. because another console replica has claimed it
+func (m *Model) ClaimAgentLogCollection(id int) (bool, error) {
+	n, err := m.Client.AgentLogCollection.Update().
+		Where(agentlogcollection.ID(id), agentlogcollection.StatusEQ(agentlogcollection.StatusQueued)).
+		SetStatus(agentlogcollection.StatusCollecting).
+		Save(context.Background())
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RequeueAgentLogCollection puts a log collection back in the queue when the agent goes offline before answering
+func (m *Model) RequeueAgentLogCollection(id int) error {
+	return m.Client.AgentLogCollection.Update().
+		Where(agentlogcollection.ID(id), agentlogcollection.StatusEQ(agentlogcollection.StatusCollecting)).
+		SetStatus(agentlogcollection.StatusQueued).
+		Exec(context.Background())
+}
+
+// FailAgentLogCollection records why the logs of the agent couldn't be collected
+func (m *Model) FailAgentLogCollection(id int, reason string) error {
+	return m.Client.AgentLogCollection.Update().
+		Where(agentlogcollection.ID(id), agentlogcollection.StatusEQ(agentlogcollection.StatusCollecting)).
+		SetStatus(agentlogcollection.StatusFailed).
+		SetError(reason).
+		SetCompletedAt(time.Now()).
+		Exec(context.Background())
+}
+
+// CompleteAgentLogCollection records the file with the logs of the agent and when it's removed. It returns
+// ErrNotFound if the collection has timed out while the logs were being collected
+func (m *Model) CompleteAgentLogCollection(id int, fileName string, size int64, expiresAt time.Time) error {
+	n, err := m.Client.AgentLogCollection.Update().
+		Where(agentlogcollection.ID(id), agentlogcollection.StatusEQ(agentlogcollection.StatusCollecting)).
+		SetStatus(agentlogcollection.StatusReady).
+		SetFileName(fileName).
+		SetSize(size).
+		SetCompletedAt(time.Now()).
+		SetExpiresAt(expiresAt).
+		Save(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExpireAgentLogCollections times out the log collections whose agent hasn't been online before the deadline and
+// expires the collected logs. It returns the files of the expired collections so they can be removed
+func (m *Model) ExpireAgentLogCollections(now time.Time) ([]string, error) {
+	if err := m.Client.AgentLogCollection.Update().
+		Where(
+			agentlogcollection.StatusIn(agentlogcollection.StatusQueued, agentlogcollection.StatusCollecting),
+			agentlogcollection.DeadlineLT(now),
+		).
+		SetStatus(agentlogcollection.StatusTimedOut).
+		SetCompletedAt(now).
+		Exec(context.Background()); err != nil {
+		return nil, err
+	}
+
+	expired, err := m.Client.AgentLogCollection.Query().
+		Where(agentlogcollection.StatusEQ(agentlogcollection.StatusReady), agentlogcollection.ExpiresAtLT(now)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	ids := []int{}
+	for _, collection := range expired {
+		files = append(files, collection.FileName)
+		ids = append(ids, collection.ID)
+	}
+	if len(ids) == 0 {
+		return files, nil
+	}
+
+	if err := m.Client.AgentLogCollection.Update().
+		Where(agentlogcollection.IDIn(ids...)).
+		SetStatus(agentlogcollection.StatusExpired).
+		Exec(context.Background()); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/agentlogcollection"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentLogCollectionsTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+}
+
+func (suite *AgentLogCollectionsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+}
+
+func (suite *AgentLogCollectionsTestSuite) TestCreateAgentLogCollection() {
+	collection, err := suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection")
+	assert.Equal(suite.T(), agentlogcollection.StatusQueued, collection.Status)
+
+	_, err = suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.ErrorIs(suite.T(), err, ErrAlreadyExists, "should only have one pending collection per agent")
+
+	_, err = suite.model.CreateAgentLogCollection("agent2", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection of another agent")
+
+	_, err = suite.model.GetAgentLogCollection(collection.ID, "agent1", suite.tenantID)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.model.GetAgentLogCollection(collection.ID, "agent1", suite.tenantID+1)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not get log collections of other tenants")
+
+	_, err = suite.model.GetAgentLogCollection(collection.ID, "agent2", suite.tenantID)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not get log collections of other agents")
+}
+
+func (suite *AgentLogCollectionsTestSuite) TestClaimAgentLogCollection() {
+	collection, err := suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection")
+
+	claimed, err := suite.model.ClaimAgentLogCollection(collection.ID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), claimed, "should claim the queued collection")
+
+	claimed, err = suite.model.ClaimAgentLogCollection(collection.ID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), claimed, "should not claim a collection twice")
+
+	err = suite.model.RequeueAgentLogCollection(collection.ID)
+	assert.NoError(suite.T(), err)
+
+	queued, err := suite.model.GetQueuedAgentLogCollections()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), queued, 1, "should be queued again")
+
+	claimed, err = suite.model.ClaimAgentLogCollection(collection.ID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), claimed, "should claim the requeued collection")
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	err = suite.model.CompleteAgentLogCollection(collection.ID, "logs.zip", 1024, expiresAt)
+	assert.NoError(suite.T(), err)
+
+	collection, err = suite.model.GetAgentLogCollection(collection.ID, "agent1", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), agentlogcollection.StatusReady, collection.Status)
+	assert.Equal(suite.T(), "logs.zip", collection.FileName)
+	assert.Equal(suite.T(), int64(1024), collection.Size)
+
+	err = suite.model.CompleteAgentLogCollection(collection.ID, "logs.zip", 1024, expiresAt)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should only complete collections being collected")
+}
+
+func (suite *AgentLogCollectionsTestSuite) TestFailAgentLogCollection() {
+	collection, err := suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection")
+
+	claimed, err := suite.model.ClaimAgentLogCollection(collection.ID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), claimed)
+
+	err = suite.model.FailAgentLogCollection(collection.ID, "logs too large")
+	assert.NoError(suite.T(), err)
+
+	collection, err = suite.model.GetAgentLogCollection(collection.ID, "agent1", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), agentlogcollection.StatusFailed, collection.Status)
+	assert.Equal(suite.T(), "logs too large", collection.Error)
+
+	_, err = suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should request the logs again after a failure")
+}
+
+func (suite *AgentLogCollectionsTestSuite) TestExpireAgentLogCollections() {
+	now := time.Now()
+
+	stale, err := suite.model.CreateAgentLogCollection("agent1", suite.tenantID, "admin", now.Add(-time.Minute))
+	assert.NoError(suite.T(), err, "should create log collection")
+
+	ready, err := suite.model.CreateAgentLogCollection("agent2", suite.tenantID, "admin", now.Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection")
+	err = suite.model.Client.AgentLogCollection.UpdateOneID(ready.ID).
+		SetStatus(agentlogcollection.StatusReady).
+		SetFileName("agent2.zip").
+		SetExpiresAt(now.Add(-time.Minute)).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	pending, err := suite.model.CreateAgentLogCollection("agent3", suite.tenantID, "admin", now.Add(time.Hour))
+	assert.NoError(suite.T(), err, "should create log collection")
+
+	queued, err := suite.model.GetQueuedAgentLogCollections()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), queued, 1, "should not return collections past their deadline")
+
+	files, err := suite.model.ExpireAgentLogCollections(now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"agent2.zip"}, files, "should return the files to remove")
+
+	for id, status := range map[int]agentlogcollection.Status{
+		stale.ID:   agentlogcollection.StatusTimedOut,
+		ready.ID:   agentlogcollection.StatusExpired,
+		pending.ID: agentlogcollection.StatusQueued,
+	} {
+		collection, err := suite.model.Client.AgentLogCollection.Get(context.Background(), id)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), status, collection.Status)
+	}
+}
+
+func TestAgentLogCollectionsTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentLogCollectionsTestSuite))
+}
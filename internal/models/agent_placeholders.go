@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentplaceholder"
+)
+
+// DefaultAgentPlaceholderRetention is how long a placeholder waits for the first report of its agent if no other
+// value is configured
+const DefaultAgentPlaceholderRetention = 14 * 24 * time.Hour
+
+// GetAgentPlaceholderRetention returns how long a placeholder waits for the first report of its agent
+func (m *Model) GetAgentPlaceholderRetention() time.Duration {
+	if m.AgentPlaceholderRetention <= 0 {
+		return DefaultAgentPlaceholderRetention
+	}
+	return m.AgentPlaceholderRetention
+}
+
+// CreateAgentPlaceholder pre-generates the UUID of an agent whose configuration is downloaded with the token,
+// so the download can be correlated with the agent when it reports for the first time
+func (m *Model) CreateAgentPlaceholder(tokenID, tenantID int, requestedBy string) (*ent.AgentPlaceholder, error) {
+	return m.Client.AgentPlaceholder.Create().
+		SetID(uuid.New().String()).
+		SetTokenID(tokenID).
+		SetTenantID(tenantID).
+		SetRequestedBy(requestedBy).
+		SetCreatedAt(time.Now()).
+		Save(context.Background())
+}
+
+// GetPendingAgentPlaceholders returns the placeholders of the tenant whose agents haven't reported yet, most
+// recent first
+func (m *Model) GetPendingAgentPlaceholders(tenantID int) ([]*ent.AgentPlaceholder, error) {
+	return m.Client.AgentPlaceholder.Query().
+		Where(agentplaceholder.TenantID(tenantID), agentplaceholder.CompletedAtIsNil()).
+		WithToken().
+		Order(ent.Desc(agentplaceholder.FieldCreatedAt)).
+		All(context.Background())
+}
+
+// CompleteAgentPlaceholders completes the placeholders whose agents have reported, it returns how many have been
+// completed
+func (m *Model) CompleteAgentPlaceholders() (int, error) {
+	ctx := context.Background()
+
+	ids, err := m.Client.AgentPlaceholder.Query().Where(agentplaceholder.CompletedAtIsNil()).IDs(ctx)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	reported, err := m.Client.Agent.Query().Where(agent.IDIn(ids...)).IDs(ctx)
+	if err != nil || len(reported) == 0 {
+		return 0, err
+	}
+
+	return m.Client.AgentPlaceholder.Update().
+		Where(agentplaceholder.IDIn(reported...), agentplaceholder.CompletedAtIsNil()).
+		SetCompletedAt(time.Now()).
+		Save(ctx)
+}
+
+// PurgeAgentPlaceholders removes the placeholders whose agents haven't reported within the retention, it returns
+// how many have been removed
+func (m *Model) PurgeAgentPlaceholders() (int, error) {
+	return m.Client.AgentPlaceholder.Delete().
+		Where(agentplaceholder.CompletedAtIsNil(), agentplaceholder.CreatedAtLTE(time.Now().Add(-m.GetAgentPlaceholderRetention()))).
+		Exec(context.Background())
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentPlaceholdersTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	siteID   int
+	tokenID  int
+}
+
+func (suite *AgentPlaceholdersTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	token, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Office", "11111111-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(suite.T(), err, "should create enrollment token")
+	suite.tokenID = token.ID
+}
+
+func (suite *AgentPlaceholdersTestSuite) TestCompleteAndPurge() {
+	reported, err := suite.model.CreateAgentPlaceholder(suite.tokenID, suite.tenantID, "admin")
+	assert.NoError(suite.T(), err, "should create placeholder")
+	assert.Len(suite.T(), reported.ID, 36, "should pre-generate a UUID")
+
+	pending, err := suite.model.CreateAgentPlaceholder(suite.tokenID, suite.tenantID, "operator")
+	assert.NoError(suite.T(), err)
+
+	placeholders, err := suite.model.GetPendingAgentPlaceholders(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), placeholders, 2) {
+		assert.Equal(suite.T(), "Office", placeholders[0].Edges.Token.Description, "should load the token")
+	}
+
+	err = suite.model.Client.Agent.Create().SetID(reported.ID).SetHostname("pc1").SetOs("windows").SetNickname("pc1").AddSiteIDs(suite.siteID).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	completed, err := suite.model.CompleteAgentPlaceholders()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, completed, "should complete the placeholder whose agent has reported")
+
+	placeholders, err = suite.model.GetPendingAgentPlaceholders(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), placeholders, 1) {
+		assert.Equal(suite.T(), pending.ID, placeholders[0].ID)
+	}
+
+	purged, err := suite.model.PurgeAgentPlaceholders()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, purged, "should wait for the agents within the retention")
+
+	suite.model.AgentPlaceholderRetention = time.Nanosecond
+	purged, err = suite.model.PurgeAgentPlaceholders()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, purged, "should only purge the placeholders whose agents never reported")
+
+	n, err := suite.model.Client.AgentPlaceholder.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, n, "should keep the completed placeholder")
+}
+
+func TestAgentPlaceholdersTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentPlaceholdersTestSuite))
+}
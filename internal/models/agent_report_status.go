@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// Report statuses of the agents, they depend on the time since the last report and the thresholds of the agent
+const (
+	AgentReportOnTime  = "on_time"
+	AgentReportLate    = "late"
+	AgentReportOffline = "offline"
+)
+
+// Thresholds used when neither the global settings, the tenant nor the tags of the agent set them
+const (
+	DefaultAgentLateThreshold    = 12 * time.Hour
+	DefaultAgentOfflineThreshold = 24 * time.Hour
+)
+
+// AgentThreshold is the time without reports after which an agent is late or offline
+type AgentThreshold struct {
+	Late    time.Duration
+	Offline time.Duration
+}
+
+// Status returns the report status of an agent that reported at lastContact
+func (t AgentThreshold) Status(lastContact, now time.Time) string {
+	elapsed := now.Sub(lastContact)
+	switch {
+	case elapsed > t.Offline:
+		return AgentReportOffline
+	case elapsed > t.Late:
+		return AgentReportLate
+	default:
+		return AgentReportOnTime
+	}
+}
+
+// override returns the threshold with the hours given, zero keeps the current value. An agent can't be
+// late after being offline, so the late threshold is capped by the offline one
+func (t AgentThreshold) override(lateHours, offlineHours int) AgentThreshold {
+	if lateHours > 0 {
+		t.Late = time.Duration(lateHours) * time.Hour
+	}
+	if offlineHours > 0 {
+		t.Offline = time.Duration(offlineHours) * time.Hour
+	}
+	return AgentThreshold{Late: min(t.Late, t.Offline), Offline: t.Offline}
+}
+
+// AgentThresholds are the thresholds of a tenant and those of its tags that override them
+type AgentThresholds struct {
+	Default AgentThreshold
+	Tags    map[int]AgentThreshold
+}
+
+// For returns the threshold of the agent, its tags must be loaded. If several of its tags override the
+// default, the most lenient one wins so that an agent expected to be away isn't reported as offline
+func (t AgentThresholds) For(a *ent.Agent) AgentThreshold {
+	threshold, overridden := t.Default, false
+	for _, tg := range a.Edges.Tags {
+		tagThreshold, ok := t.Tags[tg.ID]
+		if !ok {
+			continue
+		}
+		if !overridden || tagThreshold.Offline > threshold.Offline || (tagThreshold.Offline == threshold.Offline && tagThreshold.Late > threshold.Late) {
+			threshold, overridden = tagThreshold, true
+		}
+	}
+	return threshold
+}
+
+// Status returns the report status of the agent, its tags must be loaded
+func (t AgentThresholds) Status(a *ent.Agent, now time.Time) string {
+	return t.For(a).Status(a.LastContact, now)
+}
+
+// GetAgentThresholds reads the thresholds of the tenant. The tenant settings override the global ones and
+// these override the defaults. They're read every time they're needed, so a change is shown at once
+func (m *Model) GetAgentThresholds(tenantID int) (AgentThresholds, error) {
+	ctx := context.Background()
+
+	t := AgentThresholds{
+		Default: AgentThreshold{Late: DefaultAgentLateThreshold, Offline: DefaultAgentOfflineThreshold},
+		Tags:    map[int]AgentThreshold{},
+	}
+
+	for _, where := range []func(*ent.SettingsQuery){
+		func(q *ent.SettingsQuery) { q.Where(settings.Not(settings.HasTenant())) },
+		func(q *ent.SettingsQuery) { q.Where(settings.HasTenantWith(tenant.ID(tenantID))) },
+	} {
+		query := m.Client.Settings.Query().Select(settings.FieldAgentLateThresholdInHours, settings.FieldAgentOfflineThresholdInHours)
+		where(query)
+		s, err := query.Only(ctx)
+		if err != nil {
+			if ent.IsNotFound(err) {
+				continue
+			}
+			return t, err
+		}
+		t.Default = t.Default.override(s.AgentLateThresholdInHours, s.AgentOfflineThresholdInHours)
+	}
+
+	tags, err := m.Client.Tag.Query().
+		Where(tag.HasTenantWith(tenant.ID(tenantID)), tag.Or(tag.LateThresholdInHoursGT(0), tag.OfflineThresholdInHoursGT(0))).
+		All(ctx)
+	if err != nil {
+		return t, err
+	}
+	for _, tg := range tags {
+		t.Tags[tg.ID] = t.Default.override(tg.LateThresholdInHours, tg.OfflineThresholdInHours)
+	}
+
+	return t, nil
+}
+
+// UpdateAgentThresholds saves the thresholds of the global or tenant settings, zero inherits the value
+func (m *Model) UpdateAgentThresholds(settingsId, lateHours, offlineHours int) error {
+	return m.Client.Settings.UpdateOneID(settingsId).
+		SetAgentLateThresholdInHours(lateHours).
+		SetAgentOfflineThresholdInHours(offlineHours).
+		Exec(context.Background())
+}
+
+// UpdateTagThresholds saves the thresholds that the tag sets for its agents, zero inherits the value
+func (m *Model) UpdateTagThresholds(tagId, lateHours, offlineHours int, c *partials.CommonInfo) error {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return err
+	}
+
+	return m.Client.Tag.Update().
+		SetLateThresholdInHours(lateHours).
+		SetOfflineThresholdInHours(offlineHours).
+		Where(tag.ID(tagId), tag.HasTenantWith(tenant.ID(tenantID))).
+		Exec(context.Background())
+}
+
+// GetAgentsReportStatus returns the report status of the admitted agents in the tenant or site
+func (m *Model) GetAgentsReportStatus(c *partials.CommonInfo, now time.Time) (map[string]string, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds, err := m.GetAgentThresholds(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().
+		Select(agent.FieldID, agent.FieldLastContact).
+		WithTags(func(q *ent.TagQuery) { q.Select(tag.FieldID) }).
+		Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission))
+	if siteID == -1 {
+		query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	agents, err := query.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string, len(agents))
+	for _, a := range agents {
+		status[a.ID] = thresholds.Status(a, now)
+	}
+	return status, nil
+}
+
+// CountAgentsByReportStatus counts the admitted agents in the tenant or site by their report status
+func (m *Model) CountAgentsByReportStatus(c *partials.CommonInfo) (map[string]int, error) {
+	status, err := m.GetAgentsReportStatus(c, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{AgentReportOnTime: 0, AgentReportLate: 0, AgentReportOffline: 0}
+	for _, s := range status {
+		counts[s]++
+	}
+	return counts, nil
+}
+
+// agentIDsByReportStatus returns the IDs of the admitted agents in the tenant or site with one of the statuses
+func (m *Model) agentIDsByReportStatus(c *partials.CommonInfo, statuses ...string) ([]string, error) {
+	status, err := m.GetAgentsReportStatus(c, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for id, s := range status {
+		if slices.Contains(statuses, s) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// applyAgentReportStatusFilter keeps the agents that are late or offline when the filter asks for them,
+// the status depends on the thresholds so it can't be a simple condition on the last contact
+func (m *Model) applyAgentReportStatusFilter(query *ent.AgentQuery, f filters.AgentFilter, c *partials.CommonInfo) error {
+	statuses := []string{}
+	if f.Late {
+		statuses = append(statuses, AgentReportLate)
+	}
+	if f.NoContact {
+		statuses = append(statuses, AgentReportOffline)
+	}
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	ids, err := m.agentIDsByReportStatus(c, statuses...)
+	if err != nil {
+		return err
+	}
+	query.Where(agent.IDIn(ids...))
+	return nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentReportStatusTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *AgentReportStatusTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: "-1"}
+
+	// agent0 reported 1 hour ago, agent1 18 hours ago and agent2 30 hours ago
+	for i, hours := range []int{1, 18, 30} {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			SetLastContact(time.Now().Add(-time.Duration(hours) * time.Hour)).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *AgentReportStatusTestSuite) TestThresholdStatus() {
+	now := time.Now()
+	threshold := AgentThreshold{Late: 2 * time.Hour, Offline: 6 * time.Hour}
+
+	assert.Equal(suite.T(), AgentReportOnTime, threshold.Status(now.Add(-time.Hour), now))
+	assert.Equal(suite.T(), AgentReportLate, threshold.Status(now.Add(-3*time.Hour), now))
+	assert.Equal(suite.T(), AgentReportOffline, threshold.Status(now.Add(-7*time.Hour), now))
+}
+
+func (suite *AgentReportStatusTestSuite) TestDefaultThresholds() {
+	counts, err := suite.model.CountAgentsByReportStatus(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count agents by report status")
+	assert.Equal(suite.T(), map[string]int{AgentReportOnTime: 1, AgentReportLate: 1, AgentReportOffline: 1}, counts)
+}
+
+func (suite *AgentReportStatusTestSuite) TestTenantThresholds() {
+	assert.NoError(suite.T(), suite.model.CreateInitialSettings(), "should create global settings")
+
+	s, err := suite.model.GetGeneralSettings(strconv.Itoa(suite.tenantID))
+	assert.NoError(suite.T(), err, "should get tenant settings")
+
+	// The change is used at once, the agents don't have to report again
+	err = suite.model.UpdateAgentThresholds(s.ID, 0, 12)
+	assert.NoError(suite.T(), err, "should save tenant thresholds")
+
+	thresholds, err := suite.model.GetAgentThresholds(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get thresholds")
+	assert.Equal(suite.T(), AgentThreshold{Late: 12 * time.Hour, Offline: 12 * time.Hour}, thresholds.Default, "late can't be over offline")
+
+	counts, err := suite.model.CountAgentsByReportStatus(suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{AgentReportOnTime: 1, AgentReportLate: 0, AgentReportOffline: 2}, counts)
+
+	count, err := suite.model.CountAllAgents(filters.AgentFilter{NoContact: true}, true, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should filter offline agents")
+	assert.Equal(suite.T(), 2, count)
+}
+
+func (suite *AgentReportStatusTestSuite) TestTagThresholds() {
+	ctx := context.Background()
+
+	lenient, err := suite.model.Client.Tag.Create().SetTag("Laptops").SetColor("red").SetTenantID(suite.tenantID).Save(ctx)
+	assert.NoError(suite.T(), err, "should create tag")
+	strict, err := suite.model.Client.Tag.Create().SetTag("Servers").SetColor("blue").SetTenantID(suite.tenantID).Save(ctx)
+	assert.NoError(suite.T(), err, "should create tag")
+
+	assert.NoError(suite.T(), suite.model.UpdateTagThresholds(lenient.ID, 24, 48, suite.commonInfo))
+	assert.NoError(suite.T(), suite.model.UpdateTagThresholds(strict.ID, 0, 2, suite.commonInfo))
+
+	assert.NoError(suite.T(), suite.model.Client.Agent.UpdateOneID("agent0").AddTagIDs(strict.ID).Exec(ctx))
+	assert.NoError(suite.T(), suite.model.Client.Agent.UpdateOneID("agent2").AddTagIDs(strict.ID, lenient.ID).Exec(ctx))
+
+	thresholds, err := suite.model.GetAgentThresholds(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get thresholds")
+	assert.Equal(suite.T(), AgentThreshold{Late: 2 * time.Hour, Offline: 2 * time.Hour}, thresholds.Tags[strict.ID], "should inherit late and cap it")
+
+	status, err := suite.model.GetAgentsReportStatus(suite.commonInfo, time.Now())
+	assert.NoError(suite.T(), err, "should get report status")
+	assert.Equal(suite.T(), AgentReportOnTime, status["agent0"], "should be within the tag threshold")
+	assert.Equal(suite.T(), AgentReportLate, status["agent1"], "should use the default threshold")
+	assert.Equal(suite.T(), AgentReportLate, status["agent2"], "the most lenient tag should win")
+
+	count, err := suite.model.CountAllAgents(filters.AgentFilter{Late: true}, true, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should filter late agents")
+	assert.Equal(suite.T(), 2, count)
+}
+
+func TestAgentReportStatusTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentReportStatusTestSuite))
+}
@@ -0,0 +1,509 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentsettingsprofile"
+	"github.com/open-uem/ent/agentsettingsprofileassignment"
+	"github.com/open-uem/ent/agentsettingsprofilestatus"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/ent/tenant"
+)
+
+// Settings of an agent that a profile can differ from, as shown in the drift report
+const (
+	SettingsProfileDebugMode        = "debug_mode"
+	SettingsProfileSFTPService      = "sftp_service"
+	SettingsProfileSFTPPort         = "sftp_port"
+	SettingsProfileVNCProxyPort     = "vnc_proxy_port"
+	SettingsProfileRemoteAssistance = "remote_assistance"
+)
+
+// SettingsProfileValues are the settings of a profile as entered in the profile editor
+type SettingsProfileValues struct {
+	Name             string
+	Description      string
+	Frequency        int
+	DebugMode        bool
+	SFTPService      bool
+	SFTPPort         string
+	VNCProxyPort     string
+	RemoteAssistance bool
+	// Priority decides between the profiles of the tags of an agent, the lowest number wins
+	Priority int
+}
+
+// SettingsProfileCandidate is a profile assigned to an agent, directly or through its site or one of its tags
+type SettingsProfileCandidate struct {
+	Profile *ent.AgentSettingsProfile
+	Target  agentsettingsprofileassignment.TargetType
+}
+
+// SettingsProfileResolution is the profile that applies to an agent and the profiles it overrides
+type SettingsProfileResolution struct {
+	Effective  *SettingsProfileCandidate
+	Overridden []SettingsProfileCandidate
+}
+
+// AgentSettingsProfileState is the effective profile of an agent, whether the agent has applied it and
+// the settings reported by the agent that differ from it
+type AgentSettingsProfileState struct {
+	Agent      *ent.Agent
+	Resolution SettingsProfileResolution
+	Status     *ent.AgentSettingsProfileStatus
+	Drift      []string
+}
+
+// Conflict returns if more than one profile is assigned to the agent
+func (r SettingsProfileResolution) Conflict() bool {
+	return r.Effective != nil && slices.ContainsFunc(r.Overridden, func(o SettingsProfileCandidate) bool {
+		return o.Profile.ID != r.Effective.Profile.ID
+	})
+}
+
+// Profile returns the effective profile of the agent or nil if the agent keeps its own settings
+func (s AgentSettingsProfileState) Profile() *ent.AgentSettingsProfile {
+	if s.Resolution.Effective == nil {
+		return nil
+	}
+	return s.Resolution.Effective.Profile
+}
+
+// NeedsPush returns if the effective profile, or its latest revision, hasn't been applied by the agent yet.
+// A failed profile isn't pushed again until it's changed
+func (s AgentSettingsProfileState) NeedsPush() bool {
+	p := s.Profile()
+	if p == nil {
+		return false
+	}
+	if s.Status == nil || s.Status.ProfileID != p.ID || s.Status.Revision != p.Revision {
+		return true
+	}
+	return s.Status.Status == agentsettingsprofilestatus.StatusPending
+}
+
+// settingsProfileTargetRank orders the targets from the most to the least specific
+var settingsProfileTargetRank = map[agentsettingsprofileassignment.TargetType]int{
+	agentsettingsprofileassignment.TargetTypeAgent: 0,
+	agentsettingsprofileassignment.TargetTypeTag:   1,
+	agentsettingsprofileassignment.TargetTypeSite:  2,
+}
+
+// ResolveSettingsProfile returns the profile that applies to an agent. A profile assigned to the agent wins
+// over the profiles of its tags, which win over the profile of its site. If several tags of the agent have
+// a profile, the one with the lowest priority number wins and then the oldest one
+func ResolveSettingsProfile(a *ent.Agent, assignments []*ent.AgentSettingsProfileAssignment, profiles map[int]*ent.AgentSettingsProfile) SettingsProfileResolution {
+	candidates := []SettingsProfileCandidate{}
+	for _, assignment := range assignments {
+		p, ok := profiles[assignment.ProfileID]
+		if !ok || !settingsProfileAssignedTo(a, assignment) {
+			continue
+		}
+		candidates = append(candidates, SettingsProfileCandidate{Profile: p, Target: assignment.TargetType})
+	}
+
+	if len(candidates) == 0 {
+		return SettingsProfileResolution{}
+	}
+
+	slices.SortStableFunc(candidates, func(x, y SettingsProfileCandidate) int {
+		if r := settingsProfileTargetRank[x.Target] - settingsProfileTargetRank[y.Target]; r != 0 {
+			return r
+		}
+		if r := x.Profile.Priority - y.Profile.Priority; r != 0 {
+			return r
+		}
+		return x.Profile.ID - y.Profile.ID
+	})
+
+	return SettingsProfileResolution{Effective: &candidates[0], Overridden: candidates[1:]}
+}
+
+func settingsProfileAssignedTo(a *ent.Agent, assignment *ent.AgentSettingsProfileAssignment) bool {
+	switch assignment.TargetType {
+	case agentsettingsprofileassignment.TargetTypeAgent:
+		return assignment.TargetID == a.ID
+	case agentsettingsprofileassignment.TargetTypeSite:
+		return slices.ContainsFunc(a.Edges.Site, func(s *ent.Site) bool { return strconv.Itoa(s.ID) == assignment.TargetID })
+	case agentsettingsprofileassignment.TargetTypeTag:
+		return slices.ContainsFunc(a.Edges.Tags, func(t *ent.Tag) bool { return strconv.Itoa(t.ID) == assignment.TargetID })
+	}
+	return false
+}
+
+// SettingsProfileDrift returns the settings reported by the agent that differ from the profile. The report
+// frequency isn't reported by the agents so it can't drift
+func SettingsProfileDrift(a *ent.Agent, p *ent.AgentSettingsProfile) []string {
+	drift := []string{}
+	if a.DebugMode != p.DebugMode {
+		drift = append(drift, SettingsProfileDebugMode)
+	}
+	if a.SftpService != p.SftpService {
+		drift = append(drift, SettingsProfileSFTPService)
+	}
+	if p.SftpService && a.SftpPort != p.SftpPort {
+		drift = append(drift, SettingsProfileSFTPPort)
+	}
+	if a.VncProxyPort != p.VncProxyPort {
+		drift = append(drift, SettingsProfileVNCProxyPort)
+	}
+	if a.RemoteAssistance != p.RemoteAssistance {
+		drift = append(drift, SettingsProfileRemoteAssistance)
+	}
+	return drift
+}
+
+// GetSettingsProfiles returns the settings profiles of the tenant
+func (m *Model) GetSettingsProfiles(tenantID int) ([]*ent.AgentSettingsProfile, error) {
+	return m.Client.AgentSettingsProfile.Query().
+		Where(agentsettingsprofile.TenantID(tenantID)).
+		Order(ent.Asc(agentsettingsprofile.FieldPriority), ent.Asc(agentsettingsprofile.FieldID)).
+		All(context.Background())
+}
+
+// GetSettingsProfile returns a settings profile of the tenant
+func (m *Model) GetSettingsProfile(id, tenantID int) (*ent.AgentSettingsProfile, error) {
+	p, err := m.Client.AgentSettingsProfile.Query().
+		Where(agentsettingsprofile.ID(id), agentsettingsprofile.TenantID(tenantID)).
+		Only(context.Background())
+	return p, dbError(err)
+}
+
+// CreateSettingsProfile saves a new settings profile of the tenant
+func (m *Model) CreateSettingsProfile(tenantID int, v SettingsProfileValues) (*ent.AgentSettingsProfile, error) {
+	exists, err := m.Client.AgentSettingsProfile.Query().
+		Where(agentsettingsprofile.TenantID(tenantID), agentsettingsprofile.Name(v.Name)).
+		Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyExists
+	}
+
+	return m.Client.AgentSettingsProfile.Create().
+		SetTenantID(tenantID).
+		SetName(v.Name).
+		SetDescription(v.Description).
+		SetFrequency(v.Frequency).
+		SetDebugMode(v.DebugMode).
+		SetSftpService(v.SFTPService).
+		SetSftpPort(v.SFTPPort).
+		SetVncProxyPort(v.VNCProxyPort).
+		SetRemoteAssistance(v.RemoteAssistance).
+		SetPriority(v.Priority).
+		SetRevision(1).
+		SetModified(time.Now()).
+		Save(context.Background())
+}
+
+// UpdateSettingsProfile saves the settings of a profile. A new revision is created so the profile is
+// pushed again to its agents
+func (m *Model) UpdateSettingsProfile(id, tenantID int, v SettingsProfileValues) (*ent.AgentSettingsProfile, error) {
+	exists, err := m.Client.AgentSettingsProfile.Query().
+		Where(agentsettingsprofile.TenantID(tenantID), agentsettingsprofile.Name(v.Name), agentsettingsprofile.IDNEQ(id)).
+		Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyExists
+	}
+
+	n, err := m.Client.AgentSettingsProfile.Update().
+		Where(agentsettingsprofile.ID(id), agentsettingsprofile.TenantID(tenantID)).
+		SetName(v.Name).
+		SetDescription(v.Description).
+		SetFrequency(v.Frequency).
+		SetDebugMode(v.DebugMode).
+		SetSftpService(v.SFTPService).
+		SetSftpPort(v.SFTPPort).
+		SetVncProxyPort(v.VNCProxyPort).
+		SetRemoteAssistance(v.RemoteAssistance).
+		SetPriority(v.Priority).
+		AddRevision(1).
+		SetModified(time.Now()).
+		Save(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return m.GetSettingsProfile(id, tenantID)
+}
+
+// DeleteSettingsProfile removes a profile and its assignments. The agents keep the settings they have applied
+func (m *Model) DeleteSettingsProfile(id, tenantID int) error {
+	tx, err := m.Client.Tx(context.Background())
+	if err != nil {
+		return err
+	}
+
+	n, err := tx.AgentSettingsProfile.Delete().
+		Where(agentsettingsprofile.ID(id), agentsettingsprofile.TenantID(tenantID)).
+		Exec(context.Background())
+	if err != nil {
+		return rollback(tx, err)
+	}
+	if n == 0 {
+		return rollback(tx, ErrNotFound)
+	}
+
+	if _, err := tx.AgentSettingsProfileAssignment.Delete().
+		Where(agentsettingsprofileassignment.ProfileID(id)).
+		Exec(context.Background()); err != nil {
+		return rollback(tx, err)
+	}
+
+	if _, err := tx.AgentSettingsProfileStatus.Delete().
+		Where(agentsettingsprofilestatus.ProfileID(id)).
+		Exec(context.Background()); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// GetSettingsProfileAssignments returns the sites, tags and agents the profiles of the tenant are assigned to
+func (m *Model) GetSettingsProfileAssignments(tenantID int) ([]*ent.AgentSettingsProfileAssignment, error) {
+	return m.Client.AgentSettingsProfileAssignment.Query().
+		Where(agentsettingsprofileassignment.TenantID(tenantID)).
+		Order(ent.Asc(agentsettingsprofileassignment.FieldTargetType), ent.Asc(agentsettingsprofileassignment.FieldID)).
+		All(context.Background())
+}
+
+// AssignSettingsProfile assigns a profile to a site, tag or agent of the tenant, replacing the profile
+// assigned to it, if any
+func (m *Model) AssignSettingsProfile(profileID, tenantID int, target agentsettingsprofileassignment.TargetType, targetID string) error {
+	if _, err := m.GetSettingsProfile(profileID, tenantID); err != nil {
+		return err
+	}
+
+	var exists bool
+	var err error
+	switch target {
+	case agentsettingsprofileassignment.TargetTypeAgent:
+		exists, err = m.Client.Agent.Query().
+			Where(agent.ID(targetID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+			Exist(context.Background())
+	case agentsettingsprofileassignment.TargetTypeSite:
+		id, convErr := strconv.Atoi(targetID)
+		if convErr != nil {
+			return ErrNotFound
+		}
+		exists, err = m.Client.Site.Query().
+			Where(site.ID(id), site.HasTenantWith(tenant.ID(tenantID))).
+			Exist(context.Background())
+	case agentsettingsprofileassignment.TargetTypeTag:
+		id, convErr := strconv.Atoi(targetID)
+		if convErr != nil {
+			return ErrNotFound
+		}
+		exists, err = m.Client.Tag.Query().
+			Where(tag.ID(id), tag.HasTenantWith(tenant.ID(tenantID))).
+			Exist(context.Background())
+	default:
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	tx, err := m.Client.Tx(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.AgentSettingsProfileAssignment.Delete().
+		Where(
+			agentsettingsprofileassignment.TenantID(tenantID),
+			agentsettingsprofileassignment.TargetTypeEQ(target),
+			agentsettingsprofileassignment.TargetID(targetID),
+		).
+		Exec(context.Background()); err != nil {
+		return rollback(tx, err)
+	}
+
+	if err := tx.AgentSettingsProfileAssignment.Create().
+		SetTenantID(tenantID).
+		SetProfileID(profileID).
+		SetTargetType(target).
+		SetTargetID(targetID).
+		Exec(context.Background()); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// UnassignSettingsProfile removes an assignment of a profile of the tenant
+func (m *Model) UnassignSettingsProfile(assignmentID, tenantID int) error {
+	n, err := m.Client.AgentSettingsProfileAssignment.Delete().
+		Where(agentsettingsprofileassignment.ID(assignmentID), agentsettingsprofileassignment.TenantID(tenantID)).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetAgentSettingsProfileStates returns the effective profile of the admitted agents of the tenant and
+// whether they have applied it, ordered by hostname
+func (m *Model) GetAgentSettingsProfileStates(tenantID int) ([]AgentSettingsProfileState, error) {
+	agents, err := m.Client.Agent.Query().
+		Where(
+			agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))),
+			agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission),
+		).
+		WithSite().
+		WithTags().
+		Order(ent.Asc(agent.FieldHostname)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return m.settingsProfileStates(tenantID, agents)
+}
+
+// GetAgentSettingsProfileState returns the effective profile of an agent of the tenant and whether the
+// agent has applied it
+func (m *Model) GetAgentSettingsProfileState(agentID string, tenantID int) (AgentSettingsProfileState, error) {
+	a, err := m.Client.Agent.Query().
+		Where(agent.ID(agentID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+		WithSite().
+		WithTags().
+		Only(context.Background())
+	if err != nil {
+		return AgentSettingsProfileState{}, dbError(err)
+	}
+
+	states, err := m.settingsProfileStates(tenantID, []*ent.Agent{a})
+	if err != nil {
+		return AgentSettingsProfileState{}, err
+	}
+	return states[0], nil
+}
+
+func (m *Model) settingsProfileStates(tenantID int, agents []*ent.Agent) ([]AgentSettingsProfileState, error) {
+	profiles, err := m.GetSettingsProfiles(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]*ent.AgentSettingsProfile{}
+	for _, p := range profiles {
+		byID[p.ID] = p
+	}
+
+	assignments, err := m.GetSettingsProfileAssignments(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := m.Client.AgentSettingsProfileStatus.Query().
+		Where(agentsettingsprofilestatus.TenantID(tenantID)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	statusByAgent := map[string]*ent.AgentSettingsProfileStatus{}
+	for _, s := range statuses {
+		statusByAgent[s.AgentID] = s
+	}
+
+	states := []AgentSettingsProfileState{}
+	for _, a := range agents {
+		state := AgentSettingsProfileState{
+			Agent:      a,
+			Resolution: ResolveSettingsProfile(a, assignments, byID),
+			Status:     statusByAgent[a.ID],
+		}
+		// Only the settings of the agents that have applied the profile can drift from it
+		if p := state.Profile(); p != nil && !state.NeedsPush() && state.Status.Status == agentsettingsprofilestatus.StatusApplied {
+			state.Drift = SettingsProfileDrift(a, p)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// SetAgentSettingsProfileStatus records whether the agent has applied the revision of the profile
+func (m *Model) SetAgentSettingsProfileStatus(agentID string, tenantID int, p *ent.AgentSettingsProfile, status agentsettingsprofilestatus.Status, reason string) error {
+	return m.Client.AgentSettingsProfileStatus.Create().
+		SetAgentID(agentID).
+		SetTenantID(tenantID).
+		SetProfileID(p.ID).
+		SetRevision(p.Revision).
+		SetStatus(status).
+		SetError(reason).
+		SetModified(time.Now()).
+		OnConflictColumns(agentsettingsprofilestatus.FieldAgentID).
+		UpdateNewValues().
+		Exec(context.Background())
+}
+
+// SaveAppliedSettingsProfile records that the agent has applied the profile and saves its settings
+// in the agent, as when they're changed from the agent settings
+func (m *Model) SaveAppliedSettingsProfile(agentID string, tenantID int, p *ent.AgentSettingsProfile) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	if err := m.Client.Agent.Update().
+		Where(agent.ID(agentID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+		SetDebugMode(p.DebugMode).
+		SetSftpService(p.SftpService).
+		SetSftpPort(p.SftpPort).
+		SetVncProxyPort(p.VncProxyPort).
+		SetRemoteAssistance(p.RemoteAssistance).
+		SetSettingsModified(time.Now()).
+		Exec(context.Background()); err != nil {
+		return err
+	}
+
+	return m.SetAgentSettingsProfileStatus(agentID, tenantID, p, agentsettingsprofilestatus.StatusApplied, "")
+}
+
+// GetEnrollmentSettingsProfile returns the profile of the site where the agents enrolled with a token
+// are placed, the default site of the tenant if the token has no site, or nil if it has no profile
+func (m *Model) GetEnrollmentSettingsProfile(tenantID int, siteID *int) (*ent.AgentSettingsProfile, error) {
+	if siteID == nil {
+		s, err := m.Client.Site.Query().
+			Where(site.IsDefault(true), site.HasTenantWith(tenant.ID(tenantID))).
+			Only(context.Background())
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		siteID = &s.ID
+	}
+
+	assignment, err := m.Client.AgentSettingsProfileAssignment.Query().
+		Where(
+			agentsettingsprofileassignment.TenantID(tenantID),
+			agentsettingsprofileassignment.TargetTypeEQ(agentsettingsprofileassignment.TargetTypeSite),
+			agentsettingsprofileassignment.TargetID(strconv.Itoa(*siteID)),
+		).
+		Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return m.GetSettingsProfile(assignment.ProfileID, tenantID)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentsettingsprofileassignment"
+	"github.com/open-uem/ent/agentsettingsprofilestatus"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestResolveSettingsProfile(t *testing.T) {
+	profiles := map[int]*openuem_ent.AgentSettingsProfile{
+		1: {ID: 1, Name: "Site"},
+		2: {ID: 2, Name: "Servers", Priority: 10},
+		3: {ID: 3, Name: "Kiosks", Priority: 5},
+		4: {ID: 4, Name: "Debug"},
+	}
+	assignments := []*openuem_ent.AgentSettingsProfileAssignment{
+		{ProfileID: 1, TargetType: agentsettingsprofileassignment.TargetTypeSite, TargetID: "1"},
+		{ProfileID: 2, TargetType: agentsettingsprofileassignment.TargetTypeTag, TargetID: "1"},
+		{ProfileID: 3, TargetType: agentsettingsprofileassignment.TargetTypeTag, TargetID: "2"},
+		{ProfileID: 4, TargetType: agentsettingsprofileassignment.TargetTypeAgent, TargetID: "debug"},
+	}
+
+	a := &openuem_ent.Agent{ID: "plain", Edges: openuem_ent.AgentEdges{Site: []*openuem_ent.Site{{ID: 1}}}}
+	r := ResolveSettingsProfile(a, assignments, profiles)
+	if assert.NotNil(t, r.Effective) {
+		assert.Equal(t, 1, r.Effective.Profile.ID, "should apply the profile of the site")
+	}
+	assert.False(t, r.Conflict())
+
+	a = &openuem_ent.Agent{ID: "tagged", Edges: openuem_ent.AgentEdges{
+		Site: []*openuem_ent.Site{{ID: 1}},
+		Tags: []*openuem_ent.Tag{{ID: 1}, {ID: 2}},
+	}}
+	r = ResolveSettingsProfile(a, assignments, profiles)
+	if assert.NotNil(t, r.Effective) {
+		assert.Equal(t, 3, r.Effective.Profile.ID, "should apply the profile of the tag with the lowest priority number")
+	}
+	assert.Len(t, r.Overridden, 2)
+	assert.True(t, r.Conflict())
+
+	a = &openuem_ent.Agent{ID: "debug", Edges: openuem_ent.AgentEdges{
+		Site: []*openuem_ent.Site{{ID: 1}},
+		Tags: []*openuem_ent.Tag{{ID: 1}},
+	}}
+	r = ResolveSettingsProfile(a, assignments, profiles)
+	if assert.NotNil(t, r.Effective) {
+		assert.Equal(t, 4, r.Effective.Profile.ID, "should apply the profile assigned to the agent")
+	}
+
+	a = &openuem_ent.Agent{ID: "other", Edges: openuem_ent.AgentEdges{Site: []*openuem_ent.Site{{ID: 2}}}}
+	assert.Nil(t, ResolveSettingsProfile(a, assignments, profiles).Effective, "should keep the settings of the agent without profile")
+}
+
+func TestSettingsProfileDrift(t *testing.T) {
+	p := &openuem_ent.AgentSettingsProfile{SftpService: true, SftpPort: "2022", VncProxyPort: "1443", RemoteAssistance: true}
+
+	assert.Empty(t, SettingsProfileDrift(&openuem_ent.Agent{SftpService: true, SftpPort: "2022", VncProxyPort: "1443", RemoteAssistance: true}, p))
+	assert.Equal(t, []string{SettingsProfileDebugMode, SettingsProfileSFTPPort}, SettingsProfileDrift(&openuem_ent.Agent{DebugMode: true, SftpService: true, SftpPort: "2222", VncProxyPort: "1443", RemoteAssistance: true}, p))
+}
+
+type AgentSettingsProfilesTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	siteID   int
+}
+
+func (suite *AgentSettingsProfilesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	for _, id := range []string{"agent1", "agent2"} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *AgentSettingsProfilesTestSuite) TestSettingsProfiles() {
+	p, err := suite.model.CreateSettingsProfile(suite.tenantID, SettingsProfileValues{Name: "Default", Frequency: 5, SFTPService: true, SFTPPort: "2022"})
+	assert.NoError(suite.T(), err, "should create profile")
+	assert.Equal(suite.T(), 1, p.Revision)
+
+	_, err = suite.model.CreateSettingsProfile(suite.tenantID, SettingsProfileValues{Name: "Default", Frequency: 5})
+	assert.ErrorIs(suite.T(), err, ErrAlreadyExists, "should not repeat the names of the profiles")
+
+	p, err = suite.model.UpdateSettingsProfile(p.ID, suite.tenantID, SettingsProfileValues{Name: "Default", Frequency: 10, SFTPService: true, SFTPPort: "2022"})
+	assert.NoError(suite.T(), err, "should update profile")
+	assert.Equal(suite.T(), 2, p.Revision, "should create a new revision")
+	assert.Equal(suite.T(), 10, p.Frequency)
+
+	_, err = suite.model.UpdateSettingsProfile(p.ID, suite.tenantID+1, SettingsProfileValues{Name: "Default", Frequency: 10})
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not update profiles of other tenants")
+
+	err = suite.model.AssignSettingsProfile(p.ID, suite.tenantID, agentsettingsprofileassignment.TargetTypeSite, "999")
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should only assign profiles to sites of the tenant")
+
+	err = suite.model.AssignSettingsProfile(p.ID, suite.tenantID, agentsettingsprofileassignment.TargetTypeSite, strconv.Itoa(suite.siteID))
+	assert.NoError(suite.T(), err, "should assign profile to site")
+
+	enrollment, err := suite.model.GetEnrollmentSettingsProfile(suite.tenantID, nil)
+	assert.NoError(suite.T(), err)
+	if assert.NotNil(suite.T(), enrollment, "new agents of the default site should use its profile") {
+		assert.Equal(suite.T(), p.ID, enrollment.ID)
+	}
+
+	states, err := suite.model.GetAgentSettingsProfileStates(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), states, 2)
+	for _, s := range states {
+		assert.True(suite.T(), s.NeedsPush(), "should push the profile to the agents")
+	}
+
+	err = suite.model.SaveAppliedSettingsProfile("agent1", suite.tenantID, p)
+	assert.NoError(suite.T(), err)
+	err = suite.model.SetAgentSettingsProfileStatus("agent2", suite.tenantID, p, agentsettingsprofilestatus.StatusFailed, "port in use")
+	assert.NoError(suite.T(), err)
+
+	state, err := suite.model.GetAgentSettingsProfileState("agent1", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), state.NeedsPush(), "should not push the applied profile again")
+	assert.Empty(suite.T(), state.Drift)
+
+	state, err = suite.model.GetAgentSettingsProfileState("agent2", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), state.NeedsPush(), "should not push the failed profile until it's changed")
+
+	err = suite.model.Client.Agent.UpdateOneID("agent1").SetDebugMode(true).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	state, err = suite.model.GetAgentSettingsProfileState("agent1", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{SettingsProfileDebugMode}, state.Drift, "should report the settings changed in the agent")
+
+	_, err = suite.model.UpdateSettingsProfile(p.ID, suite.tenantID, SettingsProfileValues{Name: "Default", Frequency: 15, SFTPService: true, SFTPPort: "2022"})
+	assert.NoError(suite.T(), err)
+	state, err = suite.model.GetAgentSettingsProfileState("agent2", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), state.NeedsPush(), "should push the new revision")
+
+	err = suite.model.DeleteSettingsProfile(p.ID, suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assignments, err := suite.model.GetSettingsProfileAssignments(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), assignments, "should remove the assignments of the profile")
+}
+
+func TestAgentSettingsProfilesTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentSettingsProfilesTestSuite))
+}
@@ -11,13 +11,16 @@ import (
 	"github.com/open-uem/ent/antivirus"
 	"github.com/open-uem/ent/app"
 	"github.com/open-uem/ent/computer"
+	"github.com/open-uem/ent/networkadapter"
 	"github.com/open-uem/ent/operatingsystem"
 	"github.com/open-uem/ent/predicate"
+	"github.com/open-uem/ent/printer"
 	"github.com/open-uem/ent/release"
 	"github.com/open-uem/ent/site"
 	"github.com/open-uem/ent/systemupdate"
 	"github.com/open-uem/ent/tag"
 	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/ent/update"
 	openuem_nats "github.com/open-uem/nats"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
@@ -51,6 +54,9 @@ func (m *Model) GetAllAgents(f filters.AgentFilter, c *partials.CommonInfo) ([]*
 
 	// Apply filters
 	applyAgentFilters(query, f)
+	if err := m.applyAgentReportStatusFilter(query, f, c); err != nil {
+		return nil, err
+	}
 
 	agents, err := query.All(context.Background())
 	if err != nil {
@@ -59,6 +65,39 @@ func (m *Model) GetAllAgents(f filters.AgentFilter, c *partials.CommonInfo) ([]*
 	return agents, nil
 }
 
+// GetAgentsByHardware returns the agents with at least the CPU cores and the RAM given, zero
+// values don't filter. The agents report the memory of the computer in MB
+func (m *Model) GetAgentsByHardware(c *partials.CommonInfo, minCPUCores, minRAMGB int) ([]*ent.Agent, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().WithSite().WithTags().WithRelease().WithComputer()
+	if siteID == -1 {
+		query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	hardware := []predicate.Computer{}
+	if minCPUCores > 0 {
+		hardware = append(hardware, computer.ProcessorCoresGTE(int64(minCPUCores)))
+	}
+	if minRAMGB > 0 {
+		hardware = append(hardware, computer.MemoryGTE(uint64(minRAMGB)*1024))
+	}
+	if len(hardware) > 0 {
+		query.Where(agent.HasComputerWith(hardware...))
+	}
+
+	return query.Order(ent.Asc(agent.FieldNickname)).All(context.Background())
+}
+
 func (m *Model) GetAgentsByPage(p partials.PaginationAndSort, f filters.AgentFilter, excludeWaitingForAdmissionAgents bool, c *partials.CommonInfo) ([]*ent.Agent, error) {
 	var err error
 	var agents []*ent.Agent
@@ -92,6 +131,9 @@ func (m *Model) GetAgentsByPage(p partials.PaginationAndSort, f filters.AgentFil
 
 	// Apply filters
 	applyAgentFilters(query, f)
+	if err := m.applyAgentReportStatusFilter(query, f, c); err != nil {
+		return nil, err
+	}
 
 	switch p.SortBy {
 	case "nickname":
@@ -146,6 +188,38 @@ func (m *Model) GetAgentsByPage(p partials.PaginationAndSort, f filters.AgentFil
 	return agents, nil
 }
 
+// GetAgentsAfter returns the next agents after the agent with the cursor ID, the agents are sorted by
+// ID so a page doesn't change if agents are added or removed while scrolling. An empty cursor returns
+// the first page
+func (m *Model) GetAgentsAfter(cursor string, pageSize int, f filters.AgentFilter, c *partials.CommonInfo) ([]*ent.Agent, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().WithSite().WithTags().WithRelease()
+	if siteID == -1 {
+		query = query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query = query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	if cursor != "" {
+		query = query.Where(agent.IDGT(cursor))
+	}
+
+	applyAgentFilters(query, f)
+	if err := m.applyAgentReportStatusFilter(query, f, c); err != nil {
+		return nil, err
+	}
+
+	return query.Order(ent.Asc(agent.FieldID)).Limit(pageSize).All(context.Background())
+}
+
 func (m *Model) GetAgentById(agentId string, c *partials.CommonInfo) (*ent.Agent, error) {
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
@@ -253,11 +327,63 @@ func (m *Model) CountAllAgents(f filters.AgentFilter, excludeWaitingForAdmission
 	}
 
 	applyAgentFilters(query, f)
+	if err := m.applyAgentReportStatusFilter(query, f, c); err != nil {
+		return -1, err
+	}
 
 	count, err := query.Count(context.Background())
 	return count, err
 }
 
+// GetAgentIDsBySelection returns the IDs of the agents matching the filter in the current tenant and site,
+// leaving out the agents the user has unselected
+func (m *Model) GetAgentIDsBySelection(f filters.AgentFilter, excluded []string, c *partials.CommonInfo) ([]string, error) {
+	query, err := m.agentSelectionQuery(f, excluded, c)
+	if err != nil {
+		return nil, err
+	}
+	return query.IDs(context.Background())
+}
+
+// CountAgentsBySelection returns the number of agents matching the filter in the current tenant and site,
+// leaving out the agents the user has unselected
+func (m *Model) CountAgentsBySelection(f filters.AgentFilter, excluded []string, c *partials.CommonInfo) (int, error) {
+	query, err := m.agentSelectionQuery(f, excluded, c)
+	if err != nil {
+		return -1, err
+	}
+	return query.Count(context.Background())
+}
+
+func (m *Model) agentSelectionQuery(f filters.AgentFilter, excluded []string, c *partials.CommonInfo) (*ent.AgentQuery, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query()
+	if siteID == -1 {
+		query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	applyAgentFilters(query, f)
+	if err := m.applyAgentReportStatusFilter(query, f, c); err != nil {
+		return nil, err
+	}
+
+	if len(excluded) > 0 {
+		query.Where(agent.IDNotIn(excluded...))
+	}
+
+	return query, nil
+}
+
 func (m *Model) GetAgentsUsedOSes(c *partials.CommonInfo, f filters.AgentFilter, dontShowIfUnsupportedEDR bool) ([]string, error) {
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
@@ -362,7 +488,7 @@ func applyAgentFilters(query *ent.AgentQuery, f filters.AgentFilter) {
 			query.Where(agent.AgentStatusEQ(agent.AgentStatusEnabled))
 		}
 
-		if len(f.AgentStatusOptions) == 1 && f.AgentStatusOptions[0] == "No Contact" {
+		if len(f.AgentStatusOptions) == 1 && (f.AgentStatusOptions[0] == "No Contact" || f.AgentStatusOptions[0] == "Late") {
 			query.Where(agent.AgentStatusEQ(agent.AgentStatusEnabled))
 		}
 
@@ -399,6 +525,10 @@ func applyAgentFilters(query *ent.AgentQuery, f filters.AgentFilter) {
 		}
 	}
 
+	if !f.ContactSince.IsZero() {
+		query.Where(agent.LastContactGTE(f.ContactSince))
+	}
+
 	if len(f.Tags) > 0 {
 		predicates := []predicate.Agent{}
 		for _, id := range f.Tags {
@@ -418,13 +548,10 @@ func applyAgentFilters(query *ent.AgentQuery, f filters.AgentFilter) {
 			agent.HasComputerWith(computer.ModelContainsFold(f.Search)),
 		))
 	}
-
-	if f.NoContact {
-		query.Where(agent.LastContactLTE((time.Now().AddDate(0, 0, -1))))
-	}
 }
 
-func (m *Model) CountAgentsReportedLast24h(c *partials.CommonInfo) (int, error) {
+// CountOnlineAgents counts the agents in the tenant or site from the list of agents that are online
+func (m *Model) CountOnlineAgents(onlineIDs []string, c *partials.CommonInfo) (int, error) {
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
 		return 0, err
@@ -434,71 +561,90 @@ func (m *Model) CountAgentsReportedLast24h(c *partials.CommonInfo) (int, error)
 		return 0, err
 	}
 
+	if len(onlineIDs) == 0 {
+		return 0, nil
+	}
+
 	if siteID == -1 {
-		count, err := m.Client.Agent.Query().Where(agent.LastContactGTE(time.Now().AddDate(0, 0, -1)), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
-		if err != nil {
-			return 0, err
-		}
-		return count, err
+		return m.Client.Agent.Query().Where(agent.IDIn(onlineIDs...), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
 	} else {
-		count, err := m.Client.Agent.Query().Where(agent.LastContactGTE(time.Now().AddDate(0, 0, -1)), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
-		if err != nil {
-			return 0, err
+		return m.Client.Agent.Query().Where(agent.IDIn(onlineIDs...), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
+	}
+}
+
+// SaveAgentsLastSeen stores the date of the last heartbeat received from each agent
+func (m *Model) SaveAgentsLastSeen(lastSeen map[string]time.Time) error {
+	for agentID, when := range lastSeen {
+		if err := m.Client.Agent.Update().
+			Where(agent.ID(agentID), agent.Or(agent.LastSeenIsNil(), agent.LastSeenLT(when))).
+			SetLastSeen(when).
+			Exec(context.Background()); err != nil {
+			return err
 		}
-		return count, err
 	}
+	return nil
+}
+
+// GetAgentsLastSeenSince returns the date of the last heartbeat of the agents seen after the date
+func (m *Model) GetAgentsLastSeenSince(since time.Time) (map[string]time.Time, error) {
+	agents, err := m.Client.Agent.Query().Where(agent.LastSeenGT(since)).Select(agent.FieldID, agent.FieldLastSeen).All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	lastSeen := map[string]time.Time{}
+	for _, a := range agents {
+		lastSeen[a.ID] = a.LastSeen
+	}
+	return lastSeen, nil
 }
 
-func (m *Model) CountAgentsNotReportedLast24h(c *partials.CommonInfo) (int, error) {
+// DeleteAgent moves the agent to the recycle bin of the tenant, it's purged once the retention has passed
+func (m *Model) DeleteAgent(agentId string, c *partials.CommonInfo) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	tenantID, err := strconv.Atoi(c.TenantID)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
+	query := m.Client.Agent.UpdateOneID(agentId).Where(agent.DeletedAtIsNil()).SetDeletedAt(time.Now()).SetDeletedTenantID(tenantID)
 	if siteID == -1 {
-		count, err := m.Client.Agent.Query().Where(agent.LastContactLT(time.Now().AddDate(0, 0, -1)), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
-		if err != nil {
-			return 0, err
-		}
-		return count, err
+		err = query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
 	} else {
-		count, err := m.Client.Agent.Query().Where(agent.LastContactLT(time.Now().AddDate(0, 0, -1)), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background())
-		if err != nil {
-			return 0, err
-		}
-		return count, err
+		err = query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
 	}
+	return err
 }
 
-func (m *Model) DeleteAgent(agentId string, c *partials.CommonInfo) error {
+// DeleteAgents moves the agents of the tenant, or of the site, to the recycle bin and returns how many
+// have been moved
+func (m *Model) DeleteAgents(agentIDs []string, c *partials.CommonInfo) (int, error) {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	tenantID, err := strconv.Atoi(c.TenantID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	query := m.Client.Agent.Update().Where(agent.IDIn(agentIDs...), agent.DeletedAtIsNil()).SetDeletedAt(time.Now()).SetDeletedTenantID(tenantID)
 	if siteID == -1 {
-		err = m.Client.Agent.DeleteOneID(agentId).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
-		if err != nil {
-			return err
-		}
-	} else {
-		err = m.Client.Agent.DeleteOneID(agentId).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
-		if err != nil {
-			return err
-		}
+		return query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Save(context.Background())
 	}
-	return nil
+	return query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Save(context.Background())
 }
 
 func (m *Model) EnableAgent(agentId string, c *partials.CommonInfo) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
 		return err
@@ -707,23 +853,27 @@ func (m *Model) CountWaitingForAdmissionAgents(c *partials.CommonInfo) (int, err
 }
 
 func (m *Model) AgentsExists(c *partials.CommonInfo) (bool, error) {
-	siteID, err := strconv.Atoi(c.SiteID)
-	if err != nil {
-		return false, err
-	}
-	tenantID, err := strconv.Atoi(c.TenantID)
-	if err != nil {
-		return false, err
-	}
+	return cached(m.Cache, cacheKeyAgents+"-exist-"+c.TenantID+"-"+c.SiteID, func() (bool, error) {
+		siteID, err := strconv.Atoi(c.SiteID)
+		if err != nil {
+			return false, err
+		}
+		tenantID, err := strconv.Atoi(c.TenantID)
+		if err != nil {
+			return false, err
+		}
 
-	if siteID == -1 {
-		return m.Client.Agent.Query().Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Exist(context.Background())
-	} else {
-		return m.Client.Agent.Query().Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Exist(context.Background())
-	}
+		if siteID == -1 {
+			return m.Client.Agent.Query().Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Exist(context.Background())
+		} else {
+			return m.Client.Agent.Query().Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission)).Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Exist(context.Background())
+		}
+	})
 }
 
 func (m *Model) DeleteAllAgents(c *partials.CommonInfo) (int, error) {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
 		return 0, err
@@ -734,9 +884,9 @@ func (m *Model) DeleteAllAgents(c *partials.CommonInfo) (int, error) {
 	}
 
 	if siteID == -1 {
-		return m.Client.Agent.Delete().Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
+		return m.Client.Agent.Update().Where(agent.DeletedAtIsNil(), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).SetDeletedAt(time.Now()).SetDeletedTenantID(tenantID).Save(context.Background())
 	} else {
-		return m.Client.Agent.Delete().Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
+		return m.Client.Agent.Update().Where(agent.DeletedAtIsNil(), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetDeletedAt(time.Now()).SetDeletedTenantID(tenantID).Save(context.Background())
 	}
 }
 
@@ -1034,3 +1184,35 @@ func (m *Model) GetAgentNetBirdById(agentId string, c *partials.CommonInfo) (*en
 		return agent, err
 	}
 }
+
+// CountAgentsInventory returns how many software, updates, printers and network adapters have been reported
+// by the agents, the inventory rows removed with them
+func (m *Model) CountAgentsInventory(agentIDs []string) (int, error) {
+	ctx := context.Background()
+
+	if len(agentIDs) == 0 {
+		return 0, nil
+	}
+
+	apps, err := m.Client.App.Query().Where(app.HasOwnerWith(agent.IDIn(agentIDs...))).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updates, err := m.Client.Update.Query().Where(update.HasOwnerWith(agent.IDIn(agentIDs...))).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	printers, err := m.Client.Printer.Query().Where(printer.HasOwnerWith(agent.IDIn(agentIDs...))).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	adapters, err := m.Client.NetworkAdapter.Query().Where(networkadapter.HasOwnerWith(agent.IDIn(agentIDs...))).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return apps + updates + printers + adapters, nil
+}
@@ -28,6 +28,7 @@ type AgentsTestSuite struct {
 
 func (suite *AgentsTestSuite) SetupTest() {
 	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
 	suite.model = Model{Client: client}
 
 	t, err := suite.model.CreateDefaultTenant()
@@ -251,6 +252,32 @@ func (suite *AgentsTestSuite) TestGetAgentsByPage() {
 	}
 }
 
+func (suite *AgentsTestSuite) TestGetAgentsByHardware() {
+	// agent0 has 2 cores and 4 GB, agent1 4 cores and 8 GB and agent2 8 cores and 16 GB
+	for i := 0; i < 3; i++ {
+		err := suite.model.Client.Computer.Create().
+			SetManufacturer("manufacturer").
+			SetModel("model").
+			SetProcessorCores(int64(2 << i)).
+			SetMemory(uint64(4096 << i)).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create computer")
+	}
+
+	agents, err := suite.model.GetAgentsByHardware(suite.commonInfo, 4, 0)
+	assert.NoError(suite.T(), err, "should get agents by CPU cores")
+	assert.Equal(suite.T(), []string{"agent1", "agent2"}, agentIDs(agents))
+
+	agents, err = suite.model.GetAgentsByHardware(suite.commonInfo, 4, 16)
+	assert.NoError(suite.T(), err, "should get agents by CPU cores and RAM")
+	assert.Equal(suite.T(), []string{"agent2"}, agentIDs(agents))
+
+	agents, err = suite.model.GetAgentsByHardware(suite.commonInfo, 0, 0)
+	assert.NoError(suite.T(), err, "should get all agents without filters")
+	assert.Equal(suite.T(), 7, len(agents), "should include agents without hardware info")
+}
+
 func (suite *AgentsTestSuite) TestGetAgentById() {
 	var err error
 
@@ -305,16 +332,45 @@ func (suite *AgentsTestSuite) TestCountAllAgents() {
 	assert.Equal(suite.T(), 1, count, "should count 1 agents")
 }
 
-func (suite *AgentsTestSuite) TestCountAgentsReportedLast24h() {
-	count, err := suite.model.CountAgentsReportedLast24h(suite.commonInfo)
-	assert.NoError(suite.T(), err, "should count agents that reported in last 24h")
-	assert.Equal(suite.T(), 6, count, "should count 6 agents that reported in last 24h")
+func (suite *AgentsTestSuite) TestGetAgentIDsBySelection() {
+	ids, err := suite.model.GetAgentIDsBySelection(filters.AgentFilter{}, nil, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get agents matching an empty filter")
+	assert.Equal(suite.T(), 7, len(ids), "should get all agents")
+
+	f := filters.AgentFilter{AgentStatusOptions: []string{"Enabled"}}
+	ids, err = suite.model.GetAgentIDsBySelection(f, nil, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get agents matching the filter")
+	assert.ElementsMatch(suite.T(), []string{"agent0", "agent2", "agent4", "agent6"}, ids, "should get enabled agents")
+
+	ids, err = suite.model.GetAgentIDsBySelection(f, []string{"agent2", "agent3"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get agents matching the filter with exclusions")
+	assert.ElementsMatch(suite.T(), []string{"agent0", "agent4", "agent6"}, ids, "should honor exclusions")
+
+	otherTenant := &partials.CommonInfo{TenantID: "9999", SiteID: "-1"}
+	ids, err = suite.model.GetAgentIDsBySelection(filters.AgentFilter{}, nil, otherTenant)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(ids), "should not get agents from other tenants")
+
+	_, err = suite.model.GetAgentIDsBySelection(filters.AgentFilter{}, nil, &partials.CommonInfo{TenantID: "a", SiteID: "-1"})
+	assert.Error(suite.T(), err, "should fail with an invalid tenant")
+}
+
+func (suite *AgentsTestSuite) TestCountAgentsBySelection() {
+	count, err := suite.model.CountAgentsBySelection(filters.AgentFilter{}, []string{"agent1", "agent5"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count selected agents")
+	assert.Equal(suite.T(), 5, count, "should not count excluded agents")
+
+	count, err = suite.model.CountAgentsBySelection(filters.AgentFilter{Nickname: "agent6"}, []string{"agent1"}, suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count, "exclusions outside the filter should not change the count")
 }
 
-func (suite *AgentsTestSuite) TestCountAgentsNotReportedLast24h() {
-	count, err := suite.model.CountAgentsNotReportedLast24h(suite.commonInfo)
-	assert.NoError(suite.T(), err, "should count agents that not reported in last 24h")
-	assert.Equal(suite.T(), 0, count, "should count 6 agents that not reported in last 24h")
+func (suite *AgentsTestSuite) TestCountAgentsByReportStatus() {
+	counts, err := suite.model.CountAgentsByReportStatus(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count agents by report status")
+	assert.Equal(suite.T(), 6, counts[AgentReportOnTime], "should count 6 agents that reported on time")
+	assert.Equal(suite.T(), 0, counts[AgentReportLate], "should count 0 late agents")
+	assert.Equal(suite.T(), 0, counts[AgentReportOffline], "should count 0 offline agents")
 }
 
 func (suite *AgentsTestSuite) TestDeleteAgent() {
@@ -429,12 +485,72 @@ func (suite *AgentsTestSuite) TestDeleteAllAgents() {
 	assert.Equal(suite.T(), false, exists, "agents should not exist")
 }
 
+func (suite *AgentsTestSuite) TestDeleteAgents() {
+	count, err := suite.model.DeleteAgents([]string{"agent0", "agent1", "agent9"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should delete the agents")
+	assert.Equal(suite.T(), 2, count, "should only delete the agents that exist")
+
+	count, err = suite.model.DeleteAgents([]string{"agent0", "agent2"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should delete the agents")
+	assert.Equal(suite.T(), 1, count, "should skip the agents in the recycle bin")
+
+	_, err = suite.model.GetAgentById("agent0", suite.commonInfo)
+	assert.Error(suite.T(), err, "should move the agents to the recycle bin")
+}
+
+func (suite *AgentsTestSuite) TestCountAgentsInventory() {
+	for i, id := range []string{"agent0", "agent1"} {
+		for range i + 1 {
+			err := suite.model.Client.App.Create().SetName("App").SetVersion("1.0").SetOwnerID(id).Exec(context.Background())
+			assert.NoError(suite.T(), err)
+		}
+		err := suite.model.Client.Printer.Create().SetName("printer").SetOwnerID(id).Exec(context.Background())
+		assert.NoError(suite.T(), err)
+	}
+
+	count, err := suite.model.CountAgentsInventory([]string{"agent0", "agent1"})
+	assert.NoError(suite.T(), err, "should count the inventory of the agents")
+	assert.Equal(suite.T(), 5, count)
+
+	count, err = suite.model.CountAgentsInventory([]string{"agent0"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count, "should only count the inventory of the agents given")
+
+	count, err = suite.model.CountAgentsInventory(nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
 func (suite *AgentsTestSuite) TestCountPendingUpdateAgents() {
 	count, err := suite.model.CountPendingUpdateAgents(suite.commonInfo)
 	assert.NoError(suite.T(), err, "should count pending update agents")
 	assert.Equal(suite.T(), 4, count, "should count 4 agents with pending updates")
 }
 
+func (suite *AgentsTestSuite) TestCountOnlineAgents() {
+	count, err := suite.model.CountOnlineAgents([]string{"agent0", "agent1", "agent2", "agent9"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count online agents")
+	assert.Equal(suite.T(), 2, count, "should count 2 online agents, excluding waiting for admission and unknown agents")
+
+	count, err = suite.model.CountOnlineAgents([]string{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count online agents")
+	assert.Equal(suite.T(), 0, count, "should count no online agents")
+}
+
+func (suite *AgentsTestSuite) TestSaveAgentsLastSeen() {
+	now := time.Now()
+	err := suite.model.SaveAgentsLastSeen(map[string]time.Time{"agent0": now, "agent2": now.Add(-time.Hour)})
+	assert.NoError(suite.T(), err, "should save last seen dates")
+
+	err = suite.model.SaveAgentsLastSeen(map[string]time.Time{"agent0": now.Add(-time.Minute)})
+	assert.NoError(suite.T(), err, "should ignore older last seen dates")
+
+	lastSeen, err := suite.model.GetAgentsLastSeenSince(now.Add(-10 * time.Minute))
+	assert.NoError(suite.T(), err, "should get last seen dates")
+	assert.Equal(suite.T(), 1, len(lastSeen), "should only get agents seen recently")
+	assert.True(suite.T(), now.Equal(lastSeen["agent0"]), "should keep the newest last seen date")
+}
+
 func (suite *AgentsTestSuite) TestCountNoAutoupdateAgents() {
 	count, err := suite.model.CountNoAutoupdateAgents(suite.commonInfo)
 	assert.NoError(suite.T(), err, "should count no autoupdate agents")
@@ -640,6 +756,40 @@ func (suite *AgentsTestSuite) TestGetUpdateAgentsByPage() {
 
 }
 
+func (suite *AgentsTestSuite) TestGetAgentsAfter() {
+	items, err := suite.model.GetAgentsAfter("", 3, filters.AgentFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get the first agents")
+	if assert.Len(suite.T(), items, 3) {
+		assert.Equal(suite.T(), "agent0", items[0].ID)
+		assert.Equal(suite.T(), "agent2", items[2].ID)
+	}
+
+	items, err = suite.model.GetAgentsAfter("agent2", 3, filters.AgentFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get the agents after the cursor")
+	if assert.Len(suite.T(), items, 3) {
+		assert.Equal(suite.T(), "agent3", items[0].ID)
+		assert.Equal(suite.T(), "agent5", items[2].ID)
+	}
+
+	items, err = suite.model.GetAgentsAfter("agent5", 3, filters.AgentFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get the last page")
+	if assert.Len(suite.T(), items, 1) {
+		assert.Equal(suite.T(), "agent6", items[0].ID)
+	}
+
+	items, err = suite.model.GetAgentsAfter("agent0", 3, filters.AgentFilter{AgentStatusOptions: []string{"Disabled"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should apply the filters")
+	assert.Len(suite.T(), items, 2)
+}
+
 func TestAgentsTestSuite(t *testing.T) {
 	suite.Run(t, new(AgentsTestSuite))
 }
+
+func agentIDs(agents []*openuem_ent.Agent) []string {
+	ids := []string{}
+	for _, a := range agents {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
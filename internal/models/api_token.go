@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/apitoken"
+)
+
+// APITokenPrefix starts the value of every API token so leaked tokens can be found by secret scanners
+const APITokenPrefix = "ouem_"
+
+// ErrInvalidAPIToken is returned for unknown or expired API tokens
+var ErrInvalidAPIToken = errors.New("invalid API token")
+
+// CreateAPIToken creates an API token for the user and returns its value. Only a SHA-256 hash
+// of the value is stored so the value can't be shown again
+func (m *Model) CreateAPIToken(userID, name string, expiresAt *time.Time) (string, *ent.APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	value := APITokenPrefix + hex.EncodeToString(secret)
+
+	token, err := m.Client.APIToken.Create().
+		SetUserID(userID).
+		SetName(name).
+		SetTokenHash(hashAPIToken(value)).
+		SetCreatedAt(time.Now()).
+		SetNillableExpiresAt(expiresAt).
+		Save(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+
+	return value, token, nil
+}
+
+// GetAPITokens returns the API tokens of the user, newest first
+func (m *Model) GetAPITokens(userID string) ([]*ent.APIToken, error) {
+	return m.Client.APIToken.Query().
+		Where(apitoken.UserID(userID)).
+		Order(ent.Desc(apitoken.FieldCreatedAt), ent.Desc(apitoken.FieldID)).
+		All(context.Background())
+}
+
+// DeleteAPIToken revokes an API token of the user
+func (m *Model) DeleteAPIToken(userID string, tokenID int) error {
+	_, err := m.Client.APIToken.Delete().
+		Where(apitoken.ID(tokenID), apitoken.UserID(userID)).
+		Exec(context.Background())
+	return err
+}
+
+// AuthenticateAPIToken returns the API token with that value if it hasn't expired and records its use
+func (m *Model) AuthenticateAPIToken(value string) (*ent.APIToken, error) {
+	if !strings.HasPrefix(value, APITokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	token, err := m.Client.APIToken.Query().Where(apitoken.TokenHash(hashAPIToken(value))).Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if err := m.Client.APIToken.UpdateOneID(token.ID).SetLastUsedAt(time.Now()).Exec(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func hashAPIToken(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type APITokenTestSuite struct {
+	suite.Suite
+	t     enttest.TestingT
+	model Model
+}
+
+func (suite *APITokenTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+}
+
+func (suite *APITokenTestSuite) TestCreateAPIToken() {
+	value, token, err := suite.model.CreateAPIToken("admin", "CMDB", nil)
+	assert.NoError(suite.T(), err, "should create API token")
+	assert.True(suite.T(), strings.HasPrefix(value, APITokenPrefix), "should prefix the value")
+	assert.Equal(suite.T(), hashAPIToken(value), token.TokenHash, "should store the hash of the value")
+	assert.NotContains(suite.T(), token.TokenHash, value)
+
+	tokens, err := suite.model.GetAPITokens("admin")
+	assert.NoError(suite.T(), err, "should get API tokens")
+	assert.Len(suite.T(), tokens, 1)
+
+	tokens, err = suite.model.GetAPITokens("other")
+	assert.NoError(suite.T(), err, "should get API tokens")
+	assert.Empty(suite.T(), tokens, "should only get the tokens of the user")
+}
+
+func (suite *APITokenTestSuite) TestAuthenticateAPIToken() {
+	value, token, err := suite.model.CreateAPIToken("admin", "CMDB", nil)
+	assert.NoError(suite.T(), err, "should create API token")
+
+	authenticated, err := suite.model.AuthenticateAPIToken(value)
+	assert.NoError(suite.T(), err, "should authenticate the token")
+	assert.Equal(suite.T(), token.ID, authenticated.ID)
+	assert.Equal(suite.T(), "admin", authenticated.UserID)
+
+	tokens, err := suite.model.GetAPITokens("admin")
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), tokens[0].LastUsedAt, "should record the use of the token")
+
+	_, err = suite.model.AuthenticateAPIToken(value + "0")
+	assert.ErrorIs(suite.T(), err, ErrInvalidAPIToken, "should not authenticate unknown tokens")
+
+	expiresAt := time.Now().Add(-time.Minute)
+	expired, _, err := suite.model.CreateAPIToken("admin", "Expired", &expiresAt)
+	assert.NoError(suite.T(), err, "should create API token")
+	_, err = suite.model.AuthenticateAPIToken(expired)
+	assert.ErrorIs(suite.T(), err, ErrInvalidAPIToken, "should not authenticate expired tokens")
+}
+
+func (suite *APITokenTestSuite) TestDeleteAPIToken() {
+	value, token, err := suite.model.CreateAPIToken("admin", "CMDB", nil)
+	assert.NoError(suite.T(), err, "should create API token")
+
+	err = suite.model.DeleteAPIToken("other", token.ID)
+	assert.NoError(suite.T(), err)
+	_, err = suite.model.AuthenticateAPIToken(value)
+	assert.NoError(suite.T(), err, "should not delete the tokens of other users")
+
+	err = suite.model.DeleteAPIToken("admin", token.ID)
+	assert.NoError(suite.T(), err, "should delete API token")
+	_, err = suite.model.AuthenticateAPIToken(value)
+	assert.ErrorIs(suite.T(), err, ErrInvalidAPIToken, "should not authenticate deleted tokens")
+}
+
+func TestAPITokenTestSuite(t *testing.T) {
+	suite.Run(t, new(APITokenTestSuite))
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/apitoken"
+	"github.com/open-uem/ent/apiusage"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/tenant"
+)
+
+// Rate limits of the API used until the hoster admins change them, in requests per minute with bursts of
+// the given number of requests
+const (
+	DefaultAPIRateLimit       = 300
+	DefaultAPIRateBurst       = 20
+	DefaultAPITenantRateLimit = 1200
+	DefaultAPITenantRateBurst = 100
+)
+
+// APIRateLimits are the requests per minute allowed for each API token and for all the tokens used with a
+// tenant. A zero rate doesn't limit the requests
+type APIRateLimits struct {
+	TokenRate   int
+	TokenBurst  int
+	TenantRate  int
+	TenantBurst int
+}
+
+// APIUsage is the number of requests of an API token to a tenant in a day, TenantID is zero for the
+// requests that were not made to a tenant. Limited are the requests rejected by the rate limits
+type APIUsage struct {
+	Day      time.Time
+	TokenID  int
+	UserID   string
+	TenantID int
+	Requests int
+	Limited  int
+}
+
+// APIUsageEntry is the usage of a day with the names of the token and the tenant, a deleted token has
+// no name
+type APIUsageEntry struct {
+	APIUsage
+	TokenName  string
+	TenantName string
+}
+
+// APIUsageTotal is the number of requests of a tenant or of an API token in a period
+type APIUsageTotal struct {
+	ID       int
+	Name     string
+	UserID   string
+	Requests int
+	Limited  int
+}
+
+// GetAPIRateLimits returns the rate limits of the API from the global settings, they're read on every
+// API request so they're cached
+func (m *Model) GetAPIRateLimits() (APIRateLimits, error) {
+	return cached(m.Cache, cacheKeyAPIRateLimits, func() (APIRateLimits, error) {
+		s, err := m.Client.Settings.Query().
+			Select(settings.FieldAPIRateLimit, settings.FieldAPIRateBurst, settings.FieldAPITenantRateLimit, settings.FieldAPITenantRateBurst).
+			Where(settings.Not(settings.HasTenant())).
+			Only(context.Background())
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return APIRateLimits{TokenRate: DefaultAPIRateLimit, TokenBurst: DefaultAPIRateBurst, TenantRate: DefaultAPITenantRateLimit, TenantBurst: DefaultAPITenantRateBurst}, nil
+			}
+			return APIRateLimits{}, err
+		}
+
+		return APIRateLimits{
+			TokenRate:   s.APIRateLimit,
+			TokenBurst:  s.APIRateBurst,
+			TenantRate:  s.APITenantRateLimit,
+			TenantBurst: s.APITenantRateBurst,
+		}, nil
+	})
+}
+
+// SaveAPIRateLimits stores the rate limits of the API in the global settings
+func (m *Model) SaveAPIRateLimits(l APIRateLimits) error {
+	defer m.Cache.Invalidate(cacheKeyAPIRateLimits)
+
+	return m.Client.Settings.Update().
+		Where(settings.Not(settings.HasTenant())).
+		SetAPIRateLimit(l.TokenRate).
+		SetAPIRateBurst(l.TokenBurst).
+		SetAPITenantRateLimit(l.TenantRate).
+		SetAPITenantRateBurst(l.TenantBurst).
+		Exec(context.Background())
+}
+
+// AddAPIUsage adds the requests counted since the last call to the usage of each token, tenant and day
+func (m *Model) AddAPIUsage(usage []APIUsage) error {
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range usage {
+		requests, limited := u.Requests, u.Limited
+		err := tx.APIUsage.Create().
+			SetDay(apiUsageDay(u.Day)).
+			SetTokenID(u.TokenID).
+			SetUserID(u.UserID).
+			SetTenantID(u.TenantID).
+			SetRequests(requests).
+			SetLimited(limited).
+			OnConflictColumns(apiusage.FieldDay, apiusage.FieldTokenID, apiusage.FieldTenantID).
+			Update(func(up *ent.APIUsageUpsert) {
+				up.AddRequests(requests)
+				up.AddLimited(limited)
+			}).
+			Exec(ctx)
+		if err != nil {
+			return rollback(tx, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAPIUsage returns the usage of the API since the day, newest first
+func (m *Model) GetAPIUsage(since time.Time) ([]APIUsageEntry, error) {
+	ctx := context.Background()
+
+	rows, err := m.Client.APIUsage.Query().Where(apiusage.DayGTE(apiUsageDay(since))).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenIDs := []int{}
+	tenantIDs := []int{}
+	for _, r := range rows {
+		tokenIDs = append(tokenIDs, r.TokenID)
+		if r.TenantID != 0 {
+			tenantIDs = append(tenantIDs, r.TenantID)
+		}
+	}
+
+	tokens, err := m.Client.APIToken.Query().Where(apitoken.IDIn(tokenIDs...)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokenNames := map[int]string{}
+	for _, t := range tokens {
+		tokenNames[t.ID] = t.Name
+	}
+
+	tenants, err := m.Client.Tenant.Query().Where(tenant.IDIn(tenantIDs...)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenantNames := map[int]string{}
+	for _, t := range tenants {
+		tenantNames[t.ID] = t.Description
+	}
+
+	entries := []APIUsageEntry{}
+	for _, r := range rows {
+		entries = append(entries, APIUsageEntry{
+			APIUsage:   APIUsage{Day: r.Day, TokenID: r.TokenID, UserID: r.UserID, TenantID: r.TenantID, Requests: r.Requests, Limited: r.Limited},
+			TokenName:  tokenNames[r.TokenID],
+			TenantName: tenantNames[r.TenantID],
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Day.Equal(entries[j].Day) {
+			return entries[i].Day.After(entries[j].Day)
+		}
+		if entries[i].TenantName != entries[j].TenantName {
+			return entries[i].TenantName < entries[j].TenantName
+		}
+		return entries[i].TokenID < entries[j].TokenID
+	})
+
+	return entries, nil
+}
+
+// APIUsageByTenant adds up the usage of each tenant, the one with more requests first
+func APIUsageByTenant(entries []APIUsageEntry) []APIUsageTotal {
+	return apiUsageTotals(entries, func(e APIUsageEntry) APIUsageTotal {
+		return APIUsageTotal{ID: e.TenantID, Name: e.TenantName}
+	})
+}
+
+// APIUsageByToken adds up the usage of each API token, the one with more requests first
+func APIUsageByToken(entries []APIUsageEntry) []APIUsageTotal {
+	return apiUsageTotals(entries, func(e APIUsageEntry) APIUsageTotal {
+		return APIUsageTotal{ID: e.TokenID, Name: e.TokenName, UserID: e.UserID}
+	})
+}
+
+func apiUsageTotals(entries []APIUsageEntry, key func(e APIUsageEntry) APIUsageTotal) []APIUsageTotal {
+	totals := map[int]*APIUsageTotal{}
+	for _, e := range entries {
+		k := key(e)
+		total, ok := totals[k.ID]
+		if !ok {
+			total = &k
+			totals[k.ID] = total
+		}
+		total.Requests += e.Requests
+		total.Limited += e.Limited
+	}
+
+	result := []APIUsageTotal{}
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// apiUsageDay is the day of the usage, in UTC so all the consoles count the same day
+func apiUsageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
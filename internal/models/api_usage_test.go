@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type APIUsageTestSuite struct {
+	suite.Suite
+	t     enttest.TestingT
+	model Model
+}
+
+func (suite *APIUsageTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+}
+
+func (suite *APIUsageTestSuite) TestAPIRateLimits() {
+	limits, err := suite.model.GetAPIRateLimits()
+	assert.NoError(suite.T(), err, "should get the default limits without settings")
+	assert.Equal(suite.T(), APIRateLimits{TokenRate: DefaultAPIRateLimit, TokenBurst: DefaultAPIRateBurst, TenantRate: DefaultAPITenantRateLimit, TenantBurst: DefaultAPITenantRateBurst}, limits)
+
+	assert.NoError(suite.T(), suite.model.CreateInitialSettings())
+	saved := APIRateLimits{TokenRate: 60, TokenBurst: 5, TenantRate: 0, TenantBurst: 0}
+	assert.NoError(suite.T(), suite.model.SaveAPIRateLimits(saved), "should save the limits")
+
+	limits, err = suite.model.GetAPIRateLimits()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), saved, limits)
+}
+
+func (suite *APIUsageTestSuite) TestAddAPIUsage() {
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+
+	err := suite.model.AddAPIUsage([]APIUsage{
+		{Day: today, TokenID: 1, UserID: "admin", TenantID: 1, Requests: 10, Limited: 2},
+		{Day: today, TokenID: 1, UserID: "admin", TenantID: 0, Requests: 1},
+		{Day: today, TokenID: 2, UserID: "operator", TenantID: 1, Requests: 4},
+		{Day: yesterday, TokenID: 1, UserID: "admin", TenantID: 1, Requests: 7},
+	})
+	assert.NoError(suite.T(), err, "should add the usage")
+
+	err = suite.model.AddAPIUsage([]APIUsage{{Day: today, TokenID: 1, UserID: "admin", TenantID: 1, Requests: 5, Limited: 1}})
+	assert.NoError(suite.T(), err, "should add the usage to the stored one")
+
+	entries, err := suite.model.GetAPIUsage(today)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 3, "should only get the usage since the day")
+
+	byToken := APIUsageByToken(entries)
+	assert.Equal(suite.T(), []APIUsageTotal{
+		{ID: 1, UserID: "admin", Requests: 16, Limited: 3},
+		{ID: 2, UserID: "operator", Requests: 4},
+	}, byToken, "should add up the usage of each token")
+
+	entries, err = suite.model.GetAPIUsage(yesterday)
+	assert.NoError(suite.T(), err)
+	byTenant := APIUsageByTenant(entries)
+	assert.Equal(suite.T(), []APIUsageTotal{
+		{ID: 1, Requests: 26, Limited: 3},
+		{ID: 0, Requests: 1},
+	}, byTenant, "should add up the usage of each tenant")
+}
+
+func TestAPIUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(APIUsageTestSuite))
+}
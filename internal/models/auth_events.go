@@ -0,0 +1,413 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/authalert"
+	"github.com/open-uem/ent/authevent"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/ent/usertenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// Authentication events recorded for the alerts and the investigations
+const (
+	AuthEventLoginFailed     = "login_failed"
+	AuthEventLoginSucceeded  = "login_succeeded"
+	AuthEventResetCodeFailed = "reset_code_failed"
+)
+
+// Rules that raise authentication alerts
+const (
+	AuthAlertAccountFailures = "account_failures"
+	AuthAlertIPFailures      = "ip_failures"
+	AuthAlertNewNetwork      = "new_network"
+)
+
+// AuthAlertWindow is the period in which the failures are counted, an acknowledged alert isn't raised
+// again until the window after the acknowledgement has passed
+const AuthAlertWindow = time.Hour
+
+// DefaultAuthAlertFailures is the number of failures in the window that raises an alert if the
+// threshold hasn't been set
+const DefaultAuthAlertFailures = 50
+
+// AuthEventsRetention is how long the authentication events are kept
+const AuthEventsRetention = 90 * 24 * time.Hour
+
+// AuthAlertSettings are the rules enabled for a tenant
+type AuthAlertSettings struct {
+	Enabled bool
+	// AccountFailures and IPFailures are the failures in the window that raise an alert
+	AccountFailures int
+	IPFailures      int
+	// NewNetworks alerts when a tenant admin logs in from a network never seen before
+	NewNetworks bool
+}
+
+// SourceNetwork returns the network an address belongs to, the /24 for IPv4 and the /48 for IPv6, so
+// the addresses assigned by the same provider are considered the same source
+func SourceNetwork(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ip
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: addr.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// SaveAuthEvent records an authentication event, userID is the username entered if the account
+// doesn't exist
+func (m *Model) SaveAuthEvent(eventType, userID, ip string) error {
+	return m.Client.AuthEvent.Create().
+		SetType(eventType).
+		SetUserID(userID).
+		SetIPAddress(ip).
+		SetNetwork(SourceNetwork(ip)).
+		SetCreated(time.Now()).
+		Exec(context.Background())
+}
+
+// CountAuthEvents returns how many events of the tenant match the filter
+func (m *Model) CountAuthEvents(tenantID int, f filters.AuthEventFilter) (int, error) {
+	query, err := m.authEventsQuery(tenantID, f)
+	if err != nil {
+		return 0, err
+	}
+
+	return query.Count(context.Background())
+}
+
+// GetAuthEventsByPage returns the events of the tenant that match the filter, newest first
+func (m *Model) GetAuthEventsByPage(tenantID int, p partials.PaginationAndSort, f filters.AuthEventFilter) ([]*ent.AuthEvent, error) {
+	query, err := m.authEventsQuery(tenantID, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.
+		Order(ent.Desc(authevent.FieldCreated), ent.Desc(authevent.FieldID)).
+		Limit(p.PageSize).
+		Offset((p.CurrentPage - 1) * p.PageSize).
+		All(context.Background())
+}
+
+// authEventsQuery returns the events of the members of the tenant, the main tenant sees all the events
+// as its admins manage the console
+func (m *Model) authEventsQuery(tenantID int, f filters.AuthEventFilter) (*ent.AuthEventQuery, error) {
+	query := m.Client.AuthEvent.Query()
+
+	isMain, err := m.IsMainTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMain {
+		members, err := m.tenantMembers(tenantID, false)
+		if err != nil {
+			return nil, err
+		}
+		query.Where(authevent.UserIDIn(members...))
+	}
+
+	if f.Type != "" {
+		query.Where(authevent.Type(f.Type))
+	}
+	if f.Username != "" {
+		query.Where(authevent.UserIDContainsFold(f.Username))
+	}
+	if f.IPAddress != "" {
+		query.Where(authevent.IPAddressContains(f.IPAddress))
+	}
+	if from, err := time.ParseInLocation("2006-01-02", f.From, time.Local); err == nil {
+		query.Where(authevent.CreatedGTE(from))
+	}
+	if to, err := time.ParseInLocation("2006-01-02", f.To, time.Local); err == nil {
+		query.Where(authevent.CreatedLT(to.AddDate(0, 0, 1)))
+	}
+
+	return query, nil
+}
+
+// tenantMembers returns the usernames of the members of the tenant or of its admins only
+func (m *Model) tenantMembers(tenantID int, adminsOnly bool) ([]string, error) {
+	query := m.Client.UserTenant.Query().Where(usertenant.TenantID(tenantID))
+	if adminsOnly {
+		query.Where(usertenant.RoleEQ(usertenant.RoleAdmin))
+	}
+
+	return query.Select(usertenant.FieldUserID).Strings(context.Background())
+}
+
+// DeleteOldAuthEvents removes the events older than the retention period
+func (m *Model) DeleteOldAuthEvents() error {
+	_, err := m.Client.AuthEvent.Delete().Where(authevent.CreatedLT(time.Now().Add(-AuthEventsRetention))).Exec(context.Background())
+	return err
+}
+
+// GetAuthAlertSettings returns the alert rules of the tenant, the alerts are disabled if the tenant
+// has no settings yet
+func (m *Model) GetAuthAlertSettings(tenantID int) (AuthAlertSettings, error) {
+	s, err := m.Client.Settings.Query().
+		Select(settings.FieldAuthAlertsEnabled, settings.FieldAuthAlertsAccountFailures, settings.FieldAuthAlertsIPFailures, settings.FieldAuthAlertsNewNetworks).
+		Where(settings.HasTenantWith(tenant.ID(tenantID))).
+		Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return AuthAlertSettings{AccountFailures: DefaultAuthAlertFailures, IPFailures: DefaultAuthAlertFailures}, nil
+		}
+		return AuthAlertSettings{}, err
+	}
+
+	return authAlertSettings(s), nil
+}
+
+func authAlertSettings(s *ent.Settings) AuthAlertSettings {
+	alerts := AuthAlertSettings{
+		Enabled:         s.AuthAlertsEnabled,
+		AccountFailures: s.AuthAlertsAccountFailures,
+		IPFailures:      s.AuthAlertsIPFailures,
+		NewNetworks:     s.AuthAlertsNewNetworks,
+	}
+	if alerts.AccountFailures <= 0 {
+		alerts.AccountFailures = DefaultAuthAlertFailures
+	}
+	if alerts.IPFailures <= 0 {
+		alerts.IPFailures = DefaultAuthAlertFailures
+	}
+	return alerts
+}
+
+// SaveAuthAlertSettings stores the alert rules in the settings of the tenant
+func (m *Model) SaveAuthAlertSettings(tenantID int, s AuthAlertSettings) error {
+	if s.AccountFailures < 1 || s.IPFailures < 1 {
+		return fmt.Errorf("the thresholds must be at least one failure")
+	}
+
+	update := func() (int, error) {
+		return m.Client.Settings.Update().Where(settings.HasTenantWith(tenant.ID(tenantID))).
+			SetAuthAlertsEnabled(s.Enabled).
+			SetAuthAlertsAccountFailures(s.AccountFailures).
+			SetAuthAlertsIPFailures(s.IPFailures).
+			SetAuthAlertsNewNetworks(s.NewNetworks).
+			Save(context.Background())
+	}
+
+	n, err := update()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	if err := m.CloneGlobalSettings(tenantID); err != nil {
+		return err
+	}
+	_, err = update()
+	return err
+}
+
+// GetAuthAlertTenants returns the alert rules of the tenants that have them enabled
+func (m *Model) GetAuthAlertTenants() (map[int]AuthAlertSettings, error) {
+	all, err := m.Client.Settings.Query().
+		Where(settings.AuthAlertsEnabled(true), settings.HasTenant()).
+		WithTenant().
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := map[int]AuthAlertSettings{}
+	for _, s := range all {
+		if s.Edges.Tenant != nil {
+			tenants[s.Edges.Tenant.ID] = authAlertSettings(s)
+		}
+	}
+	return tenants, nil
+}
+
+// EvaluateAuthAlerts applies the rules of the tenant to the events and returns the alerts that have
+// been raised and must be notified. The failures are counted in the last window, the logins from new
+// networks are checked since the previous evaluation
+func (m *Model) EvaluateAuthAlerts(tenantID int, s AuthAlertSettings, since time.Time) ([]*ent.AuthAlert, error) {
+	ctx := context.Background()
+	now := time.Now()
+	failures := []string{AuthEventLoginFailed, AuthEventResetCodeFailed}
+
+	isMain, err := m.IsMainTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := m.tenantMembers(tenantID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	raised := []*ent.AuthAlert{}
+	raise := func(rule, subject, details string, count int) error {
+		alert, err := m.raiseAuthAlert(tenantID, rule, subject, details, count, now)
+		if alert != nil {
+			raised = append(raised, alert)
+		}
+		return err
+	}
+
+	var byAccount []struct {
+		UserID string `json:"user_id"`
+		Count  int    `json:"count"`
+	}
+	if err := m.Client.AuthEvent.Query().
+		Where(authevent.TypeIn(failures...), authevent.CreatedGTE(now.Add(-AuthAlertWindow)), authevent.UserIDIn(members...)).
+		GroupBy(authevent.FieldUserID).
+		Aggregate(ent.Count()).
+		Scan(ctx, &byAccount); err != nil {
+		return nil, err
+	}
+	for _, a := range byAccount {
+		if a.Count >= s.AccountFailures {
+			if err := raise(AuthAlertAccountFailures, a.UserID, "", a.Count); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The addresses aren't linked to a tenant, the failures from an address are for the main tenant admins
+	if isMain {
+		var byIP []struct {
+			IPAddress string `json:"ip_address"`
+			Count     int    `json:"count"`
+		}
+		if err := m.Client.AuthEvent.Query().
+			Where(authevent.TypeIn(failures...), authevent.CreatedGTE(now.Add(-AuthAlertWindow))).
+			GroupBy(authevent.FieldIPAddress).
+			Aggregate(ent.Count()).
+			Scan(ctx, &byIP); err != nil {
+			return nil, err
+		}
+		for _, a := range byIP {
+			if a.Count >= s.IPFailures {
+				if err := raise(AuthAlertIPFailures, a.IPAddress, "", a.Count); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if s.NewNetworks {
+		admins, err := m.tenantMembers(tenantID, true)
+		if err != nil {
+			return nil, err
+		}
+
+		logins, err := m.Client.AuthEvent.Query().
+			Where(authevent.Type(AuthEventLoginSucceeded), authevent.CreatedGTE(since), authevent.UserIDIn(admins...)).
+			Order(ent.Asc(authevent.FieldCreated)).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range logins {
+			isNew, err := m.isNewAuthNetwork(l)
+			if err != nil {
+				return nil, err
+			}
+			if isNew {
+				if err := raise(AuthAlertNewNetwork, l.UserID+" "+l.Network, l.IPAddress, 1); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return raised, nil
+}
+
+// isNewAuthNetwork checks if the user had logged in before but never from the network of the login.
+// The first login of a user isn't considered new as there's nothing to compare with
+func (m *Model) isNewAuthNetwork(login *ent.AuthEvent) (bool, error) {
+	previous := m.Client.AuthEvent.Query().Where(
+		authevent.Type(AuthEventLoginSucceeded),
+		authevent.UserID(login.UserID),
+		authevent.CreatedLT(login.Created),
+	)
+
+	known, err := previous.Clone().Where(authevent.Network(login.Network)).Exist(context.Background())
+	if err != nil || known {
+		return false, err
+	}
+
+	return previous.Exist(context.Background())
+}
+
+// raiseAuthAlert creates the alert unless the same alert is still open or has been acknowledged
+// in the last window, in which case it's updated and nil is returned so it isn't notified again
+func (m *Model) raiseAuthAlert(tenantID int, rule, subject, details string, count int, now time.Time) (*ent.AuthAlert, error) {
+	ctx := context.Background()
+
+	last, err := m.Client.AuthAlert.Query().
+		Where(authalert.Rule(rule), authalert.Subject(subject), authalert.HasTenantWith(tenant.ID(tenantID))).
+		Order(ent.Desc(authalert.FieldFirstSeen)).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+
+	if last != nil && (last.AcknowledgedAt == nil || last.AcknowledgedAt.After(now.Add(-AuthAlertWindow))) {
+		return nil, m.Client.AuthAlert.UpdateOneID(last.ID).
+			SetCount(max(last.Count, count)).
+			SetLastSeen(now).
+			Exec(ctx)
+	}
+
+	return m.Client.AuthAlert.Create().
+		SetRule(rule).
+		SetSubject(subject).
+		SetDetails(details).
+		SetCount(count).
+		SetFirstSeen(now).
+		SetLastSeen(now).
+		SetTenantID(tenantID).
+		Save(ctx)
+}
+
+// GetAuthAlerts returns the latest alerts of the tenant, the open ones first
+func (m *Model) GetAuthAlerts(tenantID, limit int) ([]*ent.AuthAlert, error) {
+	return m.Client.AuthAlert.Query().
+		Where(authalert.HasTenantWith(tenant.ID(tenantID))).
+		Order(ent.Asc(authalert.FieldAcknowledged), ent.Desc(authalert.FieldLastSeen)).
+		Limit(limit).
+		All(context.Background())
+}
+
+// AcknowledgeAuthAlert marks the alert of the tenant as acknowledged by the user
+func (m *Model) AcknowledgeAuthAlert(tenantID, alertID int, userID string) error {
+	return dbError(m.Client.AuthAlert.UpdateOneID(alertID).
+		Where(authalert.HasTenantWith(tenant.ID(tenantID)), authalert.Acknowledged(false)).
+		SetAcknowledged(true).
+		SetAcknowledgedBy(userID).
+		SetAcknowledgedAt(time.Now()).
+		Exec(context.Background()))
+}
+
+// AuthAlertDescription describes the alert in the notifications
+func AuthAlertDescription(a *ent.AuthAlert) string {
+	switch a.Rule {
+	case AuthAlertAccountFailures:
+		return fmt.Sprintf("There have been %d failed login attempts for the account %s in the last hour", a.Count, a.Subject)
+	case AuthAlertIPFailures:
+		return fmt.Sprintf("There have been %d failed login attempts from the address %s in the last hour", a.Count, a.Subject)
+	case AuthAlertNewNetwork:
+		user, network, _ := strings.Cut(a.Subject, " ")
+		return fmt.Sprintf("The admin %s has logged in from %s, in the network %s where the account had never logged in from", user, a.Details, network)
+	}
+	return a.Rule + " " + a.Subject
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceNetwork(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", SourceNetwork("203.0.113.45"))
+	assert.Equal(t, "2001:db8:1::/48", SourceNetwork("2001:db8:1:2::10"))
+	assert.Equal(t, "unknown", SourceNetwork("unknown"))
+}
+
+func TestEvaluateAuthAlerts(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:authalerts?mode=memory&_fk=1")
+	defer client.Close()
+	ctx := context.Background()
+	m := Model{Client: client}
+
+	tenant, err := client.Tenant.Create().SetDescription("Tenant").SetIsDefault(true).Save(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, client.User.Create().SetID("admin").SetName("Admin").SetEmail("admin@example.com").SetCreated(time.Now()).Exec(ctx))
+	assert.NoError(t, m.AssignUserToTenant("admin", tenant.ID, UserTenantRoleAdmin, true))
+
+	for range 3 {
+		assert.NoError(t, m.SaveAuthEvent(AuthEventLoginFailed, "admin", "198.51.100.7"))
+	}
+	assert.NoError(t, client.AuthEvent.Create().SetType(AuthEventLoginSucceeded).SetUserID("admin").SetIPAddress("192.0.2.10").SetNetwork(SourceNetwork("192.0.2.10")).SetCreated(time.Now().AddDate(0, 0, -7)).Exec(ctx))
+	assert.NoError(t, m.SaveAuthEvent(AuthEventLoginSucceeded, "admin", "192.0.2.99"))
+	assert.NoError(t, m.SaveAuthEvent(AuthEventLoginSucceeded, "admin", "203.0.113.5"))
+
+	s := AuthAlertSettings{Enabled: true, AccountFailures: 3, IPFailures: 4, NewNetworks: true}
+	since := time.Now().Add(-time.Minute)
+	alerts, err := m.EvaluateAuthAlerts(tenant.ID, s, since)
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 2, "the address is below its threshold and 192.0.2.0/24 is a known network") {
+		assert.Equal(t, AuthAlertAccountFailures, alerts[0].Rule)
+		assert.Equal(t, 3, alerts[0].Count)
+		assert.Equal(t, AuthAlertNewNetwork, alerts[1].Rule)
+		assert.Equal(t, "admin 203.0.113.0/24", alerts[1].Subject)
+	}
+
+	assert.NoError(t, m.SaveAuthEvent(AuthEventLoginFailed, "admin", "198.51.100.7"))
+	alerts, err = m.EvaluateAuthAlerts(tenant.ID, s, since)
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 1, "open alerts should not be notified again") {
+		assert.Equal(t, AuthAlertIPFailures, alerts[0].Rule)
+		assert.Equal(t, "198.51.100.7", alerts[0].Subject)
+	}
+
+	open, err := m.GetAuthAlerts(tenant.ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, open, 3)
+	for _, a := range open {
+		assert.NoError(t, m.AcknowledgeAuthAlert(tenant.ID, a.ID, "admin"))
+	}
+	assert.ErrorIs(t, m.AcknowledgeAuthAlert(tenant.ID, open[0].ID, "admin"), ErrNotFound, "should not acknowledge twice")
+
+	alerts, err = m.EvaluateAuthAlerts(tenant.ID, s, since)
+	assert.NoError(t, err)
+	assert.Empty(t, alerts, "acknowledged alerts should not be notified again in the same window")
+}
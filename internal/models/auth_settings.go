@@ -3,11 +3,15 @@ package models
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/authentication"
 	"github.com/sethvargo/go-password/password"
 )
 
+// GetAuthenticationSettings returns the authentication settings, they're created with the singleton key
+// if they don't exist so concurrent requests don't create them twice
 func (m *Model) GetAuthenticationSettings() (*openuem_ent.Authentication, error) {
 
 	settings, err := m.Client.Authentication.Query().Only(context.Background())
@@ -16,14 +20,21 @@ func (m *Model) GetAuthenticationSettings() (*openuem_ent.Authentication, error)
 			return nil, err
 		}
 
-		return m.Client.Authentication.Create().Save(context.Background())
+		if err := m.Client.Authentication.Create().
+			SetSingleton(singletonKey).
+			OnConflictColumns(authentication.FieldSingleton).
+			Ignore().
+			Exec(context.Background()); err != nil {
+			return nil, err
+		}
+		return m.Client.Authentication.Query().Only(context.Background())
 	}
 
 	return settings, nil
 }
 
 func (m *Model) SaveAuthenticationSettings(useCertificates bool, allowRegister bool, useOIDC bool, provider string,
-	server string, clientID string, roleAdmin string, roleOperator string, roleUser string, autoCreate bool, autoApprove bool, usePasswd bool) error {
+	server string, clientID string, roleAdmin string, roleOperator string, roleUser string, autoCreate bool, autoApprove bool, usePasswd bool, authOrder string) error {
 
 	s, err := m.Client.Authentication.Query().Only(context.Background())
 	if err != nil {
@@ -42,7 +53,8 @@ func (m *Model) SaveAuthenticationSettings(useCertificates bool, allowRegister b
 		SetOIDCRoleOperator(roleOperator).
 		SetOIDCRoleUser(roleUser).
 		SetOIDCAutoCreateAccount(autoCreate).
-		SetOIDCAutoApprove(autoApprove)
+		SetOIDCAutoApprove(autoApprove).
+		SetAuthOrder(authOrder)
 
 	// Create encryption key for OIDC cookie
 	if useOIDC {
@@ -60,6 +72,64 @@ func (m *Model) SaveAuthenticationSettings(useCertificates bool, allowRegister b
 	return update.Exec(context.Background())
 }
 
+// LDAPSettings are the connection, search and role mapping settings of the LDAP authentication
+type LDAPSettings struct {
+	Enabled  bool
+	Host     string
+	Port     int
+	Security string
+	BindDN   string
+	// BindPassword is the password of the service account, it's encrypted when it's stored and kept if empty
+	BindPassword string
+	UserBaseDN   string
+	UserFilter   string
+	GroupBaseDN  string
+	GroupFilter  string
+	RoleAdmin    string
+	RoleOperator string
+	RoleUser     string
+	AutoCreate   bool
+	AutoApprove  bool
+}
+
+// SaveLDAPSettings stores the LDAP settings, the users' passwords are never stored
+func (m *Model) SaveLDAPSettings(l LDAPSettings) error {
+	s, err := m.GetAuthenticationSettings()
+	if err != nil {
+		return err
+	}
+
+	update := m.Client.Authentication.UpdateOneID(s.ID).
+		SetUseLDAP(l.Enabled).
+		SetLDAPHost(l.Host).
+		SetLDAPPort(l.Port).
+		SetLDAPSecurity(l.Security).
+		SetLDAPBindDN(l.BindDN).
+		SetLDAPUserBaseDN(l.UserBaseDN).
+		SetLDAPUserFilter(l.UserFilter).
+		SetLDAPGroupBaseDN(l.GroupBaseDN).
+		SetLDAPGroupFilter(l.GroupFilter).
+		SetLDAPRoleAdmin(l.RoleAdmin).
+		SetLDAPRoleOperator(l.RoleOperator).
+		SetLDAPRoleUser(l.RoleUser).
+		SetLDAPAutoCreateAccount(l.AutoCreate).
+		SetLDAPAutoApprove(l.AutoApprove)
+
+	// The password of the service account is not shown in the form, an empty one keeps the current one
+	// unless the bind DN has been removed
+	if l.BindPassword != "" {
+		encrypted, err := m.sealSecret(l.BindPassword)
+		if err != nil {
+			return fmt.Errorf("could not encrypt the LDAP bind password: %w", err)
+		}
+		update.SetLDAPBindPassword(encrypted)
+	} else if l.BindDN == "" {
+		update.SetLDAPBindPassword("")
+	}
+
+	return update.Exec(context.Background())
+}
+
 func (m *Model) ReEnableCertificatesAuth() error {
 
 	s, err := m.Client.Authentication.Query().Only(context.Background())
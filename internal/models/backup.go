@@ -0,0 +1,477 @@
+package models
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/databasebackup"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/softwarerepo"
+	"github.com/open-uem/openuem-console/internal/common/s3storage"
+)
+
+const (
+	BackupTriggerManual    = "manual"
+	BackupTriggerScheduled = "scheduled"
+
+	BackupStatusRunning = "running"
+	BackupStatusDone    = "done"
+	BackupStatusFailed  = "failed"
+
+	// BackupMethodPGDump archives are restored with pg_restore
+	BackupMethodPGDump = "pg_dump"
+	// BackupMethodCopy archives only have the data of the tables, they're restored with psql
+	// on a database whose schema has been created by the console
+	BackupMethodCopy = "copy"
+
+	// DefaultBackupRetention is how many successful backups are kept if it isn't set
+	DefaultBackupRetention = 7
+
+	// backupS3Prefix is where the backups are stored in the software repos
+	backupS3Prefix = "backups"
+)
+
+// ErrBackupRunning is returned when a backup is requested while another one is running
+var ErrBackupRunning = errors.New("a database backup is already running")
+
+// BackupConfig is set when the console starts, it can't be changed from the console so a
+// compromised admin account can't write backups anywhere in the server
+type BackupConfig struct {
+	// Dir is where the backups are stored when they aren't uploaded to a software repo
+	Dir string
+	// PGDumpPath is the pg_dump binary, the COPY based fallback is used if it can't be found
+	PGDumpPath string
+}
+
+// BackupSettings are the schedule and the destination of the database backups
+type BackupSettings struct {
+	Enabled   bool
+	Hour      int
+	Retention int
+	// RepoID is the global software repo where the backups are uploaded, 0 keeps them in the backups directory
+	RepoID       int
+	NotifyEmails []string
+}
+
+// GetBackupSettings returns the backup settings stored in the global settings
+func (m *Model) GetBackupSettings() (*BackupSettings, error) {
+	s, err := m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Select(
+		settings.FieldBackupEnabled,
+		settings.FieldBackupHour,
+		settings.FieldBackupRetention,
+		settings.FieldBackupRepoID,
+		settings.FieldBackupNotifyEmails,
+	).Only(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	retention := s.BackupRetention
+	if retention <= 0 {
+		retention = DefaultBackupRetention
+	}
+
+	notifyEmails := []string{}
+	for _, email := range strings.Split(s.BackupNotifyEmails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			notifyEmails = append(notifyEmails, email)
+		}
+	}
+
+	return &BackupSettings{
+		Enabled:      s.BackupEnabled,
+		Hour:         s.BackupHour,
+		Retention:    retention,
+		RepoID:       s.BackupRepoID,
+		NotifyEmails: notifyEmails,
+	}, nil
+}
+
+// SaveBackupSettings stores the backup settings in the global settings
+func (m *Model) SaveBackupSettings(s *BackupSettings) error {
+	if s.Hour < 0 || s.Hour > 23 {
+		return fmt.Errorf("the backup hour must be between 0 and 23")
+	}
+	if s.Retention < 1 {
+		return fmt.Errorf("at least one backup must be kept")
+	}
+	if s.RepoID != 0 {
+		if _, err := m.backupRepo(s.RepoID); err != nil {
+			return err
+		}
+	}
+
+	return m.Client.Settings.Update().Where(settings.Not(settings.HasTenant())).
+		SetBackupEnabled(s.Enabled).
+		SetBackupHour(s.Hour).
+		SetBackupRetention(s.Retention).
+		SetBackupRepoID(s.RepoID).
+		SetBackupNotifyEmails(strings.Join(s.NotifyEmails, ",")).
+		Exec(context.Background())
+}
+
+// GetDatabaseBackups returns the latest backups, the running one first
+func (m *Model) GetDatabaseBackups(limit int) ([]*ent.DatabaseBackup, error) {
+	return m.Client.DatabaseBackup.Query().Order(ent.Desc(databasebackup.FieldStartedAt)).Limit(limit).All(context.Background())
+}
+
+// GetLastDatabaseBackup returns the latest backup started by the trigger, nil if there are none
+func (m *Model) GetLastDatabaseBackup(trigger string) (*ent.DatabaseBackup, error) {
+	b, err := m.Client.DatabaseBackup.Query().Where(databasebackup.Trigger(trigger)).Order(ent.Desc(databasebackup.FieldStartedAt)).First(context.Background())
+	if ent.IsNotFound(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// StartDatabaseBackup records a new running backup. Only one backup can run at a time
+func (m *Model) StartDatabaseBackup(trigger string, repoID int) (*ent.DatabaseBackup, error) {
+	running, err := m.Client.DatabaseBackup.Query().Where(databasebackup.Status(BackupStatusRunning)).Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		return nil, ErrBackupRunning
+	}
+
+	return m.Client.DatabaseBackup.Create().
+		SetTrigger(trigger).
+		SetStatus(BackupStatusRunning).
+		SetRepoID(repoID).
+		SetStartedAt(time.Now()).
+		Save(context.Background())
+}
+
+// FailDatabaseBackup marks a backup that couldn't run as failed
+func (m *Model) FailDatabaseBackup(id int, reason error) error {
+	return m.Client.DatabaseBackup.UpdateOneID(id).
+		SetStatus(BackupStatusFailed).
+		SetError(reason.Error()).
+		SetFinishedAt(time.Now()).
+		Exec(context.Background())
+}
+
+// FailInterruptedBackups marks the backups that were running when the console stopped as failed
+func (m *Model) FailInterruptedBackups() error {
+	return m.Client.DatabaseBackup.Update().
+		Where(databasebackup.Status(BackupStatusRunning)).
+		SetStatus(BackupStatusFailed).
+		SetError("the console was stopped while the backup was running").
+		SetFinishedAt(time.Now()).
+		Exec(context.Background())
+}
+
+// RunDatabaseBackup dumps the database to the destination of the backup, removes the backups
+// over the retention and, if the backup fails, notifies the configured recipients
+func (m *Model) RunDatabaseBackup(ctx context.Context, b *ent.DatabaseBackup, s *BackupSettings) error {
+	fileName, method, size, err := m.dumpToDestination(ctx, b)
+
+	update := m.Client.DatabaseBackup.UpdateOneID(b.ID).SetFinishedAt(time.Now()).SetMethod(method)
+	if err != nil {
+		update.SetStatus(BackupStatusFailed).SetError(err.Error())
+	} else {
+		update.SetStatus(BackupStatusDone).SetFileName(fileName).SetSize(size)
+	}
+	if updateErr := update.Exec(context.Background()); updateErr != nil {
+		return errors.Join(err, updateErr)
+	}
+
+	if err != nil {
+		if notifyErr := m.enqueueBackupFailureEmail(s.NotifyEmails, b, err); notifyErr != nil {
+			return errors.Join(err, fmt.Errorf("could not notify the backup failure: %w", notifyErr))
+		}
+		return err
+	}
+
+	return m.pruneDatabaseBackups(ctx, s.Retention)
+}
+
+// dumpToDestination writes the dump to a temporary file, next to its final location if the backup
+// is kept in the backups directory, and moves or uploads it when it's complete
+func (m *Model) dumpToDestination(ctx context.Context, b *ent.DatabaseBackup) (string, string, int64, error) {
+	tmpDir := ""
+	if b.RepoID == 0 {
+		if m.Backups.Dir == "" {
+			return "", "", 0, fmt.Errorf("the backups directory is not configured")
+		}
+		tmpDir = m.Backups.Dir
+	}
+
+	f, err := os.CreateTemp(tmpDir, ".openuem-backup-*.partial")
+	if err != nil {
+		return "", "", 0, fmt.Errorf("could not create the backup file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	method, err := m.DumpDatabase(ctx, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", method, 0, err
+	}
+
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return "", method, 0, err
+	}
+
+	fileName := BackupFileName(method, b.StartedAt)
+	if b.RepoID == 0 {
+		if err := os.Rename(f.Name(), filepath.Join(m.Backups.Dir, fileName)); err != nil {
+			return "", method, 0, fmt.Errorf("could not move the backup file: %w", err)
+		}
+		return fileName, method, info.Size(), nil
+	}
+
+	client, err := m.backupS3Client(b.RepoID)
+	if err != nil {
+		return "", method, 0, err
+	}
+
+	r, err := os.Open(f.Name())
+	if err != nil {
+		return "", method, 0, err
+	}
+	defer r.Close()
+
+	if err := client.Upload(ctx, backupS3Prefix+"/"+fileName, r, "application/octet-stream"); err != nil {
+		return "", method, 0, fmt.Errorf("could not upload the backup: %w", err)
+	}
+	return fileName, method, info.Size(), nil
+}
+
+// pruneDatabaseBackups removes the successful backups over the retention, and their files, and the
+// failed backups older than the oldest backup that is kept
+func (m *Model) pruneDatabaseBackups(ctx context.Context, retention int) error {
+	expired, err := m.Client.DatabaseBackup.Query().
+		Where(databasebackup.Status(BackupStatusDone)).
+		Order(ent.Desc(databasebackup.FieldStartedAt)).
+		Offset(retention).
+		All(ctx)
+	if err != nil || len(expired) == 0 {
+		return err
+	}
+
+	var errs []error
+	for _, b := range expired {
+		if err := m.deleteBackupFile(ctx, b); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := m.Client.DatabaseBackup.DeleteOneID(b.ID).Exec(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := m.Client.DatabaseBackup.Delete().
+		Where(databasebackup.Status(BackupStatusFailed), databasebackup.StartedAtLT(expired[0].StartedAt)).
+		Exec(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Model) deleteBackupFile(ctx context.Context, b *ent.DatabaseBackup) error {
+	if b.RepoID == 0 {
+		if m.Backups.Dir == "" {
+			return fmt.Errorf("the backups directory is not configured")
+		}
+		if err := os.Remove(filepath.Join(m.Backups.Dir, filepath.Base(b.FileName))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	client, err := m.backupS3Client(b.RepoID)
+	if err != nil {
+		return err
+	}
+	return client.Delete(ctx, backupS3Prefix+"/"+b.FileName)
+}
+
+// backupRepo returns the global software repo used to store the backups
+func (m *Model) backupRepo(repoID int) (*ent.SoftwareRepo, error) {
+	repo, err := m.GetSoftwareRepoByID(repoID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if repo.RepoType != softwarerepo.RepoTypeGlobal {
+		return nil, fmt.Errorf("backups can only be stored in global software repos")
+	}
+	return repo, nil
+}
+
+func (m *Model) backupS3Client(repoID int) (*s3storage.Client, error) {
+	repo, err := m.backupRepo(repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3storage.New(s3storage.Config{
+		Endpoint:  repo.Endpoint,
+		Bucket:    repo.Bucket,
+		Region:    repo.Region,
+		AccessKey: repo.AccessKey,
+		SecretKey: repo.SecretKey,
+		BasePath:  repo.BasePath,
+	})
+}
+
+// BackupFileName is the name of the backup file, it sorts in the same order as the backups
+func BackupFileName(method string, startedAt time.Time) string {
+	name := "openuem-" + startedAt.UTC().Format("20060102-150405")
+	if method == BackupMethodCopy {
+		return name + ".sql.gz"
+	}
+	return name + ".dump"
+}
+
+// DumpDatabase writes a backup of the database to w with pg_dump. If pg_dump can't be found the data
+// of the tables is exported with COPY instead. It returns the method used
+func (m *Model) DumpDatabase(ctx context.Context, w io.Writer) (string, error) {
+	cmd, err := pgDumpCommand(ctx, m.Backups.PGDumpPath, m.dbURL)
+	if err != nil {
+		return BackupMethodPGDump, err
+	}
+
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		if m.db == nil {
+			return BackupMethodCopy, fmt.Errorf("the database connection is not open")
+		}
+		return BackupMethodCopy, copyDatabase(ctx, m.db, w)
+	}
+
+	stderr := strings.Builder{}
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return BackupMethodPGDump, fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return BackupMethodPGDump, nil
+}
+
+// pgDumpCommand runs pg_dump with the database URL of the console. The password is passed in the
+// environment so it isn't shown in the processes list
+func pgDumpCommand(ctx context.Context, pgDumpPath, dbURL string) (*exec.Cmd, error) {
+	if pgDumpPath == "" {
+		pgDumpPath = "pg_dump"
+	}
+
+	u, err := url.Parse(dbURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("the database URL is not a valid Postgres URL")
+	}
+
+	env := os.Environ()
+	if password, ok := u.User.Password(); ok {
+		env = append(env, "PGPASSWORD="+password)
+		u.User = url.User(u.User.Username())
+	}
+
+	cmd := exec.CommandContext(ctx, pgDumpPath, "--format=custom", "--no-password", "--dbname="+u.String())
+	cmd.Env = env
+	return cmd, nil
+}
+
+// copyDatabase writes the data of every table with COPY, in the format that psql restores, compressed with gzip.
+// All the tables are copied in a read-only transaction, so they share the same snapshot as with pg_dump and
+// the rows written by the agents during the backup can't break the foreign keys between them
+func copyDatabase(ctx context.Context, db *sql.DB, w io.Writer) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		return fmt.Errorf("could not start the backup transaction: %w", err)
+	}
+	// Nothing is written, so the transaction is always rolled back
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+			log.Printf("[ERROR]: could not end the backup transaction: %v", err)
+		}
+	}()
+
+	tables := []string{}
+	rows, err := conn.QueryContext(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := fmt.Fprintf(zw, "-- OpenUEM data backup created on %s\n-- Restore it with psql into a database whose schema has been created by the console\nSET session_replication_role = replica;\n\n", time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("the COPY backup requires the pgx driver")
+		}
+
+		for _, table := range tables {
+			identifier := pgx.Identifier{table}.Sanitize()
+			if _, err := fmt.Fprintf(zw, "TRUNCATE %s CASCADE;\nCOPY %s FROM stdin;\n", identifier, identifier); err != nil {
+				return err
+			}
+			if _, err := pgxConn.Conn().PgConn().CopyTo(ctx, zw, "COPY "+identifier+" TO STDOUT"); err != nil {
+				return fmt.Errorf("could not copy the table %s: %w", table, err)
+			}
+			if _, err := io.WriteString(zw, "\\.\n\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// enqueueBackupFailureEmail notifies the recipients, using the global SMTP settings, that a backup has failed
+func (m *Model) enqueueBackupFailureEmail(recipients []string, b *ent.DatabaseBackup, backupErr error) error {
+	if len(recipients) == 0 || !m.IsSMTPConfigured() {
+		return nil
+	}
+
+	return m.EnqueueEmail(Email{
+		Kind:    EmailKindBackupFailure,
+		To:      recipients,
+		Subject: "OpenUEM database backup failed",
+		Body:    fmt.Sprintf("The %s database backup started on %s has failed:\n\n%v\n", b.Trigger, b.StartedAt.UTC().Format(time.RFC1123), backupErr),
+	})
+}
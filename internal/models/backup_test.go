@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPGDumpCommand(t *testing.T) {
+	cmd, err := pgDumpCommand(context.Background(), "", "postgres://openuem:s3cr3t@db:5432/openuem?sslmode=disable")
+	assert.NoError(t, err)
+	assert.Equal(t, "pg_dump", cmd.Args[0], "should default to pg_dump")
+	assert.Contains(t, cmd.Args, "--dbname=postgres://openuem@db:5432/openuem?sslmode=disable", "should not pass the password as an argument")
+	assert.True(t, slices.Contains(cmd.Env, "PGPASSWORD=s3cr3t"), "should pass the password in the environment")
+
+	_, err = pgDumpCommand(context.Background(), "/usr/bin/pg_dump", "mysql://root@db/openuem")
+	assert.Error(t, err, "should only accept Postgres URLs")
+}
+
+func TestBackupFileName(t *testing.T) {
+	startedAt := time.Date(2026, 10, 16, 2, 0, 5, 0, time.UTC)
+	assert.Equal(t, "openuem-20261016-020005.dump", BackupFileName(BackupMethodPGDump, startedAt))
+	assert.Equal(t, "openuem-20261016-020005.sql.gz", BackupFileName(BackupMethodCopy, startedAt))
+}
@@ -4,28 +4,88 @@ import (
 	"context"
 
 	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/branding"
 )
 
+// BrandingDefaults are the values of the branding created when there is none yet,
+// white-label builds set their own when the console starts
+type BrandingDefaults struct {
+	ProductName          string
+	PrimaryColor         string
+	LogoLight            string
+	LogoSmall            string
+	LoginBackgroundImage string
+	LoginWelcomeText     string
+	BugReportLink        string
+	HelpLink             string
+	// ShowVersion keeps the default of the schema if it's nil
+	ShowVersion *bool
+}
+
+// OpenUEMBranding is the default branding of OpenUEM, it's used for the fields not set in Model.DefaultBranding
+var OpenUEMBranding = BrandingDefaults{
+	ProductName:  "OpenUEM",
+	PrimaryColor: "#16a34a",
+}
+
 // GetBranding retrieves the global branding settings.
 // There should only be one branding record (singleton pattern).
 func (m *Model) GetBranding() (*ent.Branding, error) {
-	return m.Client.Branding.Query().First(context.Background())
+	b, err := m.Client.Branding.Query().Order(ent.Asc(branding.FieldID)).First(context.Background())
+	return b, dbError(err)
 }
 
 // GetOrCreateBranding retrieves branding settings or creates default if not exists.
+// Concurrent requests, even from other consoles, may not find the branding at the same
+// time so the branding is created with the singleton key and only the first one is kept.
 func (m *Model) GetOrCreateBranding() (*ent.Branding, error) {
-	b, err := m.Client.Branding.Query().First(context.Background())
-	if err != nil {
-		if ent.IsNotFound(err) {
-			// Create default branding
-			return m.Client.Branding.Create().
-				SetProductName("OpenUEM").
-				SetPrimaryColor("#16a34a").
-				Save(context.Background())
-		}
+	b, err := m.GetBranding()
+	if err == nil {
+		return b, nil
+	}
+	if !ent.IsNotFound(err) {
 		return nil, err
 	}
-	return b, nil
+
+	// Create default branding
+	d := m.DefaultBranding
+	if d.ProductName == "" {
+		d.ProductName = OpenUEMBranding.ProductName
+	}
+	if d.PrimaryColor == "" {
+		d.PrimaryColor = OpenUEMBranding.PrimaryColor
+	}
+
+	create := m.Client.Branding.Create().
+		SetSingleton(singletonKey).
+		SetProductName(d.ProductName).
+		SetPrimaryColor(d.PrimaryColor)
+	if d.LogoLight != "" {
+		create = create.SetLogoLight(d.LogoLight)
+	}
+	if d.LogoSmall != "" {
+		create = create.SetLogoSmall(d.LogoSmall)
+	}
+	if d.LoginBackgroundImage != "" {
+		create = create.SetLoginBackgroundImage(d.LoginBackgroundImage)
+	}
+	if d.LoginWelcomeText != "" {
+		create = create.SetLoginWelcomeText(d.LoginWelcomeText)
+	}
+	if d.BugReportLink != "" {
+		create = create.SetBugReportLink(d.BugReportLink)
+	}
+	if d.HelpLink != "" {
+		create = create.SetHelpLink(d.HelpLink)
+	}
+	if d.ShowVersion != nil {
+		create = create.SetShowVersion(*d.ShowVersion)
+	}
+
+	if err := create.OnConflictColumns(branding.FieldSingleton).Ignore().Exec(context.Background()); err != nil {
+		return nil, dbError(err)
+	}
+	return m.GetBranding()
 }
 
 // UpdateBranding updates the global branding settings.
@@ -66,7 +126,7 @@ func (m *Model) UpdateBranding(b *ent.Branding) error {
 		update = update.ClearLoginWelcomeText()
 	}
 
-	return update.Exec(context.Background())
+	return dbError(update.Exec(context.Background()))
 }
 
 // SaveLogoLight saves the light mode logo.
@@ -75,9 +135,9 @@ func (m *Model) SaveLogoLight(logoData string) error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetLogoLight(logoData).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // SaveLogoSmall saves the small logo/favicon.
@@ -86,9 +146,9 @@ func (m *Model) SaveLogoSmall(logoData string) error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetLogoSmall(logoData).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // UpdatePrimaryColor updates the primary color.
@@ -98,9 +158,9 @@ func (m *Model) UpdatePrimaryColor(primary string) error {
 		return err
 	}
 
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetPrimaryColor(primary).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // SaveLoginBackgroundImage saves the login page background image.
@@ -109,9 +169,9 @@ func (m *Model) SaveLoginBackgroundImage(imageData string) error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetLoginBackgroundImage(imageData).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // SaveLoginWelcomeText saves the login page welcome text.
@@ -120,9 +180,9 @@ func (m *Model) SaveLoginWelcomeText(text string) error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetLoginWelcomeText(text).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // BrandingExists checks if branding settings exist.
@@ -136,9 +196,9 @@ func (m *Model) DeleteLogoLight() error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		ClearLogoLight().
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // DeleteLogoSmall removes the small logo.
@@ -147,9 +207,9 @@ func (m *Model) DeleteLogoSmall() error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		ClearLogoSmall().
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // DeleteLoginBackgroundImage removes the login background image.
@@ -158,9 +218,9 @@ func (m *Model) DeleteLoginBackgroundImage() error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		ClearLoginBackgroundImage().
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 func (m *Model) UpdateShowVersion(show bool) error {
@@ -168,9 +228,9 @@ func (m *Model) UpdateShowVersion(show bool) error {
 	if err != nil {
 		return err
 	}
-	return m.Client.Branding.UpdateOneID(b.ID).
+	return dbError(m.Client.Branding.UpdateOneID(b.ID).
 		SetShowVersion(show).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 func (m *Model) UpdateBugReportLink(link string) error {
@@ -184,7 +244,7 @@ func (m *Model) UpdateBugReportLink(link string) error {
 	} else {
 		update = update.SetBugReportLink(link)
 	}
-	return update.Exec(context.Background())
+	return dbError(update.Exec(context.Background()))
 }
 
 func (m *Model) UpdateHelpLink(link string) error {
@@ -198,5 +258,5 @@ func (m *Model) UpdateHelpLink(link string) error {
 	} else {
 		update = update.SetHelpLink(link)
 	}
-	return update.Exec(context.Background())
+	return dbError(update.Exec(context.Background()))
 }
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrCreateBrandingDefaults(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:branding?mode=memory&_fk=1")
+	defer client.Close()
+
+	m := Model{Client: client, DefaultBranding: BrandingDefaults{ProductName: "Acme UEM", HelpLink: "https://help.example.com"}}
+	b, err := m.GetOrCreateBranding()
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme UEM", b.ProductName, "should create the branding with the defaults of the model")
+	assert.Equal(t, "https://help.example.com", b.HelpLink)
+	assert.Equal(t, OpenUEMBranding.PrimaryColor, b.PrimaryColor, "should use the OpenUEM defaults for the fields not set")
+
+	m.DefaultBranding.ProductName = "Other"
+	b, err = m.GetOrCreateBranding()
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme UEM", b.ProductName, "should not change an existing branding")
+}
+
+func TestGetOrCreateBrandingConcurrently(t *testing.T) {
+	// A single connection keeps one in-memory database, the requests still interleave between queries
+	db, err := sql.Open("sqlite3", "file:branding_concurrent?mode=memory&_fk=1")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	client := ent.NewClient(ent.Driver(entsql.OpenDB("sqlite3", db)))
+	assert.NoError(t, client.Schema.Create(context.Background()))
+
+	m := Model{Client: client}
+
+	var wg sync.WaitGroup
+	ids := make([]int, 20)
+	errs := make([]error, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b, err := m.GetOrCreateBranding()
+			errs[i] = err
+			if err == nil {
+				ids[i] = b.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range ids {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, ids[0], ids[i], "all the requests should get the same branding")
+	}
+
+	n, err := client.Branding.Query().Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "should create only one branding")
+}
+
+func TestMigrateSingletons(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:singletons?mode=memory&_fk=1")
+	defer client.Close()
+
+	m := Model{Client: client}
+	ctx := context.Background()
+
+	// Brandings created twice before the singleton key existed
+	first, err := client.Branding.Create().SetProductName("First").SetPrimaryColor("#000000").Save(ctx)
+	assert.NoError(t, err)
+	_, err = client.Branding.Create().SetProductName("Second").SetPrimaryColor("#ffffff").SetHelpLink("https://help.example.com").Save(ctx)
+	assert.NoError(t, err)
+	_, err = client.Authentication.Create().Save(ctx)
+	assert.NoError(t, err)
+	_, err = client.Authentication.Create().Save(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.MigrateSingletons())
+
+	brandings, err := client.Branding.Query().All(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, brandings, 1, "should merge the brandings") {
+		assert.Equal(t, first.ID, brandings[0].ID, "should keep the oldest branding")
+		assert.Equal(t, "First", brandings[0].ProductName, "should keep the values of the oldest branding")
+		assert.Equal(t, "https://help.example.com", brandings[0].HelpLink, "should fill the empty fields with the duplicates")
+		if assert.NotNil(t, brandings[0].Singleton) {
+			assert.Equal(t, singletonKey, *brandings[0].Singleton)
+		}
+	}
+
+	n, err := client.Authentication.Query().Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "should merge the authentication settings")
+
+	assert.NoError(t, m.MigrateSingletons(), "should do nothing once merged")
+
+	b, err := m.GetOrCreateBranding()
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, b.ID, "should not create another branding once merged")
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached queries are kept if no other value is configured
+const DefaultCacheTTL = 5 * time.Second
+
+// Prefixes of the cache keys, used to invalidate all the entries related to an entity
+const (
+	cacheKeyAgents          = "agents"
+	cacheKeyServers         = "servers"
+	cacheKeyTenants         = "tenants"
+	cacheKeySites           = "sites"
+	cacheKeySecurityHeaders = "security_headers"
+	cacheKeyDateTime        = "datetime"
+	cacheKeyAPIRateLimits   = "api_rate_limits"
+)
+
+// Cache keeps for a few seconds the result of queries run on almost every page that rarely change,
+// like checking if agents exist or getting the tenants and sites shown in the sidebar.
+// A nil cache or a zero TTL disables caching
+type Cache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// CacheStats are the cache metrics shown in the health endpoint
+type CacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// cached returns the value stored for the key or runs the query and stores its result
+func cached[T any](c *Cache, key string, query func() (T, error)) (T, error) {
+	if c == nil || c.ttl <= 0 {
+		return query()
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		if value, ok := entry.value.(T); ok {
+			c.hits.Add(1)
+			return value, nil
+		}
+	}
+	c.misses.Add(1)
+
+	value, err := query()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// Invalidate removes the entries whose keys start with any of the prefixes
+func (c *Cache) Invalidate(prefixes ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// Stats returns the number of hits and misses since the cache was created
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	stats := CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
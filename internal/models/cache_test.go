@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(time.Minute)
+
+	calls := 0
+	query := func() (bool, error) {
+		calls++
+		return true, nil
+	}
+
+	value, err := cached(cache, cacheKeyAgents+"-exist-1-1", query)
+	assert.NoError(t, err)
+	assert.True(t, value)
+
+	value, err = cached(cache, cacheKeyAgents+"-exist-1-1", query)
+	assert.NoError(t, err)
+	assert.True(t, value)
+	assert.Equal(t, 1, calls, "should use the cached value")
+
+	_, err = cached(cache, cacheKeyServers+"-exist", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls, "should not share values between keys")
+
+	cache.Invalidate(cacheKeyAgents)
+	_, err = cached(cache, cacheKeyAgents+"-exist-1-1", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "should query again after invalidation")
+
+	_, err = cached(cache, cacheKeyServers+"-exist", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "should keep entries with other prefixes")
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(3), stats.Misses)
+	assert.InDelta(t, 0.4, stats.HitRate, 0.001)
+}
+
+func TestCacheErrorsAndExpiration(t *testing.T) {
+	cache := NewCache(10 * time.Millisecond)
+
+	_, err := cached(cache, "failing", func() (int, error) { return 0, errors.New("query failed") })
+	assert.Error(t, err)
+
+	calls := 0
+	query := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	_, err = cached(cache, "failing", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "should not cache errors")
+
+	time.Sleep(20 * time.Millisecond)
+	value, err := cached(cache, "failing", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value, "should query again when the entry expires")
+}
+
+func TestNilCache(t *testing.T) {
+	var cache *Cache
+
+	calls := 0
+	for range 2 {
+		_, err := cached(cache, "key", func() (bool, error) {
+			calls++
+			return true, nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls, "nil cache should disable caching")
+
+	cache.Invalidate(cacheKeyAgents)
+	assert.Equal(t, CacheStats{}, cache.Stats())
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentcheckin"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+)
+
+// HeatmapBucket is the number of agent check-ins in an hour of a day of the week
+type HeatmapBucket struct {
+	Hour      int `json:"hour" sql:"hour"`
+	DayOfWeek int `json:"day_of_week" sql:"day_of_week"`
+	Count     int `json:"count" sql:"count"`
+}
+
+// GetCheckinHeatmap groups the check-ins of the tenant's agents in the last days by hour (0-23) and
+// day of the week (0-6, Sunday is 0). Hours and days without check-ins are not returned
+func (m *Model) GetCheckinHeatmap(tenantID int, days int) ([]HeatmapBucket, error) {
+	buckets := []HeatmapBucket{}
+
+	if err := m.Client.AgentCheckin.Query().
+		Where(
+			agentcheckin.CheckedInAtGTE(time.Now().AddDate(0, 0, -days)),
+			agentcheckin.HasAgentWith(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))),
+		).
+		Modify(func(s *sql.Selector) {
+			hour := fmt.Sprintf("CAST(EXTRACT(HOUR FROM %s) AS INTEGER)", s.C(agentcheckin.FieldCheckedInAt))
+			dayOfWeek := fmt.Sprintf("CAST(EXTRACT(DOW FROM %s) AS INTEGER)", s.C(agentcheckin.FieldCheckedInAt))
+			s.Select(sql.As(hour, "hour"), sql.As(dayOfWeek, "day_of_week"), sql.As(sql.Count("*"), "count")).
+				GroupBy(hour, dayOfWeek).
+				OrderBy(dayOfWeek, hour)
+		}).
+		Scan(context.Background(), &buckets); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,610 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/computer"
+	"github.com/open-uem/ent/customreport"
+	"github.com/open-uem/ent/metadata"
+	"github.com/open-uem/ent/operatingsystem"
+	"github.com/open-uem/ent/orgmetadata"
+	"github.com/open-uem/ent/predicate"
+	"github.com/open-uem/ent/release"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// CustomReportEntityAgents is the entity the custom reports are built from, the only one for now
+const CustomReportEntityAgents = "agents"
+
+// CustomReportPreviewRows is how many rows the report builder shows, the whole report is exported
+const CustomReportPreviewRows = 50
+
+// CustomReportCustomFieldPrefix is the prefix of the keys of the custom fields of the tenant, followed by the ID
+// of the metadata
+const CustomReportCustomFieldPrefix = "custom:"
+
+// Operators of the filter conditions of a custom report
+const (
+	CustomReportEquals    = "equals"
+	CustomReportNotEquals = "not_equals"
+	CustomReportContains  = "contains"
+	CustomReportBefore    = "before"
+	CustomReportAfter     = "after"
+)
+
+// customReportMaxFilters limits the conditions of a report, each one is a subquery
+const customReportMaxFilters = 10
+
+// customReportDateLayout is the layout of the dates typed in the filters and of the dates of the rows
+const (
+	customReportDateLayout     = "2006-01-02"
+	customReportDateTimeLayout = "2006-01-02 15:04"
+)
+
+var (
+	textOperators = []string{CustomReportEquals, CustomReportNotEquals, CustomReportContains}
+	enumOperators = []string{CustomReportEquals, CustomReportNotEquals}
+	timeOperators = []string{CustomReportBefore, CustomReportAfter}
+)
+
+// CustomReportFilter is a condition that the rows of the report must meet
+type CustomReportFilter struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// CustomReportDefinition is what a saved report shows. Columns, filters and group-by are keys of the field
+// catalog, they're mapped to ent predicates so no query is built from what the user types
+type CustomReportDefinition struct {
+	Entity  string               `json:"entity"`
+	Columns []string             `json:"columns"`
+	Filters []CustomReportFilter `json:"filters"`
+	GroupBy string               `json:"group_by"`
+}
+
+// CustomReportValues are the values of a report as entered in the report builder
+type CustomReportValues struct {
+	Name        string
+	Description string
+	Definition  CustomReportDefinition
+	// Scheduled reports are summarized in the weekly report
+	Scheduled bool
+}
+
+// CustomReportField is a field of the catalog that can be shown, filtered or grouped by. Label is the key of
+// its translation, or the name of the custom field
+type CustomReportField struct {
+	Key       string
+	Label     string
+	Custom    bool
+	Operators []string
+	// Values are the only values accepted by the filters, if any
+	Values []string
+	value  func(a *ent.Agent, loc *time.Location) string
+	filter func(operator, value string) (predicate.Agent, error)
+}
+
+// CustomReportGroup is a value of the group-by field and how many rows have it
+type CustomReportGroup struct {
+	Value string
+	Count int
+}
+
+// CustomReportResult are the rows of a report, sorted by the group-by field if any. Total is the number of
+// rows of the whole report, there may be fewer rows if a limit was given
+type CustomReportResult struct {
+	Columns []CustomReportField
+	Rows    [][]string
+	Groups  []CustomReportGroup
+	Total   int
+}
+
+// errCustomReportOperator is returned when an operator doesn't apply to the field of the filter
+var errCustomReportOperator = errors.New("the operator can't be used with this field")
+
+// CustomReportFields returns the fields of the agents that the reports can use, followed by the custom fields
+// of the tenant
+func (m *Model) CustomReportFields(tenantID int) ([]CustomReportField, error) {
+	fields := []CustomReportField{
+		textField("hostname", "custom_reports.fields.hostname", func(a *ent.Agent) string { return a.Hostname }, agent.Hostname, agent.HostnameContainsFold),
+		textField("nickname", "custom_reports.fields.nickname", func(a *ent.Agent) string { return a.Nickname }, agent.Nickname, agent.NicknameContainsFold),
+		enumField("status", "custom_reports.fields.status", func(a *ent.Agent) string { return string(a.AgentStatus) }, agentStatusValues(), func(v string) predicate.Agent {
+			return agent.AgentStatusEQ(agent.AgentStatus(v))
+		}),
+		textField("os", "custom_reports.fields.os", func(a *ent.Agent) string { return a.Os }, agent.Os, agent.OsContainsFold),
+		textField("ip", "custom_reports.fields.ip", func(a *ent.Agent) string { return a.IP }, agent.IP, agent.IPContainsFold),
+		textField("mac", "custom_reports.fields.mac", func(a *ent.Agent) string { return a.MAC }, agent.MAC, agent.MACContainsFold),
+		enumField("remote", "custom_reports.fields.remote", func(a *ent.Agent) string { return strconv.FormatBool(a.IsRemote) }, []string{"true", "false"}, func(v string) predicate.Agent {
+			return agent.IsRemote(v == "true")
+		}),
+		timeField("last_contact", "custom_reports.fields.last_contact", func(a *ent.Agent) time.Time { return a.LastContact }, agent.LastContactLT, agent.LastContactGTE),
+		timeField("first_contact", "custom_reports.fields.first_contact", func(a *ent.Agent) time.Time { return a.FirstContact }, agent.FirstContactLT, agent.FirstContactGTE),
+		textField("version", "custom_reports.fields.version", func(a *ent.Agent) string {
+			if a.Edges.Release == nil {
+				return ""
+			}
+			return a.Edges.Release.Version
+		}, func(v string) predicate.Agent {
+			return agent.HasReleaseWith(release.Version(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasReleaseWith(release.VersionContainsFold(v))
+		}),
+		textField("site", "custom_reports.fields.site", func(a *ent.Agent) string {
+			if len(a.Edges.Site) == 0 {
+				return ""
+			}
+			return a.Edges.Site[0].Description
+		}, func(v string) predicate.Agent {
+			return agent.HasSiteWith(site.Description(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasSiteWith(site.DescriptionContainsFold(v))
+		}),
+		textField("tags", "custom_reports.fields.tags", func(a *ent.Agent) string {
+			tags := []string{}
+			for _, t := range a.Edges.Tags {
+				tags = append(tags, t.Tag)
+			}
+			slices.Sort(tags)
+			return strings.Join(tags, ", ")
+		}, func(v string) predicate.Agent {
+			return agent.HasTagsWith(tag.Tag(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasTagsWith(tag.TagContainsFold(v))
+		}),
+		textField("manufacturer", "custom_reports.fields.manufacturer", func(a *ent.Agent) string {
+			if a.Edges.Computer == nil {
+				return ""
+			}
+			return a.Edges.Computer.Manufacturer
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.Manufacturer(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.ManufacturerContainsFold(v))
+		}),
+		textField("model", "custom_reports.fields.model", func(a *ent.Agent) string {
+			if a.Edges.Computer == nil {
+				return ""
+			}
+			return a.Edges.Computer.Model
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.Model(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.ModelContainsFold(v))
+		}),
+		textField("serial", "custom_reports.fields.serial", func(a *ent.Agent) string {
+			if a.Edges.Computer == nil {
+				return ""
+			}
+			return a.Edges.Computer.Serial
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.Serial(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasComputerWith(computer.SerialContainsFold(v))
+		}),
+		textField("os_version", "custom_reports.fields.os_version", func(a *ent.Agent) string {
+			if a.Edges.Operatingsystem == nil {
+				return ""
+			}
+			return a.Edges.Operatingsystem.Version
+		}, func(v string) predicate.Agent {
+			return agent.HasOperatingsystemWith(operatingsystem.Version(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasOperatingsystemWith(operatingsystem.VersionContainsFold(v))
+		}),
+		textField("username", "custom_reports.fields.username", func(a *ent.Agent) string {
+			if a.Edges.Operatingsystem == nil {
+				return ""
+			}
+			return a.Edges.Operatingsystem.Username
+		}, func(v string) predicate.Agent {
+			return agent.HasOperatingsystemWith(operatingsystem.Username(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasOperatingsystemWith(operatingsystem.UsernameContainsFold(v))
+		}),
+	}
+
+	custom, err := m.Client.OrgMetadata.Query().
+		Where(orgmetadata.HasTenantWith(tenant.ID(tenantID))).
+		Order(ent.Asc(orgmetadata.FieldName)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range custom {
+		id := o.ID
+		f := textField(CustomReportCustomFieldPrefix+strconv.Itoa(id), o.Name, func(a *ent.Agent) string {
+			for _, data := range a.Edges.Metadata {
+				if data.Edges.Org != nil && data.Edges.Org.ID == id {
+					return data.Value
+				}
+			}
+			return ""
+		}, func(v string) predicate.Agent {
+			return agent.HasMetadataWith(metadata.HasOrgWith(orgmetadata.ID(id)), metadata.Value(v))
+		}, func(v string) predicate.Agent {
+			return agent.HasMetadataWith(metadata.HasOrgWith(orgmetadata.ID(id)), metadata.ValueContainsFold(v))
+		})
+		f.Custom = true
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// ValidateCustomReport checks that the report only uses fields of the catalog of the tenant, with operators
+// and values that apply to them
+func (m *Model) ValidateCustomReport(tenantID int, d CustomReportDefinition) error {
+	fields, err := m.CustomReportFields(tenantID)
+	if err != nil {
+		return err
+	}
+	_, _, err = customReportQuery(fields, d)
+	return err
+}
+
+// RunCustomReport returns the rows of the report for the agents of the tenant, or of the site. If limit is
+// greater than zero only the first rows are returned
+func (m *Model) RunCustomReport(d CustomReportDefinition, limit int, c *partials.CommonInfo) (*CustomReportResult, error) {
+	ctx := context.Background()
+
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := m.CustomReportFields(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, predicates, err := customReportQuery(fields, d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dates are shown in the timezone of the tenant
+	loc := time.Local
+	if dt, err := m.GetDateTimeSettings(c.TenantID); err == nil {
+		loc = helpers.LoadTimezone(dt.Timezone)
+	}
+
+	query := m.Client.Agent.Query()
+	if siteID == -1 {
+		query = query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query = query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+	query = query.Where(predicates...)
+
+	result := CustomReportResult{Columns: columns, Rows: [][]string{}, Groups: []CustomReportGroup{}}
+
+	// Without groups the rows are limited by the database, otherwise they're sorted by group first
+	if d.GroupBy == "" && limit > 0 {
+		if result.Total, err = query.Clone().Count(ctx); err != nil {
+			return nil, err
+		}
+		query = query.Limit(limit)
+	}
+
+	agents, err := query.
+		WithSite().
+		WithTags().
+		WithRelease().
+		WithComputer().
+		WithOperatingsystem().
+		WithMetadata(func(q *ent.MetadataQuery) { q.WithOrg() }).
+		Order(ent.Asc(agent.FieldHostname), ent.Asc(agent.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range agents {
+		row := []string{}
+		for _, column := range columns {
+			row = append(row, column.value(a, loc))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if d.GroupBy != "" {
+		// The group-by field is the first column
+		slices.SortStableFunc(result.Rows, func(x, y []string) int {
+			return strings.Compare(x[0], y[0])
+		})
+		for _, row := range result.Rows {
+			if n := len(result.Groups); n > 0 && result.Groups[n-1].Value == row[0] {
+				result.Groups[n-1].Count++
+				continue
+			}
+			result.Groups = append(result.Groups, CustomReportGroup{Value: row[0], Count: 1})
+		}
+		result.Total = len(result.Rows)
+		if limit > 0 && len(result.Rows) > limit {
+			result.Rows = result.Rows[:limit]
+		}
+	} else if limit <= 0 {
+		result.Total = len(result.Rows)
+	}
+
+	return &result, nil
+}
+
+// GetCustomReports returns the reports saved in the tenant, all its users can run them
+func (m *Model) GetCustomReports(tenantID int) ([]*ent.CustomReport, error) {
+	return m.Client.CustomReport.Query().
+		Where(customreport.TenantID(tenantID)).
+		Order(ent.Asc(customreport.FieldName)).
+		All(context.Background())
+}
+
+// GetScheduledCustomReports returns the reports of the tenant that are summarized in the weekly report
+func (m *Model) GetScheduledCustomReports(tenantID int) ([]*ent.CustomReport, error) {
+	return m.Client.CustomReport.Query().
+		Where(customreport.TenantID(tenantID), customreport.Scheduled(true)).
+		Order(ent.Asc(customreport.FieldName)).
+		All(context.Background())
+}
+
+// GetCustomReport returns a report saved in the tenant
+func (m *Model) GetCustomReport(id, tenantID int) (*ent.CustomReport, error) {
+	r, err := m.Client.CustomReport.Query().
+		Where(customreport.ID(id), customreport.TenantID(tenantID)).
+		Only(context.Background())
+	return r, dbError(err)
+}
+
+// CreateCustomReport saves a new report in the tenant, the user that creates it can edit it later
+func (m *Model) CreateCustomReport(tenantID int, createdBy string, v CustomReportValues) (*ent.CustomReport, error) {
+	definition, err := m.customReportDefinition(tenantID, v.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := m.Client.CustomReport.Query().
+		Where(customreport.TenantID(tenantID), customreport.Name(v.Name)).
+		Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyExists
+	}
+
+	r, err := m.Client.CustomReport.Create().
+		SetTenantID(tenantID).
+		SetName(v.Name).
+		SetDescription(v.Description).
+		SetDefinition(definition).
+		SetScheduled(v.Scheduled).
+		SetCreatedBy(createdBy).
+		SetCreated(time.Now()).
+		SetModified(time.Now()).
+		Save(context.Background())
+	return r, dbError(err)
+}
+
+// UpdateCustomReport saves the changes of a report of the tenant
+func (m *Model) UpdateCustomReport(id, tenantID int, v CustomReportValues) (*ent.CustomReport, error) {
+	definition, err := m.customReportDefinition(tenantID, v.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := m.Client.CustomReport.Query().
+		Where(customreport.TenantID(tenantID), customreport.Name(v.Name), customreport.IDNEQ(id)).
+		Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyExists
+	}
+
+	n, err := m.Client.CustomReport.Update().
+		Where(customreport.ID(id), customreport.TenantID(tenantID)).
+		SetName(v.Name).
+		SetDescription(v.Description).
+		SetDefinition(definition).
+		SetScheduled(v.Scheduled).
+		SetModified(time.Now()).
+		Save(context.Background())
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return m.GetCustomReport(id, tenantID)
+}
+
+// DeleteCustomReport removes a report of the tenant
+func (m *Model) DeleteCustomReport(id, tenantID int) error {
+	n, err := m.Client.CustomReport.Delete().
+		Where(customreport.ID(id), customreport.TenantID(tenantID)).
+		Exec(context.Background())
+	if err != nil {
+		return dbError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CustomReportDefinitionOf decodes the definition of a saved report
+func CustomReportDefinitionOf(r *ent.CustomReport) (CustomReportDefinition, error) {
+	d := CustomReportDefinition{}
+	if err := json.Unmarshal([]byte(r.Definition), &d); err != nil {
+		return d, fmt.Errorf("could not decode the definition of report %d: %w", r.ID, err)
+	}
+	return d, nil
+}
+
+// customReportDefinition validates the definition of a report and encodes it to be stored
+func (m *Model) customReportDefinition(tenantID int, d CustomReportDefinition) (string, error) {
+	if err := m.ValidateCustomReport(tenantID, d); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// customReportQuery maps the definition of a report to the fields of the catalog, the group-by field first,
+// and to the predicates of its filters. A field, operator or value that isn't in the catalog is rejected
+func customReportQuery(fields []CustomReportField, d CustomReportDefinition) ([]CustomReportField, []predicate.Agent, error) {
+	if d.Entity != CustomReportEntityAgents {
+		return nil, nil, &ValidationError{Field: "entity", Err: fmt.Errorf("unknown entity %q", d.Entity)}
+	}
+
+	catalog := map[string]CustomReportField{}
+	for _, f := range fields {
+		catalog[f.Key] = f
+	}
+
+	keys := slices.Clone(d.Columns)
+	if d.GroupBy != "" {
+		keys = slices.DeleteFunc(keys, func(k string) bool { return k == d.GroupBy })
+		keys = append([]string{d.GroupBy}, keys...)
+	}
+	if len(keys) == 0 {
+		return nil, nil, &ValidationError{Field: "columns", Err: errors.New("no columns have been selected")}
+	}
+
+	columns := []CustomReportField{}
+	for _, key := range keys {
+		f, ok := catalog[key]
+		if !ok {
+			return nil, nil, &ValidationError{Field: "columns", Err: fmt.Errorf("unknown field %q", key)}
+		}
+		if slices.ContainsFunc(columns, func(c CustomReportField) bool { return c.Key == key }) {
+			continue
+		}
+		columns = append(columns, f)
+	}
+
+	if len(d.Filters) > customReportMaxFilters {
+		return nil, nil, &ValidationError{Field: "filters", Err: fmt.Errorf("a report can't have more than %d filters", customReportMaxFilters)}
+	}
+
+	predicates := []predicate.Agent{}
+	for _, filter := range d.Filters {
+		f, ok := catalog[filter.Field]
+		if !ok {
+			return nil, nil, &ValidationError{Field: "filters", Err: fmt.Errorf("unknown field %q", filter.Field)}
+		}
+		if !slices.Contains(f.Operators, filter.Operator) {
+			return nil, nil, &ValidationError{Field: "filters", Err: errCustomReportOperator}
+		}
+		if len(f.Values) > 0 && !slices.Contains(f.Values, filter.Value) {
+			return nil, nil, &ValidationError{Field: "filters", Err: fmt.Errorf("%q is not a value of %s", filter.Value, f.Key)}
+		}
+		p, err := f.filter(filter.Operator, filter.Value)
+		if err != nil {
+			return nil, nil, &ValidationError{Field: "filters", Err: err}
+		}
+		predicates = append(predicates, p)
+	}
+
+	return columns, predicates, nil
+}
+
+func textField(key, label string, value func(a *ent.Agent) string, equals, contains func(v string) predicate.Agent) CustomReportField {
+	return CustomReportField{
+		Key:       key,
+		Label:     label,
+		Operators: textOperators,
+		value:     func(a *ent.Agent, _ *time.Location) string { return value(a) },
+		filter: func(operator, v string) (predicate.Agent, error) {
+			switch operator {
+			case CustomReportEquals:
+				return equals(v), nil
+			case CustomReportNotEquals:
+				return agent.Not(equals(v)), nil
+			case CustomReportContains:
+				return contains(v), nil
+			}
+			return nil, errCustomReportOperator
+		},
+	}
+}
+
+func enumField(key, label string, value func(a *ent.Agent) string, values []string, equals func(v string) predicate.Agent) CustomReportField {
+	return CustomReportField{
+		Key:       key,
+		Label:     label,
+		Operators: enumOperators,
+		Values:    values,
+		value:     func(a *ent.Agent, _ *time.Location) string { return value(a) },
+		filter: func(operator, v string) (predicate.Agent, error) {
+			switch operator {
+			case CustomReportEquals:
+				return equals(v), nil
+			case CustomReportNotEquals:
+				return agent.Not(equals(v)), nil
+			}
+			return nil, errCustomReportOperator
+		},
+	}
+}
+
+func timeField(key, label string, value func(a *ent.Agent) time.Time, before, after func(t time.Time) predicate.Agent) CustomReportField {
+	return CustomReportField{
+		Key:       key,
+		Label:     label,
+		Operators: timeOperators,
+		value: func(a *ent.Agent, loc *time.Location) string {
+			t := value(a)
+			if t.IsZero() {
+				return ""
+			}
+			return t.In(loc).Format(customReportDateTimeLayout)
+		},
+		filter: func(operator, v string) (predicate.Agent, error) {
+			t, err := time.Parse(customReportDateLayout, v)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a date", v)
+			}
+			switch operator {
+			case CustomReportBefore:
+				return before(t), nil
+			case CustomReportAfter:
+				return after(t), nil
+			}
+			return nil, errCustomReportOperator
+		},
+	}
+}
+
+func agentStatusValues() []string {
+	return []string{
+		string(agent.AgentStatusEnabled),
+		string(agent.AgentStatusDisabled),
+		string(agent.AgentStatusWaitingForAdmission),
+		string(agent.AgentStatusDecommissioned),
+	}
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CustomReportsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	costCenter string
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *CustomReportsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: "-1"}
+
+	costCenter, err := client.OrgMetadata.Create().SetName("Cost center").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create a custom field")
+	suite.costCenter = CustomReportCustomFieldPrefix + strconv.Itoa(costCenter.ID)
+
+	for i := 0; i < 6; i++ {
+		status := agent.AgentStatusEnabled
+		if i%3 == 0 {
+			status = agent.AgentStatusDisabled
+		}
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("host%d", i)).
+			SetNickname(fmt.Sprintf("host%d", i)).
+			SetOs("windows").
+			SetAgentStatus(status).
+			SetLastContact(time.Now().AddDate(0, 0, -i*10)).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.Metadata.Create().SetOrgID(costCenter.ID).SetOwnerID(fmt.Sprintf("agent%d", i)).SetValue(fmt.Sprintf("CC-%d", i%2)).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should set the custom field")
+	}
+}
+
+func (suite *CustomReportsTestSuite) TestCustomReportFields() {
+	fields, err := suite.model.CustomReportFields(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get the field catalog")
+
+	last := fields[len(fields)-1]
+	assert.Equal(suite.T(), suite.costCenter, last.Key, "should add the custom fields of the tenant")
+	assert.Equal(suite.T(), "Cost center", last.Label)
+	assert.True(suite.T(), last.Custom)
+}
+
+func (suite *CustomReportsTestSuite) TestRunCustomReport() {
+	d := CustomReportDefinition{
+		Entity:  CustomReportEntityAgents,
+		Columns: []string{"hostname", suite.costCenter},
+		Filters: []CustomReportFilter{{Field: "status", Operator: CustomReportEquals, Value: "Enabled"}},
+	}
+
+	result, err := suite.model.RunCustomReport(d, 0, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should run the report")
+	assert.Equal(suite.T(), 4, result.Total)
+	assert.Equal(suite.T(), [][]string{{"host1", "CC-1"}, {"host2", "CC-0"}, {"host4", "CC-0"}, {"host5", "CC-1"}}, result.Rows)
+
+	result, err = suite.model.RunCustomReport(d, 2, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should run the preview of the report")
+	assert.Equal(suite.T(), 4, result.Total, "should count the rows of the whole report")
+	assert.Len(suite.T(), result.Rows, 2, "should limit the rows of the preview")
+
+	d.Filters = []CustomReportFilter{
+		{Field: suite.costCenter, Operator: CustomReportEquals, Value: "CC-0"},
+		{Field: "last_contact", Operator: CustomReportAfter, Value: time.Now().AddDate(0, 0, -25).Format("2006-01-02")},
+	}
+	result, err = suite.model.RunCustomReport(d, 0, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should filter by custom fields and dates")
+	assert.Equal(suite.T(), [][]string{{"host0", "CC-0"}, {"host2", "CC-0"}}, result.Rows)
+}
+
+func (suite *CustomReportsTestSuite) TestRunCustomReportGroupBy() {
+	d := CustomReportDefinition{Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, GroupBy: "status"}
+
+	result, err := suite.model.RunCustomReport(d, 3, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should run the report")
+	assert.Equal(suite.T(), "status", result.Columns[0].Key, "the group-by field should be the first column")
+	assert.Equal(suite.T(), []CustomReportGroup{{Value: "Disabled", Count: 2}, {Value: "Enabled", Count: 4}}, result.Groups)
+	assert.Equal(suite.T(), 6, result.Total)
+	assert.Equal(suite.T(), [][]string{{"Disabled", "host0"}, {"Disabled", "host3"}, {"Enabled", "host1"}}, result.Rows)
+}
+
+func (suite *CustomReportsTestSuite) TestRunCustomReportRejectsUnknownFields() {
+	var validationErr *ValidationError
+
+	for name, d := range map[string]CustomReportDefinition{
+		"unknown entity":   {Entity: "users", Columns: []string{"hostname"}},
+		"no columns":       {Entity: CustomReportEntityAgents},
+		"unknown column":   {Entity: CustomReportEntityAgents, Columns: []string{"hostname; DROP TABLE agents"}},
+		"unknown filter":   {Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, Filters: []CustomReportFilter{{Field: "passwd", Operator: CustomReportEquals, Value: "x"}}},
+		"invalid operator": {Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, Filters: []CustomReportFilter{{Field: "last_contact", Operator: CustomReportContains, Value: "2024"}}},
+		"invalid value":    {Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, Filters: []CustomReportFilter{{Field: "status", Operator: CustomReportEquals, Value: "Lost"}}},
+		"invalid date":     {Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, Filters: []CustomReportFilter{{Field: "last_contact", Operator: CustomReportBefore, Value: "yesterday"}}},
+		"unknown group-by": {Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, GroupBy: "id"},
+	} {
+		_, err := suite.model.RunCustomReport(d, 0, suite.commonInfo)
+		assert.True(suite.T(), errors.As(err, &validationErr), name)
+	}
+}
+
+func (suite *CustomReportsTestSuite) TestCustomReports() {
+	v := CustomReportValues{
+		Name:       "Disabled agents",
+		Definition: CustomReportDefinition{Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, Filters: []CustomReportFilter{{Field: "status", Operator: CustomReportEquals, Value: "Disabled"}}},
+	}
+
+	r, err := suite.model.CreateCustomReport(suite.tenantID, "admin", v)
+	assert.NoError(suite.T(), err, "should save the report")
+	assert.Equal(suite.T(), "admin", r.CreatedBy)
+
+	_, err = suite.model.CreateCustomReport(suite.tenantID, "operator", v)
+	assert.ErrorIs(suite.T(), err, ErrAlreadyExists, "the names of the reports should be unique in the tenant")
+
+	d, err := CustomReportDefinitionOf(r)
+	assert.NoError(suite.T(), err, "should decode the definition")
+	assert.Equal(suite.T(), v.Definition, d)
+
+	v.Scheduled = true
+	v.Definition.Columns = []string{"hostname", "unknown"}
+	_, err = suite.model.UpdateCustomReport(r.ID, suite.tenantID, v)
+	var validationErr *ValidationError
+	assert.True(suite.T(), errors.As(err, &validationErr), "should not save an invalid definition")
+
+	v.Definition.Columns = []string{"hostname", "os"}
+	r, err = suite.model.UpdateCustomReport(r.ID, suite.tenantID, v)
+	assert.NoError(suite.T(), err, "should update the report")
+	assert.True(suite.T(), r.Scheduled)
+
+	scheduled, err := suite.model.GetScheduledCustomReports(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get the scheduled reports")
+	assert.Len(suite.T(), scheduled, 1)
+
+	_, err = suite.model.GetCustomReport(r.ID, suite.tenantID+1)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not get the reports of another tenant")
+
+	assert.ErrorIs(suite.T(), suite.model.DeleteCustomReport(r.ID, suite.tenantID+1), ErrNotFound, "should not delete the reports of another tenant")
+	assert.NoError(suite.T(), suite.model.DeleteCustomReport(r.ID, suite.tenantID), "should delete the report")
+
+	reports, err := suite.model.GetCustomReports(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get the reports")
+	assert.Empty(suite.T(), reports)
+}
+
+func TestCustomReportsTestSuite(t *testing.T) {
+	s := new(CustomReportsTestSuite)
+	s.t = t
+	suite.Run(t, s)
+}
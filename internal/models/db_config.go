@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"entgo.io/ent/dialect"
+)
+
+// DBConfig sets the database connection pool and the slow query log.
+// Zero values keep the database/sql defaults and disable the slow query log
+type DBConfig struct {
+	MaxOpenConns       int
+	MaxIdleConns       int
+	ConnMaxLifetime    time.Duration
+	SlowQueryThreshold time.Duration
+}
+
+// DBStats are the connection pool metrics shown in the health endpoint
+type DBStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+}
+
+func (cfg DBConfig) apply(db *sql.DB) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+}
+
+// DBStats returns the state of the database connection pool
+func (m *Model) DBStats() DBStats {
+	if m.db == nil {
+		return DBStats{}
+	}
+
+	stats := m.db.Stats()
+	return DBStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+	}
+}
+
+// Ping checks that the database can be reached running SELECT 1 directly through the connection pool
+func (m *Model) Ping(ctx context.Context) error {
+	if m.db == nil {
+		return errors.New("the database connection is not open")
+	}
+
+	var one int
+	if err := m.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("could not reach the database: %w", err)
+	}
+	return nil
+}
+
+// slowQueryDriver logs the statements that take longer than the threshold. Arguments
+// may contain passwords or personal data so only their number is logged
+type slowQueryDriver struct {
+	dialect.Driver
+	threshold time.Duration
+}
+
+func (d *slowQueryDriver) Exec(ctx context.Context, query string, args, v any) error {
+	defer d.log(time.Now(), query, args)
+	return d.Driver.Exec(ctx, query, args, v)
+}
+
+func (d *slowQueryDriver) Query(ctx context.Context, query string, args, v any) error {
+	defer d.log(time.Now(), query, args)
+	return d.Driver.Query(ctx, query, args, v)
+}
+
+func (d *slowQueryDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowQueryTx{Tx: tx, driver: d}, nil
+}
+
+func (d *slowQueryDriver) log(start time.Time, query string, args any) {
+	if elapsed := time.Since(start); elapsed > d.threshold {
+		log.Printf("[WARN]: slow query took %v: %s (%s)", elapsed, query, redactArgs(args))
+	}
+}
+
+type slowQueryTx struct {
+	dialect.Tx
+	driver *slowQueryDriver
+}
+
+func (tx *slowQueryTx) Exec(ctx context.Context, query string, args, v any) error {
+	defer tx.driver.log(time.Now(), query, args)
+	return tx.Tx.Exec(ctx, query, args, v)
+}
+
+func (tx *slowQueryTx) Query(ctx context.Context, query string, args, v any) error {
+	defer tx.driver.log(time.Now(), query, args)
+	return tx.Tx.Query(ctx, query, args, v)
+}
+
+func redactArgs(args any) string {
+	if values, ok := args.([]any); ok {
+		return fmt.Sprintf("%d arguments redacted", len(values))
+	}
+	return "arguments redacted"
+}
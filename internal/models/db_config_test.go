@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBConfigApply(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:pool?mode=memory")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	DBConfig{}.apply(db)
+	assert.Equal(t, 0, db.Stats().MaxOpenConnections, "zero values should keep the defaults")
+
+	DBConfig{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: time.Minute}.apply(db)
+	assert.Equal(t, 5, db.Stats().MaxOpenConnections, "should limit open connections")
+}
+
+func TestSlowQueryDriver(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:slow?mode=memory")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	driver := &slowQueryDriver{Driver: entsql.OpenDB("sqlite3", db), threshold: time.Hour}
+
+	rows := &entsql.Rows{}
+	assert.NoError(t, driver.Query(context.Background(), "SELECT ?", []any{1}, rows), "should run queries through the wrapped driver")
+	assert.NoError(t, rows.Close())
+
+	tx, err := driver.Tx(context.Background())
+	assert.NoError(t, err, "should start transactions through the wrapped driver")
+	assert.NoError(t, tx.Exec(context.Background(), "CREATE TABLE t (id INTEGER)", []any{}, nil))
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, "2 arguments redacted", redactArgs([]any{"user", "secret"}), "should not log arguments")
+}
+
+func TestPing(t *testing.T) {
+	m := Model{}
+	assert.Error(t, m.Ping(context.Background()), "should fail without a database connection")
+
+	db, err := sql.Open("sqlite3", "file:ping?mode=memory")
+	assert.NoError(t, err)
+	m.db = db
+	assert.NoError(t, m.Ping(context.Background()))
+
+	assert.NoError(t, db.Close())
+	assert.Error(t, m.Ping(context.Background()), "should fail when the connection is closed")
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"strings"
+
+	"entgo.io/ent/dialect"
+	"github.com/open-uem/openuem-console/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingDriver adds a span for each statement to the trace of its context. Like the slow
+// query log, the arguments are never added to the spans
+type tracingDriver struct {
+	dialect.Driver
+}
+
+func (d *tracingDriver) Exec(ctx context.Context, query string, args, v any) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := d.Driver.Exec(ctx, query, args, v)
+	telemetry.End(span, err)
+	return err
+}
+
+func (d *tracingDriver) Query(ctx context.Context, query string, args, v any) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := d.Driver.Query(ctx, query, args, v)
+	telemetry.End(span, err)
+	return err
+}
+
+func (d *tracingDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracingTx{Tx: tx}, nil
+}
+
+type tracingTx struct {
+	dialect.Tx
+}
+
+func (tx *tracingTx) Exec(ctx context.Context, query string, args, v any) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := tx.Tx.Exec(ctx, query, args, v)
+	telemetry.End(span, err)
+	return err
+}
+
+func (tx *tracingTx) Query(ctx context.Context, query string, args, v any) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := tx.Tx.Query(ctx, query, args, v)
+	telemetry.End(span, err)
+	return err
+}
+
+// startQuerySpan starts the span of the statement, named after its operation as the statements
+// are too long and varied to be the name of a span
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+
+	return telemetry.Tracer().Start(ctx, "db "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// DiskUsageEntry is the disk usage of an agent, adding up its logical disks. Sizes are in GB (10^9 bytes)
+type DiskUsageEntry struct {
+	SiteName     string  `json:"site_name"`
+	AgentID      string  `json:"agent_id"`
+	Hostname     string  `json:"hostname"`
+	DiskUsedGB   float64 `json:"disk_used_gb"`
+	DiskTotalGB  float64 `json:"disk_total_gb"`
+	UsagePercent float64 `json:"usage_percent"`
+}
+
+// GetDiskUsageReport returns the disk usage of the agents of the tenant or of the site, the fullest first.
+// Agents without logical disks or whose disk sizes can't be read are not returned
+func (m *Model) GetDiskUsageReport(c *partials.CommonInfo) ([]DiskUsageEntry, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().WithLogicaldisks().WithSite()
+	if siteID == -1 {
+		query = query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query = query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	agents, err := query.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []DiskUsageEntry{}
+	for _, a := range agents {
+		entry := DiskUsageEntry{AgentID: a.ID, Hostname: a.Hostname}
+		for _, s := range a.Edges.Site {
+			if siteID == -1 || s.ID == siteID {
+				entry.SiteName = s.Description
+				break
+			}
+		}
+
+		for _, ld := range a.Edges.Logicaldisks {
+			total, ok := parseDiskSizeGB(ld.SizeInUnits)
+			if !ok || total == 0 {
+				continue
+			}
+			used := total * float64(ld.Usage) / 100
+			if remaining, ok := parseDiskSizeGB(ld.RemainingSpaceInUnits); ok && remaining <= total {
+				used = total - remaining
+			}
+			entry.DiskTotalGB += total
+			entry.DiskUsedGB += used
+		}
+		if entry.DiskTotalGB == 0 {
+			continue
+		}
+		entry.UsagePercent = entry.DiskUsedGB / entry.DiskTotalGB * 100
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].UsagePercent != entries[j].UsagePercent {
+			return entries[i].UsagePercent > entries[j].UsagePercent
+		}
+		if entries[i].SiteName != entries[j].SiteName {
+			return entries[i].SiteName < entries[j].SiteName
+		}
+		return entries[i].Hostname < entries[j].Hostname
+	})
+
+	return entries, nil
+}
+
+// diskSizeUnits are the bytes of the units of the sizes reported by the agents, e.g. 465.8 GiB or 500.1GB
+var diskSizeUnits = map[string]float64{
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+	"PIB": 1 << 50,
+}
+
+// parseDiskSizeGB converts a size reported by the agents to GB
+func parseDiskSizeGB(size string) (float64, bool) {
+	size = strings.TrimSpace(size)
+	i := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != ','
+	})
+	if i <= 0 {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(size[:i], ",", "."), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	bytes, ok := diskSizeUnits[strings.ToUpper(strings.TrimSpace(size[i:]))]
+	if !ok {
+		return 0, false
+	}
+
+	return value * bytes / 1e9, true
+}
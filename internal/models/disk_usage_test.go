@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiskSizeGB(t *testing.T) {
+	for size, want := range map[string]float64{
+		"500 GB":   500,
+		"500.1GB":  500.1,
+		"1,5 TB":   1500,
+		"512 MB":   0.512,
+		"1 GiB":    1.073741824,
+		" 2 tib  ": 2.199023255552,
+	} {
+		got, ok := parseDiskSizeGB(size)
+		assert.True(t, ok, size)
+		assert.InDelta(t, want, got, 0.000001, size)
+	}
+
+	for _, size := range []string{"", "GB", "500", "500 XB", "1.2.3 GB"} {
+		_, ok := parseDiskSizeGB(size)
+		assert.False(t, ok, size)
+	}
+}
+
+func TestGetDiskUsageReport(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:diskusage?mode=memory&_fk=1")
+	defer client.Close()
+	m := Model{Client: client}
+
+	tenant, err := client.Tenant.Create().SetDescription("Tenant").SetIsDefault(true).Save(context.Background())
+	assert.NoError(t, err)
+	s, err := m.CreateDefaultSite(tenant)
+	assert.NoError(t, err)
+
+	for id, disks := range map[string][][2]string{
+		"half":    {{"100 GB", "50 GB"}},
+		"full":    {{"100 GB", "10 GB"}, {"1 TB", "100 GB"}},
+		"unknown": {{"", ""}},
+		"none":    {},
+	} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetNickname(id).SetOs("windows").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(t, err)
+		for _, d := range disks {
+			err := client.LogicalDisk.Create().SetLabel("C:").SetSizeInUnits(d[0]).SetRemainingSpaceInUnits(d[1]).SetOwnerID(id).Exec(context.Background())
+			assert.NoError(t, err)
+		}
+	}
+
+	entries, err := m.GetDiskUsageReport(&partials.CommonInfo{TenantID: strconv.Itoa(tenant.ID), SiteID: strconv.Itoa(s.ID)})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2, "should skip the agents without disk sizes") {
+		assert.Equal(t, "full", entries[0].AgentID, "should return the fullest agents first")
+		assert.Equal(t, s.Description, entries[0].SiteName)
+		assert.InDelta(t, 1100, entries[0].DiskTotalGB, 0.001, "should add up the logical disks")
+		assert.InDelta(t, 990, entries[0].DiskUsedGB, 0.001)
+		assert.InDelta(t, 90, entries[0].UsagePercent, 0.001)
+		assert.InDelta(t, 50, entries[1].UsagePercent, 0.001)
+	}
+
+	entries, err = m.GetDiskUsageReport(&partials.CommonInfo{TenantID: strconv.Itoa(tenant.ID + 1), SiteID: "-1"})
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "should not return the agents of other tenants")
+}
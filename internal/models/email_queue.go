@@ -0,0 +1,252 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/emailmessage"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/wneessen/go-mail"
+)
+
+// Statuses of the emails in the queue. An email is pending until the worker claims it, then it's sent,
+// or pending again until it runs out of attempts and fails
+const (
+	EmailStatusPending   = "pending"
+	EmailStatusSending   = "sending"
+	EmailStatusSent      = "sent"
+	EmailStatusFailed    = "failed"
+	EmailStatusCancelled = "cancelled"
+)
+
+// Kinds of the emails, they tell the admins in the history what sent them
+const (
+	EmailKindBackupFailure = "backup_failure"
+	EmailKindWeeklyReport  = "weekly_report"
+	EmailKindAccessRequest = "access_request"
+)
+
+// EmailMaxAttempts is how many times an email is tried before it fails
+const EmailMaxAttempts = 8
+
+const (
+	// emailFirstRetryDelay is the wait after the first failed attempt, it's doubled after each attempt
+	emailFirstRetryDelay = time.Minute
+	// emailMaxRetryDelay caps the wait, so the last attempts aren't days apart
+	emailMaxRetryDelay = 2 * time.Hour
+	// emailHistoryRetention is how long the sent and cancelled emails are kept in the history
+	emailHistoryRetention = 30 * 24 * time.Hour
+)
+
+// Email is a message to enqueue
+type Email struct {
+	// TenantID selects the SMTP settings of the tenant, 0 uses the global settings
+	TenantID int
+	Kind     string
+	// To are the recipients, they're sent as blind copies so they don't see each other
+	To      []string
+	Subject string
+	Body    string
+}
+
+// ErrEmailNotRetryable is returned when a retry or a cancel doesn't apply to the status of the email
+var ErrEmailNotRetryable = errors.New("the email can't be changed in its current status")
+
+// EnqueueEmail stores an email to be delivered by the worker as soon as possible
+func (m *Model) EnqueueEmail(e Email) error {
+	if len(e.To) == 0 {
+		return fmt.Errorf("the email %q has no recipients", e.Subject)
+	}
+
+	return m.Client.EmailMessage.Create().
+		SetTenantID(e.TenantID).
+		SetKind(e.Kind).
+		SetRecipients(e.To).
+		SetSubject(e.Subject).
+		SetBody(e.Body).
+		SetStatus(EmailStatusPending).
+		SetNextAttemptAt(time.Now()).
+		SetCreatedAt(time.Now()).
+		Exec(context.Background())
+}
+
+// EmailRetryDelay is the wait before the next attempt after the failed attempts
+func EmailRetryDelay(attempts int) time.Duration {
+	delay := emailFirstRetryDelay
+	for i := 1; i < attempts && delay < emailMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, emailMaxRetryDelay)
+}
+
+// ClaimEmails marks as sending up to limit pending emails whose attempt is due, oldest first. An email
+// is claimed only once even if several consoles share the database
+func (m *Model) ClaimEmails(now time.Time, limit int) ([]*ent.EmailMessage, error) {
+	ctx := context.Background()
+
+	due, err := m.Client.EmailMessage.Query().
+		Where(emailmessage.Status(EmailStatusPending), emailmessage.NextAttemptAtLTE(now)).
+		Order(ent.Asc(emailmessage.FieldNextAttemptAt), ent.Asc(emailmessage.FieldID)).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]*ent.EmailMessage, 0, len(due))
+	for _, e := range due {
+		n, err := m.Client.EmailMessage.Update().
+			Where(emailmessage.ID(e.ID), emailmessage.Status(EmailStatusPending)).
+			SetStatus(EmailStatusSending).
+			Save(ctx)
+		if err != nil {
+			return claimed, err
+		}
+		if n == 1 {
+			e.Status = EmailStatusSending
+			claimed = append(claimed, e)
+		}
+	}
+	return claimed, nil
+}
+
+// ReleaseInterruptedEmails makes pending again the emails that were being sent when the console stopped
+func (m *Model) ReleaseInterruptedEmails() error {
+	return m.Client.EmailMessage.Update().
+		Where(emailmessage.Status(EmailStatusSending)).
+		SetStatus(EmailStatusPending).
+		Exec(context.Background())
+}
+
+// DeliverEmail sends a claimed email with the SMTP server of its tenant or the global SMTP settings
+func (m *Model) DeliverEmail(e *ent.EmailMessage) error {
+	settings, err := m.TenantSMTPSettings(e.TenantID)
+	if err != nil {
+		return err
+	}
+
+	if settings.Server == "" || settings.Port == 0 || settings.MailFrom == "" {
+		return fmt.Errorf("SMTP settings are not configured")
+	}
+
+	c, err := NewMailClient(settings)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.NewMsg()
+	if err := setMailFrom(msg, settings); err != nil {
+		return err
+	}
+	if err := msg.Bcc(e.Recipients...); err != nil {
+		return err
+	}
+	msg.Subject(e.Subject)
+	msg.SetBodyString(mail.TypeTextPlain, e.Body)
+
+	return c.DialAndSend(msg)
+}
+
+// EmailSent records that a claimed email has been delivered
+func (m *Model) EmailSent(id int, now time.Time) error {
+	return m.Client.EmailMessage.UpdateOneID(id).
+		SetStatus(EmailStatusSent).
+		AddAttempts(1).
+		SetSentAt(now).
+		SetLastError("").
+		Exec(context.Background())
+}
+
+// EmailDeliveryFailed records a failed attempt of a claimed email, it's pending again after the backoff
+// unless it has run out of attempts
+func (m *Model) EmailDeliveryFailed(e *ent.EmailMessage, deliveryErr error, now time.Time) error {
+	attempts := e.Attempts + 1
+
+	update := m.Client.EmailMessage.UpdateOneID(e.ID).
+		SetAttempts(attempts).
+		SetLastError(deliveryErr.Error())
+	if attempts >= EmailMaxAttempts {
+		update.SetStatus(EmailStatusFailed)
+	} else {
+		update.SetStatus(EmailStatusPending).SetNextAttemptAt(now.Add(EmailRetryDelay(attempts)))
+	}
+	return update.Exec(context.Background())
+}
+
+// RetryEmail queues again a failed or cancelled email with all its attempts
+func (m *Model) RetryEmail(id int) error {
+	n, err := m.Client.EmailMessage.Update().
+		Where(emailmessage.ID(id), emailmessage.StatusIn(EmailStatusFailed, EmailStatusCancelled)).
+		SetStatus(EmailStatusPending).
+		SetAttempts(0).
+		SetNextAttemptAt(time.Now()).
+		Save(context.Background())
+	return m.emailUpdateError(id, n, err)
+}
+
+// CancelEmail stops the delivery of a pending email
+func (m *Model) CancelEmail(id int) error {
+	n, err := m.Client.EmailMessage.Update().
+		Where(emailmessage.ID(id), emailmessage.Status(EmailStatusPending)).
+		SetStatus(EmailStatusCancelled).
+		Save(context.Background())
+	return m.emailUpdateError(id, n, err)
+}
+
+// emailUpdateError tells apart the emails that don't exist from those whose status doesn't allow the change
+func (m *Model) emailUpdateError(id, updated int, err error) error {
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	exists, err := m.Client.EmailMessage.Query().Where(emailmessage.ID(id)).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrEmailNotRetryable
+}
+
+// DeleteOldEmails removes from the history the emails sent or cancelled before the retention
+func (m *Model) DeleteOldEmails(now time.Time) error {
+	_, err := m.Client.EmailMessage.Delete().
+		Where(
+			emailmessage.StatusIn(EmailStatusSent, EmailStatusCancelled),
+			emailmessage.CreatedAtLT(now.Add(-emailHistoryRetention)),
+		).
+		Exec(context.Background())
+	return err
+}
+
+// GetEmailsByPage returns a page of the emails in the history, the latest first
+func (m *Model) GetEmailsByPage(p partials.PaginationAndSort, f filters.EmailFilter) ([]*ent.EmailMessage, error) {
+	query := m.Client.EmailMessage.Query()
+
+	applyEmailsFilter(query, f)
+
+	return query.
+		Order(ent.Desc(emailmessage.FieldCreatedAt), ent.Desc(emailmessage.FieldID)).
+		Limit(p.PageSize).
+		Offset((p.CurrentPage - 1) * p.PageSize).
+		All(context.Background())
+}
+
+func (m *Model) CountEmails(f filters.EmailFilter) (int, error) {
+	query := m.Client.EmailMessage.Query()
+
+	applyEmailsFilter(query, f)
+
+	return query.Count(context.Background())
+}
+
+func applyEmailsFilter(query *ent.EmailMessageQuery, f filters.EmailFilter) {
+	if len(f.StatusOptions) > 0 {
+		query.Where(emailmessage.StatusIn(f.StatusOptions...))
+	}
+}
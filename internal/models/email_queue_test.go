@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/emailmessage"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EmailQueueTestSuite struct {
+	suite.Suite
+	t     enttest.TestingT
+	model Model
+}
+
+func (suite *EmailQueueTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+}
+
+func (suite *EmailQueueTestSuite) enqueue(subject string) {
+	err := suite.model.EnqueueEmail(Email{Kind: EmailKindBackupFailure, To: []string{"admin@example.com"}, Subject: subject, Body: "body"})
+	assert.NoError(suite.T(), err, "should enqueue email")
+}
+
+func (suite *EmailQueueTestSuite) TestEmailRetryDelay() {
+	assert.Equal(suite.T(), time.Minute, EmailRetryDelay(1))
+	assert.Equal(suite.T(), 2*time.Minute, EmailRetryDelay(2))
+	assert.Equal(suite.T(), 8*time.Minute, EmailRetryDelay(4))
+	assert.Equal(suite.T(), 2*time.Hour, EmailRetryDelay(20), "should cap the delay")
+}
+
+func (suite *EmailQueueTestSuite) TestEnqueueAndClaim() {
+	err := suite.model.EnqueueEmail(Email{Subject: "nobody"})
+	assert.Error(suite.T(), err, "should not enqueue emails without recipients")
+
+	suite.enqueue("first")
+	suite.enqueue("second")
+	suite.enqueue("third")
+
+	claimed, err := suite.model.ClaimEmails(time.Now(), 2)
+	assert.NoError(suite.T(), err, "should claim emails")
+	if assert.Len(suite.T(), claimed, 2) {
+		assert.Equal(suite.T(), "first", claimed[0].Subject, "should claim the oldest first")
+		assert.Equal(suite.T(), []string{"admin@example.com"}, claimed[0].Recipients)
+	}
+
+	claimed, err = suite.model.ClaimEmails(time.Now(), 10)
+	assert.NoError(suite.T(), err, "should claim emails")
+	assert.Len(suite.T(), claimed, 1, "should not claim the emails twice")
+
+	// The console stopped while the emails were being sent
+	assert.NoError(suite.T(), suite.model.ReleaseInterruptedEmails(), "should release interrupted emails")
+	claimed, err = suite.model.ClaimEmails(time.Now(), 10)
+	assert.NoError(suite.T(), err, "should claim emails")
+	assert.Len(suite.T(), claimed, 3, "should claim the released emails again")
+}
+
+func (suite *EmailQueueTestSuite) TestDeliveryFailuresBackoff() {
+	suite.enqueue("report")
+	now := time.Now()
+
+	for attempt := 1; attempt <= EmailMaxAttempts; attempt++ {
+		claimed, err := suite.model.ClaimEmails(now, 10)
+		assert.NoError(suite.T(), err, "should claim emails")
+		if !assert.Len(suite.T(), claimed, 1, "should claim the email when its attempt is due") {
+			return
+		}
+
+		err = suite.model.EmailDeliveryFailed(claimed[0], errors.New("connection refused"), now)
+		assert.NoError(suite.T(), err, "should save the failed attempt")
+
+		e, err := suite.model.Client.EmailMessage.Get(context.Background(), claimed[0].ID)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), attempt, e.Attempts)
+		assert.Equal(suite.T(), "connection refused", e.LastError)
+
+		if attempt < EmailMaxAttempts {
+			assert.Equal(suite.T(), EmailStatusPending, e.Status)
+			claimed, err = suite.model.ClaimEmails(now, 10)
+			assert.NoError(suite.T(), err)
+			assert.Empty(suite.T(), claimed, "should wait before the next attempt")
+			now = e.NextAttemptAt
+		} else {
+			assert.Equal(suite.T(), EmailStatusFailed, e.Status, "should fail after the last attempt")
+		}
+	}
+
+	claimed, err := suite.model.ClaimEmails(now.Add(24*time.Hour), 10)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), claimed, "should not try failed emails again")
+}
+
+func (suite *EmailQueueTestSuite) TestRetryAndCancel() {
+	suite.enqueue("report")
+	claimed, err := suite.model.ClaimEmails(time.Now(), 10)
+	assert.NoError(suite.T(), err)
+	id := claimed[0].ID
+
+	assert.ErrorIs(suite.T(), suite.model.RetryEmail(id), ErrEmailNotRetryable, "should not retry an email being sent")
+	assert.ErrorIs(suite.T(), suite.model.CancelEmail(id), ErrEmailNotRetryable, "should not cancel an email being sent")
+	assert.ErrorIs(suite.T(), suite.model.RetryEmail(9999), ErrNotFound)
+
+	claimed[0].Attempts = EmailMaxAttempts - 1
+	assert.NoError(suite.T(), suite.model.EmailDeliveryFailed(claimed[0], errors.New("timeout"), time.Now()))
+
+	assert.NoError(suite.T(), suite.model.RetryEmail(id), "should retry a failed email")
+	e, err := suite.model.Client.EmailMessage.Get(context.Background(), id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), EmailStatusPending, e.Status)
+	assert.Equal(suite.T(), 0, e.Attempts, "should have all the attempts again")
+
+	assert.NoError(suite.T(), suite.model.CancelEmail(id), "should cancel a pending email")
+	claimed, err = suite.model.ClaimEmails(time.Now(), 10)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), claimed, "should not send cancelled emails")
+}
+
+func (suite *EmailQueueTestSuite) TestEmailSentAndHistory() {
+	suite.enqueue("old")
+	suite.enqueue("pending")
+
+	claimed, err := suite.model.ClaimEmails(time.Now(), 1)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.model.EmailSent(claimed[0].ID, time.Now()), "should save the email as sent")
+
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+	emails, err := suite.model.GetEmailsByPage(p, filters.EmailFilter{StatusOptions: []string{EmailStatusSent}})
+	assert.NoError(suite.T(), err, "should get sent emails")
+	if assert.Len(suite.T(), emails, 1) {
+		assert.Equal(suite.T(), "old", emails[0].Subject)
+		assert.Equal(suite.T(), 1, emails[0].Attempts)
+		assert.NotNil(suite.T(), emails[0].SentAt)
+	}
+
+	count, err := suite.model.CountEmails(filters.EmailFilter{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count)
+
+	// Only the sent emails past the retention are removed from the history
+	err = suite.model.Client.EmailMessage.Update().SetCreatedAt(time.Now().Add(-40 * 24 * time.Hour)).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.model.DeleteOldEmails(time.Now()), "should delete old emails")
+
+	remaining, err := suite.model.Client.EmailMessage.Query().Where(emailmessage.Status(EmailStatusPending)).Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, remaining, "should keep the pending emails")
+	count, err = suite.model.CountEmails(filters.EmailFilter{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+}
+
+func (suite *EmailQueueTestSuite) TestDeliverEmailWithoutSettings() {
+	suite.enqueue("report")
+	claimed, err := suite.model.ClaimEmails(time.Now(), 1)
+	assert.NoError(suite.T(), err)
+
+	assert.Error(suite.T(), suite.model.DeliverEmail(claimed[0]), "should not send emails until SMTP is configured")
+}
+
+func TestEmailQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(EmailQueueTestSuite))
+}
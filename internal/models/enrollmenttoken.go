@@ -2,14 +2,90 @@ package models
 
 import (
 	"context"
+	"slices"
 	"time"
 
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
 	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
 	"github.com/open-uem/ent/enrollmenttoken"
+	"github.com/open-uem/ent/predicate"
+	"github.com/open-uem/ent/site"
 	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
-func (m *Model) CreateEnrollmentToken(tenantID int, siteID *int, description string, tokenValue string, maxUses int, expiresAt *time.Time) (*ent.EnrollmentToken, error) {
+// Statuses of an enrollment token to filter the tokens, they're the keys of their translations
+const (
+	EnrollmentTokenActive   = "enrollment.active"
+	EnrollmentTokenInactive = "enrollment.inactive"
+	EnrollmentTokenExpired  = "enrollment.expired"
+)
+
+var EnrollmentTokenStatuses = []string{EnrollmentTokenActive, EnrollmentTokenInactive, EnrollmentTokenExpired}
+
+// EnrollmentTokenPlatforms are the operating systems a token can be restricted to, they're the values of
+// the platform param of the config download
+var EnrollmentTokenPlatforms = []string{"linux", "macos", "windows"}
+
+// NormalizeEnrollmentTokenPlatforms keeps the known platforms once and in order, an empty list allows all of them
+func NormalizeEnrollmentTokenPlatforms(platforms []string) []string {
+	allowed := []string{}
+	for _, p := range EnrollmentTokenPlatforms {
+		if slices.Contains(platforms, p) {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+// EnrollmentTokenAllowsPlatform returns true if the token can enroll agents of the platform
+func EnrollmentTokenAllowsPlatform(t *ent.EnrollmentToken, platform string) bool {
+	return len(t.AllowedOs) == 0 || slices.Contains(t.AllowedOs, platform)
+}
+
+// EnrollmentTokenIsExpired returns true if the token has an expiration date and it has been reached, as the
+// expired status of the filters
+func EnrollmentTokenIsExpired(t *ent.EnrollmentToken) bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())
+}
+
+// EnrollmentTokenStatus returns the status of the token, one of EnrollmentTokenStatuses. An inactive token
+// is shown as inactive even if it has expired
+func EnrollmentTokenStatus(t *ent.EnrollmentToken) string {
+	switch {
+	case !t.Active:
+		return EnrollmentTokenInactive
+	case EnrollmentTokenIsExpired(t):
+		return EnrollmentTokenExpired
+	default:
+		return EnrollmentTokenActive
+	}
+}
+
+// EnrollmentTokenSites are the sites the agents enrolled with a token can be in. The agents are enrolled
+// in the default site, the site edge of the token, and operators can move them to the other allowed sites
+// while they wait for admission. A token without sites enrolls the agents in the default site of the tenant
+type EnrollmentTokenSites struct {
+	DefaultID int
+	IDs       []int
+}
+
+// normalize makes the default site one of the allowed sites, the first one if there's no default
+func (s EnrollmentTokenSites) normalize() EnrollmentTokenSites {
+	ids := slices.Compact(slices.Sorted(slices.Values(s.IDs)))
+	if s.DefaultID > 0 && !slices.Contains(ids, s.DefaultID) {
+		ids = append(ids, s.DefaultID)
+	}
+	if s.DefaultID <= 0 && len(ids) > 0 {
+		s.DefaultID = ids[0]
+	}
+	return EnrollmentTokenSites{DefaultID: s.DefaultID, IDs: ids}
+}
+
+func (m *Model) CreateEnrollmentToken(tenantID int, sites EnrollmentTokenSites, description string, tokenValue string, maxUses int, expiresAt *time.Time, allowedOS []string) (*ent.EnrollmentToken, error) {
 	query := m.Client.EnrollmentToken.Create().
 		SetToken(tokenValue).
 		SetDescription(description).
@@ -17,55 +93,272 @@ func (m *Model) CreateEnrollmentToken(tenantID int, siteID *int, description str
 		SetActive(true).
 		SetTenantID(tenantID)
 
-	if siteID != nil && *siteID > 0 {
-		query.SetSiteID(*siteID)
+	sites = sites.normalize()
+	if sites.DefaultID > 0 {
+		query.SetSiteID(sites.DefaultID).AddAllowedSiteIDs(sites.IDs...)
 	}
 
 	if expiresAt != nil {
 		query.SetExpiresAt(*expiresAt)
 	}
 
-	return query.Save(context.Background())
-}
+	if allowedOS = NormalizeEnrollmentTokenPlatforms(allowedOS); len(allowedOS) > 0 {
+		query.SetAllowedOs(allowedOS)
+	}
 
-func (m *Model) GetEnrollmentTokens(tenantID int) ([]*ent.EnrollmentToken, error) {
-	return m.Client.EnrollmentToken.Query().
-		Where(enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).
-		WithSite().
-		Order(ent.Desc(enrollmenttoken.FieldCreated)).
-		All(context.Background())
+	t, err := query.Save(context.Background())
+	return t, dbError(err)
 }
 
 func (m *Model) GetEnrollmentTokenByID(tokenID int) (*ent.EnrollmentToken, error) {
-	return m.Client.EnrollmentToken.Query().
+	t, err := m.Client.EnrollmentToken.Query().
 		Where(enrollmenttoken.ID(tokenID)).
 		WithSite().
+		WithAllowedSites().
+		WithTenant().
+		Only(context.Background())
+	return t, dbError(err)
+}
+
+// GetEnrollmentTokenByIDForTenant returns the token only if it belongs to the tenant, so
+// tokens from other tenants are not found
+func (m *Model) GetEnrollmentTokenByIDForTenant(tokenID int, tenantID int) (*ent.EnrollmentToken, error) {
+	t, err := m.Client.EnrollmentToken.Query().
+		Where(enrollmenttoken.ID(tokenID), enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).
+		WithSite().
+		WithAllowedSites().
 		WithTenant().
 		Only(context.Background())
+	return t, dbError(err)
 }
 
 func (m *Model) DeleteEnrollmentToken(tokenID int) error {
-	return m.Client.EnrollmentToken.DeleteOneID(tokenID).Exec(context.Background())
+	return dbError(m.Client.EnrollmentToken.DeleteOneID(tokenID).Exec(context.Background()))
 }
 
 func (m *Model) ToggleEnrollmentToken(tokenID int, active bool) error {
-	return m.Client.EnrollmentToken.UpdateOneID(tokenID).
+	return dbError(m.Client.EnrollmentToken.UpdateOneID(tokenID).
 		SetActive(active).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
-func (m *Model) GetEnrollmentTokenByValue(tokenValue string) (*ent.EnrollmentToken, error) {
-	return m.Client.EnrollmentToken.Query().
+// ToggleEnrollmentTokenLandingPage publishes or hides the page where end users enroll their devices with the token
+func (m *Model) ToggleEnrollmentTokenLandingPage(tokenID int, enabled bool) error {
+	return dbError(m.Client.EnrollmentToken.UpdateOneID(tokenID).
+		SetLandingPage(enabled).
+		Exec(context.Background()))
+}
+
+// GetEnrollmentTokenByValue returns the token with the value and the number of times it can still be
+// used, UnlimitedUses if it has no limit. Use UseEnrollmentToken to check the limit before using it
+func (m *Model) GetEnrollmentTokenByValue(tokenValue string) (*ent.EnrollmentToken, int, error) {
+	t, err := m.Client.EnrollmentToken.Query().
 		Where(enrollmenttoken.Token(tokenValue)).
 		WithSite().
 		WithTenant().
 		Only(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+	return t, remainingUses(t), nil
 }
 
-func (m *Model) IncrementEnrollmentTokenUses(tokenValue string) error {
-	_, err := m.Client.EnrollmentToken.Update().
+// UseEnrollmentToken counts a use of the token with the value if use, called with the token and the
+// number of times it can still be used, doesn't return an error. The token is locked until use returns,
+// so concurrent requests can't use it more times than its limit
+func (m *Model) UseEnrollmentToken(tokenValue string, use func(token *ent.EnrollmentToken, remainingUses int) error) error {
+	tx, err := m.Client.Tx(context.Background())
+	if err != nil {
+		return err
+	}
+
+	t, err := tx.EnrollmentToken.Query().
 		Where(enrollmenttoken.Token(tokenValue)).
-		AddCurrentUses(1).
-		Save(context.Background())
-	return err
+		WithSite().
+		WithTenant().
+		Modify(func(s *sql.Selector) {
+			// SQLite doesn't support row locks, its writes are serialized
+			if s.Dialect() != dialect.SQLite {
+				s.ForUpdate()
+			}
+		}).
+		Only(context.Background())
+	if err != nil {
+		return rollback(tx, err)
+	}
+
+	if err := use(t, remainingUses(t)); err != nil {
+		return rollback(tx, err)
+	}
+
+	if err := tx.EnrollmentToken.UpdateOneID(t.ID).AddCurrentUses(1).Exec(context.Background()); err != nil {
+		return rollback(tx, err)
+	}
+
+	if err := countTokenDownload(context.Background(), tx, t.ID); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEnrollmentTokenSites replaces the sites of the token of the tenant. It returns ErrNotFound if the
+// token or any of the sites don't belong to the tenant
+func (m *Model) UpdateEnrollmentTokenSites(tokenID, tenantID int, sites EnrollmentTokenSites) error {
+	ctx := context.Background()
+	sites = sites.normalize()
+
+	n, err := m.Client.Site.Query().Where(site.IDIn(sites.IDs...), site.HasTenantWith(tenant.ID(tenantID))).Count(ctx)
+	if err != nil {
+		return err
+	}
+	if n != len(sites.IDs) {
+		return ErrNotFound
+	}
+
+	update := m.Client.EnrollmentToken.Update().
+		Where(enrollmenttoken.ID(tokenID), enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).
+		ClearAllowedSites().
+		AddAllowedSiteIDs(sites.IDs...)
+	if sites.DefaultID > 0 {
+		update.SetSiteID(sites.DefaultID)
+	} else {
+		update.ClearSite()
+	}
+
+	updated, err := update.Save(ctx)
+	if err != nil {
+		return dbError(err)
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MigrateEnrollmentTokenSites allows the tokens bound to a single site, before tokens could have several
+// sites, to enroll agents in that site
+func (m *Model) MigrateEnrollmentTokenSites() error {
+	ctx := context.Background()
+
+	tokens, err := m.Client.EnrollmentToken.Query().
+		Where(enrollmenttoken.HasSite(), enrollmenttoken.Not(enrollmenttoken.HasAllowedSites())).
+		WithSite().
+		All(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		if err := m.Client.EnrollmentToken.UpdateOneID(t.ID).AddAllowedSiteIDs(t.Edges.Site.ID).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAgentEnrollmentSites returns the sites allowed by the token the agent of the tenant was enrolled with,
+// none if it wasn't enrolled with a token
+func (m *Model) GetAgentEnrollmentSites(agentID string, tenantID int) ([]*ent.Site, error) {
+	return m.Client.Site.Query().
+		Where(
+			site.HasTenantWith(tenant.ID(tenantID)),
+			site.HasAllowedEnrollmentTokensWith(enrollmenttoken.HasAgentsWith(agent.ID(agentID))),
+		).
+		Order(ent.Asc(site.FieldDescription)).
+		All(context.Background())
+}
+
+// SetEnrollmentTokenConfigHash saves the hash of the config package of the token if it has changed, and
+// returns when it changed last. The date has the precision of the Last-Modified header, in seconds
+func (m *Model) SetEnrollmentTokenConfigHash(t *ent.EnrollmentToken, hash string) (time.Time, error) {
+	if t.ConfigHash == hash && t.ConfigUpdatedAt != nil {
+		return *t.ConfigUpdatedAt, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := m.Client.EnrollmentToken.UpdateOneID(t.ID).
+		SetConfigHash(hash).
+		SetConfigUpdatedAt(now).
+		Exec(context.Background()); err != nil {
+		return time.Time{}, err
+	}
+
+	t.ConfigHash = hash
+	t.ConfigUpdatedAt = &now
+	return now, nil
+}
+
+// UnlimitedUses is the number of remaining uses of the tokens without a limit of uses
+const UnlimitedUses = -1
+
+func remainingUses(t *ent.EnrollmentToken) int {
+	if t.MaxUses == 0 {
+		return UnlimitedUses
+	}
+	return max(t.MaxUses-t.CurrentUses, 0)
+}
+
+// GetEnrollmentTokensByPage returns a page of the tokens of the tenant, the latest first by default
+func (m *Model) GetEnrollmentTokensByPage(tenantID int, p partials.PaginationAndSort, f filters.EnrollmentTokenFilter) ([]*ent.EnrollmentToken, error) {
+	query := m.Client.EnrollmentToken.Query().Where(enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).WithSite().WithAllowedSites()
+
+	applyEnrollmentTokensFilter(query, f)
+
+	switch p.SortBy {
+	case "description":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(enrollmenttoken.FieldDescription))
+		} else {
+			query.Order(ent.Desc(enrollmenttoken.FieldDescription))
+		}
+	case "uses":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(enrollmenttoken.FieldCurrentUses))
+		} else {
+			query.Order(ent.Desc(enrollmenttoken.FieldCurrentUses))
+		}
+	case "expires":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(enrollmenttoken.FieldExpiresAt))
+		} else {
+			query.Order(ent.Desc(enrollmenttoken.FieldExpiresAt))
+		}
+	default:
+		query.Order(ent.Desc(enrollmenttoken.FieldCreated))
+	}
+
+	return query.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize).All(context.Background())
+}
+
+func (m *Model) CountEnrollmentTokens(tenantID int, f filters.EnrollmentTokenFilter) (int, error) {
+	query := m.Client.EnrollmentToken.Query().Where(enrollmenttoken.HasTenantWith(tenant.ID(tenantID)))
+
+	applyEnrollmentTokensFilter(query, f)
+
+	return query.Count(context.Background())
+}
+
+func applyEnrollmentTokensFilter(query *ent.EnrollmentTokenQuery, f filters.EnrollmentTokenFilter) {
+	if len(f.Description) > 0 {
+		query.Where(enrollmenttoken.DescriptionContainsFold(f.Description))
+	}
+
+	if len(f.StatusOptions) > 0 {
+		now := time.Now()
+		status := []predicate.EnrollmentToken{}
+		for _, option := range f.StatusOptions {
+			switch option {
+			case EnrollmentTokenActive:
+				status = append(status, enrollmenttoken.And(enrollmenttoken.Active(true), enrollmenttoken.Or(enrollmenttoken.ExpiresAtIsNil(), enrollmenttoken.ExpiresAtGT(now))))
+			case EnrollmentTokenInactive:
+				status = append(status, enrollmenttoken.Active(false))
+			case EnrollmentTokenExpired:
+				status = append(status, enrollmenttoken.And(enrollmenttoken.Active(true), enrollmenttoken.ExpiresAtLTE(now)))
+			}
+		}
+		if len(status) > 0 {
+			query.Where(enrollmenttoken.Or(status...))
+		}
+	}
 }
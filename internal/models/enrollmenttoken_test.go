@@ -0,0 +1,275 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EnrollmentTokenTestSuite struct {
+	suite.Suite
+	t              enttest.TestingT
+	model          Model
+	tenantID       int
+	secondTenantID int
+	tokenID        int
+}
+
+func (suite *EnrollmentTokenTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	tenant, err := client.Tenant.Create().SetDescription("TestTenant").SetIsDefault(true).Save(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.tenantID = tenant.ID
+
+	secondTenant, err := client.Tenant.Create().SetDescription("SecondTenant").Save(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.secondTenantID = secondTenant.ID
+
+	token, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Office", "11111111-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(suite.T(), err, "should create enrollment token")
+	suite.tokenID = token.ID
+}
+
+func (suite *EnrollmentTokenTestSuite) TestGetEnrollmentTokenByIDForTenant() {
+	token, err := suite.model.GetEnrollmentTokenByIDForTenant(suite.tokenID, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get token of the tenant")
+	assert.Equal(suite.T(), "Office", token.Description)
+	assert.Equal(suite.T(), suite.tenantID, token.Edges.Tenant.ID, "should load the tenant")
+
+	_, err = suite.model.GetEnrollmentTokenByIDForTenant(suite.tokenID, suite.secondTenantID)
+	assert.True(suite.T(), openuem_ent.IsNotFound(err), "should not find token of another tenant")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestGetEnrollmentTokensByPage() {
+	expired := time.Now().Add(-time.Hour)
+	_, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Warehouse", "22222222-2222-3333-4444-555555555555", 0, &expired, nil)
+	assert.NoError(suite.T(), err)
+	_, err = suite.model.CreateEnrollmentToken(suite.secondTenantID, EnrollmentTokenSites{}, "Office abroad", "33333333-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(suite.T(), err)
+
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "description", SortOrder: "asc"}
+	tokens, err := suite.model.GetEnrollmentTokensByPage(suite.tenantID, p, filters.EnrollmentTokenFilter{})
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), tokens, 2, "should only get the tokens of the tenant") {
+		assert.Equal(suite.T(), "Office", tokens[0].Description)
+		assert.Equal(suite.T(), "Warehouse", tokens[1].Description)
+	}
+
+	f := filters.EnrollmentTokenFilter{Description: "office"}
+	count, err := suite.model.CountEnrollmentTokens(suite.tenantID, f)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count, "should filter by description")
+
+	f = filters.EnrollmentTokenFilter{StatusOptions: []string{EnrollmentTokenExpired}}
+	tokens, err = suite.model.GetEnrollmentTokensByPage(suite.tenantID, p, f)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), tokens, 1, "should filter by status") {
+		assert.Equal(suite.T(), "Warehouse", tokens[0].Description)
+	}
+
+	f = filters.EnrollmentTokenFilter{StatusOptions: []string{"unknown"}}
+	count, err = suite.model.CountEnrollmentTokens(suite.tenantID, f)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count, "should ignore unknown statuses")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestEnrollmentTokenStatus() {
+	token, err := suite.model.GetEnrollmentTokenByID(suite.tokenID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), EnrollmentTokenIsExpired(token), "a token without expiration date should not expire")
+	assert.Equal(suite.T(), EnrollmentTokenActive, EnrollmentTokenStatus(token))
+
+	expired := time.Now().Add(-time.Hour)
+	token.ExpiresAt = &expired
+	assert.True(suite.T(), EnrollmentTokenIsExpired(token))
+	assert.Equal(suite.T(), EnrollmentTokenExpired, EnrollmentTokenStatus(token))
+
+	token.Active = false
+	assert.Equal(suite.T(), EnrollmentTokenInactive, EnrollmentTokenStatus(token), "an inactive token should be shown as inactive even if it has expired")
+
+	notExpired := time.Now().Add(time.Hour).In(time.FixedZone("UTC-10", -10*60*60))
+	token.ExpiresAt = &notExpired
+	assert.False(suite.T(), EnrollmentTokenIsExpired(token), "the time zone of the expiration date should not matter")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestUseEnrollmentToken() {
+	_, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Lab", "44444444-2222-3333-4444-555555555555", 2, nil, nil)
+	assert.NoError(suite.T(), err)
+
+	_, remaining, err := suite.model.GetEnrollmentTokenByValue("44444444-2222-3333-4444-555555555555")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, remaining)
+
+	errLimit := errors.New("limit reached")
+	use := func(token *openuem_ent.EnrollmentToken, remainingUses int) error {
+		if remainingUses == 0 {
+			return errLimit
+		}
+		return nil
+	}
+	assert.NoError(suite.T(), suite.model.UseEnrollmentToken("44444444-2222-3333-4444-555555555555", use))
+	assert.NoError(suite.T(), suite.model.UseEnrollmentToken("44444444-2222-3333-4444-555555555555", use))
+	assert.ErrorIs(suite.T(), suite.model.UseEnrollmentToken("44444444-2222-3333-4444-555555555555", use), errLimit)
+
+	token, remaining, err := suite.model.GetEnrollmentTokenByValue("44444444-2222-3333-4444-555555555555")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, token.CurrentUses, "should not count the uses that failed")
+	assert.Equal(suite.T(), 0, remaining)
+
+	assert.NoError(suite.T(), suite.model.UseEnrollmentToken("11111111-2222-3333-4444-555555555555", func(token *openuem_ent.EnrollmentToken, remainingUses int) error {
+		assert.Equal(suite.T(), UnlimitedUses, remainingUses)
+		return nil
+	}))
+
+	err = suite.model.UseEnrollmentToken("55555555-2222-3333-4444-555555555555", use)
+	assert.True(suite.T(), openuem_ent.IsNotFound(err))
+}
+
+func (suite *EnrollmentTokenTestSuite) TestGetTokenDownloadStats() {
+	use := func(token *openuem_ent.EnrollmentToken, remainingUses int) error { return nil }
+	assert.NoError(suite.T(), suite.model.UseEnrollmentToken("11111111-2222-3333-4444-555555555555", use))
+	assert.NoError(suite.T(), suite.model.UseEnrollmentToken("11111111-2222-3333-4444-555555555555", use))
+	assert.Error(suite.T(), suite.model.UseEnrollmentToken("11111111-2222-3333-4444-555555555555", func(token *openuem_ent.EnrollmentToken, remainingUses int) error {
+		return errors.New("download failed")
+	}))
+
+	err := suite.model.Client.TokenDownloadStat.Create().SetTokenID(suite.tokenID).SetDate(downloadStatDate(time.Now().AddDate(0, 0, -3))).SetCount(5).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	err = suite.model.Client.TokenDownloadStat.Create().SetTokenID(suite.tokenID).SetDate(downloadStatDate(time.Now().AddDate(0, 0, -30))).SetCount(9).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	stats, err := suite.model.GetTokenDownloadStats(suite.tokenID, 7)
+	assert.NoError(suite.T(), err)
+	if assert.Equal(suite.T(), 7, len(stats), "should return a day for each of the last days") {
+		assert.Equal(suite.T(), 2, stats[6].Count, "should count the downloads of today once per day")
+		assert.Equal(suite.T(), 5, stats[3].Count)
+		assert.Equal(suite.T(), 0, stats[0].Count, "should fill the days without downloads")
+		assert.Equal(suite.T(), downloadStatDate(time.Now()), stats[6].Date)
+	}
+
+	n, err := suite.model.Client.TokenDownloadStat.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, n, "should keep a single row per day")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestSetEnrollmentTokenConfigHash() {
+	token, _, err := suite.model.GetEnrollmentTokenByValue("11111111-2222-3333-4444-555555555555")
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), token.ConfigUpdatedAt, "should not have a config date before the first download")
+
+	modified, err := suite.model.SetEnrollmentTokenConfigHash(token, "hash1")
+	assert.NoError(suite.T(), err, "should save the config hash")
+	assert.Equal(suite.T(), modified, modified.Truncate(time.Second), "should have the precision of the HTTP dates")
+
+	token, _, err = suite.model.GetEnrollmentTokenByValue("11111111-2222-3333-4444-555555555555")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "hash1", token.ConfigHash)
+
+	same, err := suite.model.SetEnrollmentTokenConfigHash(token, "hash1")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), modified.Equal(same), "should keep the date if the config hasn't changed")
+
+	token.ConfigUpdatedAt = &time.Time{}
+	changed, err := suite.model.SetEnrollmentTokenConfigHash(token, "hash2")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), changed.IsZero(), "should update the date when the config changes")
+
+	token, _, err = suite.model.GetEnrollmentTokenByValue("11111111-2222-3333-4444-555555555555")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "hash2", token.ConfigHash)
+}
+
+func (suite *EnrollmentTokenTestSuite) TestEnrollmentTokenSites() {
+	ctx := context.Background()
+	client := suite.model.Client
+
+	office, err := client.Site.Create().SetDescription("Office").SetTenantID(suite.tenantID).Save(ctx)
+	assert.NoError(suite.T(), err)
+	warehouse, err := client.Site.Create().SetDescription("Warehouse").SetTenantID(suite.tenantID).Save(ctx)
+	assert.NoError(suite.T(), err)
+	abroad, err := client.Site.Create().SetDescription("Abroad").SetTenantID(suite.secondTenantID).Save(ctx)
+	assert.NoError(suite.T(), err)
+
+	// The default site is allowed even if it wasn't selected
+	token, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{DefaultID: warehouse.ID, IDs: []int{office.ID, office.ID}}, "Branches", "44444444-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(suite.T(), err, "should create token with several sites")
+	token, err = suite.model.GetEnrollmentTokenByID(token.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), warehouse.ID, token.Edges.Site.ID, "should enroll in the default site")
+	assert.ElementsMatch(suite.T(), []int{office.ID, warehouse.ID}, siteIDs(token.Edges.AllowedSites))
+
+	// Without a default site the first allowed site is the default
+	err = suite.model.UpdateEnrollmentTokenSites(token.ID, suite.tenantID, EnrollmentTokenSites{IDs: []int{warehouse.ID}})
+	assert.NoError(suite.T(), err, "should update the sites")
+	token, err = suite.model.GetEnrollmentTokenByID(token.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), warehouse.ID, token.Edges.Site.ID)
+	assert.Equal(suite.T(), []int{warehouse.ID}, siteIDs(token.Edges.AllowedSites))
+
+	err = suite.model.UpdateEnrollmentTokenSites(token.ID, suite.tenantID, EnrollmentTokenSites{IDs: []int{office.ID, abroad.ID}})
+	assert.True(suite.T(), errors.Is(err, ErrNotFound), "should not allow sites of another tenant")
+	err = suite.model.UpdateEnrollmentTokenSites(token.ID, suite.secondTenantID, EnrollmentTokenSites{IDs: []int{abroad.ID}})
+	assert.True(suite.T(), errors.Is(err, ErrNotFound), "should not update tokens of another tenant")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestEnrollmentTokenAllowedOS() {
+	token, err := suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Servers", "55555555-2222-3333-4444-555555555555", 0, nil, []string{"windows", "beos", "linux", "linux"})
+	assert.NoError(suite.T(), err, "should create token restricted to some platforms")
+	assert.Equal(suite.T(), []string{"linux", "windows"}, token.AllowedOs, "should keep the known platforms once")
+	assert.True(suite.T(), EnrollmentTokenAllowsPlatform(token, "linux"))
+	assert.False(suite.T(), EnrollmentTokenAllowsPlatform(token, "macos"), "should not allow other platforms")
+
+	token, err = suite.model.CreateEnrollmentToken(suite.tenantID, EnrollmentTokenSites{}, "Any", "66666666-2222-3333-4444-555555555555", 0, nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), EnrollmentTokenAllowsPlatform(token, "macos"), "should allow any platform without restrictions")
+}
+
+func (suite *EnrollmentTokenTestSuite) TestMigrateEnrollmentTokenSites() {
+	ctx := context.Background()
+	client := suite.model.Client
+
+	office, err := client.Site.Create().SetDescription("Office").SetTenantID(suite.tenantID).Save(ctx)
+	assert.NoError(suite.T(), err)
+
+	// A token bound to a single site before tokens could have several sites
+	token, err := client.EnrollmentToken.Create().
+		SetToken("55555555-2222-3333-4444-555555555555").
+		SetDescription("Legacy").
+		SetTenantID(suite.tenantID).
+		SetSiteID(office.ID).
+		Save(ctx)
+	assert.NoError(suite.T(), err)
+
+	assert.NoError(suite.T(), suite.model.MigrateEnrollmentTokenSites(), "should migrate the sites")
+	assert.NoError(suite.T(), suite.model.MigrateEnrollmentTokenSites(), "should migrate the sites only once")
+
+	token, err = suite.model.GetEnrollmentTokenByID(token.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []int{office.ID}, siteIDs(token.Edges.AllowedSites), "should allow the site of the token")
+
+	token, err = suite.model.GetEnrollmentTokenByID(suite.tokenID)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), token.Edges.AllowedSites, "should leave the tokens without a site alone")
+}
+
+func siteIDs(sites []*openuem_ent.Site) []int {
+	ids := make([]int, 0, len(sites))
+	for _, s := range sites {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestEnrollmentTokenTestSuite(t *testing.T) {
+	suite.Run(t, new(EnrollmentTokenTestSuite))
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ent "github.com/open-uem/ent"
+)
+
+// Errors returned by the model so handlers can show a translated message instead of the database error
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrAlreadyExists   = errors.New("already exists")
+	ErrForeignKeyInUse = errors.New("still in use by other entities")
+
+	// ErrDefaultTenantRequired is returned when the default tenant would be unset without choosing a new one
+	ErrDefaultTenantRequired = errors.New("a default tenant is required")
+
+	// ErrTenantHasNoAdmin is returned when a tenant without admins would become the main tenant
+	ErrTenantHasNoAdmin = errors.New("the tenant has no admin users")
+
+	// ErrNoTenantsAssigned is returned when the user isn't assigned to any tenant yet
+	ErrNoTenantsAssigned = errors.New("user has no tenant assignments")
+)
+
+// ValidationError is returned when a field has a value that can't be saved
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %v", e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// dbError wraps the errors returned by ent in the model errors. The ent error is
+// kept in the chain so ent.IsNotFound and similar functions still work
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *ent.ValidationError
+	switch {
+	case ent.IsNotFound(err):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case ent.IsConstraintError(err):
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: %w", ErrForeignKeyInUse, err)
+		}
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	case errors.As(err, &validationErr):
+		return &ValidationError{Field: validationErr.Name, Err: err}
+	}
+	return err
+}
+
+// isForeignKeyViolation tells foreign key violations from unique violations,
+// ent reports both as constraint errors
+func isForeignKeyViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "foreign key") || strings.Contains(msg, "sqlstate 23503")
+}
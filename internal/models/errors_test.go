@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBError(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:errors?mode=memory&_fk=1")
+	defer client.Close()
+	m := Model{Client: client}
+
+	assert.NoError(t, dbError(nil))
+
+	_, err := m.GetTenantByID(9999)
+	assert.ErrorIs(t, err, ErrNotFound, "should return not found errors")
+	assert.True(t, openuem_ent.IsNotFound(err), "should keep the ent error")
+
+	tenant, err := client.Tenant.Create().SetDescription("Tenant").SetIsDefault(true).Save(context.Background())
+	assert.NoError(t, err)
+
+	err = client.User.Create().SetID("user1").SetName("User 1").SetEmail("user1@example.com").SetCreated(time.Now()).Exec(context.Background())
+	assert.NoError(t, err)
+	err = client.User.Create().SetID("user1").SetName("User 1").SetEmail("user1@example.com").SetCreated(time.Now()).Exec(context.Background())
+	assert.ErrorIs(t, dbError(err), ErrAlreadyExists, "unique violations should be already exists errors")
+
+	err = m.AssignUserToTenant("user1", tenant.ID, UserTenantRoleAdmin, true)
+	assert.NoError(t, err)
+	err = m.AssignUserToTenant("user1", tenant.ID, UserTenantRoleAdmin, true)
+	assert.ErrorIs(t, err, ErrAlreadyExists, "should not assign a user twice to a tenant")
+
+	err = m.UpdateTenant(tenant.ID, "Tenant", false)
+	assert.ErrorIs(t, err, ErrDefaultTenantRequired, "should keep a default tenant")
+
+	err = dbError(&ValidationError{Field: "description", Err: errors.New("empty")})
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "description", validationErr.Field)
+}
+
+func TestIsForeignKeyViolation(t *testing.T) {
+	assert.True(t, isForeignKeyViolation(errors.New("FOREIGN KEY constraint failed")))
+	assert.True(t, isForeignKeyViolation(errors.New(`update or delete on table "tenants" violates foreign key constraint (SQLSTATE 23503)`)))
+	assert.False(t, isForeignKeyViolation(errors.New(`duplicate key value violates unique constraint (SQLSTATE 23505)`)))
+}
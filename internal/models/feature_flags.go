@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/featureflag"
+)
+
+// Experimental features that can be enabled for each tenant. They're disabled until a hoster admin
+// enables them in the settings of the tenant
+const (
+	FeatureSAML           = "saml"
+	FeatureOIDC           = "oidc"
+	FeatureTenantBranding = "tenant_branding"
+)
+
+// Features lists the experimental features in the order they're shown in the settings of a tenant
+var Features = []string{FeatureSAML, FeatureOIDC, FeatureTenantBranding}
+
+// IsFeatureEnabled checks if the feature has been enabled for the tenant
+func (m *Model) IsFeatureEnabled(tenantID int, feature string) (bool, error) {
+	flag, err := m.Client.FeatureFlag.Query().
+		Where(featureflag.TenantID(tenantID), featureflag.FeatureName(feature)).
+		Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return flag.Enabled, nil
+}
+
+// GetFeatureFlags returns whether each of the experimental features is enabled for the tenant
+func (m *Model) GetFeatureFlags(tenantID int) (map[string]bool, error) {
+	flags, err := m.Client.FeatureFlag.Query().Where(featureflag.TenantID(tenantID)).All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := map[string]bool{}
+	for _, f := range Features {
+		enabled[f] = false
+	}
+	for _, f := range flags {
+		enabled[f.FeatureName] = f.Enabled
+	}
+	return enabled, nil
+}
+
+// SetFeatureEnabled enables or disables the feature for the tenant
+func (m *Model) SetFeatureEnabled(tenantID int, feature string, enabled bool) error {
+	n, err := m.Client.FeatureFlag.Update().
+		Where(featureflag.TenantID(tenantID), featureflag.FeatureName(feature)).
+		SetEnabled(enabled).
+		Save(context.Background())
+	if err != nil || n > 0 {
+		return err
+	}
+
+	return m.Client.FeatureFlag.Create().
+		SetTenantID(tenantID).
+		SetFeatureName(feature).
+		SetEnabled(enabled).
+		Exec(context.Background())
+}
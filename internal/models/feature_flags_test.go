@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	defer client.Close()
+	m := Model{Client: client}
+
+	tenant, err := client.Tenant.Create().SetDescription("Tenant").Save(context.Background())
+	assert.NoError(t, err)
+	other, err := client.Tenant.Create().SetDescription("Other").Save(context.Background())
+	assert.NoError(t, err)
+
+	enabled, err := m.IsFeatureEnabled(tenant.ID, FeatureSAML)
+	assert.NoError(t, err)
+	assert.False(t, enabled, "features should be disabled by default")
+
+	assert.NoError(t, m.SetFeatureEnabled(tenant.ID, FeatureSAML, true))
+	enabled, err = m.IsFeatureEnabled(tenant.ID, FeatureSAML)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	enabled, err = m.IsFeatureEnabled(other.ID, FeatureSAML)
+	assert.NoError(t, err)
+	assert.False(t, enabled, "features should only be enabled for the tenant")
+
+	assert.NoError(t, m.SetFeatureEnabled(tenant.ID, FeatureSAML, false))
+	enabled, err = m.IsFeatureEnabled(tenant.ID, FeatureSAML)
+	assert.NoError(t, err)
+	assert.False(t, enabled, "should disable the feature again")
+
+	assert.NoError(t, m.SetFeatureEnabled(tenant.ID, FeatureOIDC, true))
+	flags, err := m.GetFeatureFlags(tenant.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{FeatureSAML: false, FeatureOIDC: true, FeatureTenantBranding: false}, flags)
+}
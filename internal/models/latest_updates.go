@@ -71,3 +71,22 @@ func (m *Model) GetLatestUpdates(agentId string, p partials.PaginationAndSort, c
 
 	return updates, nil
 }
+
+// GetAllLatestUpdates returns all the updates installed on an agent, newest first
+func (m *Model) GetAllLatestUpdates(agentId string, c *partials.CommonInfo) ([]*ent.Update, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Update.Query().Where(update.HasOwnerWith(agent.ID(agentId), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	if siteID != -1 {
+		query = m.Client.Update.Query().Where(update.HasOwnerWith(agent.ID(agentId), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+
+	return query.Order(ent.Desc(update.FieldDate)).All(context.Background())
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/loginattempt"
+)
+
+const (
+	// LoginLockoutFailures is the number of failed logins in the window that locks the account
+	LoginLockoutFailures = 5
+	// LoginLockoutWindow is the period in which the failed logins are counted
+	LoginLockoutWindow = 10 * time.Minute
+	// LoginLockoutDuration is how long the account is locked
+	LoginLockoutDuration = 15 * time.Minute
+	// LoginAttemptsRetention is how long the login attempts are kept, the authentication events keep the history
+	LoginAttemptsRetention = 24 * time.Hour
+)
+
+// RecordLoginAttempt saves a password login of an account, userID is the username entered if the account
+// doesn't exist. The account is locked when the failures since the last successful login reach the limit
+func (m *Model) RecordLoginAttempt(userID string, success bool, ip string) error {
+	now := time.Now()
+
+	attempt, err := m.Client.LoginAttempt.Create().
+		SetUserID(userID).
+		SetSuccess(success).
+		SetIPAddress(ip).
+		SetCreated(now).
+		Save(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if success {
+		return nil
+	}
+
+	// The failures before the last successful login don't count
+	since := now.Add(-LoginLockoutWindow)
+	last, err := m.Client.LoginAttempt.Query().
+		Where(loginattempt.UserID(userID), loginattempt.Success(true), loginattempt.CreatedGT(since)).
+		Order(ent.Desc(loginattempt.FieldCreated)).
+		First(context.Background())
+	if err != nil && !ent.IsNotFound(err) {
+		return err
+	}
+	if last != nil {
+		since = last.Created
+	}
+
+	failures, err := m.Client.LoginAttempt.Query().
+		Where(loginattempt.UserID(userID), loginattempt.Success(false), loginattempt.CreatedGT(since)).
+		Count(context.Background())
+	if err != nil {
+		return err
+	}
+	if failures < LoginLockoutFailures {
+		return nil
+	}
+
+	return m.Client.LoginAttempt.UpdateOne(attempt).
+		SetLockedUntil(now.Add(LoginLockoutDuration)).
+		Exec(context.Background())
+}
+
+// IsUserLockedOut returns if the account is locked because of the failed logins and until when
+func (m *Model) IsUserLockedOut(userID string) (bool, time.Time, error) {
+	attempt, err := m.Client.LoginAttempt.Query().
+		Where(loginattempt.UserID(userID), loginattempt.LockedUntilGT(time.Now())).
+		Order(ent.Desc(loginattempt.FieldLockedUntil)).
+		First(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return false, time.Time{}, nil
+		}
+		return false, time.Time{}, err
+	}
+	return true, attempt.LockedUntil, nil
+}
+
+// DeleteOldLoginAttempts removes the login attempts older than the retention period
+func (m *Model) DeleteOldLoginAttempts() error {
+	_, err := m.Client.LoginAttempt.Delete().Where(loginattempt.CreatedLT(time.Now().Add(-LoginAttemptsRetention))).Exec(context.Background())
+	return err
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLockout(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:loginattempts?mode=memory&_fk=1")
+	defer client.Close()
+	m := Model{Client: client}
+
+	// Failures out of the window don't count
+	assert.NoError(t, client.LoginAttempt.Create().SetUserID("admin").SetSuccess(false).SetIPAddress("198.51.100.7").SetCreated(time.Now().Add(-LoginLockoutWindow-time.Minute)).Exec(context.Background()))
+
+	for range LoginLockoutFailures - 1 {
+		assert.NoError(t, m.RecordLoginAttempt("admin", false, "198.51.100.7"))
+	}
+	locked, _, err := m.IsUserLockedOut("admin")
+	assert.NoError(t, err)
+	assert.False(t, locked, "should not lock the account below the limit")
+
+	assert.NoError(t, m.RecordLoginAttempt("admin", true, "192.0.2.10"))
+	assert.NoError(t, m.RecordLoginAttempt("admin", false, "198.51.100.7"))
+	locked, _, err = m.IsUserLockedOut("admin")
+	assert.NoError(t, err)
+	assert.False(t, locked, "should not count the failures before a successful login")
+
+	for range LoginLockoutFailures - 1 {
+		assert.NoError(t, m.RecordLoginAttempt("admin", false, "198.51.100.7"))
+	}
+	locked, until, err := m.IsUserLockedOut("admin")
+	assert.NoError(t, err)
+	assert.True(t, locked, "should lock the account")
+	assert.WithinDuration(t, time.Now().Add(LoginLockoutDuration), until, time.Minute)
+
+	locked, _, err = m.IsUserLockedOut("other")
+	assert.NoError(t, err)
+	assert.False(t, locked, "should only lock the account with the failures")
+}
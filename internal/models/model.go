@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"entgo.io/ent/dialect"
 	entsql "entgo.io/ent/dialect/sql"
@@ -18,17 +19,41 @@ import (
 	"github.com/open-uem/ent/site"
 	"github.com/open-uem/ent/tag"
 	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 )
 
 type Model struct {
 	Client *ent.Client
+	Cache  *Cache
+
+	// DeletedAgentsRetention is how long deleted agents can be restored before they're purged
+	DeletedAgentsRetention time.Duration
+
+	// AgentPlaceholderRetention is how long the agent UUIDs pre-generated for a configuration wait for the first
+	// report of their agents before they're removed
+	AgentPlaceholderRetention time.Duration
+
+	// Backups is where and how the database backups are made
+	Backups BackupConfig
+
+	// DefaultBranding is the branding created when there is none yet
+	DefaultBranding BrandingDefaults
+
+	db      *sql.DB
+	dbURL   string
+	roles   RoleCache
+	secrets *auth.SecretBox
 }
 
-func New(dbUrl string, driverName, domain string) (*Model, error) {
+func New(dbUrl string, driverName, domain string, cfg DBConfig, opts ...Option) (*Model, error) {
 	var db *sql.DB
 	var err error
 
 	model := Model{}
+	for _, opt := range opts {
+		opt(&model)
+	}
 
 	switch driverName {
 	case "pgx":
@@ -36,7 +61,19 @@ func New(dbUrl string, driverName, domain string) (*Model, error) {
 		if err != nil {
 			return nil, fmt.Errorf("could not connect with Postgres database: %v", err)
 		}
-		model.Client = ent.NewClient(ent.Driver(entsql.OpenDB(dialect.Postgres, db)))
+		cfg.apply(db)
+		model.db = db
+		model.dbURL = dbUrl
+
+		var driver dialect.Driver = entsql.OpenDB(dialect.Postgres, db)
+		if cfg.SlowQueryThreshold > 0 {
+			driver = &slowQueryDriver{Driver: driver, threshold: cfg.SlowQueryThreshold}
+		}
+		if telemetry.Enabled() {
+			driver = &tracingDriver{Driver: driver}
+		}
+		model.Client = ent.NewClient(ent.Driver(driver))
+		model.Client.Agent.Intercept(excludeDeletedAgents())
 	default:
 		return nil, fmt.Errorf("unsupported DB driver")
 	}
@@ -58,36 +95,40 @@ func (m *Model) Close() error {
 	return m.Client.Close()
 }
 
-func (m *Model) CreateDefaultTenantAndSite() error {
+// CreateDefaultTenantAndSite creates the default tenant with its default site if there are no tenants yet.
+// It returns the hoster tenant, the main tenant, whether it has just been created or it already existed
+func (m *Model) CreateDefaultTenantAndSite() (*ent.Tenant, error) {
 	nTenants, err := m.CountTenants()
 	if err != nil {
-		return fmt.Errorf("could not count existing tenants")
+		return nil, fmt.Errorf("could not count existing tenants")
 	}
 
-	if nTenants == 0 {
-		t, err := m.CreateDefaultTenant()
-		if err != nil {
-			return fmt.Errorf("could not create default tenant")
-		}
-		nSites, err := m.CountSites(t.ID)
+	if nTenants > 0 {
+		return m.GetMainTenant()
+	}
+
+	t, err := m.CreateDefaultTenant()
+	if err != nil {
+		return nil, fmt.Errorf("could not create default tenant")
+	}
+	nSites, err := m.CountSites(t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count existing sites")
+	}
+
+	if nSites == 0 {
+		_, err := m.CreateDefaultSite(t)
 		if err != nil {
-			return fmt.Errorf("could not count existing sites")
+			return nil, fmt.Errorf("could not create default site")
 		}
 
-		if nSites == 0 {
-			_, err := m.CreateDefaultSite(t)
-			if err != nil {
-				return fmt.Errorf("could not create default site")
-			}
-
-			// Create copy of global settings
-			if err := m.CloneGlobalSettings(t.ID); err != nil {
-				return fmt.Errorf("could not clone global settings, reason: %v", err)
-			}
+		// Create copy of global settings
+		if err := m.CloneGlobalSettings(t.ID); err != nil {
+			return nil, fmt.Errorf("could not clone global settings, reason: %v", err)
 		}
 	}
 
-	return nil
+	return t, nil
 }
 
 func (m *Model) AssociateAgentsToDefaultTenantAndSite() error {
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/user"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/auth"
+)
+
+// PasswordPolicyFromSettings returns the password policy of the authentication settings
+func PasswordPolicyFromSettings(s *openuem_ent.Authentication) auth.PasswordPolicy {
+	return auth.PasswordPolicy{
+		MinLength:        s.PasswordMinLength,
+		RequireUppercase: s.PasswordRequireUppercase,
+		RequireLowercase: s.PasswordRequireLowercase,
+		RequireDigit:     s.PasswordRequireDigit,
+		RequireSymbol:    s.PasswordRequireSymbol,
+		DisallowCommon:   s.PasswordDisallowCommon,
+		History:          s.PasswordHistory,
+		MaxAgeDays:       s.PasswordMaxAge,
+	}
+}
+
+func (m *Model) GetPasswordPolicy() (auth.PasswordPolicy, error) {
+	s, err := m.GetAuthenticationSettings()
+	if err != nil {
+		return auth.PasswordPolicy{}, err
+	}
+	return PasswordPolicyFromSettings(s), nil
+}
+
+func (m *Model) SavePasswordPolicy(p auth.PasswordPolicy) error {
+	s, err := m.GetAuthenticationSettings()
+	if err != nil {
+		return err
+	}
+
+	return m.Client.Authentication.UpdateOneID(s.ID).
+		SetPasswordMinLength(p.MinLength).
+		SetPasswordRequireUppercase(p.RequireUppercase).
+		SetPasswordRequireLowercase(p.RequireLowercase).
+		SetPasswordRequireDigit(p.RequireDigit).
+		SetPasswordRequireSymbol(p.RequireSymbol).
+		SetPasswordDisallowCommon(p.DisallowCommon).
+		SetPasswordHistory(p.History).
+		SetPasswordMaxAge(p.MaxAgeDays).
+		Exec(context.Background())
+}
+
+// CheckPasswordPolicy returns an *auth.PasswordPolicyError if the new password of the user doesn't comply
+// with the password policy, including the reuse of the last passwords
+func (m *Model) CheckPasswordPolicy(username, password string) error {
+	p, err := m.GetPasswordPolicy()
+	if err != nil {
+		return err
+	}
+
+	if err := p.Validate(password); err != nil {
+		return err
+	}
+
+	u, err := m.Client.User.Query().Select(user.FieldHash, user.FieldPasswordHistory).Where(user.ID(username)).Only(context.Background())
+	if err != nil {
+		if openuem_ent.IsNotFound(err) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	return p.CheckHistory(password, u.Hash, u.PasswordHistory)
+}
+
+// ForcePasswordChange makes the user change the password the next time it logs in
+func (m *Model) ForcePasswordChange(username string) error {
+	return m.Client.User.UpdateOneID(username).SetRegister(openuem_nats.REGISTER_FORCE_PASSWORD_CHANGE).Exec(context.Background())
+}
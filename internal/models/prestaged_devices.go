@@ -0,0 +1,415 @@
+package models
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/computer"
+	"github.com/open-uem/ent/networkadapter"
+	"github.com/open-uem/ent/prestageddevice"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// Statuses of the pre-staged devices, a device is pending until an agent matching it is admitted
+const (
+	PreStagedDevicePending  = "pending"
+	PreStagedDeviceEnrolled = "enrolled"
+)
+
+// preStagedDeviceColumns are the columns of the CSV file, the tags are separated by semicolons
+var preStagedDeviceColumns = []string{"serial", "mac", "asset_tag", "nickname", "site", "tags"}
+
+// PreStagedDeviceRecord is a row of the CSV file with the inventory expected to enroll
+type PreStagedDeviceRecord struct {
+	Line     int
+	Serial   string
+	MAC      string
+	AssetTag string
+	Nickname string
+	Site     string
+	Tags     []string
+}
+
+// PreStagedImportRecordError is an offending row of the CSV file
+type PreStagedImportRecordError struct {
+	Line   int
+	Record string
+	Reason string
+}
+
+// PreStagedImportError is returned when the CSV file can't be imported. Nothing has been saved
+// as a single offending row rejects the whole file
+type PreStagedImportError struct {
+	Records []PreStagedImportRecordError
+}
+
+func (e *PreStagedImportError) Error() string {
+	return fmt.Sprintf("the devices could not be imported, %d rows have errors", len(e.Records))
+}
+
+// ParsePreStagedDevices reads the rows of the CSV file, the first row is skipped if it's the header.
+// Rows that can't be read are returned as record errors so that all of them are reported at once
+func ParsePreStagedDevices(r io.Reader) ([]PreStagedDeviceRecord, []PreStagedImportRecordError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records := []PreStagedDeviceRecord{}
+	recordErrors := []PreStagedImportRecordError{}
+
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(row[0]), preStagedDeviceColumns[0]) {
+			continue
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+
+		if len(row) != len(preStagedDeviceColumns) {
+			recordErrors = append(recordErrors, PreStagedImportRecordError{Line: line, Record: strings.Join(row, ","), Reason: fmt.Sprintf("expected %d columns: %s", len(preStagedDeviceColumns), strings.Join(preStagedDeviceColumns, ","))})
+			continue
+		}
+
+		record := PreStagedDeviceRecord{
+			Line:     line,
+			Serial:   strings.TrimSpace(row[0]),
+			MAC:      strings.TrimSpace(row[1]),
+			AssetTag: strings.TrimSpace(row[2]),
+			Nickname: strings.TrimSpace(row[3]),
+			Site:     strings.TrimSpace(row[4]),
+		}
+		for t := range strings.SplitSeq(row[5], ";") {
+			if t = strings.TrimSpace(t); t != "" {
+				record.Tags = append(record.Tags, t)
+			}
+		}
+
+		if record.MAC != "" {
+			mac, err := NormalizeMAC(record.MAC)
+			if err != nil {
+				recordErrors = append(recordErrors, PreStagedImportRecordError{Line: line, Record: record.key(), Reason: fmt.Sprintf("%s is not a valid MAC address", record.MAC)})
+				continue
+			}
+			record.MAC = mac
+		}
+
+		if record.Serial == "" && record.MAC == "" {
+			recordErrors = append(recordErrors, PreStagedImportRecordError{Line: line, Record: strings.Join(row, ","), Reason: "a serial number or a MAC address is required to match the device"})
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, recordErrors, nil
+}
+
+// key identifies the record in the errors
+func (r PreStagedDeviceRecord) key() string {
+	if r.Serial != "" {
+		return r.Serial
+	}
+	return r.MAC
+}
+
+// NormalizeMAC returns the MAC address in lowercase with colons, the format the devices are matched with
+func NormalizeMAC(mac string) (string, error) {
+	hw, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil {
+		return "", err
+	}
+	return hw.String(), nil
+}
+
+// ImportPreStagedDevices saves the devices expected to enroll in the tenant. The serials and MACs can't be
+// repeated in the file nor in the pending devices, as an agent must match a single device, and the sites
+// and tags must exist. If any row has errors, nothing is saved and all of them are returned
+func (m *Model) ImportPreStagedDevices(tenantID int, records []PreStagedDeviceRecord, recordErrors []PreStagedImportRecordError) (int, error) {
+	ctx := context.Background()
+
+	sites, err := m.Client.Site.Query().Where(site.HasTenantWith(tenant.ID(tenantID))).All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	siteIDs := map[string]int{}
+	for _, s := range sites {
+		siteIDs[strings.ToLower(s.Description)] = s.ID
+	}
+
+	tags, err := m.Client.Tag.Query().Where(tag.HasTenantWith(tenant.ID(tenantID))).All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tagIDs := map[string]int{}
+	for _, t := range tags {
+		tagIDs[strings.ToLower(t.Tag)] = t.ID
+	}
+
+	pending, err := m.Client.PreStagedDevice.Query().
+		Where(prestageddevice.HasTenantWith(tenant.ID(tenantID)), prestageddevice.EnrolledAtIsNil()).
+		All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	serials := map[string]string{}
+	macs := map[string]string{}
+	for _, d := range pending {
+		if d.Serial != "" {
+			serials[strings.ToLower(d.Serial)] = "a pending device"
+		}
+		if d.Mac != "" {
+			macs[d.Mac] = "a pending device"
+		}
+	}
+
+	type validRecord struct {
+		record PreStagedDeviceRecord
+		siteID int
+		tagIDs []int
+	}
+	valid := []validRecord{}
+
+	for _, r := range records {
+		reasons := []string{}
+
+		if r.Serial != "" {
+			if other, ok := serials[strings.ToLower(r.Serial)]; ok {
+				reasons = append(reasons, fmt.Sprintf("serial %s is already used by %s", r.Serial, other))
+			} else {
+				serials[strings.ToLower(r.Serial)] = fmt.Sprintf("line %d", r.Line)
+			}
+		}
+
+		if r.MAC != "" {
+			if other, ok := macs[r.MAC]; ok {
+				reasons = append(reasons, fmt.Sprintf("MAC address %s is already used by %s", r.MAC, other))
+			} else {
+				macs[r.MAC] = fmt.Sprintf("line %d", r.Line)
+			}
+		}
+
+		v := validRecord{record: r}
+		if r.Site != "" {
+			id, ok := siteIDs[strings.ToLower(r.Site)]
+			if !ok {
+				reasons = append(reasons, fmt.Sprintf("site %s doesn't exist", r.Site))
+			}
+			v.siteID = id
+		}
+		for _, t := range r.Tags {
+			id, ok := tagIDs[strings.ToLower(t)]
+			if !ok {
+				reasons = append(reasons, fmt.Sprintf("tag %s doesn't exist", t))
+				continue
+			}
+			v.tagIDs = append(v.tagIDs, id)
+		}
+
+		if len(reasons) > 0 {
+			recordErrors = append(recordErrors, PreStagedImportRecordError{Line: r.Line, Record: r.key(), Reason: strings.Join(reasons, ", ")})
+			continue
+		}
+		valid = append(valid, v)
+	}
+
+	if len(recordErrors) > 0 {
+		slices.SortStableFunc(recordErrors, func(a, b PreStagedImportRecordError) int { return a.Line - b.Line })
+		return 0, &PreStagedImportError{Records: recordErrors}
+	}
+
+	builders := []*ent.PreStagedDeviceCreate{}
+	for _, v := range valid {
+		builder := m.Client.PreStagedDevice.Create().
+			SetTenantID(tenantID).
+			SetSerial(v.record.Serial).
+			SetMac(v.record.MAC).
+			SetAssetTag(v.record.AssetTag).
+			SetNickname(v.record.Nickname).
+			AddTagIDs(v.tagIDs...).
+			SetCreatedAt(time.Now())
+		if v.siteID != 0 {
+			builder.SetSiteID(v.siteID)
+		}
+		builders = append(builders, builder)
+	}
+
+	if err := m.Client.PreStagedDevice.CreateBulk(builders...).Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(builders), nil
+}
+
+// MatchPreStagedDevice returns the pending device of the tenant expected for the agent, matched by the
+// serial number of its computer first and then by the MAC addresses of its network adapters, or nil if
+// the agent wasn't pre-staged
+func (m *Model) MatchPreStagedDevice(agentID string, tenantID int) (*ent.PreStagedDevice, error) {
+	ctx := context.Background()
+
+	query := func() *ent.PreStagedDeviceQuery {
+		return m.Client.PreStagedDevice.Query().
+			WithSite().
+			WithTags().
+			Where(prestageddevice.HasTenantWith(tenant.ID(tenantID)), prestageddevice.EnrolledAtIsNil()).
+			Order(ent.Asc(prestageddevice.FieldID))
+	}
+
+	c, err := m.Client.Computer.Query().Where(computer.HasOwnerWith(agent.ID(agentID))).Only(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+	if c != nil && strings.TrimSpace(c.Serial) != "" {
+		d, err := query().Where(prestageddevice.SerialEqualFold(strings.TrimSpace(c.Serial))).First(ctx)
+		if err == nil {
+			return d, nil
+		}
+		if !ent.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	adapters, err := m.Client.NetworkAdapter.Query().Where(networkadapter.HasOwnerWith(agent.ID(agentID))).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	macs := []string{}
+	for _, a := range adapters {
+		if mac, err := NormalizeMAC(a.MACAddress); err == nil {
+			macs = append(macs, mac)
+		}
+	}
+	if len(macs) == 0 {
+		return nil, nil
+	}
+
+	d, err := query().Where(prestageddevice.MacIn(macs...)).First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// ApplyPreStagedDevice sets the nickname and the tags of the device to the admitted agent and marks the
+// device as enrolled, its site is applied when the agent is moved at admission
+func (m *Model) ApplyPreStagedDevice(agentID string, d *ent.PreStagedDevice) error {
+	ctx := context.Background()
+
+	update := m.Client.Agent.UpdateOneID(agentID)
+	if d.Nickname != "" {
+		update.SetNickname(d.Nickname)
+	}
+	for _, t := range d.Edges.Tags {
+		update.AddTagIDs(t.ID)
+	}
+	if err := update.Exec(ctx); err != nil {
+		return err
+	}
+
+	return m.Client.PreStagedDevice.UpdateOneID(d.ID).
+		SetEnrolledAgentID(agentID).
+		SetEnrolledAt(time.Now()).
+		Exec(ctx)
+}
+
+// GetPreStagedDevicesByPage returns the pre-staged devices of the tenant, the last imported first
+func (m *Model) GetPreStagedDevicesByPage(tenantID int, p partials.PaginationAndSort, f filters.PreStagedDeviceFilter) ([]*ent.PreStagedDevice, error) {
+	query := m.Client.PreStagedDevice.Query().WithSite().WithTags().Where(prestageddevice.HasTenantWith(tenant.ID(tenantID)))
+
+	applyPreStagedDevicesFilter(query, f)
+
+	switch p.SortBy {
+	case "serial":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(prestageddevice.FieldSerial))
+		} else {
+			query.Order(ent.Desc(prestageddevice.FieldSerial))
+		}
+	case "nickname":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(prestageddevice.FieldNickname))
+		} else {
+			query.Order(ent.Desc(prestageddevice.FieldNickname))
+		}
+	case "created":
+		if p.SortOrder == "asc" {
+			query.Order(ent.Asc(prestageddevice.FieldCreatedAt))
+		} else {
+			query.Order(ent.Desc(prestageddevice.FieldCreatedAt))
+		}
+	default:
+		query.Order(ent.Desc(prestageddevice.FieldCreatedAt), ent.Desc(prestageddevice.FieldID))
+	}
+
+	return query.
+		Limit(p.PageSize).
+		Offset((p.CurrentPage - 1) * p.PageSize).
+		All(context.Background())
+}
+
+// CountPreStagedDevices counts the pre-staged devices of the tenant
+func (m *Model) CountPreStagedDevices(tenantID int, f filters.PreStagedDeviceFilter) (int, error) {
+	query := m.Client.PreStagedDevice.Query().Where(prestageddevice.HasTenantWith(tenant.ID(tenantID)))
+
+	applyPreStagedDevicesFilter(query, f)
+
+	return query.Count(context.Background())
+}
+
+func applyPreStagedDevicesFilter(query *ent.PreStagedDeviceQuery, f filters.PreStagedDeviceFilter) {
+	if f.Serial != "" {
+		query.Where(prestageddevice.Or(prestageddevice.SerialContainsFold(f.Serial), prestageddevice.AssetTagContainsFold(f.Serial)))
+	}
+
+	if len(f.StatusOptions) == 1 {
+		switch f.StatusOptions[0] {
+		case PreStagedDevicePending:
+			query.Where(prestageddevice.EnrolledAtIsNil())
+		case PreStagedDeviceEnrolled:
+			query.Where(prestageddevice.EnrolledAtNotNil())
+		}
+	}
+}
+
+// DeletePreStagedDevice removes a pre-staged device of the tenant
+func (m *Model) DeletePreStagedDevice(id, tenantID int) error {
+	n, err := m.Client.PreStagedDevice.Delete().
+		Where(prestageddevice.ID(id), prestageddevice.HasTenantWith(tenant.ID(tenantID))).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PreStagedDeviceStatus returns whether the device has enrolled or is still pending
+func PreStagedDeviceStatus(d *ent.PreStagedDevice) string {
+	if d.EnrolledAt != nil {
+		return PreStagedDeviceEnrolled
+	}
+	return PreStagedDevicePending
+}
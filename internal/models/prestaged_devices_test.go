@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PreStagedDevicesTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+}
+
+func (suite *PreStagedDevicesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+	ctx := context.Background()
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	_, err = client.Site.Create().SetDescription("Warehouse").SetTenantID(t.ID).Save(ctx)
+	assert.NoError(suite.T(), err, "should create site")
+
+	_, err = client.Tag.Create().SetTag("Laptops").SetColor("red").SetTenantID(t.ID).Save(ctx)
+	assert.NoError(suite.T(), err, "should create tag")
+
+	// agent0 has a serial number and agent1 only has a network adapter
+	for i := range 2 {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusWaitingForAdmission).
+			AddSiteIDs(s.ID).
+			Exec(ctx)
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	err = client.Computer.Create().
+		SetManufacturer("manufacturer").
+		SetModel("model").
+		SetSerial("SN-0001").
+		SetOwnerID("agent0").
+		Exec(ctx)
+	assert.NoError(suite.T(), err, "should create computer")
+
+	err = client.NetworkAdapter.Create().
+		SetName("eth0").
+		SetMACAddress("AA-BB-CC-DD-EE-01").
+		SetAddresses("192.168.1.10").
+		SetSpeed("1Gbps").
+		SetOwnerID("agent1").
+		Exec(ctx)
+	assert.NoError(suite.T(), err, "should create network adapter")
+}
+
+func (suite *PreStagedDevicesTestSuite) importCSV(data string) (int, error) {
+	records, recordErrors, err := ParsePreStagedDevices(strings.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return suite.model.ImportPreStagedDevices(suite.tenantID, records, recordErrors)
+}
+
+func (suite *PreStagedDevicesTestSuite) TestImportDuplicatedSerials() {
+	_, err := suite.importCSV("serial,mac,asset_tag,nickname,site,tags\n" +
+		"SN-0001,,A1,Reception,Warehouse,Laptops\n" +
+		"sn-0001,,A2,Office,,\n" +
+		"SN-0002,not-a-mac,A3,,,\n" +
+		"SN-0003,,A4,,Unknown,Servers\n" +
+		",,A5,,,\n")
+
+	var importErr *PreStagedImportError
+	if assert.True(suite.T(), errors.As(err, &importErr), "should reject the file") {
+		lines := []int{}
+		for _, r := range importErr.Records {
+			lines = append(lines, r.Line)
+		}
+		assert.Equal(suite.T(), []int{3, 4, 5, 6}, lines, "should report every offending row")
+		assert.Contains(suite.T(), importErr.Records[0].Reason, "line 2", "should point to the first row with the serial")
+		assert.Contains(suite.T(), importErr.Records[2].Reason, "site Unknown")
+		assert.Contains(suite.T(), importErr.Records[2].Reason, "tag Servers")
+	}
+
+	count, err := suite.model.CountPreStagedDevices(suite.tenantID, filters.PreStagedDeviceFilter{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count, "should not save any row")
+
+	n, err := suite.importCSV("SN-0001,,A1,Reception,Warehouse,Laptops\n,aa:bb:cc:dd:ee:01,A2,Office,,\n")
+	assert.NoError(suite.T(), err, "should import the devices")
+	assert.Equal(suite.T(), 2, n)
+
+	_, err = suite.importCSV("SN-0001,,A3,,,\n")
+	assert.True(suite.T(), errors.As(err, &importErr), "should reject the serial of a pending device")
+}
+
+func (suite *PreStagedDevicesTestSuite) TestMatchAndApply() {
+	_, err := suite.importCSV("SN-0001,,A1,Reception,Warehouse,Laptops\n,aa:bb:cc:dd:ee:01,A2,Office,,\n")
+	assert.NoError(suite.T(), err, "should import the devices")
+
+	d, err := suite.model.MatchPreStagedDevice("agent0", suite.tenantID)
+	assert.NoError(suite.T(), err, "should match by serial")
+	if assert.NotNil(suite.T(), d) {
+		assert.Equal(suite.T(), "Warehouse", d.Edges.Site.Description)
+		assert.NoError(suite.T(), suite.model.ApplyPreStagedDevice("agent0", d), "should apply the device")
+	}
+
+	a, err := suite.model.Client.Agent.Query().WithTags().Where(agent.ID("agent0")).Only(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Reception", a.Nickname)
+	if assert.Len(suite.T(), a.Edges.Tags, 1) {
+		assert.Equal(suite.T(), "Laptops", a.Edges.Tags[0].Tag)
+	}
+
+	d, err = suite.model.MatchPreStagedDevice("agent0", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), d, "should not match an enrolled device again")
+
+	d, err = suite.model.MatchPreStagedDevice("agent1", suite.tenantID)
+	assert.NoError(suite.T(), err, "should match by MAC")
+	if assert.NotNil(suite.T(), d) {
+		assert.Equal(suite.T(), "Office", d.Nickname)
+	}
+
+	pending, err := suite.model.CountPreStagedDevices(suite.tenantID, filters.PreStagedDeviceFilter{StatusOptions: []string{PreStagedDevicePending}})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, pending, "should report the device that never enrolled")
+
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+	devices, err := suite.model.GetPreStagedDevicesByPage(suite.tenantID, p, filters.PreStagedDeviceFilter{StatusOptions: []string{PreStagedDeviceEnrolled}})
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), devices, 1) {
+		assert.Equal(suite.T(), "agent0", devices[0].EnrolledAgentID)
+	}
+}
+
+func (suite *PreStagedDevicesTestSuite) TestUnmatchedAgent() {
+	_, err := suite.importCSV("SN-0002,,A1,Reception,Warehouse,Laptops\n")
+	assert.NoError(suite.T(), err, "should import the devices")
+
+	d, err := suite.model.MatchPreStagedDevice("agent0", suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), d, "should not match another serial")
+
+	tags, err := suite.model.Client.Tag.Query().Where(tag.HasOwnerWith(agent.ID("agent0"))).Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, tags)
+}
+
+func TestPreStagedDevicesTestSuite(t *testing.T) {
+	suite.Run(t, new(PreStagedDevicesTestSuite))
+}
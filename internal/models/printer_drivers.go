@@ -0,0 +1,264 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/approvedprinterdriver"
+	"github.com/open-uem/ent/printer"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// PrinterDriverVersion is the number of printers of a model that use a driver version
+type PrinterDriverVersion struct {
+	Model         string `sql:"model"`
+	DriverVersion string `sql:"driver_version"`
+	Count         int    `sql:"count"`
+}
+
+// AgentDefaultPrinter is the default printer of an agent
+type AgentDefaultPrinter struct {
+	AgentID       string
+	Printer       string
+	Model         string
+	DriverVersion string
+}
+
+// PrinterDriverComplianceEntry is a printer whose driver version isn't the approved one for its model
+type PrinterDriverComplianceEntry struct {
+	SiteName        string `json:"site_name"`
+	AgentID         string `json:"agent_id"`
+	Hostname        string `json:"hostname"`
+	Printer         string `json:"printer"`
+	IsDefault       bool   `json:"is_default"`
+	DefaultPrinter  string `json:"default_printer"`
+	Model           string `json:"model"`
+	DriverVersion   string `json:"driver_version"`
+	ApprovedVersion string `json:"approved_version"`
+}
+
+// PrinterDriverComplianceSummary is how many printers of a model with an approved driver use it
+type PrinterDriverComplianceSummary struct {
+	Model           string
+	ApprovedVersion string
+	Compliant       int
+	NonCompliant    int
+}
+
+// GetPrinterDriverVersions returns how many printers of each model use each driver version, in the agents of the
+// tenant or of the site, sorted by model and version
+func (m *Model) GetPrinterDriverVersions(c *partials.CommonInfo) ([]PrinterDriverVersion, error) {
+	query, err := m.scopedPrinters(c)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []PrinterDriverVersion{}
+	if err := query.GroupBy(printer.FieldModel, printer.FieldDriverVersion).Aggregate(ent.Count()).Scan(context.Background(), &versions); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Model != versions[j].Model {
+			return versions[i].Model < versions[j].Model
+		}
+		return versions[i].DriverVersion < versions[j].DriverVersion
+	})
+
+	return versions, nil
+}
+
+// GetAgentDefaultPrinters returns the default printer of the agents of the tenant or of the site, by agent
+func (m *Model) GetAgentDefaultPrinters(c *partials.CommonInfo) (map[string]AgentDefaultPrinter, error) {
+	query, err := m.scopedPrinters(c)
+	if err != nil {
+		return nil, err
+	}
+
+	printers, err := query.Where(printer.IsDefault(true)).WithOwner().All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := map[string]AgentDefaultPrinter{}
+	for _, p := range printers {
+		if p.Edges.Owner == nil {
+			continue
+		}
+		defaults[p.Edges.Owner.ID] = AgentDefaultPrinter{AgentID: p.Edges.Owner.ID, Printer: p.Name, Model: p.Model, DriverVersion: p.DriverVersion}
+	}
+	return defaults, nil
+}
+
+// GetApprovedPrinterDrivers returns the driver version approved for each printer model of the tenant
+func (m *Model) GetApprovedPrinterDrivers(tenantID int) ([]*ent.ApprovedPrinterDriver, error) {
+	return m.Client.ApprovedPrinterDriver.Query().
+		Where(approvedprinterdriver.TenantID(tenantID)).
+		Order(ent.Asc(approvedprinterdriver.FieldModel)).
+		All(context.Background())
+}
+
+// SaveApprovedPrinterDriver approves the driver version of a printer model of the tenant, replacing the
+// version approved before for the model
+func (m *Model) SaveApprovedPrinterDriver(tenantID int, model, driverVersion string) error {
+	return m.Client.ApprovedPrinterDriver.Create().
+		SetTenantID(tenantID).
+		SetModel(strings.TrimSpace(model)).
+		SetDriverVersion(strings.TrimSpace(driverVersion)).
+		OnConflictColumns(approvedprinterdriver.FieldTenantID, approvedprinterdriver.FieldModel).
+		UpdateNewValues().
+		Exec(context.Background())
+}
+
+// DeleteApprovedPrinterDriver removes the approved driver of a printer model of the tenant
+func (m *Model) DeleteApprovedPrinterDriver(tenantID, id int) error {
+	n, err := m.Client.ApprovedPrinterDriver.Delete().
+		Where(approvedprinterdriver.ID(id), approvedprinterdriver.TenantID(tenantID)).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPrinterDriverCompliance returns the printers of the agents of the tenant, or of the site, whose driver
+// version isn't the approved one for their model. Only the printers of the model are returned if it's not
+// empty. The printers of models without an approved driver are not checked
+func (m *Model) GetPrinterDriverCompliance(c *partials.CommonInfo, printerModel string) ([]PrinterDriverComplianceEntry, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	approved, printers, err := m.checkedPrinters(c, printerModel)
+	if err != nil || len(printers) == 0 {
+		return []PrinterDriverComplianceEntry{}, err
+	}
+
+	defaults, err := m.GetAgentDefaultPrinters(c)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []PrinterDriverComplianceEntry{}
+	for _, p := range printers {
+		if p.DriverVersion == approved[p.Model] || p.Edges.Owner == nil {
+			continue
+		}
+
+		a := p.Edges.Owner
+		entry := PrinterDriverComplianceEntry{
+			AgentID:         a.ID,
+			Hostname:        a.Hostname,
+			Printer:         p.Name,
+			IsDefault:       p.IsDefault,
+			DefaultPrinter:  defaults[a.ID].Printer,
+			Model:           p.Model,
+			DriverVersion:   p.DriverVersion,
+			ApprovedVersion: approved[p.Model],
+		}
+		for _, s := range a.Edges.Site {
+			if siteID == -1 || s.ID == siteID {
+				entry.SiteName = s.Description
+				break
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Model != entries[j].Model {
+			return entries[i].Model < entries[j].Model
+		}
+		if entries[i].SiteName != entries[j].SiteName {
+			return entries[i].SiteName < entries[j].SiteName
+		}
+		if entries[i].Hostname != entries[j].Hostname {
+			return entries[i].Hostname < entries[j].Hostname
+		}
+		return entries[i].Printer < entries[j].Printer
+	})
+
+	return entries, nil
+}
+
+// GetPrinterDriverComplianceSummary returns, for each printer model with an approved driver, how many printers
+// of the agents of the tenant, or of the site, use the approved version and how many don't
+func (m *Model) GetPrinterDriverComplianceSummary(c *partials.CommonInfo) ([]PrinterDriverComplianceSummary, error) {
+	approved, printers, err := m.checkedPrinters(c, "")
+	if err != nil {
+		return nil, err
+	}
+
+	summary := map[string]*PrinterDriverComplianceSummary{}
+	for model, version := range approved {
+		summary[model] = &PrinterDriverComplianceSummary{Model: model, ApprovedVersion: version}
+	}
+	for _, p := range printers {
+		if p.DriverVersion == approved[p.Model] {
+			summary[p.Model].Compliant++
+		} else {
+			summary[p.Model].NonCompliant++
+		}
+	}
+
+	result := []PrinterDriverComplianceSummary{}
+	for _, s := range summary {
+		result = append(result, *s)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].NonCompliant != result[j].NonCompliant {
+			return result[i].NonCompliant > result[j].NonCompliant
+		}
+		return result[i].Model < result[j].Model
+	})
+
+	return result, nil
+}
+
+// checkedPrinters returns the approved driver version of each model of the tenant and the printers in the scope
+// of the models with an approved driver, only those of the model if it's not empty
+func (m *Model) checkedPrinters(c *partials.CommonInfo, printerModel string) (map[string]string, []*ent.Printer, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	drivers, err := m.GetApprovedPrinterDrivers(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	approved := map[string]string{}
+	for _, d := range drivers {
+		if printerModel == "" || d.Model == printerModel {
+			approved[d.Model] = d.DriverVersion
+		}
+	}
+	if len(approved) == 0 {
+		return approved, nil, nil
+	}
+
+	checkedModels := []string{}
+	for model := range approved {
+		checkedModels = append(checkedModels, model)
+	}
+
+	query, err := m.scopedPrinters(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	printers, err := query.Where(printer.ModelIn(checkedModels...)).WithOwner(func(q *ent.AgentQuery) { q.WithSite() }).All(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return approved, printers, nil
+}
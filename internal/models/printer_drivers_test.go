@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PrinterDriversTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	commonInfo *partials.CommonInfo
+	otherSite  *partials.CommonInfo
+}
+
+func (suite *PrinterDriversTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+	ctx := context.Background()
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	other, err := client.Site.Create().SetDescription("Other").SetTenantID(t.ID).Save(ctx)
+	assert.NoError(suite.T(), err, "should create another site")
+	suite.otherSite = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(other.ID)}
+
+	for _, a := range []struct {
+		id     string
+		siteID int
+	}{{"agent1", s.ID}, {"agent2", s.ID}, {"agent3", other.ID}} {
+		err := client.Agent.Create().SetID(a.id).SetHostname(a.id).SetOs("windows").SetNickname(a.id).AddSiteIDs(a.siteID).Exec(ctx)
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	for _, p := range []struct {
+		agentID, name, model, version string
+		isDefault                     bool
+	}{
+		{"agent1", "Office", "HP LaserJet Pro", "1.2", true},
+		{"agent1", "Labels", "Zebra ZD421", "5.0", false},
+		{"agent2", "Office", "HP LaserJet Pro", "1.1", false},
+		{"agent2", "Home", "Canon i-SENSYS", "2.0", true},
+		{"agent3", "Office", "HP LaserJet Pro", "1.0", true},
+	} {
+		err := client.Printer.Create().SetName(p.name).SetModel(p.model).SetDriverVersion(p.version).SetIsDefault(p.isDefault).SetOwnerID(p.agentID).Exec(ctx)
+		assert.NoError(suite.T(), err, "should create printer")
+	}
+
+	assert.NoError(suite.T(), suite.model.SaveApprovedPrinterDriver(t.ID, "HP LaserJet Pro", "1.0"))
+	assert.NoError(suite.T(), suite.model.SaveApprovedPrinterDriver(t.ID, " HP LaserJet Pro ", "1.2"), "should replace the approved version of the model")
+	assert.NoError(suite.T(), suite.model.SaveApprovedPrinterDriver(t.ID, "Zebra ZD421", "5.0"))
+}
+
+func (suite *PrinterDriversTestSuite) TestGetApprovedPrinterDrivers() {
+	drivers, err := suite.model.GetApprovedPrinterDrivers(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), drivers, 2, "should keep one approved driver per model") {
+		assert.Equal(suite.T(), "HP LaserJet Pro", drivers[0].Model)
+		assert.Equal(suite.T(), "1.2", drivers[0].DriverVersion)
+	}
+
+	assert.ErrorIs(suite.T(), suite.model.DeleteApprovedPrinterDriver(suite.tenantID+1, drivers[0].ID), ErrNotFound, "should not delete the drivers of another tenant")
+	assert.NoError(suite.T(), suite.model.DeleteApprovedPrinterDriver(suite.tenantID, drivers[0].ID))
+}
+
+func (suite *PrinterDriversTestSuite) TestGetPrinterDriverVersions() {
+	versions, err := suite.model.GetPrinterDriverVersions(suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []PrinterDriverVersion{
+		{Model: "Canon i-SENSYS", DriverVersion: "2.0", Count: 1},
+		{Model: "HP LaserJet Pro", DriverVersion: "1.1", Count: 1},
+		{Model: "HP LaserJet Pro", DriverVersion: "1.2", Count: 1},
+		{Model: "Zebra ZD421", DriverVersion: "5.0", Count: 1},
+	}, versions, "should only count the printers of the site")
+}
+
+func (suite *PrinterDriversTestSuite) TestGetAgentDefaultPrinters() {
+	defaults, err := suite.model.GetAgentDefaultPrinters(suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), defaults, 2)
+	assert.Equal(suite.T(), "Home", defaults["agent2"].Printer)
+	assert.Equal(suite.T(), "Canon i-SENSYS", defaults["agent2"].Model)
+}
+
+func (suite *PrinterDriversTestSuite) TestGetPrinterDriverCompliance() {
+	entries, err := suite.model.GetPrinterDriverCompliance(suite.commonInfo, "")
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), entries, 1, "should only flag the printers of the site without the approved driver") {
+		assert.Equal(suite.T(), "agent2", entries[0].AgentID)
+		assert.Equal(suite.T(), "1.1", entries[0].DriverVersion)
+		assert.Equal(suite.T(), "1.2", entries[0].ApprovedVersion)
+		assert.Equal(suite.T(), "Home", entries[0].DefaultPrinter, "should show the default printer of the agent")
+		assert.False(suite.T(), entries[0].IsDefault)
+	}
+
+	entries, err = suite.model.GetPrinterDriverCompliance(suite.otherSite, "HP LaserJet Pro")
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), entries, 1) {
+		assert.Equal(suite.T(), "agent3", entries[0].AgentID)
+		assert.Equal(suite.T(), "Other", entries[0].SiteName)
+		assert.True(suite.T(), entries[0].IsDefault)
+	}
+
+	entries, err = suite.model.GetPrinterDriverCompliance(suite.commonInfo, "Canon i-SENSYS")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries, "should not check the models without an approved driver")
+
+	entries, err = suite.model.GetPrinterDriverCompliance(&partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: "-1"}, "")
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 2, "should flag the printers of all the sites of the tenant")
+}
+
+func (suite *PrinterDriversTestSuite) TestGetPrinterDriverComplianceSummary() {
+	summary, err := suite.model.GetPrinterDriverComplianceSummary(&partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: "-1"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []PrinterDriverComplianceSummary{
+		{Model: "HP LaserJet Pro", ApprovedVersion: "1.2", Compliant: 1, NonCompliant: 2},
+		{Model: "Zebra ZD421", ApprovedVersion: "5.0", Compliant: 1, NonCompliant: 0},
+	}, summary, "should count the printers of the models with an approved driver, the least compliant first")
+}
+
+func TestPrinterDriversTestSuite(t *testing.T) {
+	suite.Run(t, new(PrinterDriversTestSuite))
+}
@@ -2,8 +2,10 @@ package models
 
 import (
 	"context"
+	"sort"
 	"strconv"
 
+	ent "github.com/open-uem/ent"
 	"github.com/open-uem/ent/agent"
 	"github.com/open-uem/ent/printer"
 	"github.com/open-uem/ent/site"
@@ -27,3 +29,52 @@ func (m *Model) CountDifferentPrinters(c *partials.CommonInfo) (int, error) {
 		return m.Client.Printer.Query().Where(printer.HasOwnerWith(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))).Select(printer.FieldName).Unique(true).Count(context.Background())
 	}
 }
+
+// PrinterModelCount is the number of printers of a model
+type PrinterModelCount struct {
+	Model string
+	Count int
+}
+
+// GetPrinterModelDistribution returns how many printers of each model the agents of the tenant, or of the
+// site, have, the most used models first
+func (m *Model) GetPrinterModelDistribution(c *partials.CommonInfo) ([]PrinterModelCount, error) {
+	query, err := m.scopedPrinters(c)
+	if err != nil {
+		return nil, err
+	}
+
+	printers := []PrinterModelCount{}
+	if err := query.GroupBy(printer.FieldModel).Aggregate(ent.Count()).Scan(context.Background(), &printers); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(printers, func(i, j int) bool {
+		if printers[i].Count != printers[j].Count {
+			return printers[i].Count > printers[j].Count
+		}
+		return printers[i].Model < printers[j].Model
+	})
+
+	return printers, nil
+}
+
+// scopedPrinters returns a query of the printers of the agents of the tenant, or of the site
+func (m *Model) scopedPrinters(c *partials.CommonInfo) (*ent.PrinterQuery, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Printer.Query()
+	if siteID == -1 {
+		query.Where(printer.HasOwnerWith(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query.Where(printer.HasOwnerWith(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+	return query, nil
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+var printerModels = []string{"HP LaserJet Pro", "Brother HL-L2350", "HP LaserJet Pro", "Canon i-SENSYS"}
+
 type PrintersTestSuite struct {
 	suite.Suite
 	t          enttest.TestingT
@@ -37,6 +39,7 @@ func (suite *PrintersTestSuite) SetupTest() {
 	for i := 0; i <= 6; i++ {
 		err := client.Printer.Create().
 			SetName(fmt.Sprintf("printer%d", i)).
+			SetModel(printerModels[i%len(printerModels)]).
 			SetOwnerID("agent1").
 			Exec(context.Background())
 		assert.NoError(suite.T(), err)
@@ -49,6 +52,20 @@ func (suite *PrintersTestSuite) TestCountDifferentPrinters() {
 	assert.Equal(suite.T(), 7, count, "should count 7 different printers")
 }
 
+func (suite *PrintersTestSuite) TestGetPrinterModelDistribution() {
+	distribution, err := suite.model.GetPrinterModelDistribution(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get the printer models")
+	assert.Equal(suite.T(), []PrinterModelCount{
+		{Model: "HP LaserJet Pro", Count: 4},
+		{Model: "Brother HL-L2350", Count: 2},
+		{Model: "Canon i-SENSYS", Count: 1},
+	}, distribution, "should count the printers of each model, the most used first")
+
+	distribution, err = suite.model.GetPrinterModelDistribution(&partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: "-1"})
+	assert.NoError(suite.T(), err, "should get the printer models of the tenant")
+	assert.Len(suite.T(), distribution, 3)
+}
+
 func TestPrintersTestSuite(t *testing.T) {
 	suite.Run(t, new(PrintersTestSuite))
 }
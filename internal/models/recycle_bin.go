@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/certificate"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"golang.org/x/crypto/ocsp"
+)
+
+// DefaultDeletedAgentsRetention is how long deleted agents are kept in the recycle bin if no other value is configured
+const DefaultDeletedAgentsRetention = 30 * 24 * time.Hour
+
+// ErrSiteRequired is returned when a deleted agent can't be restored to its site because the site has been removed
+var ErrSiteRequired = errors.New("the site of the agent no longer exists")
+
+type includeDeletedAgentsKey struct{}
+
+// withDeletedAgents returns a context whose agent queries include the agents in the recycle bin
+func withDeletedAgents(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedAgentsKey{}, true)
+}
+
+// excludeDeletedAgents hides the deleted agents from every agent query and traversal,
+// so queries don't have to filter them one by one
+func excludeDeletedAgents() ent.Interceptor {
+	return ent.TraverseFunc(func(ctx context.Context, q ent.Query) error {
+		if include, _ := ctx.Value(includeDeletedAgentsKey{}).(bool); include {
+			return nil
+		}
+		if aq, ok := q.(*ent.AgentQuery); ok {
+			aq.Where(agent.DeletedAtIsNil())
+		}
+		return nil
+	})
+}
+
+// GetDeletedAgentsRetention returns how long deleted agents can be restored
+func (m *Model) GetDeletedAgentsRetention() time.Duration {
+	if m.DeletedAgentsRetention <= 0 {
+		return DefaultDeletedAgentsRetention
+	}
+	return m.DeletedAgentsRetention
+}
+
+// deletedAgentsPurgeDate returns the date before which deleted agents can't be restored and are purged
+func (m *Model) deletedAgentsPurgeDate() time.Time {
+	return time.Now().Add(-m.GetDeletedAgentsRetention())
+}
+
+// GetDeletedAgents returns the agents in the recycle bin of the tenant, most recently deleted first
+func (m *Model) GetDeletedAgents(c *partials.CommonInfo) ([]*ent.Agent, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Client.Agent.Query().WithSite().
+		Where(agent.DeletedTenantID(tenantID), agent.DeletedAtGT(m.deletedAgentsPurgeDate())).
+		Order(ent.Desc(agent.FieldDeletedAt)).
+		All(withDeletedAgents(context.Background()))
+}
+
+// GetDeletedAgentById returns an agent in the recycle bin of the tenant
+func (m *Model) GetDeletedAgentById(agentId string, c *partials.CommonInfo) (*ent.Agent, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := m.Client.Agent.Query().WithSite().
+		Where(agent.ID(agentId), agent.DeletedTenantID(tenantID), agent.DeletedAtGT(m.deletedAgentsPurgeDate())).
+		Only(withDeletedAgents(context.Background()))
+	return a, dbError(err)
+}
+
+// RestoreAgent takes an agent out of the recycle bin. If its site has been removed, it's
+// restored to siteID, which must be a site of the tenant, or ErrSiteRequired is returned
+func (m *Model) RestoreAgent(agentId string, siteID int, c *partials.CommonInfo) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return err
+	}
+
+	a, err := m.GetDeletedAgentById(agentId, c)
+	if err != nil {
+		return err
+	}
+
+	update := m.Client.Agent.UpdateOneID(a.ID).ClearDeletedAt().ClearDeletedTenantID()
+	if len(a.Edges.Site) == 0 {
+		if siteID <= 0 {
+			return ErrSiteRequired
+		}
+		exists, err := m.Client.Site.Query().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return dbError(err)
+		}
+		if !exists {
+			return ErrSiteRequired
+		}
+		update.AddSiteIDs(siteID)
+	}
+
+	return dbError(update.Exec(context.Background()))
+}
+
+// PurgeDeletedAgents removes the agents that have been in the recycle bin longer than the
+// retention and revokes their certificates. It returns the number of agents removed
+func (m *Model) PurgeDeletedAgents() (int, error) {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	ctx := withDeletedAgents(context.Background())
+	agents, err := m.Client.Agent.Query().Where(agent.DeletedAtLTE(m.deletedAgentsPurgeDate())).Select(agent.FieldID).All(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, a := range agents {
+		certs, err := m.Client.Certificate.Query().Where(certificate.UID(a.ID)).All(context.Background())
+		if err != nil {
+			return purged, err
+		}
+		for _, cert := range certs {
+			// The certificate may have been revoked already by an admin
+			if err := m.RevokeCertificate(cert, "agent has been deleted", ocsp.CessationOfOperation); err != nil && !ent.IsConstraintError(err) {
+				return purged, err
+			}
+			if err := m.DeleteCertificate(cert.ID); err != nil {
+				return purged, err
+			}
+		}
+
+		if err := m.Client.Agent.DeleteOneID(a.ID).Exec(ctx); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
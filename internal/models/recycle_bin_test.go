@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecycleBinTestSuite struct {
+	suite.Suite
+	t           enttest.TestingT
+	model       Model
+	commonInfo  *partials.CommonInfo
+	otherSiteID int
+}
+
+func (suite *RecycleBinTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	other, err := client.Site.Create().SetDescription("Other").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create other site")
+	suite.otherSiteID = other.ID
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: "-1"}
+
+	for _, id := range []string{"agent1", "agent2"} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	err = client.Certificate.Create().SetID(1).SetType("agent").SetDescription("agent1").SetExpiry(time.Now().AddDate(1, 0, 0)).SetUID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent certificate")
+}
+
+func (suite *RecycleBinTestSuite) TestDeleteAndRestoreAgent() {
+	err := suite.model.DeleteAgent("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should delete agent")
+
+	_, err = suite.model.GetAgentById("agent1", suite.commonInfo)
+	assert.True(suite.T(), openuem_ent.IsNotFound(err), "deleted agents should be hidden")
+
+	count, err := suite.model.Client.Agent.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count, "deleted agents should be hidden from every query")
+
+	deleted, err := suite.model.GetDeletedAgents(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deleted agents")
+	assert.Len(suite.T(), deleted, 1, "deleted agent should be in the recycle bin")
+
+	err = suite.model.RestoreAgent("agent1", 0, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should restore agent to its site")
+
+	_, err = suite.model.GetAgentById("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err, "restored agent should be visible")
+
+	err = suite.model.RestoreAgent("agent2", 0, suite.commonInfo)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not restore agents that aren't deleted")
+}
+
+func (suite *RecycleBinTestSuite) TestRestoreAgentWithoutSite() {
+	err := suite.model.DeleteAgent("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should delete agent")
+
+	a, err := suite.model.GetDeletedAgentById("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	err = suite.model.Client.Agent.UpdateOneID(a.ID).ClearSite().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should remove the site of the agent")
+
+	err = suite.model.RestoreAgent("agent1", 0, suite.commonInfo)
+	assert.ErrorIs(suite.T(), err, ErrSiteRequired, "should require a new site")
+
+	err = suite.model.RestoreAgent("agent1", 9999, suite.commonInfo)
+	assert.ErrorIs(suite.T(), err, ErrSiteRequired, "should not restore to sites of other tenants")
+
+	err = suite.model.RestoreAgent("agent1", suite.otherSiteID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should restore agent to the new site")
+
+	a, err = suite.model.GetAgentById("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.otherSiteID, a.Edges.Site[0].ID, "should be in the new site")
+}
+
+func (suite *RecycleBinTestSuite) TestPurgeDeletedAgents() {
+	err := suite.model.DeleteAgent("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should delete agent")
+
+	purged, err := suite.model.PurgeDeletedAgents()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, purged, "should keep agents within the retention")
+
+	suite.model.DeletedAgentsRetention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+
+	err = suite.model.RestoreAgent("agent1", 0, suite.commonInfo)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not restore agents past the retention")
+
+	purged, err = suite.model.PurgeDeletedAgents()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, purged, "should purge agents past the retention")
+
+	exists, err := suite.model.Client.Revocation.Query().Exist(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), exists, "should revoke the certificate of the agent")
+
+	_, err = suite.model.GetCertificateByUID("agent1")
+	assert.True(suite.T(), openuem_ent.IsNotFound(err), "should remove the certificate of the agent")
+
+	count, err := suite.model.Client.Agent.Query().Count(withDeletedAgents(context.Background()))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count, "should only keep the agent that wasn't deleted")
+}
+
+func TestRecycleBinTestSuite(t *testing.T) {
+	suite.Run(t, new(RecycleBinTestSuite))
+}
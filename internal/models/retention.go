@@ -0,0 +1,351 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/agentcheckin"
+	"github.com/open-uem/ent/agentcommandlog"
+	"github.com/open-uem/ent/agentevent"
+	"github.com/open-uem/ent/historyrollup"
+	"github.com/open-uem/ent/retentionpolicy"
+)
+
+// Categories of historical data whose retention can be set. The data of a category is kept
+// forever until an admin sets a retention for it
+const (
+	RetentionCheckins    = "checkins"
+	RetentionCommandLogs = "command_logs"
+	RetentionAgentEvents = "agent_events"
+)
+
+// RetentionCategories lists the categories in the order they're shown in the settings
+var RetentionCategories = []string{RetentionCheckins, RetentionCommandLogs, RetentionAgentEvents}
+
+// retentionBatchSize is how many rows are rolled up and deleted in each transaction, so the purge
+// doesn't keep the tables locked while the agents report
+const retentionBatchSize = 1000
+
+// RetentionPolicy is how long the raw data of a category is kept
+type RetentionPolicy struct {
+	Category string
+	// Days is how many days the raw data is kept, 0 keeps it forever
+	Days int
+	// Rollup adds the data to the daily aggregates before it's deleted
+	Rollup bool
+}
+
+// StorageUsage is the data stored for a category
+type StorageUsage struct {
+	Category   string
+	Rows       int
+	RollupRows int
+	// Size is the estimated size in bytes of the table and its indexes, 0 if it can't be estimated
+	Size int64
+}
+
+// rollupKey identifies a daily aggregate, the tenant, agent and key are empty when the category
+// doesn't have them
+type rollupKey struct {
+	day      time.Time
+	tenantID int
+	agentID  string
+	key      string
+}
+
+// purgeBatchFunc rolls up, if asked to, and deletes a batch of the rows older than before, it returns
+// how many rows have been deleted
+type purgeBatchFunc func(ctx context.Context, tx *ent.Tx, before time.Time, rollup bool) (int, error)
+
+var purgeBatches = map[string]purgeBatchFunc{
+	RetentionCheckins:    purgeCheckinsBatch,
+	RetentionCommandLogs: purgeCommandLogsBatch,
+	RetentionAgentEvents: purgeAgentEventsBatch,
+}
+
+// retentionTables are the tables of the categories whose size is estimated
+var retentionTables = map[string]string{
+	RetentionCheckins:    agentcheckin.Table,
+	RetentionCommandLogs: agentcommandlog.Table,
+	RetentionAgentEvents: agentevent.Table,
+}
+
+// GetRetentionPolicies returns the policy of every category, the categories without a policy keep
+// their data forever
+func (m *Model) GetRetentionPolicies() ([]RetentionPolicy, error) {
+	saved, err := m.Client.RetentionPolicy.Query().All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]RetentionPolicy, 0, len(RetentionCategories))
+	for _, category := range RetentionCategories {
+		p := RetentionPolicy{Category: category}
+		for _, s := range saved {
+			if s.Category == category {
+				p.Days = s.Days
+				p.Rollup = s.Rollup
+			}
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// SaveRetentionPolicy stores the policy of a category
+func (m *Model) SaveRetentionPolicy(p RetentionPolicy) error {
+	if _, ok := purgeBatches[p.Category]; !ok {
+		return fmt.Errorf("unknown retention category %q", p.Category)
+	}
+	if p.Days < 0 {
+		return fmt.Errorf("the days to keep the data cannot be negative")
+	}
+
+	n, err := m.Client.RetentionPolicy.Update().
+		Where(retentionpolicy.Category(p.Category)).
+		SetDays(p.Days).
+		SetRollup(p.Rollup).
+		Save(context.Background())
+	if err != nil || n > 0 {
+		return err
+	}
+
+	return m.Client.RetentionPolicy.Create().
+		SetCategory(p.Category).
+		SetDays(p.Days).
+		SetRollup(p.Rollup).
+		Exec(context.Background())
+}
+
+// RetentionCutoff is the date before which the data of the policy is purged. It's the start of a day
+// in UTC, so a day is never split between the raw data and its aggregate
+func RetentionCutoff(p RetentionPolicy, now time.Time) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -p.Days)
+}
+
+// PurgeHistory deletes, in batches, the data of the category older than the retention of the policy,
+// adding it first to the daily aggregates if the policy rolls it up. progress is called after each
+// batch with the rows deleted so far. It returns how many rows have been deleted
+func (m *Model) PurgeHistory(p RetentionPolicy, now time.Time, progress func(purged int)) (int, error) {
+	purgeBatch, ok := purgeBatches[p.Category]
+	if !ok {
+		return 0, fmt.Errorf("unknown retention category %q", p.Category)
+	}
+	if p.Days <= 0 {
+		return 0, nil
+	}
+
+	ctx := context.Background()
+	before := RetentionCutoff(p, now)
+
+	purged := 0
+	for {
+		tx, err := m.Client.Tx(ctx)
+		if err != nil {
+			return purged, err
+		}
+
+		n, err := purgeBatch(ctx, tx, before, p.Rollup)
+		if err != nil {
+			return purged, rollback(tx, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return purged, err
+		}
+
+		purged += n
+		if n > 0 && progress != nil {
+			progress(purged)
+		}
+		if n < retentionBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func purgeCheckinsBatch(ctx context.Context, tx *ent.Tx, before time.Time, rollup bool) (int, error) {
+	checkins, err := tx.AgentCheckin.Query().
+		Where(agentcheckin.CheckedInAtLT(before)).
+		WithAgent().
+		Order(ent.Asc(agentcheckin.FieldID)).
+		Limit(retentionBatchSize).
+		All(ctx)
+	if err != nil || len(checkins) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int, 0, len(checkins))
+	counts := map[rollupKey]int{}
+	for _, c := range checkins {
+		ids = append(ids, c.ID)
+		k := rollupKey{day: rollupDay(c.CheckedInAt)}
+		if c.Edges.Agent != nil {
+			k.agentID = c.Edges.Agent.ID
+		}
+		counts[k]++
+	}
+
+	if rollup {
+		if err := addRollups(ctx, tx, RetentionCheckins, counts); err != nil {
+			return 0, err
+		}
+	}
+
+	return tx.AgentCheckin.Delete().Where(agentcheckin.IDIn(ids...)).Exec(ctx)
+}
+
+func purgeCommandLogsBatch(ctx context.Context, tx *ent.Tx, before time.Time, rollup bool) (int, error) {
+	logs, err := tx.AgentCommandLog.Query().
+		Where(agentcommandlog.ExecutedAtLT(before)).
+		Order(ent.Asc(agentcommandlog.FieldID)).
+		Limit(retentionBatchSize).
+		All(ctx)
+	if err != nil || len(logs) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int, 0, len(logs))
+	counts := map[rollupKey]int{}
+	for _, l := range logs {
+		ids = append(ids, l.ID)
+		k := rollupKey{day: rollupDay(l.ExecutedAt), tenantID: l.TenantID, agentID: l.AgentID, key: "succeeded"}
+		if l.ExitCode != 0 {
+			k.key = "failed"
+		}
+		counts[k]++
+	}
+
+	if rollup {
+		if err := addRollups(ctx, tx, RetentionCommandLogs, counts); err != nil {
+			return 0, err
+		}
+	}
+
+	return tx.AgentCommandLog.Delete().Where(agentcommandlog.IDIn(ids...)).Exec(ctx)
+}
+
+func purgeAgentEventsBatch(ctx context.Context, tx *ent.Tx, before time.Time, rollup bool) (int, error) {
+	events, err := tx.AgentEvent.Query().
+		Where(agentevent.CreatedAtLT(before)).
+		Order(ent.Asc(agentevent.FieldID)).
+		Limit(retentionBatchSize).
+		All(ctx)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int, 0, len(events))
+	counts := map[rollupKey]int{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+		counts[rollupKey{day: rollupDay(e.CreatedAt), tenantID: e.TenantID, agentID: e.AgentID, key: e.Type}]++
+	}
+
+	if rollup {
+		if err := addRollups(ctx, tx, RetentionAgentEvents, counts); err != nil {
+			return 0, err
+		}
+	}
+
+	return tx.AgentEvent.Delete().Where(agentevent.IDIn(ids...)).Exec(ctx)
+}
+
+// rollupDay is the day, in UTC, a row is aggregated in
+func rollupDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// addRollups adds the counts to the daily aggregates of the category, a day may have been rolled up
+// partially by a previous batch
+func addRollups(ctx context.Context, tx *ent.Tx, category string, counts map[rollupKey]int) error {
+	for k, count := range counts {
+		n, err := tx.HistoryRollup.Update().
+			Where(
+				historyrollup.Category(category),
+				historyrollup.Day(k.day),
+				historyrollup.TenantID(k.tenantID),
+				historyrollup.AgentID(k.agentID),
+				historyrollup.Key(k.key),
+			).
+			AddCount(count).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+
+		if err := tx.HistoryRollup.Create().
+			SetCategory(category).
+			SetDay(k.day).
+			SetTenantID(k.tenantID).
+			SetAgentID(k.agentID).
+			SetKey(k.key).
+			SetCount(count).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetHistoryRollups returns the daily aggregates of the category, oldest first
+func (m *Model) GetHistoryRollups(category string) ([]*ent.HistoryRollup, error) {
+	return m.Client.HistoryRollup.Query().
+		Where(historyrollup.Category(category)).
+		Order(ent.Asc(historyrollup.FieldDay), ent.Asc(historyrollup.FieldID)).
+		All(context.Background())
+}
+
+// GetStorageUsage returns the rows stored for each category and the estimated size of their tables
+func (m *Model) GetStorageUsage() ([]StorageUsage, error) {
+	ctx := context.Background()
+
+	usage := make([]StorageUsage, 0, len(RetentionCategories))
+	for _, category := range RetentionCategories {
+		u := StorageUsage{Category: category}
+
+		var err error
+		switch category {
+		case RetentionCheckins:
+			u.Rows, err = m.Client.AgentCheckin.Query().Count(ctx)
+		case RetentionCommandLogs:
+			u.Rows, err = m.Client.AgentCommandLog.Query().Count(ctx)
+		case RetentionAgentEvents:
+			u.Rows, err = m.Client.AgentEvent.Query().Count(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		u.RollupRows, err = m.Client.HistoryRollup.Query().Where(historyrollup.Category(category)).Count(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		u.Size, err = m.tableSize(ctx, retentionTables[category])
+		if err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// tableSize returns the size in bytes of the table and its indexes in Postgres, 0 if the size
+// can't be read
+func (m *Model) tableSize(ctx context.Context, table string) (int64, error) {
+	if m.db == nil {
+		return 0, nil
+	}
+
+	var size int64
+	if err := m.db.QueryRowContext(ctx, "SELECT COALESCE(pg_total_relation_size(to_regclass($1)), 0)", table).Scan(&size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RetentionTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	now      time.Time
+}
+
+func (suite *RetentionTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+	suite.now = time.Date(2026, 3, 20, 10, 30, 0, 0, time.UTC)
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	for _, id := range []string{"agent1", "agent2"} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+// daysAgo returns a time of the day, in UTC, that was days before now
+func (suite *RetentionTestSuite) daysAgo(days, hour int) time.Time {
+	d := suite.now.AddDate(0, 0, -days)
+	return time.Date(d.Year(), d.Month(), d.Day(), hour, 0, 0, 0, time.UTC)
+}
+
+func (suite *RetentionTestSuite) TestDefaultPoliciesKeepEverything() {
+	policies, err := suite.model.GetRetentionPolicies()
+	assert.NoError(suite.T(), err, "should get retention policies")
+	assert.Len(suite.T(), policies, len(RetentionCategories))
+	for _, p := range policies {
+		assert.Equal(suite.T(), 0, p.Days, "should keep the data forever by default")
+		assert.False(suite.T(), p.Rollup)
+	}
+
+	err = suite.model.Client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(1000, 1)).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create check-in")
+
+	for _, p := range policies {
+		purged, err := suite.model.PurgeHistory(p, suite.now, nil)
+		assert.NoError(suite.T(), err, "should purge history")
+		assert.Equal(suite.T(), 0, purged, "should not purge anything without a retention")
+	}
+
+	count, err := suite.model.Client.AgentCheckin.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+}
+
+func (suite *RetentionTestSuite) TestSaveRetentionPolicy() {
+	err := suite.model.SaveRetentionPolicy(RetentionPolicy{Category: RetentionCheckins, Days: 30, Rollup: true})
+	assert.NoError(suite.T(), err, "should save retention policy")
+
+	err = suite.model.SaveRetentionPolicy(RetentionPolicy{Category: RetentionCheckins, Days: 60})
+	assert.NoError(suite.T(), err, "should update retention policy")
+
+	policies, err := suite.model.GetRetentionPolicies()
+	assert.NoError(suite.T(), err, "should get retention policies")
+	assert.Equal(suite.T(), RetentionPolicy{Category: RetentionCheckins, Days: 60}, policies[0])
+
+	assert.Error(suite.T(), suite.model.SaveRetentionPolicy(RetentionPolicy{Category: "unknown", Days: 1}), "should reject unknown categories")
+	assert.Error(suite.T(), suite.model.SaveRetentionPolicy(RetentionPolicy{Category: RetentionCheckins, Days: -1}), "should reject negative days")
+}
+
+func (suite *RetentionTestSuite) TestCheckinsRollupsMatchRawData() {
+	ctx := context.Background()
+	client := suite.model.Client
+
+	// More check-ins than a batch so the same day is rolled up by several batches
+	expected := map[string]int{}
+	for i := range retentionBatchSize + 250 {
+		agentID := "agent1"
+		if i%3 == 0 {
+			agentID = "agent2"
+		}
+		checkedInAt := suite.daysAgo(40+i%5, i%24)
+		err := client.AgentCheckin.Create().SetAgentID(agentID).SetCheckedInAt(checkedInAt).Exec(ctx)
+		assert.NoError(suite.T(), err, "should create check-in")
+		expected[rollupDay(checkedInAt).Format(time.DateOnly)+"/"+agentID]++
+	}
+
+	// Recent check-ins are kept
+	for range 3 {
+		err := client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(2, 8)).Exec(ctx)
+		assert.NoError(suite.T(), err, "should create check-in")
+	}
+
+	batches := 0
+	purged, err := suite.model.PurgeHistory(RetentionPolicy{Category: RetentionCheckins, Days: 30, Rollup: true}, suite.now, func(int) { batches++ })
+	assert.NoError(suite.T(), err, "should purge check-ins")
+	assert.Equal(suite.T(), retentionBatchSize+250, purged)
+	assert.Equal(suite.T(), 2, batches, "should report the progress after each batch")
+
+	remaining, err := client.AgentCheckin.Query().Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, remaining, "should keep the check-ins within the retention")
+
+	rollups, err := suite.model.GetHistoryRollups(RetentionCheckins)
+	assert.NoError(suite.T(), err, "should get rollups")
+
+	got := map[string]int{}
+	for _, r := range rollups {
+		got[r.Day.UTC().Format(time.DateOnly)+"/"+r.AgentID] += r.Count
+	}
+	assert.Equal(suite.T(), expected, got, "should aggregate every check-in in its day and agent")
+	assert.Len(suite.T(), rollups, len(expected), "should have one aggregate for each day and agent")
+}
+
+func (suite *RetentionTestSuite) TestCommandLogsAndEventsRollupsMatchRawData() {
+	ctx := context.Background()
+	client := suite.model.Client
+
+	for i, exitCode := range []int{0, 0, 1, 0, 2} {
+		err := client.AgentCommandLog.Create().
+			SetAgentID("agent1").
+			SetTenantID(suite.tenantID).
+			SetUserID("admin").
+			SetCommand("hostname").
+			SetExitCode(exitCode).
+			SetOutputHash("hash").
+			SetExecutedAt(suite.daysAgo(10, i)).
+			Exec(ctx)
+		assert.NoError(suite.T(), err, "should create command log")
+	}
+
+	for _, eventType := range []string{AgentEventDecommissioned, AgentEventDecommissioned, "restored"} {
+		err := client.AgentEvent.Create().
+			SetAgentID("agent2").
+			SetTenantID(suite.tenantID).
+			SetType(eventType).
+			SetCreatedAt(suite.daysAgo(10, 5)).
+			Exec(ctx)
+		assert.NoError(suite.T(), err, "should create agent event")
+	}
+
+	purged, err := suite.model.PurgeHistory(RetentionPolicy{Category: RetentionCommandLogs, Days: 7, Rollup: true}, suite.now, nil)
+	assert.NoError(suite.T(), err, "should purge command logs")
+	assert.Equal(suite.T(), 5, purged)
+
+	purged, err = suite.model.PurgeHistory(RetentionPolicy{Category: RetentionAgentEvents, Days: 7, Rollup: true}, suite.now, nil)
+	assert.NoError(suite.T(), err, "should purge agent events")
+	assert.Equal(suite.T(), 3, purged)
+
+	rollups, err := suite.model.GetHistoryRollups(RetentionCommandLogs)
+	assert.NoError(suite.T(), err, "should get rollups")
+	counts := map[string]int{}
+	for _, r := range rollups {
+		assert.Equal(suite.T(), suite.tenantID, r.TenantID)
+		assert.Equal(suite.T(), "agent1", r.AgentID)
+		counts[r.Key] += r.Count
+	}
+	assert.Equal(suite.T(), map[string]int{"succeeded": 3, "failed": 2}, counts)
+
+	rollups, err = suite.model.GetHistoryRollups(RetentionAgentEvents)
+	assert.NoError(suite.T(), err, "should get rollups")
+	counts = map[string]int{}
+	for _, r := range rollups {
+		assert.Equal(suite.T(), "agent2", r.AgentID)
+		counts[r.Key] += r.Count
+	}
+	assert.Equal(suite.T(), map[string]int{AgentEventDecommissioned: 2, "restored": 1}, counts)
+}
+
+func (suite *RetentionTestSuite) TestPurgeWithoutRollup() {
+	ctx := context.Background()
+
+	err := suite.model.Client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(31, 23)).Exec(ctx)
+	assert.NoError(suite.T(), err, "should create check-in")
+
+	// The day of the cutoff is kept whole
+	err = suite.model.Client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(30, 0)).Exec(ctx)
+	assert.NoError(suite.T(), err, "should create check-in")
+
+	purged, err := suite.model.PurgeHistory(RetentionPolicy{Category: RetentionCheckins, Days: 30}, suite.now, nil)
+	assert.NoError(suite.T(), err, "should purge check-ins")
+	assert.Equal(suite.T(), 1, purged)
+
+	rollups, err := suite.model.GetHistoryRollups(RetentionCheckins)
+	assert.NoError(suite.T(), err, "should get rollups")
+	assert.Empty(suite.T(), rollups, "should not roll up the check-ins")
+}
+
+func (suite *RetentionTestSuite) TestGetStorageUsage() {
+	ctx := context.Background()
+
+	err := suite.model.Client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(40, 1)).Exec(ctx)
+	assert.NoError(suite.T(), err, "should create check-in")
+	err = suite.model.Client.AgentCheckin.Create().SetAgentID("agent1").SetCheckedInAt(suite.daysAgo(1, 1)).Exec(ctx)
+	assert.NoError(suite.T(), err, "should create check-in")
+
+	_, err = suite.model.PurgeHistory(RetentionPolicy{Category: RetentionCheckins, Days: 30, Rollup: true}, suite.now, nil)
+	assert.NoError(suite.T(), err, "should purge check-ins")
+
+	usage, err := suite.model.GetStorageUsage()
+	assert.NoError(suite.T(), err, "should get storage usage")
+	assert.Len(suite.T(), usage, len(RetentionCategories))
+	assert.Equal(suite.T(), StorageUsage{Category: RetentionCheckins, Rows: 1, RollupRows: 1}, usage[0])
+	assert.Equal(suite.T(), StorageUsage{Category: RetentionCommandLogs}, usage[1])
+}
+
+func TestRetentionTestSuite(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
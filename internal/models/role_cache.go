@@ -0,0 +1,34 @@
+package models
+
+// RoleCache keeps the roles of the users in the tenants, so the middlewares checking them on
+// every request don't hit the database. Implementations must be safe for concurrent use and
+// should expire the entries, as roles removed with a user or a tenant aren't deleted one by one
+type RoleCache interface {
+	Get(userID string, tenantID int) (UserTenantRole, bool)
+	Set(userID string, tenantID int, role UserTenantRole)
+	Delete(userID string, tenantID int)
+}
+
+// NoopRoleCache doesn't cache the roles, it's used when no other cache is set
+type NoopRoleCache struct{}
+
+func (NoopRoleCache) Get(string, int) (UserTenantRole, bool) { return "", false }
+func (NoopRoleCache) Set(string, int, UserTenantRole)        {}
+func (NoopRoleCache) Delete(string, int)                     {}
+
+// Option configures the model created by New
+type Option func(m *Model)
+
+// WithRoleCache caches the roles of the users in the tenants, e.g. in a cache shared by the console replicas
+func WithRoleCache(cache RoleCache) Option {
+	return func(m *Model) {
+		m.roles = cache
+	}
+}
+
+func (m *Model) roleCache() RoleCache {
+	if m.roles == nil {
+		return NoopRoleCache{}
+	}
+	return m.roles
+}
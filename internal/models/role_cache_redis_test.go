@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisRoleCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache := NewRedisRoleCache(redis.NewClient(&redis.Options{Addr: server.Addr()}), 30*time.Second)
+	defer cache.Close()
+
+	_, ok := cache.Get("user0", 1)
+	assert.False(t, ok, "missing roles should be a miss")
+
+	cache.Set("user0", 1, UserTenantRoleAdmin)
+	role, ok := cache.Get("user0", 1)
+	assert.True(t, ok, "should get the cached role")
+	assert.Equal(t, UserTenantRoleAdmin, role)
+	assert.Equal(t, 30*time.Second, server.TTL(redisRoleCacheKey("user0", 1)), "the role should expire")
+
+	_, ok = cache.Get("user0", 2)
+	assert.False(t, ok, "the roles should be cached by tenant")
+
+	cache.Delete("user0", 1)
+	_, ok = cache.Get("user0", 1)
+	assert.False(t, ok, "should not get a deleted role")
+
+	cache.Set("user0", 1, UserTenantRoleOperator)
+	server.FastForward(31 * time.Second)
+	_, ok = cache.Get("user0", 1)
+	assert.False(t, ok, "should not get an expired role")
+
+	cache.Set("user0", 1, UserTenantRoleOperator)
+	server.Close()
+	_, ok = cache.Get("user0", 1)
+	assert.False(t, ok, "Redis errors should be a miss so the role is read from the database")
+}
+
+func TestNewRedisRoleCacheFromURL(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisRoleCacheFromURL("redis://"+server.Addr()+"/0", 0)
+	assert.NoError(t, err)
+	defer cache.Close()
+	assert.Equal(t, DefaultRoleCacheTTL, cache.ttl, "should use the default TTL")
+
+	cache.Set("user0", 1, UserTenantRoleUser)
+	role, ok := cache.Get("user0", 1)
+	assert.True(t, ok)
+	assert.Equal(t, UserTenantRoleUser, role)
+
+	_, err = NewRedisRoleCacheFromURL("http://localhost", 0)
+	assert.Error(t, err, "should reject URLs that aren't Redis URLs")
+}
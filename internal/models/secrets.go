@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/authentication"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/smtpconfig"
+	"github.com/open-uem/openuem-console/internal/auth"
+)
+
+// WithSecrets encrypts the secrets stored in the database, e.g. the SMTP passwords, with the box
+func WithSecrets(box *auth.SecretBox) Option {
+	return func(m *Model) {
+		m.secrets = box
+	}
+}
+
+// OpenSecret decrypts a secret read from the database, secrets stored in plaintext are returned as they are
+func (m *Model) OpenSecret(value string) (string, error) {
+	return m.secrets.Open(value)
+}
+
+func (m *Model) sealSecret(value string) (string, error) {
+	return m.secrets.Seal(value)
+}
+
+// storedSecret is a secret stored in a column of the database
+type storedSecret struct {
+	column string
+	id     int
+	value  string
+	// jwtKeyEncrypted is set for the columns that were encrypted with the JWT key before the secrets key
+	// was introduced, such as the LDAP bind password
+	jwtKeyEncrypted bool
+	update          func(ctx context.Context, client *ent.Client, value string) error
+}
+
+// storedSecrets returns the secrets stored in the database that are not empty
+func storedSecrets(ctx context.Context, client *ent.Client) ([]storedSecret, error) {
+	secrets := []storedSecret{}
+
+	allSettings, err := client.Settings.Query().Select(settings.FieldSMTPPassword).Where(settings.SMTPPasswordNEQ("")).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range allSettings {
+		id := s.ID
+		secrets = append(secrets, storedSecret{
+			column: settings.Table + "." + settings.FieldSMTPPassword,
+			id:     id,
+			value:  s.SMTPPassword,
+			update: func(ctx context.Context, client *ent.Client, value string) error {
+				return client.Settings.UpdateOneID(id).SetSMTPPassword(value).Exec(ctx)
+			},
+		})
+	}
+
+	smtpConfigs, err := client.SMTPConfig.Query().Select(smtpconfig.FieldPassword).Where(smtpconfig.PasswordNEQ("")).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range smtpConfigs {
+		id := c.ID
+		secrets = append(secrets, storedSecret{
+			column: smtpconfig.Table + "." + smtpconfig.FieldPassword,
+			id:     id,
+			value:  c.Password,
+			update: func(ctx context.Context, client *ent.Client, value string) error {
+				return client.SMTPConfig.UpdateOneID(id).SetPassword(value).Exec(ctx)
+			},
+		})
+	}
+
+	authSettings, err := client.Authentication.Query().Select(authentication.FieldLDAPBindPassword).Where(authentication.LDAPBindPasswordNEQ("")).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range authSettings {
+		id := s.ID
+		secrets = append(secrets, storedSecret{
+			column:          authentication.Table + "." + authentication.FieldLDAPBindPassword,
+			id:              id,
+			value:           s.LDAPBindPassword,
+			jwtKeyEncrypted: true,
+			update: func(ctx context.Context, client *ent.Client, value string) error {
+				return client.Authentication.UpdateOneID(id).SetLDAPBindPassword(value).Exec(ctx)
+			},
+		})
+	}
+
+	return secrets, nil
+}
+
+// CheckSecrets verifies that the encrypted secrets can be decrypted with the secrets key, it fails if the
+// key is missing or it's not the key they were encrypted with. It returns how many secrets are not
+// encrypted with the secrets key yet
+func (m *Model) CheckSecrets() (int, error) {
+	secrets, err := storedSecrets(context.Background(), m.Client)
+	if err != nil {
+		return 0, fmt.Errorf("could not get the secrets stored in the database: %w", err)
+	}
+
+	pending := 0
+	for _, s := range secrets {
+		if !auth.IsEncryptedSecret(s.value) {
+			pending++
+			continue
+		}
+		if _, err := m.secrets.Open(s.value); err != nil {
+			return pending, fmt.Errorf("could not decrypt %s (id %d): %w", s.column, s.id, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// EncryptSecrets encrypts in place the secrets that are not encrypted with the secrets key yet and
+// returns how many have been encrypted. jwtKey decrypts the secrets encrypted with the JWT key
+func (m *Model) EncryptSecrets(jwtKey string) (int, error) {
+	if !m.secrets.Enabled() {
+		return 0, errors.New("the secrets key has not been set")
+	}
+
+	return m.updateSecrets(func(s storedSecret) (string, bool, error) {
+		if auth.IsEncryptedSecret(s.value) {
+			return "", false, nil
+		}
+
+		plaintext := s.value
+		if s.jwtKeyEncrypted {
+			if jwtKey == "" {
+				return "", false, fmt.Errorf("%s (id %d) is encrypted with the JWT key, it's required to encrypt it with the secrets key", s.column, s.id)
+			}
+			var err error
+			if plaintext, err = auth.DecryptSecret(jwtKey, s.value); err != nil {
+				return "", false, fmt.Errorf("could not decrypt %s (id %d) with the JWT key: %w", s.column, s.id, err)
+			}
+		}
+
+		sealed, err := m.secrets.Seal(plaintext)
+		return sealed, true, err
+	})
+}
+
+// RotateSecretsKey encrypts the secrets again with the new key and returns how many have been encrypted.
+// All the secrets must be encrypted with the current key
+func (m *Model) RotateSecretsKey(newSecrets *auth.SecretBox) (int, error) {
+	if !m.secrets.Enabled() || !newSecrets.Enabled() {
+		return 0, errors.New("both the current and the new secrets keys are required")
+	}
+
+	return m.updateSecrets(func(s storedSecret) (string, bool, error) {
+		if !auth.IsEncryptedSecret(s.value) {
+			return "", false, fmt.Errorf("%s (id %d) is not encrypted with the secrets key, run the encrypt-secrets command first", s.column, s.id)
+		}
+
+		plaintext, err := m.secrets.Open(s.value)
+		if err != nil {
+			return "", false, fmt.Errorf("could not decrypt %s (id %d) with the current key: %w", s.column, s.id, err)
+		}
+
+		sealed, err := newSecrets.Seal(plaintext)
+		return sealed, true, err
+	})
+}
+
+// updateSecrets replaces the stored secrets with the values returned by update in a transaction, so
+// the secrets are never encrypted with different keys
+func (m *Model) updateSecrets(update func(s storedSecret) (string, bool, error)) (int, error) {
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	secrets, err := storedSecrets(ctx, tx.Client())
+	if err != nil {
+		return 0, rollback(tx, err)
+	}
+
+	updated := 0
+	for _, s := range secrets {
+		value, changed, err := update(s)
+		if err != nil {
+			return 0, rollback(tx, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := s.update(ctx, tx.Client(), value); err != nil {
+			return 0, rollback(tx, fmt.Errorf("could not update %s (id %d): %w", s.column, s.id, err))
+		}
+		updated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+func rollback(tx *ent.Tx, err error) error {
+	if rerr := tx.Rollback(); rerr != nil {
+		return fmt.Errorf("%w: could not roll back the transaction: %v", err, rerr)
+	}
+	return err
+}
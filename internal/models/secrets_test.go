@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SecretsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	settingsId int
+	authId     int
+}
+
+var (
+	testSecretsKey    = strings.Repeat("k", auth.MinSecretsKeyLength)
+	testNewSecretsKey = strings.Repeat("n", auth.MinSecretsKeyLength)
+)
+
+func (suite *SecretsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	settings, err := client.Settings.Create().SetSMTPUser("mailer").SetSMTPPassword("smtp-secret").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create settings")
+	suite.settingsId = settings.ID
+
+	bindPassword, err := auth.EncryptSecret("jwt-key", "bind-secret")
+	assert.NoError(suite.T(), err)
+	a, err := client.Authentication.Create().SetLDAPBindDN("cn=openuem").SetLDAPBindPassword(bindPassword).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create authentication settings")
+	suite.authId = a.ID
+}
+
+func (suite *SecretsTestSuite) withKey(key string) *Model {
+	return &Model{Client: suite.model.Client, secrets: auth.NewSecretBox(key)}
+}
+
+func (suite *SecretsTestSuite) TestEncryptSecrets() {
+	pending, err := suite.model.CheckSecrets()
+	assert.NoError(suite.T(), err, "secrets in plaintext don't need a key")
+	assert.Equal(suite.T(), 2, pending)
+
+	_, err = suite.model.EncryptSecrets("jwt-key")
+	assert.Error(suite.T(), err, "should require the secrets key")
+
+	m := suite.withKey(testSecretsKey)
+	_, err = m.EncryptSecrets("")
+	assert.Error(suite.T(), err, "should require the JWT key to decrypt the LDAP bind password")
+	settings, err := m.Client.Settings.Get(context.Background(), suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp-secret", settings.SMTPPassword, "should not encrypt any secret if one fails")
+
+	n, err := m.EncryptSecrets("jwt-key")
+	assert.NoError(suite.T(), err, "should encrypt the secrets")
+	assert.Equal(suite.T(), 2, n)
+
+	settings, err = m.Client.Settings.Get(context.Background(), suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), auth.IsEncryptedSecret(settings.SMTPPassword))
+	assert.NotContains(suite.T(), settings.SMTPPassword, "smtp-secret")
+	password, err := m.GetSMTPPassword(suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp-secret", password)
+
+	a, err := m.Client.Authentication.Get(context.Background(), suite.authId)
+	assert.NoError(suite.T(), err)
+	bindPassword, err := m.OpenSecret(a.LDAPBindPassword)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "bind-secret", bindPassword)
+
+	pending, err = m.CheckSecrets()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, pending)
+
+	n, err = m.EncryptSecrets("jwt-key")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, n, "should not encrypt the secrets twice")
+
+	_, err = suite.model.CheckSecrets()
+	assert.ErrorIs(suite.T(), err, auth.ErrSecretsKeyMissing, "should fail if the key is missing")
+	_, err = suite.withKey(testNewSecretsKey).CheckSecrets()
+	assert.Error(suite.T(), err, "should fail with a different key")
+}
+
+func (suite *SecretsTestSuite) TestRotateSecretsKey() {
+	m := suite.withKey(testSecretsKey)
+	_, err := m.RotateSecretsKey(auth.NewSecretBox(testNewSecretsKey))
+	assert.Error(suite.T(), err, "should require the secrets to be encrypted first")
+
+	_, err = m.EncryptSecrets("jwt-key")
+	assert.NoError(suite.T(), err)
+
+	n, err := m.RotateSecretsKey(auth.NewSecretBox(testNewSecretsKey))
+	assert.NoError(suite.T(), err, "should rotate the key")
+	assert.Equal(suite.T(), 2, n)
+
+	_, err = m.CheckSecrets()
+	assert.Error(suite.T(), err, "the old key should no longer decrypt the secrets")
+
+	rotated := suite.withKey(testNewSecretsKey)
+	pending, err := rotated.CheckSecrets()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, pending)
+	password, err := rotated.GetSMTPPassword(suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp-secret", password)
+}
+
+func (suite *SecretsTestSuite) TestUpdateSMTPSettingsEncryptsPassword() {
+	m := suite.withKey(testSecretsKey)
+	smtp := SMTPSettings{ID: suite.settingsId, Server: "smtp.example.com", Port: 587, User: "mailer", Password: "new-secret", Auth: "PLAIN", MailFrom: "openuem@example.com"}
+	assert.NoError(suite.T(), m.UpdateSMTPSettings(&smtp))
+
+	settings, err := m.Client.Settings.Get(context.Background(), suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), auth.IsEncryptedSecret(settings.SMTPPassword), "should store the password encrypted")
+
+	smtp.Password = ""
+	assert.NoError(suite.T(), m.UpdateSMTPSettings(&smtp))
+	password, err := m.GetSMTPPassword(suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "new-secret", password, "an empty password should keep the stored one")
+
+	smtp.User = ""
+	assert.NoError(suite.T(), m.UpdateSMTPSettings(&smtp))
+	password, err = m.GetSMTPPassword(suite.settingsId)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), password, "removing the user should remove the password")
+}
+
+func TestSecretsTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretsTestSuite))
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/cspviolation"
+	"github.com/open-uem/ent/settings"
+)
+
+// MaxCSPViolations is how many different violations are kept, new ones are ignored until the list is
+// cleared so the public report endpoint can't fill the database
+const MaxCSPViolations = 500
+
+// SecurityHeadersSettings is how the Content Security Policy of the console is sent
+type SecurityHeadersSettings struct {
+	// ContentSecurityPolicy replaces the default policy if it's not empty
+	ContentSecurityPolicy string
+	ReportOnly            bool
+	// FrameAncestors are the origins separated by spaces that can embed the console in an iframe
+	FrameAncestors string
+}
+
+// GetSecurityHeadersSettings returns the global security headers settings, they're read on every
+// request so they're cached
+func (m *Model) GetSecurityHeadersSettings() (SecurityHeadersSettings, error) {
+	return cached(m.Cache, cacheKeySecurityHeaders, func() (SecurityHeadersSettings, error) {
+		s, err := m.Client.Settings.Query().
+			Select(settings.FieldContentSecurityPolicy, settings.FieldContentSecurityPolicyReportOnly, settings.FieldFrameAncestors).
+			Where(settings.Not(settings.HasTenant())).
+			Only(context.Background())
+		if err != nil {
+			return SecurityHeadersSettings{}, err
+		}
+
+		return SecurityHeadersSettings{
+			ContentSecurityPolicy: s.ContentSecurityPolicy,
+			ReportOnly:            s.ContentSecurityPolicyReportOnly,
+			FrameAncestors:        s.FrameAncestors,
+		}, nil
+	})
+}
+
+func (m *Model) SaveSecurityHeadersSettings(s SecurityHeadersSettings) error {
+	defer m.Cache.Invalidate(cacheKeySecurityHeaders)
+
+	return m.Client.Settings.Update().
+		Where(settings.Not(settings.HasTenant())).
+		SetContentSecurityPolicy(s.ContentSecurityPolicy).
+		SetContentSecurityPolicyReportOnly(s.ReportOnly).
+		SetFrameAncestors(s.FrameAncestors).
+		Exec(context.Background())
+}
+
+// SaveCSPViolation counts a violation of the Content Security Policy, the same directive, blocked URI
+// and document are counted together
+func (m *Model) SaveCSPViolation(directive, blockedURI, documentURI string) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	n, err := m.Client.CSPViolation.Update().
+		Where(cspviolation.Directive(directive), cspviolation.BlockedURI(blockedURI), cspviolation.DocumentURI(documentURI)).
+		AddCount(1).
+		SetLastSeen(now).
+		Save(ctx)
+	if err != nil || n > 0 {
+		return err
+	}
+
+	total, err := m.Client.CSPViolation.Query().Count(ctx)
+	if err != nil {
+		return err
+	}
+	if total >= MaxCSPViolations {
+		return nil
+	}
+
+	return m.Client.CSPViolation.Create().
+		SetDirective(directive).
+		SetBlockedURI(blockedURI).
+		SetDocumentURI(documentURI).
+		SetCount(1).
+		SetFirstSeen(now).
+		SetLastSeen(now).
+		Exec(ctx)
+}
+
+// GetCSPViolations returns the most reported violations first
+func (m *Model) GetCSPViolations(limit int) ([]*openuem_ent.CSPViolation, error) {
+	return m.Client.CSPViolation.Query().
+		Order(openuem_ent.Desc(cspviolation.FieldCount), openuem_ent.Desc(cspviolation.FieldLastSeen)).
+		Limit(limit).
+		All(context.Background())
+}
+
+func (m *Model) DeleteCSPViolations() error {
+	_, err := m.Client.CSPViolation.Delete().Exec(context.Background())
+	return err
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeadersSettings(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:securityheaders?mode=memory&_fk=1")
+	defer client.Close()
+
+	m := Model{Client: client, Cache: NewCache(DefaultCacheTTL)}
+	assert.NoError(t, client.Settings.Create().Exec(context.Background()))
+
+	s, err := m.GetSecurityHeadersSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityHeadersSettings{}, s, "should use the default policy")
+
+	want := SecurityHeadersSettings{ContentSecurityPolicy: "default-src 'self'", ReportOnly: true, FrameAncestors: "https://intranet.example.com"}
+	assert.NoError(t, m.SaveSecurityHeadersSettings(want))
+
+	s, err = m.GetSecurityHeadersSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, want, s, "saving should invalidate the cached settings")
+}
+
+func TestSaveCSPViolation(t *testing.T) {
+	client := enttest.Open(t, "sqlite3", "file:cspviolations?mode=memory&_fk=1")
+	defer client.Close()
+
+	m := Model{Client: client}
+	for range 3 {
+		assert.NoError(t, m.SaveCSPViolation("script-src-elem", "https://cdn.example.com/lib.js", "https://console.example.com/agents"))
+	}
+	assert.NoError(t, m.SaveCSPViolation("style-src-attr", "inline", "https://console.example.com/agents"))
+
+	violations, err := m.GetCSPViolations(10)
+	assert.NoError(t, err)
+	if assert.Len(t, violations, 2) {
+		assert.Equal(t, "script-src-elem", violations[0].Directive, "should return the most reported first")
+		assert.Equal(t, 3, violations[0].Count)
+		assert.Equal(t, 1, violations[1].Count)
+	}
+
+	assert.NoError(t, m.DeleteCSPViolations())
+	violations, err = m.GetCSPViolations(10)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
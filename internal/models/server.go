@@ -108,11 +108,15 @@ func (m *Model) GetServerById(serverId int) (*ent.Server, error) {
 }
 
 func (m *Model) DeleteServer(serverId int) error {
+	defer m.Cache.Invalidate(cacheKeyServers)
+
 	return m.Client.Server.DeleteOneID(serverId).Exec(context.Background())
 }
 
 func (m *Model) ServersExists() (bool, error) {
-	return m.Client.Server.Query().Exist(context.Background())
+	return cached(m.Cache, cacheKeyServers+"-exist", func() (bool, error) {
+		return m.Client.Server.Query().Exist(context.Background())
+	})
 }
 
 func applyServerFilters(query *ent.ServerQuery, f filters.UpdateServersFilter) {
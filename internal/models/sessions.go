@@ -5,6 +5,7 @@ import (
 
 	ent "github.com/open-uem/ent"
 	"github.com/open-uem/ent/sessions"
+	"github.com/open-uem/ent/user"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
@@ -59,3 +60,9 @@ func (m *Model) DeleteSession(token string) error {
 	}
 	return nil
 }
+
+// DeleteUserSessions closes all the sessions of the user
+func (m *Model) DeleteUserSessions(uid string) error {
+	_, err := m.Client.Sessions.Delete().Where(sessions.HasOwnerWith(user.ID(uid))).Exec(context.Background())
+	return err
+}
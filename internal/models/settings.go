@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"strconv"
+	"time"
 
 	openuem_ent "github.com/open-uem/ent"
 	"github.com/open-uem/ent/settings"
@@ -31,6 +32,19 @@ type GeneralSettings struct {
 	AutoAdmitAgents          bool
 	NetBird                  bool
 	ItemsPerPage             int
+	Timezone                 string
+	DateFormat               string
+	SiteAssignment           string
+	LogCollectionTimeout     int
+	AgentLateThreshold       int
+	AgentOfflineThreshold    int
+}
+
+// DateTimeSettings are the timezone and the date format used to show dates and to read the scheduled
+// dates, an empty value is inherited from the global settings or the server
+type DateTimeSettings struct {
+	Timezone   string
+	DateFormat string
 }
 
 func (m *Model) GetMaxUploadSize() (string, error) {
@@ -350,6 +364,8 @@ func (m *Model) GetGeneralSettings(tenantID string) (*openuem_ent.Settings, erro
 			settings.FieldSessionLifetimeInMinutes,
 			settings.FieldUpdateChannel,
 			settings.FieldAgentReportFrequenceInMinutes,
+			settings.FieldAgentLateThresholdInHours,
+			settings.FieldAgentOfflineThresholdInHours,
 			settings.FieldRequestVncPin,
 			settings.FieldProfilesApplicationFrequenceInMinutes,
 			settings.FieldUseWinget,
@@ -359,6 +375,9 @@ func (m *Model) GetGeneralSettings(tenantID string) (*openuem_ent.Settings, erro
 			settings.FieldDisableRemoteAssistance,
 			settings.FieldDetectRemoteAgents,
 			settings.FieldAutoAdmitAgents,
+			settings.FieldTimezone,
+			settings.FieldDateFormat,
+			settings.FieldAgentLogCollectionTimeoutInHours,
 			settings.TagColumn,
 		).Where(settings.Not(settings.HasTenantWith()))
 	} else {
@@ -370,6 +389,8 @@ func (m *Model) GetGeneralSettings(tenantID string) (*openuem_ent.Settings, erro
 		query = m.Client.Settings.Query().WithTag().Select(
 			settings.FieldID,
 			settings.FieldAgentReportFrequenceInMinutes,
+			settings.FieldAgentLateThresholdInHours,
+			settings.FieldAgentOfflineThresholdInHours,
 			settings.FieldRequestVncPin,
 			settings.FieldProfilesApplicationFrequenceInMinutes,
 			settings.FieldUseWinget,
@@ -379,6 +400,9 @@ func (m *Model) GetGeneralSettings(tenantID string) (*openuem_ent.Settings, erro
 			settings.FieldDisableRemoteAssistance,
 			settings.FieldDetectRemoteAgents,
 			settings.FieldAutoAdmitAgents,
+			settings.FieldTimezone,
+			settings.FieldDateFormat,
+			settings.FieldSiteAssignment,
 			settings.TagColumn,
 		).Where(settings.HasTenantWith(tenant.ID(id)))
 	}
@@ -529,6 +553,23 @@ func (m *Model) UpdateDefaultItemsPerPageSetting(settingsId, itemsPerPage int) e
 	return m.Client.Settings.UpdateOneID(settingsId).SetDefaultItemsPerPage(itemsPerPage).Exec(context.Background())
 }
 
+// GetAgentLogCollectionTimeout returns how long a log collection waits for an offline agent
+func (m *Model) GetAgentLogCollectionTimeout() (time.Duration, error) {
+	s, err := m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Select(settings.FieldAgentLogCollectionTimeoutInHours).Only(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	if s.AgentLogCollectionTimeoutInHours <= 0 {
+		return DefaultAgentLogCollectionTimeout, nil
+	}
+	return time.Duration(s.AgentLogCollectionTimeoutInHours) * time.Hour, nil
+}
+
+func (m *Model) UpdateAgentLogCollectionTimeout(settingsId, hours int) error {
+	return m.Client.Settings.UpdateOneID(settingsId).SetAgentLogCollectionTimeoutInHours(hours).Exec(context.Background())
+}
+
 func (m *Model) CloneGlobalSettings(tenantID int) error {
 	s, err := m.Client.Settings.Query().WithTag().Where(settings.Not(settings.HasTenant())).Only(context.Background())
 	if err != nil {
@@ -537,6 +578,8 @@ func (m *Model) CloneGlobalSettings(tenantID int) error {
 
 	query := m.Client.Settings.Create().
 		SetAgentReportFrequenceInMinutes(s.AgentReportFrequenceInMinutes).
+		SetAgentLateThresholdInHours(s.AgentLateThresholdInHours).
+		SetAgentOfflineThresholdInHours(s.AgentOfflineThresholdInHours).
 		SetAutoAdmitAgents(s.AutoAdmitAgents).
 		SetCountry(s.Country).
 		SetDetectRemoteAgents(s.DetectRemoteAgents).
@@ -544,24 +587,18 @@ func (m *Model) CloneGlobalSettings(tenantID int) error {
 		SetDisableSftp(s.DisableSftp).
 		SetMaxUploadSize(s.MaxUploadSize).
 		SetNatsRequestTimeoutSeconds(s.NatsRequestTimeoutSeconds).
-		SetMessageFrom(s.MessageFrom).
 		SetProfilesApplicationFrequenceInMinutes(s.ProfilesApplicationFrequenceInMinutes).
 		SetRefreshTimeInMinutes(s.RefreshTimeInMinutes).
 		SetDefaultItemsPerPage(s.DefaultItemsPerPage).
 		SetRequestVncPin(s.RequestVncPin).
-		SetSMTPAuth(s.SMTPAuth).
-		SetSMTPPassword(s.SMTPPassword).
-		SetSMTPPort(s.SMTPPort).
-		SetSMTPServer(s.SMTPServer).
-		SetSMTPStarttls(s.SMTPStarttls).
-		SetSMTPTLS(s.SMTPTLS).
-		SetSMTPUser(s.SMTPUser).
 		SetSessionLifetimeInMinutes(s.SessionLifetimeInMinutes).
 		SetUpdateChannel(s.UpdateChannel).
 		SetUseFlatpak(s.UseFlatpak).
 		SetUseBrew(s.UseBrew).
 		SetUseWinget(s.UseWinget).
 		SetUserCertYearsValid(s.UserCertYearsValid).
+		SetTimezone(s.Timezone).
+		SetDateFormat(s.DateFormat).
 		SetTenantID(tenantID)
 
 	if s.Edges.Tag != nil {
@@ -579,6 +616,8 @@ func (m *Model) ApplyGlobalSettings(tenantID int) error {
 
 	query := m.Client.Settings.Update().Where(settings.HasTenantWith(tenant.ID(tenantID))).
 		SetAgentReportFrequenceInMinutes(s.AgentReportFrequenceInMinutes).
+		SetAgentLateThresholdInHours(s.AgentLateThresholdInHours).
+		SetAgentOfflineThresholdInHours(s.AgentOfflineThresholdInHours).
 		SetAutoAdmitAgents(s.AutoAdmitAgents).
 		SetCountry(s.Country).
 		SetDetectRemoteAgents(s.DetectRemoteAgents).
@@ -586,24 +625,18 @@ func (m *Model) ApplyGlobalSettings(tenantID int) error {
 		SetDisableSftp(s.DisableSftp).
 		SetMaxUploadSize(s.MaxUploadSize).
 		SetNatsRequestTimeoutSeconds(s.NatsRequestTimeoutSeconds).
-		SetMessageFrom(s.MessageFrom).
 		SetProfilesApplicationFrequenceInMinutes(s.ProfilesApplicationFrequenceInMinutes).
 		SetRefreshTimeInMinutes(s.RefreshTimeInMinutes).
 		SetDefaultItemsPerPage(s.DefaultItemsPerPage).
 		SetRequestVncPin(s.RequestVncPin).
-		SetSMTPAuth(s.SMTPAuth).
-		SetSMTPPassword(s.SMTPPassword).
-		SetSMTPPort(s.SMTPPort).
-		SetSMTPServer(s.SMTPServer).
-		SetSMTPStarttls(s.SMTPStarttls).
-		SetSMTPTLS(s.SMTPTLS).
-		SetSMTPUser(s.SMTPUser).
 		SetSessionLifetimeInMinutes(s.SessionLifetimeInMinutes).
 		SetUpdateChannel(s.UpdateChannel).
 		SetUseFlatpak(s.UseFlatpak).
 		SetUseBrew(s.UseBrew).
 		SetUseWinget(s.UseWinget).
 		SetUserCertYearsValid(s.UserCertYearsValid).
+		SetTimezone(s.Timezone).
+		SetDateFormat(s.DateFormat).
 		SetTenantID(tenantID)
 
 	query = query.ClearTag()
@@ -614,3 +647,58 @@ func (m *Model) ApplyGlobalSettings(tenantID int) error {
 
 	return query.Exec(context.Background())
 }
+
+// GetDateTimeSettings returns the timezone and the date format of the tenant, the values the tenant
+// leaves empty are taken from the global settings. They're used on every page so they're cached
+func (m *Model) GetDateTimeSettings(tenantID string) (DateTimeSettings, error) {
+	return cached(m.Cache, cacheKeyDateTime+"-"+tenantID, func() (DateTimeSettings, error) {
+		global, err := m.Client.Settings.Query().
+			Select(settings.FieldTimezone, settings.FieldDateFormat).
+			Where(settings.Not(settings.HasTenant())).
+			Only(context.Background())
+		if err != nil {
+			return DateTimeSettings{}, err
+		}
+
+		s := DateTimeSettings{Timezone: global.Timezone, DateFormat: global.DateFormat}
+		if tenantID == "-1" {
+			return s, nil
+		}
+
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			return DateTimeSettings{}, err
+		}
+
+		t, err := m.Client.Settings.Query().
+			Select(settings.FieldTimezone, settings.FieldDateFormat).
+			Where(settings.HasTenantWith(tenant.ID(id))).
+			Only(context.Background())
+		if err != nil {
+			if openuem_ent.IsNotFound(err) {
+				return s, nil
+			}
+			return DateTimeSettings{}, err
+		}
+
+		if t.Timezone != "" {
+			s.Timezone = t.Timezone
+		}
+		if t.DateFormat != "" {
+			s.DateFormat = t.DateFormat
+		}
+		return s, nil
+	})
+}
+
+func (m *Model) UpdateTimezoneSetting(settingsId int, timezone string) error {
+	defer m.Cache.Invalidate(cacheKeyDateTime)
+
+	return m.Client.Settings.UpdateOneID(settingsId).SetTimezone(timezone).Exec(context.Background())
+}
+
+func (m *Model) UpdateDateFormatSetting(settingsId int, dateFormat string) error {
+	defer m.Cache.Invalidate(cacheKeyDateTime)
+
+	return m.Client.Settings.UpdateOneID(settingsId).SetDateFormat(dateFormat).Exec(context.Background())
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"strconv"
 	"testing"
 
 	"github.com/open-uem/ent/enttest"
@@ -216,6 +217,35 @@ func (suite *SettingsTestSuite) TestCreateInitialSettings() {
 	assert.Equal(suite.T(), true, settings.RequestVncPin, "default request vnc pin should be true")
 }
 
+func (suite *SettingsTestSuite) TestGetDateTimeSettings() {
+	s, err := suite.model.GetDateTimeSettings("-1")
+	assert.NoError(suite.T(), err, "should get global date and time settings")
+	assert.Equal(suite.T(), DateTimeSettings{}, s, "by default the server time and the language of the user should be used")
+
+	err = suite.model.UpdateTimezoneSetting(suite.settingsId, "America/New_York")
+	assert.NoError(suite.T(), err, "should update timezone setting")
+	err = suite.model.UpdateDateFormatSetting(suite.settingsId, "mdy")
+	assert.NoError(suite.T(), err, "should update date format setting")
+
+	tenant, err := suite.model.Client.Tenant.Create().SetDescription("Tenant").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tenant")
+	err = suite.model.CloneGlobalSettings(tenant.ID)
+	assert.NoError(suite.T(), err, "should clone global settings")
+
+	tenantSettings, err := suite.model.GetGeneralSettings(strconv.Itoa(tenant.ID))
+	assert.NoError(suite.T(), err, "should get tenant settings")
+	assert.Equal(suite.T(), "America/New_York", tenantSettings.Timezone, "tenant should get the global timezone")
+
+	err = suite.model.UpdateTimezoneSetting(tenantSettings.ID, "Europe/Madrid")
+	assert.NoError(suite.T(), err, "should update tenant timezone setting")
+	err = suite.model.UpdateDateFormatSetting(tenantSettings.ID, "")
+	assert.NoError(suite.T(), err, "should clear tenant date format setting")
+
+	s, err = suite.model.GetDateTimeSettings(strconv.Itoa(tenant.ID))
+	assert.NoError(suite.T(), err, "should get tenant date and time settings")
+	assert.Equal(suite.T(), DateTimeSettings{Timezone: "Europe/Madrid", DateFormat: "mdy"}, s, "tenant should inherit the empty date format")
+}
+
 func TestSettingsTestSuite(t *testing.T) {
 	suite.Run(t, new(SettingsTestSuite))
 }
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/authentication"
+	"github.com/open-uem/ent/branding"
+)
+
+// singletonKey is the value of the unique singleton column of the global settings that must have only one
+// row, so a concurrent creation conflicts instead of adding a second row
+const singletonKey = "global"
+
+// MigrateSingletons merges the branding and the authentication settings created twice by concurrent
+// requests into the oldest row, and sets the singleton key on it so they can't be duplicated again
+func (m *Model) MigrateSingletons() error {
+	if err := m.mergeBrandings(); err != nil {
+		return err
+	}
+	return m.mergeAuthentications()
+}
+
+// mergeBrandings keeps the oldest branding, the one GetBranding has been returning, and fills its empty
+// fields with the values of the other brandings in the order they were created
+func (m *Model) mergeBrandings() error {
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	brandings, err := tx.Branding.Query().Order(ent.Asc(branding.FieldID)).All(ctx)
+	if err != nil {
+		return rollback(tx, err)
+	}
+	if len(brandings) == 0 || (len(brandings) == 1 && brandings[0].Singleton != nil) {
+		return rollback(tx, nil)
+	}
+
+	kept := brandings[0]
+	update := tx.Branding.UpdateOneID(kept.ID).SetSingleton(singletonKey)
+	duplicates := []int{}
+	for _, b := range brandings[1:] {
+		duplicates = append(duplicates, b.ID)
+
+		if kept.LogoLight == "" && b.LogoLight != "" {
+			kept.LogoLight = b.LogoLight
+			update.SetLogoLight(b.LogoLight)
+		}
+		if kept.LogoSmall == "" && b.LogoSmall != "" {
+			kept.LogoSmall = b.LogoSmall
+			update.SetLogoSmall(b.LogoSmall)
+		}
+		if kept.LoginBackgroundImage == "" && b.LoginBackgroundImage != "" {
+			kept.LoginBackgroundImage = b.LoginBackgroundImage
+			update.SetLoginBackgroundImage(b.LoginBackgroundImage)
+		}
+		if kept.LoginWelcomeText == "" && b.LoginWelcomeText != "" {
+			kept.LoginWelcomeText = b.LoginWelcomeText
+			update.SetLoginWelcomeText(b.LoginWelcomeText)
+		}
+		if kept.BugReportLink == "" && b.BugReportLink != "" {
+			kept.BugReportLink = b.BugReportLink
+			update.SetBugReportLink(b.BugReportLink)
+		}
+		if kept.HelpLink == "" && b.HelpLink != "" {
+			kept.HelpLink = b.HelpLink
+			update.SetHelpLink(b.HelpLink)
+		}
+	}
+
+	// The duplicates are removed first so the singleton key doesn't conflict with one of them
+	if len(duplicates) > 0 {
+		if _, err := tx.Branding.Delete().Where(branding.IDIn(duplicates...)).Exec(ctx); err != nil {
+			return rollback(tx, err)
+		}
+	}
+
+	if err := update.Exec(ctx); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// mergeAuthentications keeps the oldest authentication settings. The duplicates can't have been changed
+// as the settings are saved only when there's one row
+func (m *Model) mergeAuthentications() error {
+	ctx := context.Background()
+
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	settings, err := tx.Authentication.Query().Order(ent.Asc(authentication.FieldID)).All(ctx)
+	if err != nil {
+		return rollback(tx, err)
+	}
+	if len(settings) == 0 || (len(settings) == 1 && settings[0].Singleton != nil) {
+		return rollback(tx, nil)
+	}
+
+	if len(settings) > 1 {
+		if _, err := tx.Authentication.Delete().Where(authentication.IDGT(settings[0].ID)).Exec(ctx); err != nil {
+			return rollback(tx, err)
+		}
+	}
+
+	if err := tx.Authentication.UpdateOneID(settings[0].ID).SetSingleton(singletonKey).Exec(ctx); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
@@ -27,7 +27,9 @@ func (m *Model) GetDefaultSite(t *ent.Tenant) (*ent.Site, error) {
 }
 
 func (m *Model) GetAssociatedSites(t *ent.Tenant) ([]*ent.Site, error) {
-	return m.Client.Site.Query().Where(site.HasTenantWith(tenant.ID(t.ID))).All(context.Background())
+	return cached(m.Cache, cacheKeySites+"-tenant-"+strconv.Itoa(t.ID), func() ([]*ent.Site, error) {
+		return m.Client.Site.Query().Where(site.HasTenantWith(tenant.ID(t.ID))).All(context.Background())
+	})
 }
 
 // GetSite returns a site by ID with tenant validation
@@ -159,7 +161,27 @@ func applySitesFilter(query *ent.SiteQuery, f filters.SiteFilter) {
 	}
 }
 
-func (m *Model) AddSite(tenantID int, name string, isDefault bool, domain string, catalogRing string) error {
+// SiteLogLevel returns the log level of the agents of a site, info if it's not set
+func SiteLogLevel(logLevel string) (site.LogLevel, error) {
+	if logLevel == "" {
+		return site.LogLevelInfo, nil
+	}
+
+	l := site.LogLevel(logLevel)
+	if err := site.LogLevelValidator(l); err != nil {
+		return "", err
+	}
+	return l, nil
+}
+
+func (m *Model) AddSite(tenantID int, name string, isDefault bool, domain string, catalogRing string, logLevel string, networks []string) error {
+	defer m.Cache.Invalidate(cacheKeySites)
+
+	level, err := SiteLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
 	if isDefault {
 		// Remove the is default property for existing sites
 		if err := m.Client.Site.Update().Where(site.HasTenantWith(tenant.ID(tenantID))).SetIsDefault(false).Exec(context.Background()); err != nil {
@@ -167,16 +189,22 @@ func (m *Model) AddSite(tenantID int, name string, isDefault bool, domain string
 		}
 	}
 
-	creator := m.Client.Site.Create().SetDescription(name).SetIsDefault(isDefault).SetDomain(domain).SetTenantID(tenantID)
+	creator := m.Client.Site.Create().SetDescription(name).SetIsDefault(isDefault).SetDomain(domain).SetLogLevel(level).SetNetworks(networks).SetTenantID(tenantID)
 	if catalogRing != "" {
 		creator = creator.SetCatalogRing(catalogRing)
 	}
 	return creator.Exec(context.Background())
 }
 
-func (m *Model) UpdateSite(tenantID int, siteID int, desc string, domain string, isDefault bool, catalogRing string) error {
+func (m *Model) UpdateSite(tenantID int, siteID int, desc string, domain string, isDefault bool, catalogRing string, logLevel string, networks []string) error {
+	defer m.Cache.Invalidate(cacheKeySites)
+
+	level, err := SiteLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
 
-	query := m.Client.Site.Update().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).SetDescription(desc).SetDomain(domain)
+	query := m.Client.Site.Update().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).SetDescription(desc).SetDomain(domain).SetLogLevel(level).SetNetworks(networks)
 
 	if catalogRing != "" {
 		query = query.SetCatalogRing(catalogRing)
@@ -202,6 +230,8 @@ func (m *Model) UpdateSite(tenantID int, siteID int, desc string, domain string,
 }
 
 func (m *Model) DeleteSite(tenantID int, siteID int) error {
+	defer m.Cache.Invalidate(cacheKeySites)
+
 	_, err := m.Client.Site.Delete().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
 	return err
 }
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+)
+
+// Modes of the site assignment rules of a tenant. With the rules disabled the networks of the sites are
+// only used to report the misplaced agents, with admission the agents are moved to the site of their
+// network when they're admitted and with confirm an operator confirms every move in the report
+const (
+	SiteAssignmentDisabled  = ""
+	SiteAssignmentAdmission = "admission"
+	SiteAssignmentConfirm   = "confirm"
+)
+
+// SiteAssignmentModes are the modes in the order they're offered in the settings
+var SiteAssignmentModes = []string{SiteAssignmentDisabled, SiteAssignmentAdmission, SiteAssignmentConfirm}
+
+// ErrInvalidNetwork is returned when a network of a site isn't in CIDR notation
+var ErrInvalidNetwork = errors.New("the network must be in CIDR notation, like 192.168.1.0/24")
+
+// SiteNetworkMatch is a site with a network that contains the IP address of an agent
+type SiteNetworkMatch struct {
+	SiteID   int    `json:"site_id"`
+	SiteName string `json:"site_name"`
+	Network  string `json:"network"`
+}
+
+// SiteNetworkConflict is a network of a site that overlaps a network of another site. The agents in
+// both networks aren't assigned automatically, an operator has to choose their site
+type SiteNetworkConflict struct {
+	SiteName      string `json:"site_name"`
+	Network       string `json:"network"`
+	OtherSiteName string `json:"other_site_name"`
+	OtherNetwork  string `json:"other_network"`
+}
+
+// MisplacedAgent is an agent whose IP address isn't in the networks of its site but in the networks of other sites
+type MisplacedAgent struct {
+	AgentID  string             `json:"agent_id"`
+	Hostname string             `json:"hostname"`
+	IP       string             `json:"ip"`
+	SiteID   int                `json:"site_id"`
+	SiteName string             `json:"site_name"`
+	Matches  []SiteNetworkMatch `json:"matches"`
+}
+
+// Conflict returns true if the agent is in the networks of more than one site
+func (a MisplacedAgent) Conflict() bool {
+	return len(a.Matches) > 1
+}
+
+// IsValidSiteAssignmentMode returns true if the mode is one of the site assignment modes
+func IsValidSiteAssignmentMode(mode string) bool {
+	return slices.Contains(SiteAssignmentModes, mode)
+}
+
+// ParseSiteNetworks reads the networks of a site separated by commas or new lines. The networks
+// are stored without host bits, 10.0.1.1/16 is 10.0.0.0/16, and without duplicates
+func ParseSiteNetworks(value string) ([]string, error) {
+	networks := []string{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || unicode.IsSpace(r) }) {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidNetwork, entry)
+		}
+
+		network := prefix.Masked().String()
+		if !slices.Contains(networks, network) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+// MatchSiteNetworks returns the sites with a network that contains the IP address, with the most specific
+// network of each site. An agent can only be assigned automatically if a single site matches
+func MatchSiteNetworks(ip string, sites []*ent.Site) []SiteNetworkMatch {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	matches := []SiteNetworkMatch{}
+	for _, s := range sites {
+		best := netip.Prefix{}
+		for _, n := range s.Networks {
+			prefix, err := netip.ParsePrefix(n)
+			if err != nil || !prefix.Contains(addr) {
+				continue
+			}
+			if !best.IsValid() || prefix.Bits() > best.Bits() {
+				best = prefix
+			}
+		}
+
+		if best.IsValid() {
+			matches = append(matches, SiteNetworkMatch{SiteID: s.ID, SiteName: s.Description, Network: best.String()})
+		}
+	}
+	return matches
+}
+
+// SiteNetworkConflicts returns the networks that overlap a network of another site
+func SiteNetworkConflicts(sites []*ent.Site) []SiteNetworkConflict {
+	conflicts := []SiteNetworkConflict{}
+	for i, s := range sites {
+		for _, other := range sites[i+1:] {
+			for _, n := range s.Networks {
+				prefix, err := netip.ParsePrefix(n)
+				if err != nil {
+					continue
+				}
+				for _, o := range other.Networks {
+					otherPrefix, err := netip.ParsePrefix(o)
+					if err != nil || !prefix.Overlaps(otherPrefix) {
+						continue
+					}
+					conflicts = append(conflicts, SiteNetworkConflict{SiteName: s.Description, Network: n, OtherSiteName: other.Description, OtherNetwork: o})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// GetMisplacedAgents returns the agents of the tenant, or of the site, whose IP address is in the networks of
+// other sites and not in the networks of their site, sorted by hostname
+func (m *Model) GetMisplacedAgents(c *partials.CommonInfo) ([]MisplacedAgent, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	sites, err := m.GetSites(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(sites, func(s *ent.Site) bool { return len(s.Networks) > 0 }) {
+		return []MisplacedAgent{}, nil
+	}
+
+	query := m.Client.Agent.Query().WithSite().
+		Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.AgentStatusNEQ(agent.AgentStatusDecommissioned)).
+		Order(ent.Asc(agent.FieldHostname))
+	if siteID == -1 {
+		query = query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query = query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	agents, err := query.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	misplaced := []MisplacedAgent{}
+	for _, a := range agents {
+		if len(a.Edges.Site) != 1 {
+			continue
+		}
+		current := a.Edges.Site[0]
+
+		matches := MatchSiteNetworks(a.IP, sites)
+		if len(matches) == 0 || slices.ContainsFunc(matches, func(match SiteNetworkMatch) bool { return match.SiteID == current.ID }) {
+			continue
+		}
+
+		misplaced = append(misplaced, MisplacedAgent{
+			AgentID:  a.ID,
+			Hostname: a.Hostname,
+			IP:       a.IP,
+			SiteID:   current.ID,
+			SiteName: current.Description,
+			Matches:  matches,
+		})
+	}
+	return misplaced, nil
+}
+
+// MigrateAgentToSite moves an agent of the tenant to another site of the same tenant. It returns
+// ErrNotFound if the agent or the site don't belong to the tenant
+func (m *Model) MigrateAgentToSite(agentID string, targetSiteID, tenantID int) error {
+	defer m.Cache.Invalidate(cacheKeyAgents)
+
+	exists, err := m.Client.Site.Query().Where(site.ID(targetSiteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	a, err := m.Client.Agent.Query().WithSite().Where(agent.ID(agentID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Only(context.Background())
+	if err != nil {
+		return dbError(err)
+	}
+
+	query := m.Client.Agent.UpdateOneID(agentID)
+	inSite := false
+	for _, s := range a.Edges.Site {
+		if s.ID == targetSiteID {
+			inSite = true
+			continue
+		}
+		query.RemoveSiteIDs(s.ID)
+	}
+	if !inSite {
+		query.AddSiteIDs(targetSiteID)
+	}
+	return query.Exec(context.Background())
+}
+
+// GetSiteAssignmentMode returns how the agents of the tenant are assigned to the site of their network
+func (m *Model) GetSiteAssignmentMode(tenantID int) (string, error) {
+	s, err := m.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(tenantID))).Select(settings.FieldSiteAssignment).Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return SiteAssignmentDisabled, nil
+		}
+		return "", err
+	}
+	return s.SiteAssignment, nil
+}
+
+func (m *Model) UpdateSiteAssignmentMode(settingsId int, mode string) error {
+	return m.Client.Settings.UpdateOneID(settingsId).SetSiteAssignment(mode).Exec(context.Background())
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestParseSiteNetworks(t *testing.T) {
+	networks, err := ParseSiteNetworks("192.168.1.0/24, 10.0.1.1/16\n10.0.0.0/16;2001:db8::/32")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.0/16", "2001:db8::/32"}, networks, "should remove the host bits and the duplicates")
+
+	networks, err = ParseSiteNetworks("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, networks, "should accept a site without networks")
+
+	_, err = ParseSiteNetworks("192.168.1.0/24, 192.168.300.0/24")
+	assert.ErrorIs(t, err, ErrInvalidNetwork)
+	assert.Contains(t, err.Error(), "192.168.300.0/24", "should report the invalid network")
+
+	_, err = ParseSiteNetworks("192.168.1.1")
+	assert.ErrorIs(t, err, ErrInvalidNetwork, "should require the prefix length")
+}
+
+func TestMatchSiteNetworks(t *testing.T) {
+	sites := []*openuem_ent.Site{
+		{ID: 1, Description: "Madrid", Networks: []string{"10.0.0.0/8", "10.1.0.0/16"}},
+		{ID: 2, Description: "Berlin", Networks: []string{"192.168.1.0/24"}},
+		{ID: 3, Description: "Lab", Networks: []string{"10.1.2.0/24"}},
+		{ID: 4, Description: "Empty"},
+	}
+
+	assert.Equal(t, []SiteNetworkMatch{{SiteID: 2, SiteName: "Berlin", Network: "192.168.1.0/24"}}, MatchSiteNetworks("192.168.1.20", sites))
+	assert.Equal(t, []SiteNetworkMatch{{SiteID: 2, SiteName: "Berlin", Network: "192.168.1.0/24"}}, MatchSiteNetworks("::ffff:192.168.1.20", sites), "should match IPv4-mapped addresses")
+	assert.Equal(t, []SiteNetworkMatch{{SiteID: 1, SiteName: "Madrid", Network: "10.1.0.0/16"}}, MatchSiteNetworks("10.1.5.1", sites), "should use the most specific network of the site")
+	assert.Len(t, MatchSiteNetworks("10.1.2.3", sites), 2, "should return every site whose networks contain the address")
+	assert.Empty(t, MatchSiteNetworks("172.16.0.1", sites))
+	assert.Empty(t, MatchSiteNetworks("", sites), "should ignore agents without IP address")
+}
+
+func TestSiteNetworkConflicts(t *testing.T) {
+	sites := []*openuem_ent.Site{
+		{ID: 1, Description: "Madrid", Networks: []string{"10.0.0.0/8", "10.1.0.0/16"}},
+		{ID: 2, Description: "Berlin", Networks: []string{"192.168.1.0/24"}},
+		{ID: 3, Description: "Lab", Networks: []string{"10.1.2.0/24"}},
+	}
+
+	conflicts := SiteNetworkConflicts(sites)
+	assert.Equal(t, []SiteNetworkConflict{
+		{SiteName: "Madrid", Network: "10.0.0.0/8", OtherSiteName: "Lab", OtherNetwork: "10.1.2.0/24"},
+		{SiteName: "Madrid", Network: "10.1.0.0/16", OtherSiteName: "Lab", OtherNetwork: "10.1.2.0/24"},
+	}, conflicts, "should report the overlaps between sites but not within a site")
+
+	assert.Empty(t, SiteNetworkConflicts(sites[:2]))
+}
+
+type SiteNetworksTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	madridID   int
+	berlinID   int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *SiteNetworksTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	madrid, err := client.Site.Create().SetDescription("Madrid").SetNetworks([]string{"10.0.0.0/16"}).SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site")
+	suite.madridID = madrid.ID
+
+	berlin, err := client.Site.Create().SetDescription("Berlin").SetNetworks([]string{"10.1.0.0/16"}).SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site")
+	suite.berlinID = berlin.ID
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: "-1"}
+
+	for id, a := range map[string]struct {
+		ip     string
+		siteID int
+		status agent.AgentStatus
+	}{
+		"in-place":  {ip: "10.0.0.5", siteID: madrid.ID, status: agent.AgentStatusEnabled},
+		"misplaced": {ip: "10.1.0.5", siteID: madrid.ID, status: agent.AgentStatusEnabled},
+		"unknown":   {ip: "172.16.0.5", siteID: berlin.ID, status: agent.AgentStatusEnabled},
+		"waiting":   {ip: "10.0.0.6", siteID: berlin.ID, status: agent.AgentStatusWaitingForAdmission},
+	} {
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).SetIP(a.ip).SetAgentStatus(a.status).AddSiteIDs(a.siteID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *SiteNetworksTestSuite) TestGetMisplacedAgents() {
+	misplaced, err := suite.model.GetMisplacedAgents(suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []MisplacedAgent{{
+		AgentID:  "misplaced",
+		Hostname: "misplaced",
+		IP:       "10.1.0.5",
+		SiteID:   suite.madridID,
+		SiteName: "Madrid",
+		Matches:  []SiteNetworkMatch{{SiteID: suite.berlinID, SiteName: "Berlin", Network: "10.1.0.0/16"}},
+	}}, misplaced, "should only report admitted agents in the networks of another site")
+
+	misplaced, err = suite.model.GetMisplacedAgents(&partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: strconv.Itoa(suite.berlinID)})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), misplaced, "should only report the agents of the site")
+}
+
+func (suite *SiteNetworksTestSuite) TestMigrateAgentToSite() {
+	err := suite.model.MigrateAgentToSite("misplaced", suite.berlinID, suite.tenantID)
+	assert.NoError(suite.T(), err, "should move the agent")
+
+	a, err := suite.model.Client.Agent.Query().WithSite().Where(agent.ID("misplaced")).Only(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), a.Edges.Site, 1)
+	assert.Equal(suite.T(), suite.berlinID, a.Edges.Site[0].ID, "should be in the new site")
+
+	misplaced, err := suite.model.GetMisplacedAgents(suite.commonInfo)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), misplaced, "should not be misplaced anymore")
+
+	err = suite.model.MigrateAgentToSite("misplaced", suite.berlinID, suite.tenantID)
+	assert.NoError(suite.T(), err, "should keep the agent in its site")
+
+	err = suite.model.MigrateAgentToSite("misplaced", suite.berlinID, suite.tenantID+1)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not move agents to sites of other tenants")
+
+	err = suite.model.MigrateAgentToSite("unknown", suite.berlinID, suite.tenantID)
+	assert.ErrorIs(suite.T(), err, ErrNotFound, "should not move agents of other tenants")
+}
+
+func (suite *SiteNetworksTestSuite) TestSiteAssignmentMode() {
+	mode, err := suite.model.GetSiteAssignmentMode(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), SiteAssignmentDisabled, mode, "should be disabled without settings")
+
+	err = suite.model.Client.Settings.Create().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create global settings")
+
+	s, err := suite.model.GetGeneralSettings(suite.commonInfo.TenantID)
+	assert.NoError(suite.T(), err, "should create the settings of the tenant")
+
+	err = suite.model.UpdateSiteAssignmentMode(s.ID, SiteAssignmentAdmission)
+	assert.NoError(suite.T(), err)
+
+	mode, err = suite.model.GetSiteAssignmentMode(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), SiteAssignmentAdmission, mode)
+}
+
+func TestSiteNetworksTestSuite(t *testing.T) {
+	suite.Run(t, new(SiteNetworksTestSuite))
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/open-uem/ent/site"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteLogLevel(t *testing.T) {
+	level, err := SiteLogLevel("")
+	assert.NoError(t, err)
+	assert.Equal(t, site.LogLevelInfo, level, "should log at info level by default")
+
+	level, err = SiteLogLevel("debug")
+	assert.NoError(t, err)
+	assert.Equal(t, site.LogLevelDebug, level)
+
+	_, err = SiteLogLevel("verbose")
+	assert.Error(t, err, "should only accept the levels of the agent")
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	openuem_ent "github.com/open-uem/ent"
@@ -81,11 +82,51 @@ func (m *Model) GetSMTPSettings(tenantID string) (*openuem_ent.Settings, error)
 	return s, nil
 }
 
+// UpdateSMTPSettings stores the SMTP settings with the password encrypted. The password is not shown in the
+// form, an empty one keeps the current one unless the user has been removed
 func (m *Model) UpdateSMTPSettings(settings *SMTPSettings) error {
-	mainQuery := m.Client.Settings.UpdateOneID(settings.ID).SetSMTPServer(settings.Server).SetSMTPPort(settings.Port).SetSMTPUser(settings.User).SetSMTPPassword(settings.Password).SetMessageFrom(settings.MailFrom)
+	mainQuery := m.Client.Settings.UpdateOneID(settings.ID).SetSMTPServer(settings.Server).SetSMTPPort(settings.Port).SetSMTPUser(settings.User).SetMessageFrom(settings.MailFrom)
+
+	if settings.Password != "" {
+		password, err := m.sealSecret(settings.Password)
+		if err != nil {
+			return fmt.Errorf("could not encrypt the SMTP password: %w", err)
+		}
+		mainQuery.SetSMTPPassword(password)
+	} else if settings.User == "" {
+		mainQuery.SetSMTPPassword("")
+	}
+
 	return mainQuery.Exec(context.Background())
 }
 
+// GetSMTPPassword returns the decrypted SMTP password of the settings
+func (m *Model) GetSMTPPassword(settingsID int) (string, error) {
+	s, err := m.Client.Settings.Query().Select(settings.FieldSMTPPassword).Where(settings.ID(settingsID)).Only(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return m.OpenSecret(s.SMTPPassword)
+}
+
+// smtpSettings returns the SMTP settings read from the database with the decrypted password
+func (m *Model) smtpSettings(s *openuem_ent.Settings) (*SMTPSettings, error) {
+	password, err := m.OpenSecret(s.SMTPPassword)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt the SMTP password: %w", err)
+	}
+
+	return &SMTPSettings{
+		ID:       s.ID,
+		Server:   s.SMTPServer,
+		Port:     s.SMTPPort,
+		User:     s.SMTPUser,
+		Password: password,
+		Auth:     s.SMTPAuth,
+		MailFrom: s.MessageFrom,
+	}, nil
+}
+
 func (m *Model) IsSMTPConfigured() bool {
 	s, err := m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).First(context.Background())
 	if err != nil {
@@ -103,4 +144,5 @@ type SMTPSettings struct {
 	Password string
 	Auth     string
 	MailFrom string
+	FromName string
 }
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/smtpconfig"
+	"github.com/wneessen/go-mail"
+)
+
+// GetTenantSMTPConfig returns the SMTP server of the tenant. It's not found if the tenant sends its
+// emails with the global SMTP settings
+func (m *Model) GetTenantSMTPConfig(tenantID int) (*ent.SMTPConfig, error) {
+	return m.Client.SMTPConfig.Query().Where(smtpconfig.TenantID(tenantID)).Only(context.Background())
+}
+
+// SaveTenantSMTPConfig makes the tenant send its emails with its own SMTP server. The password is stored
+// encrypted, an empty one keeps the current one unless the user has been removed
+func (m *Model) SaveTenantSMTPConfig(tenantID int, s *SMTPSettings) error {
+	ctx := context.Background()
+
+	password := ""
+	if s.Password != "" {
+		sealed, err := m.sealSecret(s.Password)
+		if err != nil {
+			return fmt.Errorf("could not encrypt the SMTP password: %w", err)
+		}
+		password = sealed
+	}
+
+	current, err := m.GetTenantSMTPConfig(tenantID)
+	if ent.IsNotFound(err) {
+		return m.Client.SMTPConfig.Create().
+			SetTenantID(tenantID).
+			SetHost(s.Server).
+			SetPort(s.Port).
+			SetUsername(s.User).
+			SetPassword(password).
+			SetAuth(s.Auth).
+			SetFromAddress(s.MailFrom).
+			SetFromName(s.FromName).
+			Exec(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	update := m.Client.SMTPConfig.UpdateOne(current).
+		SetHost(s.Server).
+		SetPort(s.Port).
+		SetUsername(s.User).
+		SetAuth(s.Auth).
+		SetFromAddress(s.MailFrom).
+		SetFromName(s.FromName)
+	if password != "" {
+		update.SetPassword(password)
+	} else if s.User == "" {
+		update.SetPassword("")
+	}
+	return update.Exec(ctx)
+}
+
+// DeleteTenantSMTPConfig makes the tenant send its emails with the global SMTP settings again
+func (m *Model) DeleteTenantSMTPConfig(tenantID int) error {
+	_, err := m.Client.SMTPConfig.Delete().Where(smtpconfig.TenantID(tenantID)).Exec(context.Background())
+	return err
+}
+
+// GetTenantSMTPPassword returns the decrypted password of the SMTP server of the tenant
+func (m *Model) GetTenantSMTPPassword(tenantID int) (string, error) {
+	c, err := m.GetTenantSMTPConfig(tenantID)
+	if err != nil {
+		return "", err
+	}
+	return m.OpenSecret(c.Password)
+}
+
+// TenantSMTPSettings returns the settings to send the emails of the tenant, those of its own SMTP server
+// or the global SMTP settings if it doesn't have one. The tenant 0 always uses the global settings
+func (m *Model) TenantSMTPSettings(tenantID int) (*SMTPSettings, error) {
+	if tenantID != 0 {
+		c, err := m.GetTenantSMTPConfig(tenantID)
+		if err == nil {
+			password, err := m.OpenSecret(c.Password)
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt the SMTP password of the tenant: %w", err)
+			}
+			return &SMTPSettings{
+				ID:       c.ID,
+				Server:   c.Host,
+				Port:     c.Port,
+				User:     c.Username,
+				Password: password,
+				Auth:     c.Auth,
+				MailFrom: c.FromAddress,
+				FromName: c.FromName,
+			}, nil
+		}
+		if !ent.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	s, err := m.GetSMTPSettings("-1")
+	if err != nil {
+		return nil, err
+	}
+	return m.smtpSettings(s)
+}
+
+// MigrateTenantSMTPSettings moves the SMTP server set in the settings of a tenant to its own SMTP
+// configuration. The tenants whose settings had the global SMTP server now follow the global settings.
+// The SMTP server is removed from the settings of the tenants, so they're only migrated once
+func (m *Model) MigrateTenantSMTPSettings() error {
+	ctx := context.Background()
+
+	global, err := m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	configured, err := m.Client.SMTPConfig.Query().Select(smtpconfig.FieldTenantID).Ints(ctx)
+	if err != nil {
+		return err
+	}
+
+	tenantSettings, err := m.Client.Settings.Query().WithTenant().Where(settings.HasTenant()).All(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range tenantSettings {
+		if slices.Contains(configured, s.Edges.Tenant.ID) {
+			continue
+		}
+		if s.SMTPServer == "" || (s.SMTPServer == global.SMTPServer && s.SMTPPort == global.SMTPPort && s.SMTPUser == global.SMTPUser && s.MessageFrom == global.MessageFrom) {
+			continue
+		}
+
+		if err := m.Client.SMTPConfig.Create().
+			SetTenantID(s.Edges.Tenant.ID).
+			SetHost(s.SMTPServer).
+			SetPort(s.SMTPPort).
+			SetUsername(s.SMTPUser).
+			SetPassword(s.SMTPPassword).
+			SetAuth(s.SMTPAuth).
+			SetFromAddress(s.MessageFrom).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	return m.Client.Settings.Update().
+		Where(settings.HasTenant(), settings.SMTPServerNEQ("")).
+		SetSMTPServer("").
+		SetSMTPUser("").
+		SetSMTPPassword("").
+		Exec(ctx)
+}
+
+// setMailFrom sets the sender of the message, with its name if the settings have one
+func setMailFrom(msg *mail.Msg, settings *SMTPSettings) error {
+	if settings.FromName != "" {
+		return msg.FromFormat(settings.FromName, settings.MailFrom)
+	}
+	return msg.From(settings.MailFrom)
+}
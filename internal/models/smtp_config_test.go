@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SMTPConfigTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+}
+
+func (suite *SMTPConfigTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client, secrets: auth.NewSecretBox(testSecretsKey)}
+
+	err := client.Settings.Create().SetSMTPServer("smtp.example.com").SetSMTPPort(587).SetSMTPUser("global").SetSMTPPassword("global-secret").SetMessageFrom("global@example.com").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create global settings")
+
+	tenant, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = tenant.ID
+}
+
+func (suite *SMTPConfigTestSuite) TestTenantSMTPSettings() {
+	_, err := suite.model.GetTenantSMTPConfig(suite.tenantID)
+	assert.True(suite.T(), ent.IsNotFound(err), "tenant should not have its own SMTP server")
+
+	s, err := suite.model.TenantSMTPSettings(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp.example.com", s.Server, "should fall back to the global settings")
+	assert.Equal(suite.T(), "global-secret", s.Password)
+
+	err = suite.model.SaveTenantSMTPConfig(suite.tenantID, &SMTPSettings{Server: "mail.tenant.com", Port: 465, User: "tenant", Password: "tenant-secret", Auth: "PLAIN", MailFrom: "it@tenant.com", FromName: "Tenant IT"})
+	assert.NoError(suite.T(), err, "should save the SMTP server of the tenant")
+
+	c, err := suite.model.GetTenantSMTPConfig(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), auth.IsEncryptedSecret(c.Password), "should encrypt the password")
+
+	s, err = suite.model.TenantSMTPSettings(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mail.tenant.com", s.Server, "should use the SMTP server of the tenant")
+	assert.Equal(suite.T(), 465, s.Port)
+	assert.Equal(suite.T(), "tenant-secret", s.Password)
+	assert.Equal(suite.T(), "Tenant IT", s.FromName)
+
+	s, err = suite.model.TenantSMTPSettings(0)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp.example.com", s.Server, "should use the global settings without a tenant")
+
+	err = suite.model.SaveTenantSMTPConfig(suite.tenantID, &SMTPSettings{Server: "mail2.tenant.com", Port: 587, User: "tenant", Auth: "LOGIN", MailFrom: "it@tenant.com"})
+	assert.NoError(suite.T(), err, "should update the SMTP server of the tenant")
+	password, err := suite.model.GetTenantSMTPPassword(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "tenant-secret", password, "should keep the password if a new one is not entered")
+
+	err = suite.model.DeleteTenantSMTPConfig(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	s, err = suite.model.TenantSMTPSettings(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp.example.com", s.Server, "should use the global settings again")
+}
+
+func (suite *SMTPConfigTestSuite) TestMigrateTenantSMTPSettings() {
+	other, err := suite.model.Client.Tenant.Create().SetDescription("Other").Save(context.Background())
+	assert.NoError(suite.T(), err)
+
+	err = suite.model.Client.Settings.Create().SetTenantID(suite.tenantID).SetSMTPServer("smtp.example.com").SetSMTPPort(587).SetSMTPUser("global").SetSMTPPassword("global-secret").SetMessageFrom("global@example.com").Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	err = suite.model.Client.Settings.Create().SetTenantID(other.ID).SetSMTPServer("mail.other.com").SetSMTPPort(25).SetSMTPUser("other").SetSMTPPassword("other-secret").SetMessageFrom("it@other.com").Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	assert.NoError(suite.T(), suite.model.MigrateTenantSMTPSettings(), "should migrate the SMTP settings of the tenants")
+	assert.NoError(suite.T(), suite.model.MigrateTenantSMTPSettings(), "should migrate them only once")
+
+	_, err = suite.model.GetTenantSMTPConfig(suite.tenantID)
+	assert.True(suite.T(), ent.IsNotFound(err), "tenant with the global SMTP server should follow the global settings")
+
+	s, err := suite.model.TenantSMTPSettings(other.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mail.other.com", s.Server, "tenant should keep its own SMTP server")
+	assert.Equal(suite.T(), "other-secret", s.Password)
+
+	n, err := suite.model.Client.SMTPConfig.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, n)
+
+	s, err = suite.model.TenantSMTPSettings(0)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "smtp.example.com", s.Server, "should not change the global settings")
+}
+
+func TestSMTPConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(SMTPConfigTestSuite))
+}
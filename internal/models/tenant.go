@@ -3,11 +3,14 @@ package models
 import (
 	"context"
 	"fmt"
+	"net"
 	"time"
 
 	ent "github.com/open-uem/ent"
 	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/featureflag"
 	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/smtpconfig"
 	"github.com/open-uem/ent/tenant"
 	"github.com/open-uem/ent/usertenant"
 	"github.com/open-uem/openuem-console/internal/views/filters"
@@ -37,7 +40,8 @@ func (m *Model) GetDefaultTenant() (*ent.Tenant, error) {
 }
 
 func (m *Model) GetTenantByID(tenantID int) (*ent.Tenant, error) {
-	return m.Client.Tenant.Query().Where(tenant.ID(tenantID)).Only(context.Background())
+	t, err := m.Client.Tenant.Query().Where(tenant.ID(tenantID)).Only(context.Background())
+	return t, dbError(err)
 }
 
 func (m *Model) GetTenantByName(name string) (*ent.Tenant, error) {
@@ -105,27 +109,30 @@ func (m *Model) GetTenantsByPage(p partials.PaginationAndSort, f filters.TenantF
 }
 
 func (m *Model) UpdateTenant(tenantID int, desc string, isDefault bool) error {
+	defer m.Cache.Invalidate(cacheKeyTenants)
 
 	query := m.Client.Tenant.Update().Where(tenant.ID(tenantID)).SetDescription(desc)
 
 	if isDefault {
 		if err := m.Client.Tenant.Update().Where(tenant.Not(tenant.ID(tenantID))).SetIsDefault(false).Exec(context.Background()); err != nil {
-			return err
+			return dbError(err)
 		}
-		return query.SetIsDefault(true).Exec(context.Background())
+		return dbError(query.SetIsDefault(true).Exec(context.Background()))
 	} else {
 		count, err := m.Client.Tenant.Query().Where(tenant.Not(tenant.ID(tenantID)), tenant.IsDefault(true)).Count(context.Background())
 		if err != nil {
 			return err
 		}
 		if count == 0 {
-			return fmt.Errorf("this is the current default organization, you cannot remove it as default org until you select a new default org first")
+			return ErrDefaultTenantRequired
 		}
-		return query.SetIsDefault(false).Exec(context.Background())
+		return dbError(query.SetIsDefault(false).Exec(context.Background()))
 	}
 }
 
 func (m *Model) AddTenant(name string, isDefault bool, siteName string) error {
+	defer m.Cache.Invalidate(cacheKeyTenants, cacheKeySites)
+
 	if isDefault {
 		// Remove the is default property for existing orgs
 		if err := m.Client.Tenant.Update().SetIsDefault(false).Exec(context.Background()); err != nil {
@@ -161,18 +168,34 @@ func (m *Model) AddTenant(name string, isDefault bool, siteName string) error {
 		return cloneErr
 	}
 
-	return m.Client.Site.Create().SetDescription(siteName).SetIsDefault(true).SetTenantID(t.ID).Exec(context.Background())
+	return dbError(m.Client.Site.Create().SetDescription(siteName).SetIsDefault(true).SetTenantID(t.ID).Exec(context.Background()))
 }
 
 func (m *Model) DeleteTenant(tenantID int) error {
+	defer m.Cache.Invalidate(cacheKeyTenants, cacheKeySites)
+
+	if err := m.CheckMainTenantDeletion(tenantID); err != nil {
+		return err
+	}
+
 	// Delete user-tenant associations first (no cascade configured on this edge)
 	_, err := m.Client.UserTenant.Delete().Where(usertenant.TenantID(tenantID)).Exec(context.Background())
 	if err != nil {
 		return fmt.Errorf("could not delete user-tenant associations: %w", err)
 	}
 
+	_, err = m.Client.FeatureFlag.Delete().Where(featureflag.TenantID(tenantID)).Exec(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not delete the feature flags: %w", err)
+	}
+
+	_, err = m.Client.SMTPConfig.Delete().Where(smtpconfig.TenantID(tenantID)).Exec(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not delete the SMTP configuration: %w", err)
+	}
+
 	_, err = m.Client.Tenant.Delete().Where(tenant.ID(tenantID)).Exec(context.Background())
-	return err
+	return dbError(err)
 }
 
 func (m *Model) TenantNameTaken(desc string) (bool, error) {
@@ -228,6 +251,8 @@ func (m *Model) GetTenantByOIDCOrgID(orgID string) (*ent.Tenant, error) {
 }
 
 func (m *Model) UpdateTenantOIDC(tenantID int, oidcOrgID string, oidcDefaultRole string) error {
+	defer m.Cache.Invalidate(cacheKeyTenants)
+
 	query := m.Client.Tenant.UpdateOneID(tenantID)
 	if oidcOrgID != "" {
 		query.SetOidcOrgID(oidcOrgID)
@@ -240,6 +265,35 @@ func (m *Model) UpdateTenantOIDC(tenantID int, oidcOrgID string, oidcDefaultRole
 	return query.Exec(context.Background())
 }
 
+// UpdateTenantAllowedIPs sets the networks, in CIDR notation, allowed to access the tenant's console
+func (m *Model) UpdateTenantAllowedIPs(tenantID int, allowedIPs []string) error {
+	for _, cidr := range allowedIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return err
+		}
+	}
+
+	defer m.Cache.Invalidate(cacheKeyTenants)
+	return m.Client.Tenant.UpdateOneID(tenantID).SetAllowedIPs(allowedIPs).Exec(context.Background())
+}
+
+// UpdateTenantInstallScripts sets the shell commands that the install command of the tenant runs before and after
+// installing the agent. Empty scripts are not run
+func (m *Model) UpdateTenantInstallScripts(tenantID int, preInstall, postInstall string) error {
+	defer m.Cache.Invalidate(cacheKeyTenants)
+	return dbError(m.Client.Tenant.UpdateOneID(tenantID).SetPreInstallScript(preInstall).SetPostInstallScript(postInstall).Exec(context.Background()))
+}
+
+// GetTenantAllowedIPs returns the networks, in CIDR notation, allowed to access the tenant's console.
+// They're not cached so a change is seen at once by every console
+func (m *Model) GetTenantAllowedIPs(tenantID int) ([]string, error) {
+	t, err := m.Client.Tenant.Query().Where(tenant.ID(tenantID)).Select(tenant.FieldAllowedIPs).Only(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return t.AllowedIPs, nil
+}
+
 func (m *Model) GetAgentsByTenant(tenantID int) ([]*ent.Agent, error) {
 	return m.Client.Agent.Query().Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).All(context.Background())
 }
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/agentcommandlog"
+	"github.com/open-uem/ent/enrollmenttoken"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tag"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/ent/usertenant"
+)
+
+// TenantExportSchemaVersion is increased when the files of the tenant export or their fields change
+const TenantExportSchemaVersion = 2
+
+// tenantExportBatchSize is how many rows are loaded at once, so large tables are
+// streamed to the archive instead of being loaded in memory
+const tenantExportBatchSize = 200
+
+// TenantExportFiles are the files of the tenant export in the order they're written
+var TenantExportFiles = []string{"tenant.json", "sites.json", "tags.json", "settings.json", "enrollment_tokens.json", "agents.json", "users.csv", "audit.csv", "weekly_report.json"}
+
+// TenantExportManifest describes the contents of a tenant export
+type TenantExportManifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	TenantID      int            `json:"tenant_id"`
+	TenantName    string         `json:"tenant_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Counts        map[string]int `json:"counts"`
+}
+
+// ExportTenant writes the data of a tenant to the archive, one file per entity followed by
+// manifest.json with the number of items in each file. progress is called after each file
+func (m *Model) ExportTenant(tenantID int, zw *zip.Writer, progress func(file string)) (*TenantExportManifest, error) {
+	t, err := m.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := TenantExportManifest{
+		SchemaVersion: TenantExportSchemaVersion,
+		TenantID:      t.ID,
+		TenantName:    t.Description,
+		CreatedAt:     time.Now(),
+		Counts:        map[string]int{},
+	}
+
+	writers := map[string]func(w io.Writer) (int, error){
+		"tenant.json": func(w io.Writer) (int, error) {
+			return 1, json.NewEncoder(w).Encode(t)
+		},
+		"sites.json": func(w io.Writer) (int, error) {
+			return m.exportTenantSites(tenantID, w)
+		},
+		"tags.json": func(w io.Writer) (int, error) {
+			return m.exportTenantTags(tenantID, w)
+		},
+		"settings.json": func(w io.Writer) (int, error) {
+			return m.exportTenantSettings(tenantID, w)
+		},
+		"enrollment_tokens.json": func(w io.Writer) (int, error) {
+			return m.exportTenantEnrollmentTokens(tenantID, w)
+		},
+		"agents.json": func(w io.Writer) (int, error) {
+			return m.exportTenantAgents(tenantID, w)
+		},
+		"users.csv": func(w io.Writer) (int, error) {
+			return m.exportTenantUsers(tenantID, w)
+		},
+		"audit.csv": func(w io.Writer) (int, error) {
+			return m.exportTenantAudit(tenantID, w)
+		},
+		"weekly_report.json": func(w io.Writer) (int, error) {
+			report, err := m.BuildWeeklyReport(tenantID)
+			if err != nil {
+				return 0, err
+			}
+			return 1, json.NewEncoder(w).Encode(report)
+		},
+	}
+
+	for _, file := range TenantExportFiles {
+		w, err := zw.Create(file)
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Counts[file], err = writers[file](w); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(file)
+		}
+	}
+
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func (m *Model) exportTenantSites(tenantID int, w io.Writer) (int, error) {
+	sites, err := m.Client.Site.Query().Where(site.HasTenantWith(tenant.ID(tenantID))).Order(ent.Asc(site.FieldID)).All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	array := newJSONArrayWriter(w)
+	for _, s := range sites {
+		if err := array.Write(s); err != nil {
+			return 0, err
+		}
+	}
+	return array.Count(), array.Close()
+}
+
+func (m *Model) exportTenantTags(tenantID int, w io.Writer) (int, error) {
+	tags, err := m.Client.Tag.Query().Where(tag.HasTenantWith(tenant.ID(tenantID))).Order(ent.Asc(tag.FieldID)).All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	array := newJSONArrayWriter(w)
+	for _, t := range tags {
+		if err := array.Write(t); err != nil {
+			return 0, err
+		}
+	}
+	return array.Count(), array.Close()
+}
+
+// exportTenantSettings writes the settings of the tenant without the SMTP password
+func (m *Model) exportTenantSettings(tenantID int, w io.Writer) (int, error) {
+	s, err := m.Client.Settings.Query().WithTag().Where(settings.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return 0, json.NewEncoder(w).Encode(nil)
+		}
+		return 0, err
+	}
+
+	s.SMTPPassword = ""
+	return 1, json.NewEncoder(w).Encode(s)
+}
+
+// exportTenantEnrollmentTokens writes the enrollment tokens without their values,
+// which must not be usable outside this console
+func (m *Model) exportTenantEnrollmentTokens(tenantID int, w io.Writer) (int, error) {
+	tokens, err := m.Client.EnrollmentToken.Query().
+		WithSite().
+		WithAllowedSites().
+		Where(enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).
+		Order(ent.Asc(enrollmenttoken.FieldID)).
+		All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	array := newJSONArrayWriter(w)
+	for _, t := range tokens {
+		t.Token = ""
+		if err := array.Write(t); err != nil {
+			return 0, err
+		}
+	}
+	return array.Count(), array.Close()
+}
+
+// exportTenantAgents writes the agents with their inventory in batches ordered by ID
+func (m *Model) exportTenantAgents(tenantID int, w io.Writer) (int, error) {
+	array := newJSONArrayWriter(w)
+
+	lastID := ""
+	for {
+		agents, err := m.Client.Agent.Query().
+			WithSite().WithTags().WithComputer().WithOperatingsystem().WithAntivirus().WithSystemupdate().
+			WithApps().WithNetworkadapters().WithLogicaldisks().WithPhysicaldisks().WithMemoryslots().
+			WithMonitors().WithShares().
+			Where(agent.IDGT(lastID), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+			Order(ent.Asc(agent.FieldID)).
+			Limit(tenantExportBatchSize).
+			All(context.Background())
+		if err != nil {
+			return 0, err
+		}
+
+		for _, a := range agents {
+			if err := array.Write(a); err != nil {
+				return 0, err
+			}
+		}
+
+		if len(agents) < tenantExportBatchSize {
+			break
+		}
+		lastID = agents[len(agents)-1].ID
+	}
+
+	return array.Count(), array.Close()
+}
+
+// exportTenantUsers writes the members of the tenant and their role. Passwords,
+// certificates and other credentials are never exported
+func (m *Model) exportTenantUsers(tenantID int, w io.Writer) (int, error) {
+	members, err := m.Client.UserTenant.Query().Where(usertenant.TenantID(tenantID)).WithUser().All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "name", "email", "role", "created"}); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, member := range members {
+		u := member.Edges.User
+		if u == nil {
+			continue
+		}
+		if err := cw.Write([]string{u.ID, u.Name, u.Email, string(member.Role), u.Created.Format(time.RFC3339)}); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// exportTenantAudit writes the audit trail of the remote commands run on the agents of the tenant
+func (m *Model) exportTenantAudit(tenantID int, w io.Writer) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"executed_at", "agent_id", "user_id", "command", "exit_code", "output_sha256"}); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	lastID := 0
+	for {
+		logs, err := m.Client.AgentCommandLog.Query().
+			Where(agentcommandlog.TenantID(tenantID), agentcommandlog.IDGT(lastID)).
+			Order(ent.Asc(agentcommandlog.FieldID)).
+			Limit(tenantExportBatchSize).
+			All(context.Background())
+		if err != nil {
+			return 0, err
+		}
+
+		for _, l := range logs {
+			if err := cw.Write([]string{l.ExecutedAt.Format(time.RFC3339), l.AgentID, l.UserID, l.Command, strconv.Itoa(l.ExitCode), l.OutputHash}); err != nil {
+				return 0, err
+			}
+		}
+		count += len(logs)
+
+		// Flush every batch so the rows don't accumulate in the CSV writer buffer
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return 0, err
+		}
+
+		if len(logs) < tenantExportBatchSize {
+			break
+		}
+		lastID = logs[len(logs)-1].ID
+	}
+
+	return count, nil
+}
+
+// jsonArrayWriter writes a JSON array item by item so it doesn't have to be kept in memory
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if a.count == 0 {
+		sep = "[\n"
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+func (a *jsonArrayWriter) Count() int {
+	return a.count
+}
+
+func (a *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantExportTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+}
+
+func (suite *TenantExportTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	other, err := client.Tenant.Create().SetDescription("Other").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create other tenant")
+	otherSite, err := suite.model.CreateDefaultSite(other)
+	assert.NoError(suite.T(), err, "should create other site")
+
+	// Create more agents than a batch to check they're all exported
+	for i := 0; i < tenantExportBatchSize+5; i++ {
+		id := fmt.Sprintf("agent%03d", i)
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).AddSiteIDs(s.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+	err = client.Agent.Create().SetID("other").SetHostname("other").SetOs("windows").SetNickname("other").AddSiteIDs(otherSite.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent of other tenant")
+
+	err = client.User.Create().SetID("user1").SetName("User 1").SetEmail("user1@example.com").SetCreated(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create user")
+	err = suite.model.AssignUserToTenant("user1", t.ID, UserTenantRoleAdmin, true)
+	assert.NoError(suite.T(), err, "should assign user to tenant")
+
+	err = suite.model.SaveAgentCommandLog("agent000", t.ID, "user1", "whoami", 0, []byte("root"))
+	assert.NoError(suite.T(), err, "should save command log")
+}
+
+func (suite *TenantExportTestSuite) TestExportTenant() {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	progress := []string{}
+	manifest, err := suite.model.ExportTenant(suite.tenantID, zw, func(file string) { progress = append(progress, file) })
+	assert.NoError(suite.T(), err, "should export tenant")
+	assert.NoError(suite.T(), zw.Close())
+	assert.Equal(suite.T(), TenantExportFiles, progress, "should report the progress of each file")
+
+	assert.Equal(suite.T(), TenantExportSchemaVersion, manifest.SchemaVersion)
+	assert.Equal(suite.T(), tenantExportBatchSize+5, manifest.Counts["agents.json"], "should only export the agents of the tenant")
+	assert.Equal(suite.T(), 1, manifest.Counts["sites.json"])
+	assert.Equal(suite.T(), 1, manifest.Counts["users.csv"])
+	assert.Equal(suite.T(), 1, manifest.Counts["audit.csv"])
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(suite.T(), err, "should be a valid ZIP")
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(suite.T(), err)
+		files[f.Name], err = io.ReadAll(r)
+		assert.NoError(suite.T(), err)
+		r.Close()
+	}
+
+	agents := []map[string]any{}
+	assert.NoError(suite.T(), json.Unmarshal(files["agents.json"], &agents), "agents should be a JSON array")
+	assert.Len(suite.T(), agents, tenantExportBatchSize+5)
+
+	users, err := csv.NewReader(bytes.NewReader(files["users.csv"])).ReadAll()
+	assert.NoError(suite.T(), err, "users should be a CSV file")
+	assert.Equal(suite.T(), []string{"user1", "User 1", "user1@example.com", "admin"}, users[1][:4])
+
+	exported := TenantExportManifest{}
+	assert.NoError(suite.T(), json.Unmarshal(files["manifest.json"], &exported), "should include the manifest")
+	assert.Equal(suite.T(), manifest.Counts, exported.Counts)
+}
+
+func TestJSONArrayWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	array := newJSONArrayWriter(buf)
+	assert.NoError(t, array.Close())
+	assert.Equal(t, "[]\n", buf.String(), "should write an empty array")
+
+	buf.Reset()
+	array = newJSONArrayWriter(buf)
+	assert.NoError(t, array.Write(map[string]int{"a": 1}))
+	assert.NoError(t, array.Write(map[string]int{"b": 2}))
+	assert.NoError(t, array.Close())
+
+	items := []map[string]int{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+	assert.Equal(t, 2, array.Count())
+	assert.Equal(t, []map[string]int{{"a": 1}, {"b": 2}}, items)
+}
+
+func TestTenantExportTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantExportTestSuite))
+}
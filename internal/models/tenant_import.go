@@ -0,0 +1,588 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/user"
+	"github.com/open-uem/ent/usertenant"
+)
+
+// tenantImportMaxFileSize limits the uncompressed size of each file read from a bundle
+const tenantImportMaxFileSize = 512 << 20
+
+// ErrInvalidTenantBundle is returned when the uploaded file isn't a tenant export that can be imported
+var ErrInvalidTenantBundle = errors.New("invalid tenant export bundle")
+
+// TenantImportBundle is the data read from a tenant export. Agents only keep the fields
+// and edges that are imported, the inventory is reported again by the agents
+type TenantImportBundle struct {
+	Manifest         TenantExportManifest
+	Tenant           *ent.Tenant
+	Sites            []*ent.Site
+	Tags             []*ent.Tag
+	Settings         *ent.Settings
+	EnrollmentTokens []*ent.EnrollmentToken
+	Agents           []*ent.Agent
+	Users            []TenantImportUser
+}
+
+// TenantImportUser is a member of the exported tenant
+type TenantImportUser struct {
+	ID      string
+	Name    string
+	Email   string
+	Role    UserTenantRole
+	Created time.Time
+}
+
+// TenantImportOptions are chosen by the admin after checking the preview
+type TenantImportOptions struct {
+	TenantName string
+	// CreateUsers creates the members that don't match an existing user by email, otherwise they're skipped
+	CreateUsers bool
+}
+
+// TenantImportRecordError is an offending record of the bundle
+type TenantImportRecordError struct {
+	File   string
+	Record string
+	Reason string
+}
+
+// TenantImportError is returned when the bundle can't be imported. Nothing has been
+// saved as the import runs in a transaction that is rolled back
+type TenantImportError struct {
+	Records []TenantImportRecordError
+}
+
+func (e *TenantImportError) Error() string {
+	return fmt.Sprintf("the tenant could not be imported, %d records have errors", len(e.Records))
+}
+
+// TenantImportPreview has the number of items of each entity and the conflicts with this console
+type TenantImportPreview struct {
+	Counts       map[string]int
+	MatchedUsers int
+	NewUsers     int
+	Conflicts    []TenantImportRecordError
+}
+
+// ReadTenantImportBundle reads the files of a tenant export and checks the schema version
+// and that each file has the number of items listed in the manifest
+func ReadTenantImportBundle(zr *zip.Reader) (*TenantImportBundle, error) {
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	b := TenantImportBundle{}
+	if err := readBundleJSON(files, "manifest.json", &b.Manifest); err != nil {
+		return nil, err
+	}
+	if b.Manifest.SchemaVersion < 1 || b.Manifest.SchemaVersion > TenantExportSchemaVersion {
+		return nil, fmt.Errorf("%w: schema version %d is not supported", ErrInvalidTenantBundle, b.Manifest.SchemaVersion)
+	}
+
+	if err := readBundleJSON(files, "tenant.json", &b.Tenant); err != nil {
+		return nil, err
+	}
+	if b.Tenant == nil {
+		return nil, fmt.Errorf("%w: tenant.json is empty", ErrInvalidTenantBundle)
+	}
+
+	if err := readBundleJSON(files, "sites.json", &b.Sites); err != nil {
+		return nil, err
+	}
+
+	if err := readBundleAgents(files, &b); err != nil {
+		return nil, err
+	}
+
+	// Version 1 bundles don't have tags, settings nor enrollment tokens
+	if b.Manifest.SchemaVersion >= 2 {
+		if err := readBundleJSON(files, "tags.json", &b.Tags); err != nil {
+			return nil, err
+		}
+		if err := readBundleJSON(files, "settings.json", &b.Settings); err != nil {
+			return nil, err
+		}
+		if err := readBundleJSON(files, "enrollment_tokens.json", &b.EnrollmentTokens); err != nil {
+			return nil, err
+		}
+	}
+
+	users, err := readBundleUsers(files)
+	if err != nil {
+		return nil, err
+	}
+	b.Users = users
+
+	for file, count := range map[string]int{"sites.json": len(b.Sites), "agents.json": len(b.Agents), "users.csv": len(b.Users), "tags.json": len(b.Tags), "enrollment_tokens.json": len(b.EnrollmentTokens)} {
+		if expected, ok := b.Manifest.Counts[file]; ok && expected != count {
+			return nil, fmt.Errorf("%w: %s has %d items but the manifest lists %d", ErrInvalidTenantBundle, file, count, expected)
+		}
+	}
+
+	return &b, nil
+}
+
+func openBundleFile(files map[string]*zip.File, name string) (io.ReadCloser, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidTenantBundle, name)
+	}
+	if f.UncompressedSize64 > tenantImportMaxFileSize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidTenantBundle, name)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s could not be opened: %w", ErrInvalidTenantBundle, name, err)
+	}
+	return r, nil
+}
+
+func readBundleJSON(files map[string]*zip.File, name string, v any) error {
+	r, err := openBundleFile(files, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(io.LimitReader(r, tenantImportMaxFileSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s could not be decoded: %w", ErrInvalidTenantBundle, name, err)
+	}
+	return nil
+}
+
+// readBundleAgents decodes the agents one by one and drops their inventory, so only
+// one agent with its inventory is kept in memory at once
+func readBundleAgents(files map[string]*zip.File, b *TenantImportBundle) error {
+	r, err := openBundleFile(files, "agents.json")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := json.NewDecoder(io.LimitReader(r, tenantImportMaxFileSize))
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("%w: agents.json could not be decoded: %w", ErrInvalidTenantBundle, err)
+	}
+
+	for dec.More() {
+		a := ent.Agent{}
+		if err := dec.Decode(&a); err != nil {
+			return fmt.Errorf("%w: agents.json could not be decoded: %w", ErrInvalidTenantBundle, err)
+		}
+		a.Edges = ent.AgentEdges{Site: a.Edges.Site, Tags: a.Edges.Tags}
+		b.Agents = append(b.Agents, &a)
+	}
+	return nil
+}
+
+func readBundleUsers(files map[string]*zip.File) ([]TenantImportUser, error) {
+	r, err := openBundleFile(files, "users.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	records, err := csv.NewReader(io.LimitReader(r, tenantImportMaxFileSize)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: users.csv could not be decoded: %w", ErrInvalidTenantBundle, err)
+	}
+
+	users := []TenantImportUser{}
+	for i, record := range records {
+		// Skip the header
+		if i == 0 {
+			continue
+		}
+		if len(record) != 5 {
+			return nil, fmt.Errorf("%w: users.csv line %d has %d fields", ErrInvalidTenantBundle, i+1, len(record))
+		}
+
+		created, err := time.Parse(time.RFC3339, record[4])
+		if err != nil {
+			created = time.Now()
+		}
+		users = append(users, TenantImportUser{ID: record[0], Name: record[1], Email: record[2], Role: UserTenantRole(record[3]), Created: created})
+	}
+	return users, nil
+}
+
+// PreviewTenantImport counts the items that will be imported and finds the records that conflict with
+// this console: agents that already exist, users whose ID is taken and references to missing sites
+func (m *Model) PreviewTenantImport(b *TenantImportBundle, opts TenantImportOptions) (*TenantImportPreview, error) {
+	p := TenantImportPreview{
+		Counts: map[string]int{
+			"sites.json":             len(b.Sites),
+			"tags.json":              len(b.bundleTags()),
+			"enrollment_tokens.json": len(b.EnrollmentTokens),
+			"agents.json":            len(b.Agents),
+			"users.csv":              len(b.Users),
+		},
+	}
+
+	taken, err := m.TenantNameTaken(opts.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "tenant.json", Record: opts.TenantName, Reason: "a tenant with this name already exists"})
+	}
+
+	sites := map[int]bool{}
+	for _, s := range b.Sites {
+		sites[s.ID] = true
+	}
+
+	existing, err := m.existingAgentIDs(b.Agents)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range b.Agents {
+		if existing[a.ID] {
+			p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "agents.json", Record: a.ID, Reason: "an agent with this ID already exists"})
+		}
+		for _, s := range a.Edges.Site {
+			if !sites[s.ID] {
+				p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "agents.json", Record: a.ID, Reason: fmt.Sprintf("site %d is not in the bundle", s.ID)})
+			}
+		}
+	}
+
+	for _, t := range b.EnrollmentTokens {
+		if t.Edges.Site != nil && !sites[t.Edges.Site.ID] {
+			p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "enrollment_tokens.json", Record: strconv.Itoa(t.ID), Reason: fmt.Sprintf("site %d is not in the bundle", t.Edges.Site.ID)})
+		}
+		for _, s := range t.Edges.AllowedSites {
+			if !sites[s.ID] {
+				p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "enrollment_tokens.json", Record: strconv.Itoa(t.ID), Reason: fmt.Sprintf("site %d is not in the bundle", s.ID)})
+			}
+		}
+	}
+
+	for _, u := range b.Users {
+		if !u.Role.AtLeast(UserTenantRoleUser) {
+			p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "users.csv", Record: u.Email, Reason: fmt.Sprintf("role %q is not valid", u.Role)})
+		}
+
+		existing, err := m.Client.User.Query().Where(user.Email(u.Email)).First(context.Background())
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, err
+		}
+		if existing != nil {
+			p.MatchedUsers++
+			continue
+		}
+
+		p.NewUsers++
+		if !opts.CreateUsers {
+			continue
+		}
+		idTaken, err := m.Client.User.Query().Where(user.ID(u.ID)).Exist(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if idTaken {
+			p.Conflicts = append(p.Conflicts, TenantImportRecordError{File: "users.csv", Record: u.Email, Reason: fmt.Sprintf("user ID %s is used by a user with another email", u.ID)})
+		}
+	}
+
+	return &p, nil
+}
+
+// existingAgentIDs returns the agents of the bundle that are already in this console, including
+// the deleted ones as they're in the recycle bin of another tenant and keep their ID
+func (m *Model) existingAgentIDs(agents []*ent.Agent) (map[string]bool, error) {
+	ctx := withDeletedAgents(context.Background())
+	existing := map[string]bool{}
+
+	for start := 0; start < len(agents); start += tenantExportBatchSize {
+		ids := []string{}
+		for _, a := range agents[start:min(start+tenantExportBatchSize, len(agents))] {
+			ids = append(ids, a.ID)
+		}
+
+		found, err := m.Client.Agent.Query().Where(agent.IDIn(ids...)).IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
+
+// ImportTenant creates a tenant with the data of the bundle in a transaction, in dependency
+// order and mapping the IDs of the bundle to the new ones. Enrollment tokens get new values.
+// If any record fails, the transaction is rolled back and a *TenantImportError is returned
+func (m *Model) ImportTenant(b *TenantImportBundle, opts TenantImportOptions) (int, error) {
+	preview, err := m.PreviewTenantImport(b, opts)
+	if err != nil {
+		return 0, err
+	}
+	if len(preview.Conflicts) > 0 {
+		return 0, &TenantImportError{Records: preview.Conflicts}
+	}
+
+	defer m.Cache.Invalidate(cacheKeyTenants, cacheKeySites)
+
+	tx, err := m.Client.Tx(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	tenantID, recordErr := (&tenantImporter{client: tx.Client(), bundle: b, opts: opts}).run()
+	if recordErr != nil {
+		if err := tx.Rollback(); err != nil {
+			return 0, err
+		}
+		return 0, &TenantImportError{Records: []TenantImportRecordError{*recordErr}}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return tenantID, nil
+}
+
+// bundleTags returns the tags of the bundle and, for version 1 bundles, the tags of the agents
+func (b *TenantImportBundle) bundleTags() []*ent.Tag {
+	tags := append([]*ent.Tag{}, b.Tags...)
+	found := map[int]bool{}
+	for _, t := range tags {
+		found[t.ID] = true
+	}
+
+	for _, a := range b.Agents {
+		for _, t := range a.Edges.Tags {
+			if !found[t.ID] {
+				found[t.ID] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+// tenantImporter saves the bundle with the transaction client and keeps the new IDs
+type tenantImporter struct {
+	client   *ent.Client
+	bundle   *TenantImportBundle
+	opts     TenantImportOptions
+	tenantID int
+	sites    map[int]int
+	tags     map[int]int
+}
+
+// run returns the offending record if any of them can't be saved
+func (ti *tenantImporter) run() (int, *TenantImportRecordError) {
+	ctx := context.Background()
+
+	t, err := ti.client.Tenant.Create().SetDescription(ti.opts.TenantName).SetIsDefault(false).Save(ctx)
+	if err != nil {
+		return 0, &TenantImportRecordError{File: "tenant.json", Record: ti.opts.TenantName, Reason: err.Error()}
+	}
+	ti.tenantID = t.ID
+
+	for _, step := range []func(ctx context.Context) *TenantImportRecordError{
+		ti.importSites, ti.importTags, ti.importSettings, ti.importEnrollmentTokens, ti.importAgents, ti.importUsers,
+	} {
+		if err := step(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return ti.tenantID, nil
+}
+
+func (ti *tenantImporter) importSites(ctx context.Context) *TenantImportRecordError {
+	ti.sites = map[int]int{}
+	for _, s := range ti.bundle.Sites {
+		query := ti.client.Site.Create().SetDescription(s.Description).SetIsDefault(s.IsDefault).SetDomain(s.Domain).SetNetworks(s.Networks).SetTenantID(ti.tenantID)
+		if s.CatalogRing != nil {
+			query.SetCatalogRing(*s.CatalogRing)
+		}
+		if s.LogLevel != "" {
+			query.SetLogLevel(s.LogLevel)
+		}
+
+		created, err := query.Save(ctx)
+		if err != nil {
+			return &TenantImportRecordError{File: "sites.json", Record: s.Description, Reason: err.Error()}
+		}
+		ti.sites[s.ID] = created.ID
+	}
+	return nil
+}
+
+func (ti *tenantImporter) importTags(ctx context.Context) *TenantImportRecordError {
+	ti.tags = map[int]int{}
+	for _, t := range ti.bundle.bundleTags() {
+		query := ti.client.Tag.Create().SetTag(t.Tag).SetDescription(t.Description).SetColor(t.Color).SetTenantID(ti.tenantID).
+			SetLateThresholdInHours(t.LateThresholdInHours).SetOfflineThresholdInHours(t.OfflineThresholdInHours)
+		if t.CatalogRing != nil {
+			query.SetCatalogRing(*t.CatalogRing)
+		}
+
+		created, err := query.Save(ctx)
+		if err != nil {
+			return &TenantImportRecordError{File: "tags.json", Record: t.Tag, Reason: err.Error()}
+		}
+		ti.tags[t.ID] = created.ID
+	}
+	return nil
+}
+
+// importSettings saves the settings of the bundle, or a copy of the global settings for bundles
+// without them. The SMTP password isn't exported so it has to be set again
+func (ti *tenantImporter) importSettings(ctx context.Context) *TenantImportRecordError {
+	s := ti.bundle.Settings
+	if s == nil {
+		if err := (&Model{Client: ti.client}).CloneGlobalSettings(ti.tenantID); err != nil {
+			return &TenantImportRecordError{File: "settings.json", Record: "global", Reason: err.Error()}
+		}
+		return nil
+	}
+
+	query := ti.client.Settings.Create().
+		SetAgentReportFrequenceInMinutes(s.AgentReportFrequenceInMinutes).
+		SetAgentLateThresholdInHours(s.AgentLateThresholdInHours).
+		SetAgentOfflineThresholdInHours(s.AgentOfflineThresholdInHours).
+		SetAutoAdmitAgents(s.AutoAdmitAgents).
+		SetCountry(s.Country).
+		SetDetectRemoteAgents(s.DetectRemoteAgents).
+		SetDisableRemoteAssistance(s.DisableRemoteAssistance).
+		SetDisableSftp(s.DisableSftp).
+		SetMaxUploadSize(s.MaxUploadSize).
+		SetNatsRequestTimeoutSeconds(s.NatsRequestTimeoutSeconds).
+		SetMessageFrom(s.MessageFrom).
+		SetProfilesApplicationFrequenceInMinutes(s.ProfilesApplicationFrequenceInMinutes).
+		SetRefreshTimeInMinutes(s.RefreshTimeInMinutes).
+		SetDefaultItemsPerPage(s.DefaultItemsPerPage).
+		SetRequestVncPin(s.RequestVncPin).
+		SetSMTPAuth(s.SMTPAuth).
+		SetSMTPPort(s.SMTPPort).
+		SetSMTPServer(s.SMTPServer).
+		SetSMTPStarttls(s.SMTPStarttls).
+		SetSMTPTLS(s.SMTPTLS).
+		SetSMTPUser(s.SMTPUser).
+		SetSessionLifetimeInMinutes(s.SessionLifetimeInMinutes).
+		SetSiteAssignment(s.SiteAssignment).
+		SetUpdateChannel(s.UpdateChannel).
+		SetUseFlatpak(s.UseFlatpak).
+		SetUseBrew(s.UseBrew).
+		SetUseWinget(s.UseWinget).
+		SetUserCertYearsValid(s.UserCertYearsValid).
+		SetTenantID(ti.tenantID)
+
+	if s.Edges.Tag != nil {
+		query.SetTagID(ti.tags[s.Edges.Tag.ID])
+	}
+
+	if err := query.Exec(ctx); err != nil {
+		return &TenantImportRecordError{File: "settings.json", Record: strconv.Itoa(s.ID), Reason: err.Error()}
+	}
+	return nil
+}
+
+func (ti *tenantImporter) importEnrollmentTokens(ctx context.Context) *TenantImportRecordError {
+	for _, t := range ti.bundle.EnrollmentTokens {
+		query := ti.client.EnrollmentToken.Create().
+			SetToken(uuid.New().String()).
+			SetDescription(t.Description).
+			SetMaxUses(t.MaxUses).
+			SetActive(t.Active).
+			SetLandingPage(t.LandingPage).
+			SetTenantID(ti.tenantID)
+
+		if len(t.AllowedOs) > 0 {
+			query.SetAllowedOs(t.AllowedOs)
+		}
+		if t.Edges.Site != nil {
+			query.SetSiteID(ti.sites[t.Edges.Site.ID])
+		}
+		for _, s := range t.Edges.AllowedSites {
+			query.AddAllowedSiteIDs(ti.sites[s.ID])
+		}
+		if t.ExpiresAt != nil {
+			query.SetExpiresAt(*t.ExpiresAt)
+		}
+
+		if err := query.Exec(ctx); err != nil {
+			return &TenantImportRecordError{File: "enrollment_tokens.json", Record: t.Description, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+func (ti *tenantImporter) importAgents(ctx context.Context) *TenantImportRecordError {
+	for _, a := range ti.bundle.Agents {
+		query := ti.client.Agent.Create().
+			SetID(a.ID).
+			SetHostname(a.Hostname).
+			SetOs(a.Os).
+			SetNickname(a.Nickname).
+			SetDescription(a.Description).
+			SetIP(a.IP).
+			SetFirstContact(a.FirstContact).
+			SetLastContact(a.LastContact).
+			SetRemoteAssistance(a.RemoteAssistance).
+			SetSftpService(a.SftpService)
+
+		if a.AgentStatus != "" {
+			query.SetAgentStatus(a.AgentStatus)
+		}
+		for _, s := range a.Edges.Site {
+			query.AddSiteIDs(ti.sites[s.ID])
+		}
+		for _, t := range a.Edges.Tags {
+			query.AddTagIDs(ti.tags[t.ID])
+		}
+
+		if err := query.Exec(ctx); err != nil {
+			return &TenantImportRecordError{File: "agents.json", Record: a.ID, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// importUsers adds the members matched by email to the tenant and creates the others if requested
+func (ti *tenantImporter) importUsers(ctx context.Context) *TenantImportRecordError {
+	for _, u := range ti.bundle.Users {
+		uid := ""
+		existing, err := ti.client.User.Query().Where(user.Email(u.Email)).First(ctx)
+		switch {
+		case err == nil:
+			uid = existing.ID
+		case !ent.IsNotFound(err):
+			return &TenantImportRecordError{File: "users.csv", Record: u.Email, Reason: err.Error()}
+		case !ti.opts.CreateUsers:
+			continue
+		default:
+			if err := ti.client.User.Create().SetID(u.ID).SetName(u.Name).SetEmail(u.Email).SetCreated(u.Created).Exec(ctx); err != nil {
+				return &TenantImportRecordError{File: "users.csv", Record: u.Email, Reason: err.Error()}
+			}
+			uid = u.ID
+		}
+
+		if err := ti.client.UserTenant.Create().SetUserID(uid).SetTenantID(ti.tenantID).SetRole(usertenant.Role(u.Role)).SetIsDefault(false).Exec(ctx); err != nil {
+			return &TenantImportRecordError{File: "users.csv", Record: u.Email, Reason: err.Error()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enrollmenttoken"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/ent/usertenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantImportTestSuite struct {
+	suite.Suite
+	t      enttest.TestingT
+	source Model
+	target Model
+	bundle *TenantImportBundle
+}
+
+// SetupTest exports a tenant from the source console so it can be imported in the target one
+func (suite *TenantImportTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:source?mode=memory&_fk=1")
+	client.Agent.Intercept(excludeDeletedAgents())
+	suite.source = Model{Client: client}
+
+	t, err := suite.source.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	s, err := suite.source.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	tag, err := client.Tag.Create().SetTag("production").SetDescription("Production").SetColor("red").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+
+	err = client.Settings.Create().SetTenantID(t.ID).SetSMTPServer("smtp.example.com").SetSMTPPassword("secret").SetTagID(tag.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create settings")
+
+	_, err = suite.source.CreateEnrollmentToken(t.ID, EnrollmentTokenSites{DefaultID: s.ID}, "Office", "11111111-2222-3333-4444-555555555555", 10, nil, nil)
+	assert.NoError(suite.T(), err, "should create enrollment token")
+
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("agent%d", i)
+		err := client.Agent.Create().SetID(id).SetHostname(id).SetOs("windows").SetNickname(id).AddSiteIDs(s.ID).AddTagIDs(tag.ID).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	for _, uid := range []string{"user1", "user2"} {
+		err = client.User.Create().SetID(uid).SetName(uid).SetEmail(uid + "@example.com").SetCreated(time.Now()).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create user")
+		err = suite.source.AssignUserToTenant(uid, t.ID, UserTenantRoleOperator, true)
+		assert.NoError(suite.T(), err, "should assign user to tenant")
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	_, err = suite.source.ExportTenant(t.ID, zw, nil)
+	assert.NoError(suite.T(), err, "should export tenant")
+	assert.NoError(suite.T(), zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(suite.T(), err, "should be a valid ZIP")
+	suite.bundle, err = ReadTenantImportBundle(zr)
+	assert.NoError(suite.T(), err, "should read the bundle")
+
+	// The target console has a user with the email of user1 but another ID
+	targetClient := enttest.Open(suite.t, "sqlite3", "file:target?mode=memory&_fk=1")
+	targetClient.Agent.Intercept(excludeDeletedAgents())
+	suite.target = Model{Client: targetClient}
+
+	err = targetClient.User.Create().SetID("existing").SetName("Existing").SetEmail("user1@example.com").SetCreated(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create user")
+}
+
+func (suite *TenantImportTestSuite) TestReadTenantImportBundle() {
+	assert.Equal(suite.T(), "DefaultTenant", suite.bundle.Tenant.Description)
+	assert.Len(suite.T(), suite.bundle.Agents, 3)
+	assert.Len(suite.T(), suite.bundle.Users, 2)
+	assert.Empty(suite.T(), suite.bundle.Settings.SMTPPassword, "SMTP password should not be exported")
+	assert.Empty(suite.T(), suite.bundle.EnrollmentTokens[0].Token, "token values should not be exported")
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("manifest.json")
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), json.NewEncoder(w).Encode(TenantExportManifest{SchemaVersion: TenantExportSchemaVersion + 1}))
+	assert.NoError(suite.T(), zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(suite.T(), err)
+	_, err = ReadTenantImportBundle(zr)
+	assert.True(suite.T(), errors.Is(err, ErrInvalidTenantBundle), "should not read bundles with a newer schema version")
+}
+
+func (suite *TenantImportTestSuite) TestPreviewTenantImport() {
+	preview, err := suite.target.PreviewTenantImport(suite.bundle, TenantImportOptions{TenantName: "Imported"})
+	assert.NoError(suite.T(), err, "should preview the import")
+	assert.Equal(suite.T(), 3, preview.Counts["agents.json"])
+	assert.Equal(suite.T(), 1, preview.Counts["tags.json"])
+	assert.Equal(suite.T(), 1, preview.MatchedUsers, "should match users by email")
+	assert.Equal(suite.T(), 1, preview.NewUsers)
+	assert.Empty(suite.T(), preview.Conflicts)
+}
+
+func (suite *TenantImportTestSuite) TestImportTenant() {
+	tenantID, err := suite.target.ImportTenant(suite.bundle, TenantImportOptions{TenantName: "Imported", CreateUsers: false})
+	assert.NoError(suite.T(), err, "should import the tenant")
+
+	client := suite.target.Client
+	sites, err := client.Site.Query().Where(site.HasTenantWith(tenant.ID(tenantID))).All(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), sites, 1, "should import the sites")
+
+	a, err := client.Agent.Query().WithSite().WithTags().Where(agent.ID("agent1")).Only(context.Background())
+	assert.NoError(suite.T(), err, "should import the agents")
+	assert.Equal(suite.T(), sites[0].ID, a.Edges.Site[0].ID, "should map the site of the agents")
+	assert.Equal(suite.T(), "production", a.Edges.Tags[0].Tag, "should map the tags of the agents")
+
+	s, err := client.Settings.Query().WithTag().Where(settings.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+	assert.NoError(suite.T(), err, "should import the settings")
+	assert.Equal(suite.T(), "smtp.example.com", s.SMTPServer)
+	assert.Equal(suite.T(), a.Edges.Tags[0].ID, s.Edges.Tag.ID, "should map the admitted tag")
+
+	token, err := client.EnrollmentToken.Query().Where(enrollmenttoken.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+	assert.NoError(suite.T(), err, "should import the enrollment tokens")
+	assert.NotEqual(suite.T(), "11111111-2222-3333-4444-555555555555", token.Token, "should regenerate token values")
+	assert.NotEmpty(suite.T(), token.Token)
+
+	members, err := client.UserTenant.Query().Where(usertenant.TenantID(tenantID)).All(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), members, 1, "should skip the users that don't exist")
+	assert.Equal(suite.T(), "existing", members[0].UserID, "should match users by email")
+	assert.Equal(suite.T(), usertenant.RoleOperator, members[0].Role)
+}
+
+func (suite *TenantImportTestSuite) TestImportTenantCreateUsers() {
+	tenantID, err := suite.target.ImportTenant(suite.bundle, TenantImportOptions{TenantName: "Imported", CreateUsers: true})
+	assert.NoError(suite.T(), err, "should import the tenant")
+
+	isMember, err := suite.target.UserHasAccessToTenant("user2", tenantID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), isMember, "should create the users that don't exist")
+}
+
+func (suite *TenantImportTestSuite) TestImportTenantConflicts() {
+	err := suite.target.Client.Agent.Create().SetID("agent1").SetHostname("agent1").SetOs("windows").SetNickname("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	_, err = suite.target.ImportTenant(suite.bundle, TenantImportOptions{TenantName: "Imported"})
+	importErr := &TenantImportError{}
+	if assert.True(suite.T(), errors.As(err, &importErr), "should not import bundles with conflicts") {
+		assert.Equal(suite.T(), []TenantImportRecordError{{File: "agents.json", Record: "agent1", Reason: "an agent with this ID already exists"}}, importErr.Records)
+	}
+
+	taken, err := suite.target.TenantNameTaken("Imported")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), taken, "should not create the tenant")
+}
+
+func (suite *TenantImportTestSuite) TestImportTenantRollback() {
+	// The second agent with the same ID fails when it's saved
+	suite.bundle.Agents = append(suite.bundle.Agents, suite.bundle.Agents[0])
+
+	_, err := suite.target.ImportTenant(suite.bundle, TenantImportOptions{TenantName: "Imported"})
+	importErr := &TenantImportError{}
+	if assert.True(suite.T(), errors.As(err, &importErr), "should fail to import the tenant") {
+		assert.Equal(suite.T(), "agent0", importErr.Records[0].Record, "should report the offending record")
+	}
+
+	count, err := suite.target.Client.Tenant.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count, "should roll back the tenant")
+	count, err = suite.target.Client.Site.Query().Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count, "should roll back the sites")
+}
+
+func TestTenantImportTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantImportTestSuite))
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"slices"
+
+	"entgo.io/ent/dialect/sql"
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/ent/usertenant"
+)
+
+const (
+	// TenantSwitcherLimit is the maximum number of tenants listed by the tenant switcher
+	TenantSwitcherLimit = 20
+	// RecentTenantsLimit is the number of recently used tenants kept for each user
+	RecentTenantsLimit = 5
+)
+
+// SearchUserTenants returns the assignments of the user to the tenants whose name contains the search,
+// the default tenant first and then alphabetically. Only the tenants the user is assigned to are searched
+func (m *Model) SearchUserTenants(userID, search string, limit int) ([]*ent.UserTenant, error) {
+	query := m.Client.UserTenant.Query().Where(usertenant.UserID(userID))
+	if search != "" {
+		query = query.Where(usertenant.HasTenantWith(tenant.DescriptionContainsFold(search)))
+	}
+
+	return query.
+		WithTenant().
+		Order(usertenant.ByIsDefault(sql.OrderDesc()), usertenant.ByTenantField(tenant.FieldDescription)).
+		Limit(limit).
+		All(context.Background())
+}
+
+// GetRecentUserTenants returns the assignments of the user to the tenants used recently, the latest first.
+// The tenants the user has been removed from since then are skipped
+func (m *Model) GetRecentUserTenants(userID string) ([]*ent.UserTenant, error) {
+	u, err := m.Client.User.Get(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(u.RecentTenants) == 0 {
+		return []*ent.UserTenant{}, nil
+	}
+
+	userTenants, err := m.Client.UserTenant.Query().
+		Where(usertenant.UserID(userID), usertenant.TenantIDIn(u.RecentTenants...)).
+		WithTenant().
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(userTenants, func(a, b *ent.UserTenant) int {
+		return slices.Index(u.RecentTenants, a.TenantID) - slices.Index(u.RecentTenants, b.TenantID)
+	})
+	return userTenants, nil
+}
+
+// AddRecentTenant puts the tenant at the top of the recently used tenants of the user
+func (m *Model) AddRecentTenant(userID string, tenantID int) error {
+	u, err := m.Client.User.Get(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+
+	recent := []int{tenantID}
+	for _, id := range u.RecentTenants {
+		if id != tenantID && len(recent) < RecentTenantsLimit {
+			recent = append(recent, id)
+		}
+	}
+
+	return m.Client.User.UpdateOneID(userID).SetRecentTenants(recent).Exec(context.Background())
+}
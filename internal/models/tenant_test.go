@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openuem_ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantTestSuite struct {
+	suite.Suite
+	t              enttest.TestingT
+	model          Model
+	tenantID       int
+	secondTenantID int
+}
+
+func (suite *TenantTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client, Cache: NewCache(time.Minute)}
+
+	tenant, err := client.Tenant.Create().SetDescription("TestTenant").SetIsDefault(true).Save(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.tenantID = tenant.ID
+
+	secondTenant, err := client.Tenant.Create().SetDescription("SecondTenant").Save(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.secondTenantID = secondTenant.ID
+}
+
+func (suite *TenantTestSuite) TestTenantAllowedIPs() {
+	allowedIPs, err := suite.model.GetTenantAllowedIPs(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get allowed IPs for tenant without restrictions")
+	assert.Empty(suite.T(), allowedIPs)
+
+	err = suite.model.UpdateTenantAllowedIPs(suite.tenantID, []string{"192.168.1.0/24", "10.0.0.0/8"})
+	assert.NoError(suite.T(), err, "should set allowed IPs")
+
+	allowedIPs, err = suite.model.GetTenantAllowedIPs(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"192.168.1.0/24", "10.0.0.0/8"}, allowedIPs)
+
+	// A change made by another console is seen at once
+	err = suite.model.Client.Tenant.UpdateOneID(suite.tenantID).SetAllowedIPs([]string{"10.0.0.0/8"}).Exec(context.Background())
+	assert.NoError(suite.T(), err)
+	allowedIPs, err = suite.model.GetTenantAllowedIPs(suite.tenantID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"10.0.0.0/8"}, allowedIPs, "should not cache the allowed IPs")
+
+	err = suite.model.UpdateTenantAllowedIPs(suite.tenantID, []string{"192.168.1.0/33"})
+	assert.Error(suite.T(), err, "should reject invalid CIDRs")
+
+	allowedIPs, err = suite.model.GetTenantAllowedIPs(suite.secondTenantID)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), allowedIPs, "other tenants should not be restricted")
+
+	_, err = suite.model.GetTenantAllowedIPs(9999)
+	assert.Equal(suite.T(), true, openuem_ent.IsNotFound(err), "should fail for non existing tenant")
+}
+
+func TestTenantTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantTestSuite))
+}
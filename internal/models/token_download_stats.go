@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/tokendownloadstat"
+)
+
+// TokenDownloadStat is the number of times the configuration of an enrollment token was downloaded in a day
+type TokenDownloadStat struct {
+	Date  time.Time
+	Count int
+}
+
+// GetTokenDownloadStats returns the downloads of the token in the last days, one per day from the oldest
+// to today, with the days without downloads as zero
+func (m *Model) GetTokenDownloadStats(tokenID int, days int) ([]TokenDownloadStat, error) {
+	if days < 1 {
+		days = 1
+	}
+
+	today := downloadStatDate(time.Now())
+	from := today.AddDate(0, 0, -(days - 1))
+
+	rows, err := m.Client.TokenDownloadStat.Query().
+		Where(tokendownloadstat.TokenID(tokenID), tokendownloadstat.DateGTE(from)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[time.Time]int{}
+	for _, r := range rows {
+		counts[downloadStatDate(r.Date)] += r.Count
+	}
+
+	stats := make([]TokenDownloadStat, 0, days)
+	for d := from; !d.After(today); d = d.AddDate(0, 0, 1) {
+		stats = append(stats, TokenDownloadStat{Date: d, Count: counts[d]})
+	}
+	return stats, nil
+}
+
+// countTokenDownload adds a download of the token to the stats of today, it's called in the transaction that
+// counts the use of the token so the stats match the uses
+func countTokenDownload(ctx context.Context, tx *ent.Tx, tokenID int) error {
+	today := downloadStatDate(time.Now())
+
+	n, err := tx.TokenDownloadStat.Update().
+		Where(tokendownloadstat.TokenID(tokenID), tokendownloadstat.Date(today)).
+		AddCount(1).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	return tx.TokenDownloadStat.Create().SetTokenID(tokenID).SetDate(today).SetCount(1).Exec(ctx)
+}
+
+// downloadStatDate is the day of the stats, in UTC so all the consoles agree on it
+func downloadStatDate(t time.Time) time.Time {
+	y, mo, d := t.UTC().Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+}
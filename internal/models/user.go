@@ -11,11 +11,12 @@ import (
 
 	"github.com/alexedwards/argon2id"
 	ent "github.com/open-uem/ent"
+	"github.com/open-uem/ent/predicate"
 	"github.com/open-uem/ent/recoverycode"
 	"github.com/open-uem/ent/user"
 	"github.com/open-uem/ent/usertenant"
 	openuem_nats "github.com/open-uem/nats"
-	"github.com/open-uem/openuem-console/internal/views/admin_views"
+	"github.com/open-uem/openuem-console/internal/auth"
 	"github.com/open-uem/openuem-console/internal/views/filters"
 	"github.com/open-uem/openuem-console/internal/views/partials"
 )
@@ -123,44 +124,45 @@ func (m *Model) AddUser(uid, name, email, phone, country string, authType string
 	query := m.Client.User.Create().SetID(uid).SetName(name).SetEmail(email).SetPhone(phone).SetCountry(country).SetCreated(time.Now())
 
 	switch authType {
-	case admin_views.CERTIFICATES_AUTH:
+	case auth.CERTIFICATES_AUTH:
 		count, err := existQuery.Count(context.Background())
 		if err != nil {
 			return nil, err
 		}
 
 		if count > 0 {
-			return nil, fmt.Errorf("a user with username %s already exists", uid)
+			return nil, fmt.Errorf("%w: a user with username %s already exists", ErrAlreadyExists, uid)
 		}
 
-	case admin_views.OIDC_AUTH:
+	case auth.OIDC_AUTH:
 		count, err := existQuery.Count(context.Background())
 		if err != nil {
 			return nil, err
 		}
 
 		if count > 0 {
-			return nil, fmt.Errorf("a user with username %s already exists", uid)
+			return nil, fmt.Errorf("%w: a user with username %s already exists", ErrAlreadyExists, uid)
 		}
 
 		query.SetOpenid(true)
 		query.SetEmailVerified(true)
 		query.SetRegister(openuem_nats.REGISTER_OIDC_FIRST_LOGIN)
-	case admin_views.PASSWORD_AUTH:
+	case auth.PASSWORD_AUTH:
 		// Check if email already assigned to a different user for the same auth type
 		exist, err := m.Client.User.Query().Where(user.Passwd(true), user.Email(email)).Exist(context.Background())
 		if err != nil {
 			return nil, err
 		}
 		if exist {
-			return nil, fmt.Errorf("%s is already assigned to another account that authenticates with password", email)
+			return nil, fmt.Errorf("%w: %s is already assigned to another account that authenticates with password", ErrAlreadyExists, email)
 		}
 		query.SetRegister(openuem_nats.REGISTER_PASSWORD_LINK_SENT)
 		query.SetEmailVerified(true)
 		query.SetPasswd(true)
 	}
 
-	return query.Save(context.Background())
+	u, err := query.Save(context.Background())
+	return u, dbError(err)
 }
 
 func (m *Model) AddImportedUser(uid, name, email, phone, country string, oidc bool) error {
@@ -191,10 +193,24 @@ func (m *Model) AddOIDCUser(uid, name, email, phone string, emailVerified bool,
 	return nil
 }
 
+// AddLDAPUser creates the account of a user the first time it logs in with LDAP, it has no password hash
+// as the password is checked by the directory
+func (m *Model) AddLDAPUser(uid, name, email, phone string, autoApprove bool) error {
+	query := m.Client.User.Create().SetID(uid).SetName(name).SetEmail(email).SetPhone(phone).SetCreated(time.Now()).SetLdap(true)
+
+	if autoApprove {
+		query.SetRegister(openuem_nats.REGISTER_APPROVED)
+	} else {
+		query.SetRegister(openuem_nats.REGISTER_IN_REVIEW)
+	}
+
+	return query.Exec(context.Background())
+}
+
 func (m *Model) UpdateUser(uid, name, email, phone, country string) error {
 	u, err := m.Client.User.Get(context.Background(), uid)
 	if err != nil {
-		return err
+		return dbError(err)
 	}
 
 	if u.Passwd && email != u.Email {
@@ -204,12 +220,26 @@ func (m *Model) UpdateUser(uid, name, email, phone, country string) error {
 			return err
 		}
 		if exist {
-			return errors.New("this email is already assigned to another account that authenticates with password")
+			return fmt.Errorf("%w: this email is already assigned to another account that authenticates with password", ErrAlreadyExists)
 		}
 	}
 
 	query := m.Client.User.UpdateOneID(uid).SetName(name).SetEmail(email).SetPhone(phone).SetCountry(country).SetModified(time.Now())
-	return query.Exec(context.Background())
+	return dbError(query.Exec(context.Background()))
+}
+
+func (m *Model) UpdateUserLanguage(uid, language string) error {
+	return m.Client.User.UpdateOneID(uid).SetLanguage(language).Exec(context.Background())
+}
+
+func (m *Model) UpdateUserTheme(uid, theme string) error {
+	return m.Client.User.UpdateOneID(uid).SetTheme(theme).Exec(context.Background())
+}
+
+// UpdateUserDateTime saves the timezone and the date format chosen by the user, empty values use the
+// settings of the tenant
+func (m *Model) UpdateUserDateTime(uid, timezone, dateFormat string) error {
+	return m.Client.User.UpdateOneID(uid).SetTimezone(timezone).SetDateFormat(dateFormat).Exec(context.Background())
 }
 
 func (m *Model) RegisterUser(uid, name, email, phone, country, password string, authType string) error {
@@ -223,7 +253,7 @@ func (m *Model) RegisterUser(uid, name, email, phone, country, password string,
 		return fmt.Errorf("username %s already exists", uid)
 	}
 
-	if authType == admin_views.PASSWORD_AUTH {
+	if authType == auth.PASSWORD_AUTH {
 		userID := m.GetUserIDByEmail(email)
 		if userID != "" && userID != uid {
 			return fmt.Errorf("email %s already assigned to %s", email, userID)
@@ -232,11 +262,11 @@ func (m *Model) RegisterUser(uid, name, email, phone, country, password string,
 
 	query := m.Client.User.Create().SetID(uid).SetName(name).SetEmail(email).SetPhone(phone).SetCountry(country).SetCreated(time.Now()).SetRegister(openuem_nats.REGISTER_IN_REVIEW)
 
-	if authType == admin_views.PASSWORD_AUTH {
+	if authType == auth.PASSWORD_AUTH {
 		query.SetPasswd(true)
 	}
 
-	if authType == admin_views.OIDC_AUTH {
+	if authType == auth.OIDC_AUTH {
 		query.SetOpenid(true)
 	}
 
@@ -244,7 +274,8 @@ func (m *Model) RegisterUser(uid, name, email, phone, country, password string,
 }
 
 func (m *Model) GetUserById(uid string) (*ent.User, error) {
-	return m.Client.User.Get(context.Background(), uid)
+	u, err := m.Client.User.Get(context.Background(), uid)
+	return u, dbError(err)
 }
 
 func (m *Model) ConsumeRecoveryCode(uid string, code string) bool {
@@ -286,8 +317,17 @@ func (m *Model) ConfirmLogIn(uid string) error {
 	return m.Client.User.Update().SetRegister(openuem_nats.REGISTER_COMPLETE).SetCertClearPassword("").Where(user.ID(uid)).Exec(context.Background())
 }
 
+// UpdateUserLastSeen records when the user was last active in the console. It's only written if the stored
+// value is older than the interval, so the requests of the same session don't write it each time
+func (m *Model) UpdateUserLastSeen(uid string, when time.Time, interval time.Duration) error {
+	return m.Client.User.Update().
+		Where(user.ID(uid), user.Or(user.LastSeenAtIsNil(), user.LastSeenAtLT(when.Add(-interval)))).
+		SetLastSeenAt(when).
+		Exec(context.Background())
+}
+
 func (m *Model) DeleteUser(uid string) error {
-	return m.Client.User.DeleteOneID(uid).Exec(context.Background())
+	return dbError(m.Client.User.DeleteOneID(uid).Exec(context.Background()))
 }
 
 func applyUsersFilter(query *ent.UserQuery, f filters.UserFilter) {
@@ -429,22 +469,31 @@ func (m *Model) CreateDefaultAdminPassword(reset bool) error {
 }
 
 func (m *Model) ChangePassword(username string, password string) error {
-	exist, err := m.Client.User.Query().Where(user.ID(username)).Exist(context.Background())
+	u, err := m.Client.User.Query().Select(user.FieldHash, user.FieldPasswordHistory).Where(user.ID(username)).Only(context.Background())
 	if err != nil {
+		if ent.IsNotFound(err) {
+			return errors.New("user not found")
+		}
 		return err
 	}
 
-	if exist {
-		hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
-		if err != nil {
-			return err
-		}
+	policy, err := m.GetPasswordPolicy()
+	if err != nil {
+		return err
+	}
 
-		// Save password
-		return m.Client.User.Update().Where(user.ID(username)).SetRegister("users.completed").SetHash(hash).Exec(context.Background())
-	} else {
-		return errors.New("user not found")
+	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	if err != nil {
+		return err
 	}
+
+	// Save password, the previous hashes are kept so they can't be reused
+	return m.Client.User.UpdateOneID(username).
+		SetRegister("users.completed").
+		SetHash(hash).
+		SetPasswordHistory(policy.NextHistory(u.Hash, u.PasswordHistory)).
+		SetPasswordChangedAt(time.Now()).
+		Exec(context.Background())
 }
 
 func (m *Model) SaveTOTPSecretKey(username string, secret string) error {
@@ -515,6 +564,22 @@ func (m *Model) GetUserIDByEmail(email string) string {
 	return user.ID
 }
 
+// GetPasswordResetUser returns the account with the username or email that can reset its password by
+// email, nil if there's none
+func (m *Model) GetPasswordResetUser(identifier string) *ent.User {
+	if identifier == "" {
+		return nil
+	}
+
+	for _, p := range []predicate.User{user.ID(identifier), user.Email(identifier)} {
+		u, err := m.Client.User.Query().Where(p, user.Passwd(true), user.Ldap(false), user.EmailNEQ("")).First(context.Background())
+		if err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
 func (m *Model) SaveForgotCode(username string, code string) error {
 	expiresAt := time.Now().Add(3 * time.Hour)
 	if err := m.Client.User.UpdateOneID(username).SetForgotPasswordCode(code).SetForgotPasswordCodeExpiresAt(expiresAt).Exec(context.Background()); err != nil {
@@ -360,6 +360,43 @@ func (suite *UserTestSuite) TestUpdateUser() {
 	assert.Equal(suite.T(), "ES", user.Country, "user should have ES country")
 }
 
+func (suite *UserTestSuite) TestUpdateUserLanguage() {
+	err := suite.model.UpdateUserLanguage("user9", "de")
+	assert.Equal(suite.T(), true, openuem_ent.IsNotFound(err), "cannot update non existing user")
+
+	err = suite.model.UpdateUserLanguage("user2", "de")
+	assert.NoError(suite.T(), err, "should update user language")
+
+	user, err := suite.model.GetUserById("user2")
+	assert.NoError(suite.T(), err, "should get recently updated user")
+	assert.Equal(suite.T(), "de", user.Language, "user should have de language")
+}
+
+func (suite *UserTestSuite) TestUpdateUserTheme() {
+	err := suite.model.UpdateUserTheme("user9", "dark")
+	assert.Equal(suite.T(), true, openuem_ent.IsNotFound(err), "cannot update non existing user")
+
+	err = suite.model.UpdateUserTheme("user2", "dark")
+	assert.NoError(suite.T(), err, "should update user theme")
+
+	user, err := suite.model.GetUserById("user2")
+	assert.NoError(suite.T(), err, "should get recently updated user")
+	assert.Equal(suite.T(), "dark", user.Theme, "user should have dark theme")
+}
+
+func (suite *UserTestSuite) TestUpdateUserDateTime() {
+	err := suite.model.UpdateUserDateTime("user9", "Europe/Berlin", "iso")
+	assert.Equal(suite.T(), true, openuem_ent.IsNotFound(err), "cannot update non existing user")
+
+	err = suite.model.UpdateUserDateTime("user2", "Europe/Berlin", "iso")
+	assert.NoError(suite.T(), err, "should update user date and time preferences")
+
+	user, err := suite.model.GetUserById("user2")
+	assert.NoError(suite.T(), err, "should get recently updated user")
+	assert.Equal(suite.T(), "Europe/Berlin", user.Timezone, "user should have Europe/Berlin timezone")
+	assert.Equal(suite.T(), "iso", user.DateFormat, "user should have iso date format")
+}
+
 func (suite *UserTestSuite) TestRegisterUser() {
 	err := suite.model.RegisterUser("user7", "User7", "user7@example.com", "", "ES", "apassword", "certificate")
 	assert.NoError(suite.T(), err, "should register a user")
@@ -429,6 +466,28 @@ func (suite *UserTestSuite) TestDeleteUser() {
 	assert.Equal(suite.T(), 6, count, "should count 6 users")
 }
 
+func (suite *UserTestSuite) TestUpdateUserLastSeen() {
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err := suite.model.UpdateUserLastSeen("user1", seen, time.Minute)
+	assert.NoError(suite.T(), err, "should record when the user was seen")
+
+	err = suite.model.UpdateUserLastSeen("user1", seen.Add(30*time.Second), time.Minute)
+	assert.NoError(suite.T(), err)
+	user, err := suite.model.GetUserById("user1")
+	assert.NoError(suite.T(), err)
+	if assert.NotNil(suite.T(), user.LastSeenAt) {
+		assert.True(suite.T(), seen.Equal(*user.LastSeenAt), "should not write it again within the interval")
+	}
+
+	err = suite.model.UpdateUserLastSeen("user1", seen.Add(2*time.Minute), time.Minute)
+	assert.NoError(suite.T(), err)
+	user, err = suite.model.GetUserById("user1")
+	assert.NoError(suite.T(), err)
+	if assert.NotNil(suite.T(), user.LastSeenAt) {
+		assert.True(suite.T(), seen.Add(2*time.Minute).Equal(*user.LastSeenAt), "should write it once the interval has passed")
+	}
+}
+
 func TestUserTestSuite(t *testing.T) {
 	suite.Run(t, new(UserTestSuite))
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 
+	"entgo.io/ent/dialect/sql"
 	ent "github.com/open-uem/ent"
 	"github.com/open-uem/ent/tenant"
 	"github.com/open-uem/ent/user"
 	"github.com/open-uem/ent/usertenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
 // UserTenantRole represents the role a user has within a tenant
@@ -19,8 +22,26 @@ const (
 	UserTenantRoleUser     UserTenantRole = "user"     // Read-only access
 )
 
-// AssignUserToTenant assigns a user to a tenant with the specified role
+// userTenantRoleLevels orders the roles from less to more permissions
+var userTenantRoleLevels = map[UserTenantRole]int{
+	UserTenantRoleUser:     1,
+	UserTenantRoleOperator: 2,
+	UserTenantRoleAdmin:    3,
+}
+
+// AtLeast returns true if the role has the same or more permissions than the other role.
+// Unknown roles have no permissions
+func (r UserTenantRole) AtLeast(other UserTenantRole) bool {
+	level, ok := userTenantRoleLevels[r]
+	return ok && level >= userTenantRoleLevels[other]
+}
+
+// AssignUserToTenant assigns a user to a tenant with the specified role. The first tenant assigned to
+// a user becomes its default tenant whatever isDefault is
 func (m *Model) AssignUserToTenant(userID string, tenantID int, role UserTenantRole, isDefault bool) error {
+	defer m.Cache.Invalidate(cacheKeyTenants)
+	defer m.roleCache().Delete(userID, tenantID)
+
 	// Check if assignment already exists
 	exists, err := m.Client.UserTenant.Query().
 		Where(
@@ -31,11 +52,20 @@ func (m *Model) AssignUserToTenant(userID string, tenantID int, role UserTenantR
 		return err
 	}
 	if exists {
-		return fmt.Errorf("user %s is already assigned to tenant %d", userID, tenantID)
+		return fmt.Errorf("%w: user %s is already assigned to tenant %d", ErrAlreadyExists, userID, tenantID)
+	}
+
+	// The first tenant of a user is always its default tenant, otherwise it would have none
+	hasTenants, err := m.Client.UserTenant.Query().Where(usertenant.UserID(userID)).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !hasTenants {
+		isDefault = true
 	}
 
 	// If this should be the default, remove default from other assignments
-	if isDefault {
+	if isDefault && hasTenants {
 		err = m.Client.UserTenant.Update().
 			Where(usertenant.UserID(userID)).
 			SetIsDefault(false).
@@ -45,33 +75,38 @@ func (m *Model) AssignUserToTenant(userID string, tenantID int, role UserTenantR
 		}
 	}
 
-	return m.Client.UserTenant.Create().
+	return dbError(m.Client.UserTenant.Create().
 		SetUserID(userID).
 		SetTenantID(tenantID).
 		SetRole(usertenant.Role(role)).
 		SetIsDefault(isDefault).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // RemoveUserFromTenant removes a user from a tenant
 func (m *Model) RemoveUserFromTenant(userID string, tenantID int) error {
+	defer m.Cache.Invalidate(cacheKeyTenants)
+	defer m.roleCache().Delete(userID, tenantID)
+
 	_, err := m.Client.UserTenant.Delete().
 		Where(
 			usertenant.UserID(userID),
 			usertenant.TenantID(tenantID),
 		).Exec(context.Background())
-	return err
+	return dbError(err)
 }
 
 // UpdateUserTenantRole updates the role of a user within a tenant
 func (m *Model) UpdateUserTenantRole(userID string, tenantID int, role UserTenantRole) error {
-	return m.Client.UserTenant.Update().
+	defer m.roleCache().Delete(userID, tenantID)
+
+	return dbError(m.Client.UserTenant.Update().
 		Where(
 			usertenant.UserID(userID),
 			usertenant.TenantID(tenantID),
 		).
 		SetRole(usertenant.Role(role)).
-		Exec(context.Background())
+		Exec(context.Background()))
 }
 
 // SetUserDefaultTenant sets the default tenant for a user
@@ -97,9 +132,11 @@ func (m *Model) SetUserDefaultTenant(userID string, tenantID int) error {
 
 // GetUserTenants returns all tenants a user has access to
 func (m *Model) GetUserTenants(userID string) ([]*ent.Tenant, error) {
+	// The default tenant of the user comes first in the tenant switcher, then the others alphabetically
 	userTenants, err := m.Client.UserTenant.Query().
 		Where(usertenant.UserID(userID)).
 		WithTenant().
+		Order(usertenant.ByIsDefault(sql.OrderDesc()), usertenant.ByTenantField(tenant.FieldDescription)).
 		All(context.Background())
 	if err != nil {
 		return nil, err
@@ -122,7 +159,8 @@ func (m *Model) GetUserTenantsWithRoles(userID string) ([]*ent.UserTenant, error
 		All(context.Background())
 }
 
-// GetUserDefaultTenant returns the default tenant for a user
+// GetUserDefaultTenant returns the default tenant for a user, or ErrNoTenantsAssigned if
+// the user isn't assigned to any tenant
 func (m *Model) GetUserDefaultTenant(userID string) (*ent.Tenant, error) {
 	ut, err := m.Client.UserTenant.Query().
 		Where(
@@ -138,6 +176,9 @@ func (m *Model) GetUserDefaultTenant(userID string) (*ent.Tenant, error) {
 			WithTenant().
 			First(context.Background())
 		if err != nil {
+			if ent.IsNotFound(err) {
+				return nil, ErrNoTenantsAssigned
+			}
 			return nil, err
 		}
 	}
@@ -155,6 +196,10 @@ func (m *Model) UserHasAccessToTenant(userID string, tenantID int) (bool, error)
 
 // GetUserRoleInTenant returns the role of a user in a specific tenant
 func (m *Model) GetUserRoleInTenant(userID string, tenantID int) (UserTenantRole, error) {
+	if role, ok := m.roleCache().Get(userID, tenantID); ok {
+		return role, nil
+	}
+
 	ut, err := m.Client.UserTenant.Query().
 		Where(
 			usertenant.UserID(userID),
@@ -163,7 +208,10 @@ func (m *Model) GetUserRoleInTenant(userID string, tenantID int) (UserTenantRole
 	if err != nil {
 		return "", err
 	}
-	return UserTenantRole(ut.Role), nil
+
+	role := UserTenantRole(ut.Role)
+	m.roleCache().Set(userID, tenantID, role)
+	return role, nil
 }
 
 // IsUserTenantAdmin checks if a user is an admin in a specific tenant
@@ -194,12 +242,120 @@ func (m *Model) GetTenantUsers(tenantID int) ([]*ent.User, error) {
 	return users, nil
 }
 
-// GetTenantUsersWithRoles returns all user assignments for a tenant including roles
-func (m *Model) GetTenantUsersWithRoles(tenantID int) ([]*ent.UserTenant, error) {
-	return m.Client.UserTenant.Query().
-		Where(usertenant.TenantID(tenantID)).
+// GetEffectiveTenantUsers returns the users with access to a tenant, those assigned to it and the admins of
+// the hoster tenant, the main tenant, who manage every tenant. The union is resolved in a single query
+func (m *Model) GetEffectiveTenantUsers(tenantID int) ([]*ent.User, error) {
+	return m.Client.User.Query().
+		Where(func(s *sql.Selector) {
+			assigned := sql.Table(usertenant.Table)
+			hosters := sql.Table(usertenant.Table)
+			tenants := sql.Table(tenant.Table)
+			s.Where(sql.In(s.C(user.FieldID),
+				sql.Select(assigned.C(usertenant.FieldUserID)).
+					From(assigned).
+					Where(sql.EQ(assigned.C(usertenant.FieldTenantID), tenantID)).
+					Union(
+						sql.Select(hosters.C(usertenant.FieldUserID)).
+							From(hosters).
+							Where(sql.And(
+								sql.In(hosters.C(usertenant.FieldTenantID), sql.Select(sql.Min(tenants.C(tenant.FieldID))).From(tenants)),
+								sql.EQ(hosters.C(usertenant.FieldRole), string(usertenant.RoleAdmin)),
+							)),
+					),
+			))
+		}).
+		Order(ent.Asc(user.FieldID)).
+		All(context.Background())
+}
+
+// GetTenantMembersByPage returns a page of the user assignments of a tenant, the members seen most recently
+// first by default. Members who have never been seen are shown last
+func (m *Model) GetTenantMembersByPage(tenantID int, p partials.PaginationAndSort, f filters.TenantMemberFilter) ([]*ent.UserTenant, error) {
+	query := m.Client.UserTenant.Query().Where(usertenant.TenantID(tenantID)).WithUser()
+
+	applyTenantMembersFilter(query, f)
+
+	order := sql.OrderDesc()
+	if p.SortOrder == "asc" {
+		order = sql.OrderAsc()
+	}
+
+	switch p.SortBy {
+	case "name":
+		query.Order(usertenant.ByUserField(user.FieldName, order))
+	case "email":
+		query.Order(usertenant.ByUserField(user.FieldEmail, order))
+	case "role":
+		query.Order(usertenant.ByRole(order))
+	case "username":
+		query.Order(usertenant.ByUserID(order))
+	case "last_seen":
+		query.Order(usertenant.ByUserField(user.FieldLastSeenAt, order, sql.OrderNullsLast()), usertenant.ByUserID())
+	default:
+		query.Order(usertenant.ByUserField(user.FieldLastSeenAt, sql.OrderDesc(), sql.OrderNullsLast()), usertenant.ByUserID())
+	}
+
+	return query.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize).All(context.Background())
+}
+
+func (m *Model) CountTenantMembers(tenantID int, f filters.TenantMemberFilter) (int, error) {
+	query := m.Client.UserTenant.Query().Where(usertenant.TenantID(tenantID))
+
+	applyTenantMembersFilter(query, f)
+
+	return query.Count(context.Background())
+}
+
+func applyTenantMembersFilter(query *ent.UserTenantQuery, f filters.TenantMemberFilter) {
+	if len(f.Name) > 0 {
+		query.Where(usertenant.HasUserWith(user.Or(
+			user.IDContainsFold(f.Name),
+			user.NameContainsFold(f.Name),
+			user.EmailContainsFold(f.Name),
+		)))
+	}
+
+	roles := []usertenant.Role{}
+	for _, r := range f.RoleOptions {
+		if usertenant.RoleValidator(usertenant.Role(r)) == nil {
+			roles = append(roles, usertenant.Role(r))
+		}
+	}
+	if len(roles) > 0 {
+		query.Where(usertenant.RoleIn(roles...))
+	}
+}
+
+// GetTenantAdminEmails returns the email addresses of the tenant's admins
+func (m *Model) GetTenantAdminEmails(tenantID int) ([]string, error) {
+	userTenants, err := m.Client.UserTenant.Query().
+		Where(
+			usertenant.TenantID(tenantID),
+			usertenant.RoleEQ(usertenant.RoleAdmin),
+		).
 		WithUser().
 		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []string{}
+	for _, ut := range userTenants {
+		if ut.Edges.User != nil && ut.Edges.User.Email != "" {
+			emails = append(emails, ut.Edges.User.Email)
+		}
+	}
+	return emails, nil
+}
+
+// CountTenantAdmins returns the number of users with admin role in the tenant
+func (m *Model) CountTenantAdmins(tenantID int) (int, error) {
+	return m.Client.UserTenant.Query().
+		Where(
+			usertenant.TenantID(tenantID),
+			usertenant.RoleEQ(usertenant.RoleAdmin),
+		).
+		Count(context.Background())
 }
 
 // GetMainTenant returns the main tenant (the one with the lowest ID)
@@ -211,10 +367,20 @@ func (m *Model) GetMainTenant() (*ent.Tenant, error) {
 
 // GetTenantsWhereUserIsAdmin returns all tenants where the user has admin role
 func (m *Model) GetTenantsWhereUserIsAdmin(userID string) ([]*ent.Tenant, error) {
+	return m.getTenantsWhereUserHasRole(userID, usertenant.RoleAdmin)
+}
+
+// GetTenantsWhereUserHasWriteAccess returns all tenants where the user can change the settings,
+// those where the user has admin or operator role
+func (m *Model) GetTenantsWhereUserHasWriteAccess(userID string) ([]*ent.Tenant, error) {
+	return m.getTenantsWhereUserHasRole(userID, usertenant.RoleAdmin, usertenant.RoleOperator)
+}
+
+func (m *Model) getTenantsWhereUserHasRole(userID string, roles ...usertenant.Role) ([]*ent.Tenant, error) {
 	userTenants, err := m.Client.UserTenant.Query().
 		Where(
 			usertenant.UserID(userID),
-			usertenant.RoleEQ(usertenant.RoleAdmin),
+			usertenant.RoleIn(roles...),
 		).
 		WithTenant().
 		All(context.Background())
@@ -240,6 +406,35 @@ func (m *Model) IsMainTenant(tenantID int) (bool, error) {
 	return mainTenant.ID == tenantID, nil
 }
 
+// CheckMainTenantDeletion checks that the tenant that becomes the main tenant when the tenant is
+// deleted has at least one admin, otherwise nobody could manage the global settings
+func (m *Model) CheckMainTenantDeletion(tenantID int) error {
+	isMain, err := m.IsMainTenant(tenantID)
+	if err != nil || !isMain {
+		return dbError(err)
+	}
+
+	next, err := m.Client.Tenant.Query().
+		Where(tenant.IDNEQ(tenantID)).
+		Order(ent.Asc(tenant.FieldID)).
+		First(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	admins, err := m.CountTenantAdmins(next.ID)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return ErrTenantHasNoAdmin
+	}
+	return nil
+}
+
 // IsMainTenantAdmin checks if a user is an admin in the main tenant
 func (m *Model) IsMainTenantAdmin(userID string) (bool, error) {
 	mainTenant, err := m.GetMainTenant()
@@ -256,28 +451,57 @@ func (m *Model) IsMainTenantAdmin(userID string) (bool, error) {
 
 // GetTenantsForUser returns all tenants the user is explicitly assigned to
 func (m *Model) GetTenantsForUser(userID string) ([]*ent.Tenant, error) {
-	return m.GetUserTenants(userID)
+	return cached(m.Cache, cacheKeyTenants+"-user-"+userID, func() ([]*ent.Tenant, error) {
+		return m.GetUserTenants(userID)
+	})
 }
 
-// GetUsersNotInTenant returns all users that are NOT assigned to the given tenant
+// GetUsersNotInTenant returns all users that are NOT assigned to the given tenant.
+// The exclusion is pushed to the database as a NOT EXISTS subquery so the
+// tenant's assignments don't have to be loaded in memory
 func (m *Model) GetUsersNotInTenant(tenantID int) ([]*ent.User, error) {
-	// Get user IDs already in this tenant
-	existingUTs, err := m.Client.UserTenant.Query().
-		Where(usertenant.TenantID(tenantID)).
+	return m.Client.User.Query().
+		Where(func(s *sql.Selector) {
+			t := sql.Table(usertenant.Table)
+			s.Where(sql.NotExists(
+				sql.Select(t.C(usertenant.FieldUserID)).
+					From(t).
+					Where(sql.And(
+						sql.ColumnsEQ(t.C(usertenant.FieldUserID), s.C(user.FieldID)),
+						sql.EQ(t.C(usertenant.FieldTenantID), tenantID),
+					)),
+			))
+		}).
 		All(context.Background())
+}
+
+// RequestTenantAccess asks the admins of the main tenant, by email, to assign a tenant to a user without
+// tenants. It returns false if the email can't be sent because SMTP isn't configured or the admins have no email
+func (m *Model) RequestTenantAccess(userID string) (bool, error) {
+	if !m.IsSMTPConfigured() {
+		return false, nil
+	}
+
+	u, err := m.Client.User.Get(context.Background(), userID)
 	if err != nil {
-		return nil, err
+		return false, dbError(err)
 	}
 
-	existingUserIDs := make([]string, 0, len(existingUTs))
-	for _, ut := range existingUTs {
-		existingUserIDs = append(existingUserIDs, ut.UserID)
+	mainTenant, err := m.GetMainTenant()
+	if err != nil {
+		return false, err
 	}
 
-	// Query all users NOT in that list
-	query := m.Client.User.Query()
-	if len(existingUserIDs) > 0 {
-		query.Where(user.IDNotIn(existingUserIDs...))
+	recipients, err := m.GetTenantAdminEmails(mainTenant.ID)
+	if err != nil || len(recipients) == 0 {
+		return false, err
 	}
-	return query.All(context.Background())
+
+	err = m.EnqueueEmail(Email{
+		Kind:    EmailKindAccessRequest,
+		To:      recipients,
+		Subject: "OpenUEM access request",
+		Body:    fmt.Sprintf("The user %s (%s, %s) has signed in but isn't assigned to any tenant, and asks to be assigned to one.\n", u.ID, u.Name, u.Email),
+	})
+	return err == nil, err
 }
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/predicate"
+	"github.com/open-uem/ent/settings"
+	"github.com/open-uem/ent/site"
+	"github.com/open-uem/ent/tenant"
+	"github.com/open-uem/openuem-console/internal/views/filters"
+	"github.com/open-uem/openuem-console/internal/views/helpers"
+	"github.com/open-uem/openuem-console/internal/views/locales"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/wneessen/go-mail"
+)
+
+// WeeklyReport is the agent status summary sent to tenant admins every week
+type WeeklyReport struct {
+	TenantID            int
+	TenantName          string
+	From                time.Time
+	To                  time.Time
+	TotalAgents         int
+	OnlineAgents        int
+	LateAgents          int
+	OfflineAgents       int
+	NewEnrollments      int
+	WaitingForAdmission int
+	PendingUpdates      int
+	DisabledAntivirus   int
+	OutdatedAntivirus   int
+	// CustomReports are the custom reports of the tenant scheduled to be summarized every week
+	CustomReports []WeeklyCustomReport
+	// Language is the language of the tenant, the report is written in it
+	Language string
+}
+
+// WeeklyCustomReport is the number of rows of a scheduled custom report, by group if it's grouped. Error is
+// set if the report couldn't be run, e.g. a custom field it uses has been removed
+type WeeklyCustomReport struct {
+	Name   string
+	Total  int
+	Groups []CustomReportGroup
+	Error  string
+}
+
+// BuildWeeklyReport returns the agent status summary of the last seven days for a tenant
+func (m *Model) BuildWeeklyReport(tenantID int) (*WeeklyReport, error) {
+	var err error
+
+	t, err := m.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := WeeklyReport{
+		TenantID:   t.ID,
+		TenantName: t.Description,
+		Language:   m.GetTenantLanguage(tenantID),
+		To:         time.Now(),
+	}
+	// The period is shown in the timezone of the tenant, or in server time if it can't be read
+	if dt, err := m.GetDateTimeSettings(strconv.Itoa(tenantID)); err == nil {
+		report.To = report.To.In(helpers.LoadTimezone(dt.Timezone))
+	}
+	report.From = report.To.AddDate(0, 0, -7)
+
+	c := &partials.CommonInfo{TenantID: strconv.Itoa(tenantID), SiteID: "-1"}
+
+	if report.TotalAgents, err = m.CountAllAgents(filters.AgentFilter{}, true, c); err != nil {
+		return nil, err
+	}
+
+	// Online, late and offline follow the thresholds of the tenant and its tags
+	reportCounts, err := m.CountAgentsByReportStatus(c)
+	if err != nil {
+		return nil, err
+	}
+	report.OnlineAgents = reportCounts[AgentReportOnTime]
+	report.LateAgents = reportCounts[AgentReportLate]
+	report.OfflineAgents = reportCounts[AgentReportOffline]
+
+	if report.NewEnrollments, err = m.Client.Agent.Query().Where(agent.FirstContactGTE(report.From), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).Count(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if report.WaitingForAdmission, err = m.CountWaitingForAdmissionAgents(c); err != nil {
+		return nil, err
+	}
+
+	if report.PendingUpdates, err = m.CountPendingUpdateAgents(c); err != nil {
+		return nil, err
+	}
+
+	if report.DisabledAntivirus, err = m.CountDisabledAntivirusAgents(c); err != nil {
+		return nil, err
+	}
+
+	if report.OutdatedAntivirus, err = m.CountOutdatedAntivirusDatabaseAgents(c); err != nil {
+		return nil, err
+	}
+
+	scheduled, err := m.GetScheduledCustomReports(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range scheduled {
+		summary := WeeklyCustomReport{Name: r.Name}
+		d, err := CustomReportDefinitionOf(r)
+		if err == nil {
+			var result *CustomReportResult
+			if result, err = m.RunCustomReport(d, 1, c); err == nil {
+				summary.Total = result.Total
+				summary.Groups = result.Groups
+			}
+		}
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		report.CustomReports = append(report.CustomReports, summary)
+	}
+
+	return &report, nil
+}
+
+// CriticalAlerts returns a description for every alert found in the report
+func (r *WeeklyReport) CriticalAlerts(ctx context.Context) []string {
+	alerts := []string{}
+
+	if r.OfflineAgents > 0 {
+		alerts = append(alerts, i18n.T(ctx, "weekly_report.offline_alert", r.OfflineAgents))
+	}
+	if r.WaitingForAdmission > 0 {
+		alerts = append(alerts, i18n.T(ctx, "weekly_report.admission_alert", r.WaitingForAdmission))
+	}
+	if r.PendingUpdates > 0 {
+		alerts = append(alerts, i18n.T(ctx, "weekly_report.updates_alert", r.PendingUpdates))
+	}
+	if r.DisabledAntivirus > 0 {
+		alerts = append(alerts, i18n.T(ctx, "weekly_report.antivirus_disabled_alert", r.DisabledAntivirus))
+	}
+	if r.OutdatedAntivirus > 0 {
+		alerts = append(alerts, i18n.T(ctx, "weekly_report.antivirus_outdated_alert", r.OutdatedAntivirus))
+	}
+
+	return alerts
+}
+
+// Subject returns the subject of the report email
+func (r *WeeklyReport) Subject(ctx context.Context) string {
+	return i18n.T(ctx, "weekly_report.subject", r.TenantName)
+}
+
+// Body returns the report as plain text
+func (r *WeeklyReport) Body(ctx context.Context) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.title", r.TenantName))
+	fmt.Fprintf(&b, "%s\n\n", i18n.T(ctx, "weekly_report.period", r.From.Format("2006-01-02"), r.To.Format("2006-01-02")))
+	fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.total_agents", r.TotalAgents))
+	fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.online_agents", r.OnlineAgents))
+	fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.late_agents", r.LateAgents))
+	fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.offline_agents", r.OfflineAgents))
+	fmt.Fprintf(&b, "%s\n\n", i18n.T(ctx, "weekly_report.new_enrollments", r.NewEnrollments))
+
+	alerts := r.CriticalAlerts(ctx)
+	if len(alerts) == 0 {
+		fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.no_critical_alerts"))
+	} else {
+		fmt.Fprintf(&b, "%s\n", i18n.T(ctx, "weekly_report.critical_alerts"))
+		for _, alert := range alerts {
+			fmt.Fprintf(&b, "  - %s\n", alert)
+		}
+	}
+
+	if len(r.CustomReports) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", i18n.T(ctx, "weekly_report.scheduled_reports"))
+		for _, report := range r.CustomReports {
+			if report.Error != "" {
+				fmt.Fprintf(&b, "  - %s\n", i18n.T(ctx, "weekly_report.report_failed", report.Name, report.Error))
+				continue
+			}
+			fmt.Fprintf(&b, "  - %s\n", i18n.T(ctx, "weekly_report.report_rows", report.Name, report.Total))
+			for _, g := range report.Groups {
+				value := g.Value
+				if value == "" {
+					value = i18n.T(ctx, "weekly_report.empty_value")
+				}
+				fmt.Fprintf(&b, "      %s: %d\n", value, g.Count)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Context returns a context with the language of the report to translate it
+func (r *WeeklyReport) Context() context.Context {
+	ctx, err := ctxi18n.WithLocale(context.Background(), r.Language)
+	if err != nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// GetTenantLanguage returns the language set in the settings of the tenant, or in the global settings if
+// the tenant doesn't set a supported one. English is used if none is set
+func (m *Model) GetTenantLanguage(tenantID int) string {
+	for _, where := range []predicate.Settings{settings.HasTenantWith(tenant.ID(tenantID)), settings.Not(settings.HasTenant())} {
+		s, err := m.Client.Settings.Query().Where(where).Select(settings.FieldLanguage).Only(context.Background())
+		if err == nil && locales.IsSupported(s.Language) {
+			return s.Language
+		}
+	}
+	return "en"
+}
+
+// EnqueueWeeklyReportEmail builds the weekly report for a tenant and enqueues it to be sent with the tenant's
+// SMTP server or the global SMTP settings
+func (m *Model) EnqueueWeeklyReportEmail(tenantID int, recipients []string) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients for the weekly report of tenant %d", tenantID)
+	}
+
+	s, err := m.TenantSMTPSettings(tenantID)
+	if err != nil {
+		return err
+	}
+
+	if s.Server == "" || s.Port == 0 || s.MailFrom == "" {
+		return fmt.Errorf("SMTP settings are not configured")
+	}
+
+	report, err := m.BuildWeeklyReport(tenantID)
+	if err != nil {
+		return err
+	}
+
+	ctx := report.Context()
+	return m.EnqueueEmail(Email{
+		TenantID: tenantID,
+		Kind:     EmailKindWeeklyReport,
+		To:       recipients,
+		Subject:  report.Subject(ctx),
+		Body:     report.Body(ctx),
+	})
+}
+
+// SendWeeklyReportEmailWithSettings builds the weekly report for a tenant and sends it right away using the
+// SMTP settings provided, they aren't stored so the email can't be queued
+func (m *Model) SendWeeklyReportEmailWithSettings(tenantID int, recipients []string, settings *SMTPSettings) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients for the weekly report of tenant %d", tenantID)
+	}
+
+	if settings.Server == "" || settings.Port == 0 || settings.MailFrom == "" {
+		return fmt.Errorf("SMTP settings are not configured")
+	}
+
+	report, err := m.BuildWeeklyReport(tenantID)
+	if err != nil {
+		return err
+	}
+
+	c, err := NewMailClient(settings)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.NewMsg()
+	if err := setMailFrom(msg, settings); err != nil {
+		return err
+	}
+	if err := msg.Bcc(recipients...); err != nil {
+		return err
+	}
+	ctx := report.Context()
+	msg.Subject(report.Subject(ctx))
+	msg.SetBodyString(mail.TypeTextPlain, report.Body(ctx))
+
+	return c.DialAndSend(msg)
+}
+
+// NewMailClient returns a client for the SMTP server, authenticating unless the settings say otherwise
+func NewMailClient(settings *SMTPSettings) (*mail.Client, error) {
+	if settings.Auth == "NOAUTH" || (settings.User == "" && settings.Password == "") {
+		return mail.NewClient(settings.Server, mail.WithPort(settings.Port))
+	}
+	return mail.NewClient(settings.Server, mail.WithPort(settings.Port), mail.WithSMTPAuth(mail.SMTPAuthType(settings.Auth)),
+		mail.WithUsername(settings.User), mail.WithPassword(settings.Password))
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/open-uem/ent/agent"
+	"github.com/open-uem/ent/enttest"
+	"github.com/open-uem/openuem-console/internal/views/locales"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type WeeklyReportTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+}
+
+func (suite *WeeklyReportTestSuite) SetupSuite() {
+	translations, err := locales.WithFallback("en")
+	assert.NoError(suite.T(), err, "should read translations")
+	assert.NoError(suite.T(), ctxi18n.LoadWithDefault(translations, "en"), "should load translations")
+}
+
+func (suite *WeeklyReportTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	r, err := client.Release.Create().
+		SetArch("amd64").
+		SetChannel("stable").
+		SetOs("windows").
+		SetVersion("0.1.0").
+		Save(context.Background())
+	assert.NoError(suite.T(), err, "should create a release")
+
+	// agent0 to agent3 reported recently, agent4 and agent5 are offline, agent6 is waiting for admission.
+	// agent0 and agent1 were enrolled this week
+	for i := 0; i <= 6; i++ {
+		query := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetReleaseID(r.ID).
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			SetFirstContact(time.Now().AddDate(0, -1, 0)).
+			SetLastContact(time.Now()).
+			AddSiteIDs(s.ID)
+
+		if i <= 1 {
+			query.SetFirstContact(time.Now().AddDate(0, 0, -2))
+		}
+		if i == 4 || i == 5 {
+			query.SetLastContact(time.Now().AddDate(0, 0, -3))
+		}
+		if i == 6 {
+			query.SetAgentStatus(agent.AgentStatusWaitingForAdmission)
+		}
+
+		err := query.Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	for i, role := range []string{"admin", "admin", "operator"} {
+		err := client.User.Create().
+			SetID(fmt.Sprintf("user%d", i)).
+			SetName(fmt.Sprintf("User %d", i)).
+			SetEmail(fmt.Sprintf("user%d@example.com", i)).
+			SetCreated(time.Now()).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create user")
+
+		err = client.UserTenant.Create().SetUserID(fmt.Sprintf("user%d", i)).SetTenantID(t.ID).SetRole(role).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should assign user to tenant")
+	}
+}
+
+func (suite *WeeklyReportTestSuite) TestBuildWeeklyReport() {
+	report, err := suite.model.BuildWeeklyReport(suite.tenantID)
+	assert.NoError(suite.T(), err, "should build weekly report")
+	assert.Equal(suite.T(), suite.tenantID, report.TenantID)
+	assert.Equal(suite.T(), 6, report.TotalAgents, "should count agents already admitted")
+	assert.Equal(suite.T(), 4, report.OnlineAgents, "should count online agents")
+	assert.Equal(suite.T(), 0, report.LateAgents, "should count late agents")
+	assert.Equal(suite.T(), 2, report.OfflineAgents, "should count offline agents")
+	assert.Equal(suite.T(), 2, report.NewEnrollments, "should count agents enrolled in the last week")
+	assert.Equal(suite.T(), 1, report.WaitingForAdmission, "should count agents waiting for admission")
+
+	assert.Equal(suite.T(), "en", report.Language, "should use English if no language is set")
+
+	ctx := report.Context()
+	alerts := report.CriticalAlerts(ctx)
+	assert.Contains(suite.T(), alerts, "2 agent(s) are offline, they haven't reported within their threshold")
+	assert.Contains(suite.T(), alerts, "1 agent(s) are waiting for admission")
+	assert.Equal(suite.T(), "OpenUEM weekly report for DefaultTenant", report.Subject(ctx))
+	assert.Contains(suite.T(), report.Body(ctx), "Total agents: 6")
+
+	_, err = suite.model.BuildWeeklyReport(9999)
+	assert.Error(suite.T(), err, "should fail for an unknown tenant")
+}
+
+func (suite *WeeklyReportTestSuite) TestBuildWeeklyReportScheduledReports() {
+	_, err := suite.model.CreateCustomReport(suite.tenantID, "user0", CustomReportValues{
+		Name:       "Agents by status",
+		Definition: CustomReportDefinition{Entity: CustomReportEntityAgents, Columns: []string{"hostname"}, GroupBy: "status"},
+		Scheduled:  true,
+	})
+	assert.NoError(suite.T(), err, "should save a scheduled report")
+
+	_, err = suite.model.CreateCustomReport(suite.tenantID, "user0", CustomReportValues{
+		Name:       "Not scheduled",
+		Definition: CustomReportDefinition{Entity: CustomReportEntityAgents, Columns: []string{"hostname"}},
+	})
+	assert.NoError(suite.T(), err, "should save a report")
+
+	report, err := suite.model.BuildWeeklyReport(suite.tenantID)
+	assert.NoError(suite.T(), err, "should build weekly report")
+	if assert.Len(suite.T(), report.CustomReports, 1, "should only run the scheduled reports") {
+		assert.Equal(suite.T(), "Agents by status", report.CustomReports[0].Name)
+		assert.Equal(suite.T(), 7, report.CustomReports[0].Total)
+	}
+	assert.Contains(suite.T(), report.Body(report.Context()), "Agents by status: 7 row(s)")
+}
+
+func (suite *WeeklyReportTestSuite) TestWeeklyReportLanguage() {
+	err := suite.model.Client.Settings.Create().SetLanguage("de").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create global settings")
+
+	report, err := suite.model.BuildWeeklyReport(suite.tenantID)
+	assert.NoError(suite.T(), err, "should build weekly report")
+	assert.Equal(suite.T(), "de", report.Language, "should use the global language if the tenant doesn't set one")
+
+	ctx := report.Context()
+	assert.Equal(suite.T(), "OpenUEM-Wochenbericht für DefaultTenant", report.Subject(ctx))
+	assert.Contains(suite.T(), report.CriticalAlerts(ctx), "1 Agent(en) warten auf die Zulassung")
+	assert.Contains(suite.T(), report.Body(ctx), "Agenten insgesamt: 6")
+
+	err = suite.model.Client.Settings.Create().SetTenantID(suite.tenantID).SetLanguage("en").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create tenant settings")
+	assert.Equal(suite.T(), "en", suite.model.GetTenantLanguage(suite.tenantID), "the language of the tenant should override the global one")
+
+	assert.NoError(suite.T(), suite.model.Client.Settings.Update().SetLanguage("xx").Exec(context.Background()))
+	assert.Equal(suite.T(), "en", suite.model.GetTenantLanguage(suite.tenantID), "should use English if the language isn't supported")
+}
+
+func (suite *WeeklyReportTestSuite) TestGetTenantAdminEmails() {
+	emails, err := suite.model.GetTenantAdminEmails(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get tenant admin emails")
+	assert.ElementsMatch(suite.T(), []string{"user0@example.com", "user1@example.com"}, emails, "should only get admins")
+}
+
+func (suite *WeeklyReportTestSuite) TestSendWeeklyReportEmailWithoutRecipients() {
+	err := suite.model.SendWeeklyReportEmailWithSettings(suite.tenantID, []string{}, &SMTPSettings{Server: "localhost", Port: 25, MailFrom: "openuem@example.com"})
+	assert.Error(suite.T(), err, "should fail without recipients")
+}
+
+func TestWeeklyReportTestSuite(t *testing.T) {
+	suite.Run(t, new(WeeklyReportTestSuite))
+}
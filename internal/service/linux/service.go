@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/open-uem/openuem-console/internal/common"
+	"github.com/open-uem/openuem-console/internal/telemetry"
 )
 
 func main() {
@@ -18,7 +19,7 @@ func main() {
 	w := common.NewWorker("openuem-console-service")
 
 	// Start Task Scheduler
-	w.TaskScheduler, err = gocron.NewScheduler()
+	w.TaskScheduler, err = gocron.NewScheduler(telemetry.SchedulerOptions()...)
 	if err != nil {
 		log.Fatalf("[FATAL]: could not create task scheduler, reason: %s", err.Error())
 		return
@@ -51,6 +52,12 @@ func main() {
 		log.Fatalf("[FATAL]: could not create server releases temp dir: %v", err)
 	}
 
+	// Create the database backups directory, local backups are disabled if it's not valid
+	if err := w.CreateBackupDir(); err != nil {
+		log.Printf("[ERROR]: could not create the database backups dir, local backups are disabled: %v", err)
+		w.Backups.Dir = ""
+	}
+
 	// Start the worker
 	w.StartWorker()
 
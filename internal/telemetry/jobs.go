@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SchedulerOptions returns the options of a task scheduler that trace each run of its jobs. The scheduler
+// is created before the config is read, so the listeners check if tracing is enabled when the jobs run
+func SchedulerOptions() []gocron.SchedulerOption {
+	var running sync.Map
+
+	return []gocron.SchedulerOption{
+		gocron.WithGlobalJobOptions(gocron.WithEventListeners(
+			gocron.BeforeJobRuns(func(jobID uuid.UUID, jobName string) {
+				if !Enabled() {
+					return
+				}
+				_, span := Tracer().Start(context.Background(), "job "+jobName,
+					trace.WithSpanKind(trace.SpanKindInternal),
+					trace.WithAttributes(attribute.String("job.id", jobID.String())),
+				)
+				running.Store(jobID, span)
+			}),
+			gocron.AfterJobRuns(func(jobID uuid.UUID, jobName string) {
+				if span, ok := running.LoadAndDelete(jobID); ok {
+					End(span.(trace.Span), nil)
+				}
+			}),
+			gocron.AfterJobRunsWithError(func(jobID uuid.UUID, jobName string, err error) {
+				if span, ok := running.LoadAndDelete(jobID); ok {
+					End(span.(trace.Span), err)
+				}
+			}),
+		)),
+	}
+}
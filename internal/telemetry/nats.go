@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operations of the NATS spans
+const (
+	NATSPublish = "publish"
+	NATSRequest = "request"
+)
+
+// StartNATS starts the span of a message sent to the subject and returns the message with the trace
+// context in its headers, so the workers and agents receiving it can continue the trace. Subjects
+// usually end with the ID of an agent so they're an attribute instead of the name of the span
+func StartNATS(ctx context.Context, operation, subject string, data []byte) (context.Context, trace.Span, *nats.Msg) {
+	kind := trace.SpanKindProducer
+	if operation == NATSRequest {
+		kind = trace.SpanKindClient
+	}
+
+	ctx, span := Tracer().Start(ctx, "nats "+operation,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.operation.name", operation),
+			attribute.String("messaging.destination.name", subject),
+			attribute.Int("messaging.message.body.size", len(data)),
+		),
+	)
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(msg.Header)))
+	return ctx, span, msg
+}
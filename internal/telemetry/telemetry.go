@@ -0,0 +1,100 @@
+// Package telemetry exports OpenTelemetry traces of the requests, database queries, NATS messages,
+// outbound HTTP requests and background jobs of the console. Tracing is disabled unless an OTLP
+// endpoint is configured, then the instrumentation isn't installed and the global tracer is a no-op
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the name of the service the traces of the console belong to
+const ServiceName = "openuem-console"
+
+const instrumentationName = "github.com/open-uem/openuem-console"
+
+// DefaultSampleRate exports all the traces
+const DefaultSampleRate = 1.0
+
+// Attributes of the spans of the console
+const (
+	UserIDKey   = attribute.Key("enduser.id")
+	TenantIDKey = attribute.Key("openuem.tenant.id")
+	SiteIDKey   = attribute.Key("openuem.site.id")
+)
+
+// Config sets where the traces are exported and how many of them
+type Config struct {
+	// Endpoint is the URL of the OTLP/HTTP collector e.g. http://tempo:4318, tracing is disabled if it's empty
+	Endpoint string
+	// SampleRate is the fraction of the traces exported, from 0 to 1. The traces started by another
+	// service follow the sampling decision of that service
+	SampleRate float64
+}
+
+var enabled atomic.Bool
+
+// Enabled tells if the traces are exported. The instrumentation checks it before it's installed,
+// so nothing is added to requests, queries or messages when tracing is disabled
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Tracer returns the tracer of the console, a no-op tracer if tracing is disabled
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start exports the traces to the endpoint of the config and returns the function that sends the
+// pending spans and stops the exporter. The outbound HTTP requests made with the default transport
+// are traced and carry the trace context from then on
+func Start(cfg Config, version string) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("the sample rate of the traces must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("could not create the OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", ServiceName),
+			attribute.String("service.version", version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRate))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	http.DefaultTransport = Transport(http.DefaultTransport)
+	enabled.Store(true)
+
+	return provider.Shutdown, nil
+}
+
+// End ends the span recording the error, if any
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
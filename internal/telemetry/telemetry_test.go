@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestStartWithoutEndpoint(t *testing.T) {
+	stop, err := Start(Config{SampleRate: DefaultSampleRate}, "0.12.0")
+	assert.NoError(t, err)
+	assert.False(t, Enabled(), "tracing should be disabled without an endpoint")
+	assert.NoError(t, stop(context.Background()))
+
+	_, err = Start(Config{Endpoint: "http://localhost:4318", SampleRate: 2}, "0.12.0")
+	assert.Error(t, err, "should reject sample rates greater than 1")
+	assert.False(t, Enabled())
+}
+
+func TestTransport(t *testing.T) {
+	recorder := recordSpans(t)
+
+	traceparent := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/hook?token=secret")
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "HTTP GET", spans[0].Name())
+		assert.Contains(t, traceparent, spans[0].SpanContext().TraceID().String(), "should send the trace context in the headers")
+		for _, attr := range spans[0].Attributes() {
+			if attr.Key == "url.full" {
+				assert.Equal(t, server.URL+"/hook", attr.Value.AsString(), "should not record the query of the URL")
+			}
+		}
+	}
+}
+
+func TestStartNATS(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span, msg := StartNATS(context.Background(), NATSPublish, "agent.settings.1", []byte("{}"))
+	span.End()
+
+	assert.Equal(t, "agent.settings.1", msg.Subject)
+	assert.Equal(t, []byte("{}"), msg.Data)
+	if spans := recorder.Ended(); assert.Len(t, spans, 1) {
+		assert.Equal(t, "nats publish", spans[0].Name())
+		assert.Contains(t, msg.Header.Get("Traceparent"), spans[0].SpanContext().TraceID().String(), "should send the trace context in the headers")
+	}
+}
@@ -0,0 +1,41 @@
+// Package version holds the version and the commit the console has been built from. They're set
+// at build time with:
+//
+//	go build -ldflags "-X github.com/open-uem/openuem-console/internal/version.Version=0.12.0 -X github.com/open-uem/openuem-console/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+import "runtime/debug"
+
+var (
+	// Version is the version of the console, empty if it wasn't set when it was built
+	Version string
+	// Commit is the commit of the console, empty if it wasn't set when it was built
+	Commit string
+)
+
+// Get returns the version set at build time or the fallback if the console was built without it
+func Get(fallback string) string {
+	if Version != "" {
+		return Version
+	}
+	return fallback
+}
+
+// GetCommit returns the commit set at build time or the one recorded by the Go toolchain
+// when the console was built from a git checkout
+func GetCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+			return s.Value[:7]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package admin_views
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	openuem_nats "github.com/open-uem/nats"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAboutVersion(t *testing.T) {
+	config := partials.CommonInfo{TenantID: "-1"}
+	release := &openuem_nats.OpenUEMRelease{Version: "0.13.0", Summary: "Console version notifications", ReleaseNotesURL: "https://example.com/0.13.0"}
+
+	tests := []struct {
+		name    string
+		info    AboutInfo
+		summary bool
+	}{
+		{name: "disabled", info: AboutInfo{Version: "0.12.0", CheckUpdates: false, LatestRelease: release}},
+		{name: "not checked", info: AboutInfo{Version: "0.12.0", CheckUpdates: true}},
+		{name: "new version", info: AboutInfo{Version: "0.12.0", CheckUpdates: true, LatestRelease: release}, summary: true},
+		{name: "up to date", info: AboutInfo{Version: "0.13.0", CheckUpdates: true, LatestRelease: release}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() {
+				_ = w.CloseWithError(AboutVersion(test.info, &config).Render(context.Background(), w))
+			}()
+			doc, err := goquery.NewDocumentFromReader(r)
+			if err != nil {
+				t.Fatalf("failed to read template: %v", err)
+			}
+
+			assert.Equal(t, test.info.Version, doc.Find("#about-current-version").Text())
+			if test.summary {
+				assert.Equal(t, release.Summary, doc.Find("#about-summary").Text(), "should show the changelog of the new version")
+				assert.Equal(t, release.ReleaseNotesURL, doc.Find("#about-updates a").AttrOr("href", ""))
+			} else {
+				assert.Equal(t, 0, doc.Find("#about-summary").Length(), "should only show the changelog of a newer version")
+			}
+		})
+	}
+}
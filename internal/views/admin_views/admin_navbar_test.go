@@ -11,7 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-var globalNavbarTests = []string{"users", "sessions", "smtp", "sessions", "settings", "update-servers", "certificates"}
+var globalNavbarTests = []string{"users", "sessions", "smtp", "sessions", "settings", "update-servers", "certificates", "allowlist", "backups", "retention", "emails", "security-headers", "api-usage", "nats", "about"}
 
 var tenantNavbarTests = []string{"tags", "metadata", "settings", "update-agents"}
 
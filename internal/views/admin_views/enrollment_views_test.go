@@ -0,0 +1,102 @@
+package admin_views
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	ent "github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrollmentTokenRowReveal(t *testing.T) {
+	config := partials.CommonInfo{TenantID: "1"}
+	token := &ent.EnrollmentToken{ID: 1, Token: "11111111-2222-3333-4444-555555555555", Active: true}
+
+	for _, revealed := range []bool{true, false} {
+		r, w := io.Pipe()
+		go func() {
+			_ = w.CloseWithError(EnrollmentTokenRow(token, false, revealed, &config).Render(context.Background(), w))
+		}()
+		doc, err := goquery.NewDocumentFromReader(r)
+		if err != nil {
+			t.Fatalf("failed to read template: %v", err)
+		}
+
+		code := doc.Find("code").First().Text()
+		copyButton := doc.Find("[data-token]")
+		if revealed {
+			assert.Equal(t, token.Token, code, "should show the full token after creating it")
+			assert.Equal(t, token.Token, copyButton.AttrOr("data-token", ""), "should be able to copy the token")
+		} else {
+			assert.Equal(t, "11111111...", code, "should mask the token")
+			assert.Equal(t, 0, copyButton.Length(), "should not expose the token to copy")
+			assert.NotContains(t, doc.Text(), token.Token)
+		}
+	}
+}
+
+func TestEnrollmentTokenRowRoles(t *testing.T) {
+	token := &ent.EnrollmentToken{ID: 1, Token: "11111111-2222-3333-4444-555555555555", Active: true}
+
+	for role, canChange := range map[string]bool{"admin": true, "operator": false, "user": false} {
+		t.Run(role, func(t *testing.T) {
+			config := partials.CommonInfo{TenantID: "1", UserRole: role}
+			r, w := io.Pipe()
+			go func() {
+				_ = w.CloseWithError(EnrollmentTokenRow(token, false, false, &config).Render(context.Background(), w))
+			}()
+			doc, err := goquery.NewDocumentFromReader(r)
+			if err != nil {
+				t.Fatalf("failed to read template: %v", err)
+			}
+
+			if canChange {
+				assert.Equal(t, 3, doc.Find("[hx-post], [hx-delete]").Length(), "should toggle, publish and delete the token")
+				assert.Equal(t, 7, doc.Find("[hx-get]").Length(), "should show the install commands, the sites and the downloads")
+			} else {
+				assert.Equal(t, 0, doc.Find("[hx-post], [hx-delete]").Length(), "should not render actions the role can't perform")
+				assert.Equal(t, 5, doc.Find("[hx-get]").Length(), "should always show the install commands")
+			}
+		})
+	}
+}
+
+func TestEnrollmentTokenRowAllowedOS(t *testing.T) {
+	config := partials.CommonInfo{TenantID: "1"}
+	token := &ent.EnrollmentToken{ID: 1, Token: "11111111-2222-3333-4444-555555555555", Active: true, AllowedOs: []string{"linux"}}
+
+	r, w := io.Pipe()
+	go func() {
+		_ = w.CloseWithError(EnrollmentTokenRow(token, false, false, &config).Render(context.Background(), w))
+	}()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		t.Fatalf("failed to read template: %v", err)
+	}
+
+	platforms := []string{}
+	doc.Find("[hx-get*='/command?platform=']").Each(func(_ int, s *goquery.Selection) {
+		platforms = append(platforms, s.AttrOr("hx-get", "")[len("/tenant/1/admin/enrollment/1/command?platform="):])
+	})
+	assert.Equal(t, []string{"linux", "docker"}, platforms, "should only show the install commands of the allowed platforms")
+}
+
+func TestEnrollmentTokenDownloads(t *testing.T) {
+	token := &ent.EnrollmentToken{ID: 1, Description: "Office"}
+	counts := []int{0, 4, 2}
+
+	r, w := io.Pipe()
+	go func() {
+		_ = w.CloseWithError(EnrollmentTokenDownloads(token, counts).Render(context.Background(), w))
+	}()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		t.Fatalf("failed to read template: %v", err)
+	}
+
+	points := doc.Find("#enrollment-token-sparkline polyline").AttrOr("points", "")
+	assert.Equal(t, "0.0,38.0 120.0,2.0 240.0,20.0", points, "should scale the days to the day with more downloads")
+}
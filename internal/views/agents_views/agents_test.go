@@ -0,0 +1,63 @@
+package agents_views
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/open-uem/ent"
+	"github.com/open-uem/openuem-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddActionsButtonRoles(t *testing.T) {
+	agent := &ent.Agent{ID: "agent1", AgentStatus: "Enabled"}
+
+	for role, canChange := range map[string]bool{"admin": true, "operator": true, "user": false} {
+		t.Run(role, func(t *testing.T) {
+			config := partials.CommonInfo{TenantID: "1", SiteID: "-1", UserRole: role}
+			r, w := io.Pipe()
+			go func() {
+				_ = w.CloseWithError(AddActionsButton(agent, 0, false, &config).Render(context.Background(), w))
+			}()
+			doc, err := goquery.NewDocumentFromReader(r)
+			if err != nil {
+				t.Fatalf("failed to read template: %v", err)
+			}
+
+			deleteLink := doc.Find("[hx-get='/tenant/1/agents/agent1/delete']")
+			if canChange {
+				assert.Equal(t, 3, doc.Find("[hx-post]").Length(), "should force a report, regenerate the certificates and restart the agent")
+				assert.Equal(t, 1, deleteLink.Length(), "should delete the agent")
+			} else {
+				assert.Equal(t, 0, doc.Find("[hx-post], [hx-delete]").Length(), "should not render actions the role can't perform")
+				assert.Equal(t, 0, deleteLink.Length(), "should not delete the agent")
+			}
+			assert.Equal(t, 1, doc.Find("[hx-get='/tenant/1/agents/agent1/settings']").Length(), "should always show the settings of the agent")
+		})
+	}
+}
+
+func TestAgentCardsSentinel(t *testing.T) {
+	agents := []*ent.Agent{{ID: "agent1", Nickname: "Reception", AgentStatus: "Enabled"}, {ID: "agent2", Nickname: "Office", AgentStatus: "Disabled"}}
+	config := partials.CommonInfo{TenantID: "1", SiteID: "-1"}
+
+	for nextURL, sentinels := range map[string]int{"/tenant/1/agents?cursor=agent2": 1, "": 0} {
+		r, w := io.Pipe()
+		go func() {
+			_ = w.CloseWithError(AgentCards(agents, map[string]bool{"agent1": true}, map[string]string{}, nextURL, &config).Render(context.Background(), w))
+		}()
+		doc, err := goquery.NewDocumentFromReader(r)
+		if err != nil {
+			t.Fatalf("failed to read template: %v", err)
+		}
+
+		assert.Equal(t, 1, doc.Find("[hx-get='/tenant/1/computers/agent1']").Length(), "should link the card to the agent")
+		sentinel := doc.Find(".agent-cards-sentinel")
+		if assert.Equal(t, sentinels, sentinel.Length(), "should only load more agents if the page was full") && sentinels > 0 {
+			assert.Equal(t, nextURL, sentinel.AttrOr("hx-get", ""))
+			assert.Contains(t, sentinel.AttrOr("hx-trigger", ""), "revealed")
+		}
+	}
+}
@@ -10,16 +10,21 @@ import (
 	"github.com/invopop/ctxi18n/i18n"
 )
 
-func AgentsByLastReportDate(ctx context.Context, countReportedLast24, countAllAgents int) render.ChartSnippet {
+// AgentsByLastReportDate shows the agents that report on time, those that are late and those that are offline
+// according to their thresholds
+func AgentsByLastReportDate(ctx context.Context, countOnTime, countLate, countOffline int) render.ChartSnippet {
 	pie := charts.NewPie()
 
 	pieData := []opts.PieData{}
 
+	countAllAgents := countOnTime + countLate + countOffline
+
 	// preformat data
 	if countAllAgents > 0 {
 		pieData = []opts.PieData{
-			{Name: i18n.T(ctx, "charts.last_contact_less_24"), Value: countReportedLast24},
-			{Name: i18n.T(ctx, "charts.last_contact_more_24"), Value: countAllAgents - countReportedLast24},
+			{Name: i18n.T(ctx, "charts.last_contact_on_time"), Value: countOnTime},
+			{Name: i18n.T(ctx, "charts.last_contact_late"), Value: countLate},
+			{Name: i18n.T(ctx, "charts.last_contact_offline"), Value: countOffline},
 		}
 	}
 
@@ -34,7 +39,7 @@ func AgentsByLastReportDate(ctx context.Context, countReportedLast24, countAllAg
 
 	leftTitle := getLeftTitlePercentage(countAllAgents)
 
-	colors := opts.Colors{"#48C639", "#C63948"}
+	colors := opts.Colors{"#48C639", "#E8A33D", "#C63948"}
 
 	textStyle := opts.TextStyle{FontSize: 36, Color: "#777"}
 
@@ -0,0 +1,47 @@
+package charts
+
+import (
+	"context"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/render"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/open-uem/openuem-console/internal/models"
+)
+
+// PrintersByModel shows how many printers of each model are in use, the most used models first
+func PrintersByModel(ctx context.Context, printers []models.PrinterModelCount) render.ChartSnippet {
+	bar := charts.NewBar()
+
+	// preformat data
+	names := []string{}
+	barData := []opts.BarData{}
+
+	for _, p := range printers {
+		name := p.Model
+		if name == "" {
+			name = i18n.T(ctx, "charts.unknown_printer_model")
+		}
+		names = append(names, name)
+		barData = append(barData, opts.BarData{Name: name, Value: p.Count})
+	}
+
+	// put data into chart
+	bar.SetXAxis(names).AddSeries(i18n.T(ctx, "charts.printer_models"), barData).SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{Show: opts.Bool(true), Position: "top"}),
+	)
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Show: opts.Bool(false)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(false)}),
+		charts.WithXAxisOpts(opts.XAxis{AxisLabel: &opts.AxisLabel{Interval: "0", Rotate: 30, Color: "#777"}}),
+		charts.WithColorsOpts(opts.Colors{"#759aa0"}),
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:  "720px",
+			Height: "360px",
+		}),
+	)
+
+	return bar.RenderSnippet()
+}
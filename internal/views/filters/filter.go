@@ -2,8 +2,10 @@ package filters
 
 import (
 	"net/url"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/open-uem/openuem-console/internal/views/partials"
 )
 
 type AgentFilter struct {
@@ -22,9 +24,12 @@ type AgentFilter struct {
 	WithApplicationPublisher string
 	SelectedItems            int
 	SelectedAllAgents        string
+	AllMatchingSelected      bool
+	ExcludedAgents           []string
 	SelectedRelease          string
 	IsRemote                 []string
 	NoContact                bool
+	Late                     bool
 	Search                   string
 	AntivirusNameOptions     []string
 	AntivirusUpdatedOptions  []string
@@ -35,6 +40,8 @@ type AgentFilter struct {
 	LastInstallFrom          string
 	LastInstallTo            string
 	PendingUpdateOptions     []string
+	// ContactSince keeps the agents that have contacted since then, it's more precise than ContactFrom
+	ContactSince time.Time
 }
 
 type ApplicationsFilter struct {
@@ -131,6 +138,33 @@ type UpdateServersFilter struct {
 	SelectedRelease    string
 }
 
+type AuthEventFilter struct {
+	Type      string
+	Username  string
+	IPAddress string
+	From      string
+	To        string
+}
+
+type EnrollmentTokenFilter struct {
+	Description   string
+	StatusOptions []string
+}
+
+type TenantMemberFilter struct {
+	Name        string
+	RoleOptions []string
+}
+
+type EmailFilter struct {
+	StatusOptions []string
+}
+
+type PreStagedDeviceFilter struct {
+	Serial        string
+	StatusOptions []string
+}
+
 type DeployPackageFilter struct {
 	Sources []string
 	Arch    string
@@ -153,5 +187,5 @@ func GetPaginationUrl(c echo.Context) string {
 		}
 	}
 
-	return c.Request().URL.Path
+	return partials.GetListUrl(c)
 }
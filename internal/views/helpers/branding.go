@@ -10,25 +10,36 @@ import (
 // Input: "#6d28d9" or "6d28d9"
 // Output: "263 70% 50%" (format used by CSS variables in the theme)
 func HexToHSL(hex string) string {
+	h, s, l, ok := hexToHSLValues(hex)
+	if !ok {
+		return ""
+	}
+
+	// Convert to degrees and percentages
+	return fmt.Sprintf("%.1f %.1f%% %.1f%%", h*360.0, s*100.0, l*100.0)
+}
+
+// hexToHSLValues converts a hex color string to hue, saturation and lightness in the 0-1 range
+func hexToHSLValues(hex string) (float64, float64, float64, bool) {
 	// Remove # if present
 	hex = strings.TrimPrefix(hex, "#")
 
 	if len(hex) != 6 {
-		return ""
+		return 0, 0, 0, false
 	}
 
 	// Parse RGB values
 	r, err := strconv.ParseInt(hex[0:2], 16, 64)
 	if err != nil {
-		return ""
+		return 0, 0, 0, false
 	}
 	g, err := strconv.ParseInt(hex[2:4], 16, 64)
 	if err != nil {
-		return ""
+		return 0, 0, 0, false
 	}
 	b, err := strconv.ParseInt(hex[4:6], 16, 64)
 	if err != nil {
-		return ""
+		return 0, 0, 0, false
 	}
 
 	// Convert to 0-1 range
@@ -87,12 +98,83 @@ func HexToHSL(hex string) string {
 		h /= 6.0
 	}
 
-	// Convert to degrees and percentages
-	hDeg := h * 360.0
-	sPct := s * 100.0
-	lPct := l * 100.0
+	return h, s, l, true
+}
+
+// hslToHex converts hue, saturation and lightness in the 0-1 range to a hex color string
+func hslToHex(h, s, l float64) string {
+	var r, g, b float64
+
+	if s == 0 {
+		// Achromatic
+		r, g, b = l, l, l
+	} else {
+		var q float64
+		if l < 0.5 {
+			q = l * (1 + s)
+		} else {
+			q = l + s - l*s
+		}
+		p := 2*l - q
+		r = hueToRGB(p, q, h+1.0/3.0)
+		g = hueToRGB(p, q, h)
+		b = hueToRGB(p, q, h-1.0/3.0)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", int(r*255.0+0.5), int(g*255.0+0.5), int(b*255.0+0.5))
+}
 
-	return fmt.Sprintf("%.1f %.1f%% %.1f%%", hDeg, sPct, lPct)
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t += 1
+	}
+	if t > 1 {
+		t -= 1
+	}
+	switch {
+	case t < 1.0/6.0:
+		return p + (q-p)*6*t
+	case t < 1.0/2.0:
+		return q
+	case t < 2.0/3.0:
+		return p + (q-p)*(2.0/3.0-t)*6
+	}
+	return p
+}
+
+// DarkModeColor returns a variant of the color that stays readable on dark backgrounds,
+// dark colors are lightened and very saturated colors are toned down
+// Input: "#1e3a8a"
+// Output: "#5779db"
+func DarkModeColor(hex string) string {
+	h, s, l, ok := hexToHSLValues(hex)
+	if !ok {
+		return ""
+	}
+
+	if l < 0.6 {
+		l = 0.6
+	}
+	if s > 0.8 {
+		s = 0.8
+	}
+
+	return hslToHex(h, s, l)
+}
+
+// DarkModeSecondaryColor returns a dark, muted tone of the color's hue used
+// as secondary color on dark backgrounds
+func DarkModeSecondaryColor(hex string) string {
+	h, s, _, ok := hexToHSLValues(hex)
+	if !ok {
+		return ""
+	}
+
+	if s > 0.3 {
+		s = 0.3
+	}
+
+	return hslToHex(h, s, 0.18)
 }
 
 // GetContrastColor returns a contrasting foreground color (white or black in HSL format)
@@ -120,6 +202,9 @@ func GetContrastColor(hex string) string {
 	return "0 0% 98%" // Near white
 }
 
+// brandingThemes are the theme classes the branding colors override
+var brandingThemes = []string{"openuem", "zinc", "slate", "stone", "gray", "neutral", "red", "rose", "orange", "green", "blue", "yellow", "violet"}
+
 // GenerateBrandingCSS generates the complete CSS style tag content for the primary color
 // Uses high specificity selectors to override theme defaults
 func GenerateBrandingCSS(primaryColor string) string {
@@ -139,22 +224,21 @@ func GenerateBrandingCSS(primaryColor string) string {
 	cssProps := fmt.Sprintf("--primary:%s !important;--primary-foreground:%s !important;--ring:%s !important;--uk-form-list-image:%s !important;",
 		HexToHSL(primaryColor), GetContrastColor(primaryColor), HexToHSL(primaryColor), arrowSVG)
 
+	// The light colors are hard to read on dark backgrounds so dark mode gets its own variants
+	darkPrimary := DarkModeColor(primaryColor)
+	darkSecondary := DarkModeSecondaryColor(primaryColor)
+	darkArrowSVG := strings.Replace(arrowSVG, strings.TrimPrefix(primaryColor, "#"), strings.TrimPrefix(darkPrimary, "#"), 1)
+	darkCSSProps := fmt.Sprintf("--primary:%s !important;--primary-foreground:%s !important;--ring:%s !important;--secondary:%s !important;--secondary-foreground:%s !important;--uk-form-list-image:%s !important;",
+		HexToHSL(darkPrimary), GetContrastColor(darkPrimary), HexToHSL(darkPrimary), HexToHSL(darkSecondary), GetContrastColor(darkSecondary), darkArrowSVG)
+
 	// Use specific theme class selectors for highest specificity
 	// This directly targets .uk-theme-openuem and other theme classes
 	sb.WriteString(":root{" + cssProps + "}")
-	sb.WriteString(".uk-theme-openuem,.uk-theme-openuem.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-zinc,.uk-theme-zinc.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-slate,.uk-theme-slate.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-stone,.uk-theme-stone.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-gray,.uk-theme-gray.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-neutral,.uk-theme-neutral.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-red,.uk-theme-red.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-rose,.uk-theme-rose.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-orange,.uk-theme-orange.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-green,.uk-theme-green.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-blue,.uk-theme-blue.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-yellow,.uk-theme-yellow.dark{" + cssProps + "}")
-	sb.WriteString(".uk-theme-violet,.uk-theme-violet.dark{" + cssProps + "}")
+	sb.WriteString(".dark{" + darkCSSProps + "}")
+	for _, theme := range brandingThemes {
+		sb.WriteString(fmt.Sprintf(".uk-theme-%s{%s}", theme, cssProps))
+		sb.WriteString(fmt.Sprintf(".uk-theme-%s.dark{%s}", theme, darkCSSProps))
+	}
 
 	sb.WriteString("</style>")
 
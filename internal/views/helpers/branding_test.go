@@ -0,0 +1,30 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDarkModeColor(t *testing.T) {
+	assert.Equal(t, "#5779db", DarkModeColor("#1e3a8a"), "should lighten dark colors")
+	assert.Equal(t, "#cbd5e1", DarkModeColor("cbd5e1"), "should keep light colors")
+	assert.Equal(t, "#abb8f6", DarkModeColor("#a5b4fc"), "should tone down saturated colors")
+	assert.Equal(t, "#999999", DarkModeColor("#333333"), "should lighten grays without adding hue")
+	assert.Equal(t, "", DarkModeColor("#fff"), "should reject invalid colors")
+}
+
+func TestDarkModeSecondaryColor(t *testing.T) {
+	_, s, l, ok := hexToHSLValues(DarkModeSecondaryColor("#16a34a"))
+	assert.True(t, ok)
+	assert.InDelta(t, 0.18, l, 0.01, "should be a dark tone")
+	assert.LessOrEqual(t, s, 0.31, "should be muted")
+}
+
+func TestGenerateBrandingCSS(t *testing.T) {
+	css := GenerateBrandingCSS("#1e3a8a")
+	assert.True(t, strings.Contains(css, ".uk-theme-openuem{--primary:"+HexToHSL("#1e3a8a")), "should use the primary color in light mode")
+	assert.True(t, strings.Contains(css, ".uk-theme-openuem.dark{--primary:"+HexToHSL("#5779db")), "should use the adjusted color in dark mode")
+	assert.Equal(t, "", GenerateBrandingCSS(""))
+}
@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"slices"
+	"time"
+	// The timezones are embedded as the console may run in containers without the timezone database
+	_ "time/tzdata"
+
+	"github.com/gohugoio/locales"
+)
+
+// Date formats a tenant or a user can choose, locale uses the format of the language of the user
+const (
+	DateFormatLocale = "locale"
+	DateFormatISO    = "iso"
+	DateFormatDMY    = "dmy"
+	DateFormatMDY    = "mdy"
+)
+
+// DateFormats are the date formats in the order they're offered in the settings
+var DateFormats = []string{DateFormatLocale, DateFormatISO, DateFormatDMY, DateFormatMDY}
+
+// Timezones are the timezones offered in the settings, any other IANA timezone is accepted too
+var Timezones = []string{
+	"UTC",
+	"Africa/Cairo",
+	"Africa/Johannesburg",
+	"Africa/Lagos",
+	"America/Anchorage",
+	"America/Argentina/Buenos_Aires",
+	"America/Bogota",
+	"America/Chicago",
+	"America/Denver",
+	"America/Halifax",
+	"America/Los_Angeles",
+	"America/Mexico_City",
+	"America/New_York",
+	"America/Santiago",
+	"America/Sao_Paulo",
+	"America/Toronto",
+	"Asia/Bangkok",
+	"Asia/Dubai",
+	"Asia/Hong_Kong",
+	"Asia/Jakarta",
+	"Asia/Kolkata",
+	"Asia/Seoul",
+	"Asia/Shanghai",
+	"Asia/Singapore",
+	"Asia/Tokyo",
+	"Atlantic/Canary",
+	"Australia/Perth",
+	"Australia/Sydney",
+	"Europe/Amsterdam",
+	"Europe/Athens",
+	"Europe/Berlin",
+	"Europe/Dublin",
+	"Europe/Helsinki",
+	"Europe/Istanbul",
+	"Europe/Lisbon",
+	"Europe/London",
+	"Europe/Madrid",
+	"Europe/Moscow",
+	"Europe/Paris",
+	"Europe/Rome",
+	"Europe/Warsaw",
+	"Europe/Zurich",
+	"Pacific/Auckland",
+	"Pacific/Honolulu",
+}
+
+// IsValidDateFormat returns true if the format is one of the supported date formats or empty,
+// an empty format is inherited from the tenant or the global settings
+func IsValidDateFormat(format string) bool {
+	if format == "" {
+		return true
+	}
+	return slices.Contains(DateFormats, format)
+}
+
+// IsValidTimezone returns true if the timezone is an IANA timezone or empty, an empty timezone
+// is inherited from the tenant or the global settings
+func IsValidTimezone(timezone string) bool {
+	if timezone == "" {
+		return true
+	}
+	_, err := time.LoadLocation(timezone)
+	return err == nil
+}
+
+// LoadTimezone returns the location of the timezone or the server time if it's empty or unknown
+func LoadTimezone(timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// DateFormatter shows dates in the timezone and the date format chosen for the tenant or the user
+// and reads the dates entered in the forms in that timezone
+type DateFormatter struct {
+	Location   *time.Location
+	Format     string
+	Translator locales.Translator
+}
+
+func NewDateFormatter(translator locales.Translator, timezone, format string) DateFormatter {
+	return DateFormatter{Location: LoadTimezone(timezone), Format: format, Translator: translator}
+}
+
+// In returns the time in the timezone of the formatter
+func (f DateFormatter) In(t time.Time) time.Time {
+	if f.Location == nil {
+		return t.Local()
+	}
+	return t.In(f.Location)
+}
+
+// Date formats the day of the time
+func (f DateFormatter) Date(t time.Time) string {
+	t = f.In(t)
+	switch f.Format {
+	case DateFormatISO:
+		return t.Format("2006-01-02")
+	case DateFormatDMY:
+		return t.Format("02/01/2006")
+	case DateFormatMDY:
+		return t.Format("01/02/2006")
+	}
+	if f.Translator == nil {
+		return t.Format("2006-01-02")
+	}
+	return f.Translator.FmtDateMedium(t)
+}
+
+// DateTime formats the day and the time in hours and minutes
+func (f DateFormatter) DateTime(t time.Time) string {
+	t = f.In(t)
+	switch f.Format {
+	case DateFormatISO:
+		return t.Format("2006-01-02 15:04")
+	case DateFormatDMY:
+		return t.Format("02/01/2006 15:04")
+	case DateFormatMDY:
+		return t.Format("01/02/2006 3:04 PM")
+	}
+	if f.Translator == nil {
+		return t.Format("2006-01-02 15:04")
+	}
+	return f.Translator.FmtDateMedium(t) + " " + f.Translator.FmtTimeShort(t)
+}
+
+// DateTimeSeconds formats the day and the time with seconds, used for logs
+func (f DateFormatter) DateTimeSeconds(t time.Time) string {
+	t = f.In(t)
+	switch f.Format {
+	case DateFormatISO:
+		return t.Format("2006-01-02 15:04:05")
+	case DateFormatDMY:
+		return t.Format("02/01/2006 15:04:05")
+	case DateFormatMDY:
+		return t.Format("01/02/2006 3:04:05 PM")
+	}
+	if f.Translator == nil {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return f.Translator.FmtDateMedium(t) + " " + f.Translator.FmtTimeMedium(t)
+}
+
+// Time formats the time of the day with seconds
+func (f DateFormatter) Time(t time.Time) string {
+	t = f.In(t)
+	switch f.Format {
+	case DateFormatISO, DateFormatDMY:
+		return t.Format("15:04:05")
+	case DateFormatMDY:
+		return t.Format("3:04:05 PM")
+	}
+	if f.Translator == nil {
+		return t.Format("15:04:05")
+	}
+	return f.Translator.FmtTimeMedium(t)
+}
+
+// ParseLocal reads a date entered in a form, like a datetime-local input, in the timezone of the formatter.
+// A time skipped when the clocks go forward is moved forward by the gap and a time repeated when the
+// clocks go back is the first of them, so a scheduled task never runs before the time that was entered
+func (f DateFormatter) ParseLocal(layout, value string) (time.Time, error) {
+	wall, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc := f.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	// The offsets in use a day before and after are the only ones the time can have
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+
+	first := wall.Add(-time.Duration(before) * time.Second).In(loc)
+	if sameWallClock(first, wall) {
+		return first, nil
+	}
+
+	second := wall.Add(-time.Duration(after) * time.Second).In(loc)
+	if sameWallClock(second, wall) {
+		return second, nil
+	}
+
+	// The time doesn't exist, with the offset before the change it's moved forward by the gap
+	return first, nil
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).Equal(wall)
+}
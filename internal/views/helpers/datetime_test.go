@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateFormatterFormats(t *testing.T) {
+	utc := time.Date(2026, 7, 5, 14, 7, 9, 0, time.UTC)
+
+	f := NewDateFormatter(nil, "Asia/Tokyo", DateFormatISO)
+	assert.Equal(t, "2026-07-05", f.Date(utc))
+	assert.Equal(t, "2026-07-05 23:07", f.DateTime(utc))
+	assert.Equal(t, "2026-07-05 23:07:09", f.DateTimeSeconds(utc))
+	assert.Equal(t, "23:07:09", f.Time(utc))
+
+	f = NewDateFormatter(nil, "America/Los_Angeles", DateFormatDMY)
+	assert.Equal(t, "05/07/2026 07:07", f.DateTime(utc))
+
+	f = NewDateFormatter(nil, "Pacific/Auckland", DateFormatMDY)
+	assert.Equal(t, "07/06/2026", f.Date(utc), "should use the day in the timezone")
+	assert.Equal(t, "07/06/2026 2:07 AM", f.DateTime(utc))
+	assert.Equal(t, "2:07:09 AM", f.Time(utc))
+}
+
+func TestDateFormatterDSTTransitions(t *testing.T) {
+	f := NewDateFormatter(nil, "Europe/Madrid", DateFormatISO)
+
+	// The clocks go forward from 02:00 CET to 03:00 CEST on 29 March 2026
+	assert.Equal(t, "2026-03-29 01:59", f.DateTime(time.Date(2026, 3, 29, 0, 59, 0, 0, time.UTC)))
+	assert.Equal(t, "2026-03-29 03:00", f.DateTime(time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC)))
+
+	// The clocks go back from 03:00 CEST to 02:00 CET on 25 October 2026, 02:30 happens twice
+	assert.Equal(t, "2026-10-25 02:30", f.DateTime(time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "2026-10-25 02:30", f.DateTime(time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC)))
+}
+
+func TestParseLocalDSTTransitions(t *testing.T) {
+	f := NewDateFormatter(nil, "America/New_York", DateFormatISO)
+	layout := "2006-01-02T15:04"
+
+	when, err := f.ParseLocal(layout, "2026-03-07T02:30")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 7, 7, 30, 0, 0, time.UTC), when.UTC(), "should use the standard time offset")
+
+	when, err = f.ParseLocal(layout, "2026-03-08T12:00")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 8, 16, 0, 0, 0, time.UTC), when.UTC(), "should use the summer time offset after the change")
+
+	// 02:30 doesn't exist on 8 March 2026, the clocks go from 02:00 EST to 03:00 EDT
+	when, err = f.ParseLocal(layout, "2026-03-08T02:30")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC), when.UTC(), "should move a skipped time forward")
+	assert.Equal(t, "2026-03-08 03:30", f.DateTime(when))
+
+	// 01:30 happens twice on 1 November 2026, the clocks go from 02:00 EDT to 01:00 EST
+	when, err = f.ParseLocal(layout, "2026-11-01T01:30")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), when.UTC(), "should use the first of the repeated times")
+
+	when, err = f.ParseLocal(layout, "2026-11-01T03:00")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC), when.UTC())
+
+	_, err = f.ParseLocal(layout, "tomorrow")
+	assert.Error(t, err)
+}
+
+func TestTimezoneAndDateFormatValidation(t *testing.T) {
+	assert.True(t, IsValidTimezone(""), "should inherit the timezone")
+	assert.True(t, IsValidTimezone("Europe/Berlin"))
+	assert.False(t, IsValidTimezone("Mars/Olympus_Mons"))
+	assert.Equal(t, time.Local, LoadTimezone("Mars/Olympus_Mons"), "should use the server time")
+
+	assert.True(t, IsValidDateFormat(""), "should inherit the date format")
+	assert.True(t, IsValidDateFormat(DateFormatDMY))
+	assert.False(t, IsValidDateFormat("yyyy"))
+}
@@ -0,0 +1,17 @@
+package helpers
+
+// Theme preferences a user can choose, system follows the browser preference
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+)
+
+// IsValidTheme returns true if the theme is one of the supported theme preferences
+func IsValidTheme(theme string) bool {
+	switch theme {
+	case ThemeLight, ThemeDark, ThemeSystem:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package locales
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"testing/fstest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Language is a language the console has been translated to
+type Language struct {
+	Code string
+	Name string
+}
+
+// Languages contains the languages users can choose, names are shown in their own language
+var Languages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "de", Name: "Deutsch"},
+	{Code: "es", Name: "Español"},
+	{Code: "ca", Name: "Català"},
+	{Code: "fr", Name: "Français"},
+	{Code: "no", Name: "Norsk"},
+	{Code: "pt", Name: "Português"},
+}
+
+// IsSupported returns true if the code belongs to one of the available languages
+func IsSupported(code string) bool {
+	for _, l := range Languages {
+		if l.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WithFallback returns the locale files with the keys missing in a translation filled
+// with the ones from the default language, so untranslated keys are shown in the
+// default language instead of the raw key name
+func WithFallback(defaultLocale string) (fs.FS, error) {
+	defaults, err := readLocale(defaultLocale + ".yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(Content, "*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	merged := fstest.MapFS{}
+	for _, file := range files {
+		code := strings.TrimSuffix(path.Base(file), ".yaml")
+
+		translations, err := readLocale(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if code != defaultLocale {
+			fillMissingKeys(translations, defaults)
+		}
+
+		data, err := yaml.Marshal(map[string]any{code: translations})
+		if err != nil {
+			return nil, fmt.Errorf("could not encode locale %s: %v", code, err)
+		}
+		merged[file] = &fstest.MapFile{Data: data}
+	}
+
+	return merged, nil
+}
+
+func readLocale(file string) (map[string]any, error) {
+	data, err := fs.ReadFile(Content, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read locale file %s: %v", file, err)
+	}
+
+	content := map[string]map[string]any{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("could not decode locale file %s: %v", file, err)
+	}
+
+	code := strings.TrimSuffix(path.Base(file), ".yaml")
+	translations, ok := content[code]
+	if !ok {
+		return nil, fmt.Errorf("locale file %s has no %s root key", file, code)
+	}
+
+	return translations, nil
+}
+
+func fillMissingKeys(translations, defaults map[string]any) {
+	for key, value := range defaults {
+		current, ok := translations[key]
+		if !ok {
+			translations[key] = value
+			continue
+		}
+
+		currentSection, currentIsMap := current.(map[string]any)
+		defaultSection, defaultIsMap := value.(map[string]any)
+		if currentIsMap && defaultIsMap {
+			fillMissingKeys(currentSection, defaultSection)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package locales
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWithFallback(t *testing.T) {
+	translations, err := WithFallback("en")
+	assert.NoError(t, err, "should prepare translations")
+
+	files, err := fs.Glob(translations, "*.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, len(Languages), len(files), "should have a file for every language")
+
+	data, err := fs.ReadFile(translations, "es.yaml")
+	assert.NoError(t, err)
+
+	content := map[string]map[string]any{}
+	assert.NoError(t, yaml.Unmarshal(data, &content))
+
+	section, ok := content["es"]["allowlist"].(map[string]any)
+	assert.True(t, ok, "should add sections missing in the translation")
+	assert.Equal(t, "Admin Allowlist", section["title"], "should use the default language for missing keys")
+
+	section, ok = content["es"]["language"].(map[string]any)
+	assert.True(t, ok)
+	assert.Equal(t, "Idioma", section["title"], "should keep existing translations")
+
+	data, err = fs.ReadFile(translations, "no.yaml")
+	assert.NoError(t, err)
+	content = map[string]map[string]any{}
+	assert.NoError(t, yaml.Unmarshal(data, &content))
+	_, ok = content["no"]
+	assert.True(t, ok, "should keep the no root key as a string")
+}
+
+func TestIsSupported(t *testing.T) {
+	assert.True(t, IsSupported("de"))
+	assert.False(t, IsSupported("xx"))
+	assert.False(t, IsSupported(""))
+}
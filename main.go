@@ -26,5 +26,9 @@ func getCommands() []*cli.Command {
 	return []*cli.Command{
 		commands.StartConsole(),
 		commands.StopConsole(),
+		commands.SendWeeklyReports(),
+		commands.ResetPassword(),
+		commands.EncryptSecrets(),
+		commands.RotateSecretsKey(),
 	}
 }